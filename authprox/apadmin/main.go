@@ -1,46 +1,185 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
-	"net"
-	"net/url"
+	"io/ioutil"
 	"os"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	cli "github.com/urfave/cli"
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/authprox"
 	"go.dedis.ch/cothority/v3/byzcoin/bcadmin/lib"
-	"go.dedis.ch/kyber/v3"
-	"go.dedis.ch/kyber/v3/share"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/app"
 	"go.dedis.ch/onet/v3/cfgpath"
 	"go.dedis.ch/onet/v3/log"
-	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
 )
 
+// The flags naming the enrollment.
+var (
+	typeFlag = cli.StringFlag{
+		Name:  "type",
+		Usage: "the type of validator: oidc (OpenID Connect), saml, ldap or webauthn",
+		Value: "oidc",
+	}
+	issuerFlag = cli.StringFlag{
+		Name:  "issuer",
+		Usage: "the identity provider: the Issuer URL for oidc, the entity ID for saml, the URL for ldap, the relying party ID for webauthn",
+	}
+)
+
+// validatorFlags give the configuration of the validators needing one.
+var validatorFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "cert",
+		Usage: "saml: the PEM file of the certificate of the identity provider; ldap: the PEM file of the authority of the directory certificate, optional",
+	},
+	cli.StringFlag{
+		Name:  "audience",
+		Usage: "saml: the entity ID of the service provider",
+	},
+	cli.StringFlag{
+		Name:  "destination",
+		Usage: "saml: the URL of the assertion consumer service of the service provider",
+	},
+	cli.StringFlag{
+		Name:  "bind-dn",
+		Usage: "ldap: the DN to bind as, with %s for the username, e.g. uid=%s,ou=people,dc=example,dc=com",
+	},
+	cli.StringFlag{
+		Name:  "origin",
+		Usage: "webauthn: the origin of the login page, e.g. https://login.example.com",
+	},
+	cli.StringFlag{
+		Name:  "credentials",
+		Usage: "webauthn: the TOML file of the registered credentials",
+	},
+}
+
+var privateFlag = cli.StringFlag{
+	Name:  "private",
+	Usage: "the private.toml of the conode of the operator",
+}
+
+var versionFlag = cli.IntFlag{
+	Name:  "version",
+	Usage: "the version of the new configuration: the one shown by \"apadmin show\" plus one",
+}
+
 var cmds = cli.Commands{
 	{
 		Name:  "add",
 		Usage: "add an external identity provider",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			cli.StringFlag{
 				Name:  "roster, r",
 				Usage: "the roster of the cothority that hosts the distributed Authentication Proxy",
 			},
+			typeFlag,
+			issuerFlag,
+			cli.StringSliceFlag{
+				Name:  "signature",
+				Usage: "a file written by \"apadmin sign enroll\" by the operator of an authentication proxy of the roster, repeated for each of them",
+			},
+		}, validatorFlags...),
+		Action: add,
+	},
+	{
+		Name:  "reshare",
+		Usage: "reshare the key of an external identity provider to a new roster",
+		Flags: []cli.Flag{
 			cli.StringFlag{
-				Name:  "type",
-				Usage: "the type of validator: oidc (OpenID Connect)",
-				Value: "oidc",
+				Name:  "roster, r",
+				Usage: "the new roster, whose first proxy must be a current one",
 			},
 			cli.StringFlag{
-				Name:  "issuer",
-				Usage: "the URL of the identity provider (for OpenID Connect, this is the Issuer URL)",
+				Name:  "old",
+				Usage: "the roster currently holding the enrollment, in the order it was given",
+			},
+			typeFlag,
+			issuerFlag,
+			cli.StringSliceFlag{
+				Name:  "signature",
+				Usage: "a file written by \"apadmin sign reshare\" by the operator of a current authentication proxy, repeated for at least a threshold of them",
+			},
+		},
+		Action: reshare,
+	},
+	{
+		Name:  "update",
+		Usage: "replace the configuration of an external identity provider, keeping its key",
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  "roster, r",
+				Usage: "the roster holding the enrollment, in the order it was given",
+			},
+			typeFlag,
+			issuerFlag,
+			versionFlag,
+			cli.StringSliceFlag{
+				Name:  "signature",
+				Usage: "a file written by \"apadmin sign update\" by the operator of an authentication proxy of the roster, repeated for at least a threshold of them",
+			},
+		}, validatorFlags...),
+		Action: update,
+	},
+	{
+		Name:  "sign",
+		Usage: "sign a request with the private key of the conode of an operator",
+		Subcommands: cli.Commands{
+			{
+				Name:      "enroll",
+				Usage:     "allow adding an external identity provider, given with the flags of \"apadmin add\"",
+				ArgsUsage: "file",
+				Flags: append([]cli.Flag{
+					cli.StringFlag{
+						Name:  "roster, r",
+						Usage: "the roster of the cothority that hosts the distributed Authentication Proxy",
+					},
+					typeFlag,
+					issuerFlag,
+					privateFlag,
+				}, validatorFlags...),
+				Action: signEnroll,
+			},
+			{
+				Name:      "reshare",
+				Usage:     "allow resharing the key of an external identity provider to a new roster",
+				ArgsUsage: "file",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "roster, r",
+						Usage: "the new roster",
+					},
+					typeFlag,
+					issuerFlag,
+					privateFlag,
+				},
+				Action: signReshare,
+			},
+			{
+				Name:      "update",
+				Usage:     "allow replacing the configuration of an external identity provider, given with the flags of \"apadmin update\"",
+				ArgsUsage: "file",
+				Flags: append([]cli.Flag{
+					typeFlag,
+					issuerFlag,
+					versionFlag,
+					privateFlag,
+				}, validatorFlags...),
+				Action: signUpdate,
 			},
 		},
-		Action: add,
 	},
 	{
 		Name:  "show",
@@ -101,12 +240,7 @@ func show(c *cli.Context) error {
 	if fn == "" {
 		return errors.New("--roster flag is required")
 	}
-
-	in, err := os.Open(fn)
-	if err != nil {
-		return fmt.Errorf("Could not open roster %v: %v", fn, err)
-	}
-	roster, err := readRoster(in)
+	roster, err := openRoster(fn)
 	if err != nil {
 		return err
 	}
@@ -119,7 +253,7 @@ func show(c *cli.Context) error {
 	}
 
 	for _, x := range resp.Enrollments {
-		fmt.Println(x.Type, x.Issuer, x.Public)
+		fmt.Println(x.Type, x.Issuer, x.Public, x.Version)
 	}
 	return nil
 }
@@ -135,93 +269,413 @@ func add(c *cli.Context) error {
 	if fn == "" {
 		return errors.New("--roster flag is required")
 	}
+	roster, err := openRoster(fn)
+	if err != nil {
+		return err
+	}
 
-	in, err := os.Open(fn)
+	config, err := validatorConfig(c)
 	if err != nil {
-		return fmt.Errorf("Could not open roster %v: %v", fn, err)
+		return err
 	}
-	roster, err := readRoster(in)
+
+	// Each authentication proxy needs to allow the enrollment.
+	msg, err := authprox.EnrollMessage(c.String("type"), is, roster, config)
 	if err != nil {
 		return err
 	}
+	sigs, err := readSignatures(c, roster, msg)
+	if err != nil {
+		return err
+	}
+	for i, sig := range sigs {
+		if sig == nil {
+			return fmt.Errorf("missing signature of %v", roster.List[i])
+		}
+	}
 
-	// Get all the key material ready.
+	// The authentication proxies run a DKG among them, started by the
+	// first one, so that the secret is never known to anyone.
+	cl := onet.NewClient(cothority.Suite, authprox.ServiceName)
+	req := &authprox.EnrollRequest{
+		Type:       c.String("type"),
+		Issuer:     is,
+		Roster:     *roster,
+		Config:     config,
+		Signatures: sigs,
+	}
+	resp := &authprox.EnrollResponse{}
+	err = cl.SendProtobuf(roster.List[0], req, resp)
+	if err != nil {
+		return fmt.Errorf("cannot enroll with %v: %v", roster.List[0], err)
+	}
 
-	// n: how many auth proxies will be holding shares
-	n := len(roster.List)
-	T := threshold(n)
+	fmt.Fprintln(c.App.Writer, "External provider enrolled. Use identities of this form:")
+	fmt.Fprintf(c.App.Writer, "\tproxy:%v:user@example.com\n", resp.Public)
+	return nil
+}
 
-	pubs := make([]kyber.Point, n)
-	for i := range pubs {
-		pubs[i] = roster.List[i].Public
+func reshare(c *cli.Context) error {
+	is := c.String("issuer")
+	if is == "" {
+		return errors.New("--issuer flag is required")
+	}
+	if c.String("roster") == "" {
+		return errors.New("--roster flag is required")
+	}
+	if c.String("old") == "" {
+		return errors.New("--old flag is required")
+	}
+	roster, err := openRoster(c.String("roster"))
+	if err != nil {
+		return err
+	}
+	old, err := openRoster(c.String("old"))
+	if err != nil {
+		return err
 	}
 
-	lPri := share.NewPriPoly(cothority.Suite, T, nil, cothority.Suite.RandomStream())
-	lShares := lPri.Shares(n)
-	lPub := lPri.Commit(nil)
-	_, lPubCommits := lPub.Info()
-
-	for i, s := range roster.List {
-		if !(isLoopback(s.Address) || isHTTPS(s.URL)) {
-			return fmt.Errorf("cannot send secret over insecure channel to %v", s)
-		}
-		cl := onet.NewClient(cothority.Suite, authprox.ServiceName)
-		lpri := authprox.PriShare{
-			I: lShares[i].I,
-			V: lShares[i].V,
-		}
-		req := &authprox.EnrollRequest{
-			Type:         c.String("type"),
-			Issuer:       is,
-			Participants: pubs,
-			LongPri:      lpri,
-			LongPubs:     lPubCommits,
+	// A threshold of the current authentication proxies need to allow the
+	// resharing.
+	typ := c.String("type")
+	msg, err := authprox.ReshareMessage(typ, is, roster)
+	if err != nil {
+		return err
+	}
+	sigs, err := readSignatures(c, old, msg)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, sig := range sigs {
+		if sig != nil {
+			n++
 		}
-		resp := &authprox.EnrollResponse{}
-		err := cl.SendProtobuf(s, req, resp)
+	}
+	if t := len(old.List) - (len(old.List)-1)/3; n < t {
+		return fmt.Errorf("need the signatures of %d current authentication proxies, got %d", t, n)
+	}
+
+	cl := onet.NewClient(cothority.Suite, authprox.ServiceName)
+	req := &authprox.ReshareRequest{
+		Type:       typ,
+		Issuer:     is,
+		Roster:     *roster,
+		OldRoster:  *old,
+		Signatures: sigs,
+	}
+	resp := &authprox.ReshareResponse{}
+	err = cl.SendProtobuf(roster.List[0], req, resp)
+	if err != nil {
+		return fmt.Errorf("cannot reshare with %v: %v", roster.List[0], err)
+	}
+
+	fmt.Fprintf(c.App.Writer, "External provider reshared to %d authentication proxies.\n",
+		len(roster.List))
+	return nil
+}
+
+func update(c *cli.Context) error {
+	is := c.String("issuer")
+	if is == "" {
+		return errors.New("--issuer flag is required")
+	}
+	if c.String("roster") == "" {
+		return errors.New("--roster flag is required")
+	}
+	roster, err := openRoster(c.String("roster"))
+	if err != nil {
+		return err
+	}
+	config, err := validatorConfig(c)
+	if err != nil {
+		return err
+	}
+
+	// A threshold of the authentication proxies need to allow the update.
+	typ := c.String("type")
+	msg := authprox.UpdateMessage(typ, is, config, c.Int("version"))
+	sigs, err := readSignatures(c, roster, msg)
+	if err != nil {
+		return err
+	}
+
+	// Every authentication proxy stores its configuration.
+	cl := onet.NewClient(cothority.Suite, authprox.ServiceName)
+	req := &authprox.UpdateRequest{
+		Type:       typ,
+		Issuer:     is,
+		Config:     config,
+		Version:    c.Int("version"),
+		Signatures: sigs,
+	}
+	var failed []string
+	for _, si := range roster.List {
+		err := cl.SendProtobuf(si, req, &authprox.UpdateResponse{})
 		if err != nil {
-			return fmt.Errorf("cannot enroll with %v: %v", s, err)
+			log.Errorf("cannot update %v: %v", si, err)
+			failed = append(failed, si.String())
 		}
 	}
+	if len(failed) > 0 {
+		return fmt.Errorf("the update failed on %v: run the same command again",
+			strings.Join(failed, ", "))
+	}
 
-	fmt.Fprintln(c.App.Writer, "External provider enrolled. Use identities of this form:")
-	fmt.Fprintf(c.App.Writer, "\tproxy:%v:user@example.com\n", lPubCommits[0])
+	fmt.Fprintf(c.App.Writer, "External provider updated to version %d.\n", c.Int("version"))
 	return nil
 }
 
-func readRoster(r io.Reader) (*onet.Roster, error) {
-	group, err := app.ReadGroupDescToml(r)
+func signEnroll(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("please give the file to write the signature to")
+	}
+	is := c.String("issuer")
+	if is == "" {
+		return errors.New("--issuer flag is required")
+	}
+	if c.String("roster") == "" {
+		return errors.New("--roster flag is required")
+	}
+	roster, err := openRoster(c.String("roster"))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	config, err := validatorConfig(c)
+	if err != nil {
+		return err
+	}
+	msg, err := authprox.EnrollMessage(c.String("type"), is, roster, config)
+	if err != nil {
+		return err
+	}
+	return writeSignature(c, msg)
+}
 
-	if len(group.Roster.List) == 0 {
-		return nil, errors.New("empty roster")
+func signReshare(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("please give the file to write the signature to")
 	}
-	return group.Roster, nil
+	is := c.String("issuer")
+	if is == "" {
+		return errors.New("--issuer flag is required")
+	}
+	if c.String("roster") == "" {
+		return errors.New("--roster flag is required")
+	}
+	roster, err := openRoster(c.String("roster"))
+	if err != nil {
+		return err
+	}
+	msg, err := authprox.ReshareMessage(c.String("type"), is, roster)
+	if err != nil {
+		return err
+	}
+	return writeSignature(c, msg)
+}
+
+func signUpdate(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("please give the file to write the signature to")
+	}
+	is := c.String("issuer")
+	if is == "" {
+		return errors.New("--issuer flag is required")
+	}
+	config, err := validatorConfig(c)
+	if err != nil {
+		return err
+	}
+	return writeSignature(c, authprox.UpdateMessage(c.String("type"), is, config, c.Int("version")))
+}
+
+// signatureFile is the file written by "apadmin sign", holding the
+// signature of a request by the conode of an operator.
+type signatureFile struct {
+	Public    string
+	Signature string
+}
+
+// writeSignature signs the message with the private key of the conode whose
+// private.toml is given with the --private flag, and writes it to the file
+// given as argument, so that the private key never leaves the operator.
+func writeSignature(c *cli.Context, msg []byte) error {
+	fn := c.String("private")
+	if fn == "" {
+		return errors.New("--private flag is required")
+	}
+	cfg, err := app.LoadCothority(fn)
+	if err != nil {
+		return fmt.Errorf("cannot load %v: %v", fn, err)
+	}
+	si, err := cfg.GetServerIdentity()
+	if err != nil {
+		return fmt.Errorf("cannot load %v: %v", fn, err)
+	}
+	sig, err := schnorr.Sign(cothority.Suite, si.GetPrivate(), msg)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = toml.NewEncoder(&buf).Encode(&signatureFile{
+		Public:    si.Public.String(),
+		Signature: hex.EncodeToString(sig),
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.Args().First(), buf.Bytes(), 0644)
+}
+
+// readSignatures reads the files given with the --signature flag, and
+// returns the signatures of the message by the proxies of the roster, in its
+// order. The signatures of the proxies without a file are left empty.
+func readSignatures(c *cli.Context, roster *onet.Roster, msg []byte) ([][]byte, error) {
+	sigs := make([][]byte, len(roster.List))
+	for _, fn := range c.StringSlice("signature") {
+		var f signatureFile
+		if _, err := toml.DecodeFile(fn, &f); err != nil {
+			return nil, fmt.Errorf("cannot read signature %v: %v", fn, err)
+		}
+		i := -1
+		for j, si := range roster.List {
+			if si.Public.String() == f.Public {
+				i = j
+			}
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("signature %v is not from a proxy of the roster", fn)
+		}
+		sig, err := hex.DecodeString(f.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read signature %v: %v", fn, err)
+		}
+
+		// Check it here, as the proxies only tell that one is wrong.
+		if err := schnorr.Verify(cothority.Suite, roster.List[i].Public, msg, sig); err != nil {
+			return nil, fmt.Errorf("signature %v is not for this request: %v", fn, err)
+		}
+		sigs[i] = sig
+	}
+	return sigs, nil
+}
+
+// validatorConfig returns the configuration of the validators needing one.
+func validatorConfig(c *cli.Context) ([]byte, error) {
+	switch c.String("type") {
+	case "saml":
+		if c.String("cert") == "" || c.String("audience") == "" || c.String("destination") == "" {
+			return nil, errors.New("--cert, --audience and --destination flags are required for saml")
+		}
+		cert, err := readPEM(c.String("cert"), "CERTIFICATE")
+		if err != nil {
+			return nil, err
+		}
+		return protobuf.Encode(&authprox.SAMLConfig{
+			Certificate: cert,
+			Audience:    c.String("audience"),
+			Destination: c.String("destination"),
+		})
+	case "ldap":
+		if c.String("bind-dn") == "" {
+			return nil, errors.New("--bind-dn flag is required for ldap")
+		}
+		cfg := &authprox.LDAPConfig{BindDN: c.String("bind-dn")}
+		if c.String("cert") != "" {
+			cert, err := readPEM(c.String("cert"), "CERTIFICATE")
+			if err != nil {
+				return nil, err
+			}
+			cfg.Certificate = cert
+		}
+		return protobuf.Encode(cfg)
+	case "webauthn":
+		if c.String("origin") == "" || c.String("credentials") == "" {
+			return nil, errors.New("--origin and --credentials flags are required for webauthn")
+		}
+		creds, err := readCredentials(c.String("credentials"))
+		if err != nil {
+			return nil, err
+		}
+		return protobuf.Encode(&authprox.WebAuthnConfig{
+			Origin:      c.String("origin"),
+			Credentials: creds,
+		})
+	}
+	return nil, nil
 }
 
-func faultThreshold(n int) int {
-	return (n - 1) / 3
+// readCredentials reads WebAuthn credentials from a TOML file of this form:
+//
+//	[[Credentials]]
+//	  ID = "base64url encoded credential ID"
+//	  User = "user@example.com"
+//	  PublicKey = """
+//	-----BEGIN PUBLIC KEY-----
+//	...
+//	-----END PUBLIC KEY-----
+//	"""
+func readCredentials(fn string) ([]authprox.WebAuthnCredential, error) {
+	var file struct {
+		Credentials []struct {
+			ID        string
+			User      string
+			PublicKey string
+		}
+	}
+	if _, err := toml.DecodeFile(fn, &file); err != nil {
+		return nil, fmt.Errorf("cannot read credentials %v: %v", fn, err)
+	}
+
+	var creds []authprox.WebAuthnCredential
+	for _, c := range file.Credentials {
+		id, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(c.ID, "="))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode ID of %v: %v", c.User, err)
+		}
+		block, _ := pem.Decode([]byte(c.PublicKey))
+		if block == nil || block.Type != "PUBLIC KEY" {
+			return nil, fmt.Errorf("no public key for %v", c.User)
+		}
+		creds = append(creds, authprox.WebAuthnCredential{
+			ID:        id,
+			User:      c.User,
+			PublicKey: block.Bytes,
+		})
+	}
+	return creds, nil
 }
 
-func threshold(n int) int {
-	return n - faultThreshold(n)
+func readPEM(fn, typ string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(buf)
+	if block == nil || block.Type != typ {
+		return nil, fmt.Errorf("no %v in %v", typ, fn)
+	}
+	return block.Bytes, nil
 }
 
-func isLoopback(a network.Address) bool {
-	ad := a.Resolve()
-	ip := net.ParseIP(ad)
-	if ip == nil {
-		return false
+func openRoster(fn string) (*onet.Roster, error) {
+	in, err := os.Open(fn)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open roster %v: %v", fn, err)
 	}
-	return ip.IsLoopback()
+	defer in.Close()
+	return readRoster(in)
 }
 
-func isHTTPS(ustr string) bool {
-	u, err := url.Parse(ustr)
+func readRoster(r io.Reader) (*onet.Roster, error) {
+	group, err := app.ReadGroupDescToml(r)
 	if err != nil {
-		return false
+		return nil, err
+	}
+
+	if len(group.Roster.List) == 0 {
+		return nil, errors.New("empty roster")
 	}
-	return u.Scheme == "https"
+	return group.Roster, nil
 }
@@ -21,6 +21,8 @@ main(){
 	run BCSetup
 
 	run testAdd
+	run testReshare
+	run testUpdate
 	stopTest
 }
 
@@ -35,22 +37,90 @@ BCSetup(){
 	testOK ./bcadmin -c . darc rule --rule spawn:authproxAdd --identity $KEY
 }
 
+# signAll makes the operators of the given conodes sign with "apadmin sign",
+# given the subcommand and its flags, into co$i.sig.
+signAll(){
+	local cos=$1
+	shift
+	for i in $cos; do
+		testOK ./apadmin sign "$@" --private co$i/private.toml co$i.sig
+	done
+}
+
+SIGS="--signature co1.sig --signature co2.sig --signature co3.sig"
+
 testAdd(){
 	runCoBG 1 2 3
-	testOK ./apadmin add --roster public.toml -issuer https://oauth.dedis.ch
+	rm -f co*.sig
+
+	# Every proxy needs to sign.
+	signAll "1 2" enroll --roster public.toml -issuer https://oauth.dedis.ch
+	testFail ./apadmin add --roster public.toml -issuer https://oauth.dedis.ch \
+		--signature co1.sig --signature co2.sig
+	signAll 3 enroll --roster public.toml -issuer https://oauth.dedis.ch
+	testFail ./apadmin add --roster public.toml -issuer https://other.dedis.ch $SIGS
+	testOK ./apadmin add --roster public.toml -issuer https://oauth.dedis.ch $SIGS
 	testGrep https://oauth.dedis.ch ./apadmin show --roster public.toml
 
-	# Check that secrets are not sent unencrypted.
+	testFail ./apadmin add --roster public.toml -issuer https://oauth.dedis.ch $SIGS
+
+	# Validators other than oidc need a configuration.
+	testFail ./apadmin sign enroll --roster public.toml --type ldap \
+		-issuer ldap://ldap.dedis.ch --private co1/private.toml co1.sig
+	signAll "1 2 3" enroll --roster public.toml --type ldap -issuer ldap://ldap.dedis.ch \
+		--bind-dn "uid=%s,ou=people,dc=dedis,dc=ch"
+	testOK ./apadmin add --roster public.toml --type ldap -issuer ldap://ldap.dedis.ch \
+		--bind-dn "uid=%s,ou=people,dc=dedis,dc=ch" $SIGS
+	testGrep ldap://ldap.dedis.ch ./apadmin show --roster public.toml
+
+	# Check that enrolling through an unreachable server fails.
 	cat > tcp.toml << %%
 [[servers]]
   Address = "tcp://server.example.com:7002"
   Suite = "Ed25519"
   Public = "805a20360392d3fd5170ad5be862ecaf76b40eeaa0642aa3f96557df563bef8d"
-  Description = "A fake server to test refusing sending secrets over TCP."
+  Description = "A fake server to test enrolling through an unreachable server."
 %%
 	testFail ./apadmin add --roster tcp.toml -issuer https://NOauth.dedis.ch/
-	testNGrep https://NOauth.dedis.ch ./apadmin show --roster tcp.toml
+	testNGrep https://NOauth.dedis.ch ./apadmin show --roster public.toml
+}
+
+testReshare(){
+	runCoBG 1 2 3
+	rm -f co*.sig
+	signAll "1 2 3" enroll --roster public.toml -issuer https://reshare.dedis.ch
+	testOK ./apadmin add --roster public.toml -issuer https://reshare.dedis.ch $SIGS
+	PUB=$(./apadmin show --roster public.toml | grep reshare | cut -d " " -f 3)
+
+	# A threshold of the current proxies, all 3 of them, need to sign.
+	rm -f co*.sig
+	signAll 1 reshare --roster public.toml -issuer https://reshare.dedis.ch
+	testFail ./apadmin reshare --roster public.toml --old public.toml \
+		-issuer https://reshare.dedis.ch --signature co1.sig
+	signAll "2 3" reshare --roster public.toml -issuer https://reshare.dedis.ch
+	testOK ./apadmin reshare --roster public.toml --old public.toml \
+		-issuer https://reshare.dedis.ch $SIGS
+	testGrep "reshare.dedis.ch $PUB" ./apadmin show --roster public.toml
+}
+
+testUpdate(){
+	runCoBG 1 2 3
+	rm -f co*.sig
+	signAll "1 2 3" enroll --roster public.toml --type ldap -issuer ldaps://update.dedis.ch \
+		--bind-dn "uid=%s,ou=people,dc=dedis,dc=ch"
+	testOK ./apadmin add --roster public.toml --type ldap -issuer ldaps://update.dedis.ch \
+		--bind-dn "uid=%s,ou=people,dc=dedis,dc=ch" $SIGS
+	PUB=$(./apadmin show --roster public.toml | grep update | cut -d " " -f 3)
 
+	# The configuration is replaced, keeping the key, with the next version.
+	rm -f co*.sig
+	signAll "1 2 3" update --type ldap -issuer ldaps://update.dedis.ch --version 1 \
+		--bind-dn "uid=%s,ou=staff,dc=dedis,dc=ch"
+	testFail ./apadmin update --roster public.toml --type ldap -issuer ldaps://update.dedis.ch \
+		--version 1 --bind-dn "uid=%s,ou=people,dc=dedis,dc=ch" $SIGS
+	testOK ./apadmin update --roster public.toml --type ldap -issuer ldaps://update.dedis.ch \
+		--version 1 --bind-dn "uid=%s,ou=staff,dc=dedis,dc=ch" $SIGS
+	testGrep "update.dedis.ch $PUB 1" ./apadmin show --roster public.toml
 }
 
 main
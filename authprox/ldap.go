@@ -0,0 +1,161 @@
+package authprox
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"go.dedis.ch/protobuf"
+)
+
+// ldapTimeout is how long the LDAP validator waits for the directory.
+const ldapTimeout = 10 * time.Second
+
+// NewLDAPValidator returns a Validator for LDAP directories, which does a
+// simple bind with the LDAPAuthInfo. The issuer is the URL of the directory,
+// and the claim is the username. The auth info carries no hash. As the bind
+// sends the password, it is only done over TLS: directly for ldaps:// URLs,
+// and after a StartTLS for ldap:// URLs.
+func NewLDAPValidator() ConfigurableValidator {
+	return &ldapValidator{}
+}
+
+type ldapValidator struct {
+	url    string
+	bindDN string
+	// roots are the authorities of the directory certificate, nil for the
+	// ones of the system.
+	roots *x509.CertPool
+}
+
+func (l *ldapValidator) Configure(issuer string, config []byte) (Validator, error) {
+	var cfg LDAPConfig
+	if err := protobuf.Decode(config, &cfg); err != nil {
+		return nil, fmt.Errorf("decoding LDAP config: %v", err)
+	}
+	if !strings.HasPrefix(issuer, "ldap://") && !strings.HasPrefix(issuer, "ldaps://") {
+		return nil, errors.New("the issuer must be an ldap:// or ldaps:// URL")
+	}
+	if strings.Count(cfg.BindDN, "%s") != 1 || strings.Count(cfg.BindDN, "%") != 1 {
+		return nil, errors.New("the bind DN must contain a single %s")
+	}
+	if _, err := url.Parse(issuer); err != nil {
+		return nil, fmt.Errorf("parsing the issuer URL: %v", err)
+	}
+	v := &ldapValidator{url: issuer, bindDN: cfg.BindDN}
+	if len(cfg.Certificate) != 0 {
+		cert, err := x509.ParseCertificate(cfg.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate: %v", err)
+		}
+		v.roots = x509.NewCertPool()
+		v.roots.AddCert(cert)
+	}
+	return v, nil
+}
+
+func (l *ldapValidator) FindClaim(issuer string, authInfo []byte) (string, string, error) {
+	if l.bindDN == "" {
+		return "", "", errors.New("LDAP validator is not configured")
+	}
+	if issuer != l.url {
+		return "", "", errors.New("wrong issuer")
+	}
+
+	var ai LDAPAuthInfo
+	if err := protobuf.Decode(authInfo, &ai); err != nil {
+		return "", "", fmt.Errorf("decoding auth info: %v", err)
+	}
+	if ai.Username == "" {
+		return "", "", errors.New("empty username")
+	}
+	// An empty password would be an unauthenticated bind, which succeeds.
+	if ai.Password == "" {
+		return "", "", errors.New("empty password")
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+
+	err = conn.Bind(fmt.Sprintf(l.bindDN, escapeDN(ai.Username)), ai.Password)
+	if err != nil {
+		return "", "", fmt.Errorf("bind failed: %v", err)
+	}
+	return ai.Username, "", nil
+}
+
+// dial connects to the directory over TLS.
+func (l *ldapValidator) dial() (*ldap.Conn, error) {
+	u, err := url.Parse(l.url)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		ServerName: u.Hostname(),
+		RootCAs:    l.roots,
+	}
+	dialer := &net.Dialer{Timeout: ldapTimeout}
+
+	var conn *ldap.Conn
+	switch u.Scheme {
+	case "ldaps":
+		addr := u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), ldap.DefaultLdapsPort)
+		}
+		c, err := tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		conn = ldap.NewConn(c, true)
+		conn.Start()
+		conn.SetTimeout(ldapTimeout)
+	case "ldap":
+		addr := u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), ldap.DefaultLdapPort)
+		}
+		c, err := dialer.Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		conn = ldap.NewConn(c, false)
+		conn.Start()
+		conn.SetTimeout(ldapTimeout)
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS failed: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported scheme %v", u.Scheme)
+	}
+	return conn, nil
+}
+
+// escapeDN escapes a value to be used in a DN, as described in RFC 4514.
+func escapeDN(v string) string {
+	var b strings.Builder
+	for i, c := range v {
+		switch {
+		case strings.ContainsRune(`"+,;<>\=`, c),
+			i == 0 && (c == ' ' || c == '#'),
+			i == len(v)-1 && c == ' ':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case c == 0:
+			b.WriteString(`\00`)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,181 @@
+package authprox
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/protobuf"
+)
+
+func TestLDAPValidator(t *testing.T) {
+	srv := newLDAPStandIn(t, map[string]string{
+		"uid=alice,ou=people,dc=example,dc=com": "secret",
+	})
+	defer srv.Close()
+
+	// The bind DN needs a single place for the username.
+	bad, err := protobuf.Encode(&LDAPConfig{BindDN: "ou=people,dc=example,dc=com"})
+	require.NoError(t, err)
+	_, err = NewLDAPValidator().Configure(srv.url(), bad)
+	require.Error(t, err)
+
+	_, err = NewLDAPValidator().Configure(srv.url(), []byte("not a config"))
+	require.Error(t, err)
+	bad, err = protobuf.Encode(&LDAPConfig{
+		BindDN:      "uid=%s,ou=people,dc=example,dc=com",
+		Certificate: []byte("not a certificate"),
+	})
+	require.NoError(t, err)
+	_, err = NewLDAPValidator().Configure(srv.url(), bad)
+	require.Error(t, err)
+
+	config, err := protobuf.Encode(&LDAPConfig{
+		BindDN:      "uid=%s,ou=people,dc=example,dc=com",
+		Certificate: srv.cert,
+	})
+	require.NoError(t, err)
+	_, err = NewLDAPValidator().Configure("https://ldap.example.com", config)
+	require.Error(t, err)
+	v, err := NewLDAPValidator().Configure(srv.url(), config)
+	require.NoError(t, err)
+
+	// An unconfigured validator refuses everything.
+	ai, err := protobuf.Encode(&LDAPAuthInfo{Username: "alice", Password: "secret"})
+	require.NoError(t, err)
+	_, _, err = NewLDAPValidator().FindClaim(srv.url(), ai)
+	require.Error(t, err)
+
+	claim, hash, err := v.FindClaim(srv.url(), ai)
+	require.NoError(t, err)
+	require.Equal(t, "alice", claim)
+	require.Equal(t, "", hash)
+
+	_, _, err = v.FindClaim("ldap://other.example.com", ai)
+	require.Error(t, err)
+
+	// The password is not sent if the certificate of the directory can't
+	// be checked.
+	untrusted, err := protobuf.Encode(&LDAPConfig{BindDN: "uid=%s,ou=people,dc=example,dc=com"})
+	require.NoError(t, err)
+	u, err := NewLDAPValidator().Configure(srv.url(), untrusted)
+	require.NoError(t, err)
+	_, _, err = u.FindClaim(srv.url(), ai)
+	require.Error(t, err)
+
+	// Nor if the directory doesn't do StartTLS.
+	plain := "ldap://" + srv.Addr().String()
+	p, err := NewLDAPValidator().Configure(plain, config)
+	require.NoError(t, err)
+	_, _, err = p.FindClaim(plain, ai)
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "StartTLS"))
+
+	for _, wrong := range []LDAPAuthInfo{
+		{Username: "alice", Password: "wrong"},
+		{Username: "alice", Password: ""},
+		{Username: "bob", Password: "secret"},
+		{Username: "alice,ou=people", Password: "secret"},
+	} {
+		ai, err := protobuf.Encode(&wrong)
+		require.NoError(t, err)
+		_, _, err = v.FindClaim(srv.url(), ai)
+		require.Error(t, err)
+	}
+}
+
+func TestEscapeDN(t *testing.T) {
+	require.Equal(t, "alice", escapeDN("alice"))
+	require.Equal(t, `a\,b\+c\=d\;e`, escapeDN("a,b+c=d;e"))
+	require.Equal(t, `\#a b\ `, escapeDN("#a b "))
+}
+
+// ldapStandIn is an in-process LDAP server over TLS that only knows simple
+// binds.
+type ldapStandIn struct {
+	net.Listener
+	users map[string]string
+	// cert is the DER encoded self-signed certificate of the server.
+	cert []byte
+}
+
+func newLDAPStandIn(t *testing.T, users map[string]string) *ldapStandIn {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l = tls.NewListener(l, &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{cert}, PrivateKey: key}},
+	})
+	s := &ldapStandIn{Listener: l, users: users, cert: cert}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(c)
+		}
+	}()
+	return s
+}
+
+func (s *ldapStandIn) url() string {
+	return "ldaps://" + s.Addr().String()
+}
+
+func (s *ldapStandIn) handle(c net.Conn) {
+	defer c.Close()
+	for {
+		p, err := ber.ReadPacket(c)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+		op := p.Children[1]
+		if op.Tag != ldap.ApplicationBindRequest || len(op.Children) < 3 {
+			return
+		}
+
+		code := ldap.LDAPResultInvalidCredentials
+		pw, ok := s.users[op.Children[1].Data.String()]
+		if ok && pw == op.Children[2].Data.String() {
+			code = ldap.LDAPResultSuccess
+		}
+
+		resp := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		resp.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, p.Children[0].Value, "Message ID"))
+		bind := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationBindResponse, nil, "Bind Response")
+		bind.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+		bind.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+		bind.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+		resp.AppendChild(bind)
+		if _, err := c.Write(resp.Bytes()); err != nil {
+			return
+		}
+	}
+}
@@ -11,6 +11,13 @@ import (
 	"go.dedis.ch/onet/v3/log"
 )
 
+// NewOIDCValidator returns a Validator for ID tokens of OpenID Connect
+// issuers. The claim is the email of the user, and the hash is the nonce of
+// the token. The validator can be shared between goroutines.
+func NewOIDCValidator() Validator {
+	return &oidcValidator{}
+}
+
 type oidcValidator struct {
 	sync.Mutex
 	issuers []*issuer
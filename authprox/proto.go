@@ -2,58 +2,101 @@ package authprox
 
 import (
 	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/onet/v3"
 )
 
 // PROTOSTART
 // package authprox;
+// import "onet.proto";
+//
 // option java_package = "ch.epfl.dedis.lib.proto";
 // option java_outer_classname = "AuthProxProto";
 
 // EnrollRequest is the request sent to this service to enroll
 // a user, authenticated by a certain type of external authentication.
+// The proxies of the roster run a distributed key generation, so the
+// request must be sent to the first node of the roster. Config is the
+// protobuf encoded configuration of the validator, for the types needing
+// one: SAMLConfig, LDAPConfig or WebAuthnConfig. Signatures holds one
+// signature of EnrollMessage per proxy of the roster, in the order of the
+// roster, made with the private key of the conode.
 type EnrollRequest struct {
-	Type         string
-	Issuer       string
-	Participants []kyber.Point
-	LongPri      PriShare
-	LongPubs     []kyber.Point
+	Type       string
+	Issuer     string
+	Roster     onet.Roster
+	Config     []byte `protobuf:"opt"`
+	Signatures [][]byte
 }
 
 // EnrollResponse is returned when an enrollment has been done correctly.
+// Public is the public key of the enrollment, to be used in proxy
+// identities.
 type EnrollResponse struct {
+	Public kyber.Point
+}
+
+// ReshareRequest asks the proxies holding an enrollment to reshare its
+// secret key to a new roster. The request must be sent to the first node
+// of the new roster, which must be a current participant. OldRoster holds
+// the current participants, in their order, which all take part in the
+// resharing, even if they are not in the new roster. Signatures holds one
+// entry per current participant, in the same order: at least a threshold
+// of them must be signatures of ReshareMessage made with the private key
+// of the conode, and the others can be empty.
+type ReshareRequest struct {
+	Type       string
+	Issuer     string
+	Roster     onet.Roster
+	OldRoster  onet.Roster
+	Signatures [][]byte
+}
+
+// ReshareResponse is returned when the resharing has been done correctly.
+// The public key of the enrollment does not change.
+type ReshareResponse struct {
+	Public kyber.Point
+}
+
+// UpdateRequest replaces the configuration of the validator of an
+// enrollment, e.g. to add or remove WebAuthn credentials, without changing
+// its key. It must be sent to every participant of the enrollment. Version
+// must follow the version of the current configuration, which starts at 0,
+// so that older updates cannot be replayed. Signatures holds one entry per
+// participant, in their order: at least a threshold of them must be
+// signatures of UpdateMessage made with the private key of the conode, and
+// the others can be empty.
+type UpdateRequest struct {
+	Type       string
+	Issuer     string
+	Config     []byte `protobuf:"opt"`
+	Version    int
+	Signatures [][]byte
+}
+
+// UpdateResponse is returned when the configuration has been replaced.
+type UpdateResponse struct {
 }
 
 // SignatureRequest is the request sent to this service to request that
-// the Authentication Proxy check the authentication information and
+// the Authentication Proxies check the authentication information and
 // generate a signature connecting some information identifying the
-// holder of the AuthInfo to the message.
+// holder of the AuthInfo to the message. Roster holds the participants of
+// the enrollment, in their order, and the request must be sent to the
+// first one of them: the proxies generate the random nonce of the
+// signature with a DKG among themselves, so all of them must be online.
 type SignatureRequest struct {
 	Type     string
 	Issuer   string
 	AuthInfo []byte
-	RandPri  PriShare
-	RandPubs []kyber.Point
 	Message  []byte
+	Roster   onet.Roster
 }
 
-// PriShare is a local copy of go.dedis.ch/kyber/v3/share.PriShare
-// because we do not have proto files for Kyber objects.
-type PriShare struct {
-	I int          // Index of the private share
-	V kyber.Scalar // Value of the private share
-}
-
-// PartialSig is a local copy of go.dedis.ch/kyber/v3/sign/dss.PartialSig
-// because we do not have proto files for Kyber objects.
-type PartialSig struct {
-	Partial   PriShare
-	SessionID []byte
-	Signature []byte
-}
-
-// SignatureResponse is the response to a SignMessage request.
+// SignatureResponse is the response to a SignatureRequest. Signature is
+// the Schnorr signature combined from the partial signatures of the
+// proxies, to be verified by proxy identities.
 type SignatureResponse struct {
-	PartialSignature PartialSig
+	Signature []byte
 }
 
 // EnrollmentsRequest gets a list of enrollments, optionally limited
@@ -70,9 +113,71 @@ type EnrollmentsResponse struct {
 	Enrollments []EnrollmentInfo
 }
 
-// EnrollmentInfo is public info about an enrollment.
+// EnrollmentInfo is public info about an enrollment. Version is the
+// version of its configuration.
 type EnrollmentInfo struct {
-	Type   string
-	Issuer string
-	Public kyber.Point
+	Type    string
+	Issuer  string
+	Public  kyber.Point
+	Version int
+}
+
+// SAMLConfig is the enrollment configuration of a SAML identity provider,
+// whose issuer is the entity ID of the identity provider.
+type SAMLConfig struct {
+	// Certificate is the DER encoded certificate the identity provider
+	// signs its responses with.
+	Certificate []byte
+	// Audience is the entity ID of the service provider the assertions
+	// must be intended for.
+	Audience string
+	// Destination is the URL of the assertion consumer service of the
+	// service provider, which the responses and the confirmations of
+	// their subjects must be sent to.
+	Destination string
+}
+
+// LDAPConfig is the enrollment configuration of an LDAP directory, whose
+// issuer is the URL of the directory.
+type LDAPConfig struct {
+	// BindDN is the DN to bind as, with a %s where the escaped username
+	// goes, e.g. "uid=%s,ou=people,dc=example,dc=com".
+	BindDN string
+	// Certificate is the DER encoded certificate of the authority the TLS
+	// certificate of the directory is checked against. If empty, the
+	// authorities of the system are used.
+	Certificate []byte `protobuf:"opt"`
+}
+
+// LDAPAuthInfo is the auth info of the LDAP validator.
+type LDAPAuthInfo struct {
+	Username string
+	Password string
+}
+
+// WebAuthnConfig is the enrollment configuration of a WebAuthn relying
+// party, whose issuer is the ID of the relying party.
+type WebAuthnConfig struct {
+	// Origin is the origin of the web page making the assertions, e.g.
+	// "https://login.example.com".
+	Origin      string
+	Credentials []WebAuthnCredential
+}
+
+// WebAuthnCredential is a public key credential registered for a user.
+type WebAuthnCredential struct {
+	ID   []byte
+	User string
+	// PublicKey is the PKIX DER encoded ECDSA P-256, Ed25519 or RSA public
+	// key of the credential.
+	PublicKey []byte
+}
+
+// WebAuthnAuthInfo is the auth info of the WebAuthn validator, as returned
+// by navigator.credentials.get.
+type WebAuthnAuthInfo struct {
+	CredentialID      []byte
+	AuthenticatorData []byte
+	ClientDataJSON    []byte
+	Signature         []byte
 }
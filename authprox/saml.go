@@ -0,0 +1,321 @@
+package authprox
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.dedis.ch/protobuf"
+)
+
+// Signature algorithms of the HTTP-Redirect binding.
+const (
+	samlRSASHA256   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	samlECDSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+)
+
+const samlSuccess = "urn:oasis:names:tc:SAML:2.0:status:Success"
+
+const samlBearer = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+// samlSkew is the clock skew allowed when checking the validity of an
+// assertion.
+const samlSkew = time.Minute
+
+// samlMaxSize limits the size of an inflated SAML response.
+const samlMaxSize = 1 << 20
+
+// NewSAMLValidator returns a Validator for SAML 2.0 responses sent with the
+// HTTP-Redirect binding, whose signature covers the encoded response, so
+// that no XML canonicalization is needed. The auth info is the query string
+// of the redirect, the issuer is the entity ID of the identity provider and
+// the claim is the NameID of the subject. The hash is the InResponseTo of
+// the response, without its leading underscore: clients bind the message by
+// using "_" and the hex encoded hash of the message as ID of their
+// AuthnRequest.
+//
+// The responses of the HTTP-POST binding are refused: their assertions are
+// signed with XML signatures, which need a canonicalization that is not
+// implemented here.
+func NewSAMLValidator() ConfigurableValidator {
+	return &samlValidator{}
+}
+
+type samlValidator struct {
+	issuer      string
+	cert        *x509.Certificate
+	audience    string
+	destination string
+}
+
+type samlResponse struct {
+	XMLName      xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol Response"`
+	InResponseTo string   `xml:"InResponseTo,attr"`
+	Destination  string   `xml:"Destination,attr"`
+	Issuer       string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	Status       struct {
+		StatusCode struct {
+			Value string `xml:"Value,attr"`
+		} `xml:"StatusCode"`
+	} `xml:"Status"`
+	Assertions []samlAssertion `xml:"urn:oasis:names:tc:SAML:2.0:assertion Assertion"`
+}
+
+type samlAssertion struct {
+	Issuer  string `xml:"Issuer"`
+	Subject struct {
+		NameID        string `xml:"NameID"`
+		Confirmations []struct {
+			Method string `xml:"Method,attr"`
+			Data   struct {
+				Recipient    string `xml:"Recipient,attr"`
+				NotOnOrAfter string `xml:"NotOnOrAfter,attr"`
+				InResponseTo string `xml:"InResponseTo,attr"`
+			} `xml:"SubjectConfirmationData"`
+		} `xml:"SubjectConfirmation"`
+	} `xml:"Subject"`
+	Conditions struct {
+		NotBefore    string   `xml:"NotBefore,attr"`
+		NotOnOrAfter string   `xml:"NotOnOrAfter,attr"`
+		Audiences    []string `xml:"AudienceRestriction>Audience"`
+	} `xml:"Conditions"`
+}
+
+func (v *samlValidator) Configure(issuer string, config []byte) (Validator, error) {
+	var cfg SAMLConfig
+	if err := protobuf.Decode(config, &cfg); err != nil {
+		return nil, fmt.Errorf("decoding SAML config: %v", err)
+	}
+	if issuer == "" {
+		return nil, errors.New("empty issuer")
+	}
+	if cfg.Audience == "" {
+		return nil, errors.New("empty audience")
+	}
+	if cfg.Destination == "" {
+		return nil, errors.New("empty destination")
+	}
+	cert, err := x509.ParseCertificate(cfg.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %v", err)
+	}
+	switch cert.PublicKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, errors.New("unsupported certificate key")
+	}
+	return &samlValidator{
+		issuer:      issuer,
+		cert:        cert,
+		audience:    cfg.Audience,
+		destination: cfg.Destination,
+	}, nil
+}
+
+func (v *samlValidator) FindClaim(issuer string, authInfo []byte) (string, string, error) {
+	if v.cert == nil {
+		return "", "", errors.New("SAML validator is not configured")
+	}
+	if issuer != v.issuer {
+		return "", "", errors.New("wrong issuer")
+	}
+
+	// The signature is over the parameters as they were encoded in the
+	// query, so keep them raw.
+	raw := make(map[string]string)
+	for _, kv := range strings.Split(string(authInfo), "&") {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			return "", "", errors.New("malformed query")
+		}
+		if _, ok := raw[kv[:i]]; ok {
+			return "", "", fmt.Errorf("duplicate parameter %v", kv[:i])
+		}
+		raw[kv[:i]] = kv[i+1:]
+	}
+	if _, ok := raw["Signature"]; !ok {
+		return "", "", errors.New("unsigned query: the HTTP-POST binding is " +
+			"not supported, the response must be sent with the signed " +
+			"HTTP-Redirect binding")
+	}
+	signed := "SAMLResponse=" + raw["SAMLResponse"]
+	if rs, ok := raw["RelayState"]; ok {
+		signed += "&RelayState=" + rs
+	}
+	signed += "&SigAlg=" + raw["SigAlg"]
+
+	values, err := url.ParseQuery(string(authInfo))
+	if err != nil {
+		return "", "", err
+	}
+	sig, err := base64.StdEncoding.DecodeString(values.Get("Signature"))
+	if err != nil {
+		return "", "", fmt.Errorf("decoding signature: %v", err)
+	}
+	err = v.verify(values.Get("SigAlg"), []byte(signed), sig)
+	if err != nil {
+		return "", "", fmt.Errorf("signature verification failed: %v", err)
+	}
+
+	resp, err := decodeSAMLResponse(values.Get("SAMLResponse"))
+	if err != nil {
+		return "", "", err
+	}
+	if err := v.check(resp, time.Now()); err != nil {
+		return "", "", err
+	}
+	return resp.Assertions[0].Subject.NameID,
+		strings.TrimPrefix(resp.InResponseTo, "_"), nil
+}
+
+func (v *samlValidator) verify(sigAlg string, signed, sig []byte) error {
+	h := sha256.Sum256(signed)
+	switch sigAlg {
+	case samlRSASHA256:
+		pub, ok := v.cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("the certificate is not an RSA one")
+		}
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, h[:], sig)
+	case samlECDSASHA256:
+		pub, ok := v.cert.PublicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("the certificate is not an ECDSA one")
+		}
+		// XML signatures concatenate r and s.
+		if len(sig) == 0 || len(sig)%2 != 0 {
+			return errors.New("malformed signature")
+		}
+		r := new(big.Int).SetBytes(sig[:len(sig)/2])
+		s := new(big.Int).SetBytes(sig[len(sig)/2:])
+		if !ecdsa.Verify(pub, h[:], r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported signature algorithm %v", sigAlg)
+}
+
+// check verifies that the response was sent to the destination in response
+// to a request, and holds a single assertion of the issuer, valid at the
+// given time, for the audience and confirmed for the destination.
+func (v *samlValidator) check(resp *samlResponse, now time.Time) error {
+	if resp.Status.StatusCode.Value != samlSuccess {
+		return fmt.Errorf("response status is %v", resp.Status.StatusCode.Value)
+	}
+	if resp.Destination != v.destination {
+		return errors.New("response for another destination")
+	}
+	if resp.InResponseTo == "" {
+		return errors.New("response without InResponseTo")
+	}
+	if resp.Issuer != "" && resp.Issuer != v.issuer {
+		return errors.New("response from another issuer")
+	}
+	if len(resp.Assertions) != 1 {
+		return errors.New("expected a single assertion")
+	}
+	a := resp.Assertions[0]
+	if a.Issuer != v.issuer {
+		return errors.New("assertion from another issuer")
+	}
+	if a.Subject.NameID == "" {
+		return errors.New("assertion without NameID")
+	}
+	if err := v.checkConfirmations(resp, now); err != nil {
+		return err
+	}
+
+	notOnOrAfter, err := time.Parse(time.RFC3339, a.Conditions.NotOnOrAfter)
+	if err != nil {
+		return fmt.Errorf("parsing NotOnOrAfter: %v", err)
+	}
+	if !now.Before(notOnOrAfter.Add(samlSkew)) {
+		return errors.New("the assertion has expired")
+	}
+	if a.Conditions.NotBefore != "" {
+		notBefore, err := time.Parse(time.RFC3339, a.Conditions.NotBefore)
+		if err != nil {
+			return fmt.Errorf("parsing NotBefore: %v", err)
+		}
+		if now.Add(samlSkew).Before(notBefore) {
+			return errors.New("the assertion is not yet valid")
+		}
+	}
+
+	for _, aud := range a.Conditions.Audiences {
+		if aud == v.audience {
+			return nil
+		}
+	}
+	return errors.New("the assertion is for another audience")
+}
+
+// checkConfirmations verifies that the subject of the assertion has a bearer
+// confirmation for the destination and the request of the response, valid at
+// the given time.
+func (v *samlValidator) checkConfirmations(resp *samlResponse, now time.Time) error {
+	var err error
+	for _, c := range resp.Assertions[0].Subject.Confirmations {
+		switch {
+		case c.Method != samlBearer:
+			err = errors.New("the subject confirmation is not a bearer one")
+		case c.Data.Recipient != v.destination:
+			err = errors.New("the subject confirmation is for another recipient")
+		case c.Data.InResponseTo != resp.InResponseTo:
+			err = errors.New("the subject confirmation is for another request")
+		default:
+			var notOnOrAfter time.Time
+			notOnOrAfter, err = time.Parse(time.RFC3339, c.Data.NotOnOrAfter)
+			if err != nil {
+				err = fmt.Errorf("parsing subject confirmation NotOnOrAfter: %v", err)
+			} else if !now.Before(notOnOrAfter.Add(samlSkew)) {
+				err = errors.New("the subject confirmation has expired")
+			} else {
+				return nil
+			}
+		}
+	}
+	if err == nil {
+		err = errors.New("assertion without subject confirmation")
+	}
+	return err
+}
+
+// decodeSAMLResponse decodes a response of the HTTP-Redirect binding: a
+// base64 encoded and DEFLATE compressed XML document.
+func decodeSAMLResponse(encoded string) (*samlResponse, error) {
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decoding response: %v", err)
+	}
+	r := flate.NewReader(bytes.NewReader(compressed))
+	defer r.Close()
+	buf, err := ioutil.ReadAll(io.LimitReader(r, samlMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("inflating response: %v", err)
+	}
+	if len(buf) > samlMaxSize {
+		return nil, errors.New("response too large")
+	}
+
+	var resp samlResponse
+	if err := xml.Unmarshal(buf, &resp); err != nil {
+		return nil, fmt.Errorf("parsing response: %v", err)
+	}
+	return &resp, nil
+}
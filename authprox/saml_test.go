@@ -0,0 +1,210 @@
+package authprox
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/protobuf"
+)
+
+const (
+	testIdP         = "https://idp.example.com"
+	testAudience    = "https://sp.example.com"
+	testDestination = "https://sp.example.com/acs"
+)
+
+func TestSAMLValidator(t *testing.T) {
+	key, cert := samlKey(t)
+	_, otherCert := samlKey(t)
+
+	config, err := protobuf.Encode(&SAMLConfig{
+		Certificate: cert,
+		Audience:    testAudience,
+		Destination: testDestination,
+	})
+	require.NoError(t, err)
+	v, err := NewSAMLValidator().Configure(testIdP, config)
+	require.NoError(t, err)
+
+	// The certificate must be valid, and the destination given.
+	bad, err := protobuf.Encode(&SAMLConfig{
+		Certificate: []byte("cert"),
+		Audience:    testAudience,
+		Destination: testDestination,
+	})
+	require.NoError(t, err)
+	_, err = NewSAMLValidator().Configure(testIdP, bad)
+	require.Error(t, err)
+	bad, err = protobuf.Encode(&SAMLConfig{Certificate: cert, Audience: testAudience})
+	require.NoError(t, err)
+	_, err = NewSAMLValidator().Configure(testIdP, bad)
+	require.Error(t, err)
+
+	h := sha256.Sum256(zero64[:])
+	id := "_" + hex.EncodeToString(h[:])
+	ok := newSAMLTestResponse(id)
+	ai := samlRedirect(t, key, ok.String())
+
+	claim, hash, err := v.FindClaim(testIdP, ai)
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.com", claim)
+	require.Equal(t, hex.EncodeToString(h[:]), hash)
+
+	_, _, err = v.FindClaim("https://other.example.com", ai)
+	require.Error(t, err)
+
+	// The whole query is signed.
+	tampered := bytes.Replace(ai, []byte("RelayState=state"), []byte("RelayState=other"), 1)
+	_, _, err = v.FindClaim(testIdP, tampered)
+	require.Error(t, err)
+
+	// The responses of the HTTP-POST binding have no signature in the
+	// query.
+	unsigned := ai[:bytes.Index(ai, []byte("&Signature="))]
+	_, _, err = v.FindClaim(testIdP, unsigned)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "HTTP-POST")
+
+	// Only the certificate of the enrollment is accepted.
+	config, err = protobuf.Encode(&SAMLConfig{
+		Certificate: otherCert,
+		Audience:    testAudience,
+		Destination: testDestination,
+	})
+	require.NoError(t, err)
+	other, err := NewSAMLValidator().Configure(testIdP, config)
+	require.NoError(t, err)
+	_, _, err = other.FindClaim(testIdP, ai)
+	require.Error(t, err)
+
+	// The response must be for the destination, and the assertion for the
+	// audience, valid, from the issuer and confirmed for the destination
+	// and the request.
+	expired := time.Now().Add(-time.Hour)
+	for _, change := range []func(r *samlTestResponse){
+		func(r *samlTestResponse) { r.Audience = "https://other-sp.example.com" },
+		func(r *samlTestResponse) { r.NotOnOrAfter = expired },
+		func(r *samlTestResponse) { r.Issuer = "https://other.example.com" },
+		func(r *samlTestResponse) { r.Destination = "https://other-sp.example.com/acs" },
+		func(r *samlTestResponse) { r.InResponseTo = "" },
+		func(r *samlTestResponse) { r.Method = "urn:oasis:names:tc:SAML:2.0:cm:sender-vouches" },
+		func(r *samlTestResponse) { r.Recipient = "https://other-sp.example.com/acs" },
+		func(r *samlTestResponse) { r.ConfirmedNotOnOrAfter = expired },
+		func(r *samlTestResponse) { r.ConfirmedInResponseTo = "_other" },
+		func(r *samlTestResponse) { r.Method = "" },
+	} {
+		r := newSAMLTestResponse(id)
+		change(r)
+		_, _, err = v.FindClaim(testIdP, samlRedirect(t, key, r.String()))
+		require.Error(t, err)
+	}
+}
+
+func samlKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return key, cert
+}
+
+// samlTestResponse holds the values of a test SAML response. An empty
+// Method leaves out the subject confirmation.
+type samlTestResponse struct {
+	Issuer                string
+	Audience              string
+	Destination           string
+	InResponseTo          string
+	NotOnOrAfter          time.Time
+	Method                string
+	Recipient             string
+	ConfirmedInResponseTo string
+	ConfirmedNotOnOrAfter time.Time
+}
+
+// newSAMLTestResponse returns a valid response to the request with the
+// given ID.
+func newSAMLTestResponse(inResponseTo string) *samlTestResponse {
+	valid := time.Now().Add(time.Hour)
+	return &samlTestResponse{
+		Issuer:                testIdP,
+		Audience:              testAudience,
+		Destination:           testDestination,
+		InResponseTo:          inResponseTo,
+		NotOnOrAfter:          valid,
+		Method:                samlBearer,
+		Recipient:             testDestination,
+		ConfirmedInResponseTo: inResponseTo,
+		ConfirmedNotOnOrAfter: valid,
+	}
+}
+
+func (r *samlTestResponse) String() string {
+	confirmation := ""
+	if r.Method != "" {
+		confirmation = fmt.Sprintf(`
+      <saml:SubjectConfirmation Method="%s">
+        <saml:SubjectConfirmationData Recipient="%s" InResponseTo="%s" NotOnOrAfter="%s"/>
+      </saml:SubjectConfirmation>`, r.Method, r.Recipient, r.ConfirmedInResponseTo,
+			r.ConfirmedNotOnOrAfter.UTC().Format(time.RFC3339))
+	}
+	return fmt.Sprintf(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"
+	xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"
+	ID="_response" Version="2.0" InResponseTo="%s" Destination="%s">
+  <saml:Issuer>%s</saml:Issuer>
+  <samlp:Status>
+    <samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/>
+  </samlp:Status>
+  <saml:Assertion ID="_assertion" Version="2.0">
+    <saml:Issuer>%[3]s</saml:Issuer>
+    <saml:Subject>
+      <saml:NameID>alice@example.com</saml:NameID>%s
+    </saml:Subject>
+    <saml:Conditions NotOnOrAfter="%s">
+      <saml:AudienceRestriction>
+        <saml:Audience>%s</saml:Audience>
+      </saml:AudienceRestriction>
+    </saml:Conditions>
+  </saml:Assertion>
+</samlp:Response>`, r.InResponseTo, r.Destination, r.Issuer, confirmation,
+		r.NotOnOrAfter.UTC().Format(time.RFC3339), r.Audience)
+}
+
+// samlRedirect returns the signed query string of the HTTP-Redirect
+// binding for the response.
+func samlRedirect(t *testing.T, key *rsa.PrivateKey, doc string) []byte {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(doc))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	q := "SAMLResponse=" + url.QueryEscape(base64.StdEncoding.EncodeToString(buf.Bytes())) +
+		"&RelayState=state" +
+		"&SigAlg=" + url.QueryEscape(samlRSASHA256)
+	h := sha256.Sum256([]byte(q))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	require.NoError(t, err)
+	return []byte(q + "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(sig)))
+}
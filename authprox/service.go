@@ -8,12 +8,19 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"go.dedis.ch/cothority/v3"
+	dkgprotocol "go.dedis.ch/cothority/v3/dkg/pedersen"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/share"
+	dkg "go.dedis.ch/kyber/v3/share/dkg/pedersen"
 	"go.dedis.ch/kyber/v3/sign/dss"
+	"go.dedis.ch/kyber/v3/sign/eddsa"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
 	"go.dedis.ch/kyber/v3/suites"
+	"go.dedis.ch/kyber/v3/util/key"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
@@ -26,12 +33,32 @@ const ServiceName = "AuthProx"
 
 var authProxID onet.ServiceID
 
+var partialSigMsgID network.MessageTypeID
+
+// Names of the DKG protocols run among the proxies to enroll, to reshare and
+// to generate the nonce of a signature.
+const (
+	enrollProtocol  = "authprox_enroll"
+	reshareProtocol = "authprox_reshare"
+	signProtocol    = "authprox_sign"
+)
+
+// dkgTimeout is how long the root waits for a DKG to finish, and for the
+// partial signatures following the DKG of a signature.
+var dkgTimeout = 20 * time.Second
+
 type service struct {
 	*onet.ServiceProcessor
 	ctx        context.Context
 	validators map[string]Validator
 	db         *bbolt.DB
 	bucket     []byte
+
+	// partials holds the channels of the signatures started by this proxy,
+	// by the round of their DKG, to which the other proxies send their
+	// partial signatures.
+	partials      map[string]chan *dss.PartialSig
+	partialsMutex sync.Mutex
 }
 
 func init() {
@@ -40,12 +67,20 @@ func init() {
 	if err != nil {
 		log.ErrFatal(err, "could not register")
 	}
+	for _, name := range []string{enrollProtocol, reshareProtocol, signProtocol} {
+		if _, err := onet.GlobalProtocolRegister(name, dkgprotocol.NewSetup); err != nil {
+			log.ErrFatal(err, "could not register protocol")
+		}
+	}
 	network.RegisterMessages(
 		&EnrollRequest{}, &EnrollResponse{},
+		&ReshareRequest{}, &ReshareResponse{},
+		&UpdateRequest{}, &UpdateResponse{},
 		&SignatureRequest{}, &SignatureResponse{},
 		&EnrollmentsRequest{}, &EnrollmentsResponse{}, &EnrollmentInfo{},
 		&ti{}, &dssConfig{},
 	)
+	partialSigMsgID = network.RegisterMessage(&partialSig{})
 }
 
 // A Validator is able to check the provided authInfo with respect to
@@ -55,6 +90,15 @@ type Validator interface {
 	FindClaim(issuer string, authInfo []byte) (claim string, hash string, err error)
 }
 
+// A ConfigurableValidator is a Validator that needs a configuration for each
+// issuer, given at enrollment time.
+type ConfigurableValidator interface {
+	Validator
+	// Configure returns a Validator for the issuer with the configuration
+	// of its enrollment, or an error if the configuration is not valid.
+	Configure(issuer string, config []byte) (Validator, error)
+}
+
 func (s *service) registerValidator(t string, v Validator) {
 	if _, ok := s.validators[t]; ok {
 		panic("cannot re-register a validator")
@@ -70,6 +114,22 @@ func (s *service) validator(in string) (Validator, error) {
 	return v, nil
 }
 
+// configuredValidator returns the validator of the type, configured for the
+// issuer if it needs a configuration.
+func (s *service) configuredValidator(typ, issuer string, config []byte) (Validator, error) {
+	v, err := s.validator(typ)
+	if err != nil {
+		return nil, err
+	}
+	if cv, ok := v.(ConfigurableValidator); ok {
+		return cv.Configure(issuer, config)
+	}
+	if len(config) != 0 {
+		return nil, fmt.Errorf("auth type %v takes no configuration", typ)
+	}
+	return v, nil
+}
+
 // ti is a struct to encode/decode a pair of type and issuer.
 type ti struct {
 	T, I string
@@ -79,6 +139,51 @@ type dssConfig struct {
 	Participants []kyber.Point
 	LongPri      share.PriShare
 	LongPubs     []kyber.Point
+	Config       []byte `protobuf:"opt"`
+	// Version is the version of the Config, increased by each update.
+	Version int
+}
+
+// enrollConfig is sent along with the enrollment DKG.
+type enrollConfig struct {
+	Type       string
+	Issuer     string
+	Config     []byte `protobuf:"opt"`
+	Signatures [][]byte
+}
+
+// reshareConfig is sent along with the resharing DKG. The new nodes need
+// the commitments of the current enrollment to check the new shares. The
+// DKG runs among the new nodes followed by the current participants
+// leaving the roster.
+type reshareConfig struct {
+	Type       string
+	Issuer     string
+	Signatures [][]byte
+	OldNodes   []kyber.Point
+	NewNodes   []kyber.Point
+	Commits    []kyber.Point
+	Config     []byte `protobuf:"opt"`
+	Version    int
+}
+
+// signConfig is sent along with the DKG generating the nonce of a
+// signature, so that every proxy checks the request itself.
+type signConfig struct {
+	Type     string
+	Issuer   string
+	AuthInfo []byte
+	Message  []byte
+}
+
+// partialSig is sent by the proxies to the one that started the signature,
+// once the DKG of the nonce finished. It is a copy of dss.PartialSig, with
+// the round of the DKG.
+type partialSig struct {
+	Round     string
+	Partial   share.PriShare
+	SessionID []byte
+	Signature []byte
 }
 
 func (s *service) find(typ, issuer string) (*dssConfig, error) {
@@ -177,9 +282,10 @@ func (s *service) Enrollments(req *EnrollmentsRequest) (*EnrollmentsResponse, er
 			}
 
 			resp.Enrollments = append(resp.Enrollments, EnrollmentInfo{
-				Type:   ti0.T,
-				Issuer: ti0.I,
-				Public: out.LongPubs[0],
+				Type:    ti0.T,
+				Issuer:  ti0.I,
+				Public:  out.LongPubs[0],
+				Version: out.Version,
 			})
 		}
 		return nil
@@ -190,81 +296,729 @@ func (s *service) Enrollments(req *EnrollmentsRequest) (*EnrollmentsResponse, er
 	return &resp, nil
 }
 
-// Enroll will save the proposed secret key share (and associated information)
-// in the local database.
+// Enroll runs a distributed key generation among the proxies of the roster
+// in the request, and saves the resulting secret key share (and associated
+// information) in the local database of each proxy. The complete secret
+// never exists anywhere. Each proxy checks that its operator signed the
+// request.
 func (s *service) Enroll(req *EnrollRequest) (*EnrollResponse, error) {
-	// TODO: How is auth for the right to enroll done?
-	// idea: allow other services (i.e. evoting) to define certain type/claims
-	// as admins, require AuthInfo in EnrollmentRequest, and if the validated
-	// claim is in the admin list, allow.
+	err := s.checkEnroll(req.Type, req.Issuer, req.Config, &req.Roster, req.Signatures)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := s.dkgTree(&req.Roster)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := protobuf.Encode(&enrollConfig{
+		Type:       req.Type,
+		Issuer:     req.Issuer,
+		Config:     req.Config,
+		Signatures: req.Signatures,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	// Prepare the stuff to be written
-	k, err := protobuf.Encode(&ti{T: req.Type, I: req.Issuer})
+	pi, err := s.CreateProtocol(enrollProtocol, tree)
 	if err != nil {
+		return nil, fmt.Errorf("creating dkg protocol: %v", err)
+	}
+	setup := pi.(*dkgprotocol.Setup)
+	setup.Wait = true
+	setup.KeyPair = s.keyPair()
+	if err := setup.SetConfig(&onet.GenericConfig{Data: buf}); err != nil {
+		return nil, fmt.Errorf("setting dkg config: %v", err)
+	}
+	if err := pi.Start(); err != nil {
+		return nil, fmt.Errorf("starting dkg protocol: %v", err)
+	}
+
+	cfg, err := waitDKG(setup, req.Roster.Publics(), req.Config)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store(req.Type, req.Issuer, cfg, false); err != nil {
+		return nil, err
+	}
+	log.Lvlf2("%v enrolled %v:%v with public key %v", s.ServerIdentity(),
+		req.Type, req.Issuer, cfg.LongPubs[0])
+	return &EnrollResponse{Public: cfg.LongPubs[0]}, nil
+}
+
+// Reshare runs a DKG in resharing mode to move the secret key of an
+// enrollment to a new roster, without changing its public key. Each
+// current participant checks that a threshold of the operators of the
+// current participants signed the request. The current participants
+// leaving the roster take part in the DKG to deal their shares, and then
+// forget them.
+func (s *service) Reshare(req *ReshareRequest) (*ReshareResponse, error) {
+	old, err := s.find(req.Type, req.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find key: %v", err)
+	}
+	if !samePoints(req.OldRoster.Publics(), old.Participants) {
+		return nil, errors.New("the old roster does not match the participants of the enrollment")
+	}
+	if err := s.checkReshare(req.Type, req.Issuer, &req.Roster, old, req.Signatures); err != nil {
 		return nil, err
 	}
-	lpri := share.PriShare{
-		I: req.LongPri.I,
-		V: req.LongPri.V,
+	newNodes := req.Roster.Publics()
+	list := append([]*network.ServerIdentity{}, req.Roster.List...)
+	for _, si := range req.OldRoster.List {
+		if !pointInList(si.Public, newNodes) {
+			list = append(list, si)
+		}
 	}
-	v, err := protobuf.Encode(&dssConfig{
-		Participants: req.Participants,
-		LongPri:      lpri,
-		LongPubs:     req.LongPubs,
+	tree, err := s.dkgTree(&onet.Roster{List: list})
+	if err != nil {
+		return nil, err
+	}
+	buf, err := protobuf.Encode(&reshareConfig{
+		Type:       req.Type,
+		Issuer:     req.Issuer,
+		Signatures: req.Signatures,
+		OldNodes:   old.Participants,
+		NewNodes:   newNodes,
+		Commits:    old.LongPubs,
+		Config:     old.Config,
+		Version:    old.Version,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	pi, err := s.CreateProtocol(reshareProtocol, tree)
+	if err != nil {
+		return nil, fmt.Errorf("creating reshare protocol: %v", err)
+	}
+	setup := pi.(*dkgprotocol.Setup)
+	setup.Wait = true
+	setup.KeyPair = s.keyPair()
+	setup.NewDKG = reshareDKG(setup, old.Participants, newNodes, old, nil)
+	if err := setup.SetConfig(&onet.GenericConfig{Data: buf}); err != nil {
+		return nil, fmt.Errorf("setting dkg config: %v", err)
+	}
+	if err := pi.Start(); err != nil {
+		return nil, fmt.Errorf("starting reshare protocol: %v", err)
+	}
+
+	cfg, err := waitDKG(setup, newNodes, old.Config)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Version = old.Version
+	if err := checkReshared(old, cfg); err != nil {
+		return nil, err
+	}
+	if err := s.store(req.Type, req.Issuer, cfg, true); err != nil {
+		return nil, err
+	}
+	log.Lvlf2("%v reshared %v:%v to %d proxies", s.ServerIdentity(),
+		req.Type, req.Issuer, len(cfg.Participants))
+	return &ReshareResponse{Public: cfg.LongPubs[0]}, nil
+}
+
+// Update replaces the configuration of the validator of an enrollment,
+// keeping its key shares. Each participant checks that a threshold of the
+// operators of the participants signed the new configuration and its
+// version, which must follow the current one.
+func (s *service) Update(req *UpdateRequest) (*UpdateResponse, error) {
+	old, err := s.find(req.Type, req.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find key: %v", err)
+	}
+	// The same update can be sent again to the proxies it failed on.
+	if req.Version == old.Version && bytes.Equal(req.Config, old.Config) {
+		return &UpdateResponse{}, nil
+	}
+	if req.Version != old.Version+1 {
+		return nil, fmt.Errorf("expected version %d, got %d", old.Version+1, req.Version)
+	}
+	if _, err := s.configuredValidator(req.Type, req.Issuer, req.Config); err != nil {
+		return nil, err
+	}
+	msg := UpdateMessage(req.Type, req.Issuer, req.Config, req.Version)
+	if err := checkThreshold(old.Participants, msg, req.Signatures); err != nil {
+		return nil, err
+	}
+
+	cfg := *old
+	cfg.Config = req.Config
+	cfg.Version = req.Version
+	if err := s.store(req.Type, req.Issuer, &cfg, true); err != nil {
+		return nil, err
+	}
+	log.Lvlf2("%v updated the config of %v:%v to version %d", s.ServerIdentity(),
+		req.Type, req.Issuer, req.Version)
+	return &UpdateResponse{}, nil
+}
+
+// NewProtocol is called by onet on the proxies taking part in a DKG
+// started by another proxy.
+func (s *service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfig) (onet.ProtocolInstance, error) {
+	switch tn.ProtocolName() {
+	case enrollProtocol:
+		var cfg enrollConfig
+		if err := protobuf.Decode(conf.Data, &cfg); err != nil {
+			return nil, fmt.Errorf("decoding enroll config: %v", err)
+		}
+		err := s.checkEnroll(cfg.Type, cfg.Issuer, cfg.Config, tn.Roster(), cfg.Signatures)
+		if err != nil {
+			return nil, err
+		}
+
+		pi, err := dkgprotocol.NewSetup(tn)
+		if err != nil {
+			return nil, err
+		}
+		setup := pi.(*dkgprotocol.Setup)
+		setup.KeyPair = s.keyPair()
+
+		go func() {
+			<-setup.Finished
+			dc, err := dkgConfig(setup, tn.Roster().Publics(), cfg.Config)
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			if err := s.store(cfg.Type, cfg.Issuer, dc, false); err != nil {
+				log.Error(err)
+			}
+		}()
+		return pi, nil
+	case reshareProtocol:
+		var cfg reshareConfig
+		err := protobuf.DecodeWithConstructors(conf.Data, &cfg, network.DefaultConstructors(cothority.Suite))
+		if err != nil {
+			return nil, fmt.Errorf("decoding reshare config: %v", err)
+		}
+
+		// The DKG runs among the new nodes, followed by the current
+		// participants leaving the roster.
+		publics := tn.Roster().Publics()
+		n := len(cfg.NewNodes)
+		if n == 0 || n > len(publics) || !samePoints(publics[:n], cfg.NewNodes) {
+			return nil, errors.New("the roster does not match the new nodes")
+		}
+		for _, p := range publics[n:] {
+			if !pointInList(p, cfg.OldNodes) {
+				return nil, errors.New("only current participants can leave the roster")
+			}
+		}
+		newRoster := onet.NewRoster(tn.Roster().List[:n])
+		leaving := !pointInList(s.ServerIdentity().Public, cfg.NewNodes)
+
+		// Current participants reshare their own share, after checking
+		// the request, while new ones only need the commitments.
+		old, err := s.find(cfg.Type, cfg.Issuer)
+		if err == nil {
+			if !samePoints(old.Participants, cfg.OldNodes) {
+				return nil, errors.New("participants do not match the enrollment")
+			}
+			if !bytes.Equal(old.Config, cfg.Config) || old.Version != cfg.Version {
+				return nil, errors.New("config does not match the enrollment")
+			}
+			err = s.checkReshare(cfg.Type, cfg.Issuer, newRoster, old, cfg.Signatures)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			if pointInList(s.ServerIdentity().Public, cfg.OldNodes) {
+				return nil, fmt.Errorf("cannot find key: %v", err)
+			}
+			old = nil
+		}
+
+		pi, err := dkgprotocol.NewSetup(tn)
+		if err != nil {
+			return nil, err
+		}
+		setup := pi.(*dkgprotocol.Setup)
+		setup.KeyPair = s.keyPair()
+		setup.NewDKG = reshareDKG(setup, cfg.OldNodes, cfg.NewNodes, old, cfg.Commits)
+
+		go func() {
+			<-setup.Finished
+			if leaving {
+				// The new shares make this one useless.
+				if err := s.remove(cfg.Type, cfg.Issuer); err != nil {
+					log.Error(err)
+				}
+				return
+			}
+			dc, err := dkgConfig(setup, cfg.NewNodes, cfg.Config)
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			dc.Version = cfg.Version
+			if old != nil {
+				if err := checkReshared(old, dc); err != nil {
+					log.Error(err)
+					return
+				}
+			}
+			if err := s.store(cfg.Type, cfg.Issuer, dc, old != nil); err != nil {
+				log.Error(err)
+			}
+		}()
+		return pi, nil
+	case signProtocol:
+		var cfg signConfig
+		if err := protobuf.Decode(conf.Data, &cfg); err != nil {
+			return nil, fmt.Errorf("decoding sign config: %v", err)
+		}
+		dsscfg, err := s.find(cfg.Type, cfg.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find key: %v", err)
+		}
+		if !samePoints(tn.Roster().Publics(), dsscfg.Participants) {
+			return nil, errors.New("the roster does not match the participants of the enrollment")
+		}
+		msg, err := s.claimMessage(cfg.Type, cfg.Issuer, dsscfg.Config, cfg.AuthInfo, cfg.Message)
+		if err != nil {
+			return nil, err
+		}
+
+		pi, err := dkgprotocol.NewSetup(tn)
+		if err != nil {
+			return nil, err
+		}
+		setup := pi.(*dkgprotocol.Setup)
+		setup.KeyPair = s.keyPair()
+
+		round := tn.Token().RoundID.String()
+		root := tn.Root().ServerIdentity
+		go func() {
+			select {
+			case <-setup.Finished:
+			case <-time.After(dkgTimeout):
+				log.Error("dkg of the nonce didn't finish in time")
+				return
+			}
+			d, err := s.newDSS(setup, dsscfg, msg)
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			ps, err := d.PartialSig()
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			err = s.SendRaw(root, &partialSig{
+				Round:     round,
+				Partial:   *ps.Partial,
+				SessionID: ps.SessionID,
+				Signature: ps.Signature,
+			})
+			if err != nil {
+				log.Error(err)
+			}
+		}()
+		return pi, nil
+	}
+	return nil, nil
+}
+
+// ReshareMessage returns the message that the operator of each current
+// participant signs with the private key of its conode, to allow resharing
+// the enrollment of the given type and issuer to the roster.
+func ReshareMessage(typ, issuer string, roster *onet.Roster) ([]byte, error) {
+	h := sha256.New()
+	h.Write([]byte("authprox-reshare"))
+	for _, str := range []string{typ, issuer} {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, uint32(len(str)))
+		h.Write(b)
+		h.Write([]byte(str))
+	}
+	for _, p := range roster.Publics() {
+		if _, err := p.MarshalTo(h); err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// UpdateMessage returns the message that the operator of each participant
+// signs with the private key of its conode, to allow replacing the
+// configuration of the enrollment of the given type and issuer by the given
+// one, with the given version.
+func UpdateMessage(typ, issuer string, config []byte, version int) []byte {
+	h := sha256.New()
+	h.Write([]byte("authprox-update"))
+	for _, buf := range [][]byte{[]byte(typ), []byte(issuer), config} {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, uint32(len(buf)))
+		h.Write(b)
+		h.Write(buf)
+	}
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(version))
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+// EnrollMessage returns the message that the operator of each proxy of the
+// roster signs with the private key of its conode, to allow enrolling the
+// given type and issuer with the configuration.
+func EnrollMessage(typ, issuer string, roster *onet.Roster, config []byte) ([]byte, error) {
+	h := sha256.New()
+	h.Write([]byte("authprox-enroll"))
+	for _, buf := range [][]byte{[]byte(typ), []byte(issuer), config} {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, uint32(len(buf)))
+		h.Write(b)
+		h.Write(buf)
+	}
+	for _, p := range roster.Publics() {
+		if _, err := p.MarshalTo(h); err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// checkEnroll makes sure the type is known, the config is valid for it, the
+// type is not yet enrolled for the issuer and the operator of this proxy
+// signed the request.
+func (s *service) checkEnroll(typ, issuer string, config []byte, roster *onet.Roster, sigs [][]byte) error {
+	if _, err := s.configuredValidator(typ, issuer, config); err != nil {
+		return err
+	}
+	if _, err := s.find(typ, issuer); err == nil {
+		return fmt.Errorf("enrollment already exists for type:issuer %v:%v", typ, issuer)
+	}
+	if len(sigs) != len(roster.List) {
+		return fmt.Errorf("expected %d signatures, got %d", len(roster.List), len(sigs))
+	}
+
+	idx, _ := roster.Search(s.ServerIdentity().ID)
+	if idx < 0 {
+		return errors.New("this proxy is not in the roster")
+	}
+	msg, err := EnrollMessage(typ, issuer, roster, config)
+	if err != nil {
+		return err
+	}
+	if err := schnorr.Verify(cothority.Suite, s.ServerIdentity().Public, msg, sigs[idx]); err != nil {
+		return fmt.Errorf("signature verification failed: %v", err)
+	}
+	return nil
+}
+
+// checkReshare makes sure that this proxy is a current participant, and that
+// a threshold of the operators of the current participants signed the
+// request.
+func (s *service) checkReshare(typ, issuer string, roster *onet.Roster, old *dssConfig, sigs [][]byte) error {
+	if len(roster.List) == 0 {
+		return errors.New("empty roster")
+	}
+	if !pointInList(s.ServerIdentity().Public, old.Participants) {
+		return errors.New("this proxy is not a participant of the enrollment")
+	}
+	msg, err := ReshareMessage(typ, issuer, roster)
+	if err != nil {
+		return err
+	}
+	return checkThreshold(old.Participants, msg, sigs)
+}
+
+// checkThreshold makes sure that at least a threshold of the participants
+// signed the message. The signatures are in the order of the participants,
+// and the missing ones are empty.
+func checkThreshold(participants []kyber.Point, msg []byte, sigs [][]byte) error {
+	if len(sigs) != len(participants) {
+		return fmt.Errorf("expected %d signatures, got %d", len(participants), len(sigs))
+	}
+	valid := 0
+	for i, p := range participants {
+		if len(sigs[i]) == 0 {
+			continue
+		}
+		if err := schnorr.Verify(cothority.Suite, p, msg, sigs[i]); err != nil {
+			return fmt.Errorf("signature %d verification failed: %v", i, err)
+		}
+		valid++
+	}
+	if t := threshold(len(participants)); valid < t {
+		return fmt.Errorf("expected at least %d signatures, got %d", t, valid)
+	}
+	return nil
+}
+
+// dkgTree returns the tree to run a DKG among the roster. The participants
+// of an enrollment are in the order of the roster, and the DKG protocol puts
+// its root first, so this proxy must be the first one of the roster.
+func (s *service) dkgTree(r *onet.Roster) (*onet.Tree, error) {
+	roster := onet.NewRoster(r.List)
+	if roster == nil {
+		return nil, errors.New("invalid roster")
+	}
+	if !roster.List[0].Equal(s.ServerIdentity()) {
+		return nil, errors.New("the request must be sent to the first node of the roster")
+	}
+	return roster.GenerateNaryTree(len(roster.List)), nil
+}
+
+func (s *service) keyPair() *key.Pair {
+	return &key.Pair{
+		Public:  s.ServerIdentity().Public,
+		Private: s.ServerIdentity().GetPrivate(),
+	}
+}
+
+// store writes the config for the type and issuer into the database. An
+// existing enrollment is only replaced if overwrite is set.
+func (s *service) store(typ, issuer string, cfg *dssConfig, overwrite bool) error {
+	k, err := protobuf.Encode(&ti{T: typ, I: issuer})
+	if err != nil {
+		return err
+	}
+	v, err := protobuf.Encode(cfg)
+	if err != nil {
+		return err
+	}
+
 	// Write the type/claim -> dssConfg into the database.
-	err = s.db.Update(func(tx *bbolt.Tx) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
 		// Need to do the find inside of the Update tx, or else it is racy
 		// with respect to other writers.
 		b := tx.Bucket(s.bucket)
 		if b == nil {
 			return errors.New("nil bucket")
 		}
-		if ret := b.Get(k); ret != nil {
-			return fmt.Errorf("enrollment already exists for type:issuer %v:%v", req.Type, req.Issuer)
+		if ret := b.Get(k); ret != nil && !overwrite {
+			return fmt.Errorf("enrollment already exists for type:issuer %v:%v", typ, issuer)
 		}
 		return b.Put(k, v)
 	})
+}
+
+// remove deletes the enrollment for the type and issuer from the database.
+func (s *service) remove(typ, issuer string) error {
+	k, err := protobuf.Encode(&ti{T: typ, I: issuer})
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return errors.New("nil bucket")
+		}
+		return b.Delete(k)
+	})
+}
+
+// waitDKG waits for the DKG started by this proxy to finish.
+func waitDKG(setup *dkgprotocol.Setup, participants []kyber.Point, config []byte) (*dssConfig, error) {
+	select {
+	case <-setup.Finished:
+		return dkgConfig(setup, participants, config)
+	case <-time.After(dkgTimeout):
+		return nil, errors.New("dkg didn't finish in time")
+	}
+}
+
+// dkgConfig turns the result of a finished DKG into the config used to
+// make partial signatures. The conode keys are the DKG keys, so the
+// participants are the public keys of the nodes receiving the shares.
+func dkgConfig(setup *dkgprotocol.Setup, participants []kyber.Point, config []byte) (*dssConfig, error) {
+	_, dks, err := setup.SharedSecret()
 	if err != nil {
 		return nil, err
 	}
+	return &dssConfig{
+		Participants: participants,
+		LongPri:      *dks.Share,
+		LongPubs:     dks.Commits,
+		Config:       config,
+	}, nil
+}
 
-	return &EnrollResponse{}, nil
+// reshareDKG returns the constructor of the DKG in resharing mode. Current
+// participants give their config, new ones the commitments of the
+// enrollment.
+func reshareDKG(setup *dkgprotocol.Setup, oldNodes, newNodes []kyber.Point, old *dssConfig, commits []kyber.Point) func() (*dkg.DistKeyGenerator, error) {
+	return func() (*dkg.DistKeyGenerator, error) {
+		c := &dkg.Config{
+			Suite:        cothority.Suite,
+			Longterm:     setup.KeyPair.Private,
+			OldNodes:     oldNodes,
+			NewNodes:     newNodes,
+			Threshold:    threshold(len(newNodes)),
+			OldThreshold: threshold(len(oldNodes)),
+		}
+		if old != nil {
+			c.Share = &dkg.DistKeyShare{
+				Commits: old.LongPubs,
+				Share:   &old.LongPri,
+			}
+		} else {
+			c.PublicCoeffs = commits
+		}
+		return dkg.NewDistKeyHandler(c)
+	}
+}
+
+// checkReshared makes sure the resharing kept the public key and changed
+// the secret share.
+func checkReshared(old, cfg *dssConfig) error {
+	if !cfg.LongPubs[0].Equal(old.LongPubs[0]) {
+		return errors.New("the reshared public key is different")
+	}
+	if cfg.LongPri.V.Equal(old.LongPri.V) {
+		return errors.New("the reshared secret is the same")
+	}
+	return nil
+}
+
+func pointInList(p1 kyber.Point, l []kyber.Point) bool {
+	for _, p2 := range l {
+		if p2.Equal(p1) {
+			return true
+		}
+	}
+	return false
+}
+
+func samePoints(a, b []kyber.Point) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 // Signature will verify the authentication information in
 // the request, according to the rules specific to that authentication type.
-// If the information is valid, it will then generate a partial signature
-// on a new message which binds together the claim found from the authentication
-// information, and the message in the request, using the secret key share associated
-// with the type and issuer. It is the caller's responsibility to gather a
-// threshold of key shares and then combine them into a final signature.
+// If the information is valid, it will then generate a signature on a new
+// message which binds together the claim found from the authentication
+// information, and the message in the request, using the secret key of the
+// enrollment. The request must be sent to the first participant of the
+// enrollment, which runs a DKG among all the participants to generate the
+// nonce of the signature. Every participant checks the request itself, and
+// sends its partial signature, made with its shares of the key and of the
+// nonce, to the first one, which combines them. The client never sees the
+// shares of the nonce, so it cannot learn the shares of the key from the
+// partial signatures.
 func (s *service) Signature(req *SignatureRequest) (*SignatureResponse, error) {
 	if req == nil {
 		return nil, errors.New("no request")
 	}
 
+	// Find the config that is associated with the issuer.
+	dsscfg, err := s.find(req.Type, req.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find key: %v", err)
+	}
+	if !samePoints(req.Roster.Publics(), dsscfg.Participants) {
+		return nil, errors.New("the roster does not match the participants of the enrollment")
+	}
+	msg, err := s.claimMessage(req.Type, req.Issuer, dsscfg.Config, req.AuthInfo, req.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := s.dkgTree(&req.Roster)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := protobuf.Encode(&signConfig{
+		Type:     req.Type,
+		Issuer:   req.Issuer,
+		AuthInfo: req.AuthInfo,
+		Message:  req.Message,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pi, err := s.CreateProtocol(signProtocol, tree)
+	if err != nil {
+		return nil, fmt.Errorf("creating dkg protocol: %v", err)
+	}
+	setup := pi.(*dkgprotocol.Setup)
+	setup.Wait = true
+	setup.KeyPair = s.keyPair()
+	if err := setup.SetConfig(&onet.GenericConfig{Data: buf}); err != nil {
+		return nil, fmt.Errorf("setting dkg config: %v", err)
+	}
+
+	// The channel must exist before the other proxies can answer.
+	round := pi.Token().RoundID.String()
+	partials := make(chan *dss.PartialSig, len(dsscfg.Participants))
+	s.partialsMutex.Lock()
+	s.partials[round] = partials
+	s.partialsMutex.Unlock()
+	defer func() {
+		s.partialsMutex.Lock()
+		delete(s.partials, round)
+		s.partialsMutex.Unlock()
+	}()
+
+	if err := pi.Start(); err != nil {
+		return nil, fmt.Errorf("starting dkg protocol: %v", err)
+	}
+	select {
+	case <-setup.Finished:
+	case <-time.After(dkgTimeout):
+		return nil, errors.New("dkg didn't finish in time")
+	}
+
+	d, err := s.newDSS(setup, dsscfg, msg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.PartialSig(); err != nil {
+		return nil, err
+	}
+	timeout := time.After(dkgTimeout)
+	for !d.EnoughPartialSig() {
+		select {
+		case ps := <-partials:
+			if err := d.ProcessPartialSig(ps); err != nil {
+				log.Warnf("%v got a wrong partial signature: %v", s.ServerIdentity(), err)
+			}
+		case <-timeout:
+			return nil, errors.New("not enough partial signatures")
+		}
+	}
+	sig, err := d.Signature()
+	if err != nil {
+		return nil, err
+	}
+	if err := eddsa.Verify(dsscfg.LongPubs[0], msg, sig); err != nil {
+		return nil, fmt.Errorf("combined signature is invalid: %v", err)
+	}
+	return &SignatureResponse{Signature: sig}, nil
+}
+
+// claimMessage checks the authentication information with the validator of
+// the type, configured for the issuer, and returns the message to sign,
+// binding the claim found in it to the message.
+func (s *service) claimMessage(typ, issuer string, config, authInfo, message []byte) ([]byte, error) {
 	// Look for a validator for the external type requested.
-	validator, err := s.validator(req.Type)
+	validator, err := s.configuredValidator(typ, issuer, config)
 	if err != nil {
 		return nil, err
 	}
 
 	// Use the validator to extract a claim from the auth info.
-	claim, hashStr, err := validator.FindClaim(req.Issuer, req.AuthInfo)
+	claim, hashStr, err := validator.FindClaim(issuer, authInfo)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: For now, hash will always be unset because the only production
-	// validator is OIDC, and we have not yet figured out how to tunnel the
-	// hash of the transaction through OpenID. The "nonce" claim seems like
-	// the right tool for the job, but the oauth2 package does not let
-	// us set it, and dex does not let refreshed tokens update it.
+	// The SAML and WebAuthn validators bind the hash of the message, but
+	// the OIDC one only does if the client managed to set the "nonce" claim,
+	// which the oauth2 package does not let us do, and dex does not let
+	// refreshed tokens update. LDAP has no way to carry it.
 	if hashStr != "" {
 		// Because it travels through the 3rd party auth system, the hash
 		// is a hex encoded string here.
@@ -274,8 +1028,8 @@ func (s *service) Signature(req *SignatureRequest) (*SignatureResponse, error) {
 		}
 
 		// We managed to extract the hash from the authInfo, so we need to make sure
-		// that H(req.Message) == hash, so that we know this is not a replay attempt.
-		h := sha256.Sum256(req.Message)
+		// that H(message) == hash, so that we know this is not a replay attempt.
+		h := sha256.Sum256(message)
 		if !bytes.Equal(h[:], hashBin) {
 			return nil, errors.New("hash from AuthInfo does not match hash of message")
 		}
@@ -287,47 +1041,52 @@ func (s *service) Signature(req *SignatureRequest) (*SignatureResponse, error) {
 	binary.LittleEndian.PutUint32(b, uint32(len(claim)))
 	h.Write(b)
 	h.Write([]byte(claim))
-	h.Write(req.Message)
-	msg2 := h.Sum(nil)
+	h.Write(message)
+	return h.Sum(nil), nil
+}
 
-	// Find the config that is associated with the issuer.
-	dsscfg, err := s.find(req.Type, req.Issuer)
+// newDSS returns the DSS signing the message with the share of the key of
+// the enrollment and the share of the nonce generated by the finished DKG.
+// Both DKGs ran among the participants in the same order, so the shares
+// have the same index.
+func (s *service) newDSS(setup *dkgprotocol.Setup, cfg *dssConfig, msg []byte) (*dss.DSS, error) {
+	_, nonce, err := setup.SharedSecret()
 	if err != nil {
-		return nil, fmt.Errorf("cannot find key: %v", err)
-	}
-
-	// Make the signature.
-	suite := suites.MustFind("ed25519")
-	rpi := share.PriShare{
-		I: req.RandPri.I,
-		V: req.RandPri.V,
+		return nil, err
 	}
 	priv := s.ServerIdentity().GetPrivate()
 	if priv == nil {
 		return nil, errors.New("server has no private key")
 	}
-	d, err := dss.NewDSS(suite, priv,
-		dsscfg.Participants,
-		&dks{dsscfg.LongPri, dsscfg.LongPubs},
-		&dks{rpi, req.RandPubs},
-		msg2, threshold(len(dsscfg.Participants)))
-	if err != nil {
-		return nil, err
+	return dss.NewDSS(suites.MustFind("ed25519"), priv, cfg.Participants,
+		&dks{cfg.LongPri, cfg.LongPubs}, nonce, msg,
+		threshold(len(cfg.Participants)))
+}
+
+// handlePartialSig passes the partial signature sent by another proxy to the
+// signature waiting for it. The signature checks it.
+func (s *service) handlePartialSig(env *network.Envelope) error {
+	msg, ok := env.Msg.(*partialSig)
+	if !ok {
+		return errors.New("failed to cast to partialSig")
 	}
-	ps, err := d.PartialSig()
-	if err != nil {
-		return nil, err
+	s.partialsMutex.Lock()
+	partials := s.partials[msg.Round]
+	s.partialsMutex.Unlock()
+	if partials == nil {
+		return errors.New("no signature for this round")
 	}
-
-	ps1 := PartialSig{
-		Partial: PriShare{
-			I: ps.Partial.I,
-			V: ps.Partial.V,
-		},
-		SessionID: ps.SessionID,
-		Signature: ps.Signature,
+	ps := &dss.PartialSig{
+		Partial:   &msg.Partial,
+		SessionID: msg.SessionID,
+		Signature: msg.Signature,
+	}
+	select {
+	case partials <- ps:
+	default:
+		return errors.New("too many partial signatures")
 	}
-	return &SignatureResponse{PartialSignature: ps1}, nil
+	return nil
 }
 
 func newService(c *onet.Context) (onet.Service, error) {
@@ -338,17 +1097,24 @@ func newService(c *onet.Context) (onet.Service, error) {
 		validators:       make(map[string]Validator),
 		db:               db,
 		bucket:           bucket,
+		partials:         make(map[string]chan *dss.PartialSig),
 	}
 	if err := s.RegisterHandlers(
 		s.Enroll,
+		s.Reshare,
+		s.Update,
 		s.Signature,
 		s.Enrollments,
 	); err != nil {
 		log.ErrFatal(err, "Could not register handlers.")
 	}
+	s.RegisterProcessorFunc(partialSigMsgID, s.handlePartialSig)
 
 	// Register validators here
-	s.registerValidator("oidc", &oidcValidator{})
+	s.registerValidator("oidc", NewOIDCValidator())
+	s.registerValidator("saml", NewSAMLValidator())
+	s.registerValidator("ldap", NewLDAPValidator())
+	s.registerValidator("webauthn", NewWebAuthnValidator())
 
 	return s, nil
 }
@@ -359,8 +1125,8 @@ type dks struct {
 }
 
 // check that dkg correctly implements dss.DistKeyShare;
-// this is a wrapper we use to work around the fact that package
-// dss expects to take input from a DKG, but we fake it.
+// this is a wrapper we use to give the stored share of the enrollment to
+// package dss, which expects to take input from a DKG.
 var _ dss.DistKeyShare = (*dks)(nil)
 
 func (d *dks) PriShare() *share.PriShare  { return &d.pri }
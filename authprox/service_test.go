@@ -1,22 +1,20 @@
 package authprox
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/darc"
-	"go.dedis.ch/kyber/v3"
-	"go.dedis.ch/kyber/v3/share"
-	"go.dedis.ch/kyber/v3/sign/dss"
 	"go.dedis.ch/kyber/v3/sign/schnorr"
-	"go.dedis.ch/kyber/v3/suites"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
 )
 
 func TestMain(m *testing.M) {
@@ -38,115 +36,110 @@ func (e *valid) FindClaim(issuer string, ai []byte) (string, string, error) {
 var zero64 [64]byte
 
 func Test_EnrollAndSign(t *testing.T) {
-	suite := suites.MustFind("ed25519")
-	e := newEnv(t)
+	e := newEnv(t, 5)
 	defer e.local.CloseAll()
 
-	// Test with 3 out of 5 servers.
-	T := 3
+	// Test with 4 out of 5 servers.
 	nPartic := len(e.services)
 	require.Equal(t, nPartic, 5)
+	require.Equal(t, threshold(nPartic), 4)
 
-	pubs := make([]kyber.Point, nPartic)
-	for i := 0; i < nPartic; i++ {
-		pubs[i] = e.roster.List[i].Public
+	testType := "dummy"
+	validator := &valid{}
+	for _, s := range e.services {
+		s.registerValidator(testType, validator)
 	}
 
-	lPri := share.NewPriPoly(suite, T, nil, cothority.Suite.RandomStream())
-	lShares := lPri.Shares(nPartic)
-	lPub := lPri.Commit(nil)
-	_, lPubCommits := lPub.Info()
-
-	rPri := share.NewPriPoly(suite, T, nil, cothority.Suite.RandomStream())
-	rShares := rPri.Shares(nPartic)
-	rPub := rPri.Commit(nil)
-	_, rPubCommits := rPub.Info()
+	// Only known types can be enrolled.
+	_, err := e.services[0].Enroll(&EnrollRequest{
+		Type:   "unknown",
+		Roster: *e.roster,
+	})
+	require.Error(t, err)
 
-	testType := "dummy"
-	validator := &valid{}
+	// The DKG must be started by the first node of the roster.
+	sigs := e.enrollSignatures(t, e.roster, testType, "", nil)
+	_, err = e.services[1].Enroll(&EnrollRequest{
+		Type:       testType,
+		Roster:     *e.roster,
+		Signatures: sigs,
+	})
+	require.Error(t, err)
 
-	// Enroll on each proxy.
-	for i, s := range e.services {
-		s.registerValidator(testType, validator)
+	// Every proxy must sign.
+	_, err = e.services[0].Enroll(&EnrollRequest{
+		Type:   testType,
+		Roster: *e.roster,
+	})
+	require.Error(t, err)
+	_, err = e.services[0].Enroll(&EnrollRequest{
+		Type:       testType,
+		Roster:     *e.roster,
+		Signatures: append([][]byte{sigs[1]}, sigs[1:]...),
+	})
+	require.Error(t, err)
+	_, err = e.services[0].Enroll(&EnrollRequest{
+		Type:       testType,
+		Issuer:     "other",
+		Roster:     *e.roster,
+		Signatures: sigs,
+	})
+	require.Error(t, err)
 
-		lpri := PriShare{
-			I: lShares[i].I,
-			V: lShares[i].V,
-		}
-		req := &EnrollRequest{
-			Type:         testType,
-			Participants: pubs,
-			LongPri:      lpri,
-			LongPubs:     lPubCommits,
-		}
-		_, err := s.Enroll(req)
-		require.NoError(t, err)
-	}
+	// Enroll with a DKG among all the proxies.
+	enrolled, err := e.services[0].Enroll(&EnrollRequest{
+		Type:       testType,
+		Roster:     *e.roster,
+		Signatures: sigs,
+	})
+	require.NoError(t, err)
+	waitEnrolled(t, e.services, testType, "", nPartic)
+
+	// An enrollment cannot be replaced.
+	_, err = e.services[0].Enroll(&EnrollRequest{
+		Type:       testType,
+		Roster:     *e.roster,
+		Signatures: sigs,
+	})
+	require.Error(t, err)
 
 	h := sha256.Sum256(zero64[:])
 	hStr := hex.EncodeToString(h[:])
 	hBad := "00" + hStr[2:]
 	require.Equal(t, len(hStr), len(hBad))
 
-	var partials []*share.PriShare
-	for i, s := range e.services {
-		rp := PriShare{
-			I: rShares[i].I,
-			V: rShares[i].V,
-		}
-
-		// One time with incorrect hash in the auth info.
-		req := &SignatureRequest{
-			Type:     testType,
-			Message:  zero64[:],
-			AuthInfo: []byte(hBad),
-			RandPri:  rp,
-			RandPubs: rPubCommits,
-		}
-		_, err := s.Signature(req)
-		require.Error(t, err)
-
-		// And now correctly.
-		req = &SignatureRequest{
-			Type:     testType,
-			Message:  zero64[:],
-			AuthInfo: []byte(hStr),
-			RandPri:  rp,
-			RandPubs: rPubCommits,
-		}
-		resp, err := s.Signature(req)
-		require.NoError(t, err)
-
-		ps := &dss.PartialSig{
-			Partial: &share.PriShare{
-				I: resp.PartialSignature.Partial.I,
-				V: resp.PartialSignature.Partial.V,
-			},
-			SessionID: resp.PartialSignature.SessionID,
-			Signature: resp.PartialSignature.Signature,
-		}
-
-		err = schnorr.Verify(cothority.Suite, s.ServerIdentity().Public, ps.Hash(cothority.Suite), ps.Signature)
-		require.NoError(t, err)
+	// One time with incorrect hash in the auth info.
+	req := &SignatureRequest{
+		Type:     testType,
+		Message:  zero64[:],
+		AuthInfo: []byte(hBad),
+		Roster:   *e.roster,
+	}
+	_, err = e.services[0].Signature(req)
+	require.Error(t, err)
 
-		partials = append(partials, ps.Partial)
+	// The signature must be started by the first participant, with the
+	// participants in their order.
+	req.AuthInfo = []byte(hStr)
+	_, err = e.services[1].Signature(req)
+	require.Error(t, err)
+	reversed := make([]*network.ServerIdentity, nPartic)
+	for i, si := range e.roster.List {
+		reversed[nPartic-1-i] = si
 	}
+	req.Roster = *onet.NewRoster(reversed)
+	_, err = e.services[nPartic-1].Signature(req)
+	require.Error(t, err)
 
-	// Reassemble the partial signatures and validate them: this will normally
-	// happen in the client, but do it here for now to see if this stuff all holds
-	// together.
-	gamma, err := share.RecoverSecret(suite, partials, T, nPartic)
+	// And now correctly.
+	req.Roster = *e.roster
+	resp, err := e.services[0].Signature(req)
 	require.NoError(t, err)
-
-	// RandomPublic || gamma
-	var buff bytes.Buffer
-	_, _ = rPub.Commit().MarshalTo(&buff)
-	_, _ = gamma.MarshalTo(&buff)
-	sig := buff.Bytes()
+	sig := resp.Signature
 
 	// Make a Darc identity for this public key and some other claim.
 	id := darc.IdentityProxy{
-		Public: lPub.Commit(),
+		Public: enrolled.Public,
 		Data:   "dummy-claim-other",
 	}
 	err = id.Verify(zero64[:], sig)
@@ -155,68 +148,218 @@ func Test_EnrollAndSign(t *testing.T) {
 	// Make a Darc identity for this public key and the claim we know
 	// that the verifier returned.
 	id = darc.IdentityProxy{
-		Public: lPub.Commit(),
+		Public: enrolled.Public,
 		Data:   "dummy-claim",
 	}
 	err = id.Verify(zero64[:], sig)
 	require.NoError(t, err)
 
-	// Test behavior of failing FindClaim: error propogates correctly to caller, and
-	// then it is up to the client to see if they can still make final sig.
-	partials = nil
-	for i, s := range e.services {
-		// Cause the first two servers to give an error from FindClaim.
-		if i < 2 {
-			validator.err = errors.New("FindClaim returns error for testing")
-		} else {
-			validator.err = nil
-		}
-		rp := PriShare{
-			I: rShares[i].I,
-			V: rShares[i].V,
-		}
-		req := &SignatureRequest{
-			Type:     testType,
-			Message:  zero64[:],
-			AuthInfo: []byte(hStr),
-			RandPri:  rp,
-			RandPubs: rPubCommits,
-		}
-		resp, err := s.Signature(req)
-		if i < 2 {
-			require.Error(t, err)
-		} else {
-			require.NoError(t, err)
-			ps := &share.PriShare{
-				I: resp.PartialSignature.Partial.I,
-				V: resp.PartialSignature.Partial.V,
-			}
-			partials = append(partials, ps)
-		}
-	}
-	// Expect all the servers expect 2 to contirbute partials.
-	require.Equal(t, len(e.services)-2, len(partials))
+	// The client cannot pick the nonce: the proxies generate a new one for
+	// every signature, so the same message gets another commitment R. The
+	// client only gets the combined signature R || s, and never the partial
+	// signatures r_i + e * x_i of the proxies, so it cannot learn their
+	// shares of the nonce, nor of the key.
+	resp, err = e.services[0].Signature(req)
+	require.NoError(t, err)
+	require.NoError(t, id.Verify(zero64[:], resp.Signature))
+	require.Equal(t, 64, len(resp.Signature))
+	require.NotEqual(t, sig[:32], resp.Signature[:32])
+
+	// Every proxy checks the auth info: if one of them does not accept it,
+	// there is no signature.
+	dt := dkgTimeout
+	dkgTimeout = 2 * time.Second
+	defer func() { dkgTimeout = dt }()
+	e.services[2].validators[testType] = &valid{err: errors.New("FindClaim returns error for testing")}
+	_, err = e.services[0].Signature(req)
+	require.Error(t, err)
+	e.services[2].validators[testType] = validator
+	validator.err = errors.New("FindClaim returns error for testing")
+	_, err = e.services[0].Signature(req)
+	require.Error(t, err)
+	validator.err = nil
 
-	gamma, err = share.RecoverSecret(suite, partials, T, nPartic)
+	// Check that Enrollments does what we expect
+	eresp, err := e.services[0].Enrollments(&EnrollmentsRequest{Types: []string{"other"}})
 	require.NoError(t, err)
-	buff.Reset()
-	_, _ = rPub.Commit().MarshalTo(&buff)
-	_, _ = gamma.MarshalTo(&buff)
-	sig = buff.Bytes()
-	err = id.Verify(zero64[:], sig)
+	require.Equal(t, 0, len(eresp.Enrollments))
+	eresp, err = e.services[0].Enrollments(&EnrollmentsRequest{Types: []string{"dummy", "other", "dummy"}})
 	require.NoError(t, err)
+	require.Equal(t, 1, len(eresp.Enrollments))
+}
 
-	// Check that Enrollments does what we expect
-	resp, err := e.services[0].Enrollments(&EnrollmentsRequest{Types: []string{"other"}})
+func Test_Reshare(t *testing.T) {
+	e := newEnv(t, 7)
+	defer e.local.CloseAll()
+
+	testType := "dummy"
+	for _, s := range e.services {
+		s.registerValidator(testType, &valid{})
+	}
+
+	// Enroll the first 5 proxies.
+	oldRoster := onet.NewRoster(e.roster.List[:5])
+	enrolled, err := e.services[0].Enroll(&EnrollRequest{
+		Type:       testType,
+		Roster:     *oldRoster,
+		Signatures: e.enrollSignatures(t, oldRoster, testType, "", nil),
+	})
+	require.NoError(t, err)
+	waitEnrolled(t, e.services[:5], testType, "", 5)
+
+	id := darc.IdentityProxy{
+		Public: enrolled.Public,
+		Data:   "dummy-claim",
+	}
+	require.NoError(t, id.Verify(zero64[:], sign(t, e.services[:5], testType)))
+
+	// A threshold of the current participants must sign.
+	sigs := e.reshareSignatures(t, e.roster, testType, 5)
+	_, err = e.services[0].Reshare(&ReshareRequest{
+		Type:       testType,
+		Roster:     *e.roster,
+		OldRoster:  *oldRoster,
+		Signatures: [][]byte{sigs[0], sigs[1], sigs[2], nil, nil},
+	})
+	require.Error(t, err)
+	_, err = e.services[0].Reshare(&ReshareRequest{
+		Type:       testType,
+		Roster:     *e.roster,
+		OldRoster:  *oldRoster,
+		Signatures: append([][]byte{sigs[1]}, sigs[1:]...),
+	})
+	require.Error(t, err)
+	_, err = e.services[0].Reshare(&ReshareRequest{
+		Type:       testType,
+		Roster:     *e.roster,
+		OldRoster:  *oldRoster,
+		Signatures: sigs[1:],
+	})
+	require.Error(t, err)
+
+	// The old roster must be the current participants.
+	_, err = e.services[0].Reshare(&ReshareRequest{
+		Type:       testType,
+		Roster:     *e.roster,
+		OldRoster:  *e.roster,
+		Signatures: sigs,
+	})
+	require.Error(t, err)
+
+	// The resharing must be started by the first node of the roster.
+	_, err = e.services[1].Reshare(&ReshareRequest{
+		Type:       testType,
+		Roster:     *e.roster,
+		OldRoster:  *oldRoster,
+		Signatures: sigs,
+	})
+	require.Error(t, err)
+
+	// Reshare to all the 7 proxies with the signatures of 4 of the 5
+	// current ones, keeping the public key.
+	reshared, err := e.services[0].Reshare(&ReshareRequest{
+		Type:       testType,
+		Roster:     *e.roster,
+		OldRoster:  *oldRoster,
+		Signatures: append(sigs[:4], nil),
+	})
 	require.NoError(t, err)
-	require.Equal(t, 0, len(resp.Enrollments))
-	resp, err = e.services[0].Enrollments(&EnrollmentsRequest{Types: []string{"dummy", "other", "dummy"}})
+	require.True(t, enrolled.Public.Equal(reshared.Public))
+	waitEnrolled(t, e.services, testType, "", 7)
+
+	require.NoError(t, id.Verify(zero64[:], sign(t, e.services, testType)))
+
+	// Reshare to the last 6 proxies, removing the first one.
+	newRoster := onet.NewRoster(e.roster.List[1:])
+	reshared, err = e.services[1].Reshare(&ReshareRequest{
+		Type:       testType,
+		Roster:     *newRoster,
+		OldRoster:  *e.roster,
+		Signatures: e.reshareSignatures(t, newRoster, testType, 7),
+	})
+	require.NoError(t, err)
+	require.True(t, enrolled.Public.Equal(reshared.Public))
+	waitEnrolled(t, e.services[1:], testType, "", 6)
+	for i := 0; ; i++ {
+		if _, err := e.services[0].find(testType, ""); err != nil {
+			break
+		}
+		require.True(t, i < 100, "enrollment not removed")
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	require.NoError(t, id.Verify(zero64[:], sign(t, e.services[1:], testType)))
+
+	resp, err := e.services[6].Enrollments(&EnrollmentsRequest{})
 	require.NoError(t, err)
 	require.Equal(t, 1, len(resp.Enrollments))
+	require.True(t, enrolled.Public.Equal(resp.Enrollments[0].Public))
+}
+
+func Test_EnrollConfig(t *testing.T) {
+	e := newEnv(t, 5)
+	defer e.local.CloseAll()
+
+	srv := newLDAPStandIn(t, map[string]string{
+		"uid=alice,ou=people,dc=example,dc=com": "secret",
+	})
+	defer srv.Close()
+
+	// Validators without configuration refuse one.
+	_, err := e.services[0].Enroll(&EnrollRequest{
+		Type:       "oidc",
+		Issuer:     "https://oauth.example.com",
+		Roster:     *e.roster,
+		Config:     []byte("config"),
+		Signatures: e.enrollSignatures(t, e.roster, "oidc", "https://oauth.example.com", []byte("config")),
+	})
+	require.Error(t, err)
+
+	// Validators with configuration need a valid one.
+	_, err = e.services[0].Enroll(&EnrollRequest{
+		Type:       "ldap",
+		Issuer:     srv.url(),
+		Roster:     *e.roster,
+		Signatures: e.enrollSignatures(t, e.roster, "ldap", srv.url(), nil),
+	})
+	require.Error(t, err)
+
+	config, err := protobuf.Encode(&LDAPConfig{
+		BindDN:      "uid=%s,ou=people,dc=example,dc=com",
+		Certificate: srv.cert,
+	})
+	require.NoError(t, err)
+	_, err = e.services[0].Enroll(&EnrollRequest{
+		Type:       "ldap",
+		Issuer:     srv.url(),
+		Roster:     *e.roster,
+		Config:     config,
+		Signatures: e.enrollSignatures(t, e.roster, "ldap", srv.url(), config),
+	})
+	require.NoError(t, err)
+	waitEnrolled(t, e.services, "ldap", srv.url(), 5)
+
+	// Every proxy uses the configuration of the enrollment.
+	for _, pw := range []string{"wrong", "secret"} {
+		ai, err := protobuf.Encode(&LDAPAuthInfo{Username: "alice", Password: pw})
+		require.NoError(t, err)
+		_, err = e.services[0].Signature(&SignatureRequest{
+			Type:     "ldap",
+			Issuer:   srv.url(),
+			Message:  zero64[:],
+			AuthInfo: ai,
+			Roster:   *e.roster,
+		})
+		if pw == "wrong" {
+			require.Error(t, err)
+		} else {
+			require.NoError(t, err)
+		}
+	}
 }
 
 func TestService_SignatureErrors(t *testing.T) {
-	e := newEnv(t)
+	e := newEnv(t, 5)
 	defer e.local.CloseAll()
 
 	in := &SignatureRequest{
@@ -233,13 +376,92 @@ type env struct {
 	services []*service
 }
 
-func newEnv(t *testing.T) (s *env) {
+func newEnv(t *testing.T, n int) (s *env) {
 	s = &env{}
 	s.local = onet.NewLocalTestT(cothority.Suite, t)
-	s.hosts, s.roster, _ = s.local.GenTree(5, true)
+	s.hosts, s.roster, _ = s.local.GenTree(n, true)
 	for _, sv := range s.local.GetServices(s.hosts, authProxID) {
 		s.services = append(s.services, sv.(*service))
 	}
 
 	return
 }
+
+// enrollSignatures returns the signatures of the hosts of the roster allowing
+// to enroll the type and issuer with the config.
+func (s *env) enrollSignatures(t *testing.T, roster *onet.Roster, typ, issuer string, config []byte) [][]byte {
+	msg, err := EnrollMessage(typ, issuer, roster, config)
+	require.NoError(t, err)
+
+	sigs := make([][]byte, len(roster.List))
+	for i, si := range roster.List {
+		for _, h := range s.hosts {
+			if h.ServerIdentity.Equal(si) {
+				sigs[i], err = schnorr.Sign(cothority.Suite, h.ServerIdentity.GetPrivate(), msg)
+				require.NoError(t, err)
+			}
+		}
+	}
+	return sigs
+}
+
+// reshareSignatures returns the signatures of the first nOld hosts allowing
+// to reshare to the roster.
+func (s *env) reshareSignatures(t *testing.T, roster *onet.Roster, typ string, nOld int) [][]byte {
+	msg, err := ReshareMessage(typ, "", roster)
+	require.NoError(t, err)
+
+	sigs := make([][]byte, nOld)
+	for i := range sigs {
+		sigs[i], err = schnorr.Sign(cothority.Suite, s.hosts[i].ServerIdentity.GetPrivate(), msg)
+		require.NoError(t, err)
+	}
+	return sigs
+}
+
+// updateSignatures returns the signatures of the first nOld hosts, the
+// participants of the enrollment, allowing to update its config.
+func (s *env) updateSignatures(t *testing.T, typ, issuer string, config []byte, version, nOld int) [][]byte {
+	msg := UpdateMessage(typ, issuer, config, version)
+
+	sigs := make([][]byte, len(s.hosts))
+	for i := 0; i < nOld; i++ {
+		var err error
+		sigs[i], err = schnorr.Sign(cothority.Suite, s.hosts[i].ServerIdentity.GetPrivate(), msg)
+		require.NoError(t, err)
+	}
+	return sigs
+}
+
+// waitEnrolled waits for the services to store an enrollment with n
+// participants: only the root stores it before returning from the DKG.
+func waitEnrolled(t *testing.T, services []*service, typ, issuer string, n int) {
+	for _, s := range services {
+		for i := 0; ; i++ {
+			cfg, err := s.find(typ, issuer)
+			if err == nil && len(cfg.Participants) == n {
+				break
+			}
+			require.True(t, i < 100, "enrollment not stored")
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
+
+// sign asks the first of the services, which are the participants of the
+// enrollment in their order, for a signature on zero64.
+func sign(t *testing.T, services []*service, typ string) []byte {
+	var list []*network.ServerIdentity
+	for _, s := range services {
+		list = append(list, s.ServerIdentity())
+	}
+	h := sha256.Sum256(zero64[:])
+	resp, err := services[0].Signature(&SignatureRequest{
+		Type:     typ,
+		Message:  zero64[:],
+		AuthInfo: []byte(hex.EncodeToString(h[:])),
+		Roster:   *onet.NewRoster(list),
+	})
+	require.NoError(t, err)
+	return resp.Signature
+}
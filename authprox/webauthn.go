@@ -0,0 +1,184 @@
+package authprox
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"go.dedis.ch/protobuf"
+)
+
+// flagUserPresent is the flag of the authenticator data telling that the
+// user was present.
+const flagUserPresent = 0x01
+
+// NewWebAuthnValidator returns a Validator for WebAuthn (FIDO2) assertions,
+// made with the credentials registered in the configuration. The issuer is
+// the ID of the relying party, the claim is the user of the credential and
+// the hash is the hex encoded challenge: clients bind the message by using
+// its hash as challenge. The signature counter is not checked, as the
+// proxies do not keep state between signatures.
+func NewWebAuthnValidator() ConfigurableValidator {
+	return &webAuthnValidator{}
+}
+
+type webAuthnValidator struct {
+	rpID        string
+	origin      string
+	credentials []webAuthnCredential
+}
+
+type webAuthnCredential struct {
+	id     []byte
+	user   string
+	public crypto.PublicKey
+}
+
+func (v *webAuthnValidator) Configure(issuer string, config []byte) (Validator, error) {
+	var cfg WebAuthnConfig
+	if err := protobuf.Decode(config, &cfg); err != nil {
+		return nil, fmt.Errorf("decoding WebAuthn config: %v", err)
+	}
+	if issuer == "" {
+		return nil, errors.New("empty issuer")
+	}
+	if cfg.Origin == "" {
+		return nil, errors.New("empty origin")
+	}
+
+	out := &webAuthnValidator{rpID: issuer, origin: cfg.Origin}
+	for _, c := range cfg.Credentials {
+		if len(c.ID) == 0 || c.User == "" {
+			return nil, errors.New("credential without ID or user")
+		}
+		if _, err := out.credential(c.ID); err == nil {
+			return nil, fmt.Errorf("duplicate credential %x", c.ID)
+		}
+		pub, err := x509.ParsePKIXPublicKey(c.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("parsing public key of %v: %v", c.User, err)
+		}
+		switch p := pub.(type) {
+		case *ecdsa.PublicKey:
+			if p.Curve != elliptic.P256() {
+				return nil, fmt.Errorf("unsupported curve for %v", c.User)
+			}
+		case ed25519.PublicKey, *rsa.PublicKey:
+		default:
+			return nil, fmt.Errorf("unsupported public key for %v", c.User)
+		}
+		out.credentials = append(out.credentials, webAuthnCredential{
+			id:     c.ID,
+			user:   c.User,
+			public: pub,
+		})
+	}
+	return out, nil
+}
+
+func (v *webAuthnValidator) credential(id []byte) (*webAuthnCredential, error) {
+	for i := range v.credentials {
+		if bytes.Equal(v.credentials[i].id, id) {
+			return &v.credentials[i], nil
+		}
+	}
+	return nil, errors.New("unknown credential")
+}
+
+func (v *webAuthnValidator) FindClaim(issuer string, authInfo []byte) (string, string, error) {
+	if v.origin == "" {
+		return "", "", errors.New("WebAuthn validator is not configured")
+	}
+	if issuer != v.rpID {
+		return "", "", errors.New("wrong issuer")
+	}
+
+	var ai WebAuthnAuthInfo
+	if err := protobuf.Decode(authInfo, &ai); err != nil {
+		return "", "", fmt.Errorf("decoding auth info: %v", err)
+	}
+	cred, err := v.credential(ai.CredentialID)
+	if err != nil {
+		return "", "", err
+	}
+
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(ai.ClientDataJSON, &clientData); err != nil {
+		return "", "", fmt.Errorf("parsing client data: %v", err)
+	}
+	if clientData.Type != "webauthn.get" {
+		return "", "", errors.New("not an assertion")
+	}
+	if clientData.Origin != v.origin {
+		return "", "", errors.New("wrong origin")
+	}
+	challenge, err := base64.RawURLEncoding.DecodeString(clientData.Challenge)
+	if err != nil {
+		return "", "", fmt.Errorf("decoding challenge: %v", err)
+	}
+	if len(challenge) != sha256.Size {
+		return "", "", errors.New("the challenge must be the hash of the message")
+	}
+
+	// The authenticator data starts with the hash of the relying party ID,
+	// the flags and the signature counter.
+	if len(ai.AuthenticatorData) < 37 {
+		return "", "", errors.New("authenticator data too short")
+	}
+	rpIDHash := sha256.Sum256([]byte(v.rpID))
+	if !bytes.Equal(ai.AuthenticatorData[:32], rpIDHash[:]) {
+		return "", "", errors.New("wrong relying party")
+	}
+	if ai.AuthenticatorData[32]&flagUserPresent == 0 {
+		return "", "", errors.New("user not present")
+	}
+
+	clientDataHash := sha256.Sum256(ai.ClientDataJSON)
+	signed := append(append([]byte{}, ai.AuthenticatorData...), clientDataHash[:]...)
+	if err := verifyWebAuthn(cred.public, signed, ai.Signature); err != nil {
+		return "", "", fmt.Errorf("signature verification failed: %v", err)
+	}
+	return cred.user, hex.EncodeToString(challenge), nil
+}
+
+func verifyWebAuthn(public crypto.PublicKey, signed, sig []byte) error {
+	h := sha256.Sum256(signed)
+	switch pub := public.(type) {
+	case *ecdsa.PublicKey:
+		var rs struct {
+			R, S *big.Int
+		}
+		rest, err := asn1.Unmarshal(sig, &rs)
+		if err != nil || len(rest) != 0 {
+			return errors.New("malformed signature")
+		}
+		if !ecdsa.Verify(pub, h[:], rs.R, rs.S) {
+			return errors.New("invalid signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, signed, sig) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, h[:], sig)
+	default:
+		return errors.New("unsupported public key")
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package authprox
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/protobuf"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://login.example.com"
+)
+
+func TestWebAuthnValidator(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	config, err := protobuf.Encode(&WebAuthnConfig{
+		Origin: testOrigin,
+		Credentials: []WebAuthnCredential{
+			{ID: []byte("alice-key"), User: "alice@example.com", PublicKey: pub},
+		},
+	})
+	require.NoError(t, err)
+	v, err := NewWebAuthnValidator().Configure(testRPID, config)
+	require.NoError(t, err)
+
+	// Credentials must have a valid public key.
+	bad, err := protobuf.Encode(&WebAuthnConfig{
+		Origin: testOrigin,
+		Credentials: []WebAuthnCredential{
+			{ID: []byte("alice-key"), User: "alice@example.com", PublicKey: []byte("key")},
+		},
+	})
+	require.NoError(t, err)
+	_, err = NewWebAuthnValidator().Configure(testRPID, bad)
+	require.Error(t, err)
+
+	h := sha256.Sum256(zero64[:])
+	a := &webAuthnTest{
+		t:         t,
+		key:       key,
+		id:        []byte("alice-key"),
+		rpID:      testRPID,
+		origin:    testOrigin,
+		challenge: h[:],
+		flags:     flagUserPresent,
+	}
+	claim, hash, err := v.FindClaim(testRPID, a.assertion())
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.com", claim)
+	require.Equal(t, hex.EncodeToString(h[:]), hash)
+
+	_, _, err = v.FindClaim("other.example.com", a.assertion())
+	require.Error(t, err)
+
+	// Every part of the assertion is checked.
+	for _, change := range []func(*webAuthnTest){
+		func(a *webAuthnTest) { a.id = []byte("bob-key") },
+		func(a *webAuthnTest) { a.rpID = "other.example.com" },
+		func(a *webAuthnTest) { a.origin = "https://evil.example.com" },
+		func(a *webAuthnTest) { a.challenge = []byte("challenge") },
+		func(a *webAuthnTest) { a.flags = 0 },
+		func(a *webAuthnTest) { a.key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader) },
+	} {
+		wrong := *a
+		change(&wrong)
+		_, _, err = v.FindClaim(testRPID, wrong.assertion())
+		require.Error(t, err)
+	}
+}
+
+func TestWebAuthn_UpdateCredentials(t *testing.T) {
+	e := newEnv(t, 4)
+	defer e.local.CloseAll()
+
+	config := func(creds ...WebAuthnCredential) []byte {
+		buf, err := protobuf.Encode(&WebAuthnConfig{Origin: testOrigin, Credentials: creds})
+		require.NoError(t, err)
+		return buf
+	}
+	credential := func(user string) (*ecdsa.PrivateKey, WebAuthnCredential) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(t, err)
+		return key, WebAuthnCredential{ID: []byte(user), User: user, PublicKey: pub}
+	}
+	alice, aliceCred := credential("alice@example.com")
+	bob, bobCred := credential("bob@example.com")
+
+	first := config(aliceCred)
+	enrolled, err := e.services[0].Enroll(&EnrollRequest{
+		Type:       "webauthn",
+		Issuer:     testRPID,
+		Roster:     *e.roster,
+		Config:     first,
+		Signatures: e.enrollSignatures(t, e.roster, "webauthn", testRPID, first),
+	})
+	require.NoError(t, err)
+	waitEnrolled(t, e.services, "webauthn", testRPID, 4)
+
+	h := sha256.Sum256(zero64[:])
+	signWith := func(key *ecdsa.PrivateKey, cred WebAuthnCredential) error {
+		a := &webAuthnTest{t: t, key: key, id: cred.ID, rpID: testRPID,
+			origin: testOrigin, challenge: h[:], flags: flagUserPresent}
+		_, err := e.services[0].Signature(&SignatureRequest{
+			Type:     "webauthn",
+			Issuer:   testRPID,
+			AuthInfo: a.assertion(),
+			Message:  zero64[:],
+			Roster:   *e.roster,
+		})
+		return err
+	}
+	require.NoError(t, signWith(alice, aliceCred))
+	require.Error(t, signWith(bob, bobCred))
+
+	update := func(config []byte, version, nOld int) error {
+		sigs := e.updateSignatures(t, "webauthn", testRPID, config, version, nOld)
+		for _, s := range e.services {
+			_, err := s.Update(&UpdateRequest{
+				Type:       "webauthn",
+				Issuer:     testRPID,
+				Config:     config,
+				Version:    version,
+				Signatures: sigs,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Replacing the credential of alice by the one of bob needs a threshold
+	// of the operators and the next version.
+	second := config(bobCred)
+	require.Error(t, update(second, 1, 2))
+	require.Error(t, update(second, 2, 3))
+	require.Error(t, update([]byte("config"), 1, 3))
+	require.NoError(t, update(second, 1, 3))
+	require.NoError(t, signWith(bob, bobCred))
+	require.Error(t, signWith(alice, aliceCred))
+
+	// Sending the update again does nothing, but an update cannot be
+	// replayed to go back.
+	require.NoError(t, update(second, 1, 3))
+	require.Error(t, update(first, 1, 4))
+	require.NoError(t, signWith(bob, bobCred))
+
+	// The key of the enrollment does not change.
+	resp, err := e.services[3].Enrollments(&EnrollmentsRequest{Types: []string{"webauthn"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(resp.Enrollments))
+	require.True(t, enrolled.Public.Equal(resp.Enrollments[0].Public))
+	require.Equal(t, 1, resp.Enrollments[0].Version)
+}
+
+// webAuthnTest makes assertions as an authenticator would.
+type webAuthnTest struct {
+	t         *testing.T
+	key       *ecdsa.PrivateKey
+	id        []byte
+	rpID      string
+	origin    string
+	challenge []byte
+	flags     byte
+}
+
+func (a *webAuthnTest) assertion() []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	authData := append(rpIDHash[:], a.flags, 0, 0, 0, 1)
+	clientData := []byte(fmt.Sprintf(`{"type":"webauthn.get","challenge":"%s","origin":"%s"}`,
+		base64.RawURLEncoding.EncodeToString(a.challenge), a.origin))
+
+	clientDataHash := sha256.Sum256(clientData)
+	h := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	r, s, err := ecdsa.Sign(rand.Reader, a.key, h[:])
+	require.NoError(a.t, err)
+	sig, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	require.NoError(a.t, err)
+
+	buf, err := protobuf.Encode(&WebAuthnAuthInfo{
+		CredentialID:      a.id,
+		AuthenticatorData: authData,
+		ClientDataJSON:    clientData,
+		Signature:         sig,
+	})
+	require.NoError(a.t, err)
+	return buf
+}
@@ -2,6 +2,7 @@ package bevm
 
 import (
 	"crypto/ecdsa"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -18,7 +19,9 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
 	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
@@ -221,12 +224,24 @@ type Client struct {
 // NewBEvm creates a new ByzCoin EVM instance
 func NewBEvm(bcClient *byzcoin.Client, signer darc.Signer, gDarc *darc.Darc) (
 	byzcoin.InstanceID, error) {
+	return newBEvm(bcClient, signer, gDarc, byzcoin.Arguments{})
+}
+
+// NewBEvmBridge creates a new ByzCoin EVM instance in bridge mode, where the
+// Ether is backed by ByzCoin coins
+func NewBEvmBridge(bcClient *byzcoin.Client, signer darc.Signer,
+	gDarc *darc.Darc, params BridgeParams) (byzcoin.InstanceID, error) {
+	return newBEvm(bcClient, signer, gDarc, params.arguments())
+}
+
+func newBEvm(bcClient *byzcoin.Client, signer darc.Signer, gDarc *darc.Darc,
+	args byzcoin.Arguments) (byzcoin.InstanceID, error) {
 	instanceID := byzcoin.NewInstanceID(nil)
 
 	tx, err := spawnBEvm(bcClient, signer,
 		byzcoin.NewInstanceID(gDarc.GetBaseID()), &byzcoin.Spawn{
 			ContractID: ContractBEvmID,
-			Args:       byzcoin.Arguments{},
+			Args:       args,
 		})
 	if err != nil {
 		return instanceID, xerrors.Errorf("failed to execute ByzCoin "+
@@ -422,6 +437,116 @@ func (client *Client) CreditAccount(amount *big.Int,
 	return bcTx, nil
 }
 
+// Deposit takes coins from a coin instance and credits their value to the
+// given Ethereum address, in bridge mode. The signer of the client must be
+// allowed to invoke "fetch" on the coin instance.
+func (client *Client) Deposit(coinInstance byzcoin.InstanceID, coins uint64,
+	address common.Address) (*byzcoin.ClientTransaction, error) {
+	counters, err := client.bcClient.GetSignerCounters(
+		client.signer.Identity().String())
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve signer "+
+			"counters from ByzCoin: %v", err)
+	}
+
+	coinsBuf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(coinsBuf, coins)
+
+	tx, err := client.bcClient.CreateTransaction(
+		byzcoin.Instruction{
+			InstanceID:    coinInstance,
+			SignerCounter: []uint64{counters.Counters[0] + 1},
+			Invoke: &byzcoin.Invoke{
+				ContractID: contracts.ContractCoinID,
+				Command:    "fetch",
+				Args: byzcoin.Arguments{
+					{Name: "coins", Value: coinsBuf},
+				},
+			},
+		},
+		byzcoin.Instruction{
+			InstanceID:    client.instanceID,
+			SignerCounter: []uint64{counters.Counters[0] + 2},
+			Invoke: &byzcoin.Invoke{
+				ContractID: ContractBEvmID,
+				Command:    "deposit",
+				Args: byzcoin.Arguments{
+					{Name: "address", Value: address.Bytes()},
+				},
+			},
+		})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create ByzCoin "+
+			"transaction: %v", err)
+	}
+
+	err = tx.FillSignersAndSignWith(client.signer)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to sign ByzCoin "+
+			"transaction: %v", err)
+	}
+
+	_, err = client.bcClient.AddTransactionAndWait(tx, 5)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to deposit coins: %v", err)
+	}
+
+	log.Lvlf2("Deposited %d coins on '%x'", coins, address)
+
+	return &tx, nil
+}
+
+// Withdraw removes the value of the given number of coins from an Ethereum
+// account and stores the coins in a coin instance, in bridge mode
+func (client *Client) Withdraw(gasLimit uint64, gasPrice uint64,
+	account *EvmAccount, coins uint64, coinInstance byzcoin.InstanceID) (
+	*byzcoin.ClientTransaction, error) {
+	bridge, err := client.GetBridge()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve bridge state: %v",
+			err)
+	}
+	if bridge == nil {
+		return nil, xerrors.New("coins can only be withdrawn in bridge mode")
+	}
+
+	amount := new(big.Int).SetUint64(coins)
+	amount.Mul(amount, bridge.weiPerCoin())
+
+	tx := types.NewTransaction(account.Nonce, WithdrawAddress, amount,
+		gasLimit, big.NewInt(int64(gasPrice)), coinInstance[:])
+	signedTxBuffer, err := account.signAndMarshalTx(tx)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to prepare EVM transaction for "+
+			"withdrawal: %v", err)
+	}
+
+	bcTx, err := client.invoke("transaction", byzcoin.Arguments{
+		{Name: "tx", Value: signedTxBuffer},
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to invoke ByzCoin transaction for "+
+			"withdrawal: %v", err)
+	}
+
+	account.Nonce++
+
+	log.Lvlf2("Withdrew %d coins from '%x'", coins, account.Address)
+
+	return bcTx, nil
+}
+
+// GetBridge returns the bridge state of the BEvm instance, or nil if it is
+// not in bridge mode
+func (client *Client) GetBridge() (*BridgeState, error) {
+	bs, err := getBEvmState(client.bcClient, client.instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bs.Bridge, nil
+}
+
 // GetAccountBalance returns the current balance of a Ethereum address
 func (client *Client) GetAccountBalance(address common.Address) (
 	*big.Int, error) {
@@ -437,6 +562,106 @@ func (client *Client) GetAccountBalance(address common.Address) (
 	return balance, nil
 }
 
+// GetReceipt returns the receipt of an EVM transaction, as stored in ByzCoin
+func (client *Client) GetReceipt(txHash common.Hash) (*Receipt, error) {
+	key := receiptID(client.instanceID, txHash)
+
+	proofResponse, err := client.bcClient.GetProofFromLatest(key[:])
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve receipt: %v", err)
+	}
+
+	ok, err := proofResponse.Proof.InclusionProof.Exists(key[:])
+	if err != nil {
+		return nil, xerrors.Errorf("failed to check receipt proof: %v", err)
+	}
+	if !ok {
+		return nil, xerrors.Errorf("no receipt for transaction %s",
+			txHash.Hex())
+	}
+
+	_, value, _, _, err := proofResponse.Proof.KeyValue()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get receipt value: %v", err)
+	}
+
+	var receipt Receipt
+	err = protobuf.Decode(value, &receipt)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode receipt: %v", err)
+	}
+
+	return &receipt, nil
+}
+
+// GetLogs returns the logs of the EVM transactions between two blocks
+// (included), emitted by one of the addresses and matching the topics. A
+// negative toBlock means the latest block. If no address is given, the logs
+// of all the addresses are returned. Each entry of topics lists the accepted
+// values of the topic at the same position; an empty entry matches any topic.
+func (client *Client) GetLogs(fromBlock int, toBlock int,
+	addresses []common.Address, topics [][]common.Hash) ([]ReceiptLog,
+	error) {
+	request := &GetLogsRequest{
+		ByzCoinID:      client.bcClient.ID,
+		BEvmInstanceID: client.instanceID[:],
+		FromBlock:      fromBlock,
+		ToBlock:        toBlock,
+	}
+
+	for _, address := range addresses {
+		request.Addresses = append(request.Addresses, address.Bytes())
+	}
+
+	for _, hashes := range topics {
+		var filter TopicFilter
+		for _, hash := range hashes {
+			filter.Hashes = append(filter.Hashes, hash.Bytes())
+		}
+
+		request.Topics = append(request.Topics, filter)
+	}
+
+	response := &GetLogsResponse{}
+	err := client.Client.SendProtobuf(client.bcClient.Roster.List[0],
+		request, response)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get EVM logs: %v", err)
+	}
+
+	return response.Logs, nil
+}
+
+// StartGateway asks the conode to serve the Ethereum JSON-RPC gateway on the
+// given address, signing the ByzCoin transactions with the given signer. The
+// request is signed with the private key of the conode, and must be sent from
+// the host of the conode. The gateway sets the Access-Control-Allow-Origin
+// header to allowOrigin if not empty.
+func StartGateway(si *network.ServerIdentity, address string,
+	signer darc.Signer, allowOrigin string) error {
+	request := &StartGatewayRequest{
+		Address:     address,
+		Signer:      signer,
+		AllowOrigin: allowOrigin,
+		Timestamp:   time.Now().Unix(),
+	}
+
+	var err error
+	request.Signature, err = schnorr.Sign(cothority.Suite, si.GetPrivate(),
+		request.hash())
+	if err != nil {
+		return xerrors.Errorf("failed to sign request: %v", err)
+	}
+
+	client := onet.NewClient(cothority.Suite, ServiceName)
+	err = client.SendProtobuf(si, request, &StartGatewayResponse{})
+	if err != nil {
+		return xerrors.Errorf("failed to start gateway: %v", err)
+	}
+
+	return nil
+}
+
 // ---------------------------------------------------------------------------
 // Service methods
 
@@ -677,6 +902,23 @@ func encodeEvmValue(abiType string, value interface{}) (interface{}, error) {
 	return encodedValue, nil
 }
 
+// TxHash returns the hash of the EVM transaction executed by a ByzCoin
+// transaction returned by Client.Deploy() or Client.Transaction()
+func TxHash(bcTx *byzcoin.ClientTransaction) (common.Hash, error) {
+	if len(bcTx.Instructions) == 0 ||
+		bcTx.Instructions[0].Invoke == nil {
+		return common.Hash{}, xerrors.New("not a BEvm transaction")
+	}
+
+	ethTx, err := decodeTransaction(bcTx.Instructions[0].Invoke.Args)
+	if err != nil {
+		return common.Hash{}, xerrors.Errorf("failed to retrieve EVM "+
+			"transaction: %v", err)
+	}
+
+	return ethTx.Hash(), nil
+}
+
 // ---------------------------------------------------------------------------
 // Helper functions
 
@@ -700,9 +942,9 @@ func (account EvmAccount) signAndMarshalTx(tx *types.Transaction) (
 	return signedBuffer, nil
 }
 
-// Retrieve a read-only EVM state database backed by a ByzCoin client
-func getEvmDb(bcClient *byzcoin.Client, instID byzcoin.InstanceID) (
-	*state.StateDB, error) {
+// Retrieve the state of a BEvm instance through a ByzCoin client
+func getBEvmState(bcClient *byzcoin.Client, instID byzcoin.InstanceID) (
+	*State, error) {
 	// Retrieve the proof of the Byzcoin instance
 	proofResponse, err := bcClient.GetProofFromLatest(instID[:])
 	if err != nil {
@@ -723,6 +965,17 @@ func getEvmDb(bcClient *byzcoin.Client, instID byzcoin.InstanceID) (
 			"value: %v", err)
 	}
 
+	return &bs, nil
+}
+
+// Retrieve a read-only EVM state database backed by a ByzCoin client
+func getEvmDb(bcClient *byzcoin.Client, instID byzcoin.InstanceID) (
+	*state.StateDB, error) {
+	bs, err := getBEvmState(bcClient, instID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create a client ByzDB instance
 	byzDb, err := NewClientByzDatabase(instID, bcClient)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"math/rand"
 	"os"
 	"time"
@@ -12,7 +13,9 @@ import (
 	"go.dedis.ch/cothority/v3/bevm"
 	"go.dedis.ch/cothority/v3/byzcoin"
 	"go.dedis.ch/cothority/v3/byzcoin/bcadmin/lib"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
 	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3/app"
 	"go.dedis.ch/onet/v3/cfgpath"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
@@ -50,6 +53,29 @@ var cmds = cli.Commands{
 				Name:  "outID",
 				Usage: "output file for the BEvm ID (optional)",
 			},
+			cli.BoolFlag{
+				Name:  "bridge",
+				Usage: "back the Ether with ByzCoin coins",
+			},
+			cli.StringFlag{
+				Name: "coinName",
+				Usage: "type of the coins backing the Ether, as a hex " +
+					"InstanceID (default is the byzCoin type)",
+			},
+			cli.StringFlag{
+				Name:  "weiPerCoin",
+				Value: "1000000000000000000",
+				Usage: "value of one coin in wei",
+			},
+			cli.Uint64Flag{
+				Name:  "minGasPrice",
+				Usage: "lowest gas price accepted, in wei",
+			},
+			cli.StringFlag{
+				Name: "treasury",
+				Usage: "coin instance receiving the gas fees (default is " +
+					"to burn them)",
+			},
 		},
 		Action: spawn,
 	},
@@ -77,6 +103,31 @@ var cmds = cli.Commands{
 		},
 		Action: delete,
 	},
+	{
+		Name:      "gateway",
+		Usage:     "start the Ethereum JSON-RPC gateway of a conode",
+		Aliases:   []string{"g"},
+		ArgsUsage: "private.toml",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:     "address",
+				Usage:    "address to listen on, such as localhost:8545 (required)",
+				Required: true,
+			},
+			cli.StringFlag{
+				Name: "sign",
+				Usage: "public key of the entity signing the ByzCoin " +
+					"transactions of the gateway, which must not be the " +
+					"conode key (required)",
+				Required: true,
+			},
+			cli.StringFlag{
+				Name:  "allowOrigin",
+				Usage: "origin allowed to use the gateway from a browser (default is none)",
+			},
+		},
+		Action: gateway,
+	},
 }
 
 var cliApp = cli.NewApp()
@@ -149,7 +200,18 @@ func spawn(c *cli.Context) error {
 		return xerrors.Errorf("failed to load DARC data: %v", err)
 	}
 
-	bevmInstID, err := bevm.NewBEvm(cl, *signer, darc)
+	var bevmInstID byzcoin.InstanceID
+	if c.Bool("bridge") {
+		var params *bevm.BridgeParams
+		params, err = getBridgeParams(c)
+		if err != nil {
+			return xerrors.Errorf("invalid bridge parameters: %v", err)
+		}
+
+		bevmInstID, err = bevm.NewBEvmBridge(cl, *signer, darc, *params)
+	} else {
+		bevmInstID, err = bevm.NewBEvm(cl, *signer, darc)
+	}
 	if err != nil {
 		return xerrors.Errorf("failed to spawn new BEvm instance: %v", err)
 	}
@@ -172,6 +234,38 @@ func spawn(c *cli.Context) error {
 	return nil
 }
 
+func getBridgeParams(c *cli.Context) (*bevm.BridgeParams, error) {
+	params := &bevm.BridgeParams{
+		CoinName:    contracts.CoinName,
+		MinGasPrice: c.Uint64("minGasPrice"),
+	}
+
+	if coinName := c.String("coinName"); coinName != "" {
+		buf, err := hex.DecodeString(coinName)
+		if err != nil || len(buf) != len(byzcoin.InstanceID{}) {
+			return nil, xerrors.New("invalid coin name")
+		}
+		params.CoinName = byzcoin.NewInstanceID(buf)
+	}
+
+	weiPerCoin, ok := new(big.Int).SetString(c.String("weiPerCoin"), 0)
+	if !ok || weiPerCoin.Sign() <= 0 {
+		return nil, xerrors.New("invalid number of wei per coin")
+	}
+	params.WeiPerCoin = weiPerCoin
+
+	if treasury := c.String("treasury"); treasury != "" {
+		buf, err := hex.DecodeString(treasury)
+		if err != nil || len(buf) != len(byzcoin.InstanceID{}) {
+			return nil, xerrors.New("invalid treasury coin instance")
+		}
+		treasuryID := byzcoin.NewInstanceID(buf)
+		params.Treasury = &treasuryID
+	}
+
+	return params, nil
+}
+
 func delete(c *cli.Context) error {
 	bcFile := c.String("bc")
 
@@ -217,3 +311,38 @@ func delete(c *cli.Context) error {
 
 	return nil
 }
+
+func gateway(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return xerrors.New("please give: private.toml")
+	}
+
+	cfg, err := app.LoadCothority(c.Args().First())
+	if err != nil {
+		return xerrors.Errorf("failed to load conode config: %v", err)
+	}
+
+	si, err := cfg.GetServerIdentity()
+	if err != nil {
+		return xerrors.Errorf("failed to get server identity: %v", err)
+	}
+
+	signer, err := lib.LoadKeyFromString(c.String("sign"))
+	if err != nil {
+		return xerrors.Errorf("failed to load signer key: %v", err)
+	}
+
+	err = bevm.StartGateway(si, c.String("address"), *signer,
+		c.String("allowOrigin"))
+	if err != nil {
+		return xerrors.Errorf("failed to start gateway: %v", err)
+	}
+
+	_, err = fmt.Fprintf(c.App.Writer, "Started gateway on %s, signing "+
+		"as %s\n", c.String("address"), signer.Identity())
+	if err != nil {
+		return xerrors.Errorf("failed to write report msg: %v", err)
+	}
+
+	return nil
+}
@@ -60,6 +60,33 @@ var cmds = cli.Commands{
 		Flags:     commonFlags,
 		Action:    getAccountBalance,
 	},
+	{
+		Name:      "deposit",
+		Usage:     "deposit coins on a BEvm account, in bridge mode",
+		Aliases:   []string{"dp"},
+		ArgsUsage: "<coin instance ID> <number of coins>",
+		Flags:     commonFlags,
+		Action:    deposit,
+	},
+	{
+		Name:      "withdraw",
+		Usage:     "withdraw coins from a BEvm account, in bridge mode",
+		Aliases:   []string{"wd"},
+		ArgsUsage: "<coin instance ID> <number of coins>",
+		Flags: append(commonFlags,
+			cli.Uint64Flag{
+				Name:  "gasLimit",
+				Value: 1e7,
+				Usage: "gas limit for the transaction",
+			},
+			cli.Uint64Flag{
+				Name:  "gasPrice",
+				Value: 1,
+				Usage: "gas price for the transaction",
+			},
+		),
+		Action: withdraw,
+	},
 	{
 		Name:      "deployContract",
 		Usage:     "deploy a BEvm contract",
@@ -132,4 +159,46 @@ var cmds = cli.Commands{
 		),
 		Action: executeCall,
 	},
+	{
+		Name:      "receipt",
+		Usage:     "retrieve the receipt of a BEvm transaction",
+		Aliases:   []string{"r"},
+		ArgsUsage: "<transaction hash>",
+		Flags:     commonFlags,
+		Action:    getReceipt,
+	},
+	{
+		Name:      "logs",
+		Usage:     "retrieve the logs emitted by BEvm transactions",
+		Aliases:   []string{"l"},
+		ArgsUsage: "",
+		Flags: append(commonFlags,
+			cli.IntFlag{
+				Name:  "from",
+				Value: 0,
+				Usage: "first block of the range",
+			},
+			cli.IntFlag{
+				Name:  "to",
+				Value: -1,
+				Usage: "last block of the range (default is the latest block)",
+			},
+			cli.StringSliceFlag{
+				Name:  "address",
+				Usage: "only keep the logs of this address (can be repeated)",
+			},
+			cli.StringSliceFlag{
+				Name: "topic",
+				Usage: "comma-separated accepted values of the topic at " +
+					"the same position, or empty for any value (can be " +
+					"repeated)",
+			},
+			cli.StringFlag{
+				Name: "contractName, cn",
+				Usage: "only keep the logs of this contract, and show " +
+					"their event names",
+			},
+		),
+		Action: getLogs,
+	},
 }
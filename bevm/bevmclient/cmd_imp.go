@@ -1,17 +1,21 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"strconv"
+	"strings"
 
 	"golang.org/x/xerrors"
 
 	cli "github.com/urfave/cli"
 	"go.dedis.ch/cothority/v3/bevm"
+	"go.dedis.ch/cothority/v3/byzcoin"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
@@ -103,6 +107,101 @@ func getAccountBalance(ctx *cli.Context) error {
 	return nil
 }
 
+// parseCoinArgs returns the coin instance ID and the number of coins given
+// as arguments
+func parseCoinArgs(ctx *cli.Context) (byzcoin.InstanceID, uint64, error) {
+	if ctx.NArg() < 2 {
+		return byzcoin.InstanceID{}, 0, xerrors.Errorf("missing some "+
+			"argument (expected 2, got %d)", ctx.NArg())
+	}
+
+	coinID, err := hex.DecodeString(ctx.Args().Get(0))
+	if err != nil || len(coinID) != len(byzcoin.InstanceID{}) {
+		return byzcoin.InstanceID{}, 0, xerrors.New("failed to parse " +
+			"<coin instance ID> value")
+	}
+
+	coins, err := strconv.ParseUint(ctx.Args().Get(1), 0, 64)
+	if err != nil {
+		return byzcoin.InstanceID{}, 0, xerrors.Errorf("failed to parse "+
+			"<number of coins> value: %v", err)
+	}
+
+	return byzcoin.NewInstanceID(coinID), coins, nil
+}
+
+func deposit(ctx *cli.Context) error {
+	// Retrieve options and arguments
+
+	opt, err := handleCommonOptions(ctx)
+	if err != nil {
+		return xerrors.Errorf("failed to handle provided options: %v", err)
+	}
+
+	coinID, coins, err := parseCoinArgs(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Perform command
+
+	_, err = opt.bevmClient.Deposit(coinID, coins, opt.account.Address)
+	if err != nil {
+		return xerrors.Errorf("failed to deposit coins: %v", err)
+	}
+
+	_, err = fmt.Fprintf(ctx.App.Writer, "Deposited %d coins on account %s\n",
+		coins, opt.account.Address.Hex())
+	if err != nil {
+		return xerrors.Errorf("failed to write report msg: %v", err)
+	}
+
+	return nil
+}
+
+func withdraw(ctx *cli.Context) error {
+	// Retrieve options and arguments
+
+	opt, err := handleCommonOptions(ctx)
+	if err != nil {
+		return xerrors.Errorf("failed to handle provided options: %v", err)
+	}
+
+	gasLimit := ctx.Uint64("gasLimit")
+	gasPrice := ctx.Uint64("gasPrice")
+
+	coinID, coins, err := parseCoinArgs(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Perform command
+
+	bcTx, err := opt.bevmClient.Withdraw(gasLimit, gasPrice, opt.account,
+		coins, coinID)
+	if err != nil {
+		return xerrors.Errorf("failed to withdraw coins: %v", err)
+	}
+
+	err = writeAccountFile(opt.account, opt.accountName, false)
+	if err != nil {
+		return xerrors.Errorf("failed to save account information: %v", err)
+	}
+
+	_, err = fmt.Fprintf(ctx.App.Writer, "Withdrew %d coins from account %s\n",
+		coins, opt.account.Address.Hex())
+	if err != nil {
+		return xerrors.Errorf("failed to write report msg: %v", err)
+	}
+
+	err = printTxHash(ctx, bcTx)
+	if err != nil {
+		return xerrors.Errorf("failed to write transaction hash: %v", err)
+	}
+
+	return nil
+}
+
 func deployContract(ctx *cli.Context) error {
 	// Retrieve options and arguments
 
@@ -157,7 +256,7 @@ func deployContract(ctx *cli.Context) error {
 
 	// Perform command
 
-	_, contractInstance, err := opt.bevmClient.Deploy(
+	bcTx, contractInstance, err := opt.bevmClient.Deploy(
 		gasLimit, gasPrice, amount, opt.account, contract, args...)
 	if err != nil {
 		return xerrors.Errorf("failed to deploy new BEvm contract: %v", err)
@@ -178,6 +277,11 @@ func deployContract(ctx *cli.Context) error {
 		return xerrors.Errorf("failed to write report msg: %v", err)
 	}
 
+	err = printTxHash(ctx, bcTx)
+	if err != nil {
+		return xerrors.Errorf("failed to write transaction hash: %v", err)
+	}
+
 	return nil
 }
 
@@ -223,7 +327,7 @@ func executeTransaction(ctx *cli.Context) error {
 
 	// Perform command
 
-	_, err = opt.bevmClient.Transaction(
+	bcTx, err := opt.bevmClient.Transaction(
 		gasLimit, gasPrice, amount, opt.account, contractInstance,
 		method, args...)
 	if err != nil {
@@ -240,6 +344,11 @@ func executeTransaction(ctx *cli.Context) error {
 		return xerrors.Errorf("failed to write report msg: %v", err)
 	}
 
+	err = printTxHash(ctx, bcTx)
+	if err != nil {
+		return xerrors.Errorf("failed to write transaction hash: %v", err)
+	}
+
 	return nil
 }
 
@@ -303,3 +412,116 @@ func executeCall(ctx *cli.Context) error {
 
 	return nil
 }
+
+func getReceipt(ctx *cli.Context) error {
+	// Retrieve options and arguments
+
+	opt, err := handleCommonOptions(ctx)
+	if err != nil {
+		return xerrors.Errorf("failed to handle provided options: %v", err)
+	}
+
+	if !ctx.Args().Present() {
+		return xerrors.New("missing <transaction hash> argument")
+	}
+
+	txHash := common.HexToHash(ctx.Args().First())
+
+	// Perform command
+
+	receipt, err := opt.bevmClient.GetReceipt(txHash)
+	if err != nil {
+		return xerrors.Errorf("failed to retrieve transaction receipt: %v",
+			err)
+	}
+
+	status := "success"
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		status = "failure"
+	}
+
+	_, err = fmt.Fprintf(ctx.App.Writer,
+		"Transaction %s: %s, block %d, gas used %d\n",
+		txHash.Hex(), status, receipt.BlockIndex, receipt.GasUsed)
+	if err != nil {
+		return xerrors.Errorf("failed to write report msg: %v", err)
+	}
+
+	if receipt.ContractAddress != nil {
+		_, err = fmt.Fprintf(ctx.App.Writer, "Contract deployed at %s\n",
+			common.BytesToAddress(receipt.ContractAddress).Hex())
+		if err != nil {
+			return xerrors.Errorf("failed to write report msg: %v", err)
+		}
+	}
+
+	for i, l := range receipt.Logs {
+		err = printLog(ctx, fmt.Sprintf("Log %d", i), l, nil)
+		if err != nil {
+			return xerrors.Errorf("failed to write log: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func getLogs(ctx *cli.Context) error {
+	// Retrieve options and arguments
+
+	opt, err := handleCommonOptions(ctx)
+	if err != nil {
+		return xerrors.Errorf("failed to handle provided options: %v", err)
+	}
+
+	var addresses []common.Address
+	for _, address := range ctx.StringSlice("address") {
+		if !common.IsHexAddress(address) {
+			return xerrors.Errorf("invalid address: %s", address)
+		}
+
+		addresses = append(addresses, common.HexToAddress(address))
+	}
+
+	var topics [][]common.Hash
+	for _, topic := range ctx.StringSlice("topic") {
+		var hashes []common.Hash
+		if topic != "" {
+			for _, hash := range strings.Split(topic, ",") {
+				hashes = append(hashes, common.HexToHash(hash))
+			}
+		}
+
+		topics = append(topics, hashes)
+	}
+
+	var contractInstance *bevm.EvmContractInstance
+	contractName := ctx.String("contractName")
+	if contractName != "" {
+		contractInstance, err = readContractFile(contractName)
+		if err != nil {
+			return xerrors.Errorf("failed to load contract information: %v",
+				err)
+		}
+
+		addresses = append(addresses, contractInstance.Address)
+	}
+
+	// Perform command
+
+	logs, err := opt.bevmClient.GetLogs(ctx.Int("from"), ctx.Int("to"),
+		addresses, topics)
+	if err != nil {
+		return xerrors.Errorf("failed to retrieve logs: %v", err)
+	}
+
+	for _, l := range logs {
+		err = printLog(ctx, fmt.Sprintf("Block %d, transaction %s, log %d",
+			l.BlockIndex, common.BytesToHash(l.TxHash).Hex(), l.Index),
+			l.Log, contractInstance)
+		if err != nil {
+			return xerrors.Errorf("failed to write log: %v", err)
+		}
+	}
+
+	return nil
+}
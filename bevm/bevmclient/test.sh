@@ -137,6 +137,28 @@ testBevmInteraction(){
         --sign "${BEVM_USER}" \
         getRemainingCandies
 
+    # Check the receipt of a transaction
+    TX_HASH=$( runBevmClient transaction \
+        --sign "${BEVM_USER}" \
+        eatCandy \
+        '"2"' | sed -n 's/^transaction hash: //p' )
+    testGrep "success" runBevmClient receipt \
+        --sign "${BEVM_USER}" \
+        "${TX_HASH}"
+
+    # Deploy an ERC20 token and check its Transfer event
+    testOK runBevmClient deployContract \
+        --sign "${BEVM_USER}" \
+        --contractName token \
+        "${APPDIR}/../testdata/ERC20Token/ERC20Token_sol_ERC20Token.abi" \
+        "${APPDIR}/../testdata/ERC20Token/ERC20Token_sol_ERC20Token.bin"
+    testGrep "Transfer" runBevmClient logs \
+        --sign "${BEVM_USER}" \
+        --contractName token
+    testNGrep "Transfer" runBevmClient logs \
+        --sign "${BEVM_USER}" \
+        --contractName contract
+
     # Delete BEvm instance
     # Cannot delete as BEVM_USER
     testFail runBevmAdmin delete \
@@ -191,3 +191,43 @@ func handleCommonOptions(ctx *cli.Context) (*commonOptions, error) {
 		bevmClient:  bevmClient,
 	}, nil
 }
+
+func printTxHash(ctx *cli.Context, bcTx *byzcoin.ClientTransaction) error {
+	txHash, err := bevm.TxHash(bcTx)
+	if err != nil {
+		return xerrors.Errorf("failed to get transaction hash: %v", err)
+	}
+
+	_, err = fmt.Fprintf(ctx.App.Writer, "transaction hash: %s\n",
+		txHash.Hex())
+	return err
+}
+
+// printLog prints a log, with the name of its event if the log comes from the
+// given contract instance
+func printLog(ctx *cli.Context, prefix string, l bevm.Log,
+	contractInstance *bevm.EvmContractInstance) error {
+	address := common.BytesToAddress(l.Address)
+
+	topics := make([]string, len(l.Topics))
+	for i, topic := range l.Topics {
+		topics[i] = common.BytesToHash(topic).Hex()
+	}
+
+	name := ""
+	if contractInstance != nil && contractInstance.Address == address &&
+		len(l.Topics) > 0 {
+		for _, event := range contractInstance.Parent.Abi.Events {
+			if event.Id() == common.BytesToHash(l.Topics[0]) {
+				name = " " + event.Name
+				break
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(ctx.App.Writer,
+		"%s:%s address %s topics [%s] data %s\n", prefix, name,
+		address.Hex(), strings.Join(topics, ", "),
+		hex.EncodeToString(l.Data))
+	return err
+}
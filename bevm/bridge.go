@@ -0,0 +1,364 @@
+package bevm
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// In bridge mode, the Ether of a BEvm instance is backed by ByzCoin coins
+// instead of being minted by "credit":
+//  - "deposit" takes the coins given to the instruction and credits their
+//    value in wei to an Ethereum address.
+//  - an Ethereum transaction sending Ether to WithdrawAddress, with the ID of
+//    a coin instance as data, removes the Ether from the EVM and stores the
+//    corresponding coins in the coin instance.
+//  - the gas fees of the Ethereum transactions are converted back to coins,
+//    which are given to the treasury coin instance, or burnt if there is
+//    none.
+// The coins backing the Ether are counted in BridgeState.Locked, so that the
+// Ether in the EVM can never be worth more than the coins deposited.
+
+// WithdrawAddress is the Ethereum address receiving the Ether to withdraw
+// from a BEvm instance in bridge mode. Nobody knows its private key.
+var WithdrawAddress = common.BytesToAddress(
+	crypto.Keccak256([]byte("bevm:withdraw")))
+
+// BridgeState holds the configuration and the accounting of a BEvm instance
+// in bridge mode.
+type BridgeState struct {
+	// CoinName is the type of the coins backing the Ether.
+	CoinName byzcoin.InstanceID
+	// WeiPerCoin is the value of one coin in wei, as a big-endian integer.
+	WeiPerCoin []byte
+	// MinGasPrice is the lowest gas price accepted for a transaction, in wei.
+	MinGasPrice uint64
+	// Treasury is the coin instance receiving the gas fees. The fees are
+	// burnt if it is nil.
+	Treasury *byzcoin.InstanceID `protobuf:"opt"`
+	// Locked is the number of coins backing the Ether of the EVM.
+	Locked uint64
+	// Fees holds the gas fees, in wei, that do not amount to a whole coin
+	// yet, as a big-endian integer.
+	Fees []byte `protobuf:"opt"`
+}
+
+// BridgeParams are the parameters of a new BEvm instance in bridge mode.
+type BridgeParams struct {
+	CoinName byzcoin.InstanceID
+	// WeiPerCoin defaults to WeiPerEther if nil.
+	WeiPerCoin  *big.Int
+	MinGasPrice uint64
+	Treasury    *byzcoin.InstanceID
+}
+
+// coinCredit is a number of coins to store in a coin instance
+type coinCredit struct {
+	account byzcoin.InstanceID
+	coins   uint64
+}
+
+// arguments returns the spawn arguments enabling the bridge mode
+func (p BridgeParams) arguments() byzcoin.Arguments {
+	args := byzcoin.Arguments{
+		{Name: "coinName", Value: p.CoinName[:]},
+	}
+
+	if p.WeiPerCoin != nil {
+		args = append(args, byzcoin.Argument{
+			Name: "weiPerCoin", Value: p.WeiPerCoin.Bytes(),
+		})
+	}
+
+	if p.MinGasPrice > 0 {
+		minGasPrice := make([]byte, 8)
+		binary.LittleEndian.PutUint64(minGasPrice, p.MinGasPrice)
+		args = append(args, byzcoin.Argument{
+			Name: "minGasPrice", Value: minGasPrice,
+		})
+	}
+
+	if p.Treasury != nil {
+		args = append(args, byzcoin.Argument{
+			Name: "treasury", Value: p.Treasury[:],
+		})
+	}
+
+	return args
+}
+
+// newBridgeState returns the bridge state defined by the spawn arguments, or
+// nil if the BEvm instance is not in bridge mode
+func newBridgeState(rst byzcoin.ReadOnlyStateTrie,
+	args byzcoin.Arguments) (*BridgeState, error) {
+	coinName := args.Search("coinName")
+	if coinName == nil {
+		return nil, nil
+	}
+	if len(coinName) != len(byzcoin.InstanceID{}) {
+		return nil, xerrors.New("coinName needs to be an InstanceID")
+	}
+
+	bs := &BridgeState{
+		CoinName:   byzcoin.NewInstanceID(coinName),
+		WeiPerCoin: big.NewInt(WeiPerEther).Bytes(),
+	}
+
+	if weiPerCoin := args.Search("weiPerCoin"); weiPerCoin != nil {
+		if new(big.Int).SetBytes(weiPerCoin).Sign() == 0 {
+			return nil, xerrors.New("weiPerCoin cannot be zero")
+		}
+		bs.WeiPerCoin = weiPerCoin
+	}
+
+	if minGasPrice := args.Search("minGasPrice"); minGasPrice != nil {
+		if len(minGasPrice) != 8 {
+			return nil, xerrors.New("minGasPrice needs to be a 64-bit uint")
+		}
+		bs.MinGasPrice = binary.LittleEndian.Uint64(minGasPrice)
+	}
+
+	if treasury := args.Search("treasury"); treasury != nil {
+		if len(treasury) != len(byzcoin.InstanceID{}) {
+			return nil, xerrors.New("treasury needs to be an InstanceID")
+		}
+		treasuryID := byzcoin.NewInstanceID(treasury)
+
+		_, _, err := bs.loadCoin(rst, treasuryID)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid treasury: %v", err)
+		}
+		bs.Treasury = &treasuryID
+	}
+
+	return bs, nil
+}
+
+// weiPerCoin returns the value of one coin in wei
+func (bs *BridgeState) weiPerCoin() *big.Int {
+	return new(big.Int).SetBytes(bs.WeiPerCoin)
+}
+
+// loadCoin returns the coin instance stored in id and its DARC, and makes
+// sure that it holds coins of the bridge type.
+func (bs *BridgeState) loadCoin(rst byzcoin.ReadOnlyStateTrie,
+	id byzcoin.InstanceID) (*byzcoin.Coin, darc.ID, error) {
+	value, _, contractID, darcID, err := rst.GetValues(id[:])
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to get coin instance: %v",
+			err)
+	}
+	if contractID != contracts.ContractCoinID {
+		return nil, nil, xerrors.New("not a coin instance")
+	}
+
+	var coin byzcoin.Coin
+	err = protobuf.Decode(value, &coin)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to decode coin: %v", err)
+	}
+	if !coin.Name.Equal(bs.CoinName) {
+		return nil, nil, xerrors.New("coin instance holds another type " +
+			"of coins")
+	}
+
+	return &coin, darcID, nil
+}
+
+// deposit takes the coins of the bridge type and credits their value to an
+// Ethereum address. It returns the remaining coins.
+func (bs *BridgeState) deposit(stateDb *state.StateDB,
+	address common.Address, coins []byzcoin.Coin) ([]byzcoin.Coin, error) {
+	var deposited uint64
+	cout := []byzcoin.Coin{}
+	for _, co := range coins {
+		if co.Name.Equal(bs.CoinName) {
+			deposited += co.Value
+			if deposited < co.Value {
+				return nil, xerrors.New("coin overflow")
+			}
+		} else {
+			cout = append(cout, co)
+		}
+	}
+	if deposited == 0 {
+		return nil, xerrors.New("no coins to deposit")
+	}
+
+	locked := byzcoin.Coin{Value: bs.Locked}
+	err := locked.SafeAdd(deposited)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to lock coins: %v", err)
+	}
+	bs.Locked = locked.Value
+
+	amount := new(big.Int).SetUint64(deposited)
+	amount.Mul(amount, bs.weiPerCoin())
+	stateDb.AddBalance(address, amount)
+
+	log.Lvlf2("Deposited %d coins as %d wei on '%x'", deposited, amount,
+		address)
+
+	return cout, nil
+}
+
+// unlock removes coins from the ones backing the Ether
+func (bs *BridgeState) unlock(coins uint64) error {
+	locked := byzcoin.Coin{Value: bs.Locked}
+	err := locked.SafeSub(coins)
+	if err != nil {
+		return xerrors.Errorf("internal error: not enough locked coins: %v",
+			err)
+	}
+	bs.Locked = locked.Value
+
+	return nil
+}
+
+// checkTransaction verifies an Ethereum transaction before it is executed.
+// It returns the coin instance receiving the withdrawn coins and their
+// number, if the transaction is a withdrawal.
+func (bs *BridgeState) checkTransaction(rst byzcoin.ReadOnlyStateTrie,
+	tx *types.Transaction) (*coinCredit, error) {
+	if tx.GasPrice().Cmp(new(big.Int).SetUint64(bs.MinGasPrice)) < 0 {
+		return nil, xerrors.Errorf("gas price is lower than the minimum "+
+			"of %d wei", bs.MinGasPrice)
+	}
+
+	if tx.To() == nil || *tx.To() != WithdrawAddress {
+		return nil, nil
+	}
+
+	if len(tx.Data()) != len(byzcoin.InstanceID{}) {
+		return nil, xerrors.New("the data of a withdrawal needs to be the " +
+			"InstanceID of a coin instance")
+	}
+	account := byzcoin.NewInstanceID(tx.Data())
+
+	coins, remainder := new(big.Int).DivMod(tx.Value(), bs.weiPerCoin(),
+		new(big.Int))
+	if remainder.Sign() != 0 {
+		return nil, xerrors.Errorf("the value of a withdrawal needs to be a "+
+			"multiple of %d wei", bs.weiPerCoin())
+	}
+	if coins.Sign() == 0 || !coins.IsUint64() {
+		return nil, xerrors.New("invalid withdrawal value")
+	}
+
+	_, _, err := bs.loadCoin(rst, account)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid withdrawal account: %v", err)
+	}
+
+	return &coinCredit{account: account, coins: coins.Uint64()}, nil
+}
+
+// settleTransaction converts the gas fees and the withdrawal of an executed
+// Ethereum transaction to coins. It returns the coins to store in coin
+// instances.
+func (bs *BridgeState) settleTransaction(stateDb *state.StateDB,
+	tx *types.Transaction, txReceipt *types.Receipt,
+	withdrawal *coinCredit) ([]coinCredit, error) {
+	var credits []coinCredit
+
+	// The EVM pays the gas fees to the coinbase address.
+	fee := new(big.Int).SetUint64(txReceipt.GasUsed)
+	fee.Mul(fee, tx.GasPrice())
+	stateDb.SubBalance(nilAddress, fee)
+
+	fees := new(big.Int).SetBytes(bs.Fees)
+	fees.Add(fees, fee)
+	feeCoins, remainder := new(big.Int).DivMod(fees, bs.weiPerCoin(),
+		new(big.Int))
+	bs.Fees = remainder.Bytes()
+
+	if feeCoins.Sign() > 0 {
+		err := bs.unlock(feeCoins.Uint64())
+		if err != nil {
+			return nil, err
+		}
+
+		if bs.Treasury != nil {
+			credits = append(credits, coinCredit{
+				account: *bs.Treasury,
+				coins:   feeCoins.Uint64(),
+			})
+		} else {
+			log.Lvlf2("Burning %d coins of gas fees", feeCoins)
+		}
+	}
+
+	// Only a successful transaction transfers its value.
+	if withdrawal != nil &&
+		txReceipt.Status == types.ReceiptStatusSuccessful {
+		stateDb.SubBalance(WithdrawAddress, tx.Value())
+
+		err := bs.unlock(withdrawal.coins)
+		if err != nil {
+			return nil, err
+		}
+
+		credits = append(credits, *withdrawal)
+	}
+
+	return credits, nil
+}
+
+// storeCoins returns the state changes storing the credited coins. Several
+// credits to the same coin instance are merged, as the state changes are all
+// based on the same state.
+func (bs *BridgeState) storeCoins(rst byzcoin.ReadOnlyStateTrie,
+	credits []coinCredit) ([]byzcoin.StateChange, error) {
+	var merged []coinCredit
+	for _, credit := range credits {
+		found := false
+		for i := range merged {
+			if merged[i].account.Equal(credit.account) {
+				merged[i].coins += credit.coins
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			merged = append(merged, credit)
+		}
+	}
+
+	var stateChanges []byzcoin.StateChange
+	for _, credit := range merged {
+		coin, darcID, err := bs.loadCoin(rst, credit.account)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load coin instance: %v",
+				err)
+		}
+
+		err = coin.SafeAdd(credit.coins)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to store coins: %v", err)
+		}
+
+		coinData, err := protobuf.Encode(coin)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to encode coin: %v", err)
+		}
+
+		log.Lvlf2("Storing %d coins in %x", credit.coins, credit.account[:])
+
+		stateChanges = append(stateChanges, byzcoin.NewStateChange(
+			byzcoin.Update, credit.account, contracts.ContractCoinID,
+			coinData, darcID))
+	}
+
+	return stateChanges, nil
+}
@@ -0,0 +1,205 @@
+package bevm
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
+	"go.dedis.ch/protobuf"
+)
+
+func Test_Bridge(t *testing.T) {
+	// Create a new ledger and prepare for proper closing
+	bct := newBCTest(t)
+	defer bct.Close()
+
+	// Create a coin account for the user and a treasury
+	userCoin := bct.spawnCoin("user", 10000)
+	treasuryCoin := bct.spawnCoin("treasury", 0)
+
+	// Spawn a new BEvm instance in bridge mode
+	weiPerCoin := big.NewInt(1e6)
+	instanceID, err := NewBEvmBridge(bct.cl, bct.signer, bct.gDarc,
+		BridgeParams{
+			CoinName:    contracts.CoinName,
+			WeiPerCoin:  weiPerCoin,
+			MinGasPrice: 10,
+			Treasury:    &treasuryCoin,
+		})
+	require.NoError(t, err)
+
+	// Create a new BEvm client
+	bevmClient, err := NewClient(bct.cl, bct.signer, instanceID)
+	require.NoError(t, err)
+
+	bridge, err := bevmClient.GetBridge()
+	require.NoError(t, err)
+	require.NotNil(t, bridge)
+	require.Equal(t, uint64(10), bridge.MinGasPrice)
+	require.Equal(t, uint64(0), bridge.Locked)
+
+	// Initialize an account
+	a, err := NewEvmAccount(testPrivateKeys[0])
+	require.NoError(t, err)
+
+	// Ether cannot be credited in bridge mode
+	_, err = bevmClient.CreditAccount(big.NewInt(5*WeiPerEther), a.Address)
+	require.Error(t, err)
+
+	// Deposit 1000 coins
+	_, err = bevmClient.Deposit(userCoin, 1000, a.Address)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9000), bct.getCoin(userCoin))
+
+	balance, err := bevmClient.GetAccountBalance(a.Address)
+	require.NoError(t, err)
+	require.Equal(t, new(big.Int).Mul(big.NewInt(1000), weiPerCoin), balance)
+
+	// The gas price must be at least the minimum
+	candyContract, err := NewEvmContract(
+		"Candy",
+		getContractData(t, "Candy", "abi"),
+		getContractData(t, "Candy", "bin"))
+	require.NoError(t, err)
+	_, _, err = bevmClient.Deploy(txParams.GasLimit, 1, 0, a, candyContract,
+		big.NewInt(100))
+	require.Error(t, err)
+
+	// The gas fees go to the treasury
+	_, _, err = bevmClient.Deploy(txParams.GasLimit, 10, 0, a, candyContract,
+		big.NewInt(100))
+	require.NoError(t, err)
+	require.True(t, bct.getCoin(treasuryCoin) > 0)
+	bct.checkBacking(bevmClient, a, 1000)
+
+	// Withdraw 100 coins
+	_, err = bevmClient.Withdraw(txParams.GasLimit, 10, a, 100, userCoin)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9100), bct.getCoin(userCoin))
+	bct.checkBacking(bevmClient, a, 900)
+
+	// The coins cannot be withdrawn to an unknown instance
+	_, err = bevmClient.Withdraw(txParams.GasLimit, 10, a, 100,
+		byzcoin.NewInstanceID([]byte("unknown")))
+	require.Error(t, err)
+
+	// The coins cannot be withdrawn twice
+	_, err = bevmClient.Withdraw(txParams.GasLimit, 10, a, 1000, userCoin)
+	require.Error(t, err)
+
+	// The BEvm instance cannot be deleted while it holds coins
+	err = bevmClient.Delete()
+	require.Error(t, err)
+}
+
+func Test_BridgeDisabled(t *testing.T) {
+	// Create a new ledger and prepare for proper closing
+	bct := newBCTest(t)
+	defer bct.Close()
+
+	userCoin := bct.spawnCoin("user", 10000)
+
+	// Spawn a new BEvm instance without bridge mode
+	instanceID, err := NewBEvm(bct.cl, bct.signer, bct.gDarc)
+	require.NoError(t, err)
+
+	bevmClient, err := NewClient(bct.cl, bct.signer, instanceID)
+	require.NoError(t, err)
+
+	bridge, err := bevmClient.GetBridge()
+	require.NoError(t, err)
+	require.Nil(t, bridge)
+
+	a, err := NewEvmAccount(testPrivateKeys[0])
+	require.NoError(t, err)
+
+	// Coins cannot be deposited nor withdrawn
+	_, err = bevmClient.Deposit(userCoin, 1000, a.Address)
+	require.Error(t, err)
+	require.Equal(t, uint64(10000), bct.getCoin(userCoin))
+
+	_, err = bevmClient.Withdraw(txParams.GasLimit, txParams.GasPrice, a, 1,
+		userCoin)
+	require.Error(t, err)
+}
+
+// checkBacking verifies that the Ether of the account, the only one holding
+// Ether, is backed by the locked coins
+func (bct *bcTest) checkBacking(bevmClient *Client, account *EvmAccount,
+	deposited uint64) {
+	bridge, err := bevmClient.GetBridge()
+	require.NoError(bct.t, err)
+
+	treasury := bct.getCoin(*bridge.Treasury)
+	require.Equal(bct.t, deposited, bridge.Locked+treasury)
+
+	balance, err := bevmClient.GetAccountBalance(account.Address)
+	require.NoError(bct.t, err)
+
+	backed := new(big.Int).SetUint64(bridge.Locked)
+	backed.Mul(backed, bridge.weiPerCoin())
+	balance.Add(balance, new(big.Int).SetBytes(bridge.Fees))
+	require.Equal(bct.t, 0, backed.Cmp(balance))
+}
+
+// spawnCoin creates a coin instance holding the given number of coins
+func (bct *bcTest) spawnCoin(name string, coins uint64) byzcoin.InstanceID {
+	counters, err := bct.cl.GetSignerCounters(bct.signer.Identity().String())
+	require.NoError(bct.t, err)
+
+	h := sha256.New()
+	h.Write([]byte(contracts.ContractCoinID))
+	h.Write([]byte(name))
+	coinID := byzcoin.NewInstanceID(h.Sum(nil))
+
+	coinsBuf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(coinsBuf, coins)
+
+	tx, err := bct.cl.CreateTransaction(
+		byzcoin.Instruction{
+			InstanceID:    byzcoin.NewInstanceID(bct.gDarc.GetBaseID()),
+			SignerCounter: []uint64{counters.Counters[0] + 1},
+			Spawn: &byzcoin.Spawn{
+				ContractID: contracts.ContractCoinID,
+				Args: byzcoin.Arguments{
+					{Name: "coinID", Value: []byte(name)},
+				},
+			},
+		},
+		byzcoin.Instruction{
+			InstanceID:    coinID,
+			SignerCounter: []uint64{counters.Counters[0] + 2},
+			Invoke: &byzcoin.Invoke{
+				ContractID: contracts.ContractCoinID,
+				Command:    "mint",
+				Args: byzcoin.Arguments{
+					{Name: "coins", Value: coinsBuf},
+				},
+			},
+		})
+	require.NoError(bct.t, err)
+	require.NoError(bct.t, tx.FillSignersAndSignWith(bct.signer))
+
+	_, err = bct.cl.AddTransactionAndWait(tx, 5)
+	require.NoError(bct.t, err)
+
+	return coinID
+}
+
+// getCoin returns the number of coins held by a coin instance
+func (bct *bcTest) getCoin(coinID byzcoin.InstanceID) uint64 {
+	proofResponse, err := bct.cl.GetProofFromLatest(coinID[:])
+	require.NoError(bct.t, err)
+
+	_, value, _, _, err := proofResponse.Proof.KeyValue()
+	require.NoError(bct.t, err)
+
+	var coin byzcoin.Coin
+	require.NoError(bct.t, protobuf.Decode(value, &coin))
+
+	return coin.Value
+}
@@ -61,6 +61,9 @@ func contractBEvmFromBytes(in []byte) (byzcoin.Contract, error) {
 type State struct {
 	RootHash common.Hash // Hash of the last commit in the EVM state database
 	KeyList  []string    // List of keys contained in the EVM state database
+	// Bridge backs the Ether with ByzCoin coins; nil if Ether is created by
+	// "credit"
+	Bridge *BridgeState `protobuf:"opt"`
 }
 
 // NewEvmDb creates a new EVM state database from the contract state
@@ -165,6 +168,12 @@ func (c *contractBEvm) Spawn(rst byzcoin.ReadOnlyStateTrie,
 			xerrors.Errorf("failed to create new BEvm contract state: %v", err)
 	}
 
+	contractState.Bridge, err = newBridgeState(rst, inst.Spawn.Args)
+	if err != nil {
+		return nil, nil,
+			xerrors.Errorf("failed to configure BEvm bridge mode: %v", err)
+	}
+
 	contractData, err := protobuf.Encode(contractState)
 	if err != nil {
 		return nil, nil, xerrors.Errorf("failed to encode BEvm "+
@@ -218,6 +227,13 @@ func (c *contractBEvm) Invoke(rst byzcoin.ReadOnlyStateTrie,
 				"method on BEvm contract: %v", err)
 		}
 
+	case "deposit":
+		cout, err = c.invokeDeposit(inst, stateDb, coins)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("failed to execute \"deposit\" "+
+				"method on BEvm contract: %v", err)
+		}
+
 	case "transaction":
 		methodSc, err = c.invokeTransaction(rst, inst, stateDb, darcID)
 		if err != nil {
@@ -236,6 +252,9 @@ func (c *contractBEvm) Invoke(rst byzcoin.ReadOnlyStateTrie,
 				"state: %v", err)
 	}
 
+	// The methods update the bridge state in place
+	contractState.Bridge = c.State.Bridge
+
 	contractData, err := protobuf.Encode(contractState)
 	if err != nil {
 		return nil, nil,
@@ -260,6 +279,11 @@ func (c *contractBEvm) Invoke(rst byzcoin.ReadOnlyStateTrie,
 func (c *contractBEvm) invokeCredit(rst byzcoin.ReadOnlyStateTrie,
 	inst byzcoin.Instruction, stateDb *state.StateDB,
 	darcID darc.ID) ([]byzcoin.StateChange, error) {
+	if c.Bridge != nil {
+		return nil, xerrors.New("Ether cannot be credited in bridge mode, " +
+			"it must be deposited")
+	}
+
 	err := checkArguments(inst, "address", "amount")
 	if err != nil {
 		return nil,
@@ -275,37 +299,34 @@ func (c *contractBEvm) invokeCredit(rst byzcoin.ReadOnlyStateTrie,
 	return nil, nil
 }
 
+// Deposit coins on an Ethereum account, in bridge mode
+func (c *contractBEvm) invokeDeposit(inst byzcoin.Instruction,
+	stateDb *state.StateDB, coins []byzcoin.Coin) ([]byzcoin.Coin, error) {
+	if c.Bridge == nil {
+		return nil, xerrors.New("coins can only be deposited in bridge mode")
+	}
+
+	err := checkArguments(inst, "address")
+	if err != nil {
+		return nil,
+			xerrors.Errorf("failed to validate arguments for 'deposit' "+
+				"invocation on BEvm: %v", err)
+	}
+
+	address := common.BytesToAddress(inst.Invoke.Args.Search("address"))
+
+	return c.Bridge.deposit(stateDb, address, coins)
+}
+
 // Perform an Ethereum transaction (contract method call with state change)
 func (c *contractBEvm) invokeTransaction(rst byzcoin.ReadOnlyStateTrie,
 	inst byzcoin.Instruction, stateDb *state.StateDB,
 	darcID darc.ID) ([]byzcoin.StateChange, error) {
 	// Retrieve the Ethereum transaction
-	var ethTx types.Transaction
-
-	// The client can send the transaction serlalized either in JSON (using
-	// the "tx" parameter) or in RLP (using the "txRlp" parameter).
-	// This latter possibility was added due to compatibility issues with
-	// the JSON produced by the JS libraries.
-	encodedTx := inst.Invoke.Args.Search("tx")
-	if encodedTx != nil {
-		err := ethTx.UnmarshalJSON(encodedTx)
-		if err != nil {
-			return nil, xerrors.Errorf("failed to decode JSON for EVM "+
-				"transaction: %v", err)
-		}
-	} else {
-		encodedTx = inst.Invoke.Args.Search("txRlp")
-		if encodedTx == nil {
-			return nil, xerrors.New("Missing either \"tx\" or " +
-				"\"txRlp\" argument for BEvm \"transaction\" invocation")
-		}
-
-		s := rlp.NewStream(strings.NewReader(string(encodedTx)), 0)
-		err := ethTx.DecodeRLP(s)
-		if err != nil {
-			return nil, xerrors.Errorf("failed to decode RLP for EVM "+
-				"transaction: %v", err)
-		}
+	ethTx, err := decodeTransaction(inst.Invoke.Args)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve EVM transaction: %v",
+			err)
 	}
 
 	// Retrieve the TimeReader (we are actually called with a GlobalState)
@@ -318,8 +339,17 @@ func (c *contractBEvm) invokeTransaction(rst byzcoin.ReadOnlyStateTrie,
 	// Compute the timestamp for the EVM, converting [ns] to [s]
 	evmTs := uint64(tr.GetCurrentBlockTimestamp() / 1e9)
 
+	var withdrawal *coinCredit
+	if c.Bridge != nil {
+		withdrawal, err = c.Bridge.checkTransaction(rst, ethTx)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid EVM transaction in bridge "+
+				"mode: %v", err)
+		}
+	}
+
 	stateDb.Prepare(ethTx.Hash(), common.Hash{}, 0)
-	txReceipt, err := sendTx(&ethTx, stateDb, evmTs)
+	txReceipt, err := sendTx(ethTx, stateDb, evmTs)
 	if err != nil {
 		return nil,
 			xerrors.Errorf("failed to send transaction to EVM: %v", err)
@@ -340,7 +370,76 @@ func (c *contractBEvm) invokeTransaction(rst byzcoin.ReadOnlyStateTrie,
 			"logs: %v", err)
 	}
 
-	return eventStateChanges, nil
+	// The sender has already been checked by the EVM
+	from, err := types.Sender(types.MakeSigner(getChainConfig(),
+		big.NewInt(0)), ethTx)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve EVM transaction "+
+			"sender: %v", err)
+	}
+
+	receiptStateChanges, err := storeReceipt(rst, inst.InstanceID, darcID,
+		ethTx, from, txReceipt)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to store EVM transaction "+
+			"receipt: %v", err)
+	}
+
+	stateChanges := append(eventStateChanges, receiptStateChanges...)
+
+	if c.Bridge != nil {
+		credits, err := c.Bridge.settleTransaction(stateDb, ethTx, txReceipt,
+			withdrawal)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to settle EVM transaction "+
+				"in bridge mode: %v", err)
+		}
+
+		coinStateChanges, err := c.Bridge.storeCoins(rst, credits)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to store coins: %v", err)
+		}
+
+		stateChanges = append(stateChanges, coinStateChanges...)
+	}
+
+	return stateChanges, nil
+}
+
+// decodeTransaction retrieves the Ethereum transaction from the arguments of
+// a BEvm "transaction" invocation.
+func decodeTransaction(args byzcoin.Arguments) (*types.Transaction, error) {
+	var ethTx types.Transaction
+
+	// The client can send the transaction serlalized either in JSON (using
+	// the "tx" parameter) or in RLP (using the "txRlp" parameter).
+	// This latter possibility was added due to compatibility issues with
+	// the JSON produced by the JS libraries.
+	encodedTx := args.Search("tx")
+	if encodedTx != nil {
+		err := ethTx.UnmarshalJSON(encodedTx)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to decode JSON for EVM "+
+				"transaction: %v", err)
+		}
+
+		return &ethTx, nil
+	}
+
+	encodedTx = args.Search("txRlp")
+	if encodedTx == nil {
+		return nil, xerrors.New("Missing either \"tx\" or " +
+			"\"txRlp\" argument for BEvm \"transaction\" invocation")
+	}
+
+	s := rlp.NewStream(strings.NewReader(string(encodedTx)), 0)
+	err := ethTx.DecodeRLP(s)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode RLP for EVM "+
+			"transaction: %v", err)
+	}
+
+	return &ethTx, nil
 }
 
 // Helper function that sends a transaction to the EVM
@@ -391,6 +490,11 @@ func (c *contractBEvm) Delete(rst byzcoin.ReadOnlyStateTrie,
 		return nil, nil, xerrors.Errorf("failed to get darcID: %v", err)
 	}
 
+	if c.Bridge != nil && c.Bridge.Locked > 0 {
+		return nil, nil, xerrors.New("cannot delete a BEvm instance that " +
+			"still holds coins")
+	}
+
 	stateDb, err := NewEvmDb(&c.State, rst, inst.InstanceID)
 	if err != nil {
 		return nil, nil, xerrors.Errorf("failed to create new "+
@@ -414,8 +518,8 @@ func (c *contractBEvm) Delete(rst byzcoin.ReadOnlyStateTrie,
 }
 
 // Handle the log entries produced by an EVM execution.
-// Currently, only the special entries allowing to interact with Byzcoin
-// contracts are handled, the other ones are ignored.
+// Only the special entries allowing to interact with Byzcoin contracts are
+// handled here; all the entries are kept in the transaction receipt.
 func handleLogs(inst byzcoin.Instruction, rst byzcoin.ReadOnlyStateTrie,
 	logEntries []*types.Log) (
 	[]byzcoin.StateChange, error) {
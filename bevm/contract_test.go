@@ -481,8 +481,12 @@ func newBCTest(t *testing.T) (out *bcTest) {
 		[]string{
 			"spawn:bevm",
 			"invoke:bevm.credit",
+			"invoke:bevm.deposit",
 			"invoke:bevm.transaction",
-			"delete:bevm"},
+			"delete:bevm",
+			"spawn:coin",
+			"invoke:coin.mint",
+			"invoke:coin.fetch"},
 		out.signer.Identity())
 	require.NoError(t, err)
 	out.gDarc = &out.gMsg.GenesisDarc
@@ -0,0 +1,1195 @@
+package bevm
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// gatewayStorageKey is the key of the gateway configuration in the storage
+// of the service, so that the gateway is started again with the conode.
+var gatewayStorageKey = []byte("gateway")
+
+// gatewayAdminWindow is how far the timestamp of a StartGatewayRequest can be
+// from the time of the conode.
+const gatewayAdminWindow = time.Minute
+
+// gatewayInclusionWait is the number of block intervals the gateway waits
+// for a transaction to be included in the ledger.
+const gatewayInclusionWait = 10
+
+// gatewaySearchDepth is the number of blocks the gateway goes through,
+// starting from the latest one, when looking for a transaction.
+const gatewaySearchDepth = 1000
+
+// gatewayGasPrice is the gas price suggested by the gateway, in wei.
+const gatewayGasPrice = 1
+
+// The gateway maps the Ethereum JSON-RPC API to a BEvm instance. Each BEvm
+// instance is served on its own URL path:
+//
+//	/<ByzCoin ID>/<BEvm instance ID>
+//
+// where both IDs are hex-encoded.
+//
+// The ByzCoin blocks play the role of the Ethereum blocks, and the Ethereum
+// transactions are the ones found in the BEvm "transaction" invocations of
+// the blocks. The state can only be queried at the latest block.
+//
+// The Ethereum transactions sent to the gateway are wrapped in ByzCoin
+// transactions signed by the gateway, so its identity needs the
+// "invoke:bevm.transaction" rule in the DARC of the BEvm instance.
+type gateway struct {
+	service *Service
+	signer  darc.Signer
+	// allowOrigin is the value of the Access-Control-Allow-Origin header, or
+	// empty to leave CORS disabled
+	allowOrigin string
+
+	// Protects servers
+	serversLock sync.Mutex
+	servers     map[string]*rpc.Server
+
+	// Serializes the sending of the ByzCoin transactions of the gateway,
+	// which all use the same signer. Also protects counters.
+	submitLock sync.Mutex
+	// counters holds, for each ByzCoin ID, the signer counter of the last
+	// transaction sent, which may not be included yet
+	counters map[string]uint64
+}
+
+// gatewayConfig is the configuration of the gateway given by the operator of
+// the conode
+type gatewayConfig struct {
+	Address     string
+	Signer      darc.Signer
+	AllowOrigin string
+}
+
+// newGateway creates a new JSON-RPC gateway signing its ByzCoin transactions
+// with the given signer, and allowing the given origin, if any
+func newGateway(service *Service, signer darc.Signer,
+	allowOrigin string) *gateway {
+	return &gateway{
+		service:     service,
+		signer:      signer,
+		allowOrigin: allowOrigin,
+		servers:     make(map[string]*rpc.Server),
+		counters:    make(map[string]uint64),
+	}
+}
+
+// hash returns the hash signed by the conode key
+func (req *StartGatewayRequest) hash() []byte {
+	h := sha256.New()
+	h.Write([]byte(req.Address))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Signer.Identity().String()))
+	h.Write([]byte{0})
+	h.Write([]byte(req.AllowOrigin))
+	h.Write([]byte{0})
+	ts := make([]byte, 8)
+	binary.LittleEndian.PutUint64(ts, uint64(req.Timestamp))
+	h.Write(ts)
+	return h.Sum(nil)
+}
+
+// StartGateway starts the Ethereum JSON-RPC gateway of the conode with the
+// configuration given by its operator, and stores the configuration so that
+// the gateway is started again when the conode restarts.
+func (service *Service) StartGateway(req *StartGatewayRequest) (
+	*StartGatewayResponse, error) {
+	ts := time.Unix(req.Timestamp, 0)
+	if ts.Before(time.Now().Add(-gatewayAdminWindow)) ||
+		ts.After(time.Now().Add(gatewayAdminWindow)) {
+		return nil, xerrors.New("the request timestamp is too far from now")
+	}
+
+	err := schnorr.Verify(cothority.Suite, service.ServerIdentity().Public,
+		req.hash(), req.Signature)
+	if err != nil {
+		return nil, xerrors.Errorf("signature verification failed: %v", err)
+	}
+
+	cfg := &gatewayConfig{
+		Address:     req.Address,
+		Signer:      req.Signer,
+		AllowOrigin: req.AllowOrigin,
+	}
+
+	err = service.startGateway(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	err = service.Save(gatewayStorageKey, cfg)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to save gateway "+
+			"configuration: %v", err)
+	}
+
+	return &StartGatewayResponse{}, nil
+}
+
+// startGateway starts the JSON-RPC gateway with the given configuration. The
+// gateway must have its own signer, as the conode key must not be used to
+// sign ByzCoin transactions on behalf of anyone.
+func (service *Service) startGateway(cfg *gatewayConfig) error {
+	if cfg.Signer.Ed25519 == nil {
+		return xerrors.New("the gateway signer must be an Ed25519 signer")
+	}
+
+	if cfg.Signer.Ed25519.Point.Equal(service.ServerIdentity().Public) {
+		return xerrors.New("the gateway signer must not be the conode key")
+	}
+
+	service.gatewayLock.Lock()
+	defer service.gatewayLock.Unlock()
+
+	if service.gatewayListener != nil {
+		return xerrors.Errorf("the gateway is already listening on %s",
+			service.gatewayListener.Addr())
+	}
+
+	listener, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		return xerrors.Errorf("failed to listen on '%s': %v", cfg.Address,
+			err)
+	}
+
+	service.gatewayListener = listener
+
+	go func() {
+		err := http.Serve(listener,
+			newGateway(service, cfg.Signer, cfg.AllowOrigin))
+		log.Lvlf2("BEvm JSON-RPC gateway stopped: %v", err)
+	}()
+
+	log.Lvlf1("BEvm JSON-RPC gateway listening on %s, signing as %s",
+		listener.Addr(), cfg.Signer.Identity())
+
+	return nil
+}
+
+// ServeHTTP dispatches the JSON-RPC requests to the server of the BEvm
+// instance given by the URL path
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Allow the dapps of the configured origin to use the gateway from a
+	// browser
+	if g.allowOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", g.allowOrigin)
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			return
+		}
+	}
+
+	server, err := g.getServer(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	server.ServeHTTP(w, r)
+}
+
+// getServer returns the JSON-RPC server of the BEvm instance given by the
+// URL path, creating it if needed
+func (g *gateway) getServer(path string) (*rpc.Server, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 2 {
+		return nil, xerrors.New("expected URL path " +
+			"/<ByzCoin ID>/<BEvm instance ID>")
+	}
+
+	key := strings.ToLower(parts[0] + "/" + parts[1])
+
+	g.serversLock.Lock()
+	defer g.serversLock.Unlock()
+
+	server, ok := g.servers[key]
+	if ok {
+		return server, nil
+	}
+
+	byzcoinID, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return nil, xerrors.Errorf("invalid ByzCoin ID: %v", err)
+	}
+
+	bevmID, err := hex.DecodeString(parts[1])
+	if err != nil || len(bevmID) != 32 {
+		return nil, xerrors.New("invalid BEvm instance ID")
+	}
+
+	api := &ethAPI{
+		gateway:    g,
+		byzcoinID:  byzcoinID,
+		instanceID: byzcoin.NewInstanceID(bevmID),
+	}
+
+	// Make sure the BEvm instance exists before serving it
+	rst, err := api.getReadOnlyStateTrie()
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, contractID, _, err := rst.GetValues(bevmID)
+	if err != nil || contractID != ContractBEvmID {
+		return nil, xerrors.Errorf("no BEvm instance %x in ByzCoin %x",
+			bevmID, byzcoinID)
+	}
+
+	server = rpc.NewServer()
+
+	err = server.RegisterName("eth", api)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to register eth API: %v", err)
+	}
+
+	err = server.RegisterName("net", &netAPI{})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to register net API: %v", err)
+	}
+
+	g.servers[key] = server
+
+	return server, nil
+}
+
+// submit wraps an RLP-encoded Ethereum transaction in a BEvm "transaction"
+// invocation, and waits for it to be included in the ledger
+func (g *gateway) submit(byzcoinID skipchain.SkipBlockID,
+	instanceID byzcoin.InstanceID, encodedTx []byte) error {
+	bcService, err := g.service.byzcoinService()
+	if err != nil {
+		return err
+	}
+
+	interval, _, err := bcService.LoadBlockInfo(byzcoinID)
+	if err != nil {
+		return xerrors.Errorf("failed to load block info: %v", err)
+	}
+
+	txHash, err := g.send(bcService, byzcoinID, instanceID, encodedTx)
+	if err != nil {
+		return err
+	}
+
+	// Other transactions can be sent while this one waits. As ByzCoin does,
+	// give up after twice the expected time.
+	timeoutDur := 2 * interval * gatewayInclusionWait
+	timeout := time.After(timeoutDur)
+	ticker := time.NewTicker(interval / 10)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-timeout:
+			g.resetCounter(byzcoinID)
+			return xerrors.Errorf("ByzCoin transaction was not included "+
+				"after %v", timeoutDur)
+		}
+
+		status, err := bcService.GetTxStatus(&byzcoin.GetTxStatus{
+			SkipchainID: byzcoinID,
+			TxHash:      txHash,
+		})
+		if err != nil {
+			return xerrors.Errorf("failed to get ByzCoin transaction "+
+				"status: %v", err)
+		}
+
+		switch status.Status {
+		case byzcoin.TxStatusAccepted:
+			return nil
+		case byzcoin.TxStatusRefused:
+			g.resetCounter(byzcoinID)
+			return xerrors.Errorf("ByzCoin transaction was refused: %s",
+				status.Error)
+		}
+	}
+}
+
+// send signs the ByzCoin transaction wrapping an Ethereum transaction with
+// the next signer counter, and sends it without waiting for its inclusion.
+// It returns the hash of the ByzCoin transaction.
+func (g *gateway) send(bcService *byzcoin.Service,
+	byzcoinID skipchain.SkipBlockID, instanceID byzcoin.InstanceID,
+	encodedTx []byte) ([]byte, error) {
+	rst, err := bcService.GetReadOnlyStateTrie(byzcoinID)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve "+
+			"ReadOnlyStateTrie: %v", err)
+	}
+
+	g.submitLock.Lock()
+	defer g.submitLock.Unlock()
+
+	counters, err := bcService.GetSignerCounters(&byzcoin.GetSignerCounters{
+		SignerIDs:   []string{g.signer.Identity().String()},
+		SkipchainID: byzcoinID,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve signer counters "+
+			"from ByzCoin: %v", err)
+	}
+
+	// The transactions sent before might not be included yet
+	counter := counters.Counters[0]
+	if last := g.counters[string(byzcoinID)]; last > counter {
+		counter = last
+	}
+	counter++
+
+	tx := byzcoin.NewClientTransaction(rst.GetVersion(), byzcoin.Instruction{
+		InstanceID: instanceID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: ContractBEvmID,
+			Command:    "transaction",
+			Args: byzcoin.Arguments{
+				{Name: "txRlp", Value: encodedTx},
+			},
+		},
+		SignerCounter: []uint64{counter},
+	})
+
+	err = tx.FillSignersAndSignWith(g.signer)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to sign ByzCoin transaction: %v",
+			err)
+	}
+
+	_, err = bcService.AddTransaction(&byzcoin.AddTxRequest{
+		Version:     byzcoin.CurrentVersion,
+		SkipchainID: byzcoinID,
+		Transaction: tx,
+	})
+	if err != nil {
+		delete(g.counters, string(byzcoinID))
+		return nil, xerrors.Errorf("failed to add ByzCoin transaction: %v",
+			err)
+	}
+
+	g.counters[string(byzcoinID)] = counter
+
+	return tx.Instructions.Hash(), nil
+}
+
+// resetCounter makes the next transaction use the signer counter of the
+// ledger, as the transactions sent after a failed one fail as well
+func (g *gateway) resetCounter(byzcoinID skipchain.SkipBlockID) {
+	g.submitLock.Lock()
+	delete(g.counters, string(byzcoinID))
+	g.submitLock.Unlock()
+}
+
+// ---------------------------------------------------------------------------
+
+// netAPI implements the "net_*" JSON-RPC methods
+type netAPI struct{}
+
+// Version returns the network ID, which is the chain ID of the BEvm
+func (api *netAPI) Version() string {
+	return getChainConfig().ChainID.String()
+}
+
+// CallArgs holds the arguments of the "eth_call" and "eth_estimateGas"
+// JSON-RPC methods
+type CallArgs struct {
+	From     *common.Address `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      *hexutil.Uint64 `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Data     *hexutil.Bytes  `json:"data"`
+	Input    *hexutil.Bytes  `json:"input"`
+}
+
+// from returns the sender of the call, which is optional
+func (args CallArgs) from() common.Address {
+	if args.From == nil {
+		return nilAddress
+	}
+
+	return *args.From
+}
+
+// data returns the call data, which some tools send as "input"
+func (args CallArgs) data() []byte {
+	if args.Input != nil {
+		return *args.Input
+	}
+	if args.Data != nil {
+		return *args.Data
+	}
+
+	return nil
+}
+
+// FilterArgs holds the arguments of the "eth_getLogs" JSON-RPC method
+type FilterArgs struct {
+	FromBlock *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock   *rpc.BlockNumber `json:"toBlock"`
+	BlockHash *common.Hash     `json:"blockHash"`
+	Address   addressList      `json:"address"`
+	Topics    []topicList      `json:"topics"`
+}
+
+// addressList is a list of addresses, which can be given as a single address
+type addressList []common.Address
+
+// UnmarshalJSON accepts either an address or a list of addresses
+func (list *addressList) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*list = nil
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, (*[]common.Address)(list))
+	}
+
+	var address common.Address
+	err := json.Unmarshal(data, &address)
+	if err != nil {
+		return err
+	}
+
+	*list = addressList{address}
+	return nil
+}
+
+// topicList is the list of accepted values of a topic, which can be given as
+// a single hash, or null to accept any value
+type topicList []common.Hash
+
+// UnmarshalJSON accepts null, a hash or a list of hashes
+func (list *topicList) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*list = nil
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, (*[]common.Hash)(list))
+	}
+
+	var hash common.Hash
+	err := json.Unmarshal(data, &hash)
+	if err != nil {
+		return err
+	}
+
+	*list = topicList{hash}
+	return nil
+}
+
+// ethTx is an Ethereum transaction found in a ByzCoin block
+type ethTx struct {
+	tx    *types.Transaction
+	from  common.Address
+	block *skipchain.SkipBlock
+	index int
+}
+
+// ethAPI implements the "eth_*" JSON-RPC methods for a BEvm instance
+type ethAPI struct {
+	gateway    *gateway
+	byzcoinID  skipchain.SkipBlockID
+	instanceID byzcoin.InstanceID
+}
+
+// ChainId returns the chain ID used to sign the transactions
+func (api *ethAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(getChainConfig().ChainID)
+}
+
+// Accounts returns the accounts managed by the gateway, which has none
+func (api *ethAPI) Accounts() []common.Address {
+	return []common.Address{}
+}
+
+// BlockNumber returns the index of the latest ByzCoin block
+func (api *ethAPI) BlockNumber() (hexutil.Uint64, error) {
+	latest, err := api.getLatestBlock()
+	if err != nil {
+		return 0, err
+	}
+
+	return hexutil.Uint64(latest.Index), nil
+}
+
+// GasPrice returns the suggested gas price, which is at least the minimum
+// gas price of the bridge mode
+func (api *ethAPI) GasPrice() (*hexutil.Big, error) {
+	rst, err := api.getReadOnlyStateTrie()
+	if err != nil {
+		return nil, err
+	}
+
+	value, _, _, _, err := rst.GetValues(api.instanceID[:])
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve BEvm instance: %v",
+			err)
+	}
+
+	var bs State
+	err = protobuf.Decode(value, &bs)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode BEvm instance state: %v",
+			err)
+	}
+
+	gasPrice := new(big.Int).SetUint64(gatewayGasPrice)
+	if bs.Bridge != nil && bs.Bridge.MinGasPrice > gatewayGasPrice {
+		gasPrice.SetUint64(bs.Bridge.MinGasPrice)
+	}
+
+	return (*hexutil.Big)(gasPrice), nil
+}
+
+// GetBalance returns the balance of an address, in wei
+func (api *ethAPI) GetBalance(address common.Address,
+	number rpc.BlockNumber) (*hexutil.Big, error) {
+	stateDb, err := api.getStateDb(number)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*hexutil.Big)(stateDb.GetBalance(address)), nil
+}
+
+// GetTransactionCount returns the nonce of an address
+func (api *ethAPI) GetTransactionCount(address common.Address,
+	number rpc.BlockNumber) (hexutil.Uint64, error) {
+	stateDb, err := api.getStateDb(number)
+	if err != nil {
+		return 0, err
+	}
+
+	return hexutil.Uint64(stateDb.GetNonce(address)), nil
+}
+
+// GetCode returns the code of the contract at an address
+func (api *ethAPI) GetCode(address common.Address,
+	number rpc.BlockNumber) (hexutil.Bytes, error) {
+	stateDb, err := api.getStateDb(number)
+	if err != nil {
+		return nil, err
+	}
+
+	return stateDb.GetCode(address), nil
+}
+
+// Call executes a view method, using the ViewCall service method
+func (api *ethAPI) Call(args CallArgs, number rpc.BlockNumber) (
+	hexutil.Bytes, error) {
+	err := api.checkLatest(number)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.To == nil {
+		return nil, xerrors.New("missing contract address")
+	}
+
+	from := args.from()
+	resp, err := api.gateway.service.ViewCall(&ViewCallRequest{
+		ByzCoinID:       api.byzcoinID,
+		BEvmInstanceID:  api.instanceID[:],
+		AccountAddress:  from[:],
+		ContractAddress: args.To[:],
+		CallData:        args.data(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Result, nil
+}
+
+// EstimateGas returns the gas used by a transaction executed on the latest
+// state
+func (api *ethAPI) EstimateGas(args CallArgs) (hexutil.Uint64, error) {
+	stateDb, err := api.getStateDb(rpc.LatestBlockNumber)
+	if err != nil {
+		return 0, err
+	}
+
+	// timestamp in ByzCoin is in [ns], whereas in EVM it is in [s]
+	evmContext := getContext(time.Now().UnixNano() / 1e9)
+	evm := vm.NewEVM(evmContext, stateDb, getChainConfig(), getVMConfig())
+
+	value := big.NewInt(0)
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+
+	gas := evmContext.GasLimit
+	var leftOverGas uint64
+	if args.To == nil {
+		_, _, leftOverGas, err = evm.Create(vm.AccountRef(args.from()),
+			args.data(), gas, value)
+	} else {
+		_, leftOverGas, err = evm.Call(vm.AccountRef(args.from()), *args.To,
+			args.data(), gas, value)
+	}
+	if err != nil {
+		return 0, xerrors.Errorf("failed to execute EVM transaction: %v", err)
+	}
+
+	intrinsicGas, err := core.IntrinsicGas(args.data(), args.To == nil, true)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to compute intrinsic gas: %v", err)
+	}
+
+	return hexutil.Uint64(gas - leftOverGas + intrinsicGas), nil
+}
+
+// SendRawTransaction executes a signed, RLP-encoded Ethereum transaction
+// using a BEvm "transaction" invocation. It returns once the transaction is
+// included in the ledger.
+func (api *ethAPI) SendRawTransaction(encodedTx hexutil.Bytes) (
+	common.Hash, error) {
+	tx := new(types.Transaction)
+	err := rlp.DecodeBytes(encodedTx, tx)
+	if err != nil {
+		return common.Hash{}, xerrors.Errorf("failed to decode RLP for EVM "+
+			"transaction: %v", err)
+	}
+
+	err = api.gateway.submit(api.byzcoinID, api.instanceID, encodedTx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return tx.Hash(), nil
+}
+
+// GetBlockByNumber returns a ByzCoin block, with the Ethereum transactions
+// it contains
+func (api *ethAPI) GetBlockByNumber(number rpc.BlockNumber, fullTx bool) (
+	map[string]interface{}, error) {
+	block, err := api.getBlock(number)
+	if err != nil || block == nil {
+		return nil, err
+	}
+
+	var header byzcoin.DataHeader
+	err = protobuf.Decode(block.Data, &header)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode block header: %v", err)
+	}
+
+	txs, err := api.getBlockTransactions(block)
+	if err != nil {
+		return nil, err
+	}
+
+	rst, err := api.getReadOnlyStateTrie()
+	if err != nil {
+		return nil, err
+	}
+
+	br, err := getBlockReceipts(rst, api.instanceID, block.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := make([]interface{}, len(txs))
+	for i, tx := range txs {
+		if fullTx {
+			transactions[i] = marshalTransaction(tx)
+		} else {
+			transactions[i] = tx.tx.Hash()
+		}
+	}
+
+	var parentHash common.Hash
+	if len(block.BackLinkIDs) > 0 && block.Index > 0 {
+		parentHash = common.BytesToHash(block.BackLinkIDs[0])
+	}
+
+	return map[string]interface{}{
+		"number":           hexutil.Uint64(block.Index),
+		"hash":             common.BytesToHash(block.Hash),
+		"parentHash":       parentHash,
+		"nonce":            types.BlockNonce{},
+		"sha3Uncles":       types.EmptyUncleHash,
+		"logsBloom":        types.Bloom{},
+		"stateRoot":        common.BytesToHash(header.TrieRoot),
+		"miner":            nilAddress,
+		"difficulty":       (*hexutil.Big)(big.NewInt(0)),
+		"totalDifficulty":  (*hexutil.Big)(big.NewInt(0)),
+		"extraData":        hexutil.Bytes{},
+		"size":             hexutil.Uint64(len(block.Payload)),
+		"gasLimit":         hexutil.Uint64(getContext(0).GasLimit),
+		"gasUsed":          hexutil.Uint64(br.GasUsed),
+		"timestamp":        hexutil.Uint64(header.Timestamp / 1e9),
+		"transactions":     transactions,
+		"transactionsRoot": types.EmptyRootHash,
+		"receiptsRoot":     types.EmptyRootHash,
+		"uncles":           []common.Hash{},
+	}, nil
+}
+
+// GetTransactionByHash returns an Ethereum transaction, or nil if it is not
+// found
+func (api *ethAPI) GetTransactionByHash(hash common.Hash) (
+	map[string]interface{}, error) {
+	tx, err := api.findTransaction(hash)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+
+	return marshalTransaction(tx), nil
+}
+
+// GetTransactionReceipt returns the receipt of an Ethereum transaction, or
+// nil if it is not found
+func (api *ethAPI) GetTransactionReceipt(hash common.Hash) (
+	map[string]interface{}, error) {
+	rst, err := api.getReadOnlyStateTrie()
+	if err != nil {
+		return nil, err
+	}
+
+	receipt, err := getReceipt(rst, api.instanceID, hash)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+
+	block, err := api.getBlock(rpc.BlockNumber(receipt.BlockIndex))
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, xerrors.Errorf("missing block %d", receipt.BlockIndex)
+	}
+
+	logs := make([]*types.Log, len(receipt.Logs))
+	index, err := api.getLogIndex(rst, receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, l := range receipt.Logs {
+		logs[i] = ReceiptLog{
+			Log:        l,
+			TxHash:     receipt.TxHash,
+			BlockIndex: receipt.BlockIndex,
+			TxIndex:    receipt.TxIndex,
+			Index:      index + i,
+		}.toEthLog()
+		logs[i].BlockHash = common.BytesToHash(block.Hash)
+	}
+
+	var to, contractAddress *common.Address
+	if receipt.To != nil {
+		address := common.BytesToAddress(receipt.To)
+		to = &address
+	}
+	if receipt.ContractAddress != nil {
+		address := common.BytesToAddress(receipt.ContractAddress)
+		contractAddress = &address
+	}
+
+	return map[string]interface{}{
+		"transactionHash":   common.BytesToHash(receipt.TxHash),
+		"transactionIndex":  hexutil.Uint(receipt.TxIndex),
+		"blockHash":         common.BytesToHash(block.Hash),
+		"blockNumber":       hexutil.Uint64(receipt.BlockIndex),
+		"from":              common.BytesToAddress(receipt.From),
+		"to":                to,
+		"contractAddress":   contractAddress,
+		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
+		"cumulativeGasUsed": hexutil.Uint64(receipt.CumulativeGasUsed),
+		"status":            hexutil.Uint64(receipt.Status),
+		"logs":              logs,
+		"logsBloom":         types.BytesToBloom(types.LogsBloom(logs).Bytes()),
+	}, nil
+}
+
+// GetLogs returns the logs matching a filter
+func (api *ethAPI) GetLogs(filter FilterArgs) ([]*types.Log, error) {
+	request := &GetLogsRequest{
+		ByzCoinID:      api.byzcoinID,
+		BEvmInstanceID: api.instanceID[:],
+		FromBlock:      -1,
+		ToBlock:        -1,
+	}
+
+	if filter.BlockHash != nil {
+		scService, err := api.gateway.service.skipchainService()
+		if err != nil {
+			return nil, err
+		}
+
+		block := scService.GetDB().GetByID(filter.BlockHash[:])
+		if block == nil || !block.SkipChainID().Equal(api.byzcoinID) {
+			return nil, xerrors.New("unknown block hash")
+		}
+
+		request.FromBlock = block.Index
+		request.ToBlock = block.Index
+	} else {
+		if filter.FromBlock != nil && *filter.FromBlock >= 0 {
+			request.FromBlock = int(filter.FromBlock.Int64())
+		}
+		if filter.ToBlock != nil && *filter.ToBlock >= 0 {
+			request.ToBlock = int(filter.ToBlock.Int64())
+		}
+
+		if request.FromBlock < 0 {
+			latest, err := api.getLatestBlock()
+			if err != nil {
+				return nil, err
+			}
+
+			request.FromBlock = latest.Index
+		}
+	}
+
+	for _, address := range filter.Address {
+		request.Addresses = append(request.Addresses, address.Bytes())
+	}
+
+	for _, hashes := range filter.Topics {
+		var topicFilter TopicFilter
+		for _, hash := range hashes {
+			topicFilter.Hashes = append(topicFilter.Hashes, hash.Bytes())
+		}
+
+		request.Topics = append(request.Topics, topicFilter)
+	}
+
+	response, err := api.gateway.service.GetLogs(request)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]*types.Log, len(response.Logs))
+	blockHashes := make(map[int]common.Hash)
+	for i, l := range response.Logs {
+		blockHash, ok := blockHashes[l.BlockIndex]
+		if !ok {
+			block, err := api.getBlock(rpc.BlockNumber(l.BlockIndex))
+			if err != nil {
+				return nil, err
+			}
+			if block == nil {
+				return nil, xerrors.Errorf("missing block %d", l.BlockIndex)
+			}
+
+			blockHash = common.BytesToHash(block.Hash)
+			blockHashes[l.BlockIndex] = blockHash
+		}
+
+		logs[i] = l.toEthLog()
+		logs[i].BlockHash = blockHash
+	}
+
+	return logs, nil
+}
+
+// ---------------------------------------------------------------------------
+// Helper functions
+
+// getLogIndex returns the position in its block of the first log of a
+// receipt
+func (api *ethAPI) getLogIndex(rst byzcoin.ReadOnlyStateTrie,
+	receipt *Receipt) (int, error) {
+	br, err := getBlockReceipts(rst, api.instanceID, receipt.BlockIndex)
+	if err != nil {
+		return 0, err
+	}
+
+	index := 0
+	for _, txHash := range br.TxHashes[:receipt.TxIndex] {
+		prev, err := getReceipt(rst, api.instanceID,
+			common.BytesToHash(txHash))
+		if err != nil {
+			return 0, err
+		}
+		if prev == nil {
+			return 0, xerrors.Errorf("missing receipt of transaction %x",
+				txHash)
+		}
+
+		index += len(prev.Logs)
+	}
+
+	return index, nil
+}
+
+// getReadOnlyStateTrie returns the latest state of the ledger
+func (api *ethAPI) getReadOnlyStateTrie() (byzcoin.ReadOnlyStateTrie, error) {
+	bcService, err := api.gateway.service.byzcoinService()
+	if err != nil {
+		return nil, err
+	}
+
+	rst, err := bcService.GetReadOnlyStateTrie(api.byzcoinID)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve ReadOnlyStateTrie: %v",
+			err)
+	}
+
+	return rst, nil
+}
+
+// checkLatest returns an error if the block is not the latest one, as only
+// the latest state is available
+func (api *ethAPI) checkLatest(number rpc.BlockNumber) error {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return nil
+	}
+
+	latest, err := api.getLatestBlock()
+	if err != nil {
+		return err
+	}
+
+	if number.Int64() != int64(latest.Index) {
+		return xerrors.New("only the state of the latest block is available")
+	}
+
+	return nil
+}
+
+// getStateDb returns the EVM state database of the BEvm instance at the
+// given block, which must be the latest one
+func (api *ethAPI) getStateDb(number rpc.BlockNumber) (*state.StateDB,
+	error) {
+	err := api.checkLatest(number)
+	if err != nil {
+		return nil, err
+	}
+
+	rst, err := api.getReadOnlyStateTrie()
+	if err != nil {
+		return nil, err
+	}
+
+	stateDb, err := getEvmDbRst(rst, api.instanceID)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to obtain stateTrie-backed "+
+			"database for BEvm: %v", err)
+	}
+
+	return stateDb, nil
+}
+
+// getLatestBlock returns the latest block of the ledger
+func (api *ethAPI) getLatestBlock() (*skipchain.SkipBlock, error) {
+	scService, err := api.gateway.service.skipchainService()
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := scService.GetDB().GetLatestByID(api.byzcoinID)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve latest block: %v", err)
+	}
+
+	return latest, nil
+}
+
+// getBlock returns the block with the given number, or nil if it doesn't
+// exist yet
+func (api *ethAPI) getBlock(number rpc.BlockNumber) (*skipchain.SkipBlock,
+	error) {
+	latest, err := api.getLatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber ||
+		number.Int64() == int64(latest.Index) {
+		return latest, nil
+	}
+	if number.Int64() > int64(latest.Index) {
+		return nil, nil
+	}
+
+	scService, err := api.gateway.service.skipchainService()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := scService.GetSingleBlockByIndex(
+		&skipchain.GetSingleBlockByIndex{
+			Genesis: api.byzcoinID,
+			Index:   int(number.Int64()),
+		})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve block %d: %v",
+			number.Int64(), err)
+	}
+
+	return reply.SkipBlock, nil
+}
+
+// getBlockTransactions returns the Ethereum transactions executed by the
+// BEvm instance in a block, in order
+func (api *ethAPI) getBlockTransactions(block *skipchain.SkipBlock) (
+	[]*ethTx, error) {
+	var body byzcoin.DataBody
+	err := protobuf.Decode(block.Payload, &body)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode block body: %v", err)
+	}
+
+	signer := types.NewEIP155Signer(getChainConfig().ChainID)
+
+	var txs []*ethTx
+	for _, txResult := range body.TxResults {
+		if !txResult.Accepted {
+			continue
+		}
+
+		for _, instr := range txResult.ClientTransaction.Instructions {
+			if !instr.InstanceID.Equal(api.instanceID) ||
+				instr.Invoke == nil ||
+				instr.Invoke.ContractID != ContractBEvmID ||
+				instr.Invoke.Command != "transaction" {
+				continue
+			}
+
+			tx, err := decodeTransaction(instr.Invoke.Args)
+			if err != nil {
+				return nil, err
+			}
+
+			from, err := types.Sender(signer, tx)
+			if err != nil {
+				return nil, xerrors.Errorf("failed to retrieve EVM "+
+					"transaction sender: %v", err)
+			}
+
+			txs = append(txs, &ethTx{
+				tx:    tx,
+				from:  from,
+				block: block,
+				index: len(txs),
+			})
+		}
+	}
+
+	return txs, nil
+}
+
+// findTransaction looks for an Ethereum transaction using its receipt, or in
+// the last gatewaySearchDepth blocks. It returns nil if the transaction is
+// not found.
+func (api *ethAPI) findTransaction(hash common.Hash) (*ethTx, error) {
+	scService, err := api.gateway.service.skipchainService()
+	if err != nil {
+		return nil, err
+	}
+
+	rst, err := api.getReadOnlyStateTrie()
+	if err != nil {
+		return nil, err
+	}
+
+	// The receipt gives the block of the transaction
+	receipt, err := getReceipt(rst, api.instanceID, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if receipt != nil {
+		block, err := api.getBlock(rpc.BlockNumber(receipt.BlockIndex))
+		if err != nil || block == nil {
+			return nil, err
+		}
+
+		txs, err := api.getBlockTransactions(block)
+		if err != nil {
+			return nil, err
+		}
+
+		if receipt.TxIndex < len(txs) &&
+			txs[receipt.TxIndex].tx.Hash() == hash {
+			return txs[receipt.TxIndex], nil
+		}
+	}
+
+	// Transactions executed before the receipts were stored have to be
+	// looked for in the blocks
+	block, err := api.getLatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < gatewaySearchDepth && block != nil; i++ {
+		txs, err := api.getBlockTransactions(block)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range txs {
+			if tx.tx.Hash() == hash {
+				return tx, nil
+			}
+		}
+
+		if block.Index == 0 || len(block.BackLinkIDs) == 0 {
+			break
+		}
+
+		block = scService.GetDB().GetByID(block.BackLinkIDs[0])
+	}
+
+	return nil, nil
+}
+
+// marshalTransaction returns the JSON-RPC representation of a transaction
+func marshalTransaction(tx *ethTx) map[string]interface{} {
+	v, r, s := tx.tx.RawSignatureValues()
+
+	return map[string]interface{}{
+		"hash":             tx.tx.Hash(),
+		"nonce":            hexutil.Uint64(tx.tx.Nonce()),
+		"blockHash":        common.BytesToHash(tx.block.Hash),
+		"blockNumber":      hexutil.Uint64(tx.block.Index),
+		"transactionIndex": hexutil.Uint(tx.index),
+		"from":             tx.from,
+		"to":               tx.tx.To(),
+		"value":            (*hexutil.Big)(tx.tx.Value()),
+		"gas":              hexutil.Uint64(tx.tx.Gas()),
+		"gasPrice":         (*hexutil.Big)(tx.tx.GasPrice()),
+		"input":            hexutil.Bytes(tx.tx.Data()),
+		"v":                (*hexutil.Big)(v),
+		"r":                (*hexutil.Big)(r),
+		"s":                (*hexutil.Big)(s),
+	}
+}
@@ -0,0 +1,199 @@
+package bevm
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/darc"
+)
+
+func TestGateway(t *testing.T) {
+	// Create a new ledger and prepare for proper closing
+	bct := newBCTest(t)
+	defer bct.Close()
+
+	// Spawn a new BEvm instance
+	instanceID, err := NewBEvm(bct.cl, bct.signer, bct.gDarc)
+	require.NoError(t, err)
+
+	// Create a new BEvm client
+	bevmClient, err := NewClient(bct.cl, bct.signer, instanceID)
+	require.NoError(t, err)
+
+	// Initialize an account
+	a, err := NewEvmAccount(testPrivateKeys[0])
+	require.NoError(t, err)
+
+	// Credit the account
+	_, err = bevmClient.CreditAccount(big.NewInt(5*WeiPerEther), a.Address)
+	require.NoError(t, err)
+
+	// Start a gateway on the first node, signing with the genesis DARC
+	// signer
+	service := bct.servers[0].Service(ServiceName).(*Service)
+	server := httptest.NewServer(newGateway(service, bct.signer, ""))
+	defer server.Close()
+
+	// Unknown BEvm instances are not served
+	resp, err := http.Post(server.URL+"/"+hex.EncodeToString(bct.cl.ID)+
+		"/"+hex.EncodeToString(make([]byte, 32)), "application/json",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// CORS is disabled by default
+	require.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+
+	rpcClient, err := rpc.Dial(server.URL + "/" +
+		hex.EncodeToString(bct.cl.ID) + "/" + hex.EncodeToString(instanceID[:]))
+	require.NoError(t, err)
+	ec := ethclient.NewClient(rpcClient)
+	ctx := context.Background()
+
+	networkID, err := ec.NetworkID(ctx)
+	require.NoError(t, err)
+	require.Equal(t, getChainConfig().ChainID, networkID)
+
+	balance, err := ec.BalanceAt(ctx, a.Address, nil)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(5*WeiPerEther), balance)
+
+	// Deploy a Candy contract through the gateway
+	candyAbi := getContractData(t, "Candy", "abi")
+	candyBytecode := getContractData(t, "Candy", "bin")
+	candySupply := big.NewInt(1103)
+	candyContract, err := NewEvmContract(
+		"Candy", candyAbi, candyBytecode)
+	require.NoError(t, err)
+
+	packedArgs, err := candyContract.packConstructor(candySupply)
+	require.NoError(t, err)
+
+	nonce, err := ec.PendingNonceAt(ctx, a.Address)
+	require.NoError(t, err)
+
+	tx := types.NewContractCreation(nonce, big.NewInt(0), txParams.GasLimit,
+		big.NewInt(int64(txParams.GasPrice)),
+		append(candyContract.Bytecode, packedArgs...))
+	signedTx, err := types.SignTx(tx, types.HomesteadSigner{}, a.PrivateKey)
+	require.NoError(t, err)
+	require.NoError(t, ec.SendTransaction(ctx, signedTx))
+
+	receipt, err := ec.TransactionReceipt(ctx, signedTx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, signedTx.Hash(), receipt.TxHash)
+	candyAddress := crypto.CreateAddress(a.Address, nonce)
+	require.Equal(t, candyAddress, receipt.ContractAddress)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	require.True(t, receipt.GasUsed > 0)
+
+	// The Candy contract emits no event
+	logs, err := ec.FilterLogs(ctx, ethereum.FilterQuery{
+		Addresses: []common.Address{candyAddress},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(logs))
+
+	code, err := ec.CodeAt(ctx, candyAddress, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, code)
+
+	// Unknown transactions have no receipt
+	_, err = ec.TransactionReceipt(ctx, tx.Hash())
+	require.Equal(t, ethereum.NotFound, err)
+
+	// Call a view method through the gateway
+	candyInstance := EvmContractInstance{
+		Parent:  candyContract,
+		Address: candyAddress,
+	}
+	getCallData, err := candyInstance.packMethod("getRemainingCandies")
+	require.NoError(t, err)
+
+	result, err := ec.CallContract(ctx, ethereum.CallMsg{
+		From: a.Address,
+		To:   &candyAddress,
+		Data: getCallData,
+	}, nil)
+	require.NoError(t, err)
+
+	expectedResult, err := candyContract.Abi.Methods["getRemainingCandies"].
+		Outputs.Pack(candySupply)
+	require.NoError(t, err)
+	require.Equal(t, expectedResult, result)
+
+	// The gas estimation allows to execute the transaction
+	callData, err := candyInstance.packMethod("eatCandy", big.NewInt(10))
+	require.NoError(t, err)
+
+	gas, err := ec.EstimateGas(ctx, ethereum.CallMsg{
+		From: a.Address,
+		To:   &candyAddress,
+		Data: callData,
+	})
+	require.NoError(t, err)
+	require.True(t, gas > 21000)
+
+	tx = types.NewTransaction(nonce+1, candyAddress, big.NewInt(0), gas,
+		big.NewInt(int64(txParams.GasPrice)), callData)
+	signedTx, err = types.SignTx(tx, types.HomesteadSigner{}, a.PrivateKey)
+	require.NoError(t, err)
+	require.NoError(t, ec.SendTransaction(ctx, signedTx))
+
+	result, err = ec.CallContract(ctx, ethereum.CallMsg{
+		To:   &candyAddress,
+		Data: getCallData,
+	}, nil)
+	require.NoError(t, err)
+
+	expectedResult, err = candyContract.Abi.Methods["getRemainingCandies"].
+		Outputs.Pack(big.NewInt(1093))
+	require.NoError(t, err)
+	require.Equal(t, expectedResult, result)
+}
+
+func TestGateway_Start(t *testing.T) {
+	// Create a new ledger and prepare for proper closing
+	bct := newBCTest(t)
+	defer bct.Close()
+
+	si := bct.servers[0].ServerIdentity
+	service := bct.servers[0].Service(ServiceName).(*Service)
+
+	// The conode key cannot sign for the gateway
+	conodeSigner := darc.NewSignerEd25519(si.Public, si.GetPrivate())
+	err := StartGateway(si, "localhost:0", conodeSigner, "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "must not be the conode key")
+
+	// The request must be signed by the conode
+	_, err = service.StartGateway(&StartGatewayRequest{
+		Address:   "localhost:0",
+		Signer:    bct.signer,
+		Timestamp: time.Now().Unix(),
+		Signature: make([]byte, 64),
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "signature verification failed")
+
+	require.NoError(t, StartGateway(si, "localhost:0", bct.signer, ""))
+
+	// A conode runs a single gateway
+	err = StartGateway(si, "localhost:0", bct.signer, "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "already listening")
+}
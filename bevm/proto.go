@@ -1,7 +1,12 @@
 package bevm
 
+import (
+	"go.dedis.ch/cothority/v3/darc"
+)
+
 // PROTOSTART
 // package bevm;
+// import "darc.proto";
 //
 // option java_package = "ch.epfl.dedis.lib.proto";
 // option java_outer_classname = "BEvmProto";
@@ -20,3 +25,80 @@ type ViewCallRequest struct {
 type ViewCallResponse struct {
 	Result []byte
 }
+
+// Receipt is the outcome of an EVM transaction, stored in ByzCoin by the
+// BEvm contract.
+type Receipt struct {
+	TxHash            []byte
+	BlockIndex        int
+	TxIndex           int
+	From              []byte
+	To                []byte `protobuf:"opt"`
+	ContractAddress   []byte `protobuf:"opt"`
+	Status            uint64
+	GasUsed           uint64
+	CumulativeGasUsed uint64
+	Logs              []Log `protobuf:"opt"`
+}
+
+// Log is an event emitted by an EVM contract during a transaction.
+type Log struct {
+	Address []byte
+	Topics  [][]byte `protobuf:"opt"`
+	Data    []byte   `protobuf:"opt"`
+}
+
+// GetLogsRequest is a request to retrieve the logs of the EVM transactions
+// of a range of blocks. The logs must match one of the addresses, if any, and
+// the topics.
+type GetLogsRequest struct {
+	ByzCoinID      []byte
+	BEvmInstanceID []byte
+	FromBlock      int
+	// ToBlock is included in the range; a negative value means the latest
+	// block.
+	ToBlock   int
+	Addresses [][]byte      `protobuf:"opt"`
+	Topics    []TopicFilter `protobuf:"opt"`
+}
+
+// TopicFilter matches the topic at the same position of a log against a list
+// of hashes. An empty list matches any topic.
+type TopicFilter struct {
+	Hashes [][]byte `protobuf:"opt"`
+}
+
+// GetLogsResponse is the response to GetLogsRequest, containing the matching
+// logs in the order they were emitted.
+type GetLogsResponse struct {
+	Logs []ReceiptLog `protobuf:"opt"`
+}
+
+// ReceiptLog is a log with the position of its transaction. Index is the
+// position of the log among all the logs of the block.
+type ReceiptLog struct {
+	Log        Log
+	TxHash     []byte
+	BlockIndex int
+	TxIndex    int
+	Index      int
+}
+
+// StartGatewayRequest asks the conode to serve the Ethereum JSON-RPC gateway
+// on Address. The ByzCoin transactions of the gateway are signed by Signer,
+// which must be an Ed25519 signer distinct from the conode key. AllowOrigin
+// is the value of the Access-Control-Allow-Origin header of the responses;
+// if empty, the browsers are not allowed to use the gateway from other
+// origins. The request must come from the loopback interface, and be signed
+// with the conode key over the other fields.
+type StartGatewayRequest struct {
+	Address     string
+	Signer      darc.Signer
+	AllowOrigin string `protobuf:"opt"`
+	Timestamp   int64
+	Signature   []byte
+}
+
+// StartGatewayResponse is the response to StartGatewayRequest.
+type StartGatewayResponse struct {
+}
@@ -0,0 +1,317 @@
+package bevm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// ContractBEvmReceiptID identifies the ByzCoin contract that handles the
+// receipts of the EVM transactions. Like BEvmValue, it is only a byproduct of
+// BEvm transactions, and does not support Spawn or Invoke.
+var ContractBEvmReceiptID = "bevm_receipt"
+
+// maxLogsBlocks is the maximum number of blocks a log query can go through
+const maxLogsBlocks = 10000
+
+// maxLogs is the maximum number of logs returned by a log query
+const maxLogs = 10000
+
+// The receipt of each EVM transaction is stored in its own instance, derived
+// from the BEvm instance ID and the transaction hash. For each block, another
+// instance lists the transactions executed by the BEvm instance, so that the
+// logs of a range of blocks can be found without going through the blocks.
+
+// blockReceipts lists the EVM transactions of a block
+type blockReceipts struct {
+	TxHashes [][]byte
+	GasUsed  uint64
+}
+
+// receiptID returns the instance ID of the receipt of an EVM transaction
+func receiptID(bevmID byzcoin.InstanceID,
+	txHash common.Hash) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write(bevmID[:])
+	h.Write([]byte("receipt"))
+	h.Write(txHash[:])
+
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// blockReceiptsID returns the instance ID of the list of the EVM
+// transactions of a block
+func blockReceiptsID(bevmID byzcoin.InstanceID,
+	blockIndex int) byzcoin.InstanceID {
+	var index [8]byte
+	binary.LittleEndian.PutUint64(index[:], uint64(blockIndex))
+
+	h := sha256.New()
+	h.Write(bevmID[:])
+	h.Write([]byte("block-receipts"))
+	h.Write(index[:])
+
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// getInstanceValue returns the value of an instance, or nil if it does not
+// exist
+func getInstanceValue(rst byzcoin.ReadOnlyStateTrie,
+	instanceID byzcoin.InstanceID) ([]byte, error) {
+	proof, err := rst.GetProof(instanceID[:])
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get proof of instance: %v", err)
+	}
+
+	ok, err := proof.Exists(instanceID[:])
+	if err != nil {
+		return nil, xerrors.Errorf("failed to check proof of instance: %v",
+			err)
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	value, _, _, _, err := rst.GetValues(instanceID[:])
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get instance value: %v", err)
+	}
+
+	return value, nil
+}
+
+// getBlockReceipts returns the list of the EVM transactions of a block
+func getBlockReceipts(rst byzcoin.ReadOnlyStateTrie,
+	bevmID byzcoin.InstanceID, blockIndex int) (*blockReceipts, error) {
+	value, err := getInstanceValue(rst, blockReceiptsID(bevmID, blockIndex))
+	if err != nil {
+		return nil, err
+	}
+
+	var br blockReceipts
+	if value == nil {
+		return &br, nil
+	}
+
+	err = protobuf.Decode(value, &br)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode block receipts: %v", err)
+	}
+
+	return &br, nil
+}
+
+// getReceipt returns the receipt of an EVM transaction, or nil if it does
+// not exist
+func getReceipt(rst byzcoin.ReadOnlyStateTrie, bevmID byzcoin.InstanceID,
+	txHash common.Hash) (*Receipt, error) {
+	value, err := getInstanceValue(rst, receiptID(bevmID, txHash))
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	var receipt Receipt
+	err = protobuf.Decode(value, &receipt)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode receipt: %v", err)
+	}
+
+	return &receipt, nil
+}
+
+// storeReceipt returns the state changes storing the receipt of an EVM
+// transaction, executed in the block being created
+func storeReceipt(rst byzcoin.ReadOnlyStateTrie, bevmID byzcoin.InstanceID,
+	darcID darc.ID, tx *types.Transaction, from common.Address,
+	txReceipt *types.Receipt) ([]byzcoin.StateChange, error) {
+	// The latest block is the one before the block being created
+	blockIndex := rst.GetIndex() + 1
+
+	br, err := getBlockReceipts(rst, bevmID, blockIndex)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get block receipts: %v", err)
+	}
+
+	receipt := Receipt{
+		TxHash:            txReceipt.TxHash[:],
+		BlockIndex:        blockIndex,
+		TxIndex:           len(br.TxHashes),
+		From:              from[:],
+		Status:            txReceipt.Status,
+		GasUsed:           txReceipt.GasUsed,
+		CumulativeGasUsed: br.GasUsed + txReceipt.GasUsed,
+	}
+	if tx.To() != nil {
+		receipt.To = tx.To()[:]
+	} else {
+		receipt.ContractAddress = txReceipt.ContractAddress[:]
+	}
+
+	for _, logEntry := range txReceipt.Logs {
+		topics := make([][]byte, len(logEntry.Topics))
+		for i, topic := range logEntry.Topics {
+			topics[i] = topic[:]
+		}
+
+		receipt.Logs = append(receipt.Logs, Log{
+			Address: logEntry.Address[:],
+			Topics:  topics,
+			Data:    logEntry.Data,
+		})
+	}
+
+	receiptData, err := protobuf.Encode(&receipt)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to encode receipt: %v", err)
+	}
+
+	action := byzcoin.Update
+	if len(br.TxHashes) == 0 {
+		action = byzcoin.Create
+	}
+
+	br.TxHashes = append(br.TxHashes, receipt.TxHash)
+	br.GasUsed = receipt.CumulativeGasUsed
+
+	brData, err := protobuf.Encode(br)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to encode block receipts: %v", err)
+	}
+
+	return []byzcoin.StateChange{
+		byzcoin.NewStateChange(byzcoin.Create,
+			receiptID(bevmID, txReceipt.TxHash), ContractBEvmReceiptID,
+			receiptData, darcID),
+		byzcoin.NewStateChange(action,
+			blockReceiptsID(bevmID, blockIndex), ContractBEvmReceiptID,
+			brData, darcID),
+	}, nil
+}
+
+// match returns whether a log matches the addresses and topics of the
+// request
+func (req *GetLogsRequest) match(l Log) bool {
+	if len(req.Addresses) > 0 {
+		found := false
+		for _, address := range req.Addresses {
+			if bytes.Equal(address, l.Address) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(req.Topics) > len(l.Topics) {
+		return false
+	}
+
+	for i, filter := range req.Topics {
+		if len(filter.Hashes) == 0 {
+			continue
+		}
+
+		found := false
+		for _, hash := range filter.Hashes {
+			if bytes.Equal(hash, l.Topics[i]) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getLogs returns the logs of the EVM transactions matching the request
+func getLogs(rst byzcoin.ReadOnlyStateTrie, bevmID byzcoin.InstanceID,
+	req *GetLogsRequest) ([]ReceiptLog, error) {
+	toBlock := req.ToBlock
+	if toBlock < 0 || toBlock > rst.GetIndex() {
+		toBlock = rst.GetIndex()
+	}
+
+	fromBlock := req.FromBlock
+	if fromBlock < 0 {
+		fromBlock = 0
+	}
+
+	if toBlock-fromBlock >= maxLogsBlocks {
+		return nil, xerrors.Errorf("cannot query the logs of more than %d "+
+			"blocks", maxLogsBlocks)
+	}
+
+	var logs []ReceiptLog
+	for blockIndex := fromBlock; blockIndex <= toBlock; blockIndex++ {
+		br, err := getBlockReceipts(rst, bevmID, blockIndex)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to get receipts of "+
+				"block %d: %v", blockIndex, err)
+		}
+
+		index := 0
+		for txIndex, txHash := range br.TxHashes {
+			receipt, err := getReceipt(rst, bevmID,
+				common.BytesToHash(txHash))
+			if err != nil {
+				return nil, xerrors.Errorf("failed to get receipt: %v", err)
+			}
+			if receipt == nil {
+				return nil, xerrors.Errorf("missing receipt of "+
+					"transaction %x", txHash)
+			}
+
+			for _, l := range receipt.Logs {
+				if req.match(l) {
+					logs = append(logs, ReceiptLog{
+						Log:        l,
+						TxHash:     txHash,
+						BlockIndex: blockIndex,
+						TxIndex:    txIndex,
+						Index:      index,
+					})
+				}
+				index++
+			}
+
+			if len(logs) > maxLogs {
+				return nil, xerrors.Errorf("more than %d logs match the "+
+					"query", maxLogs)
+			}
+		}
+	}
+
+	return logs, nil
+}
+
+// toEthLog converts a log to its go-ethereum representation
+func (l ReceiptLog) toEthLog() *types.Log {
+	topics := make([]common.Hash, len(l.Log.Topics))
+	for i, topic := range l.Log.Topics {
+		topics[i] = common.BytesToHash(topic)
+	}
+
+	return &types.Log{
+		Address:     common.BytesToAddress(l.Log.Address),
+		Topics:      topics,
+		Data:        l.Log.Data,
+		BlockNumber: uint64(l.BlockIndex),
+		TxHash:      common.BytesToHash(l.TxHash),
+		TxIndex:     uint(l.TxIndex),
+		Index:       uint(l.Index),
+	}
+}
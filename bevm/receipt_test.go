@@ -0,0 +1,174 @@
+package bevm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Receipts(t *testing.T) {
+	// Create a new ledger and prepare for proper closing
+	bct := newBCTest(t)
+	defer bct.Close()
+
+	// Spawn a new BEvm instance
+	instanceID, err := NewBEvm(bct.cl, bct.signer, bct.gDarc)
+	require.NoError(t, err)
+
+	// Create a new BEvm client
+	bevmClient, err := NewClient(bct.cl, bct.signer, instanceID)
+	require.NoError(t, err)
+
+	// Initialize two accounts
+	a, err := NewEvmAccount(testPrivateKeys[0])
+	require.NoError(t, err)
+	b, err := NewEvmAccount(testPrivateKeys[1])
+	require.NoError(t, err)
+
+	// Credit the accounts
+	_, err = bevmClient.CreditAccount(big.NewInt(5*WeiPerEther), a.Address)
+	require.NoError(t, err)
+	_, err = bevmClient.CreditAccount(big.NewInt(5*WeiPerEther), b.Address)
+	require.NoError(t, err)
+
+	// Deploy an ERC20 Token contract, which emits a Transfer event
+	erc20Contract, err := NewEvmContract(
+		"ERC20Token",
+		getContractData(t, "ERC20Token", "abi"),
+		getContractData(t, "ERC20Token", "bin"))
+	require.NoError(t, err)
+	bcTx, erc20Instance, err := bevmClient.Deploy(
+		txParams.GasLimit, txParams.GasPrice, 0, a, erc20Contract)
+	require.NoError(t, err)
+
+	deployHash, err := TxHash(bcTx)
+	require.NoError(t, err)
+
+	receipt, err := bevmClient.GetReceipt(deployHash)
+	require.NoError(t, err)
+	require.Equal(t, deployHash[:], receipt.TxHash)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	require.Equal(t, a.Address[:], receipt.From)
+	require.Nil(t, receipt.To)
+	require.Equal(t, erc20Instance.Address[:], receipt.ContractAddress)
+	require.True(t, receipt.GasUsed > 0)
+	require.Equal(t, 1, len(receipt.Logs))
+
+	transferID := erc20Contract.Abi.Events["Transfer"].Id()
+	require.Equal(t, erc20Instance.Address[:], receipt.Logs[0].Address)
+	require.Equal(t, transferID[:], receipt.Logs[0].Topics[0])
+	deployBlock := receipt.BlockIndex
+
+	// Transfer 100 tokens from A to B
+	bcTx, err = bevmClient.Transaction(
+		txParams.GasLimit, txParams.GasPrice, 0, a,
+		erc20Instance, "transfer", b.Address, big.NewInt(100))
+	require.NoError(t, err)
+
+	transferHash, err := TxHash(bcTx)
+	require.NoError(t, err)
+
+	receipt, err = bevmClient.GetReceipt(transferHash)
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	require.Equal(t, erc20Instance.Address[:], receipt.To)
+	require.Nil(t, receipt.ContractAddress)
+	require.Equal(t, 1, len(receipt.Logs))
+	require.Equal(t, common.BytesToHash(b.Address[:]).Bytes(),
+		receipt.Logs[0].Topics[2])
+	transferBlock := receipt.BlockIndex
+	require.True(t, transferBlock > deployBlock)
+
+	// Try to transfer 101 tokens from B to A; this is reverted by the EVM
+	bcTx, err = bevmClient.Transaction(
+		txParams.GasLimit, txParams.GasPrice, 0, b,
+		erc20Instance, "transfer", a.Address, big.NewInt(101))
+	require.NoError(t, err)
+
+	failedHash, err := TxHash(bcTx)
+	require.NoError(t, err)
+
+	receipt, err = bevmClient.GetReceipt(failedHash)
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusFailed, receipt.Status)
+	require.Equal(t, 0, len(receipt.Logs))
+
+	// Unknown transactions have no receipt
+	_, err = bevmClient.GetReceipt(common.Hash{})
+	require.Error(t, err)
+
+	// All the logs
+	logs, err := bevmClient.GetLogs(0, -1, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(logs))
+	require.Equal(t, deployHash[:], logs[0].TxHash)
+	require.Equal(t, deployBlock, logs[0].BlockIndex)
+	require.Equal(t, transferHash[:], logs[1].TxHash)
+	require.Equal(t, transferBlock, logs[1].BlockIndex)
+
+	// Filter by block range
+	logs, err = bevmClient.GetLogs(deployBlock+1, -1, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	require.Equal(t, transferHash[:], logs[0].TxHash)
+
+	logs, err = bevmClient.GetLogs(0, deployBlock, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	require.Equal(t, deployHash[:], logs[0].TxHash)
+
+	// Filter by address
+	logs, err = bevmClient.GetLogs(0, -1,
+		[]common.Address{erc20Instance.Address}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(logs))
+
+	logs, err = bevmClient.GetLogs(0, -1, []common.Address{a.Address}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(logs))
+
+	// Filter by topic: transfers to B
+	logs, err = bevmClient.GetLogs(0, -1, nil, [][]common.Hash{
+		{transferID},
+		{},
+		{common.BytesToHash(b.Address[:])},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	require.Equal(t, transferHash[:], logs[0].TxHash)
+
+	// Unknown event
+	logs, err = bevmClient.GetLogs(0, -1, nil, [][]common.Hash{
+		{crypto.Keccak256Hash([]byte("Unknown()"))},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(logs))
+}
+
+func Test_LogMatch(t *testing.T) {
+	l := Log{
+		Address: []byte{1},
+		Topics:  [][]byte{{2}, {3}},
+	}
+
+	require.True(t, (&GetLogsRequest{}).match(l))
+	require.True(t, (&GetLogsRequest{
+		Addresses: [][]byte{{0}, {1}},
+	}).match(l))
+	require.False(t, (&GetLogsRequest{
+		Addresses: [][]byte{{0}},
+	}).match(l))
+	require.True(t, (&GetLogsRequest{
+		Topics: []TopicFilter{{}, {Hashes: [][]byte{{4}, {3}}}},
+	}).match(l))
+	require.False(t, (&GetLogsRequest{
+		Topics: []TopicFilter{{Hashes: [][]byte{{3}}}},
+	}).match(l))
+	require.False(t, (&GetLogsRequest{
+		Topics: []TopicFilter{{}, {}, {}},
+	}).match(l))
+}
@@ -2,12 +2,17 @@ package bevm
 
 import (
 	"encoding/hex"
+	"net"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
 	"golang.org/x/xerrors"
 )
 
@@ -17,6 +22,11 @@ const ServiceName = "BEvm"
 // Service is the service that performs BEvm operations.
 type Service struct {
 	*onet.ServiceProcessor
+
+	// Protects gatewayListener
+	gatewayLock sync.Mutex
+	// gatewayListener is the listener of the JSON-RPC gateway, if started
+	gatewayListener net.Listener
 }
 
 func init() {
@@ -30,6 +40,10 @@ func init() {
 		contractBEvmFromBytes))
 	log.ErrFatal(byzcoin.RegisterGlobalContract(ContractBEvmValueID,
 		nil))
+	log.ErrFatal(byzcoin.RegisterGlobalContract(ContractBEvmReceiptID,
+		nil))
+
+	network.RegisterMessages(&gatewayConfig{})
 
 	// Initialize service
 	_, err := onet.RegisterNewService(ServiceName, newBEvmService)
@@ -43,15 +57,9 @@ func (service *Service) ViewCall(req *ViewCallRequest) (*ViewCallResponse,
 	accountAddress := common.BytesToAddress(req.AccountAddress)
 	contractAddress := common.BytesToAddress(req.ContractAddress)
 
-	serv := service.Context.Service(byzcoin.ServiceName)
-	if serv == nil {
-		return nil, xerrors.New("cannot find \"byzcoin\" service")
-	}
-
-	bcService, ok := serv.(*byzcoin.Service)
-	if !ok {
-		return nil,
-			xerrors.New("internal error: service is not a byzcoin.Service")
+	bcService, err := service.byzcoinService()
+	if err != nil {
+		return nil, err
 	}
 
 	rst, err := bcService.GetReadOnlyStateTrie(req.ByzCoinID)
@@ -84,6 +92,101 @@ func (service *Service) ViewCall(req *ViewCallRequest) (*ViewCallResponse,
 	return &ViewCallResponse{Result: result}, nil
 }
 
+// GetLogs returns the logs of the EVM transactions of a range of blocks,
+// matching the addresses and topics of the request.
+func (service *Service) GetLogs(req *GetLogsRequest) (*GetLogsResponse,
+	error) {
+	bcService, err := service.byzcoinService()
+	if err != nil {
+		return nil, err
+	}
+
+	rst, err := bcService.GetReadOnlyStateTrie(req.ByzCoinID)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve ReadOnlyStateTrie: %v",
+			err)
+	}
+
+	logs, err := getLogs(rst, byzcoin.NewInstanceID(req.BEvmInstanceID), req)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get EVM logs: %v", err)
+	}
+
+	return &GetLogsResponse{Logs: logs}, nil
+}
+
+// Close stops the JSON-RPC gateway, if started. It is called when the conode
+// shuts down.
+func (service *Service) Close() error {
+	service.gatewayLock.Lock()
+	defer service.gatewayLock.Unlock()
+
+	if service.gatewayListener == nil {
+		return nil
+	}
+
+	err := service.gatewayListener.Close()
+	service.gatewayListener = nil
+	if err != nil {
+		return xerrors.Errorf("failed to close JSON-RPC gateway: %v", err)
+	}
+
+	return nil
+}
+
+// ProcessClientRequest implements onet.Service. We override the version
+// we normally get from embedding onet.ServiceProcessor in order to
+// hook it and get a look at the http.Request.
+func (service *Service) ProcessClientRequest(req *http.Request, path string,
+	buf []byte) ([]byte, *onet.StreamingTunnel, error) {
+	if path == "StartGatewayRequest" {
+		h, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("invalid address: %v", err)
+		}
+
+		// The request holds the private key of the gateway signer
+		if !net.ParseIP(h).IsLoopback() {
+			return nil, nil, xerrors.New("the gateway can only be " +
+				"started from loopback")
+		}
+	}
+
+	return service.ServiceProcessor.ProcessClientRequest(req, path, buf)
+}
+
+// byzcoinService returns the ByzCoin service of the conode
+func (service *Service) byzcoinService() (*byzcoin.Service, error) {
+	serv := service.Context.Service(byzcoin.ServiceName)
+	if serv == nil {
+		return nil, xerrors.New("cannot find \"byzcoin\" service")
+	}
+
+	bcService, ok := serv.(*byzcoin.Service)
+	if !ok {
+		return nil,
+			xerrors.New("internal error: service is not a byzcoin.Service")
+	}
+
+	return bcService, nil
+}
+
+// skipchainService returns the Skipchain service of the conode
+func (service *Service) skipchainService() (*skipchain.Service, error) {
+	serv := service.Context.Service(skipchain.ServiceName)
+	if serv == nil {
+		return nil, xerrors.New("cannot find \"skipchain\" service")
+	}
+
+	scService, ok := serv.(*skipchain.Service)
+	if !ok {
+		return nil,
+			xerrors.New("internal error: service is not a skipchain.Service")
+	}
+
+	return scService, nil
+}
+
 // newBEvmService creates a new service for BEvm functionality
 func newBEvmService(context *onet.Context) (onet.Service, error) {
 	service := &Service{
@@ -92,11 +195,32 @@ func newBEvmService(context *onet.Context) (onet.Service, error) {
 
 	err := service.RegisterHandlers(
 		service.ViewCall,
+		service.GetLogs,
+		service.StartGateway,
 	)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to register service "+
 			"handlers: %v", err)
 	}
 
+	// Start the gateway if its operator configured it
+	cfg, err := service.Load(gatewayStorageKey)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load gateway "+
+			"configuration: %v", err)
+	}
+	if cfg != nil {
+		gwCfg, ok := cfg.(*gatewayConfig)
+		if !ok {
+			return nil, xerrors.New("invalid gateway configuration")
+		}
+
+		err = service.startGateway(gwCfg)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to start JSON-RPC "+
+				"gateway: %v", err)
+		}
+	}
+
 	return service, nil
 }
@@ -37,6 +37,9 @@ type Client struct {
 	noncesSI map[uint64]*network.ServerIdentity
 	// Used for SendProtobufParallel. If it is nil, default values will be used.
 	options *onet.ParallelOptions
+	// FeePayer is set as the fee payer of the transactions created by
+	// CreateTransaction. It must be set for chains that charge fees.
+	FeePayer *InstanceID
 }
 
 // NewClient instantiates a new ByzCoin client.
@@ -133,7 +136,9 @@ func (c *Client) GetAllByzCoinIDs(si *network.ServerIdentity) (*GetAllByzCoinIDs
 	return reply, nil
 }
 
-// CreateTransaction creates a transaction from a list of instructions.
+// CreateTransaction creates a transaction from a list of instructions. The
+// fee payer of the transaction is the one of the client. As it is signed, it
+// must not be changed after signing the transaction.
 func (c *Client) CreateTransaction(instrs ...Instruction) (ClientTransaction, error) {
 	if c.Latest == nil {
 		if _, err := c.GetChainConfig(); err != nil {
@@ -147,6 +152,10 @@ func (c *Client) CreateTransaction(instrs ...Instruction) (ClientTransaction, er
 	}
 
 	tx := NewClientTransaction(h.Version, instrs...)
+	if c.FeePayer != nil {
+		payer := *c.FeePayer
+		tx.FeePayer = &payer
+	}
 	return tx, nil
 }
 
@@ -256,6 +265,50 @@ func (c *Client) GetProofFrom(key []byte, from *skipchain.SkipBlock) (*GetProofR
 	return rep, cothority.ErrorOrNil(err, "request failed")
 }
 
+// GetProofAt returns a proof for the key as it was stored right after the
+// block with the given index. The proof starts at the genesis block and is
+// verified, so it can be passed on to another server. As the nodes have to
+// replay the chain to build the past state, this call can take a long time.
+func (c *Client) GetProofAt(key []byte, blockIndex int) (*GetProofResponse, error) {
+	if c.Genesis == nil {
+		if err := c.fetchGenesis(); err != nil {
+			return nil, xerrors.Errorf("fetching genesis block: %v", err)
+		}
+	}
+
+	decoder := func(buf []byte, msg interface{}) error {
+		err := protobuf.Decode(buf, msg)
+		if err != nil {
+			return xerrors.Errorf("decoding: %+v", err)
+		}
+		gpr, ok := msg.(*GetProofResponse)
+		if !ok {
+			return xerrors.New("couldn't cast msg")
+		}
+		if err := gpr.Proof.VerifyFromBlock(c.Genesis); err != nil {
+			return xerrors.Errorf("proof verification: %+v", err)
+		}
+		if gpr.Proof.Latest.Index != blockIndex {
+			return xerrors.Errorf("got proof for block %d instead of %d",
+				gpr.Proof.Latest.Index, blockIndex)
+		}
+		return nil
+	}
+
+	req := &GetProofAt{
+		Version:    CurrentVersion,
+		Key:        key,
+		ID:         c.Genesis.Hash,
+		BlockIndex: blockIndex,
+	}
+	reply := &GetProofResponse{}
+	_, err := c.SendProtobufParallelWithDecoder(c.Roster.List, req, reply, c.options, decoder)
+	if err != nil {
+		return nil, xerrors.Errorf("sending: %+v", err)
+	}
+	return reply, nil
+}
+
 // GetProofAfter returns a proof for the key stored in the skipchain
 // starting from the latest known block by this client. The proof will always
 // be newer than the barrier or it will return an error.
@@ -575,6 +628,46 @@ func (c *Client) StreamTransactions(handler func(StreamingResponse, error)) erro
 	}
 }
 
+// StreamFilteredTransactions works like StreamTransactions, but the service
+// only sends the state changes of the new blocks that match the filter. Blocks
+// without a matching state change are not sent. Every response is verified
+// before it is given to the handler.
+func (c *Client) StreamFilteredTransactions(filter StreamingFilter,
+	handler func(FilteredStreamingResponse, error)) error {
+	req := FilteredStreamingRequest{
+		ID:     c.ID,
+		Filter: filter,
+	}
+	n := int(rand.Int31n(int32(len(c.Roster.List))))
+	if c.options != nil {
+		if c.options.DontShuffle {
+			n = c.options.StartNode
+		}
+	}
+
+	conn, err := c.Stream(c.Roster.List[n], &req)
+	if err != nil {
+		handler(FilteredStreamingResponse{}, err)
+		return xerrors.Errorf("stream error: %v", err)
+	}
+	for {
+		resp := FilteredStreamingResponse{}
+		if err := conn.ReadMessage(&resp); err != nil {
+			handler(FilteredStreamingResponse{}, err)
+			return nil
+		}
+
+		if err := resp.Verify(c.ID); err != nil {
+			err = xerrors.Errorf("got an invalid response from %v: %v",
+				c.Roster.List[n], err)
+			log.Warnf("%+v", err)
+			handler(FilteredStreamingResponse{}, err)
+			continue
+		}
+		handler(resp, nil)
+	}
+}
+
 func (c *Client) signerCounterDecoder(buf []byte, data interface{}) error {
 	err := protobuf.Decode(buf, data)
 	if err != nil {
@@ -626,6 +719,51 @@ func (c *Client) GetSignerCounters(ids ...string) (*GetSignerCountersResponse, e
 	return &reply, cothority.ErrorOrNil(err, "request failed")
 }
 
+// GetTxStatus returns the status of the transaction with the given hash, as
+// returned by ClientTransaction.Instructions.Hash(). As a pending transaction
+// is only known to the node it has been sent to and to the leader, all nodes
+// are asked until one of them has seen the transaction in a block.
+func (c *Client) GetTxStatus(txHash []byte) (*GetTxStatusResponse, error) {
+	req := &GetTxStatus{
+		SkipchainID: c.ID,
+		TxHash:      txHash,
+	}
+	var best *GetTxStatusResponse
+	var lastErr error
+	for _, si := range c.Roster.List {
+		reply := &GetTxStatusResponse{}
+		if err := c.SendProtobuf(si, req, reply); err != nil {
+			lastErr = err
+			continue
+		}
+		if reply.Status.isFinal() {
+			return reply, nil
+		}
+		if best == nil || best.Status == TxStatusUnknown ||
+			reply.Status == TxStatusPending {
+			best = reply
+		}
+	}
+	if best == nil {
+		return nil, xerrors.Errorf("request failed: %v", lastErr)
+	}
+	return best, nil
+}
+
+// SimulateTransaction executes the transaction on one of the nodes without
+// storing anything, and returns the state changes it would produce. The
+// transaction must be signed with the next signer counters, but these are not
+// consumed, so the same transaction can be sent with AddTransaction afterwards.
+func (c *Client) SimulateTransaction(tx ClientTransaction) (*SimulateTransactionResponse, error) {
+	req := &SimulateTransaction{
+		SkipchainID: c.ID,
+		Transaction: tx,
+	}
+	reply := &SimulateTransactionResponse{}
+	_, err := c.SendProtobufParallel(c.Roster.List, req, reply, c.options)
+	return reply, cothority.ErrorOrNil(err, "request failed")
+}
+
 // DownloadState is used by a new node to ask to download the global state.
 // The first call to DownloadState needs to have start = 0, so that the
 // service creates a snapshot of the current state which it will serve over
@@ -641,7 +779,31 @@ func (c *Client) GetSignerCounters(ids ...string) (*GetSignerCountersResponse, e
 // The first StateChange with start == 0 holds the metadata of the
 // trie which can be `protobuf.Decode`d into a struct{map[string][]byte}.
 func (c *Client) DownloadState(byzcoinID skipchain.SkipBlockID, nonce uint64, length int) (reply *DownloadStateResponse, err error) {
-	if length <= 0 {
+	return c.downloadState(&DownloadState{
+		ByzCoinID: byzcoinID,
+		Nonce:     nonce,
+		Length:    length,
+	})
+}
+
+// DownloadSnapshot works like DownloadState, but returns the latest snapshot
+// of the state instead of the current state. Snapshots are only created if
+// the SnapshotInterval of the ChainConfig is set. As all nodes create their
+// snapshots for the same blocks, the snapshot can be verified against the
+// trie root of its block, which is signed by the nodes.
+//
+// If no snapshot is available, the download returns no key/value pairs.
+func (c *Client) DownloadSnapshot(byzcoinID skipchain.SkipBlockID, nonce uint64, length int) (reply *DownloadStateResponse, err error) {
+	return c.downloadState(&DownloadState{
+		ByzCoinID: byzcoinID,
+		Nonce:     nonce,
+		Length:    length,
+		Snapshot:  true,
+	})
+}
+
+func (c *Client) downloadState(msg *DownloadState) (reply *DownloadStateResponse, err error) {
+	if msg.Length <= 0 {
 		return nil, xerrors.New("invalid parameter")
 	}
 
@@ -653,12 +815,7 @@ func (c *Client) DownloadState(byzcoinID skipchain.SkipBlockID, nonce uint64, le
 		indexStart = 1 + int(math.Ceil(math.Pow(float64(l), 1./3.)))
 	}
 
-	msg := &DownloadState{
-		ByzCoinID: byzcoinID,
-		Nonce:     nonce,
-		Length:    length,
-	}
-	si, ok := c.noncesSI[nonce]
+	si, ok := c.noncesSI[msg.Nonce]
 	if ok {
 		err = cothority.ErrorOrNil(c.SendProtobuf(si, msg, reply), "request failed")
 	} else {
@@ -777,6 +934,25 @@ func DebugRemove(si *network.ServerIdentity, byzcoinID skipchain.SkipBlockID) er
 	return cothority.ErrorOrNil(err, "request failed")
 }
 
+// ListPendingTxs returns the transactions the conode received for the given
+// byzcoin-instance that are not yet included in a block.
+func ListPendingTxs(si *network.ServerIdentity, byzcoinID skipchain.SkipBlockID) ([]ClientTransaction, error) {
+	sig, err := schnorr.Sign(cothority.Suite, si.GetPrivate(), byzcoinID)
+	if err != nil {
+		return nil, xerrors.Errorf("sign error: %v", err)
+	}
+	request := &ListPendingTxs{
+		ByzCoinID: byzcoinID,
+		Signature: sig,
+	}
+	reply := &ListPendingTxsResponse{}
+	err = onet.NewClient(cothority.Suite, ServiceName).SendProtobuf(si, request, reply)
+	if err != nil {
+		return nil, xerrors.Errorf("request failed: %v", err)
+	}
+	return reply.Transactions, nil
+}
+
 // DefaultGenesisMsg creates the message that is used to for creating the
 // genesis Darc and block. It will contain rules for spawning and evolving the
 // darc contract.
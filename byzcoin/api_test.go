@@ -274,6 +274,79 @@ func TestClient_Streaming(t *testing.T) {
 	require.NoError(t, c1.Close())
 }
 
+func TestClient_FilteredStreaming(t *testing.T) {
+	l := onet.NewTCPTest(cothority.Suite)
+	servers, roster, _ := l.GenTree(3, true)
+	registerDummy(t, servers)
+	defer l.CloseAll()
+
+	signer := darc.NewSignerEd25519(nil, nil)
+	msg, err := DefaultGenesisMsg(CurrentVersion, roster, []string{"spawn:dummy"}, signer.Identity())
+	msg.BlockInterval = time.Second
+	require.NoError(t, err)
+	d := msg.GenesisDarc
+
+	c, csr, err := NewLedger(msg, false)
+	require.NoError(t, err)
+
+	n := 2
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		for i := 0; i < n; i++ {
+			tx, err := createOneClientTxWithCounter(d.GetBaseID(), "dummy", []byte{5, 6, 7, 8}, signer, uint64(i)+1)
+			require.NoError(t, err)
+			_, err = c.AddTransaction(tx)
+			require.NoError(t, err)
+		}
+	}()
+
+	c1 := NewClientKeep(csr.Skipblock.Hash, *roster)
+	var xMut sync.Mutex
+	var x int
+	done := make(chan bool)
+	cb := func(resp FilteredStreamingResponse, err error) {
+		xMut.Lock()
+		defer xMut.Unlock()
+		if err != nil {
+			require.True(t, x >= n)
+			return
+		}
+
+		require.Empty(t, resp.Block.Payload)
+		// The contract and darc of the state changes are verified, too.
+		tampered := resp
+		tampered.StateChanges = append([]StateChange{}, resp.StateChanges...)
+		tampered.StateChanges[0].ContractID = "other"
+		require.Error(t, tampered.Verify(csr.Skipblock.SkipChainID()))
+		tampered.StateChanges[0] = resp.StateChanges[0]
+		tampered.StateChanges[0].DarcID = darc.ID(make([]byte, 32))
+		require.Error(t, tampered.Verify(csr.Skipblock.SkipChainID()))
+
+		for _, sc := range resp.StateChanges {
+			require.Equal(t, "dummy", sc.ContractID)
+			require.Equal(t, []byte{5, 6, 7, 8}, sc.Value)
+			x++
+			if x == n {
+				// We got n state changes, so we close the done channel.
+				close(done)
+			}
+		}
+	}
+
+	go func() {
+		err = c1.StreamFilteredTransactions(StreamingFilter{
+			ContractIDs: []string{"dummy"},
+		}, cb)
+		require.NoError(t, err)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Duration(10) * msg.BlockInterval):
+		require.Fail(t, "should have got n state changes")
+	}
+	require.NoError(t, c1.Close())
+}
+
 func TestClient_NoPhantomSkipchain(t *testing.T) {
 	l := onet.NewTCPTest(cothority.Suite)
 	servers, roster, _ := l.GenTree(3, true)
@@ -0,0 +1,324 @@
+package clicontracts
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/urfave/cli"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/bcadmin/lib"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// TokenSpawn is used to spawn a new token.
+func TokenSpawn(c *cli.Context) error {
+	name := c.String("name")
+	if name == "" {
+		return xerrors.New("--name flag is required")
+	}
+	symbol := c.String("symbol")
+	if symbol == "" {
+		return xerrors.New("--symbol flag is required")
+	}
+	args := byzcoin.Arguments{
+		{Name: "name", Value: []byte(name)},
+		{Name: "symbol", Value: []byte(symbol)},
+		{Name: "decimals", Value: tokenUint(c.Uint64("decimals"))},
+	}
+	if c.IsSet("supplyCap") {
+		args = append(args, byzcoin.Argument{Name: "supplyCap",
+			Value: tokenUint(c.Uint64("supplyCap"))})
+	}
+	if c.IsSet("initialSupply") {
+		args = append(args, byzcoin.Argument{Name: "initialSupply",
+			Value: tokenUint(c.Uint64("initialSupply"))})
+	}
+
+	cfg, cl, err := tokenLoadConfig(c)
+	if err != nil {
+		return err
+	}
+	d, err := tokenDarc(c, cfg, cl)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := tokenSend(c, cfg, cl, byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(d.GetBaseID()),
+		Spawn: &byzcoin.Spawn{
+			ContractID: contracts.ContractTokenID,
+			Args:       args,
+		},
+	})
+	if err != nil || ctx == nil {
+		return err
+	}
+
+	tokenID := ctx.Instructions[0].DeriveID("")
+	log.Infof("Spawned a new token. Its instance id is:\n%x", tokenID.Slice())
+	if c.IsSet("initialSupply") {
+		log.Infof("The initial supply is in the account:\n%x",
+			contracts.TokenAccountID(tokenID, d.GetBaseID()).Slice())
+	}
+
+	return lib.WaitPropagation(c, cl)
+}
+
+// TokenInvokeMint creates new tokens in an account.
+func TokenInvokeMint(c *cli.Context) error {
+	return tokenInvoke(c, contracts.ContractTokenID, "mint", "destination")
+}
+
+// TokenGet checks the proof and prints the metadata of a token.
+func TokenGet(c *cli.Context) error {
+	buf, err := tokenGetValue(c, contracts.ContractTokenID)
+	if err != nil {
+		return err
+	}
+	var token contracts.TokenData
+	if err := protobuf.Decode(buf, &token); err != nil {
+		return xerrors.Errorf("couldn't decode token: %v", err)
+	}
+
+	log.Infof("Name: %s\nSymbol: %s\nDecimals: %d\nSupply cap: %d\n"+
+		"Total supply: %d", token.Name, token.Symbol, token.Decimals,
+		token.SupplyCap, token.TotalSupply)
+	return nil
+}
+
+// TokenAccountSpawn is used to spawn a new account for a token.
+func TokenAccountSpawn(c *cli.Context) error {
+	tokenID, err := tokenIDFlag(c, "token")
+	if err != nil {
+		return err
+	}
+
+	cfg, cl, err := tokenLoadConfig(c)
+	if err != nil {
+		return err
+	}
+	d, err := tokenDarc(c, cfg, cl)
+	if err != nil {
+		return err
+	}
+	owner := d.GetBaseID()
+	args := byzcoin.Arguments{{Name: "tokenID", Value: tokenID.Slice()}}
+	if ostr := c.String("owner"); ostr != "" {
+		owner, err = lib.StringToDarcID(ostr)
+		if err != nil {
+			return xerrors.Errorf("failed to decode the owner: %v", err)
+		}
+		args = append(args, byzcoin.Argument{Name: "darcID", Value: owner})
+	}
+
+	ctx, err := tokenSend(c, cfg, cl, byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(d.GetBaseID()),
+		Spawn: &byzcoin.Spawn{
+			ContractID: contracts.ContractTokenAccountID,
+			Args:       args,
+		},
+	})
+	if err != nil || ctx == nil {
+		return err
+	}
+
+	log.Infof("Spawned a new token account. Its instance id is:\n%x",
+		contracts.TokenAccountID(tokenID, owner).Slice())
+
+	return lib.WaitPropagation(c, cl)
+}
+
+// TokenAccountInvokeTransfer sends tokens to another account.
+func TokenAccountInvokeTransfer(c *cli.Context) error {
+	return tokenInvoke(c, contracts.ContractTokenAccountID, "transfer",
+		"destination")
+}
+
+// TokenAccountInvokeApprove allows another account to spend tokens.
+func TokenAccountInvokeApprove(c *cli.Context) error {
+	return tokenInvoke(c, contracts.ContractTokenAccountID, "approve",
+		"spender")
+}
+
+// TokenAccountInvokeTransferFrom sends tokens of another account, using the
+// allowance of the given account.
+func TokenAccountInvokeTransferFrom(c *cli.Context) error {
+	return tokenInvoke(c, contracts.ContractTokenAccountID, "transferFrom",
+		"from", "destination")
+}
+
+// TokenAccountGet checks the proof and prints the balance and the allowances
+// of an account.
+func TokenAccountGet(c *cli.Context) error {
+	buf, err := tokenGetValue(c, contracts.ContractTokenAccountID)
+	if err != nil {
+		return err
+	}
+	var account contracts.TokenAccountData
+	if err := protobuf.Decode(buf, &account); err != nil {
+		return xerrors.Errorf("couldn't decode account: %v", err)
+	}
+
+	log.Infof("Token: %x\nBalance: %d", account.TokenID.Slice(),
+		account.Balance)
+	for _, a := range account.Allowances {
+		log.Infof("Allowance of %x: %d", a.Spender.Slice(), a.Amount)
+	}
+	return nil
+}
+
+// tokenInvoke sends the command to the instance given by --instid, with the
+// "amount" argument and the instance IDs given by the flags in idArgs.
+func tokenInvoke(c *cli.Context, contractID string, command string,
+	idArgs ...string) error {
+	instID, err := tokenIDFlag(c, "instid")
+	if err != nil {
+		return err
+	}
+	if !c.IsSet("amount") {
+		return xerrors.New("--amount flag is required")
+	}
+	args := byzcoin.Arguments{
+		{Name: "amount", Value: tokenUint(c.Uint64("amount"))},
+	}
+	for _, name := range idArgs {
+		id, err := tokenIDFlag(c, name)
+		if err != nil {
+			return err
+		}
+		args = append(args, byzcoin.Argument{Name: name, Value: id.Slice()})
+	}
+
+	cfg, cl, err := tokenLoadConfig(c)
+	if err != nil {
+		return err
+	}
+	ctx, err := tokenSend(c, cfg, cl, byzcoin.Instruction{
+		InstanceID: instID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: contractID,
+			Command:    command,
+			Args:       args,
+		},
+	})
+	if err != nil || ctx == nil {
+		return err
+	}
+
+	log.Infof("Invoked %s on %x", command, instID.Slice())
+	return lib.WaitPropagation(c, cl)
+}
+
+// tokenSend signs the instruction with the key given in --sign and sends it.
+// If --export is set, the transaction is written to stdout instead, and the
+// returned transaction is nil.
+func tokenSend(c *cli.Context, cfg lib.Config, cl *byzcoin.Client,
+	instr byzcoin.Instruction) (*byzcoin.ClientTransaction, error) {
+	var signer *darc.Signer
+	var err error
+	sstr := c.String("sign")
+	if sstr == "" {
+		signer, err = lib.LoadKey(cfg.AdminIdentity)
+	} else {
+		signer, err = lib.LoadKeyFromString(sstr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	counters, err := cl.GetSignerCounters(signer.Identity().String())
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get signer counters: %v", err)
+	}
+	instr.SignerCounter = []uint64{counters.Counters[0] + 1}
+
+	ctx, err := cl.CreateTransaction(instr)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.FillSignersAndSignWith(*signer)
+	if err != nil {
+		return nil, err
+	}
+
+	if lib.FindRecursivefBool("export", c) {
+		return nil, lib.ExportTransaction(ctx)
+	}
+
+	_, err = cl.AddTransactionAndWait(ctx, 10)
+	if err != nil {
+		return nil, err
+	}
+	return &ctx, nil
+}
+
+// tokenLoadConfig loads the ByzCoin config given in --bc.
+func tokenLoadConfig(c *cli.Context) (lib.Config, *byzcoin.Client, error) {
+	bcArg := c.String("bc")
+	if bcArg == "" {
+		return lib.Config{}, nil, xerrors.New("--bc flag is required")
+	}
+	return lib.LoadConfig(bcArg)
+}
+
+// tokenDarc returns the darc given in --darc, or the admin darc.
+func tokenDarc(c *cli.Context, cfg lib.Config, cl *byzcoin.Client) (*darc.Darc, error) {
+	dstr := c.String("darc")
+	if dstr == "" {
+		dstr = cfg.AdminDarc.GetIdentityString()
+	}
+	return lib.GetDarcByString(cl, dstr)
+}
+
+// tokenGetValue returns the value of the instance given in --instid, after
+// verifying its proof and its contract.
+func tokenGetValue(c *cli.Context, contractID string) ([]byte, error) {
+	instID, err := tokenIDFlag(c, "instid")
+	if err != nil {
+		return nil, err
+	}
+	_, cl, err := tokenLoadConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := cl.GetProofFromLatest(instID.Slice())
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't get proof: %v", err)
+	}
+	if !pr.Proof.InclusionProof.Match(instID.Slice()) {
+		return nil, xerrors.New("proof does not match")
+	}
+	_, buf, cid, _, err := pr.Proof.KeyValue()
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't get value out of proof: %v", err)
+	}
+	if cid != contractID {
+		return nil, xerrors.Errorf("instance is a %s, not a %s", cid, contractID)
+	}
+	return buf, nil
+}
+
+// tokenIDFlag decodes the instance ID given in the flag, which is required.
+func tokenIDFlag(c *cli.Context, name string) (byzcoin.InstanceID, error) {
+	str := c.String(name)
+	if str == "" {
+		return byzcoin.InstanceID{}, xerrors.Errorf("--%s flag is required", name)
+	}
+	buf, err := hex.DecodeString(str)
+	if err != nil || len(buf) != len(byzcoin.InstanceID{}) {
+		return byzcoin.InstanceID{}, xerrors.Errorf("failed to decode the %s string", name)
+	}
+	return byzcoin.NewInstanceID(buf), nil
+}
+
+func tokenUint(v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, v)
+	return buf
+}
@@ -0,0 +1,52 @@
+# This method should be called from the byzcoin/bcadmin/test.sh script
+
+testContractToken() {
+    run testTokenScenario
+}
+
+testTokenScenario() {
+    # In this test we spawn a token with an initial supply, send tokens to a
+    # second account directly and through an allowance, and mint up to the
+    # supply cap.
+    runCoBG 1 2 3
+    runGrepSed "export BC=" "" runBA create --roster public.toml --interval .5s
+    eval $SED
+    [ -z "$BC" ] && exit 1
+
+    testOK runBA darc add -out_id ./darc_id.txt -out_key ./darc_key.txt -unrestricted
+    ID=`cat ./darc_id.txt`
+    KEY=`cat ./darc_key.txt`
+    for rule in spawn:token spawn:tokenAccount invoke:token.mint \
+        invoke:tokenAccount.transfer invoke:tokenAccount.approve; do
+        testOK runBA darc rule -rule "$rule" --identity "$KEY" --darc "$ID" --sign "$KEY"
+    done
+    testOK runBA darc add -out_id ./darc_id2.txt -out_key ./darc_key2.txt -unrestricted
+    ID2=`cat ./darc_id2.txt`
+    KEY2=`cat ./darc_key2.txt`
+    testOK runBA darc rule -rule "invoke:tokenAccount.transferFrom" --identity "$KEY2" --darc "$ID2" --sign "$KEY2"
+
+    OUTRES=`runBA0 contract token spawn --name "Loyalty points" --symbol LP --supplyCap 150 --initialSupply 100 --darc "$ID" --sign "$KEY"`
+    matchOK "$OUTRES" "^Spawned a new token. Its instance id is:
+[0-9a-f]{64}
+The initial supply is in the account:
+[0-9a-f]{64}$"
+    TOKEN=$( echo "$OUTRES" | sed -n 2p )
+    ACCOUNT=$( echo "$OUTRES" | sed -n 4p )
+    testGrep "Total supply: 100" runBA0 contract token get --instid $TOKEN
+
+    OUTRES=`runBA0 contract tokenAccount spawn --token $TOKEN --owner "$ID2" --darc "$ID" --sign "$KEY"`
+    ACCOUNT2=$( echo "$OUTRES" | sed -n 2p )
+    matchOK "$ACCOUNT2" ^[0-9a-f]{64}$
+
+    testOK runBA contract tokenAccount invoke transfer --instid $ACCOUNT --amount 10 --destination $ACCOUNT2 --sign "$KEY"
+    testFail runBA contract tokenAccount invoke transferFrom --instid $ACCOUNT2 --amount 15 --from $ACCOUNT --destination $ACCOUNT2 --sign "$KEY2"
+    testOK runBA contract tokenAccount invoke approve --instid $ACCOUNT --amount 20 --spender $ACCOUNT2 --sign "$KEY"
+    testOK runBA contract tokenAccount invoke transferFrom --instid $ACCOUNT2 --amount 15 --from $ACCOUNT --destination $ACCOUNT2 --sign "$KEY2"
+    testGrep "Balance: 75" runBA0 contract tokenAccount get --instid $ACCOUNT
+    testGrep "Allowance of $ACCOUNT2: 5" runBA0 contract tokenAccount get --instid $ACCOUNT
+    testGrep "Balance: 25" runBA0 contract tokenAccount get --instid $ACCOUNT2
+
+    testOK runBA contract token invoke mint --instid $TOKEN --amount 50 --destination $ACCOUNT2 --sign "$KEY"
+    testFail runBA contract token invoke mint --instid $TOKEN --amount 1 --destination $ACCOUNT2 --sign "$KEY"
+    testGrep "Total supply: 150" runBA0 contract token get --instid $TOKEN
+}
@@ -7,6 +7,8 @@ import (
 	"flag"
 	"fmt"
 	"go.dedis.ch/cothority/v3/byzcoin/trie"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +17,7 @@ import (
 
 	"go.dedis.ch/kyber/v3/pairing"
 
+	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/urfave/cli"
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/byzcoin"
@@ -555,6 +558,70 @@ func dbCheck(c *cli.Context) error {
 	return nil
 }
 
+// dbMigrate copies the state tries of a conode-db to a leveldb database, and
+// configures the conode to use the leveldb storage backend.
+func dbMigrate(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return xerrors.New("please give the following arguments: conode.db")
+	}
+	boltDB, err := bbolt.Open(c.Args().First(), 0600, nil)
+	if err != nil {
+		return xerrors.Errorf("couldn't open db: %+v", err)
+	}
+	defer boltDB.Close()
+
+	var buckets [][]byte
+	err = boltDB.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+			if trieBucket.Match(name) {
+				buckets = append(buckets, append([]byte{}, name...))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return xerrors.Errorf("couldn't search tries: %+v", err)
+	}
+	if len(buckets) == 0 {
+		return xerrors.New("didn't find any state trie in the db")
+	}
+
+	path, err := filepath.Abs(byzcoin.LevelDBPath(c.Args().First()))
+	if err != nil {
+		return xerrors.Errorf("couldn't get path of leveldb: %+v", err)
+	}
+	log.Info("Opening leveldb", path)
+	ldb, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return xerrors.Errorf("couldn't open leveldb: %+v", err)
+	}
+	defer ldb.Close()
+
+	for _, bucket := range buckets {
+		scID, err := hex.DecodeString(string(bucket[len("ByzCoin_"):]))
+		if err != nil {
+			return xerrors.Errorf("couldn't decode skipchain-ID: %+v", err)
+		}
+		log.Infof("Copying state trie of %x", scID)
+		dst := trie.NewLevelDB(ldb, byzcoin.LevelDBPrefix(scID))
+		if err := trie.Copy(dst, trie.NewDiskDB(boltDB, bucket)); err != nil {
+			return xerrors.Errorf("couldn't copy trie: %+v", err)
+		}
+	}
+	err = byzcoin.SaveStorageConfig(boltDB, byzcoin.StorageConfig{
+		Backend: byzcoin.StorageLevelDB,
+		Path:    path,
+	})
+	if err != nil {
+		return xerrors.Errorf("couldn't save storage config: %+v", err)
+	}
+	log.Infof("Copied %d state tries - the conode now uses %s",
+		len(buckets), path)
+	return nil
+}
+
+var trieBucket = regexp.MustCompile("^ByzCoin_[0-9a-f]{64}$")
+
 // fetchBlocks is used by all db-related bcadmin commands.
 type fetchBlocks struct {
 	cl               *skipchain.Client
@@ -32,6 +32,34 @@ var cmds = cli.Commands{
 				Name:  "blockSize",
 				Usage: "adjust the maximum block size",
 			},
+			cli.IntFlag{
+				Name:  "snapshotInterval",
+				Usage: "keep a snapshot of the state every N blocks, at most 1000, 0 to disable",
+			},
+			cli.StringFlag{
+				Name:  "feeCoin",
+				Usage: "the coin type (hex) the fees are paid in (default: the byzCoin type)",
+			},
+			cli.Uint64Flag{
+				Name:  "txFee",
+				Usage: "the fee charged for every transaction",
+			},
+			cli.Uint64Flag{
+				Name:  "instrFee",
+				Usage: "the fee charged for every instruction",
+			},
+			cli.StringSliceFlag{
+				Name:  "contractFee",
+				Usage: "contractID=fee overrides the instruction fee for a contract, multiple use allowed",
+			},
+			cli.StringSliceFlag{
+				Name:  "feePayout",
+				Usage: "publicKey=coinID gives the fees of the blocks created by the node to the coin instance, multiple use allowed",
+			},
+			cli.BoolFlag{
+				Name:  "noFees",
+				Usage: "remove the fee schedule",
+			},
 		},
 	},
 
@@ -65,7 +93,7 @@ var cmds = cli.Commands{
                                       --instid, i <instance ID>
                                       [--sign <pub key>]     
                              }
-   CONTRACT   {value,deferred,config}`,
+   CONTRACT   {value,token,tokenAccount,deferred,config}`,
 		Subcommands: cli.Commands{
 			{
 				Name:  "value",
@@ -168,6 +196,246 @@ var cmds = cli.Commands{
 					},
 				},
 			},
+			{
+				Name:  "token",
+				Usage: "Manipulate a token contract",
+				Subcommands: cli.Commands{
+					{
+						Name:   "spawn",
+						Usage:  "spawn a token, with an optional initial supply given to the account of the DARC",
+						Action: clicontracts.TokenSpawn,
+						Flags: []cli.Flag{
+							cli.StringFlag{
+								Name:   "bc",
+								EnvVar: "BC",
+								Usage:  "the ByzCoin config to use (required)",
+							},
+							cli.StringFlag{
+								Name:  "name",
+								Usage: "the name of the token (required)",
+							},
+							cli.StringFlag{
+								Name:  "symbol",
+								Usage: "the symbol of the token (required)",
+							},
+							cli.Uint64Flag{
+								Name:  "decimals",
+								Usage: "the number of decimals used to display amounts",
+							},
+							cli.Uint64Flag{
+								Name:  "supplyCap",
+								Usage: "the maximum supply of the token (default is no limit)",
+							},
+							cli.Uint64Flag{
+								Name:  "initialSupply",
+								Usage: "the number of tokens given to the account of the DARC",
+							},
+							cli.StringFlag{
+								Name:  "darc",
+								Usage: "DARC with the right to spawn a token, which also controls the minting (default is the admin DARC)",
+							},
+							cli.StringFlag{
+								Name:  "sign",
+								Usage: "public key of the signing entity (default is the admin public key)",
+							},
+						},
+					},
+					{
+						Name:  "invoke",
+						Usage: "invoke a token contract",
+						Subcommands: cli.Commands{
+							{
+								Name:   "mint",
+								Usage:  "create new tokens in an account",
+								Action: clicontracts.TokenInvokeMint,
+								Flags: []cli.Flag{
+									cli.StringFlag{
+										Name:   "bc",
+										EnvVar: "BC",
+										Usage:  "the ByzCoin config to use (required)",
+									},
+									cli.StringFlag{
+										Name:  "instid, i",
+										Usage: "the instance ID of the token (required)",
+									},
+									cli.Uint64Flag{
+										Name:  "amount",
+										Usage: "the amount of tokens, in the smallest unit (required)",
+									},
+									cli.StringFlag{
+										Name:  "destination",
+										Usage: "the instance ID of the account receiving the tokens (required)",
+									},
+									cli.StringFlag{
+										Name:  "sign",
+										Usage: "public key of the signing entity (default is the admin public key)",
+									},
+								},
+							},
+						},
+					},
+					{
+						Name:   "get",
+						Usage:  "if the proof matches, get the metadata of the given token instance ID",
+						Action: clicontracts.TokenGet,
+						Flags: []cli.Flag{
+							cli.StringFlag{
+								Name:   "bc",
+								EnvVar: "BC",
+								Usage:  "the ByzCoin config to use (required)",
+							},
+							cli.StringFlag{
+								Name:  "instid, i",
+								Usage: "the instance id (required)",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:  "tokenAccount",
+				Usage: "Manipulate a token account contract",
+				Subcommands: cli.Commands{
+					{
+						Name:   "spawn",
+						Usage:  "spawn an account for a token",
+						Action: clicontracts.TokenAccountSpawn,
+						Flags: []cli.Flag{
+							cli.StringFlag{
+								Name:   "bc",
+								EnvVar: "BC",
+								Usage:  "the ByzCoin config to use (required)",
+							},
+							cli.StringFlag{
+								Name:  "token",
+								Usage: "the instance ID of the token (required)",
+							},
+							cli.StringFlag{
+								Name:  "owner",
+								Usage: "DARC owning the account (default is the DARC given in --darc)",
+							},
+							cli.StringFlag{
+								Name:  "darc",
+								Usage: "DARC with the right to spawn a token account (default is the admin DARC)",
+							},
+							cli.StringFlag{
+								Name:  "sign",
+								Usage: "public key of the signing entity (default is the admin public key)",
+							},
+						},
+					},
+					{
+						Name:  "invoke",
+						Usage: "invoke a token account contract",
+						Subcommands: cli.Commands{
+							{
+								Name:   "transfer",
+								Usage:  "send tokens to another account",
+								Action: clicontracts.TokenAccountInvokeTransfer,
+								Flags: []cli.Flag{
+									cli.StringFlag{
+										Name:   "bc",
+										EnvVar: "BC",
+										Usage:  "the ByzCoin config to use (required)",
+									},
+									cli.StringFlag{
+										Name:  "instid, i",
+										Usage: "the instance ID of the account (required)",
+									},
+									cli.Uint64Flag{
+										Name:  "amount",
+										Usage: "the amount of tokens, in the smallest unit (required)",
+									},
+									cli.StringFlag{
+										Name:  "destination",
+										Usage: "the instance ID of the account receiving the tokens (required)",
+									},
+									cli.StringFlag{
+										Name:  "sign",
+										Usage: "public key of the signing entity (default is the admin public key)",
+									},
+								},
+							},
+							{
+								Name:   "approve",
+								Usage:  "allow another account to spend tokens of this account",
+								Action: clicontracts.TokenAccountInvokeApprove,
+								Flags: []cli.Flag{
+									cli.StringFlag{
+										Name:   "bc",
+										EnvVar: "BC",
+										Usage:  "the ByzCoin config to use (required)",
+									},
+									cli.StringFlag{
+										Name:  "instid, i",
+										Usage: "the instance ID of the account (required)",
+									},
+									cli.Uint64Flag{
+										Name:  "amount",
+										Usage: "the amount of tokens, in the smallest unit (required)",
+									},
+									cli.StringFlag{
+										Name:  "spender",
+										Usage: "the instance ID of the account allowed to spend the tokens (required)",
+									},
+									cli.StringFlag{
+										Name:  "sign",
+										Usage: "public key of the signing entity (default is the admin public key)",
+									},
+								},
+							},
+							{
+								Name:   "transferFrom",
+								Usage:  "send tokens of another account, using the allowance of this account",
+								Action: clicontracts.TokenAccountInvokeTransferFrom,
+								Flags: []cli.Flag{
+									cli.StringFlag{
+										Name:   "bc",
+										EnvVar: "BC",
+										Usage:  "the ByzCoin config to use (required)",
+									},
+									cli.StringFlag{
+										Name:  "instid, i",
+										Usage: "the instance ID of the account of the spender (required)",
+									},
+									cli.Uint64Flag{
+										Name:  "amount",
+										Usage: "the amount of tokens, in the smallest unit (required)",
+									},
+									cli.StringFlag{
+										Name:  "from",
+										Usage: "the instance ID of the account the tokens are taken from (required)",
+									},
+									cli.StringFlag{
+										Name:  "destination",
+										Usage: "the instance ID of the account receiving the tokens (required)",
+									},
+									cli.StringFlag{
+										Name:  "sign",
+										Usage: "public key of the signing entity (default is the admin public key)",
+									},
+								},
+							},
+						},
+					},
+					{
+						Name:   "get",
+						Usage:  "if the proof matches, get the balance and allowances of the given account instance ID",
+						Action: clicontracts.TokenAccountGet,
+						Flags: []cli.Flag{
+							cli.StringFlag{
+								Name:   "bc",
+								EnvVar: "BC",
+								Usage:  "the ByzCoin config to use (required)",
+							},
+							cli.StringFlag{
+								Name:  "instid, i",
+								Usage: "the instance id (required)",
+							},
+						},
+					},
+				},
+			},
 			{
 				Name:  "deferred",
 				Usage: "Manipulate a deferred contract",
@@ -565,7 +833,7 @@ var cmds = cli.Commands{
 					},
 					cli.UintFlag{
 						Name:  "minimum, M",
-						Usage: "if this flag is set, the rule is computed to be \"M out of N\" identities. Otherwise it uses ANDs",
+						Usage: "if this flag is set, the rule is a threshold expression \"[id1, ..., idN]/M\" requiring M out of N identities. Otherwise it uses ANDs",
 					},
 				},
 			},
@@ -597,7 +865,7 @@ var cmds = cli.Commands{
 					},
 					cli.UintFlag{
 						Name:  "minimum, M",
-						Usage: "if this flag is set, the rule is computed to be \"M out of N\" identities. Otherwise it uses ANDs",
+						Usage: "if this flag is set, the rule is a threshold expression \"[id1, ..., idN]/M\" requiring M out of N identities. Otherwise it uses ANDs",
 					},
 					cli.BoolFlag{
 						Name:  "replace",
@@ -705,6 +973,12 @@ var cmds = cli.Commands{
 				ArgsUsage: "conode.db [bcID] [blocks]",
 				Action:    dbRemove,
 			},
+			{
+				Name:      "migrate",
+				Usage:     "Copy the state tries to a leveldb database for the leveldb storage backend",
+				ArgsUsage: "conode.db",
+				Action:    dbMigrate,
+			},
 			{
 				Name: "check",
 				Usage: "Check that the chain is in a correct state with" +
@@ -845,6 +1119,52 @@ var cmds = cli.Commands{
 						Name:  "hex",
 						Usage: "if set, the data of the instance is hex encoded",
 					},
+					cli.IntFlag{
+						Name:  "at",
+						Usage: "show the instance as it was at this block index",
+					},
+				},
+			},
+		},
+	},
+
+	{
+		Name:  "tx",
+		Usage: "inspect transactions",
+		Subcommands: cli.Commands{
+			{
+				Name:      "status",
+				Usage:     "shows whether a transaction is pending, accepted, refused or dropped",
+				ArgsUsage: "tx-hash",
+				Action:    txStatus,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:   "bc",
+						EnvVar: "BC",
+						Usage:  "the ByzCoin config to use (required)",
+					},
+				},
+			},
+			{
+				Name:      "pending",
+				Usage:     "lists the transactions a node received that are not yet in a block",
+				ArgsUsage: "private.toml byzcoin-id",
+				Action:    txPending,
+			},
+			{
+				Name:   "simulate",
+				Usage:  "executes a transaction exported with --export without storing it",
+				Action: txSimulate,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:   "bc",
+						EnvVar: "BC",
+						Usage:  "the ByzCoin config to use (required)",
+					},
+					cli.StringFlag{
+						Name:  "sign",
+						Usage: "public key of the signing entity (default is the admin public key)",
+					},
 				},
 			},
 		},
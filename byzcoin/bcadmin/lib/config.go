@@ -23,6 +23,10 @@ import (
 // ConfigPath points to where the files will be stored by default.
 var ConfigPath = "."
 
+// FeePayer is the coin instance paying the fees of the transactions created
+// by the clients of LoadConfig. It is nil if the chain charges no fees.
+var FeePayer *byzcoin.InstanceID
+
 // This var is used to check if an identity is empty. It helps providing some
 // insights to users in special cases, for example when using "bcadmin link"
 // that ends up using an empty identity if none is provided.
@@ -169,6 +173,7 @@ func LoadConfig(file string) (cfg Config, cl *byzcoin.Client, err error) {
 		return
 	}
 	cl = byzcoin.NewClient(cfg.ByzCoinID, cfg.Roster)
+	cl.FeePayer = FeePayer
 	return
 }
 
@@ -30,6 +30,7 @@ import (
 	_ "go.dedis.ch/cothority/v3/eventlog"
 	_ "go.dedis.ch/cothority/v3/personhood"
 	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3/util/encoding"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/app"
 	"go.dedis.ch/onet/v3/cfgpath"
@@ -75,10 +76,23 @@ func init() {
 			EnvVar: "BC_WAIT",
 			Usage:  "wait for transaction available in all nodes",
 		},
+		cli.StringFlag{
+			Name:   "feePayer",
+			EnvVar: "BC_FEE_PAYER",
+			Usage:  "the coin instance (hex) paying the fees of the transactions",
+		},
 	}
 	cliApp.Before = func(c *cli.Context) error {
 		log.SetDebugVisible(c.Int("debug"))
 		lib.ConfigPath = c.String("config")
+		if payer := c.String("feePayer"); payer != "" {
+			buf, err := hex.DecodeString(payer)
+			if err != nil || len(buf) != len(byzcoin.InstanceID{}) {
+				return xerrors.Errorf("couldn't parse fee payer %s", payer)
+			}
+			id := byzcoin.NewInstanceID(buf)
+			lib.FeePayer = &id
+		}
 		return nil
 	}
 }
@@ -574,6 +588,14 @@ func config(c *cli.Context) error {
 		}
 		chainConfig.MaxBlockSize = blockSize
 	}
+	if c.IsSet("snapshotInterval") {
+		chainConfig.SnapshotInterval = c.Int("snapshotInterval")
+	}
+	if c.Bool("noFees") {
+		chainConfig.FeeSchedule = nil
+	} else if err := updateFeeSchedule(c, &chainConfig); err != nil {
+		return err
+	}
 
 	err = updateConfig(cl, signer, chainConfig)
 	if err != nil {
@@ -585,6 +607,74 @@ func config(c *cli.Context) error {
 	return lib.WaitPropagation(c, cl)
 }
 
+// updateFeeSchedule applies the fee flags to the fee schedule of the chain
+// config. If no fee schedule exists yet, a new one is created that charges the
+// fees in contracts.CoinName.
+func updateFeeSchedule(c *cli.Context, chainConfig *byzcoin.ChainConfig) error {
+	flags := []string{"feeCoin", "txFee", "instrFee", "contractFee", "feePayout"}
+	set := false
+	for _, f := range flags {
+		set = set || c.IsSet(f)
+	}
+	if !set {
+		return nil
+	}
+
+	fs := chainConfig.FeeSchedule
+	if fs == nil {
+		fs = &byzcoin.FeeSchedule{CoinName: contracts.CoinName}
+	}
+	if coin := c.String("feeCoin"); coin != "" {
+		buf, err := hex.DecodeString(coin)
+		if err != nil || len(buf) != len(byzcoin.InstanceID{}) {
+			return xerrors.Errorf("couldn't parse fee coin %s", coin)
+		}
+		fs.CoinName = byzcoin.NewInstanceID(buf)
+	}
+	if c.IsSet("txFee") {
+		fs.TransactionFee = c.Uint64("txFee")
+	}
+	if c.IsSet("instrFee") {
+		fs.InstructionFee = c.Uint64("instrFee")
+	}
+	if cfs := c.StringSlice("contractFee"); len(cfs) > 0 {
+		fs.ContractFees = nil
+		for _, cf := range cfs {
+			parts := strings.SplitN(cf, "=", 2)
+			if len(parts) != 2 {
+				return xerrors.Errorf("contract fee must be contractID=fee, got %s", cf)
+			}
+			fee, err := strconv.ParseUint(parts[1], 10, 64)
+			if err != nil {
+				return xerrors.Errorf("couldn't parse fee of %s: %v", cf, err)
+			}
+			fs.ContractFees = append(fs.ContractFees,
+				byzcoin.ContractFee{ContractID: parts[0], Fee: fee})
+		}
+	}
+	if fps := c.StringSlice("feePayout"); len(fps) > 0 {
+		fs.Payouts = nil
+		for _, fp := range fps {
+			parts := strings.SplitN(fp, "=", 2)
+			if len(parts) != 2 {
+				return xerrors.Errorf("fee payout must be publicKey=coinID, got %s", fp)
+			}
+			pub, err := encoding.StringHexToPoint(cothority.Suite, parts[0])
+			if err != nil {
+				return xerrors.Errorf("couldn't parse public key of %s: %v", fp, err)
+			}
+			account, err := hex.DecodeString(parts[1])
+			if err != nil || len(account) != len(byzcoin.InstanceID{}) {
+				return xerrors.Errorf("couldn't parse coin ID of %s", fp)
+			}
+			fs.Payouts = append(fs.Payouts, byzcoin.FeePayout{Public: pub,
+				Account: byzcoin.NewInstanceID(account)})
+		}
+	}
+	chainConfig.FeeSchedule = fs
+	return nil
+}
+
 func mint(c *cli.Context) error {
 	if c.NArg() < 4 {
 		return xerrors.New("please give the following arguments: " +
@@ -1282,6 +1372,148 @@ func debugRemove(c *cli.Context) error {
 	return nil
 }
 
+func txStatus(c *cli.Context) error {
+	bcArg := c.String("bc")
+	if bcArg == "" {
+		return xerrors.New("--bc flag is required")
+	}
+	if c.NArg() < 1 {
+		return xerrors.New("please give the hash of the transaction")
+	}
+	txHash, err := hex.DecodeString(c.Args().First())
+	if err != nil {
+		return xerrors.Errorf("decoding tx-hash: %v", err)
+	}
+
+	_, cl, err := lib.LoadConfig(bcArg)
+	if err != nil {
+		return err
+	}
+	resp, err := cl.GetTxStatus(txHash)
+	if err != nil {
+		return xerrors.Errorf("couldn't get status: %v", err)
+	}
+
+	fmt.Fprintf(c.App.Writer, "Status: %s\n", resp.Status)
+	if resp.BlockIndex >= 0 {
+		fmt.Fprintf(c.App.Writer, "Block index: %d\n", resp.BlockIndex)
+	}
+	if resp.Error != "" {
+		fmt.Fprintf(c.App.Writer, "Error: %s\n", resp.Error)
+	}
+	return nil
+}
+
+func txPending(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return xerrors.New("please give the following arguments: private.toml byzcoin-id")
+	}
+
+	ccfg, err := app.LoadCothority(c.Args().First())
+	if err != nil {
+		return err
+	}
+	si, err := ccfg.GetServerIdentity()
+	if err != nil {
+		return err
+	}
+	bcidBuf, err := hex.DecodeString(c.Args().Get(1))
+	if err != nil {
+		return xerrors.Errorf("decoding byzcoin-id: %v", err)
+	}
+	txs, err := byzcoin.ListPendingTxs(si, skipchain.SkipBlockID(bcidBuf))
+	if err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		fmt.Fprintf(c.App.Writer, "%x: %d instruction(s)\n",
+			tx.Instructions.Hash(), len(tx.Instructions))
+	}
+	return nil
+}
+
+func txSimulate(c *cli.Context) error {
+	bcArg := c.String("bc")
+	if bcArg == "" {
+		return xerrors.New("--bc flag is required")
+	}
+
+	txBuf, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return xerrors.Errorf("failed to read from stdin: %v", err)
+	}
+	var exported byzcoin.ClientTransaction
+	err = protobuf.Decode(txBuf, &exported)
+	if err != nil {
+		return xerrors.Errorf("failed to decode transaction, did you use --export ?: %v", err)
+	}
+	if len(exported.Instructions) == 0 {
+		return xerrors.New("the transaction has no instructions")
+	}
+
+	cfg, cl, err := lib.LoadConfig(bcArg)
+	if err != nil {
+		return err
+	}
+	var signer *darc.Signer
+	if sstr := c.String("sign"); sstr == "" {
+		signer, err = lib.LoadKey(cfg.AdminIdentity)
+	} else {
+		signer, err = lib.LoadKeyFromString(sstr)
+	}
+	if err != nil {
+		return err
+	}
+	counters, err := cl.GetSignerCounters(signer.Identity().String())
+	if err != nil {
+		return xerrors.Errorf("couldn't get signer counters: %v", err)
+	}
+
+	// The exported instructions are stripped of their signatures and
+	// counters, so they are signed again with the next counters.
+	for i := range exported.Instructions {
+		exported.Instructions[i].SignerCounter = []uint64{counters.Counters[0] + uint64(i) + 1}
+	}
+	ctx, err := cl.CreateTransaction(exported.Instructions...)
+	if err != nil {
+		return err
+	}
+	// The fee payer is signed, too, so it must be set before signing.
+	if exported.FeePayer != nil {
+		payer := *exported.FeePayer
+		ctx.FeePayer = &payer
+	}
+	if err = ctx.FillSignersAndSignWith(*signer); err != nil {
+		return err
+	}
+
+	resp, err := cl.SimulateTransaction(ctx)
+	if err != nil {
+		return xerrors.Errorf("couldn't simulate transaction: %v", err)
+	}
+	for i, si := range resp.Instructions {
+		fmt.Fprintf(c.App.Writer, "Instruction %d: %s on %x\n", i,
+			si.Instruction.Action(), si.Instruction.InstanceID.Slice())
+		for _, sc := range si.StateChanges {
+			fmt.Fprintf(c.App.Writer, "\t%s %x (contract %s): %d bytes\n",
+				sc.StateAction, sc.InstanceID, sc.ContractID, len(sc.Value))
+		}
+		for _, coin := range si.CoinsOut {
+			fmt.Fprintf(c.App.Writer, "\tcoins out: %d of %x\n", coin.Value,
+				coin.Name.Slice())
+		}
+		if si.Error != "" {
+			fmt.Fprintf(c.App.Writer, "\terror: %s\n", si.Error)
+		}
+	}
+	if !resp.Accepted {
+		return xerrors.Errorf("the transaction would be refused: %s", resp.Error)
+	}
+	fmt.Fprintf(c.App.Writer, "The transaction would be accepted with %d "+
+		"state changes\n", len(resp.StateChanges))
+	return nil
+}
+
 func debugCounters(c *cli.Context) error {
 	if c.NArg() < 2 {
 		return xerrors.New("please give the following arguments: bc-xxx.cfg key-xxx.cfg")
@@ -1513,8 +1745,11 @@ func darcRule(c *cli.Context) error {
 	if min == 0 {
 		groupExpr = expression.InitAndExpr(identities...)
 	} else {
-		andGroups := lib.CombinationAnds(identities, int(min))
-		groupExpr = expression.InitOrExpr(andGroups...)
+		if int(min) > len(identities) {
+			return xerrors.Errorf("minimum %d is bigger than the number "+
+				"of identities (%d)", min, len(identities))
+		}
+		groupExpr = expression.InitThresholdExpr(int(min), identities...)
 	}
 
 	d2 := d.Copy()
@@ -1613,8 +1848,11 @@ func darcPrintRule(c *cli.Context) error {
 	if min == 0 {
 		groupExpr = expression.InitAndExpr(identities...)
 	} else {
-		andGroups := lib.CombinationAnds(identities, int(min))
-		groupExpr = expression.InitOrExpr(andGroups...)
+		if int(min) > len(identities) {
+			return xerrors.Errorf("minimum %d is bigger than the number "+
+				"of identities (%d)", min, len(identities))
+		}
+		groupExpr = expression.InitThresholdExpr(int(min), identities...)
 	}
 
 	log.Infof("%s\n", groupExpr)
@@ -1769,7 +2007,12 @@ func getInstance(c *cli.Context) error {
 		return xerrors.New("failed to decode the instID string " + instID)
 	}
 
-	pr, err := cl.GetProofFromLatest(instIDBuf)
+	var pr *byzcoin.GetProofResponse
+	if c.IsSet("at") {
+		pr, err = cl.GetProofAt(instIDBuf, c.Int("at"))
+	} else {
+		pr, err = cl.GetProofFromLatest(instIDBuf)
+	}
 	if err != nil {
 		return xerrors.Errorf("couldn't get proof: %v", err)
 	}
@@ -1804,6 +2047,9 @@ func getInstance(c *cli.Context) error {
 	fmt.Fprintf(out, "-- Value: %s\n", instanceData)
 	fmt.Fprintf(out, "-- ContranctID: %s\n", contractID)
 	fmt.Fprintf(out, "-- DarcID: %x\n", darcID)
+	if c.IsSet("at") {
+		fmt.Fprintf(out, "-- Block index: %d\n", proof.Latest.Index)
+	}
 	log.Info(out.String())
 
 	return nil
@@ -21,6 +21,7 @@ export BC_WAIT=true
 . "../clicontracts/config_test.sh"
 . "../clicontracts/deferred_test.sh"
 . "../clicontracts/value_test.sh"
+. "../clicontracts/token_test.sh"
 . "../clicontracts/name_test.sh"
 
 main(){
@@ -32,6 +33,7 @@ main(){
     run testDbMerge
     run testDbCatchup
     run testDebugBlock
+    run testTxSimulate
     run testLink
     run testLinkScenario
     run testCoin
@@ -50,6 +52,7 @@ main(){
     run testResolveiid
     run testInstructionGet
     run testContractValue
+    run testContractToken
     run testContractDeferred
     run testContractConfig
     run testContractName
@@ -93,6 +96,13 @@ testDbReplay(){
   testReNGrep "index 1"
   testNGrep "index 0" runBA0 db replay conode.db $bcID --write --continue
   testReGrep "index 1"
+
+  # copy the written trie to leveldb
+  rm -rf conode.db.leveldb
+  testGrep "Copied 1 state tries" runBA0 db migrate conode.db
+  testFile conode.db.leveldb/CURRENT
+  # the db is now configured to use the leveldb
+  rm -rf conode.db.leveldb conode.db
 }
 
 testDbMerge(){
@@ -153,6 +163,21 @@ testDebugBlock(){
     --blockIndex 1 --txDetails
 }
 
+testTxSimulate(){
+  rm -f config/*
+  runCoBG 1 2 3
+  runGrepSed "export BC=" "" runBA create --roster public.toml --interval .5s
+  eval $SED
+
+  OUTRES=`runBA0 contract -x value spawn --value simulated | runBA0 tx simulate`
+  matchOK "$OUTRES" "^Instruction 0: spawn:value on [0-9a-f]{64}
+	Create [0-9a-f]{64} \(contract value\): 9 bytes
+The transaction would be accepted with 2 state changes"
+  # Nothing has been stored, so the chain is still at the genesis block.
+  testGrep "no block with index" runBA0 debug block --bcCfg $BC --blockIndex 1
+  testFail runBA tx simulate < /dev/null
+}
+
 testLink(){
   rm -f config/*
   runCoBG 1 2 3
@@ -249,6 +274,8 @@ testRoster(){
   runBA debug counters $bc $key
   testOK runBA config --blockSize 1000000 $bc $key
   testGrep 2008 runBA0 latest $bc
+  testOK runBA config --snapshotInterval 10 $bc $key
+  testFail runBA config --snapshotInterval -1 $bc $key
 
   testFail runBA roster add $bc $key co4/public.toml
   # Deleting the leader raises an error...
@@ -374,15 +401,15 @@ testDarcAddRuleMinimum(){
   ID=`cat ./darc_id.txt`
   KEY=`cat ./darc_key.txt`
   testOK runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id darc:A -id darc:B -id darc:C -id darc:D --minimum 1
-  testFGrep "test:contract - \"((darc:A)) | ((darc:B)) | ((darc:C)) | ((darc:D))\"" runBA0 darc show --darc "$ID"
+  testFGrep "test:contract - \"[darc:A, darc:B, darc:C, darc:D]/1\"" runBA0 darc show --darc "$ID"
   
   # with a minimum
   testOK runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id darc:A -id darc:B -id darc:C -id darc:D --minimum 2 -replace
-  testFGrep "test:contract - \"((darc:A) & (darc:B)) | ((darc:A) & (darc:C)) | ((darc:A) & (darc:D)) | ((darc:B) & (darc:C)) | ((darc:B) & (darc:D)) | ((darc:C) & (darc:D))\"" runBA0 darc show --darc "$ID"
+  testFGrep "test:contract - \"[darc:A, darc:B, darc:C, darc:D]/2\"" runBA0 darc show --darc "$ID"
 
   # with a minimum and a special id composed of an AND
   testOK runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id 'darc:A & ed25519:aef' -id darc:B -id darc:C -id darc:D --minimum 2 -replace
-  testFGrep "test:contract - \"((darc:A & ed25519:aef) & (darc:B)) | ((darc:A & ed25519:aef) & (darc:C)) | ((darc:A & ed25519:aef) & (darc:D)) | ((darc:B) & (darc:C)) | ((darc:B) & (darc:D)) | ((darc:C) & (darc:D))\"" runBA0 darc show --darc "$ID"
+  testFGrep "test:contract - \"[darc:A & ed25519:aef, darc:B, darc:C, darc:D]/2\"" runBA0 darc show --darc "$ID"
 
   # with some wrong identities
   testFail runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id 'xdarc:A & ed25519:aef' -id darc:B --minimum 2 -replace
@@ -390,6 +417,7 @@ testDarcAddRuleMinimum(){
   testFail runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id 'ed25519:aef &' -id darc:B --minimum 2 -replace
   testFail runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id 'darc:A & C & ed25519:aef' -id darc:B -replace
   testFail runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id ' ' -id darc:B --minimum 2 -replace
+  testFail runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id darc:A -id darc:B --minimum 3 -replace
 }
 
 testRuleDarc(){
@@ -562,6 +590,8 @@ testInstructionGet() {
 
   testOK runBA0 instance get -i 0000000000000000000000000000000000000000000000000000000000000000
   testOK runBA0 instance get -i 0000000000000000000000000000000000000000000000000000000000000000 --hex
+  testGrep "Block index: 0" runBA0 instance get -i 0000000000000000000000000000000000000000000000000000000000000000 --at 0
+  testFail runBA0 instance get -i 0000000000000000000000000000000000000000000000000000000000000000 --at 10
 }
 
 main
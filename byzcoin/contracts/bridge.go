@@ -0,0 +1,467 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// The bridge contracts move coins between two ByzCoin ledgers without a
+// trusted operator. Each ledger holds a bridge instance that knows the
+// genesis block of the other ledger, so it can verify the proofs coming from
+// there.
+//
+// A transfer from ledger A to ledger B works like this:
+//  1. on A, the coins are fetched from an account and given to the "lock"
+//     command of the bridge to B. This creates a bridgeLock instance holding
+//     the coins, the recipient on B and a timeout.
+//  2. on B, the "claim" command of the bridge to A gets the proof of the lock
+//     from A. If the proof is valid and the timeout has not passed, the coins
+//     are created in the recipient account, and a bridgeClaim instance is
+//     created, so that the lock cannot be claimed twice.
+//  3. if nobody claimed the coins before the timeout, the "refund" command
+//     of the lock on A gets a proof from B showing that there is no
+//     bridgeClaim instance for the lock in a block created after the
+//     timeout. As the timestamps of the blocks are increasing, the lock
+//     cannot be claimed anymore, and the coins are given back to the refund
+//     account.
+//
+// ContractBridgeID is spawned from a darc instance with the following
+// arguments:
+//  - genesis is the protobuf encoded genesis block of the other ledger
+//  - coinName is the type of the coins the bridge locks and creates. If it is
+//    missing, CoinName is used
+// The instance ID of a bridge is given by BridgeID, so that each bridge knows
+// the ID of its counterpart on the other ledger, and only claims the locks
+// of that counterpart. There can only be one bridge per ledger and type of
+// coins.
+// Its commands are:
+//  - lock takes the coins of the bridge type given to the instruction, and
+//    locks them for the "recipient" account on the other ledger until
+//    "timeout", which is a 64-bit int in LittleEndian holding the unix time
+//    in nanoseconds. The "refund" account receives the coins if they are not
+//    claimed.
+//  - claim creates the coins of the lock proven by the "proof" argument in
+//    the recipient account.
+//
+// ContractBridgeLockID holds the locked coins. Its only command is "refund",
+// with the "proof" argument. A lock cannot be deleted, as this would destroy
+// its coins.
+//
+// ContractBridgeClaimID marks a lock of the other ledger as claimed. It has
+// no commands and cannot be deleted.
+
+// ContractBridgeID denotes a contract linking to another ledger.
+const ContractBridgeID = "bridge"
+
+// ContractBridgeLockID denotes a contract holding coins locked for another
+// ledger.
+const ContractBridgeLockID = "bridgeLock"
+
+// ContractBridgeClaimID denotes a contract marking a lock of another ledger
+// as claimed.
+const ContractBridgeClaimID = "bridgeClaim"
+
+// BridgeData is the data stored in a bridge instance.
+type BridgeData struct {
+	// Genesis is the genesis block of the other ledger.
+	Genesis skipchain.SkipBlock
+	// CoinName is the type of coins locked and claimed by the bridge.
+	CoinName byzcoin.InstanceID
+	// Counterpart is the bridge instance on the other ledger. Only its
+	// locks can be claimed.
+	Counterpart byzcoin.InstanceID
+}
+
+// BridgeLockData is the data stored in a lock instance.
+type BridgeLockData struct {
+	// Bridge is the bridge instance that created the lock.
+	Bridge byzcoin.InstanceID
+	// Destination is the ID of the ledger where the coins can be claimed.
+	Destination skipchain.SkipBlockID
+	// Recipient is the coin instance on the destination ledger.
+	Recipient byzcoin.InstanceID
+	// Coin holds the locked coins.
+	Coin byzcoin.Coin
+	// Timeout is the unix time in nanoseconds after which the coins cannot
+	// be claimed anymore.
+	Timeout int64
+	// Refund is the coin instance receiving the coins if they are not
+	// claimed.
+	Refund byzcoin.InstanceID
+}
+
+// BridgeClaimData is the data stored in a claim instance.
+type BridgeClaimData struct {
+	Lock      byzcoin.InstanceID
+	Recipient byzcoin.InstanceID
+}
+
+// BridgeID returns the instance ID of the bridge on the ledger with the given
+// ID, linking to the other ledger for the given type of coins.
+func BridgeID(ledger, other skipchain.SkipBlockID, coinName byzcoin.InstanceID) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write([]byte(ContractBridgeID))
+	h.Write(ledger)
+	h.Write(other)
+	h.Write(coinName.Slice())
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// BridgeClaimID returns the instance ID of the claim of the lock on the
+// ledger with the given ID.
+func BridgeClaimID(source skipchain.SkipBlockID, lock byzcoin.InstanceID) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write([]byte(ContractBridgeClaimID))
+	h.Write(source)
+	h.Write(lock.Slice())
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+type contractBridge struct {
+	byzcoin.BasicContract
+	BridgeData
+}
+
+func contractBridgeFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &contractBridge{}
+	err := protobuf.Decode(in, &c.BridgeData)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal instance data: %v", err)
+	}
+	return c, nil
+}
+
+func (c *contractBridge) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return
+	}
+
+	err = protobuf.Decode(inst.Spawn.Args.Search("genesis"), &c.Genesis)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't decode genesis block: %v", err)
+	}
+	if c.Genesis.Index != 0 || !c.Genesis.CalculateHash().Equal(c.Genesis.Hash) {
+		return nil, nil, xerrors.New("argument \"genesis\" is not a valid genesis block")
+	}
+	c.CoinName = CoinName
+	if name := inst.Spawn.Args.Search("coinName"); name != nil {
+		if len(name) != len(byzcoin.InstanceID{}) {
+			return nil, nil, xerrors.New("coinName needs to be an InstanceID")
+		}
+		c.CoinName = byzcoin.NewInstanceID(name)
+	}
+	gs, ok := rst.(byzcoin.GlobalState)
+	if !ok {
+		return nil, nil, xerrors.New("internal error: cannot convert " +
+			"ReadOnlyStateTrie to GlobalState")
+	}
+	genesis, err := gs.GetGenesisBlock()
+	if err != nil {
+		return nil, nil, xerrors.Errorf("getting genesis block: %v", err)
+	}
+	c.Counterpart = BridgeID(c.Genesis.Hash, genesis.Hash, c.CoinName)
+
+	bridgeBuf, err := protobuf.Encode(&c.BridgeData)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode bridge: %v", err)
+	}
+	sc = []byzcoin.StateChange{
+		byzcoin.NewStateChange(byzcoin.Create,
+			BridgeID(genesis.Hash, c.Genesis.Hash, c.CoinName),
+			ContractBridgeID, bridgeBuf, darcID),
+	}
+	return
+}
+
+func (c *contractBridge) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return
+	}
+	gs, ok := rst.(byzcoin.GlobalState)
+	if !ok {
+		return nil, nil, xerrors.New("internal error: cannot convert " +
+			"ReadOnlyStateTrie to GlobalState")
+	}
+
+	switch inst.Invoke.Command {
+	case "lock":
+		recipientBuf := inst.Invoke.Args.Search("recipient")
+		if len(recipientBuf) != len(byzcoin.InstanceID{}) {
+			return nil, nil, xerrors.New("argument \"recipient\" is missing or wrong length")
+		}
+		refundBuf := inst.Invoke.Args.Search("refund")
+		if len(refundBuf) != len(byzcoin.InstanceID{}) {
+			return nil, nil, xerrors.New("argument \"refund\" is missing or wrong length")
+		}
+		lock := BridgeLockData{
+			Bridge:      inst.InstanceID,
+			Destination: c.Genesis.Hash,
+			Recipient:   byzcoin.NewInstanceID(recipientBuf),
+			Coin:        byzcoin.Coin{Name: c.CoinName},
+			Refund:      byzcoin.NewInstanceID(refundBuf),
+		}
+		timeoutBuf := inst.Invoke.Args.Search("timeout")
+		if len(timeoutBuf) != 8 {
+			return nil, nil, xerrors.New("argument \"timeout\" is missing or wrong length")
+		}
+		lock.Timeout = int64(binary.LittleEndian.Uint64(timeoutBuf))
+		if lock.Timeout <= gs.GetCurrentBlockTimestamp() {
+			return nil, nil, xerrors.New("timeout is in the past")
+		}
+		if _, _, err = loadBridgeCoin(rst, lock.Refund, c.CoinName); err != nil {
+			return nil, nil, xerrors.Errorf("refund: %v", err)
+		}
+
+		// Take all the coins of the bridge type.
+		cout = []byzcoin.Coin{}
+		for _, co := range coins {
+			if co.Name.Equal(c.CoinName) {
+				if err = lock.Coin.SafeAdd(co.Value); err != nil {
+					return
+				}
+			} else {
+				cout = append(cout, co)
+			}
+		}
+		if lock.Coin.Value == 0 {
+			return nil, nil, xerrors.New("no coins to lock")
+		}
+
+		var lockBuf []byte
+		lockBuf, err = protobuf.Encode(&lock)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't encode lock: %v", err)
+		}
+		lockID := inst.DeriveID("lock")
+		log.Lvlf2("locking %d coins in %x", lock.Coin.Value, lockID.Slice())
+		sc = []byzcoin.StateChange{
+			byzcoin.NewStateChange(byzcoin.Create, lockID,
+				ContractBridgeLockID, lockBuf, darcID),
+		}
+	case "claim":
+		var genesis *skipchain.SkipBlock
+		genesis, err = gs.GetGenesisBlock()
+		if err != nil {
+			return nil, nil, xerrors.Errorf("getting genesis block: %v", err)
+		}
+		var proof byzcoin.Proof
+		proof, err = c.verifyProof(inst.Invoke.Args.Search("proof"))
+		if err != nil {
+			return
+		}
+		lockID, lockBuf, cid, _, err := proof.KeyValue()
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't get lock from proof: %v", err)
+		}
+		if !proof.InclusionProof.Match(lockID) || cid != ContractBridgeLockID {
+			return nil, nil, xerrors.New("proof is not for a lock")
+		}
+		var lock BridgeLockData
+		if err = protobuf.Decode(lockBuf, &lock); err != nil {
+			return nil, nil, xerrors.Errorf("couldn't decode lock: %v", err)
+		}
+		if !lock.Destination.Equal(genesis.Hash) {
+			return nil, nil, xerrors.New("lock is for another ledger")
+		}
+		if !lock.Bridge.Equal(c.Counterpart) {
+			return nil, nil, xerrors.New("lock is from another bridge")
+		}
+		if !lock.Coin.Name.Equal(c.CoinName) {
+			return nil, nil, xerrors.New("lock holds another type of coins")
+		}
+		if lock.Timeout <= gs.GetCurrentBlockTimestamp() {
+			return nil, nil, xerrors.New("lock has timed out")
+		}
+
+		recipient, did, err := loadBridgeCoin(rst, lock.Recipient, c.CoinName)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("recipient: %v", err)
+		}
+		if err = recipient.SafeAdd(lock.Coin.Value); err != nil {
+			return nil, nil, err
+		}
+		recipientBuf, err := protobuf.Encode(&recipient)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't encode coin: %v", err)
+		}
+		claimBuf, err := protobuf.Encode(&BridgeClaimData{
+			Lock:      byzcoin.NewInstanceID(lockID),
+			Recipient: lock.Recipient,
+		})
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't encode claim: %v", err)
+		}
+		// Creating the claim fails if the lock has already been claimed.
+		claimID := BridgeClaimID(c.Genesis.Hash, byzcoin.NewInstanceID(lockID))
+		log.Lvlf2("claiming %d coins of %x", lock.Coin.Value, lockID)
+		sc = []byzcoin.StateChange{
+			byzcoin.NewStateChange(byzcoin.Create, claimID,
+				ContractBridgeClaimID, claimBuf, darcID),
+			byzcoin.NewStateChange(byzcoin.Update, lock.Recipient,
+				ContractCoinID, recipientBuf, did),
+		}
+	default:
+		return nil, nil, xerrors.New("bridge contract can only lock and claim")
+	}
+	return
+}
+
+// verifyProof decodes the proof and verifies that it comes from the ledger of
+// the bridge.
+func (c *contractBridge) verifyProof(buf []byte) (proof byzcoin.Proof, err error) {
+	if err = protobuf.Decode(buf, &proof); err != nil {
+		err = xerrors.Errorf("couldn't decode proof: %v", err)
+		return
+	}
+	if err = proof.VerifyFromBlock(&c.Genesis); err != nil {
+		err = xerrors.Errorf("invalid proof: %v", err)
+	}
+	return
+}
+
+type contractBridgeLock struct {
+	byzcoin.BasicContract
+	BridgeLockData
+}
+
+func contractBridgeLockFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &contractBridgeLock{}
+	err := protobuf.Decode(in, &c.BridgeLockData)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal instance data: %v", err)
+	}
+	return c, nil
+}
+
+func (c *contractBridgeLock) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return
+	}
+
+	switch inst.Invoke.Command {
+	case "refund":
+		var bridgeBuf []byte
+		var cid string
+		bridgeBuf, _, cid, _, err = rst.GetValues(c.Bridge.Slice())
+		if err == nil && cid != ContractBridgeID {
+			err = xerrors.New("bridge of the lock is missing")
+		}
+		if err != nil {
+			return
+		}
+		bridge := &contractBridge{}
+		if err = protobuf.Decode(bridgeBuf, &bridge.BridgeData); err != nil {
+			return nil, nil, xerrors.Errorf("couldn't decode bridge: %v", err)
+		}
+
+		var proof byzcoin.Proof
+		proof, err = bridge.verifyProof(inst.Invoke.Args.Search("proof"))
+		if err != nil {
+			return
+		}
+		gs, ok := rst.(byzcoin.GlobalState)
+		if !ok {
+			return nil, nil, xerrors.New("internal error: cannot convert " +
+				"ReadOnlyStateTrie to GlobalState")
+		}
+		genesis, err := gs.GetGenesisBlock()
+		if err != nil {
+			return nil, nil, xerrors.Errorf("getting genesis block: %v", err)
+		}
+		claimID := BridgeClaimID(genesis.Hash, inst.InstanceID)
+		exists, err := proof.InclusionProof.Exists(claimID.Slice())
+		if err != nil {
+			return nil, nil, xerrors.Errorf("proof is not for the claim: %v", err)
+		}
+		if exists {
+			return nil, nil, xerrors.New("the lock has been claimed")
+		}
+		var header byzcoin.DataHeader
+		if err = protobuf.Decode(proof.Latest.Data, &header); err != nil {
+			return nil, nil, xerrors.Errorf("couldn't decode header: %v", err)
+		}
+		if header.Timestamp < c.Timeout {
+			return nil, nil, xerrors.New("the proof is older than the timeout")
+		}
+
+		refund, did, err := loadBridgeCoin(rst, c.Refund, c.Coin.Name)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("refund: %v", err)
+		}
+		if err = refund.SafeAdd(c.Coin.Value); err != nil {
+			return nil, nil, err
+		}
+		refundBuf, err := protobuf.Encode(&refund)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't encode coin: %v", err)
+		}
+		log.Lvlf2("refunding %d coins to %x", c.Coin.Value, c.Refund.Slice())
+		sc = []byzcoin.StateChange{
+			byzcoin.NewStateChange(byzcoin.Update, c.Refund, ContractCoinID,
+				refundBuf, did),
+			byzcoin.NewStateChange(byzcoin.Remove, inst.InstanceID,
+				ContractBridgeLockID, nil, darcID),
+		}
+	default:
+		return nil, nil, xerrors.New("bridge lock contract can only refund")
+	}
+	return
+}
+
+func (c *contractBridgeLock) Delete(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	return nil, nil, xerrors.New("a lock can only be removed by a refund")
+}
+
+type contractBridgeClaim struct {
+	byzcoin.BasicContract
+}
+
+func contractBridgeClaimFromBytes(in []byte) (byzcoin.Contract, error) {
+	return &contractBridgeClaim{}, nil
+}
+
+func (c *contractBridgeClaim) Delete(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	return nil, nil, xerrors.New("a claim cannot be deleted")
+}
+
+// loadBridgeCoin returns the coin instance stored in id and its darc, and
+// makes sure that it holds coins of the given type.
+func loadBridgeCoin(rst byzcoin.ReadOnlyStateTrie, id byzcoin.InstanceID,
+	name byzcoin.InstanceID) (coin byzcoin.Coin, did darc.ID, err error) {
+	var v []byte
+	var cid string
+	v, _, cid, did, err = rst.GetValues(id.Slice())
+	if err == nil && cid != ContractCoinID {
+		err = xerrors.New("not a coin instance")
+	}
+	if err != nil {
+		return
+	}
+	if err = protobuf.Decode(v, &coin); err != nil {
+		err = xerrors.Errorf("couldn't unmarshal coin: %v", err)
+		return
+	}
+	if !coin.Name.Equal(name) {
+		err = xerrors.New("coin instance holds another type of coins")
+	}
+	return
+}
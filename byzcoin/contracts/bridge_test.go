@@ -0,0 +1,287 @@
+package contracts
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/trie"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// bridgeGS adds the skipchain and the time to the mock trie.
+type bridgeGS struct {
+	*cvTest
+	genesis   *skipchain.SkipBlock
+	timestamp int64
+}
+
+func (gs bridgeGS) GetLatest() (*skipchain.SkipBlock, error) {
+	return nil, xerrors.New("not implemented")
+}
+
+func (gs bridgeGS) GetGenesisBlock() (*skipchain.SkipBlock, error) {
+	return gs.genesis, nil
+}
+
+func (gs bridgeGS) GetBlock(skipchain.SkipBlockID) (*skipchain.SkipBlock, error) {
+	return nil, xerrors.New("not implemented")
+}
+
+func (gs bridgeGS) GetBlockByIndex(idx int) (*skipchain.SkipBlock, error) {
+	return nil, xerrors.New("not implemented")
+}
+
+func (gs bridgeGS) GetCurrentBlockTimestamp() int64 {
+	return gs.timestamp
+}
+
+// bridgeLedger is a remote ledger consisting only of its genesis block, which
+// holds the root of the trie.
+type bridgeLedger struct {
+	trie    *trie.Trie
+	genesis *skipchain.SkipBlock
+}
+
+func newBridgeLedger(t *testing.T, timestamp int64, scs ...byzcoin.StateChange) *bridgeLedger {
+	tr, err := trie.NewTrie(trie.NewMemDB(), []byte("nonce"))
+	require.NoError(t, err)
+	var pairs []trie.KVPair
+	for i := range scs {
+		pairs = append(pairs, &scs[i])
+	}
+	require.NoError(t, tr.Batch(pairs))
+
+	header, err := protobuf.Encode(&byzcoin.DataHeader{
+		TrieRoot:  tr.GetRoot(),
+		Timestamp: timestamp,
+	})
+	require.NoError(t, err)
+	kp := key.NewKeyPair(cothority.Suite)
+	si := network.NewServerIdentity(kp.Public,
+		network.NewAddress(network.TLS, "127.0.0.1:2000"))
+	sb := skipchain.NewSkipBlock()
+	sb.Roster = onet.NewRoster([]*network.ServerIdentity{si})
+	sb.Data = header
+	sb.Hash = sb.CalculateHash()
+	return &bridgeLedger{trie: tr, genesis: sb}
+}
+
+func (l *bridgeLedger) proof(t *testing.T, id byzcoin.InstanceID) []byte {
+	pr, err := l.trie.GetProof(id.Slice())
+	require.NoError(t, err)
+	buf, err := protobuf.Encode(&byzcoin.Proof{
+		InclusionProof: *pr,
+		Links: []skipchain.ForwardLink{{
+			From:      []byte{},
+			To:        l.genesis.Hash,
+			NewRoster: l.genesis.Roster,
+		}},
+		Latest: *l.genesis,
+	})
+	require.NoError(t, err)
+	return buf
+}
+
+func (ct *cvTest) storeBridge(t *testing.T, id byzcoin.InstanceID,
+	genesis *skipchain.SkipBlock, counterpart byzcoin.InstanceID) {
+	buf, err := protobuf.Encode(&BridgeData{Genesis: *genesis, CoinName: CoinName,
+		Counterpart: counterpart})
+	require.NoError(t, err)
+	ct.Store(id, buf, ContractBridgeID, gdarc.GetBaseID())
+}
+
+func TestBridge_LockClaimRefund(t *testing.T) {
+	timeout := int64(1000)
+	timeoutBuf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(timeoutBuf, uint64(timeout))
+
+	// Ledger B has no claim, and its block is after the timeout.
+	ledgerB := newBridgeLedger(t, timeout+1)
+
+	// Lock 2 coins on ledger A.
+	ctA := newCT(t)
+	// newCT replaces gdarc, so keep the darc of ledger A.
+	darcA := gdarc.GetBaseID()
+	bridgeA := iid("bridge to B")
+	ctA.storeBridge(t, bridgeA, ledgerB.genesis, iid("bridge to A"))
+	refund := iid("refund")
+	ctA.Store(refund, ciZero, ContractCoinID, gdarc.GetBaseID())
+	recipient := iid("recipient")
+	gsA := bridgeGS{cvTest: ctA, timestamp: timeout - 1}
+
+	lockInst := byzcoin.Instruction{
+		InstanceID: bridgeA,
+		Invoke: &byzcoin.Invoke{
+			Command: "lock",
+			Args: byzcoin.Arguments{
+				{Name: "recipient", Value: recipient.Slice()},
+				{Name: "refund", Value: refund.Slice()},
+				{Name: "timeout", Value: timeoutBuf},
+			},
+		},
+	}
+	other := byzcoin.Coin{Name: iid("other coin"), Value: 1}
+	c, err := contractBridgeFromBytes(ctA.values[string(bridgeA.Slice())])
+	require.NoError(t, err)
+	sc, cout, err := c.Invoke(gsA, lockInst,
+		[]byzcoin.Coin{{Name: CoinName, Value: 2}, other})
+	require.NoError(t, err)
+	require.Equal(t, []byzcoin.Coin{other}, cout)
+	require.Equal(t, 1, len(sc))
+	lockID := lockInst.DeriveID("lock")
+	require.Equal(t, lockID.Slice(), sc[0].InstanceID)
+	var lock BridgeLockData
+	require.NoError(t, protobuf.Decode(sc[0].Value, &lock))
+	require.Equal(t, uint64(2), lock.Coin.Value)
+	require.True(t, lock.Destination.Equal(ledgerB.genesis.Hash))
+	ctA.storeScs(sc)
+
+	// Without coins or after the timeout, nothing is locked.
+	_, _, err = c.Invoke(gsA, lockInst, nil)
+	require.Error(t, err)
+	for _, arg := range []string{"recipient", "refund"} {
+		wrong := lockInst
+		wrong.Invoke = &byzcoin.Invoke{Command: "lock",
+			Args: append(byzcoin.Arguments{}, lockInst.Invoke.Args...)}
+		for i := range wrong.Invoke.Args {
+			if wrong.Invoke.Args[i].Name == arg {
+				wrong.Invoke.Args[i].Value = []byte("short")
+			}
+		}
+		_, _, err = c.Invoke(gsA, wrong, []byzcoin.Coin{{Name: CoinName, Value: 2}})
+		require.Error(t, err)
+	}
+	gsA.timestamp = timeout
+	_, _, err = c.Invoke(gsA, lockInst, []byzcoin.Coin{{Name: CoinName, Value: 2}})
+	require.Error(t, err)
+
+	// Ledger A is seen from ledger B with the lock in its trie, and with
+	// locks of another bridge and of other coins.
+	otherBridge, otherCoin := lock, lock
+	otherBridge.Bridge = iid("other bridge")
+	otherCoin.Coin.Name = iid("other coin")
+	var wrongLocks []byzcoin.StateChange
+	for i, l := range []BridgeLockData{otherBridge, otherCoin} {
+		buf, err := protobuf.Encode(&l)
+		require.NoError(t, err)
+		wrongLocks = append(wrongLocks, byzcoin.NewStateChange(byzcoin.Create,
+			iid(fmt.Sprintf("wrong lock %d", i)), ContractBridgeLockID, buf,
+			darcA))
+	}
+	ledgerA := newBridgeLedger(t, timeout-1, append(wrongLocks, sc[0])...)
+	gsA.genesis = ledgerA.genesis
+
+	ctB := newCT(t)
+	bridgeB := iid("bridge to A")
+	ctB.storeBridge(t, bridgeB, ledgerA.genesis, bridgeA)
+	ctB.Store(recipient, ciZero, ContractCoinID, gdarc.GetBaseID())
+	gsB := bridgeGS{cvTest: ctB, genesis: ledgerB.genesis, timestamp: timeout - 1}
+	claim := func(proof []byte) ([]byzcoin.StateChange, error) {
+		c, err := contractBridgeFromBytes(ctB.values[string(bridgeB.Slice())])
+		require.NoError(t, err)
+		sc, _, err := c.Invoke(gsB, byzcoin.Instruction{
+			InstanceID: bridgeB,
+			Invoke: &byzcoin.Invoke{
+				Command: "claim",
+				Args:    byzcoin.Arguments{{Name: "proof", Value: proof}},
+			},
+		}, nil)
+		return sc, err
+	}
+
+	// A proof from another ledger, and the locks of another bridge or with
+	// other coins are refused.
+	_, err = claim(ledgerB.proof(t, lockID))
+	require.Error(t, err)
+	for _, wrong := range wrongLocks {
+		_, err = claim(ledgerA.proof(t, byzcoin.NewInstanceID(wrong.InstanceID)))
+		require.Error(t, err)
+	}
+	sc, err = claim(ledgerA.proof(t, lockID))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(sc))
+	require.Equal(t, byzcoin.Create, sc[0].StateAction)
+	require.Equal(t, BridgeClaimID(ledgerA.genesis.Hash, lockID).Slice(),
+		sc[0].InstanceID)
+	require.Equal(t, byzcoin.NewStateChange(byzcoin.Update, recipient,
+		ContractCoinID, ciTwo, gdarc.GetBaseID()), sc[1])
+	gsB.timestamp = timeout
+	_, err = claim(ledgerA.proof(t, lockID))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "timed out")
+
+	// The lock can be refunded with the proof that ledger B has no claim.
+	refundLock := func(proof []byte) ([]byzcoin.StateChange, error) {
+		c, err := contractBridgeLockFromBytes(ctA.values[string(lockID.Slice())])
+		require.NoError(t, err)
+		sc, _, err := c.Invoke(gsA, byzcoin.Instruction{
+			InstanceID: lockID,
+			Invoke: &byzcoin.Invoke{
+				Command: "refund",
+				Args:    byzcoin.Arguments{{Name: "proof", Value: proof}},
+			},
+		}, nil)
+		return sc, err
+	}
+	claimID := BridgeClaimID(ledgerA.genesis.Hash, lockID)
+	_, err = refundLock(ledgerA.proof(t, claimID))
+	require.Error(t, err)
+	sc, err = refundLock(ledgerB.proof(t, claimID))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(sc))
+	require.Equal(t, byzcoin.NewStateChange(byzcoin.Update, refund,
+		ContractCoinID, ciTwo, darcA), sc[0])
+	require.Equal(t, byzcoin.Remove, sc[1].StateAction)
+
+	// Neither the lock nor the claim can be deleted.
+	c, err = contractBridgeLockFromBytes(ctA.values[string(lockID.Slice())])
+	require.NoError(t, err)
+	_, _, err = c.Delete(gsA, byzcoin.Instruction{InstanceID: lockID,
+		Delete: &byzcoin.Delete{}}, nil)
+	require.Error(t, err)
+	c, err = contractBridgeClaimFromBytes(nil)
+	require.NoError(t, err)
+	_, _, err = c.Delete(gsB, byzcoin.Instruction{InstanceID: claimID,
+		Delete: &byzcoin.Delete{}}, nil)
+	require.Error(t, err)
+}
+
+func TestBridge_Spawn(t *testing.T) {
+	ledgerB := newBridgeLedger(t, 0)
+	ledgerA := newBridgeLedger(t, 0)
+	ct := newCT(t)
+	gs := bridgeGS{cvTest: ct, genesis: ledgerA.genesis}
+	darcID := byzcoin.NewInstanceID(gdarc.GetBaseID())
+	ct.Store(darcID, nil, byzcoin.ContractDarcID, gdarc.GetBaseID())
+
+	genesisBuf, err := protobuf.Encode(ledgerB.genesis)
+	require.NoError(t, err)
+	c := &contractBridge{}
+	sc, _, err := c.Spawn(gs, byzcoin.Instruction{
+		InstanceID: darcID,
+		Spawn: &byzcoin.Spawn{
+			ContractID: ContractBridgeID,
+			Args:       byzcoin.Arguments{{Name: "genesis", Value: genesisBuf}},
+		},
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(sc))
+
+	// The IDs of both bridges are known in advance.
+	require.Equal(t, BridgeID(ledgerA.genesis.Hash, ledgerB.genesis.Hash,
+		CoinName).Slice(), sc[0].InstanceID)
+	var bridge BridgeData
+	require.NoError(t, protobuf.Decode(sc[0].Value, &bridge))
+	require.Equal(t, BridgeID(ledgerB.genesis.Hash, ledgerA.genesis.Hash,
+		CoinName), bridge.Counterpart)
+	require.Equal(t, CoinName, bridge.CoinName)
+}
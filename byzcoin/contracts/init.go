@@ -18,4 +18,24 @@ func init() {
 	if err != nil {
 		log.ErrFatal(err)
 	}
+	err = byzcoin.RegisterGlobalContract(ContractTokenID, contractTokenFromBytes)
+	if err != nil {
+		log.ErrFatal(err)
+	}
+	err = byzcoin.RegisterGlobalContract(ContractTokenAccountID, contractTokenAccountFromBytes)
+	if err != nil {
+		log.ErrFatal(err)
+	}
+	err = byzcoin.RegisterGlobalContract(ContractBridgeID, contractBridgeFromBytes)
+	if err != nil {
+		log.ErrFatal(err)
+	}
+	err = byzcoin.RegisterGlobalContract(ContractBridgeLockID, contractBridgeLockFromBytes)
+	if err != nil {
+		log.ErrFatal(err)
+	}
+	err = byzcoin.RegisterGlobalContract(ContractBridgeClaimID, contractBridgeClaimFromBytes)
+	if err != nil {
+		log.ErrFatal(err)
+	}
 }
@@ -0,0 +1,487 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// The token contracts implement fungible tokens with allowances, in the
+// spirit of ERC-20. Contrary to ContractCoin, every token has its own
+// instance holding its metadata, and any number of tokens can live on the
+// same chain.
+//
+// ContractTokenID holds the metadata and the total supply of a token. It is
+// spawned from a darc instance with the following arguments:
+//  - name and symbol are the strings describing the token
+//  - decimals is the number of decimals used to display the amounts
+//  - supplyCap is the maximum total supply. If it is missing, the supply is
+//    only limited by the size of an uint64
+//  - initialSupply, if present, creates the account of darcID holding this
+//    amount of tokens
+//  - darcID is the darc controlling the token. If it is missing, the darc of
+//    the spawning instance is used
+// All numbers are 64-bit uints in LittleEndian.
+// The only command of a token instance is "mint", which creates "amount"
+// tokens in the account given in "destination", as long as the supply cap is
+// not reached. Minting is only possible if the darc of the token has a rule
+// for "invoke:token.mint", so a token with a fixed supply is created by giving
+// it an initialSupply and a darc without this rule.
+//
+// ContractTokenAccountID holds the balance of one owner for one token. It is
+// spawned from a darc instance with the argument "tokenID", and optionally
+// "darcID" for the owner of the account. The instance ID of the account is
+// given by TokenAccountID, so there is only one account per owner and token.
+// The following commands are available on an account:
+//  - transfer sends "amount" tokens to the account in "destination"
+//  - approve allows the account in "spender" to spend "amount" tokens from
+//    this account. A new approval replaces the previous one, and an amount of
+//    0 removes it
+//  - transferFrom is invoked on the account of the spender and sends "amount"
+//    tokens from the account in "from" to the account in "destination",
+//    reducing the allowance the spender got from "from"
+// An account can only be deleted if it is empty, and a token only if its
+// supply is 0.
+
+// ContractTokenID denotes a contract holding the metadata of a token.
+const ContractTokenID = "token"
+
+// ContractTokenAccountID denotes a contract holding the tokens of an owner.
+const ContractTokenAccountID = "tokenAccount"
+
+// TokenData is the data stored in a token instance.
+type TokenData struct {
+	Name        string
+	Symbol      string
+	Decimals    uint64
+	SupplyCap   uint64
+	TotalSupply uint64
+}
+
+// TokenAccountData is the data stored in a token account instance.
+type TokenAccountData struct {
+	TokenID    byzcoin.InstanceID
+	Balance    uint64
+	Allowances []TokenAllowance
+}
+
+// TokenAllowance is the amount of tokens a spender can take out of an
+// account.
+type TokenAllowance struct {
+	Spender byzcoin.InstanceID
+	Amount  uint64
+}
+
+// Allowance returns the amount of tokens spender is allowed to take out of
+// the account.
+func (ta TokenAccountData) Allowance(spender byzcoin.InstanceID) uint64 {
+	for _, a := range ta.Allowances {
+		if a.Spender.Equal(spender) {
+			return a.Amount
+		}
+	}
+	return 0
+}
+
+// setAllowance replaces the allowance of spender. An amount of 0 removes it.
+func (ta *TokenAccountData) setAllowance(spender byzcoin.InstanceID, amount uint64) {
+	var allowances []TokenAllowance
+	for _, a := range ta.Allowances {
+		if !a.Spender.Equal(spender) {
+			allowances = append(allowances, a)
+		}
+	}
+	if amount > 0 {
+		allowances = append(allowances, TokenAllowance{Spender: spender, Amount: amount})
+	}
+	ta.Allowances = allowances
+}
+
+// TokenAccountID returns the instance ID of the account of owner for the
+// given token.
+func TokenAccountID(tokenID byzcoin.InstanceID, owner darc.ID) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write([]byte(ContractTokenAccountID))
+	h.Write(tokenID.Slice())
+	h.Write(owner)
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+type contractToken struct {
+	byzcoin.BasicContract
+	TokenData
+}
+
+func contractTokenFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &contractToken{}
+	err := protobuf.Decode(in, &c.TokenData)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal instance data: %v", err)
+	}
+	return c, nil
+}
+
+func (c *contractToken) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return
+	}
+	if did := inst.Spawn.Args.Search("darcID"); did != nil {
+		darcID = darc.ID(did)
+	}
+
+	c.Name = string(inst.Spawn.Args.Search("name"))
+	c.Symbol = string(inst.Spawn.Args.Search("symbol"))
+	if c.Name == "" || c.Symbol == "" {
+		return nil, nil, xerrors.New("arguments \"name\" and \"symbol\" are required")
+	}
+	c.Decimals, err = tokenUintArg(inst.Spawn.Args, "decimals", 0)
+	if err != nil {
+		return
+	}
+	c.SupplyCap, err = tokenUintArg(inst.Spawn.Args, "supplyCap", ^uint64(0))
+	if err != nil {
+		return
+	}
+	c.TotalSupply, err = tokenUintArg(inst.Spawn.Args, "initialSupply", 0)
+	if err != nil {
+		return
+	}
+	if c.TotalSupply > c.SupplyCap {
+		return nil, nil, xerrors.New("initial supply is bigger than the supply cap")
+	}
+
+	tokenID := inst.DeriveID("")
+	log.Lvlf2("Spawning token %s to %x, with darc %x", c.Symbol,
+		tokenID.Slice(), darcID)
+	tokenBuf, err := protobuf.Encode(&c.TokenData)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode token: %v", err)
+	}
+	sc = []byzcoin.StateChange{
+		byzcoin.NewStateChange(byzcoin.Create, tokenID, ContractTokenID,
+			tokenBuf, darcID),
+	}
+
+	if c.TotalSupply > 0 {
+		account := TokenAccountData{TokenID: tokenID, Balance: c.TotalSupply}
+		accountBuf, err := protobuf.Encode(&account)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't encode account: %v", err)
+		}
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Create,
+			TokenAccountID(tokenID, darcID), ContractTokenAccountID,
+			accountBuf, darcID))
+	}
+	return
+}
+
+func (c *contractToken) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return
+	}
+
+	switch inst.Invoke.Command {
+	case "mint":
+		var amount uint64
+		amount, err = tokenAmountArg(inst.Invoke.Args)
+		if err != nil {
+			return
+		}
+		target := byzcoin.NewInstanceID(inst.Invoke.Args.Search("destination"))
+		var account TokenAccountData
+		var accountDarc darc.ID
+		account, accountDarc, err = loadTokenAccount(rst, target, inst.InstanceID)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("destination: %v", err)
+		}
+		supply := c.TotalSupply + amount
+		if supply < c.TotalSupply || supply > c.SupplyCap {
+			return nil, nil, xerrors.New("minting would exceed the supply cap")
+		}
+		c.TotalSupply = supply
+		// The balance of an account is never bigger than the total supply.
+		account.Balance += amount
+
+		var accountBuf []byte
+		accountBuf, err = protobuf.Encode(&account)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't encode account: %v", err)
+		}
+		log.Lvlf2("minting %d to %x", amount, target.Slice())
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Update, target,
+			ContractTokenAccountID, accountBuf, accountDarc))
+	default:
+		return nil, nil, xerrors.New("token contract can only mint")
+	}
+
+	var tokenBuf []byte
+	tokenBuf, err = protobuf.Encode(&c.TokenData)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode token: %v", err)
+	}
+	sc = append(sc, byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID,
+		ContractTokenID, tokenBuf, darcID))
+	return
+}
+
+func (c *contractToken) Delete(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return
+	}
+
+	if c.TotalSupply > 0 {
+		return nil, nil, xerrors.New("cannot delete a token that has a supply")
+	}
+	sc = byzcoin.StateChanges{
+		byzcoin.NewStateChange(byzcoin.Remove, inst.InstanceID, ContractTokenID, nil, darcID),
+	}
+	return
+}
+
+type contractTokenAccount struct {
+	byzcoin.BasicContract
+	TokenAccountData
+}
+
+func contractTokenAccountFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &contractTokenAccount{}
+	err := protobuf.Decode(in, &c.TokenAccountData)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal instance data: %v", err)
+	}
+	return c, nil
+}
+
+func (c *contractTokenAccount) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return
+	}
+	if did := inst.Spawn.Args.Search("darcID"); did != nil {
+		darcID = darc.ID(did)
+	}
+
+	tokenID := byzcoin.NewInstanceID(inst.Spawn.Args.Search("tokenID"))
+	var cid string
+	_, _, cid, _, err = rst.GetValues(tokenID.Slice())
+	if err == nil && cid != ContractTokenID {
+		err = xerrors.New("tokenID is not a token contract")
+	}
+	if err != nil {
+		return
+	}
+
+	c.TokenID = tokenID
+	accountBuf, err := protobuf.Encode(&c.TokenAccountData)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode account: %v", err)
+	}
+	sc = []byzcoin.StateChange{
+		byzcoin.NewStateChange(byzcoin.Create, TokenAccountID(tokenID, darcID),
+			ContractTokenAccountID, accountBuf, darcID),
+	}
+	return
+}
+
+func (c *contractTokenAccount) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return
+	}
+
+	amount, err := tokenAmountArg(inst.Invoke.Args)
+	if err != nil {
+		return
+	}
+
+	switch inst.Invoke.Command {
+	case "transfer":
+		target := byzcoin.NewInstanceID(inst.Invoke.Args.Search("destination"))
+		if inst.InstanceID.Equal(target) {
+			return nil, nil, xerrors.New("cannot send tokens to ourselves")
+		}
+		if c.Balance < amount {
+			return nil, nil, xerrors.New("not enough tokens")
+		}
+		c.Balance -= amount
+		var targetSc byzcoin.StateChange
+		targetSc, err = creditTokenAccount(rst, target, c.TokenID, amount)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("destination: %v", err)
+		}
+		log.Lvlf2("transferring %d tokens to %x", amount, target.Slice())
+		sc = append(sc, targetSc)
+	case "approve":
+		spender := byzcoin.NewInstanceID(inst.Invoke.Args.Search("spender"))
+		if inst.InstanceID.Equal(spender) {
+			return nil, nil, xerrors.New("cannot approve ourselves")
+		}
+		c.setAllowance(spender, amount)
+	case "transferFrom":
+		from := byzcoin.NewInstanceID(inst.Invoke.Args.Search("from"))
+		target := byzcoin.NewInstanceID(inst.Invoke.Args.Search("destination"))
+		if inst.InstanceID.Equal(from) {
+			return nil, nil, xerrors.New("use transfer to spend our own tokens")
+		}
+		if from.Equal(target) {
+			return nil, nil, xerrors.New("cannot send tokens to the same account")
+		}
+		var fromAccount TokenAccountData
+		var fromDarc darc.ID
+		fromAccount, fromDarc, err = loadTokenAccount(rst, from, c.TokenID)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("from: %v", err)
+		}
+		allowance := fromAccount.Allowance(inst.InstanceID)
+		if allowance < amount {
+			return nil, nil, xerrors.Errorf("allowance of %d is too small",
+				allowance)
+		}
+		if fromAccount.Balance < amount {
+			return nil, nil, xerrors.New("not enough tokens")
+		}
+		fromAccount.setAllowance(inst.InstanceID, allowance-amount)
+		fromAccount.Balance -= amount
+		var fromBuf []byte
+		fromBuf, err = protobuf.Encode(&fromAccount)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't encode account: %v", err)
+		}
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Update, from,
+			ContractTokenAccountID, fromBuf, fromDarc))
+
+		if inst.InstanceID.Equal(target) {
+			// As the tokens come from another account, the balance of
+			// the spender cannot overflow.
+			c.Balance += amount
+		} else {
+			var targetSc byzcoin.StateChange
+			targetSc, err = creditTokenAccount(rst, target, c.TokenID, amount)
+			if err != nil {
+				return nil, nil, xerrors.Errorf("destination: %v", err)
+			}
+			sc = append(sc, targetSc)
+		}
+		log.Lvlf2("transferring %d tokens from %x to %x", amount,
+			from.Slice(), target.Slice())
+	default:
+		return nil, nil, xerrors.New("token account contract can only " +
+			"transfer, approve and transferFrom")
+	}
+
+	var accountBuf []byte
+	accountBuf, err = protobuf.Encode(&c.TokenAccountData)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode account: %v", err)
+	}
+	sc = append(sc, byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID,
+		ContractTokenAccountID, accountBuf, darcID))
+	return
+}
+
+func (c *contractTokenAccount) Delete(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return
+	}
+
+	if c.Balance > 0 {
+		return nil, nil, xerrors.New("cannot delete an account that still has tokens in it")
+	}
+	sc = byzcoin.StateChanges{
+		byzcoin.NewStateChange(byzcoin.Remove, inst.InstanceID, ContractTokenAccountID, nil, darcID),
+	}
+	return
+}
+
+// loadTokenAccount returns the account stored in id and its darc, and makes
+// sure that it holds tokens of tokenID.
+func loadTokenAccount(rst byzcoin.ReadOnlyStateTrie, id byzcoin.InstanceID,
+	tokenID byzcoin.InstanceID) (account TokenAccountData, did darc.ID, err error) {
+	var v []byte
+	var cid string
+	v, _, cid, did, err = rst.GetValues(id.Slice())
+	if err == nil && cid != ContractTokenAccountID {
+		err = xerrors.New("not a token account")
+	}
+	if err != nil {
+		return
+	}
+	err = protobuf.Decode(v, &account)
+	if err != nil {
+		err = xerrors.Errorf("couldn't unmarshal account: %v", err)
+		return
+	}
+	if !account.TokenID.Equal(tokenID) {
+		err = xerrors.New("account holds another token")
+	}
+	return
+}
+
+// creditTokenAccount returns the state change adding amount tokens to the
+// account stored in id.
+func creditTokenAccount(rst byzcoin.ReadOnlyStateTrie, id byzcoin.InstanceID,
+	tokenID byzcoin.InstanceID, amount uint64) (sc byzcoin.StateChange, err error) {
+	account, did, err := loadTokenAccount(rst, id, tokenID)
+	if err != nil {
+		return
+	}
+	// The total supply is capped by an uint64, so the balance cannot
+	// overflow.
+	account.Balance += amount
+	buf, err := protobuf.Encode(&account)
+	if err != nil {
+		err = xerrors.Errorf("couldn't encode account: %v", err)
+		return
+	}
+	sc = byzcoin.NewStateChange(byzcoin.Update, id, ContractTokenAccountID,
+		buf, did)
+	return
+}
+
+// tokenAmountArg returns the "amount" argument, which is required.
+func tokenAmountArg(args byzcoin.Arguments) (uint64, error) {
+	if args.Search("amount") == nil {
+		return 0, xerrors.New("argument \"amount\" is missing")
+	}
+	return tokenUintArg(args, "amount", 0)
+}
+
+// tokenUintArg returns the argument name as an uint64, or def if it is
+// missing.
+func tokenUintArg(args byzcoin.Arguments, name string, def uint64) (uint64, error) {
+	buf := args.Search(name)
+	if buf == nil {
+		return def, nil
+	}
+	if len(buf) != 8 {
+		return 0, xerrors.Errorf("argument \"%s\" is wrong length", name)
+	}
+	return binary.LittleEndian.Uint64(buf), nil
+}
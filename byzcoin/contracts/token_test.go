@@ -0,0 +1,221 @@
+package contracts
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/protobuf"
+)
+
+func tokenUint(v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, v)
+	return buf
+}
+
+// storeScs applies the state changes to the mock trie.
+func (ct *cvTest) storeScs(scs []byzcoin.StateChange) {
+	for _, sc := range scs {
+		ct.Store(byzcoin.NewInstanceID(sc.InstanceID), sc.Value, sc.ContractID,
+			sc.DarcID)
+	}
+}
+
+func (ct *cvTest) tokenAccount(t *testing.T, id byzcoin.InstanceID) TokenAccountData {
+	var account TokenAccountData
+	require.NoError(t, protobuf.Decode(ct.values[string(id.Slice())], &account))
+	return account
+}
+
+func (ct *cvTest) invokeToken(id byzcoin.InstanceID, cmd string, args byzcoin.Arguments) ([]byzcoin.StateChange, error) {
+	k := string(id.Slice())
+	var c byzcoin.Contract
+	var err error
+	if ct.contractIDs[k] == ContractTokenID {
+		c, err = contractTokenFromBytes(ct.values[k])
+	} else {
+		c, err = contractTokenAccountFromBytes(ct.values[k])
+	}
+	if err != nil {
+		return nil, err
+	}
+	inst := byzcoin.Instruction{
+		InstanceID: id,
+		Invoke: &byzcoin.Invoke{
+			Command: cmd,
+			Args:    args,
+		},
+	}
+	sc, _, err := c.Invoke(ct, inst, nil)
+	if err == nil {
+		ct.storeScs(sc)
+	}
+	return sc, err
+}
+
+func TestToken_SpawnMint(t *testing.T) {
+	ct := newCT(t)
+	darcID := gdarc.GetBaseID()
+
+	inst := byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(darcID),
+		Spawn: &byzcoin.Spawn{
+			ContractID: ContractTokenID,
+			Args: byzcoin.Arguments{
+				{Name: "name", Value: []byte("Loyalty points")},
+				{Name: "symbol", Value: []byte("LP")},
+				{Name: "decimals", Value: tokenUint(2)},
+				{Name: "supplyCap", Value: tokenUint(1000)},
+				{Name: "initialSupply", Value: tokenUint(100)},
+			},
+		},
+	}
+	c, err := contractTokenFromBytes(nil)
+	require.NoError(t, err)
+	sc, _, err := c.Spawn(ct, inst, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(sc))
+	ct.storeScs(sc)
+
+	tokenID := inst.DeriveID("")
+	var token TokenData
+	require.NoError(t, protobuf.Decode(sc[0].Value, &token))
+	require.Equal(t, TokenData{Name: "Loyalty points", Symbol: "LP",
+		Decimals: 2, SupplyCap: 1000, TotalSupply: 100}, token)
+	owner := TokenAccountID(tokenID, darcID)
+	require.Equal(t, owner.Slice(), sc[1].InstanceID)
+	require.Equal(t, uint64(100), ct.tokenAccount(t, owner).Balance)
+
+	// The initial supply cannot be bigger than the cap.
+	inst.Spawn.Args[4].Value = tokenUint(1001)
+	c, err = contractTokenFromBytes(nil)
+	require.NoError(t, err)
+	_, _, err = c.Spawn(ct, inst, nil)
+	require.Error(t, err)
+
+	// Spawn a second account and mint into it.
+	other := darc.ID(iid("other darc").Slice())
+	inst = byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(darcID),
+		Spawn: &byzcoin.Spawn{
+			ContractID: ContractTokenAccountID,
+			Args: byzcoin.Arguments{
+				{Name: "tokenID", Value: tokenID.Slice()},
+				{Name: "darcID", Value: other},
+			},
+		},
+	}
+	c, err = contractTokenAccountFromBytes(nil)
+	require.NoError(t, err)
+	sc, _, err = c.Spawn(ct, inst, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(sc))
+	ct.storeScs(sc)
+	account := TokenAccountID(tokenID, other)
+	require.Equal(t, account.Slice(), sc[0].InstanceID)
+	require.Equal(t, other, sc[0].DarcID)
+
+	_, err = ct.invokeToken(tokenID, "mint", byzcoin.Arguments{
+		{Name: "amount", Value: tokenUint(900)},
+		{Name: "destination", Value: account.Slice()},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(900), ct.tokenAccount(t, account).Balance)
+
+	_, err = ct.invokeToken(tokenID, "mint", byzcoin.Arguments{
+		{Name: "amount", Value: tokenUint(1)},
+		{Name: "destination", Value: account.Slice()},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "supply cap")
+
+	// The account must be of a token.
+	inst.Spawn.Args[0].Value = darcID
+	c, err = contractTokenAccountFromBytes(nil)
+	require.NoError(t, err)
+	_, _, err = c.Spawn(ct, inst, nil)
+	require.Error(t, err)
+}
+
+func TestToken_TransferAllowance(t *testing.T) {
+	ct := newCT(t)
+	tokenID := iid("token")
+	tokenBuf, err := protobuf.Encode(&TokenData{Name: "Token", Symbol: "T",
+		SupplyCap: 100, TotalSupply: 100})
+	require.NoError(t, err)
+	ct.Store(tokenID, tokenBuf, ContractTokenID, gdarc.GetBaseID())
+
+	var accounts []byzcoin.InstanceID
+	for i, balance := range []uint64{100, 0, 0} {
+		id := iid(string(rune('a' + i)))
+		buf, err := protobuf.Encode(&TokenAccountData{TokenID: tokenID,
+			Balance: balance})
+		require.NoError(t, err)
+		ct.Store(id, buf, ContractTokenAccountID, gdarc.GetBaseID())
+		accounts = append(accounts, id)
+	}
+	owner, spender, dest := accounts[0], accounts[1], accounts[2]
+
+	_, err = ct.invokeToken(owner, "transfer", byzcoin.Arguments{
+		{Name: "amount", Value: tokenUint(101)},
+		{Name: "destination", Value: dest.Slice()},
+	})
+	require.Error(t, err)
+	_, err = ct.invokeToken(owner, "transfer", byzcoin.Arguments{
+		{Name: "amount", Value: tokenUint(10)},
+		{Name: "destination", Value: dest.Slice()},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(90), ct.tokenAccount(t, owner).Balance)
+	require.Equal(t, uint64(10), ct.tokenAccount(t, dest).Balance)
+
+	// Without allowance, the spender cannot take any tokens.
+	transferFrom := func(amount uint64, to byzcoin.InstanceID) error {
+		_, err := ct.invokeToken(spender, "transferFrom", byzcoin.Arguments{
+			{Name: "amount", Value: tokenUint(amount)},
+			{Name: "from", Value: owner.Slice()},
+			{Name: "destination", Value: to.Slice()},
+		})
+		return err
+	}
+	require.Error(t, transferFrom(1, dest))
+
+	_, err = ct.invokeToken(owner, "approve", byzcoin.Arguments{
+		{Name: "amount", Value: tokenUint(30)},
+		{Name: "spender", Value: spender.Slice()},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(30), ct.tokenAccount(t, owner).Allowance(spender))
+
+	require.NoError(t, transferFrom(20, dest))
+	require.NoError(t, transferFrom(5, spender))
+	require.Error(t, transferFrom(6, dest))
+	require.Equal(t, uint64(65), ct.tokenAccount(t, owner).Balance)
+	require.Equal(t, uint64(5), ct.tokenAccount(t, owner).Allowance(spender))
+	require.Equal(t, uint64(5), ct.tokenAccount(t, spender).Balance)
+	require.Equal(t, uint64(30), ct.tokenAccount(t, dest).Balance)
+
+	// Approving 0 removes the allowance.
+	_, err = ct.invokeToken(owner, "approve", byzcoin.Arguments{
+		{Name: "amount", Value: tokenUint(0)},
+		{Name: "spender", Value: spender.Slice()},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(ct.tokenAccount(t, owner).Allowances))
+	require.Error(t, transferFrom(1, dest))
+
+	// Accounts of other tokens are refused.
+	otherBuf, err := protobuf.Encode(&TokenAccountData{TokenID: iid("other")})
+	require.NoError(t, err)
+	ct.Store(iid("other account"), otherBuf, ContractTokenAccountID,
+		gdarc.GetBaseID())
+	_, err = ct.invokeToken(owner, "transfer", byzcoin.Arguments{
+		{Name: "amount", Value: tokenUint(1)},
+		{Name: "destination", Value: iid("other account").Slice()},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "another token")
+}
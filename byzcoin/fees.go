@@ -0,0 +1,262 @@
+package byzcoin
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// Fee returns the total fee for a transaction executing the given
+// instructions.
+func (fs FeeSchedule) Fee(instrs Instructions) (uint64, error) {
+	fee := Coin{Value: fs.TransactionFee}
+	for _, instr := range instrs {
+		if err := fee.SafeAdd(fs.instructionFee(instr.ContractID())); err != nil {
+			return 0, xerrors.Errorf("computing fee: %v", err)
+		}
+	}
+	return fee.Value, nil
+}
+
+func (fs FeeSchedule) instructionFee(contractID string) uint64 {
+	for _, cf := range fs.ContractFees {
+		if cf.ContractID == contractID {
+			return cf.Fee
+		}
+	}
+	return fs.InstructionFee
+}
+
+// payout returns the account receiving the fees of the given leader, or nil
+// if the fees are burnt.
+func (fs FeeSchedule) payout(leader *network.ServerIdentity) *InstanceID {
+	for _, p := range fs.Payouts {
+		if p.Public != nil && p.Public.Equal(leader.Public) {
+			account := p.Account
+			return &account
+		}
+	}
+	return nil
+}
+
+func (fs FeeSchedule) sanityCheck() error {
+	if fs.CoinName.Equal(InstanceID{}) {
+		return xerrors.New("fee schedule needs a coin name")
+	}
+	contracts := make(map[string]bool)
+	for _, cf := range fs.ContractFees {
+		if contracts[cf.ContractID] {
+			return xerrors.Errorf("contract %s has more than one fee",
+				cf.ContractID)
+		}
+		contracts[cf.ContractID] = true
+	}
+	for i, p := range fs.Payouts {
+		if p.Public == nil {
+			return xerrors.New("fee payout without public key")
+		}
+		for _, p2 := range fs.Payouts[i+1:] {
+			if p.Public.Equal(p2.Public) {
+				return xerrors.Errorf("node %s has more than one payout",
+					p.Public)
+			}
+		}
+	}
+	return nil
+}
+
+// feeExemptCommands holds the commands that are sent without signers, and so
+// cannot pay fees. The key is the contract ID and the command.
+var feeExemptCommands = struct {
+	sync.Mutex
+	commands map[[2]string]bool
+}{commands: make(map[[2]string]bool)}
+
+// RegisterFeeExemptCommand makes the invoke command of the contract free of
+// fees. It is meant for commands that are sent by services, without a signer
+// that could pay the fees, e.g., the release of a calypso secret. The
+// contract must make sure that such a command can only succeed for a good
+// reason, as it can be sent by anybody. It should be called during module
+// initialization, like RegisterGlobalContract.
+func RegisterFeeExemptCommand(contractID, command string) {
+	feeExemptCommands.Lock()
+	defer feeExemptCommands.Unlock()
+	feeExemptCommands.commands[[2]string{contractID, command}] = true
+}
+
+// isFeeExempt returns true if all instructions of the transaction are sent to
+// the config instance, or are commands registered with
+// RegisterFeeExemptCommand. This makes sure that view-changes and
+// configuration updates, e.g. to fix a wrong fee schedule, are always
+// possible. The contract of an instance is taken from the trie, so that
+// other contracts cannot pretend to be exempt. A transaction without
+// instructions is not exempt.
+func isFeeExempt(rst ReadOnlyStateTrie, tx ClientTransaction) bool {
+	if len(tx.Instructions) == 0 {
+		return false
+	}
+	feeExemptCommands.Lock()
+	defer feeExemptCommands.Unlock()
+	for _, instr := range tx.Instructions {
+		if instr.InstanceID.Equal(ConfigInstanceID) {
+			continue
+		}
+		if instr.Invoke == nil {
+			return false
+		}
+		_, _, cid, _, err := rst.GetValues(instr.InstanceID.Slice())
+		if err != nil || !feeExemptCommands.commands[[2]string{cid, instr.Invoke.Command}] {
+			return false
+		}
+	}
+	return true
+}
+
+// chargeFees takes the fees for the instructions of the transaction from its
+// fee payer and gives them to the payout account of the current leader.
+// ctxHash is the digest signed by the instructions, as returned by
+// ClientTransaction.Hash. The resulting state changes are already stored in
+// sst.
+func chargeFees(sst *stagingStateTrie, fs FeeSchedule, leader *network.ServerIdentity,
+	tx ClientTransaction, ctxHash []byte) (StateChanges, error) {
+	fee, err := fs.Fee(tx.Instructions)
+	if err != nil {
+		return nil, err
+	}
+	if tx.FeePayer == nil {
+		return nil, xerrors.New("transaction has no fee payer")
+	}
+	if len(tx.Instructions) == 0 {
+		return nil, xerrors.New("transaction has no instructions")
+	}
+	if tx.Instructions[0].version < VersionFeePayer {
+		return nil, xerrors.Errorf("fees need at least block version %d",
+			VersionFeePayer)
+	}
+	if err := verifyFeePayer(sst, *tx.FeePayer, tx.Instructions, ctxHash); err != nil {
+		return nil, xerrors.Errorf("fee payer: %v", err)
+	}
+
+	if fee == 0 {
+		return nil, nil
+	}
+
+	scPayer, err := updateFeeAccount(sst, *tx.FeePayer, fs.CoinName,
+		func(c *Coin) error {
+			if err := c.SafeSub(fee); err != nil {
+				return xerrors.Errorf("not enough coins to pay fee of %d: %v",
+					fee, err)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	scs := StateChanges{*scPayer}
+
+	account := fs.payout(leader)
+	if account == nil {
+		log.Lvlf3("burning fee of %d as %s has no payout account", fee, leader)
+		return scs, nil
+	}
+	scLeader, err := updateFeeAccount(sst, *account, fs.CoinName,
+		func(c *Coin) error {
+			return c.SafeAdd(fee)
+		})
+	if err != nil {
+		// Refusing the transaction because the leader's account is
+		// misconfigured would let one node block the chain.
+		log.Warnf("burning fee of %d as payout of %s failed: %v", fee,
+			leader, err)
+		return scs, nil
+	}
+	return append(scs, *scLeader), nil
+}
+
+// verifyFeePayer checks that the signers of the transaction are allowed to
+// transfer coins from the fee payer.
+func verifyFeePayer(sst *stagingStateTrie, payer InstanceID,
+	instrs Instructions, ctxHash []byte) error {
+	_, _, contractID, darcID, err := GetValueContract(sst, payer.Slice())
+	if err != nil {
+		return xerrors.Errorf("reading trie: %v", err)
+	}
+	d, err := sst.LoadDarc(darcID)
+	if err != nil {
+		return xerrors.Errorf("loading darc: %v", err)
+	}
+	action := darc.Action("invoke:" + contractID + ".transfer")
+	expr := d.Rules.Get(action)
+	if len(expr) == 0 {
+		return xerrors.Errorf("action '%v' does not exist", action)
+	}
+
+	var goodIdentities []string
+	known := make(map[string]bool)
+	for _, instr := range instrs {
+		for i := range instr.Signatures {
+			id := instr.SignerIdentities[i].String()
+			if known[id] {
+				continue
+			}
+			if instr.SignerIdentities[i].Verify(ctxHash, instr.Signatures[i]) == nil {
+				known[id] = true
+				goodIdentities = append(goodIdentities, id)
+			}
+		}
+	}
+
+	getDarc := func(str string, latest bool) *darc.Darc {
+		if len(str) < 5 || string(str[0:5]) != "darc:" {
+			return nil
+		}
+		darcID, err := hex.DecodeString(str[5:])
+		if err != nil {
+			return nil
+		}
+		d, err := sst.LoadDarc(darcID)
+		if err != nil {
+			return nil
+		}
+		return d
+	}
+	err = darc.EvalExpr(expr, getDarc, goodIdentities...)
+	return cothority.ErrorOrNil(err, "darc evaluation")
+}
+
+// updateFeeAccount applies f to the coin stored in account and stores the
+// result in sst. It returns the corresponding state change.
+func updateFeeAccount(sst *stagingStateTrie, account InstanceID,
+	coinName InstanceID, f func(*Coin) error) (*StateChange, error) {
+	buf, version, contractID, darcID, err := GetValueContract(sst, account.Slice())
+	if err != nil {
+		return nil, xerrors.Errorf("reading account %s: %v", account, err)
+	}
+	var c Coin
+	if err := protobuf.Decode(buf, &c); err != nil {
+		return nil, xerrors.Errorf("account %s is not a coin: %v", account, err)
+	}
+	if !c.Name.Equal(coinName) {
+		return nil, xerrors.Errorf("account %s holds the wrong type of coins",
+			account)
+	}
+	if err := f(&c); err != nil {
+		return nil, err
+	}
+	buf, err = protobuf.Encode(&c)
+	if err != nil {
+		return nil, xerrors.Errorf("encoding coin: %v", err)
+	}
+	sc := NewStateChange(Update, account, contractID, buf, darcID)
+	sc.Version = version + 1
+	if err := sst.StoreAll(StateChanges{sc}); err != nil {
+		return nil, xerrors.Errorf("storing fee: %v", err)
+	}
+	return &sc, nil
+}
@@ -0,0 +1,242 @@
+package byzcoin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/darc/expression"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+)
+
+var feeCoinName = NewInstanceID([]byte("feeCoin"))
+
+func TestFeeSchedule_Fee(t *testing.T) {
+	fs := FeeSchedule{
+		CoinName:       feeCoinName,
+		TransactionFee: 10,
+		InstructionFee: 2,
+		ContractFees:   []ContractFee{{ContractID: "expensive", Fee: 100}},
+	}
+	instrs := Instructions{
+		{Invoke: &Invoke{ContractID: "cheap"}},
+		{Spawn: &Spawn{ContractID: "expensive"}},
+		{Delete: &Delete{ContractID: "cheap"}},
+	}
+	fee, err := fs.Fee(instrs)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10+2+100+2), fee)
+
+	fee, err = fs.Fee(nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), fee)
+
+	fs.InstructionFee = ^uint64(0)
+	_, err = fs.Fee(instrs)
+	require.Error(t, err)
+}
+
+func TestFeeSchedule_SanityCheck(t *testing.T) {
+	fs := FeeSchedule{}
+	require.Error(t, fs.sanityCheck())
+
+	fs.CoinName = feeCoinName
+	require.NoError(t, fs.sanityCheck())
+
+	fs.ContractFees = []ContractFee{{"a", 1}, {"a", 2}}
+	require.Error(t, fs.sanityCheck())
+	fs.ContractFees = []ContractFee{{"a", 1}, {"b", 2}}
+	require.NoError(t, fs.sanityCheck())
+
+	pub := key.NewKeyPair(cothority.Suite).Public
+	fs.Payouts = []FeePayout{{Public: pub}, {Public: pub}}
+	require.Error(t, fs.sanityCheck())
+	fs.Payouts = []FeePayout{{}}
+	require.Error(t, fs.sanityCheck())
+}
+
+type feeTest struct {
+	sst     *stagingStateTrie
+	signer  darc.Signer
+	leader  *network.ServerIdentity
+	payer   InstanceID
+	account InstanceID
+	fs      FeeSchedule
+}
+
+func newFeeTest(t *testing.T) *feeTest {
+	sst, err := newMemStagingStateTrie([]byte("nonce"))
+	require.NoError(t, err)
+	ft := &feeTest{
+		sst:     sst,
+		signer:  darc.NewSignerEd25519(nil, nil),
+		payer:   NewInstanceID([]byte("payer")),
+		account: NewInstanceID([]byte("account")),
+	}
+
+	var sis []*network.ServerIdentity
+	for i := 0; i < 3; i++ {
+		kp := key.NewKeyPair(cothority.Suite)
+		sis = append(sis, network.NewServerIdentity(kp.Public,
+			network.NewAddress(network.TLS, "127.0.0.1:2000")))
+	}
+	ft.leader = sis[0]
+	ft.fs = FeeSchedule{
+		CoinName:       feeCoinName,
+		TransactionFee: 10,
+		InstructionFee: 1,
+		Payouts:        []FeePayout{{Public: ft.leader.Public, Account: ft.account}},
+	}
+	config := ChainConfig{
+		Roster:          *onet.NewRoster(sis),
+		DarcContractIDs: []string{ContractDarcID},
+		FeeSchedule:     &ft.fs,
+	}
+	configBuf, err := protobuf.Encode(&config)
+	require.NoError(t, err)
+
+	id := ft.signer.Identity()
+	d := darc.NewDarc(darc.InitRules([]darc.Identity{id},
+		[]darc.Identity{id}), []byte("fees"))
+	require.NoError(t, d.Rules.AddRule("invoke:coin.transfer",
+		expression.Expr(id.String())))
+	darcBuf, err := d.ToProto()
+	require.NoError(t, err)
+
+	require.NoError(t, sst.StoreAll(StateChanges{
+		NewStateChange(Create, ConfigInstanceID, ContractConfigID, configBuf,
+			d.GetBaseID()),
+		NewStateChange(Create, NewInstanceID(d.GetBaseID()), ContractDarcID,
+			darcBuf, d.GetBaseID()),
+		NewStateChange(Create, ft.payer, "coin",
+			ft.coinBuf(t, feeCoinName, 100), d.GetBaseID()),
+		NewStateChange(Create, ft.account, "coin",
+			ft.coinBuf(t, feeCoinName, 0), d.GetBaseID()),
+	}))
+	return ft
+}
+
+func (ft *feeTest) coinBuf(t *testing.T, name InstanceID, value uint64) []byte {
+	buf, err := protobuf.Encode(&Coin{Name: name, Value: value})
+	require.NoError(t, err)
+	return buf
+}
+
+func (ft *feeTest) coinValue(t *testing.T, id InstanceID) uint64 {
+	buf, _, _, _, err := ft.sst.GetValues(id.Slice())
+	require.NoError(t, err)
+	var c Coin
+	require.NoError(t, protobuf.Decode(buf, &c))
+	return c.Value
+}
+
+func (ft *feeTest) createTx(t *testing.T, n int, signer darc.Signer) ClientTransaction {
+	var instrs Instructions
+	for i := 0; i < n; i++ {
+		instrs = append(instrs, Instruction{
+			InstanceID:    NewInstanceID([]byte{byte(i)}),
+			Invoke:        &Invoke{ContractID: "dummy", Command: "update"},
+			SignerCounter: []uint64{uint64(i + 1)},
+		})
+	}
+	ctx := NewClientTransaction(CurrentVersion, instrs...)
+	ctx.FeePayer = &ft.payer
+	require.NoError(t, ctx.FillSignersAndSignWith(signer))
+	return ctx
+}
+
+func (ft *feeTest) charge(tx ClientTransaction) (StateChanges, error) {
+	return chargeFees(ft.sst, ft.fs, ft.leader, tx, tx.Hash())
+}
+
+func TestChargeFees(t *testing.T) {
+	ft := newFeeTest(t)
+
+	scs, err := ft.charge(ft.createTx(t, 2, ft.signer))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(scs))
+	require.Equal(t, uint64(1), scs[0].Version)
+	require.Equal(t, uint64(88), ft.coinValue(t, ft.payer))
+	require.Equal(t, uint64(12), ft.coinValue(t, ft.account))
+
+	// Not enough coins left
+	_, err = ft.charge(ft.createTx(t, 80, ft.signer))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not enough coins")
+	require.Equal(t, uint64(88), ft.coinValue(t, ft.payer))
+
+	// No fee payer
+	tx := ft.createTx(t, 1, ft.signer)
+	tx.FeePayer = nil
+	_, err = ft.charge(tx)
+	require.Error(t, err)
+
+	// The fee payer is signed
+	tx = ft.createTx(t, 1, ft.signer)
+	tx.FeePayer = &ft.account
+	_, err = ft.charge(tx)
+	require.Error(t, err)
+	require.Equal(t, uint64(88), ft.coinValue(t, ft.payer))
+
+	// Blocks before VersionFeePayer don't sign the fee payer
+	tx = ft.createTx(t, 1, ft.signer)
+	tx.Instructions.SetVersion(VersionRollup)
+	_, err = ft.charge(tx)
+	require.Error(t, err)
+
+	// The signers are not allowed to use the payer
+	_, err = ft.charge(ft.createTx(t, 1, darc.NewSignerEd25519(nil, nil)))
+	require.Error(t, err)
+	require.Equal(t, uint64(88), ft.coinValue(t, ft.payer))
+
+	// A leader without payout burns the fees
+	ft.fs.Payouts = nil
+	scs, err = ft.charge(ft.createTx(t, 1, ft.signer))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(scs))
+	require.Equal(t, uint64(77), ft.coinValue(t, ft.payer))
+	require.Equal(t, uint64(12), ft.coinValue(t, ft.account))
+
+	// Fees in the wrong type of coin are refused
+	ft.fs.CoinName = NewInstanceID([]byte("otherCoin"))
+	_, err = ft.charge(ft.createTx(t, 1, ft.signer))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "wrong type of coins")
+}
+
+func TestIsFeeExempt(t *testing.T) {
+	sst, err := newMemStagingStateTrie([]byte("nonce"))
+	require.NoError(t, err)
+	exempt := NewInstanceID([]byte("exempt"))
+	require.NoError(t, sst.StoreAll(StateChanges{
+		NewStateChange(Create, exempt, "exemptContract", nil, nil),
+	}))
+	RegisterFeeExemptCommand("exemptContract", "free")
+
+	tx := ClientTransaction{}
+	require.False(t, isFeeExempt(sst, tx))
+	tx.Instructions = Instructions{{InstanceID: ConfigInstanceID}}
+	require.True(t, isFeeExempt(sst, tx))
+	tx.Instructions = append(tx.Instructions,
+		Instruction{InstanceID: NewInstanceID([]byte("other"))})
+	require.False(t, isFeeExempt(sst, tx))
+
+	// Only the registered command of the contract stored in the trie is
+	// exempt.
+	free := Instruction{InstanceID: exempt,
+		Invoke: &Invoke{ContractID: "exemptContract", Command: "free"}}
+	tx.Instructions = Instructions{free}
+	require.True(t, isFeeExempt(sst, tx))
+	other := free
+	other.Invoke = &Invoke{ContractID: "exemptContract", Command: "other"}
+	tx.Instructions = Instructions{other}
+	require.False(t, isFeeExempt(sst, tx))
+	other = free
+	other.InstanceID = NewInstanceID([]byte("other"))
+	tx.Instructions = Instructions{other}
+	require.False(t, isFeeExempt(sst, tx))
+}
@@ -24,7 +24,7 @@ func init() {
 type Version int
 
 // CurrentVersion is what we're running now
-const CurrentVersion Version = VersionRollup
+const CurrentVersion Version = VersionFeePayer
 
 const (
 	// VersionInstructionHash is the first version and indicates that a new,
@@ -44,4 +44,7 @@ const (
 	// VersionRollup indicates that the followers send their transactions to
 	// the leader, instead of polling by the leader.
 	VersionRollup = 7
+	// VersionFeePayer adds the fee payer of a transaction to the digest its
+	// instructions sign and to the hash of the transactions of a block.
+	VersionFeePayer = 8
 )
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/byzcoin/trie"
 	"go.dedis.ch/cothority/v3/byzcoinx"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
@@ -95,7 +96,7 @@ func createSC(t *testing.T) (s sc) {
 		return err
 	})
 	require.NoError(t, err)
-	s.c, err = newStateTrie(db, bucketName, []byte("nonce string"))
+	s.c, err = newStateTrie(trie.NewDiskDB(db, bucketName), []byte("nonce string"))
 	require.NoError(t, err)
 
 	s.key = []byte("key")
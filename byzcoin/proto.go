@@ -6,6 +6,7 @@ import (
 	"go.dedis.ch/cothority/v3/byzcoin/trie"
 	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/onet/v3"
 )
 
@@ -20,6 +21,7 @@ import (
 // type :InstanceID:bytes
 // type :Version:sint32
 // type :GetUpdatesFlags:uint64
+// type :TxStatus:sint32
 // import "skipchain.proto";
 // import "onet.proto";
 // import "darc.proto";
@@ -144,6 +146,21 @@ type GetProofResponse struct {
 	Proof Proof
 }
 
+// GetProofAt is the request to get a proof of a key as it was stored in the
+// global state right after the block with index BlockIndex had been applied.
+type GetProofAt struct {
+	// Version of the protocol
+	Version Version
+	// Key is the key we want to look up
+	Key []byte
+	// ID is any block that is known to us in the skipchain, can be the genesis
+	// block or any later block up to BlockIndex. The proof returned will be
+	// starting at this block.
+	ID skipchain.SkipBlockID
+	// BlockIndex is the index of the block whose state is proven.
+	BlockIndex int
+}
+
 // CheckAuthorization returns the list of actions that could be executed if the
 // signatures of the given identities are present and valid
 type CheckAuthorization struct {
@@ -171,6 +188,48 @@ type ChainConfig struct {
 	Roster          onet.Roster
 	MaxBlockSize    int
 	DarcContractIDs []string
+	// FeeSchedule, if present, makes every transaction pay a fee.
+	FeeSchedule *FeeSchedule `protobuf:"opt"`
+	// SnapshotInterval, if bigger than 0, makes every node keep a copy of
+	// the global state of every block whose index is a multiple of
+	// SnapshotInterval. New nodes can download such a snapshot, nodes can
+	// prune the blocks before it, and the past states are replayed from
+	// it. It cannot be bigger than 1000, the number of blocks replayed to
+	// prove a past state.
+	SnapshotInterval int `protobuf:"opt"`
+}
+
+// FeeSchedule defines the fees that are charged for every accepted
+// transaction. The fees are taken from the FeePayer of the ClientTransaction
+// and are paid out to the leader that created the block.
+type FeeSchedule struct {
+	// CoinName is the type of coin the fees are paid in, e.g.
+	// contracts.CoinName.
+	CoinName InstanceID
+	// TransactionFee is charged once for every transaction.
+	TransactionFee uint64
+	// InstructionFee is charged for every executed instruction, unless the
+	// contract of the instruction has an entry in ContractFees.
+	InstructionFee uint64
+	// ContractFees overrides the InstructionFee for the given contracts.
+	ContractFees []ContractFee
+	// Payouts holds the coin instances receiving the fees of the blocks
+	// created by a given node. The fees of a leader without a payout are
+	// burnt.
+	Payouts []FeePayout
+}
+
+// ContractFee is the fee charged for every instruction of a given contract.
+type ContractFee struct {
+	ContractID string
+	Fee        uint64
+}
+
+// FeePayout links a node, identified by its public key, to the coin instance
+// receiving the fees of the blocks it leads.
+type FeePayout struct {
+	Public  kyber.Point
+	Account InstanceID
 }
 
 // Proof represents everything necessary to verify a given
@@ -268,6 +327,11 @@ type Argument struct {
 // every instruction must sign for the transaction to be valid.
 type ClientTransaction struct {
 	Instructions Instructions
+	// FeePayer is the coin instance the fees are taken from if the
+	// ChainConfig holds a FeeSchedule. The signers of the transaction must
+	// be allowed to invoke "transfer" on the FeePayer. Starting with
+	// VersionFeePayer, the instructions sign the FeePayer too.
+	FeePayer *InstanceID `protobuf:"opt"`
 }
 
 // TxResult holds a transaction and the result of running it.
@@ -312,6 +376,44 @@ type StreamingResponse struct {
 	Block *skipchain.SkipBlock
 }
 
+// StreamingFilter selects the state changes sent to a subscriber. A state
+// change matches if it matches all non-empty fields of the filter, and it
+// matches a field if it matches any of the values of the field. An empty
+// filter matches all state changes.
+type StreamingFilter struct {
+	// ContractIDs matches the contract of the changed instance.
+	ContractIDs []string
+	// InstanceIDs matches the changed instance.
+	InstanceIDs []InstanceID
+	// Commands matches the state changes of the instances that have been
+	// invoked with one of the given commands in the same block.
+	Commands []string
+	// DarcIDs matches the darc controlling the changed instance.
+	DarcIDs []darc.ID
+}
+
+// FilteredStreamingRequest subscribes to the state changes of the new blocks
+// of a chain that match the filter.
+type FilteredStreamingRequest struct {
+	ID     skipchain.SkipBlockID
+	Filter StreamingFilter
+}
+
+// FilteredStreamingResponse is streamed back to the client for every new
+// block with at least one matching state change.
+type FilteredStreamingResponse struct {
+	// Block is the new block, without its payload.
+	Block skipchain.SkipBlock
+	// Links prove that the block is part of the chain, like the Links of a
+	// Proof.
+	Links []skipchain.ForwardLink
+	// StateChanges are the state changes of the block matching the filter.
+	StateChanges []StateChange
+	// InclusionProofs holds one proof for every state change, showing the
+	// value of its instance after the block.
+	InclusionProofs []trie.Proof
+}
+
 // PaginateRequest is a request to get NumPages times the consecutive list of
 // PageSize blocks.
 type PaginateRequest struct {
@@ -360,6 +462,9 @@ type DownloadState struct {
 	Nonce uint64
 	// Length of the statechanges to download
 	Length int
+	// Snapshot requests the latest snapshot of the state instead of the
+	// current state. It is only used when starting a new download.
+	Snapshot bool `protobuf:"opt"`
 }
 
 // DownloadStateResponse is returned by the service. If there are no
@@ -511,6 +616,63 @@ type DebugRemoveRequest struct {
 	Signature []byte
 }
 
+// GetTxStatus asks a node what it knows about a transaction.
+type GetTxStatus struct {
+	SkipchainID skipchain.SkipBlockID
+	// TxHash is the hash of the instructions of the transaction, as returned
+	// by ClientTransaction.Instructions.Hash().
+	TxHash []byte
+}
+
+// GetTxStatusResponse holds the status of the transaction. BlockIndex and
+// Error are only set if the transaction has been included in a block.
+type GetTxStatusResponse struct {
+	Status     TxStatus
+	BlockIndex int
+	Error      string `protobuf:"opt"`
+}
+
+// ListPendingTxs asks the conode for the transactions it received for the
+// given byzcoin-instance that are not yet included in a block.
+// It needs to be signed by the private key of the conode.
+type ListPendingTxs struct {
+	ByzCoinID []byte
+	Signature []byte
+}
+
+// ListPendingTxsResponse holds the pending transactions, in the order they
+// have been received.
+type ListPendingTxsResponse struct {
+	Transactions []ClientTransaction
+}
+
+// SimulateTransaction asks a node to execute a transaction against the
+// current state of the chain without storing the result.
+type SimulateTransaction struct {
+	SkipchainID skipchain.SkipBlockID
+	Transaction ClientTransaction
+}
+
+// SimulateTransactionResponse holds the result of a simulated transaction.
+// If the transaction would be refused, Accepted is false and Error holds the
+// reason. StateChanges holds all the changes of an accepted transaction,
+// including the signer counters and the fees.
+type SimulateTransactionResponse struct {
+	Accepted     bool
+	Error        string `protobuf:"opt"`
+	Instructions []SimulatedInstruction
+	StateChanges []StateChange
+}
+
+// SimulatedInstruction holds the outcome of one instruction of a simulated
+// transaction. The instructions generated by the contracts are included, too.
+type SimulatedInstruction struct {
+	Instruction  Instruction
+	StateChanges []StateChange
+	CoinsOut     []Coin
+	Error        string `protobuf:"opt"`
+}
+
 // IDVersion holds the InstanceID and the latest known version of an instance.
 type IDVersion struct {
 	ID      InstanceID
@@ -10,7 +10,9 @@ import (
 	"math"
 	"net"
 	"net/http"
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -114,6 +116,8 @@ type Service struct {
 	// responsible for, one for each skipchain.
 	stateTries     map[string]*stateTrie
 	stateTriesLock sync.Mutex
+	// stateStorage is the backend holding the state tries.
+	stateStorage stateStorage
 	// We need to store the state changes for keeping track
 	// of the history of an instance
 	stateChangeStorage *stateChangeStorage
@@ -155,10 +159,25 @@ type Service struct {
 	catchingUpHistoryLock sync.Mutex
 
 	downloadState downloadState
+	// snapshotLock makes sure that a snapshot is not read while it is
+	// replaced by a newer one.
+	snapshotLock sync.RWMutex
+	// snapshotsRunning holds the chains whose snapshot is being copied.
+	snapshotsRunning map[string]bool
+	snapshotsLock    sync.Mutex
+	// pruneBlocks is set if the payloads of the blocks older than the
+	// latest snapshot can be removed.
+	pruneBlocks bool
+	// keepSnapshots is the number of snapshots kept for every chain, or 0
+	// to keep all of them.
+	keepSnapshots int
+	// replayCache holds the states replayed by GetProofAt.
+	replayCache replayCache
 
 	rotationWindow time.Duration
 
 	txErrorBuf ringBuf
+	txStatus   txStatusBuf
 
 	// defaultVersion is the new version to use for new
 	// ByzCoin chains.
@@ -500,6 +519,8 @@ func (s *Service) AddTransaction(req *AddTxRequest) (*AddTxResponse, error) {
 		}
 	}
 
+	s.txStatus.setPending(req.SkipchainID, ctxHash, req.Transaction)
+
 	// Note to my future self: s.txBuffer.add used to be out here. It used to work
 	// even. But while investigating other race conditions, we realized that
 	// IF there will be a wait channel, THEN it must exist before the call to add().
@@ -589,6 +610,119 @@ func (s *Service) GetProof(req *GetProof) (*GetProofResponse, error) {
 	}, nil
 }
 
+// GetProofAt returns a proof for a key as it was stored in the global state
+// at the given block index. As only the latest state is stored, the state at
+// the given index is rebuilt by replaying the chain from the latest snapshot
+// before it or from the genesis block. At most maxReplayBlocks blocks are
+// replayed, only one state is replayed at a time, and the latest replayed
+// states are cached.
+func (s *Service) GetProofAt(req *GetProofAt) (*GetProofResponse, error) {
+	s.closedMutex.Lock()
+	closed := s.closed
+	s.closedMutex.Unlock()
+	if closed {
+		return nil, xerrors.New("cannot get proof while in closed state")
+	}
+
+	sb := s.db().GetByID(req.ID)
+	if sb == nil {
+		return nil, xerrors.New("cannot find skipblock while getting proof")
+	}
+	if req.BlockIndex < sb.Index {
+		return nil, xerrors.Errorf("block index %d is before the starting block %d",
+			req.BlockIndex, sb.Index)
+	}
+
+	st, err := s.getStateTrie(sb.SkipChainID())
+	if err != nil {
+		return nil, xerrors.Errorf("getting state trie: %v", err)
+	}
+	if req.BlockIndex > st.GetIndex() {
+		return nil, xerrors.Errorf("block index %d is not yet known, latest is %d",
+			req.BlockIndex, st.GetIndex())
+	}
+	if req.BlockIndex == st.GetIndex() {
+		return s.GetProof(&GetProof{
+			Version: req.Version,
+			Key:     req.Key,
+			ID:      req.ID,
+		})
+	}
+
+	replayed := s.replayCache.get(sb.SkipChainID(), req.BlockIndex)
+	if replayed == nil {
+		replayed, err = s.replayStateAt(sb.SkipChainID(), req.BlockIndex)
+		if err != nil {
+			return nil, err
+		}
+	}
+	proof, err := NewProof(replayed, s.db(), req.ID, req.Key)
+	if err != nil {
+		return nil, xerrors.Errorf("making proof: %v", err)
+	}
+	return &GetProofResponse{
+		Version: CurrentVersion,
+		Proof:   *proof,
+	}, nil
+}
+
+// replayStateAt rebuilds the state of the chain at the given index and adds
+// it to the replayCache.
+func (s *Service) replayStateAt(scID skipchain.SkipBlockID, index int) (*stateTrie, error) {
+	if !s.replayCache.start() {
+		return nil, xerrors.New("another state is being replayed, try again later")
+	}
+	defer s.replayCache.done()
+
+	start, startIndex, err := s.snapshotCopy(scID, index)
+	if err != nil {
+		return nil, xerrors.Errorf("getting snapshot: %v", err)
+	}
+	if start != nil && startIndex == index {
+		st, err := loadStateTrie(start)
+		if err != nil {
+			return nil, xerrors.Errorf("loading snapshot: %v", err)
+		}
+		s.replayCache.add(scID, index, st)
+		return st, nil
+	}
+	opt := ReplayStateOptions{MaxBlocks: index + 1}
+	if start != nil {
+		opt = ReplayStateOptions{MaxBlocks: index - startIndex,
+			StartingTrie: start}
+	}
+	if opt.MaxBlocks > maxReplayBlocks {
+		return nil, xerrors.Errorf("block index %d is more than %d blocks "+
+			"after the nearest snapshot", index, maxReplayBlocks)
+	}
+	// Pruning removes the blocks before the latest snapshot, so if the
+	// requested block is pruned, its state can only be found in the
+	// snapshots.
+	reply, err := s.skService().GetSingleBlockByIndex(
+		&skipchain.GetSingleBlockByIndex{Genesis: scID, Index: index})
+	if err != nil {
+		return nil, xerrors.Errorf("getting block %d: %v", index, err)
+	}
+	if isPruned(reply.SkipBlock) {
+		return nil, xerrors.Errorf("block %d has been pruned, only the states "+
+			"of the snapshots and from the latest snapshot on are available",
+			index)
+	}
+
+	log.Lvlf2("%s: replaying chain %x up to index %d", s.ServerIdentity(),
+		scID, index)
+	db, err := s.ReplayState(scID, silentReplayLog{}, opt)
+	if err != nil {
+		return nil, xerrors.Errorf("replaying state: %v", err)
+	}
+	st, err := loadStateTrie(db)
+	if err != nil {
+		return nil, xerrors.Errorf("loading replayed trie: %v", err)
+	}
+	s.replayCache.add(scID, index, st)
+	return st, nil
+}
+
 // CheckAuthorization verifies whether a given combination of identities can
 // fulfill a given rule of a given darc. Because all darcs are now used in
 // an online fashion, we need to offer this check.
@@ -740,13 +874,31 @@ func (s *Service) DownloadState(req *DownloadState) (resp *DownloadStateResponse
 		s.downloadState.stop = make(chan bool)
 		nonce := binary.LittleEndian.Uint64(random.Bits(64, true, random.New()))
 		s.downloadState.nonce = nonce
+		db := s.stateStorage.trieDB(req.ByzCoinID)
+		unlock := func() {}
+		if req.Snapshot {
+			// The lock is only held until the View started, as the
+			// View sees a consistent copy of the snapshot.
+			s.snapshotLock.RLock()
+			var once sync.Once
+			unlock = func() { once.Do(s.snapshotLock.RUnlock) }
+			db = latestSnapshotDB(s.stateStorage, req.ByzCoinID)
+		}
 		total := make(chan int)
 		go func(ds downloadState) {
-			idStr := fmt.Sprintf("%x", ds.id)
-			db, bucketName := s.GetAdditionalBucket([]byte(idStr))
-			err := db.View(func(tx *bbolt.Tx) error {
-				bucket := tx.Bucket(bucketName)
-				total <- bucket.Stats().KeyN
+			totalSent := false
+			err := db.View(func(bucket trie.Bucket) error {
+				unlock()
+				var keyN int
+				err := bucket.ForEach(func(k []byte, v []byte) error {
+					keyN++
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				total <- keyN
+				totalSent = true
 				return bucket.ForEach(func(k []byte, v []byte) error {
 					key := make([]byte, len(k))
 					copy(key, k)
@@ -762,9 +914,13 @@ func (s *Service) DownloadState(req *DownloadState) (resp *DownloadStateResponse
 					return nil
 				})
 			})
+			unlock()
 			if err != nil {
 				log.Error("while serving current database:", err)
 			}
+			if !totalSent {
+				total <- 0
+			}
 			close(ds.read)
 		}(s.downloadState)
 		s.downloadState.total = <-total
@@ -985,6 +1141,74 @@ func (s *Service) Debug(req *DebugRequest) (resp *DebugResponse, err error) {
 	return
 }
 
+// GetTxStatus returns what this node knows about the given transaction. As
+// pending transactions are only known to the node that received them and to
+// the leader, the status of a transaction sent to another node might be
+// unknown until it is included in a block.
+func (s *Service) GetTxStatus(req *GetTxStatus) (*GetTxStatusResponse, error) {
+	if s.db().GetByID(req.SkipchainID) == nil {
+		return nil, xerrors.New("skipchain ID does not exist")
+	}
+	e, _ := s.txStatus.get(req.SkipchainID, req.TxHash)
+	return &GetTxStatusResponse{
+		Status:     e.status,
+		BlockIndex: e.index,
+		Error:      e.err,
+	}, nil
+}
+
+// ListPendingTxs returns the transactions received by this node that are not
+// yet included in a block. If the node is the leader, these are the
+// transactions waiting to be proposed.
+func (s *Service) ListPendingTxs(req *ListPendingTxs) (*ListPendingTxsResponse, error) {
+	if err := schnorr.Verify(cothority.Suite, s.ServerIdentity().Public, req.ByzCoinID, req.Signature); err != nil {
+		log.Error("Signature failure:", err)
+		return nil, xerrors.Errorf("verifying signature: %v", err)
+	}
+	return &ListPendingTxsResponse{
+		Transactions: s.txStatus.pending(req.ByzCoinID),
+	}, nil
+}
+
+// SimulateTransaction executes the transaction against the current state of
+// the chain, without storing anything. It returns the state changes and coins
+// of every instruction, and the error of the contract if the transaction
+// would be refused. As the signatures and the signer counters are verified,
+// the transaction must be signed, but it is not sent to the leader, so the
+// counters can be used again.
+func (s *Service) SimulateTransaction(req *SimulateTransaction) (*SimulateTransactionResponse, error) {
+	st, err := s.getStateTrie(req.SkipchainID)
+	if err != nil {
+		return nil, xerrors.Errorf("getting trie: %v", err)
+	}
+
+	tx := req.Transaction
+	tx.Instructions = append(Instructions{}, tx.Instructions...)
+	tx.Instructions.SetVersion(st.GetVersion())
+
+	resp := &SimulateTransactionResponse{}
+	trace := func(instr Instruction, scs StateChanges, cout []Coin, err error) {
+		si := SimulatedInstruction{
+			Instruction:  instr,
+			StateChanges: scs,
+			CoinsOut:     cout,
+		}
+		if err != nil {
+			si.Error = err.Error()
+		}
+		resp.Instructions = append(resp.Instructions, si)
+	}
+	scs, _, err := s.processOneTxTrace(st.MakeStagingStateTrie(), tx,
+		req.SkipchainID, time.Now().UnixNano(), trace, false)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp, nil
+	}
+	resp.Accepted = true
+	resp.StateChanges = scs
+	return resp, nil
+}
+
 // DebugRemove deletes an existing byzcoin-instance from the conode.
 func (s *Service) DebugRemove(req *DebugRemoveRequest) (*DebugResponse, error) {
 	if err := schnorr.Verify(cothority.Suite, s.ServerIdentity().Public, req.ByzCoinID, req.Signature); err != nil {
@@ -1007,16 +1231,13 @@ func (s *Service) DebugRemove(req *DebugRemoveRequest) (*DebugResponse, error) {
 	_, exists = s.stateTries[idStrHex]
 	if exists {
 		log.Lvl2("Removing state-trie")
-		db, bn := s.GetAdditionalBucket([]byte(idStrHex))
-		if db == nil {
-			return nil, xerrors.New("didn't find trie for this byzcoin-ID")
-		}
-		err := db.Update(func(tx *bbolt.Tx) error {
-			return tx.DeleteBucket(bn)
-		})
+		err := s.stateStorage.removeTrie(req.ByzCoinID)
 		if err != nil {
 			return nil, xerrors.Errorf("deleting bucket: %v", err)
 		}
+		if err := s.stateStorage.removeSnapshot(req.ByzCoinID); err != nil {
+			return nil, xerrors.Errorf("deleting snapshot: %v", err)
+		}
 		delete(s.stateTries, idStr)
 		err = s.db().RemoveSkipchain(req.ByzCoinID)
 		if err != nil {
@@ -1247,6 +1468,11 @@ func (s *Service) createUpgradeVersionBlock(scID skipchain.SkipBlockID, version
 // and recreating it on the remote side.
 // sb is a block in the byzcoin instance that we want
 // to download.
+//
+// If the other nodes have a snapshot of the state, the snapshot is downloaded
+// and the blocks following it are replayed. Else the current state of one of
+// the nodes is downloaded. In both cases, the state is verified against the
+// trie root of its block.
 func (s *Service) downloadDB(sb *skipchain.SkipBlock) error {
 	log.Lvlf2("%s: downloading DB", s.ServerIdentity())
 	idStr := fmt.Sprintf("%x", sb.SkipChainID())
@@ -1258,10 +1484,7 @@ func (s *Service) downloadDB(sb *skipchain.SkipBlock) error {
 		_, err := s.getStateTrie(sb.SkipChainID())
 		if err == nil {
 			// Suppose we _do_ have a statetrie
-			db, stBucket := s.GetAdditionalBucket(sb.SkipChainID())
-			err := db.Update(func(tx *bbolt.Tx) error {
-				return cothority.ErrorOrNil(tx.DeleteBucket(stBucket), "deleting bucket")
-			})
+			err := s.stateStorage.removeTrie(sb.SkipChainID())
 			if err != nil {
 				return xerrors.Errorf("Cannot delete existing trie while trying to download: %v", err)
 			}
@@ -1270,76 +1493,29 @@ func (s *Service) downloadDB(sb *skipchain.SkipBlock) error {
 			s.stateTriesLock.Unlock()
 		}
 
-		// Then start downloading the stateTrie over the network.
-		cl := NewClient(sb.SkipChainID(), *sb.Roster)
-		cl.DontContact(s.ServerIdentity())
-		var db *bbolt.DB
-		var bucketName []byte
-		var nonce uint64
-		var cursor int
-		for {
-			// Note: we trust the chain therefore even if the reply is corrupted,
-			// it will be detected by difference in the root hash
-			resp, err := cl.DownloadState(sb.SkipChainID(), nonce, catchupFetchDBEntries)
-			if err != nil {
-				return xerrors.Errorf("cannot download trie: %v", err)
-			}
-			log.Lvlf1("Downloaded key/values %d..%d of %d from %s", cursor, cursor+len(resp.KeyValues), resp.Total,
-				cl.noncesSI[resp.Nonce])
-			cursor += len(resp.KeyValues)
-			if db == nil {
-				db, bucketName = s.GetAdditionalBucket([]byte(idStr))
-				nonce = resp.Nonce
-			}
-			// And store all entries in our local database.
-			err = db.Update(func(tx *bbolt.Tx) error {
-				bucket := tx.Bucket(bucketName)
-				for _, kv := range resp.KeyValues {
-					err := bucket.Put(kv.Key, kv.Value)
-					if err != nil {
-						return err
-					}
-				}
-				return nil
-			})
-			if err != nil {
-				return xerrors.Errorf("couldn't store entries: %v", err)
-			}
-			if len(resp.KeyValues) < catchupFetchDBEntries {
-				break
-			}
+		st, stBlock, err := s.downloadTrie(sb, true)
+		if err == nil {
+			err = s.replayBlocks(st, stBlock, sb)
 		}
-
-		// Check the new trie is correct
-		st, err := loadStateTrie(db, bucketName)
 		if err != nil {
-			return xerrors.Errorf("couldn't load state trie: %v", err)
-		}
-		skCl := skipchain.NewClient()
-		skCl.DontContact(s.ServerIdentity())
-		if sb.Index != st.GetIndex() {
-			log.Lvl2("Downloading corresponding block", sb.Index, st.GetIndex())
-			// TODO: add a client API to fetch a specific block and its proof
-			search, err := skCl.GetSingleBlockByIndex(sb.Roster, sb.SkipChainID(), st.GetIndex())
+			log.Lvlf2("%s: couldn't use snapshot, downloading current state: %v",
+				s.ServerIdentity(), err)
+			if err := s.stateStorage.removeTrie(sb.SkipChainID()); err != nil {
+				return xerrors.Errorf("removing snapshot: %v", err)
+			}
+			st, stBlock, err = s.downloadTrie(sb, false)
 			if err != nil {
-				return xerrors.Errorf("couldn't get correct block for verification: %v", err)
+				return err
 			}
-			sb = search.SkipBlock
-		}
-
-		header, err := decodeBlockHeader(sb)
-		if err != nil {
-			return xerrors.Errorf("couldn't unmarshal header: %v", err)
-		}
-		if !bytes.Equal(st.GetRoot(), header.TrieRoot) {
-			return xerrors.New("got wrong database, merkle roots don't work out")
 		}
 
 		// Finally initialize the stateTrie using the new database.
 		s.stateTriesLock.Lock()
 		s.stateTries[idStr] = st
 		s.stateTriesLock.Unlock()
-		chain, err := skCl.GetUpdateChain(sb.Roster, sb.SkipChainID())
+		skCl := skipchain.NewClient()
+		skCl.DontContact(s.ServerIdentity())
+		chain, err := skCl.GetUpdateChain(stBlock.Roster, stBlock.SkipChainID())
 		if err != nil {
 			return xerrors.Errorf("getting chain: %v", err)
 		}
@@ -1358,6 +1534,80 @@ func (s *Service) downloadDB(sb *skipchain.SkipBlock) error {
 	return xerrors.New("none of the non-leader and non-subleader nodes were able to give us a copy of the state")
 }
 
+// downloadTrie downloads the current state or the latest snapshot of the
+// chain of sb from one of the other nodes. It returns the trie once it has
+// been verified against the trie root of its block, together with that block.
+func (s *Service) downloadTrie(sb *skipchain.SkipBlock, snapshot bool) (*stateTrie,
+	*skipchain.SkipBlock, error) {
+	cl := NewClient(sb.SkipChainID(), *sb.Roster)
+	cl.DontContact(s.ServerIdentity())
+	download := cl.DownloadState
+	if snapshot {
+		download = cl.DownloadSnapshot
+	}
+	var db trie.DB
+	var nonce uint64
+	var cursor int
+	for {
+		// Note: we trust the chain therefore even if the reply is corrupted,
+		// it will be detected by difference in the root hash
+		resp, err := download(sb.SkipChainID(), nonce, catchupFetchDBEntries)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("cannot download trie: %v", err)
+		}
+		log.Lvlf1("Downloaded key/values %d..%d of %d from %s", cursor, cursor+len(resp.KeyValues), resp.Total,
+			cl.noncesSI[resp.Nonce])
+		cursor += len(resp.KeyValues)
+		if db == nil {
+			db = s.stateStorage.trieDB(sb.SkipChainID())
+			nonce = resp.Nonce
+		}
+		// And store all entries in our local database.
+		err = db.Update(func(bucket trie.Bucket) error {
+			for _, kv := range resp.KeyValues {
+				err := bucket.Put(kv.Key, kv.Value)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't store entries: %v", err)
+		}
+		if len(resp.KeyValues) < catchupFetchDBEntries {
+			break
+		}
+	}
+
+	// Check the new trie is correct
+	st, err := loadStateTrie(db)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't load state trie: %v", err)
+	}
+	if sb.Index != st.GetIndex() {
+		log.Lvl2("Downloading corresponding block", sb.Index, st.GetIndex())
+		// The client verifies the forward links leading to the block, so
+		// its header, and the trie root in it, are signed by the nodes.
+		skCl := skipchain.NewClient()
+		skCl.DontContact(s.ServerIdentity())
+		search, err := skCl.GetSingleBlockByIndex(sb.Roster, sb.SkipChainID(), st.GetIndex())
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't get correct block for verification: %v", err)
+		}
+		sb = search.SkipBlock
+	}
+
+	header, err := decodeBlockHeader(sb)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't unmarshal header: %v", err)
+	}
+	if !bytes.Equal(st.GetRoot(), header.TrieRoot) {
+		return nil, nil, xerrors.New("got wrong database, merkle roots don't work out")
+	}
+	return st, sb, nil
+}
+
 // catchupAll calls catchup for every byzcoin instance stored in this system.
 func (s *Service) catchupAll() error {
 	s.closedMutex.Lock()
@@ -1598,6 +1848,18 @@ func (s *Service) catchUp(sb *skipchain.SkipBlock) {
 			return
 		}
 
+		// Nodes that prune their blocks cannot give us the transactions
+		// anymore, but they have a snapshot of the state.
+		for _, block := range updates {
+			if block.Index > trieIndex && isPruned(block) {
+				log.Lvlf2("%s: block %d is pruned, downloading DB", s.ServerIdentity(), block.Index)
+				if err := s.downloadDB(sb); err != nil {
+					log.Error("Error while downloading trie:", err)
+				}
+				return
+			}
+		}
+
 		// This will call updateTrieCallback with the next block to add
 		for _, sb := range updates {
 			log.Lvlf2("Storing block %d: %x", sb.Index, sb.CalculateHash())
@@ -1749,6 +2011,12 @@ func (s *Service) updateTrieCallback(sbID skipchain.SkipBlockID) error {
 			"mean that the db is broken.")
 	}
 
+	if snapshotDue(bcConfig.SnapshotInterval, sb.Index) {
+		if err := s.createSnapshot(sb.SkipChainID(), sb.Index); err != nil {
+			log.Errorf("%s: couldn't create snapshot: %v", s.ServerIdentity(), err)
+		}
+	}
+
 	// Variables for easy understanding what's being tested. Node in this context
 	// is this node.
 	i, _ := bcConfig.Roster.Search(s.ServerIdentity().ID)
@@ -1803,11 +2071,26 @@ func (s *Service) updateTrieCallback(sbID skipchain.SkipBlockID) error {
 	ctx := s.ServiceProcessor.Context
 	ctx.SetValidPeers(ctx.NewPeerSetID(sb.SkipChainID()), sb.Roster.List)
 
+	// A view-change drops all transactions waiting at the old leader.
+	if isViewChangeTx(body.TxResults) != nil {
+		s.txStatus.dropPending(sb.SkipChainID())
+	}
+	for _, tx := range body.TxResults {
+		var errMsg string
+		if !tx.Accepted {
+			errMsg, _ = s.txErrorBuf.get(tx.ClientTransaction.Instructions.HashWithSignatures())
+		}
+		s.txStatus.setIncluded(sb.SkipChainID(), tx.ClientTransaction.Instructions.Hash(),
+			tx.Accepted, sb.Index, errMsg)
+	}
+
 	// Notify all waiting channels for processed ClientTransactions.
 	s.notifications.informBlock(sb, body.TxResults)
 
 	// At this point everything should be stored.
 	s.streamingMan.notify(string(sb.SkipChainID()), sb)
+	s.streamingMan.notifyFiltered(string(sb.SkipChainID()), sb, body.TxResults,
+		scs, st, s.db())
 
 	log.Lvlf2("%s updated trie for %x with root %x", s.ServerIdentity(), sb.SkipChainID(), st.GetRoot())
 	return nil
@@ -1864,8 +2147,7 @@ func (s *Service) getStateTrie(id skipchain.SkipBlockID) (*stateTrie, error) {
 	idStr := fmt.Sprintf("%x", id)
 	col := s.stateTries[idStr]
 	if col == nil {
-		db, name := s.GetAdditionalBucket([]byte(idStr))
-		st, err := loadStateTrie(db, name)
+		st, err := loadStateTrie(s.stateStorage.trieDB(id))
 		if err != nil {
 			return nil, xerrors.Errorf("getting trie: %v", err)
 		}
@@ -1885,8 +2167,7 @@ func (s *Service) createStateTrie(id skipchain.SkipBlockID, nonce []byte) (*stat
 	if s.stateTries[idStr] != nil {
 		return nil, xerrors.New("state trie already exists")
 	}
-	db, name := s.GetAdditionalBucket([]byte(idStr))
-	st, err := newStateTrie(db, name, nonce)
+	st, err := newStateTrie(s.stateStorage.trieDB(id), nonce)
 	if err != nil {
 		return nil, xerrors.Errorf("making trie: %v", err)
 	}
@@ -2316,17 +2597,58 @@ func ComputeSeed(instr Instruction, index uint8) []byte {
 // from the trie should be read from sst and not the service.
 func (s *Service) processOneTx(sst *stagingStateTrie, tx ClientTransaction,
 	scID skipchain.SkipBlockID, timestamp int64) (StateChanges, *stagingStateTrie, error) {
+	return s.processOneTxTrace(sst, tx, scID, timestamp, nil, true)
+}
+
+// replayOneTx works like processOneTx, but it doesn't record the errors of the
+// transaction. It is used for transactions of existing blocks, whose errors
+// have been recorded when the block was created.
+func (s *Service) replayOneTx(sst *stagingStateTrie, tx ClientTransaction,
+	scID skipchain.SkipBlockID, timestamp int64) (StateChanges, *stagingStateTrie, error) {
+	return s.processOneTxTrace(sst, tx, scID, timestamp, nil, false)
+}
+
+// instructionTrace is called by processOneTxTrace for every executed
+// instruction, including the instructions generated by other instructions.
+// If the instruction failed, err is set.
+type instructionTrace func(instr Instruction, scs StateChanges, cout []Coin, err error)
+
+// processOneTxTrace works like processOneTx, but calls trace, if it is not
+// nil, after every instruction. The errors are only kept for GetTxStatus if
+// recordErrors is set.
+func (s *Service) processOneTxTrace(sst *stagingStateTrie, tx ClientTransaction,
+	scID skipchain.SkipBlockID, timestamp int64, trace instructionTrace,
+	recordErrors bool) (StateChanges, *stagingStateTrie, error) {
 
 	// Make a new trie for each instruction. If the instruction is
 	// sucessfully implemented and changes applied, then keep it
 	// otherwise dump it.
 	sst = sst.Clone()
 
+	// Simulated or replayed transactions must not leave their errors in the
+	// buffer used by GetTxStatus.
+	addError := func(err error) {
+		if recordErrors {
+			s.addError(tx, err)
+		}
+	}
+
 	// convert ReadOnlyStateTrie to a GlobalState so that contracts may cast it if they wish
 	roSC := newROSkipChain(s.skService(), scID)
 	gs := globalState{sst, roSC, &currentBlockInfo{timestamp}}
 
-	h := tx.Instructions.Hash()
+	// The fees are charged using the configuration that is valid before
+	// the transaction is executed.
+	var fees *FeeSchedule
+	var leader *network.ServerIdentity
+	config, err := sst.LoadConfig()
+	if err == nil && config.FeeSchedule != nil && len(config.Roster.List) > 0 &&
+		!isFeeExempt(sst, tx) {
+		fees = config.FeeSchedule
+		leader = config.Roster.List[0]
+	}
+
+	h := tx.Hash()
 	var statesTemp StateChanges
 	var cin []Coin
 	for i := 0; i < len(tx.Instructions); i++ {
@@ -2341,7 +2663,10 @@ func (s *Service) processOneTx(sst *stagingStateTrie, tx ClientTransaction,
 			}
 			err = xerrors.Errorf("%s Contract %s got %x and returned error: %v",
 				s.ServerIdentity(), cid, instr.Hash(), err)
-			s.addError(tx, err)
+			if trace != nil {
+				trace(instr, nil, nil, err)
+			}
+			addError(err)
 			return nil, nil, err
 		}
 
@@ -2349,7 +2674,7 @@ func (s *Service) processOneTx(sst *stagingStateTrie, tx ClientTransaction,
 		if err != nil {
 			err = xerrors.Errorf("%s failed to update signature counters: %v",
 				s.ServerIdentity(), err)
-			s.addError(tx, err)
+			addError(err)
 			return nil, nil, err
 		}
 
@@ -2388,12 +2713,14 @@ func (s *Service) processOneTx(sst *stagingStateTrie, tx ClientTransaction,
 					err = xerrors.Errorf("%s couldn't get contractID from the "+
 						"following instruction: %x (with instanceID %x)",
 						s.ServerIdentity(), instr.Hash(), instr.InstanceID.Slice())
-					s.addError(tx, err)
-					return nil, nil, err
+				} else {
+					err = xerrors.Errorf("%s: contract %s %s %x", s.ServerIdentity(),
+						contractID, reason, sc.InstanceID)
 				}
-				err = xerrors.Errorf("%s: contract %s %s %x", s.ServerIdentity(),
-					contractID, reason, sc.InstanceID)
-				s.addError(tx, err)
+				if trace != nil {
+					trace(instr, scs, cout, err)
+				}
+				addError(err)
 				return nil, nil, err
 			}
 			log.Lvlf2("StateChange %s for id %x - contract: %s", sc.StateAction,
@@ -2430,7 +2757,7 @@ func (s *Service) processOneTx(sst *stagingStateTrie, tx ClientTransaction,
 			err = sst.StoreAll(StateChanges{sc})
 			if err != nil {
 				err = xerrors.Errorf("%s StoreAll failed: %v", s.ServerIdentity(), err)
-				s.addError(tx, err)
+				addError(err)
 				return nil, nil, err
 			}
 		}
@@ -2445,18 +2772,32 @@ func (s *Service) processOneTx(sst *stagingStateTrie, tx ClientTransaction,
 		if err = sst.StoreAll(counterScs); err != nil {
 			err = xerrors.Errorf("%s StoreAll failed to add counter changes: %v",
 				s.ServerIdentity(), err)
-			s.addError(tx, err)
+			addError(err)
 			return nil, nil, err
 		}
 
 		statesTemp = append(statesTemp, scs...)
 		statesTemp = append(statesTemp, counterScs...)
 		cin = cout
+		if trace != nil {
+			trace(instr, scs, cout, nil)
+		}
 	}
 	if len(cin) != 0 {
 		log.Lvl2(s.ServerIdentity(), "Leftover coins detected, discarding.")
 	}
 
+	if fees != nil {
+		feeScs, err := chargeFees(sst, *fees, leader, tx, h)
+		if err != nil {
+			err = xerrors.Errorf("%s couldn't charge fees: %v",
+				s.ServerIdentity(), err)
+			addError(err)
+			return nil, nil, err
+		}
+		statesTemp = append(statesTemp, feeScs...)
+	}
+
 	return statesTemp, sst, nil
 }
 
@@ -2627,6 +2968,21 @@ func loadNonceFromTxs(txs TxResults) ([]byte, error) {
 	return nonce, nil
 }
 
+// Close stops the go-routines of the service and releases the storage of the
+// state tries. It is called when the conode shuts down.
+func (s *Service) Close() error {
+	s.closedMutex.Lock()
+	if !s.closed {
+		s.closed = true
+		s.closedMutex.Unlock()
+		s.cleanupGoroutines()
+		s.working.Wait()
+	} else {
+		s.closedMutex.Unlock()
+	}
+	return cothority.ErrorOrNil(s.stateStorage.close(), "closing storage")
+}
+
 // TestClose closes the go-routines that are polling for transactions. It is
 // exported because we need it in tests, it should not be used in non-test code
 // outside of this package.
@@ -3048,8 +3404,21 @@ var existingDB = regexp.MustCompile(`^ByzCoin_[0-9a-f]+$`)
 // be stored in memory for tests and simulations, and on disk for real
 // deployments.
 func newService(c *onet.Context) (onet.Service, error) {
+	var keepSnapshots int
+	if keep := os.Getenv(SnapshotsEnv); keep != "" {
+		var err error
+		keepSnapshots, err = strconv.Atoi(keep)
+		if err != nil || keepSnapshots < 0 {
+			return nil, xerrors.Errorf("invalid %s: %s", SnapshotsEnv, keep)
+		}
+	}
+	stateStorage, err := newStateStorage(c)
+	if err != nil {
+		return nil, xerrors.Errorf("creating storage: %v", err)
+	}
 	s := &Service{
 		ServiceProcessor:   onet.NewServiceProcessor(c),
+		stateStorage:       stateStorage,
 		contracts:          globalContractRegistry.clone(),
 		storage:            &bcStorage{},
 		darcToSc:           make(map[string]skipchain.SkipBlockID),
@@ -3059,19 +3428,25 @@ func newService(c *onet.Context) (onet.Service, error) {
 		streamingMan:       streamingManager{},
 		closed:             true,
 		catchingUpHistory:  make(map[string]time.Time),
+		snapshotsRunning:   make(map[string]bool),
 		rotationWindow:     defaultRotationWindow,
 		defaultVersion:     CurrentVersion,
 		txPipeline:         make(map[string]*txPipeline),
 		// We need a large enough buffer for all errors in 2 blocks
 		// where each block might be 1 MB in size and each tx is 1 KB.
-		txErrorBuf: newRingBuf(2048),
+		txErrorBuf:    newRingBuf(2048),
+		txStatus:      newTxStatusBuf(2048),
+		pruneBlocks:   os.Getenv(PruneEnv) == "true",
+		keepSnapshots: keepSnapshots,
 	}
 
-	err := s.RegisterHandlers(
+	err = s.RegisterHandlers(
 		s.GetAllByzCoinIDs,
 		s.CreateGenesisBlock,
 		s.AddTransaction,
 		s.GetProof,
+		s.GetProofAt,
+		s.SimulateTransaction,
 		s.GetUpdates,
 		s.CheckAuthorization,
 		s.GetSignerCounters,
@@ -3082,12 +3457,15 @@ func newService(c *onet.Context) (onet.Service, error) {
 		s.CheckStateChangeValidity,
 		s.ResolveInstanceID,
 		s.Debug,
-		s.DebugRemove)
+		s.DebugRemove,
+		s.GetTxStatus,
+		s.ListPendingTxs)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := s.RegisterStreamingHandlers(s.StreamTransactions, s.PaginateBlocks); err != nil {
+	if err := s.RegisterStreamingHandlers(s.StreamTransactions, s.PaginateBlocks,
+		s.StreamFilteredTransactions); err != nil {
 		return nil, xerrors.Errorf("registering handlers: %v", err)
 	}
 	s.RegisterProcessorFunc(viewChangeMsgID, s.handleViewChangeReq)
@@ -401,6 +401,142 @@ func TestService_AddTransaction_ValidInvalid(t *testing.T) {
 	transactionOK(t, resp, err)
 }
 
+func TestService_GetTxStatus(t *testing.T) {
+	s := newSerN(t, 1, testInterval, 4, disableViewChange)
+	defer s.local.CloseAll()
+
+	getStatus := func(node int, tx ClientTransaction) *GetTxStatusResponse {
+		resp, err := s.services[node].GetTxStatus(&GetTxStatus{
+			SkipchainID: s.genesis.SkipChainID(),
+			TxHash:      tx.Instructions.Hash(),
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	tx1, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract,
+		s.value, s.signer, 1)
+	require.NoError(t, err)
+	resp := getStatus(0, tx1)
+	require.Equal(t, TxStatusUnknown, resp.Status)
+	require.Equal(t, -1, resp.BlockIndex)
+
+	s.sendTxToAndWait(t, tx1, 1, 10)
+	for node := range s.services {
+		resp = getStatus(node, tx1)
+		require.Equal(t, TxStatusAccepted, resp.Status)
+		require.Equal(t, 1, resp.BlockIndex)
+		require.Equal(t, "", resp.Error)
+	}
+
+	// Using the same counter again makes the transaction fail.
+	tx2, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract,
+		[]byte("other value"), s.signer, 1)
+	require.NoError(t, err)
+	_, err = s.service().AddTransaction(&AddTxRequest{
+		Version:       CurrentVersion,
+		SkipchainID:   s.genesis.SkipChainID(),
+		Transaction:   tx2,
+		InclusionWait: 10,
+	})
+	require.NoError(t, err)
+	resp = getStatus(0, tx2)
+	require.Equal(t, TxStatusRefused, resp.Status)
+	require.Equal(t, 2, resp.BlockIndex)
+	require.Contains(t, resp.Error, "counter")
+
+	_, err = s.service().GetTxStatus(&GetTxStatus{
+		SkipchainID: []byte("unknown"),
+		TxHash:      tx1.Instructions.Hash(),
+	})
+	require.Error(t, err)
+
+	// Only the conode itself can list its pending transactions.
+	_, err = s.service().ListPendingTxs(&ListPendingTxs{
+		ByzCoinID: s.genesis.SkipChainID(),
+	})
+	require.Error(t, err)
+	txs, err := ListPendingTxs(s.service().ServerIdentity(), s.genesis.SkipChainID())
+	require.NoError(t, err)
+	require.Equal(t, 0, len(txs))
+}
+
+func TestService_SimulateTransaction(t *testing.T) {
+	s := newSerN(t, 1, testInterval, 4, disableViewChange)
+	defer s.local.CloseAll()
+
+	// With a value of 32 bytes, the dummy contract uses it as the instance ID.
+	dcID := random.Bits(256, false, random.New())
+	tx1, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract,
+		dcID, s.signer, 1)
+	require.NoError(t, err)
+	resp, err := s.service().SimulateTransaction(&SimulateTransaction{
+		SkipchainID: s.genesis.SkipChainID(),
+		Transaction: tx1,
+	})
+	require.NoError(t, err)
+	require.True(t, resp.Accepted, resp.Error)
+	require.Equal(t, 1, len(resp.Instructions))
+	require.Equal(t, "", resp.Instructions[0].Error)
+	require.Equal(t, 1, len(resp.Instructions[0].StateChanges))
+	sc := resp.Instructions[0].StateChanges[0]
+	require.Equal(t, Create, sc.StateAction)
+	require.Equal(t, NewInstanceID(dcID).Slice(), sc.InstanceID)
+	// The changes of the transaction include the signer counter.
+	require.Equal(t, 2, len(resp.StateChanges))
+
+	// Nothing is stored and the counter can still be used.
+	pr, err := s.service().GetProof(&GetProof{
+		Version: CurrentVersion,
+		Key:     sc.InstanceID,
+		ID:      s.genesis.SkipChainID(),
+	})
+	require.NoError(t, err)
+	require.False(t, pr.Proof.InclusionProof.Match(sc.InstanceID))
+	st, err := s.service().GetTxStatus(&GetTxStatus{
+		SkipchainID: s.genesis.SkipChainID(),
+		TxHash:      tx1.Instructions.Hash(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, TxStatusUnknown, st.Status)
+	s.sendTxAndWait(t, tx1, 10)
+
+	// Once the instance exists, the same spawn is refused by the contract.
+	tx2, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract,
+		dcID, s.signer, 2)
+	require.NoError(t, err)
+	resp, err = s.service().SimulateTransaction(&SimulateTransaction{
+		SkipchainID: s.genesis.SkipChainID(),
+		Transaction: tx2,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.Accepted)
+	require.Contains(t, resp.Error, "tried to create existing instanceID")
+	require.Equal(t, 0, len(resp.StateChanges))
+	require.Equal(t, 1, len(resp.Instructions))
+	require.Contains(t, resp.Instructions[0].Error, "tried to create existing instanceID")
+	require.Equal(t, 1, len(resp.Instructions[0].StateChanges))
+
+	// A wrong counter is reported as the error of the instruction.
+	tx3, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract,
+		[]byte("other value"), s.signer, 1)
+	require.NoError(t, err)
+	resp, err = s.service().SimulateTransaction(&SimulateTransaction{
+		SkipchainID: s.genesis.SkipChainID(),
+		Transaction: tx3,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.Accepted)
+	require.Equal(t, 1, len(resp.Instructions))
+	require.Contains(t, resp.Instructions[0].Error, "counter")
+
+	_, err = s.service().SimulateTransaction(&SimulateTransaction{
+		SkipchainID: []byte("unknown"),
+		Transaction: tx3,
+	})
+	require.Error(t, err)
+}
+
 // Sends the same transaction to two different nodes and makes sure that it shows up only once in a
 // block.
 func TestService_AddTransaction_Parallel(t *testing.T) {
@@ -634,6 +770,131 @@ func TestService_GetProof(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestService_GetProofAt(t *testing.T) {
+	s := newSer(t, 2, testInterval)
+	defer s.local.CloseAll()
+
+	serKey := s.tx.Instructions[0].Hash()
+	s.sendDummyTx(t, 0, 2, 10)
+
+	getProofAt := func(index int) (*GetProofResponse, error) {
+		return s.service().GetProofAt(&GetProofAt{
+			Version:    CurrentVersion,
+			ID:         s.genesis.SkipChainID(),
+			Key:        serKey,
+			BlockIndex: index,
+		})
+	}
+
+	// Before the instance was created.
+	rep, err := getProofAt(0)
+	require.NoError(t, err)
+	require.NoError(t, rep.Proof.Verify(s.genesis.SkipChainID()))
+	require.Equal(t, 0, rep.Proof.Latest.Index)
+	require.False(t, rep.Proof.InclusionProof.Match(serKey))
+
+	// In a past block.
+	rep, err = getProofAt(1)
+	require.NoError(t, err)
+	require.NoError(t, rep.Proof.Verify(s.genesis.SkipChainID()))
+	require.Equal(t, 1, rep.Proof.Latest.Index)
+	_, v, _, _, err := rep.Proof.KeyValue()
+	require.NoError(t, err)
+	require.Equal(t, s.value, v)
+
+	// The replayed states are cached, and only one is replayed at a time.
+	require.NotNil(t, s.service().replayCache.get(s.genesis.SkipChainID(), 1))
+	require.True(t, s.service().replayCache.start())
+	_, err = s.service().replayStateAt(s.genesis.SkipChainID(), 1)
+	require.Error(t, err)
+	s.service().replayCache.done()
+	rep, err = getProofAt(1)
+	require.NoError(t, err)
+	require.Equal(t, 1, rep.Proof.Latest.Index)
+
+	// The latest block doesn't need a replay.
+	rep, err = getProofAt(2)
+	require.NoError(t, err)
+	require.Equal(t, 2, rep.Proof.Latest.Index)
+	require.True(t, rep.Proof.InclusionProof.Match(serKey))
+
+	_, err = getProofAt(3)
+	require.Error(t, err)
+
+	cl := NewClient(s.genesis.SkipChainID(), *s.roster)
+	rep, err = cl.GetProofAt(serKey, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, rep.Proof.Latest.Index)
+}
+
+// Checks that GetProofAt proves the states far behind the latest snapshot,
+// by replaying them from the snapshot before them. The replay window is
+// scaled down, so that the chain is many times longer than it.
+func TestService_GetProofAtSnapshots(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	mrb := maxReplayBlocks
+	defer func() {
+		maxReplayBlocks = mrb
+	}()
+	maxReplayBlocks = 4
+
+	config, err := s.service().LoadConfig(s.genesis.SkipChainID())
+	require.NoError(t, err)
+	config.SnapshotInterval = maxReplayBlocks + 1
+	require.Error(t, config.sanityCheck(nil))
+	config.SnapshotInterval = 3
+	require.NoError(t, config.sanityCheck(nil))
+	configBuf, err := protobuf.Encode(config)
+	require.NoError(t, err)
+	ctx, err := combineInstrsAndSign(s.signer, Instruction{
+		InstanceID: ConfigInstanceID,
+		Invoke: &Invoke{
+			ContractID: ContractConfigID,
+			Command:    "update_config",
+			Args:       []Argument{{Name: "config", Value: configBuf}},
+		},
+		SignerCounter: []uint64{1},
+		version:       CurrentVersion,
+	})
+	require.NoError(t, err)
+	s.sendTxAndWait(t, ctx, 10)
+
+	log.Lvl1("Adding dummy transactions")
+	addDummyTxs(t, s, 6*maxReplayBlocks, 1, 2)
+
+	latest, err := s.service().db().GetLatest(s.genesis)
+	require.NoError(t, err)
+	require.True(t, latest.Index >= 5*maxReplayBlocks)
+
+	// Wait for the snapshots copied in the background.
+	lastSnap := latest.Index - latest.Index%3
+	for i := 0; i < 10; i++ {
+		s.service().snapshotLock.RLock()
+		snaps := s.service().stateStorage.snapshots(s.genesis.SkipChainID())
+		s.service().snapshotLock.RUnlock()
+		if len(snaps) > 0 && snaps[len(snaps)-1] >= lastSnap {
+			break
+		}
+		require.NotEqual(t, 9, i, "snapshots have not been created")
+		time.Sleep(s.interval)
+	}
+
+	for index := 0; index < latest.Index; index++ {
+		rep, err := s.service().GetProofAt(&GetProofAt{
+			Version:    CurrentVersion,
+			ID:         s.genesis.SkipChainID(),
+			Key:        ConfigInstanceID.Slice(),
+			BlockIndex: index,
+		})
+		require.NoError(t, err, "index %d", index)
+		require.NoError(t, rep.Proof.Verify(s.genesis.SkipChainID()))
+		require.Equal(t, index, rep.Proof.Latest.Index)
+		require.True(t, rep.Proof.InclusionProof.Match(ConfigInstanceID.Slice()))
+	}
+}
+
 func TestService_DarcProxy(t *testing.T) {
 	s := newSer(t, 1, testInterval)
 	defer s.local.CloseAll()
@@ -1598,6 +1859,89 @@ func TestService_SetConfig(t *testing.T) {
 	require.Equal(t, blocksize, newBlocksize)
 }
 
+// Checks that a transaction pays its fees once a fee schedule is set, and
+// that the fee payer cannot be changed after signing.
+func TestService_Fees(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	d2 := s.darc.Copy()
+	require.NoError(t, d2.EvolveFrom(s.darc))
+	require.NoError(t, d2.Rules.AddRule("invoke:"+dummyContract+".transfer",
+		expression.Expr(s.signer.Identity().String())))
+	s.testDarcEvolution(t, *d2, false)
+
+	coinBuf, err := protobuf.Encode(&Coin{Name: feeCoinName, Value: 100})
+	require.NoError(t, err)
+	instr := createSpawnInstr(s.darc.GetBaseID(), dummyContract, "data", coinBuf)
+	instr.SignerCounter = []uint64{3}
+	ctx, err := combineInstrsAndSign(s.signer, instr)
+	require.NoError(t, err)
+	payer := NewInstanceID(ctx.Instructions[0].Hash())
+	s.sendTxAndWait(t, ctx, 10)
+
+	config, err := s.service().LoadConfig(s.genesis.SkipChainID())
+	require.NoError(t, err)
+	config.FeeSchedule = &FeeSchedule{CoinName: feeCoinName,
+		TransactionFee: 10, InstructionFee: 1}
+	configBuf, err := protobuf.Encode(config)
+	require.NoError(t, err)
+	ctx, err = combineInstrsAndSign(s.signer, Instruction{
+		InstanceID: ConfigInstanceID,
+		Invoke: &Invoke{
+			ContractID: ContractConfigID,
+			Command:    "update_config",
+			Args:       []Argument{{Name: "config", Value: configBuf}},
+		},
+		SignerCounter: []uint64{4},
+		version:       CurrentVersion,
+	})
+	require.NoError(t, err)
+	s.sendTxAndWait(t, ctx, 10)
+
+	send := func(tx ClientTransaction) *AddTxResponse {
+		resp, err := s.service().AddTransaction(&AddTxRequest{
+			Version:       CurrentVersion,
+			SkipchainID:   s.genesis.SkipChainID(),
+			Transaction:   tx,
+			InclusionWait: 10,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Without fee payer, the transaction is refused.
+	instr = createSpawnInstr(s.darc.GetBaseID(), dummyContract, "data", []byte("no payer"))
+	instr.SignerCounter = []uint64{5}
+	ctx, err = combineInstrsAndSign(s.signer, instr)
+	require.NoError(t, err)
+	require.Contains(t, send(ctx).Error, "no fee payer")
+
+	// The fee payer is signed, so it cannot be replaced.
+	instr = createSpawnInstr(s.darc.GetBaseID(), dummyContract, "data", []byte("payer"))
+	instr.SignerCounter = []uint64{5}
+	ctx = NewClientTransaction(CurrentVersion, instr)
+	ctx.FeePayer = &payer
+	require.NoError(t, ctx.FillSignersAndSignWith(s.signer))
+	swapped := ctx.Clone()
+	other := NewInstanceID(s.darc.GetBaseID())
+	swapped.FeePayer = &other
+	require.NotEmpty(t, send(swapped).Error)
+
+	transactionOK(t, send(ctx), nil)
+	resp, err := s.service().GetProof(&GetProof{
+		Version: CurrentVersion,
+		Key:     payer.Slice(),
+		ID:      s.genesis.SkipChainID(),
+	})
+	require.NoError(t, err)
+	buf, _, _, err := resp.Proof.Get(payer.Slice())
+	require.NoError(t, err)
+	var c Coin
+	require.NoError(t, protobuf.Decode(buf, &c))
+	require.Equal(t, uint64(89), c.Value)
+}
+
 func TestService_SetConfigRosterKeepLeader(t *testing.T) {
 	n := 6
 	if testing.Short() {
@@ -2129,6 +2473,107 @@ func TestService_DownloadState(t *testing.T) {
 	}
 }
 
+// Checks that snapshots are created every SnapshotInterval blocks, that the
+// blocks before the snapshot are pruned, and that a new node can start from
+// the snapshot.
+func TestService_Snapshot(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	cfdb := catchupFetchDBEntries
+	defer func() {
+		catchupFetchDBEntries = cfdb
+	}()
+	catchupFetchDBEntries = 10
+
+	for _, service := range s.services {
+		service.pruneBlocks = true
+	}
+
+	config, err := s.service().LoadConfig(s.genesis.SkipChainID())
+	require.NoError(t, err)
+	config.SnapshotInterval = 3
+	configBuf, err := protobuf.Encode(config)
+	require.NoError(t, err)
+	ctx, err := combineInstrsAndSign(s.signer, Instruction{
+		InstanceID: ConfigInstanceID,
+		Invoke: &Invoke{
+			ContractID: ContractConfigID,
+			Command:    "update_config",
+			Args:       []Argument{{Name: "config", Value: configBuf}},
+		},
+		SignerCounter: []uint64{1},
+		version:       CurrentVersion,
+	})
+	require.NoError(t, err)
+	s.sendTxAndWait(t, ctx, 10)
+
+	log.Lvl1("Adding dummy transactions")
+	addDummyTxs(t, s, 5, 1, 2)
+
+	latest, err := s.service().db().GetLatest(s.genesis)
+	require.NoError(t, err)
+	require.True(t, latest.Index >= 6)
+	snapIndex := latest.Index - latest.Index%3
+
+	for _, service := range s.services {
+		// The snapshot is copied in the background.
+		var st *stateTrie
+		for i := 0; i < 10; i++ {
+			service.snapshotLock.RLock()
+			st, err = loadStateTrie(latestSnapshotDB(service.stateStorage, s.genesis.SkipChainID()))
+			service.snapshotLock.RUnlock()
+			if err == nil && st.GetIndex() == snapIndex {
+				break
+			}
+			time.Sleep(s.interval)
+		}
+		require.NoError(t, err)
+		require.Equal(t, snapIndex, st.GetIndex())
+		reply, err := service.skService().GetSingleBlockByIndex(
+			&skipchain.GetSingleBlockByIndex{Genesis: s.genesis.SkipChainID(), Index: snapIndex})
+		require.NoError(t, err)
+		header, err := decodeBlockHeader(reply.SkipBlock)
+		require.NoError(t, err)
+		require.Equal(t, header.TrieRoot, st.GetRoot())
+	}
+
+	log.Lvl1("Checking pruned blocks")
+	for i := 0; i < 10; i++ {
+		reply, err := s.service().skService().GetSingleBlockByIndex(
+			&skipchain.GetSingleBlockByIndex{Genesis: s.genesis.SkipChainID(), Index: snapIndex - 1})
+		require.NoError(t, err)
+		if isPruned(reply.SkipBlock) {
+			break
+		}
+		require.NotEqual(t, 9, i, "block has not been pruned")
+		time.Sleep(s.interval)
+	}
+	require.False(t, isPruned(s.service().db().GetByID(s.genesis.SkipChainID())))
+	_, err = s.service().ReplayState(s.genesis.SkipChainID(), silentReplayLog{},
+		ReplayStateOptions{MaxBlocks: -1})
+	require.Error(t, err)
+	_, err = s.service().GetProofAt(&GetProofAt{
+		Version:    CurrentVersion,
+		Key:        ConfigInstanceID.Slice(),
+		ID:         s.genesis.SkipChainID(),
+		BlockIndex: snapIndex - 1,
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "pruned")
+
+	log.Lvl1("Download on new node")
+	stateTrie, err := s.service().getStateTrie(s.genesis.SkipChainID())
+	require.NoError(t, err)
+	servers, _, _ := s.local.MakeSRS(cothority.Suite, 1, ByzCoinID)
+	service := s.local.GetServices(servers, ByzCoinID)[0].(*Service)
+	require.NoError(t, service.downloadDB(latest))
+	st, err := service.getStateTrie(s.genesis.SkipChainID())
+	require.NoError(t, err)
+	require.Equal(t, stateTrie.GetIndex(), st.GetIndex())
+	require.Equal(t, stateTrie.GetRoot(), st.GetRoot())
+}
+
 // Download the state in a running Byzcoin, with a node sudeenly being caught by Amnesia.
 // This is different from the above tests, as a node needs to be able to catch up
 // while a full running byzcoin is in place.
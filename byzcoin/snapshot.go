@@ -0,0 +1,178 @@
+package byzcoin
+
+import (
+	"bytes"
+
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// PruneEnv is the environment variable that allows a conode to prune the
+// blocks of its chains. If it is set to "true", the payloads of all blocks
+// older than the latest snapshot of the state are removed whenever a new
+// snapshot is created. The headers and forward links of the blocks are kept,
+// so the chain can still be verified, but the node cannot replay the state
+// from the genesis block anymore.
+const PruneEnv = "COTHORITY_BYZCOIN_PRUNE"
+
+// SnapshotsEnv is the environment variable setting the number of snapshots a
+// conode keeps for every chain. GetProofAt replays the past states from the
+// latest snapshot before them, so older snapshots are removed only if it is
+// set to a number bigger than 0.
+const SnapshotsEnv = "COTHORITY_BYZCOIN_SNAPSHOTS"
+
+// snapshotDue returns true if a snapshot of the state must be created after
+// applying the block with the given index.
+func snapshotDue(interval int, index int) bool {
+	return interval > 0 && index > 0 && index%interval == 0
+}
+
+// isPruned returns true if the payload of the block has been removed by
+// pruning. Blocks without transactions, e.g., the blocks upgrading the
+// version, have an empty payload, too, so the hash in the header is used to
+// tell them apart.
+func isPruned(sb *skipchain.SkipBlock) bool {
+	if sb.Index == 0 || len(sb.Payload) > 0 {
+		return false
+	}
+	header, err := decodeBlockHeader(sb)
+	if err != nil {
+		return false
+	}
+	return !bytes.Equal(header.ClientTransactionHash, TxResults{}.Hash())
+}
+
+// createSnapshot adds a copy of the current state trie to the snapshots of
+// the given chain. It must be called while holding updateTrieLock, with the
+// index of the block that has just been applied, but the copy is done in the
+// background. If pruning is enabled, the blocks before the snapshot are
+// pruned afterwards. A snapshot of a chain is skipped while the previous one
+// is still being copied.
+func (s *Service) createSnapshot(scID skipchain.SkipBlockID, index int) error {
+	s.snapshotsLock.Lock()
+	defer s.snapshotsLock.Unlock()
+	if s.snapshotsRunning[string(scID)] {
+		log.Warnf("%s: skipping snapshot of %x, the previous one is still "+
+			"being copied", s.ServerIdentity(), scID)
+		return nil
+	}
+	copySnapshot, err := s.stateStorage.createSnapshot(scID, index,
+		s.keepSnapshots, &s.snapshotLock)
+	if err != nil {
+		return xerrors.Errorf("starting copy: %v", err)
+	}
+	s.snapshotsRunning[string(scID)] = true
+
+	s.working.Add(1)
+	go func() {
+		defer s.working.Done()
+		defer func() {
+			s.snapshotsLock.Lock()
+			delete(s.snapshotsRunning, string(scID))
+			s.snapshotsLock.Unlock()
+		}()
+		if err := s.copySnapshot(scID, copySnapshot); err != nil {
+			log.Errorf("%s: couldn't create snapshot: %v", s.ServerIdentity(), err)
+		}
+	}()
+	return nil
+}
+
+// copySnapshot runs the copy returned by the storage and prunes the blocks
+// before the new snapshot, if enabled.
+func (s *Service) copySnapshot(scID skipchain.SkipBlockID, copySnapshot func() error) error {
+	if err := copySnapshot(); err != nil {
+		return xerrors.Errorf("copying trie: %v", err)
+	}
+	s.snapshotLock.RLock()
+	st, err := loadStateTrie(latestSnapshotDB(s.stateStorage, scID))
+	s.snapshotLock.RUnlock()
+	if err != nil {
+		return xerrors.Errorf("loading snapshot: %v", err)
+	}
+	index := st.GetIndex()
+	log.Lvlf2("%s: created snapshot of %x at block %d", s.ServerIdentity(),
+		scID, index)
+
+	if s.pruneBlocks {
+		if err := s.pruneBefore(scID, index); err != nil {
+			return xerrors.Errorf("pruning blocks: %v", err)
+		}
+	}
+	return nil
+}
+
+// pruneBefore removes the payloads of all blocks of the chain before the
+// given index, except for the genesis block. It stops at the first block that
+// is already pruned, as all blocks before it have been pruned previously.
+func (s *Service) pruneBefore(scID skipchain.SkipBlockID, index int) error {
+	reply, err := s.skService().GetSingleBlockByIndex(
+		&skipchain.GetSingleBlockByIndex{Genesis: scID, Index: index})
+	if err != nil {
+		return xerrors.Errorf("getting block %d: %v", index, err)
+	}
+	sb := reply.SkipBlock
+	var pruned int
+	for len(sb.BackLinkIDs) > 0 {
+		sb = s.db().GetByID(sb.BackLinkIDs[0])
+		if sb == nil {
+			return xerrors.New("missing block")
+		}
+		if sb.Index == 0 || isPruned(sb) {
+			break
+		}
+		if err := s.db().PruneBlock(sb.Hash); err != nil {
+			return xerrors.Errorf("pruning block %d: %v", sb.Index, err)
+		}
+		pruned++
+	}
+	log.Lvlf2("%s: pruned %d blocks before block %d", s.ServerIdentity(),
+		pruned, index)
+	return nil
+}
+
+// replayBlocks applies the blocks following from to the state trie, until
+// it reaches the index of latest. It is used after downloading a snapshot,
+// which is older than the latest block. The blocks are fetched from the other nodes
+// and the skipchain client verifies that they are correctly linked.
+func (s *Service) replayBlocks(st *stateTrie, from *skipchain.SkipBlock,
+	latest *skipchain.SkipBlock) error {
+	cl := skipchain.NewClient()
+	cl.DontContact(s.ServerIdentity())
+	for from.Index < latest.Index {
+		updates, err := cl.GetUpdateChainLevel(latest.Roster, from.Hash, 1,
+			catchupFetchBlocks)
+		if err != nil {
+			return xerrors.Errorf("getting blocks: %v", err)
+		}
+		if len(updates) < 2 {
+			return xerrors.Errorf("no blocks after %d", from.Index)
+		}
+		for _, sb := range updates[1:] {
+			if isPruned(sb) {
+				return xerrors.Errorf("block %d is pruned", sb.Index)
+			}
+			header, err := decodeBlockHeader(sb)
+			if err != nil {
+				return xerrors.Errorf("decoding header: %v", err)
+			}
+			var body DataBody
+			if err := protobuf.Decode(sb.Payload, &body); err != nil {
+				return xerrors.Errorf("decoding body: %v", err)
+			}
+			_, _, scs, _ := s.createStateChanges(st.MakeStagingStateTrie(),
+				sb.SkipChainID(), body.TxResults, noTimeout, header.Version,
+				header.Timestamp)
+			err = st.VerifiedStoreAll(scs, sb.Index, header.Version,
+				header.TrieRoot)
+			if err != nil {
+				return xerrors.Errorf("storing state changes of block %d: %v",
+					sb.Index, err)
+			}
+		}
+		from = updates[len(updates)-1]
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"sync"
+
 	"go.dedis.ch/cothority/v3/byzcoin/trie"
 	"go.dedis.ch/kyber/v3/pairing"
 	"golang.org/x/xerrors"
@@ -22,6 +24,17 @@ type ReplayStateLog interface {
 	LogWarn(sb *skipchain.SkipBlock, msg, dump string)
 }
 
+// silentReplayLog is used when replaying the state to answer a request.
+type silentReplayLog struct{}
+
+func (silentReplayLog) LogNewBlock(*skipchain.SkipBlock) {}
+
+func (silentReplayLog) LogAppliedBlock(*skipchain.SkipBlock, DataHeader, DataBody) {}
+
+func (silentReplayLog) LogWarn(sb *skipchain.SkipBlock, msg, dump string) {
+	log.Warnf("replaying block %d: %s", sb.Index, msg)
+}
+
 // ReplayStateOptions is a placeholder for all future options.
 // If you add a new option, be sure to keep the empty value as default.
 type ReplayStateOptions struct {
@@ -30,6 +43,99 @@ type ReplayStateOptions struct {
 	StartingTrie trie.DB
 }
 
+// maxReplayBlocks is the maximum number of blocks GetProofAt replays to
+// answer a request. Older states can only be asked for if there is a snapshot
+// close enough to them, which is why the SnapshotInterval cannot be bigger.
+var maxReplayBlocks = 1000
+
+// replayCacheSize is the number of replayed states kept by replayCache.
+const replayCacheSize = 8
+
+// replayCache keeps the states rebuilt for GetProofAt, so that requests for
+// the same block don't replay the chain again. It also makes sure that only
+// one state is replayed at a time.
+type replayCache struct {
+	sync.Mutex
+	replaying bool
+	keys      []string
+	tries     map[string]*stateTrie
+}
+
+func replayCacheKey(scID skipchain.SkipBlockID, index int) string {
+	return fmt.Sprintf("%x/%d", scID, index)
+}
+
+func (c *replayCache) get(scID skipchain.SkipBlockID, index int) *stateTrie {
+	c.Lock()
+	defer c.Unlock()
+	return c.tries[replayCacheKey(scID, index)]
+}
+
+func (c *replayCache) add(scID skipchain.SkipBlockID, index int, st *stateTrie) {
+	c.Lock()
+	defer c.Unlock()
+	if c.tries == nil {
+		c.tries = make(map[string]*stateTrie)
+	}
+	key := replayCacheKey(scID, index)
+	if _, ok := c.tries[key]; ok {
+		return
+	}
+	if len(c.keys) >= replayCacheSize {
+		delete(c.tries, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+	c.keys = append(c.keys, key)
+	c.tries[key] = st
+}
+
+// start returns false if another state is being replayed. Otherwise, done
+// must be called once the replay is finished.
+func (c *replayCache) start() bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.replaying {
+		return false
+	}
+	c.replaying = true
+	return true
+}
+
+func (c *replayCache) done() {
+	c.Lock()
+	c.replaying = false
+	c.Unlock()
+}
+
+// snapshotCopy returns an in-memory copy of the latest snapshot of the chain
+// at or before the given index, and the index of its block. It returns nil if
+// there is no such snapshot.
+func (s *Service) snapshotCopy(scID skipchain.SkipBlockID, index int) (trie.DB, int, error) {
+	s.snapshotLock.RLock()
+	defer s.snapshotLock.RUnlock()
+	snapIndex := snapshotBefore(s.stateStorage.snapshots(scID), index)
+	if snapIndex < 0 {
+		return nil, 0, nil
+	}
+	snap := s.stateStorage.snapshotDB(scID, snapIndex)
+	st, err := loadStateTrie(snap)
+	if err != nil {
+		return nil, 0, xerrors.Errorf("loading snapshot %d: %v", snapIndex, err)
+	}
+	mem := trie.NewMemDB()
+	err = mem.Update(func(dst trie.Bucket) error {
+		return snap.View(func(src trie.Bucket) error {
+			return src.ForEach(func(k, v []byte) error {
+				return dst.Put(k, v)
+			})
+		})
+	})
+	if err != nil {
+		return nil, 0, xerrors.Errorf("copying snapshot: %v", err)
+	}
+	return mem, st.GetIndex(), nil
+}
+
 func replayError(sb *skipchain.SkipBlock, err error) error {
 	return cothority.ErrorOrNilSkip(err, fmt.Sprintf("replay failed in block at index %d with message", sb.Index), 2)
 }
@@ -100,6 +206,10 @@ func (s *Service) ReplayState(id skipchain.SkipBlockID,
 	for block := 0; block < opt.MaxBlocks; block++ {
 		rlog.LogNewBlock(sb)
 
+		if isPruned(sb) {
+			return nil, replayError(sb, xerrors.New("the payload of the block has been pruned"))
+		}
+
 		if sb.Payload != nil {
 			var dBody DataBody
 			err := protobuf.Decode(sb.Payload, &dBody)
@@ -126,7 +236,7 @@ func (s *Service) ReplayState(id skipchain.SkipBlockID,
 				if tx.Accepted {
 					txAccepted++
 					var scsTmp StateChanges
-					scsTmp, sst, err = s.processOneTx(sst, tx.ClientTransaction,
+					scsTmp, sst, err = s.replayOneTx(sst, tx.ClientTransaction,
 						id, dHead.Timestamp)
 					if err != nil {
 						return nil, replayError(sb, err)
@@ -134,7 +244,7 @@ func (s *Service) ReplayState(id skipchain.SkipBlockID,
 
 					scs = append(scs, scsTmp...)
 				} else {
-					_, _, err = s.processOneTx(sst, tx.ClientTransaction, id, dHead.Timestamp)
+					_, _, err = s.replayOneTx(sst, tx.ClientTransaction, id, dHead.Timestamp)
 					if err == nil {
 						return nil, replayError(sb, xerrors.New("refused transaction passes"))
 					}
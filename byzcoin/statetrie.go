@@ -14,7 +14,6 @@ import (
 	"go.dedis.ch/cothority/v3/byzcoin/trie"
 	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/skipchain"
-	"go.etcd.io/bbolt"
 	"golang.org/x/xerrors"
 )
 
@@ -287,8 +286,8 @@ type stateTrie struct {
 
 // loadStateTrie loads an existing StateTrie, an error is returned if no trie
 // exists in db
-func loadStateTrie(db *bbolt.DB, bucket []byte) (*stateTrie, error) {
-	t, err := trie.LoadTrie(trie.NewDiskDB(db, bucket))
+func loadStateTrie(db trie.DB) (*stateTrie, error) {
+	t, err := trie.LoadTrie(db)
 	if err != nil {
 		return nil, xerrors.Errorf("loading trie: %v", err)
 	}
@@ -297,8 +296,8 @@ func loadStateTrie(db *bbolt.DB, bucket []byte) (*stateTrie, error) {
 
 // newStateTrie creates a new, disk-based trie.Trie, an error is returned if
 // the db already contains a trie.
-func newStateTrie(db *bbolt.DB, bucket, nonce []byte) (*stateTrie, error) {
-	t, err := trie.NewTrie(trie.NewDiskDB(db, bucket), nonce)
+func newStateTrie(db trie.DB, nonce []byte) (*stateTrie, error) {
+	t, err := trie.NewTrie(db, nonce)
 	if err != nil {
 		return nil, xerrors.Errorf("creating trie: %v", err)
 	}
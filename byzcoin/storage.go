@@ -0,0 +1,540 @@
+package byzcoin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"go.dedis.ch/cothority/v3/byzcoin/trie"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+	"go.etcd.io/bbolt"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// StorageBBolt stores the state tries in a bucket of the bbolt database
+	// of the conode.
+	StorageBBolt = "bbolt"
+	// StorageLevelDB stores the state tries in a leveldb database next to
+	// the bbolt database of the conode.
+	StorageLevelDB = "leveldb"
+)
+
+// StorageConfig is the configuration of a conode telling where the state
+// tries are stored. It is kept in the bbolt database of the conode, so that
+// the state tries are always found where they have been migrated to, e.g.,
+// with `bcadmin db migrate`. Without configuration, StorageBBolt is used.
+// Only the state tries are moved, the skipblocks stay in the bbolt database,
+// as skipchain.SkipBlockDB exposes it.
+type StorageConfig struct {
+	// Backend is StorageBBolt or StorageLevelDB.
+	Backend string
+	// Path is the directory of the leveldb database.
+	Path string `protobuf:"opt"`
+}
+
+// StorageConfigBucket is the bucket of the bbolt database of a conode that
+// holds its StorageConfig.
+var StorageConfigBucket = []byte(ServiceName + "_storage")
+
+var storageConfigKey = []byte("config")
+
+// SaveStorageConfig stores the configuration in the bbolt database of a
+// conode. The conode must be stopped, and uses the configuration once it is
+// started again.
+func SaveStorageConfig(db *bbolt.DB, cfg StorageConfig) error {
+	if err := cfg.check(); err != nil {
+		return err
+	}
+	buf, err := protobuf.Encode(&cfg)
+	if err != nil {
+		return xerrors.Errorf("encoding config: %v", err)
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(StorageConfigBucket)
+		if err != nil {
+			return xerrors.Errorf("creating bucket: %v", err)
+		}
+		return b.Put(storageConfigKey, buf)
+	})
+}
+
+func loadStorageConfig(db *bbolt.DB, bucket []byte) (StorageConfig, error) {
+	cfg := StorageConfig{Backend: StorageBBolt}
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+		buf := b.Get(storageConfigKey)
+		if buf == nil {
+			return nil
+		}
+		return protobuf.Decode(buf, &cfg)
+	})
+	if err != nil {
+		return cfg, xerrors.Errorf("reading config: %v", err)
+	}
+	return cfg, cfg.check()
+}
+
+func (cfg StorageConfig) check() error {
+	switch cfg.Backend {
+	case StorageBBolt:
+	case StorageLevelDB:
+		if cfg.Path == "" {
+			return xerrors.New("leveldb storage needs a path")
+		}
+	default:
+		return xerrors.Errorf("unknown storage backend '%s'", cfg.Backend)
+	}
+	return nil
+}
+
+// LevelDBPath returns the directory of the leveldb database of a conode whose
+// bbolt database is stored in boltPath.
+func LevelDBPath(boltPath string) string {
+	return boltPath + ".leveldb"
+}
+
+// LevelDBPrefix returns the prefix of all keys of the state trie of the given
+// chain in the leveldb database.
+func LevelDBPrefix(scID skipchain.SkipBlockID) []byte {
+	return []byte(fmt.Sprintf("%x/", scID))
+}
+
+// Every snapshot of a chain is stored under the index of its block, and the
+// list of these indexes is kept apart. A new snapshot is only added to the
+// list once it is completely written, so that the list only holds complete
+// snapshots.
+
+// levelDBSnapshotKey returns the key of the list of the snapshots of the
+// given chain in the leveldb database.
+func levelDBSnapshotKey(scID skipchain.SkipBlockID) []byte {
+	return []byte(fmt.Sprintf("%x-snapshot", scID))
+}
+
+// levelDBSnapshotPrefix returns the prefix of all keys of the snapshot of
+// the given block of the chain in the leveldb database.
+func levelDBSnapshotPrefix(scID skipchain.SkipBlockID, index int) []byte {
+	return []byte(fmt.Sprintf("%x-snapshot-%d/", scID, index))
+}
+
+// boltSnapshotBucket returns the name of the bucket holding the snapshot of
+// the given block of the chain.
+func boltSnapshotBucket(scID skipchain.SkipBlockID, index int) []byte {
+	return []byte(fmt.Sprintf("%x-snapshot-%d", scID, index))
+}
+
+// boltSnapshotsBucket is the bucket holding the list of the snapshots of
+// every chain.
+var boltSnapshotsBucket = []byte("snapshots")
+
+// encodeSnapshotIndexes encodes the sorted indexes of the snapshots of a
+// chain.
+func encodeSnapshotIndexes(indexes []int) []byte {
+	buf := make([]byte, 8*len(indexes))
+	for i, index := range indexes {
+		binary.BigEndian.PutUint64(buf[8*i:], uint64(index))
+	}
+	return buf
+}
+
+func decodeSnapshotIndexes(buf []byte) []int {
+	indexes := make([]int, len(buf)/8)
+	for i := range indexes {
+		indexes[i] = int(binary.BigEndian.Uint64(buf[8*i:]))
+	}
+	return indexes
+}
+
+// snapshotBefore returns the largest of the sorted indexes that is not
+// bigger than index, or -1 if there is none.
+func snapshotBefore(indexes []int, index int) int {
+	i := sort.SearchInts(indexes, index+1)
+	if i == 0 {
+		return -1
+	}
+	return indexes[i-1]
+}
+
+// addSnapshotIndex adds index to the sorted indexes and returns the new
+// list, without the oldest indexes exceeding keep, and the removed indexes.
+// If keep is 0, all indexes are kept.
+func addSnapshotIndex(indexes []int, index int, keep int) ([]int, []int) {
+	i := sort.SearchInts(indexes, index)
+	if i == len(indexes) || indexes[i] != index {
+		indexes = append(indexes[:i:i], append([]int{index}, indexes[i:]...)...)
+	}
+	if keep > 0 && len(indexes) > keep {
+		return indexes[len(indexes)-keep:], indexes[:len(indexes)-keep]
+	}
+	return indexes, nil
+}
+
+// snapshotBatch is the number of keys written at once when creating a
+// snapshot, so that other writes are not blocked for too long.
+const snapshotBatch = 1000
+
+// stateStorage holds the state tries of all chains of a conode.
+type stateStorage interface {
+	// trieDB returns the database of the state trie of the given chain.
+	trieDB(scID skipchain.SkipBlockID) trie.DB
+	// removeTrie deletes the state trie of the given chain.
+	removeTrie(scID skipchain.SkipBlockID) error
+	// snapshots returns the indexes of the blocks of the snapshots of the
+	// given chain, in increasing order.
+	snapshots(scID skipchain.SkipBlockID) []int
+	// snapshotDB returns the database of the snapshot of the given block of
+	// the chain. It is empty if there is no such snapshot.
+	snapshotDB(scID skipchain.SkipBlockID, index int) trie.DB
+	// createSnapshot takes a consistent view of the current state trie of
+	// the given chain, at the block with the given index, and returns a
+	// function copying it to a new snapshot. The function can be called
+	// while the trie is updated, and must be called to release the view.
+	// The new snapshot is added while holding swap, and the oldest ones
+	// are removed so that at most keep snapshots are left, or all of them
+	// if keep is 0.
+	createSnapshot(scID skipchain.SkipBlockID, index int, keep int,
+		swap sync.Locker) (func() error, error)
+	// removeSnapshot deletes all snapshots of the given chain.
+	removeSnapshot(scID skipchain.SkipBlockID) error
+	// close releases the storage. It must not be used afterwards.
+	close() error
+}
+
+// latestSnapshotDB returns the database of the latest snapshot of the given
+// chain. It is empty if no snapshot has been created.
+func latestSnapshotDB(st stateStorage, scID skipchain.SkipBlockID) trie.DB {
+	indexes := st.snapshots(scID)
+	if len(indexes) == 0 {
+		return trie.NewMemDB()
+	}
+	return st.snapshotDB(scID, indexes[len(indexes)-1])
+}
+
+func newStateStorage(c *onet.Context) (stateStorage, error) {
+	db, bucket := c.GetAdditionalBucket([]byte("storage"))
+	cfg, err := loadStorageConfig(db, bucket)
+	if err != nil {
+		return nil, xerrors.Errorf("storage config: %v", err)
+	}
+	switch cfg.Backend {
+	case StorageLevelDB:
+		log.Lvl2("Storing state tries in", cfg.Path)
+		ldb, err := openLevelDB(cfg.Path)
+		if err != nil {
+			return nil, xerrors.Errorf("opening leveldb: %v", err)
+		}
+		return &levelStorage{db: ldb, path: cfg.Path}, nil
+	default:
+		return &boltStorage{c}, nil
+	}
+}
+
+// levelDBs holds the leveldb databases opened by the services of this
+// process. As a database can only be opened once, services sharing one, e.g.
+// in tests or in bcadmin, share the handle, which is closed by the last one.
+var levelDBs = struct {
+	sync.Mutex
+	open map[string]*sharedLevelDB
+}{open: make(map[string]*sharedLevelDB)}
+
+type sharedLevelDB struct {
+	db   *leveldb.DB
+	refs int
+}
+
+func openLevelDB(path string) (*leveldb.DB, error) {
+	levelDBs.Lock()
+	defer levelDBs.Unlock()
+	if s, ok := levelDBs.open[path]; ok {
+		s.refs++
+		return s.db, nil
+	}
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	levelDBs.open[path] = &sharedLevelDB{db: db, refs: 1}
+	return db, nil
+}
+
+func closeLevelDB(path string) error {
+	levelDBs.Lock()
+	defer levelDBs.Unlock()
+	s, ok := levelDBs.open[path]
+	if !ok {
+		return xerrors.Errorf("leveldb %s is not open", path)
+	}
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	delete(levelDBs.open, path)
+	return s.db.Close()
+}
+
+// boltStorage keeps every state trie in its own bucket.
+type boltStorage struct {
+	c *onet.Context
+}
+
+func (s *boltStorage) trieDB(scID skipchain.SkipBlockID) trie.DB {
+	db, name := s.c.GetAdditionalBucket([]byte(fmt.Sprintf("%x", scID)))
+	return trie.NewDiskDB(db, name)
+}
+
+func (s *boltStorage) removeTrie(scID skipchain.SkipBlockID) error {
+	db, name := s.c.GetAdditionalBucket([]byte(fmt.Sprintf("%x", scID)))
+	return db.Update(func(tx *bbolt.Tx) error {
+		return tx.DeleteBucket(name)
+	})
+}
+
+func (s *boltStorage) snapshots(scID skipchain.SkipBlockID) []int {
+	db, name := s.c.GetAdditionalBucket(boltSnapshotsBucket)
+	var indexes []int
+	db.View(func(tx *bbolt.Tx) error {
+		indexes = decodeSnapshotIndexes(tx.Bucket(name).Get(scID))
+		return nil
+	})
+	return indexes
+}
+
+func (s *boltStorage) snapshotDB(scID skipchain.SkipBlockID, index int) trie.DB {
+	db, name := s.c.GetAdditionalBucket(boltSnapshotBucket(scID, index))
+	return trie.NewDiskDB(db, name)
+}
+
+func (s *boltStorage) createSnapshot(scID skipchain.SkipBlockID, index int,
+	keep int, swap sync.Locker) (func() error, error) {
+	if snapshotBefore(s.snapshots(scID), index) == index {
+		return func() error { return nil }, nil
+	}
+	db, name := s.c.GetAdditionalBucket([]byte(fmt.Sprintf("%x", scID)))
+	// The read-only transaction is a consistent view of the trie, even if
+	// new blocks arrive in the meantime.
+	view, err := db.Begin(false)
+	if err != nil {
+		return nil, xerrors.Errorf("starting transaction: %v", err)
+	}
+	return func() error {
+		// Writing while the view is open could block the writes forever if
+		// the database needs to grow, so the trie is read first.
+		var keys, values [][]byte
+		src := view.Bucket(name)
+		if src == nil {
+			view.Rollback()
+			return xerrors.New("no state trie found")
+		}
+		err := src.ForEach(func(k, v []byte) error {
+			keys = append(keys, append([]byte{}, k...))
+			values = append(values, append([]byte{}, v...))
+			return nil
+		})
+		view.Rollback()
+		if err != nil {
+			return xerrors.Errorf("reading trie: %v", err)
+		}
+
+		_, dstName := s.c.GetAdditionalBucket(boltSnapshotBucket(scID, index))
+		if err := s.clearBucket(db, dstName); err != nil {
+			return xerrors.Errorf("clearing snapshot: %v", err)
+		}
+		for start := 0; start < len(keys); start += snapshotBatch {
+			end := start + snapshotBatch
+			if end > len(keys) {
+				end = len(keys)
+			}
+			err := db.Update(func(tx *bbolt.Tx) error {
+				dst := tx.Bucket(dstName)
+				for i := start; i < end; i++ {
+					if err := dst.Put(keys[i], values[i]); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return xerrors.Errorf("writing snapshot: %v", err)
+			}
+		}
+
+		_, ptrName := s.c.GetAdditionalBucket(boltSnapshotsBucket)
+		var removed []int
+		swap.Lock()
+		err = db.Update(func(tx *bbolt.Tx) error {
+			var indexes []int
+			indexes, removed = addSnapshotIndex(
+				decodeSnapshotIndexes(tx.Bucket(ptrName).Get(scID)), index, keep)
+			return tx.Bucket(ptrName).Put(scID, encodeSnapshotIndexes(indexes))
+		})
+		swap.Unlock()
+		if err != nil {
+			return xerrors.Errorf("adding snapshot: %v", err)
+		}
+		return s.deleteBuckets(db, scID, removed)
+	}, nil
+}
+
+// deleteBuckets removes the buckets of the given snapshots of the chain.
+func (s *boltStorage) deleteBuckets(db *bbolt.DB, scID skipchain.SkipBlockID,
+	indexes []int) error {
+	var names [][]byte
+	for _, index := range indexes {
+		_, name := s.c.GetAdditionalBucket(boltSnapshotBucket(scID, index))
+		names = append(names, name)
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range names {
+			err := tx.DeleteBucket(name)
+			if err != nil && err != bbolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// clearBucket removes all keys of the bucket, but keeps the bucket itself.
+func (s *boltStorage) clearBucket(db *bbolt.DB, name []byte) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.DeleteBucket(name); err != nil &&
+			err != bbolt.ErrBucketNotFound {
+			return err
+		}
+		_, err := tx.CreateBucket(name)
+		return err
+	})
+}
+
+func (s *boltStorage) removeSnapshot(scID skipchain.SkipBlockID) error {
+	db, ptrName := s.c.GetAdditionalBucket(boltSnapshotsBucket)
+	if err := s.deleteBuckets(db, scID, s.snapshots(scID)); err != nil {
+		return err
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(ptrName).Delete(scID)
+	})
+}
+
+// close does nothing, as the bbolt database belongs to onet.
+func (s *boltStorage) close() error {
+	return nil
+}
+
+// levelStorage keeps all state tries in one leveldb database, using a
+// different key prefix for every chain.
+type levelStorage struct {
+	db   *leveldb.DB
+	path string
+}
+
+func (s *levelStorage) close() error {
+	return closeLevelDB(s.path)
+}
+
+func (s *levelStorage) trieDB(scID skipchain.SkipBlockID) trie.DB {
+	return trie.NewLevelDB(s.db, LevelDBPrefix(scID))
+}
+
+func (s *levelStorage) removeTrie(scID skipchain.SkipBlockID) error {
+	return s.removePrefix(LevelDBPrefix(scID))
+}
+
+func (s *levelStorage) snapshots(scID skipchain.SkipBlockID) []int {
+	v, err := s.db.Get(levelDBSnapshotKey(scID), nil)
+	if err != nil {
+		return nil
+	}
+	return decodeSnapshotIndexes(v)
+}
+
+func (s *levelStorage) snapshotDB(scID skipchain.SkipBlockID, index int) trie.DB {
+	return trie.NewLevelDB(s.db, levelDBSnapshotPrefix(scID, index))
+}
+
+func (s *levelStorage) createSnapshot(scID skipchain.SkipBlockID, index int,
+	keep int, swap sync.Locker) (func() error, error) {
+	if snapshotBefore(s.snapshots(scID), index) == index {
+		return func() error { return nil }, nil
+	}
+	// Reading from a leveldb-snapshot makes sure that the copy is
+	// consistent, even if new blocks arrive in the meantime.
+	snap, err := s.db.GetSnapshot()
+	if err != nil {
+		return nil, xerrors.Errorf("getting snapshot: %v", err)
+	}
+	return func() error {
+		defer snap.Release()
+		dstPrefix := levelDBSnapshotPrefix(scID, index)
+		if err := s.removePrefix(dstPrefix); err != nil {
+			return xerrors.Errorf("clearing snapshot: %v", err)
+		}
+
+		srcPrefix := LevelDBPrefix(scID)
+		batch := new(leveldb.Batch)
+		it := snap.NewIterator(util.BytesPrefix(srcPrefix), nil)
+		defer it.Release()
+		for it.Next() {
+			key := append(append([]byte{}, dstPrefix...), it.Key()[len(srcPrefix):]...)
+			batch.Put(key, it.Value())
+			if batch.Len() >= snapshotBatch {
+				if err := s.db.Write(batch, nil); err != nil {
+					return xerrors.Errorf("writing snapshot: %v", err)
+				}
+				batch.Reset()
+			}
+		}
+		if err := it.Error(); err != nil {
+			return xerrors.Errorf("iterating trie: %v", err)
+		}
+		if err := s.db.Write(batch, nil); err != nil {
+			return xerrors.Errorf("writing snapshot: %v", err)
+		}
+
+		swap.Lock()
+		indexes, removed := addSnapshotIndex(s.snapshots(scID), index, keep)
+		err := s.db.Put(levelDBSnapshotKey(scID), encodeSnapshotIndexes(indexes), nil)
+		swap.Unlock()
+		if err != nil {
+			return xerrors.Errorf("adding snapshot: %v", err)
+		}
+		for _, old := range removed {
+			if err := s.removePrefix(levelDBSnapshotPrefix(scID, old)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+func (s *levelStorage) removeSnapshot(scID skipchain.SkipBlockID) error {
+	for _, index := range s.snapshots(scID) {
+		if err := s.removePrefix(levelDBSnapshotPrefix(scID, index)); err != nil {
+			return err
+		}
+	}
+	return s.db.Delete(levelDBSnapshotKey(scID), nil)
+}
+
+// removePrefix deletes all keys starting with the given prefix.
+func (s *levelStorage) removePrefix(prefix []byte) error {
+	batch := new(leveldb.Batch)
+	it := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	for it.Next() {
+		batch.Delete(append([]byte{}, it.Key()...))
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return xerrors.Errorf("iterating trie: %v", err)
+	}
+	return s.db.Write(batch, nil)
+}
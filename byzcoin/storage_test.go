@@ -0,0 +1,160 @@
+package byzcoin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/syndtr/goleveldb/leveldb"
+	"go.etcd.io/bbolt"
+)
+
+func TestStorageConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "byzcoin-storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := bbolt.Open(filepath.Join(dir, "conode.db"), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Without configuration, the tries are stored in bbolt.
+	cfg, err := loadStorageConfig(db, StorageConfigBucket)
+	require.NoError(t, err)
+	require.Equal(t, StorageBBolt, cfg.Backend)
+
+	require.Error(t, SaveStorageConfig(db, StorageConfig{Backend: StorageLevelDB}))
+	require.Error(t, SaveStorageConfig(db, StorageConfig{Backend: "other"}))
+	level := StorageConfig{Backend: StorageLevelDB, Path: filepath.Join(dir, "leveldb")}
+	require.NoError(t, SaveStorageConfig(db, level))
+	cfg, err = loadStorageConfig(db, StorageConfigBucket)
+	require.NoError(t, err)
+	require.Equal(t, level, cfg)
+}
+
+func TestOpenLevelDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "byzcoin-leveldb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The database is shared and closed with the last reference.
+	db1, err := openLevelDB(dir)
+	require.NoError(t, err)
+	db2, err := openLevelDB(dir)
+	require.NoError(t, err)
+	require.Equal(t, db1, db2)
+	require.NoError(t, closeLevelDB(dir))
+	require.NoError(t, db2.Put([]byte("key"), []byte("value"), nil))
+	require.NoError(t, closeLevelDB(dir))
+	require.Error(t, db1.Put([]byte("key"), []byte("value"), nil))
+	require.Error(t, closeLevelDB(dir))
+}
+
+func TestLevelStorage_Snapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "byzcoin-leveldb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := leveldb.OpenFile(dir, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	s := &levelStorage{db: db}
+	scID := getSBID("chain")
+
+	st, err := newStateTrie(s.trieDB(scID), []byte("nonce"))
+	require.NoError(t, err)
+	require.NoError(t, st.StoreAll(StateChanges{
+		{StateAction: Create, InstanceID: []byte("key"), Value: []byte("value")},
+	}, 1, CurrentVersion))
+
+	swap := &sync.Mutex{}
+	copySnapshot, err := s.createSnapshot(scID, 1, 2, swap)
+	require.NoError(t, err)
+	require.NoError(t, copySnapshot())
+	require.Equal(t, []int{1}, s.snapshots(scID))
+	snap, err := loadStateTrie(latestSnapshotDB(s, scID))
+	require.NoError(t, err)
+	require.Equal(t, st.GetRoot(), snap.GetRoot())
+	require.Equal(t, 1, snap.GetIndex())
+	root1 := snap.GetRoot()
+
+	// Newer states must not change the snapshot.
+	require.NoError(t, st.StoreAll(StateChanges{
+		{StateAction: Update, InstanceID: []byte("key"), Value: []byte("new")},
+	}, 2, CurrentVersion))
+	snap, err = loadStateTrie(latestSnapshotDB(s, scID))
+	require.NoError(t, err)
+	require.Equal(t, 1, snap.GetIndex())
+	require.NotEqual(t, st.GetRoot(), snap.GetRoot())
+
+	// The snapshot is taken when it is started, and only added once the
+	// copy is done.
+	copySnapshot, err = s.createSnapshot(scID, 2, 2, swap)
+	require.NoError(t, err)
+	require.NoError(t, st.StoreAll(StateChanges{
+		{StateAction: Update, InstanceID: []byte("key"), Value: []byte("newer")},
+	}, 3, CurrentVersion))
+	snap, err = loadStateTrie(latestSnapshotDB(s, scID))
+	require.NoError(t, err)
+	require.Equal(t, 1, snap.GetIndex())
+	require.NoError(t, copySnapshot())
+	require.Equal(t, []int{1, 2}, s.snapshots(scID))
+	snap, err = loadStateTrie(latestSnapshotDB(s, scID))
+	require.NoError(t, err)
+	require.Equal(t, 2, snap.GetIndex())
+
+	// The older snapshots are kept, up to the given number.
+	snap, err = loadStateTrie(s.snapshotDB(scID, 1))
+	require.NoError(t, err)
+	require.Equal(t, root1, snap.GetRoot())
+	copySnapshot, err = s.createSnapshot(scID, 3, 2, swap)
+	require.NoError(t, err)
+	require.NoError(t, copySnapshot())
+	require.Equal(t, []int{2, 3}, s.snapshots(scID))
+	_, err = loadStateTrie(s.snapshotDB(scID, 1))
+	require.Error(t, err)
+
+	// A snapshot of the same block is only made once.
+	copySnapshot, err = s.createSnapshot(scID, 3, 2, swap)
+	require.NoError(t, err)
+	require.NoError(t, copySnapshot())
+	require.Equal(t, []int{2, 3}, s.snapshots(scID))
+
+	// Removing the trie keeps the snapshots, and the other way round.
+	require.NoError(t, s.removeTrie(scID))
+	_, err = loadStateTrie(s.trieDB(scID))
+	require.Error(t, err)
+	_, err = loadStateTrie(latestSnapshotDB(s, scID))
+	require.NoError(t, err)
+	require.NoError(t, s.removeSnapshot(scID))
+	require.Empty(t, s.snapshots(scID))
+	_, err = loadStateTrie(latestSnapshotDB(s, scID))
+	require.Error(t, err)
+	_, err = loadStateTrie(s.snapshotDB(scID, 3))
+	require.Error(t, err)
+}
+
+func TestSnapshotIndexes(t *testing.T) {
+	indexes := []int{3, 6, 9}
+	require.Equal(t, indexes, decodeSnapshotIndexes(encodeSnapshotIndexes(indexes)))
+
+	require.Equal(t, -1, snapshotBefore(nil, 5))
+	require.Equal(t, -1, snapshotBefore(indexes, 2))
+	require.Equal(t, 3, snapshotBefore(indexes, 3))
+	require.Equal(t, 6, snapshotBefore(indexes, 8))
+	require.Equal(t, 9, snapshotBefore(indexes, 100))
+
+	added, removed := addSnapshotIndex(indexes, 12, 0)
+	require.Equal(t, []int{3, 6, 9, 12}, added)
+	require.Empty(t, removed)
+	added, removed = addSnapshotIndex(indexes, 12, 2)
+	require.Equal(t, []int{9, 12}, added)
+	require.Equal(t, []int{3, 6}, removed)
+	added, _ = addSnapshotIndex(indexes, 6, 0)
+	require.Equal(t, indexes, added)
+	added, _ = addSnapshotIndex(indexes, 4, 0)
+	require.Equal(t, []int{3, 4, 6, 9}, added)
+	require.Equal(t, []int{3, 6, 9}, indexes)
+}
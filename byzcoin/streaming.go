@@ -1,11 +1,14 @@
 package byzcoin
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
 
 	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
+	"golang.org/x/xerrors"
 )
 
 const (
@@ -26,13 +29,21 @@ const (
 
 func init() {
 	network.RegisterMessages(&StreamingRequest{}, &StreamingResponse{},
-		&PaginateRequest{}, &PaginateResponse{})
+		&PaginateRequest{}, &PaginateResponse{},
+		&FilteredStreamingRequest{}, &FilteredStreamingResponse{})
 }
 
 type streamingManager struct {
 	sync.Mutex
 	// key: skipchain ID, value: slice of listeners
 	listeners map[string][]chan *StreamingResponse
+	// key: skipchain ID, value: slice of listeners with a filter
+	filteredListeners map[string][]*filteredListener
+}
+
+type filteredListener struct {
+	filter  StreamingFilter
+	outChan chan *FilteredStreamingResponse
 }
 
 func (s *streamingManager) notify(scID string, block *skipchain.SkipBlock) {
@@ -51,6 +62,56 @@ func (s *streamingManager) notify(scID string, block *skipchain.SkipBlock) {
 	}
 }
 
+// notifyFiltered sends the state changes of the block to all listeners whose
+// filter matches at least one of them. It must be called when the trie is at
+// the index of the block, so that the proofs can be created.
+func (s *streamingManager) notifyFiltered(scID string, block *skipchain.SkipBlock,
+	txs TxResults, scs StateChanges, st ReadOnlyStateTrie, db *skipchain.SkipBlockDB) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, l := range s.filteredListeners[scID] {
+		matches := l.filter.filter(txs, scs)
+		if len(matches) == 0 {
+			continue
+		}
+		resp, err := newFilteredStreamingResponse(st, db, block, matches)
+		if err != nil {
+			log.Error("couldn't create filtered response:", err)
+			continue
+		}
+		l.outChan <- resp
+	}
+}
+
+func (s *streamingManager) newFilteredListener(scID string, filter StreamingFilter) chan *FilteredStreamingResponse {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.filteredListeners == nil {
+		s.filteredListeners = make(map[string][]*filteredListener)
+	}
+
+	outChan := make(chan *FilteredStreamingResponse)
+	s.filteredListeners[scID] = append(s.filteredListeners[scID],
+		&filteredListener{filter: filter, outChan: outChan})
+	return outChan
+}
+
+func (s *streamingManager) stopFilteredListener(scID string, outChan chan *FilteredStreamingResponse) {
+	s.Lock()
+	defer s.Unlock()
+
+	ls := s.filteredListeners[scID]
+	for i, listener := range ls {
+		if listener.outChan == outChan {
+			close(listener.outChan)
+			s.filteredListeners[scID] = append(ls[:i], ls[i+1:]...)
+			return
+		}
+	}
+}
+
 func (s *streamingManager) newListener(scID string) chan *StreamingResponse {
 	s.Lock()
 	defer s.Unlock()
@@ -96,6 +157,165 @@ func (s *streamingManager) stopAll() {
 
 		delete(s.listeners, key)
 	}
+	for key, l := range s.filteredListeners {
+		for _, fl := range l {
+			close(fl.outChan)
+		}
+
+		delete(s.filteredListeners, key)
+	}
+}
+
+// filter returns the state changes matching the filter. The transactions
+// are used to find the instances that have been invoked with one of the
+// commands of the filter.
+func (f StreamingFilter) filter(txs TxResults, scs StateChanges) StateChanges {
+	var invoked map[string]bool
+	if len(f.Commands) > 0 {
+		invoked = make(map[string]bool)
+		for _, tx := range txs {
+			if !tx.Accepted {
+				continue
+			}
+			for _, instr := range tx.ClientTransaction.Instructions {
+				if instr.Invoke == nil {
+					continue
+				}
+				for _, cmd := range f.Commands {
+					if instr.Invoke.Command == cmd {
+						invoked[string(instr.InstanceID[:])] = true
+					}
+				}
+			}
+		}
+	}
+
+	var matches StateChanges
+	for _, sc := range scs {
+		if f.match(sc, invoked) {
+			matches = append(matches, sc)
+		}
+	}
+	return matches
+}
+
+func (f StreamingFilter) match(sc StateChange, invoked map[string]bool) bool {
+	if len(f.ContractIDs) > 0 {
+		found := false
+		for _, cid := range f.ContractIDs {
+			found = found || sc.ContractID == cid
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.InstanceIDs) > 0 {
+		found := false
+		for _, id := range f.InstanceIDs {
+			found = found || bytes.Equal(sc.InstanceID, id[:])
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Commands) > 0 && !invoked[string(sc.InstanceID)] {
+		return false
+	}
+	if len(f.DarcIDs) > 0 {
+		found := false
+		for _, id := range f.DarcIDs {
+			found = found || sc.DarcID.Equal(id)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func newFilteredStreamingResponse(st ReadOnlyStateTrie, db *skipchain.SkipBlockDB,
+	block *skipchain.SkipBlock, scs StateChanges) (*FilteredStreamingResponse, error) {
+	p, err := NewProof(st, db, block.SkipChainID(), scs[0].InstanceID)
+	if err != nil {
+		return nil, xerrors.Errorf("creating proof: %v", err)
+	}
+	if !p.Latest.Hash.Equal(block.Hash) {
+		return nil, xerrors.New("trie is not at the index of the block")
+	}
+	resp := &FilteredStreamingResponse{
+		Block:        p.Latest,
+		Links:        p.Links,
+		StateChanges: scs,
+	}
+	// The payload is not covered by the hash of the block, and the
+	// subscriber only wants the matching state changes.
+	resp.Block.Payload = nil
+	for _, sc := range scs {
+		pr, err := st.GetProof(sc.InstanceID)
+		if err != nil {
+			return nil, xerrors.Errorf("creating inclusion proof: %v", err)
+		}
+		resp.InclusionProofs = append(resp.InclusionProofs, *pr)
+	}
+	return resp, nil
+}
+
+// Verify checks that the block is part of the chain with the given ID, and
+// that the inclusion proofs are valid for the trie root of the block. The
+// proof of every instance must show the value of the last state change of
+// this instance.
+func (r FilteredStreamingResponse) Verify(scID skipchain.SkipBlockID) error {
+	if len(r.StateChanges) == 0 {
+		return xerrors.New("no state changes")
+	}
+	if len(r.InclusionProofs) != len(r.StateChanges) {
+		return xerrors.New("need one inclusion proof per state change")
+	}
+	last := make(map[string]int)
+	for i, sc := range r.StateChanges {
+		last[string(sc.InstanceID)] = i
+	}
+	for i, sc := range r.StateChanges {
+		p := Proof{
+			InclusionProof: r.InclusionProofs[i],
+			Latest:         r.Block,
+			Links:          r.Links,
+		}
+		if i == 0 {
+			if err := p.Verify(scID); err != nil {
+				return xerrors.Errorf("verifying block: %v", err)
+			}
+		} else if err := p.VerifyInclusionProof(&r.Block); err != nil {
+			return xerrors.Errorf("verifying inclusion proof: %v", err)
+		}
+		if last[string(sc.InstanceID)] != i {
+			continue
+		}
+		exists := p.InclusionProof.Match(sc.InstanceID)
+		if sc.StateAction == Remove {
+			if exists {
+				return xerrors.Errorf("removed instance %x still exists", sc.InstanceID)
+			}
+			continue
+		}
+		if !exists {
+			return xerrors.Errorf("instance %x is missing", sc.InstanceID)
+		}
+		value, contractID, darcID, err := p.Get(sc.InstanceID)
+		if err != nil {
+			return xerrors.Errorf("getting value: %v", err)
+		}
+		if !bytes.Equal(value, sc.Value) {
+			return xerrors.Errorf("wrong value for instance %x", sc.InstanceID)
+		}
+		if contractID != sc.ContractID {
+			return xerrors.Errorf("wrong contract for instance %x", sc.InstanceID)
+		}
+		if !darcID.Equal(sc.DarcID) {
+			return xerrors.Errorf("wrong darc for instance %x", sc.InstanceID)
+		}
+	}
+	return nil
 }
 
 // StreamTransactions will stream all transactions IDs to the client until the
@@ -124,6 +344,30 @@ func (s *Service) StreamTransactions(msg *StreamingRequest) (chan *StreamingResp
 	return outChan, stopChan, nil
 }
 
+// StreamFilteredTransactions streams the state changes matching the filter of
+// every new block, together with proofs, until the client closes the
+// connection. Blocks without any matching state change are not sent.
+func (s *Service) StreamFilteredTransactions(msg *FilteredStreamingRequest) (chan *FilteredStreamingResponse, chan bool, error) {
+	stopChan := make(chan bool)
+	key := string(msg.ID)
+	outChan := s.streamingMan.newFilteredListener(key, msg.Filter)
+
+	go func() {
+		s.closedMutex.Lock()
+		if s.closed {
+			s.closedMutex.Unlock()
+			return
+		}
+		s.working.Add(1)
+		defer s.working.Done()
+		s.closedMutex.Unlock()
+
+		<-stopChan
+		s.streamingMan.stopFilteredListener(key, outChan)
+	}()
+	return outChan, stopChan, nil
+}
+
 // PaginateBlocks return blocks with pagination, ie. N asynchounous requests
 // that contain each K consecutive block. The caller is responsible for closing
 // the close chan when the caller wants to close the connection.
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/skipchain"
 )
 
@@ -393,3 +394,35 @@ func TestStreamingService_PaginateBlocks(t *testing.T) {
 
 	close(closeChan)
 }
+
+func TestStreamingFilter_Filter(t *testing.T) {
+	darcID := darc.ID{1}
+	coin := NewInstanceID([]byte("coin"))
+	other := NewInstanceID([]byte("other"))
+	scs := StateChanges{
+		{StateAction: Update, InstanceID: coin[:], ContractID: "coin", DarcID: darcID},
+		{StateAction: Create, InstanceID: other[:], ContractID: "value", DarcID: darc.ID{2}},
+	}
+	txs := TxResults{{
+		ClientTransaction: ClientTransaction{Instructions: Instructions{{
+			InstanceID: coin,
+			Invoke:     &Invoke{ContractID: "coin", Command: "transfer"},
+		}}},
+		Accepted: true,
+	}}
+
+	require.Equal(t, scs, StreamingFilter{}.filter(txs, scs))
+	require.Equal(t, scs[:1], StreamingFilter{ContractIDs: []string{"coin"}}.filter(txs, scs))
+	require.Equal(t, scs[1:], StreamingFilter{InstanceIDs: []InstanceID{other}}.filter(txs, scs))
+	require.Equal(t, scs[:1], StreamingFilter{Commands: []string{"transfer"}}.filter(txs, scs))
+	require.Empty(t, StreamingFilter{Commands: []string{"mint"}}.filter(txs, scs))
+	require.Equal(t, scs[:1], StreamingFilter{DarcIDs: []darc.ID{darcID}}.filter(txs, scs))
+	// All fields must match.
+	require.Empty(t, StreamingFilter{
+		ContractIDs: []string{"coin"},
+		InstanceIDs: []InstanceID{other},
+	}.filter(txs, scs))
+	// Refused transactions don't count.
+	txs[0].Accepted = false
+	require.Empty(t, StreamingFilter{Commands: []string{"transfer"}}.filter(txs, scs))
+}
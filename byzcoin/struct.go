@@ -660,6 +660,18 @@ func (c ChainConfig) sanityCheck(old *ChainConfig) error {
 	if len(c.Roster.List) < 3 {
 		return xerrors.New("need at least 3 nodes to have a majority")
 	}
+	if c.FeeSchedule != nil {
+		if err := c.FeeSchedule.sanityCheck(); err != nil {
+			return xerrors.Errorf("fee schedule: %v", err)
+		}
+	}
+	if c.SnapshotInterval < 0 {
+		return xerrors.New("snapshot interval is negative")
+	}
+	if c.SnapshotInterval > maxReplayBlocks {
+		return xerrors.Errorf("snapshot interval is bigger than %d, the "+
+			"number of blocks replayed to prove a past state", maxReplayBlocks)
+	}
 	if old != nil {
 		return cothority.ErrorOrNil(old.checkNewRoster(c.Roster), "roster check")
 	}
@@ -716,6 +728,21 @@ func (c ChainConfig) String() string {
 	for i, darcID := range c.DarcContractIDs {
 		fmt.Fprintf(res, "--- darc contract ID %d: %s\n", i, darcID)
 	}
+	if fs := c.FeeSchedule; fs != nil {
+		res.WriteString("-- FeeSchedule:\n")
+		fmt.Fprintf(res, "--- CoinName: %s\n", fs.CoinName)
+		fmt.Fprintf(res, "--- TransactionFee: %d\n", fs.TransactionFee)
+		fmt.Fprintf(res, "--- InstructionFee: %d\n", fs.InstructionFee)
+		for _, cf := range fs.ContractFees {
+			fmt.Fprintf(res, "--- ContractFee %s: %d\n", cf.ContractID, cf.Fee)
+		}
+		for _, p := range fs.Payouts {
+			fmt.Fprintf(res, "--- Payout %s: %s\n", p.Public, p.Account)
+		}
+	}
+	if c.SnapshotInterval > 0 {
+		fmt.Fprintf(res, "-- SnapshotInterval: %d\n", c.SnapshotInterval)
+	}
 	return res.String()
 }
 
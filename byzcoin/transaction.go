@@ -108,7 +108,7 @@ func (ctx *ClientTransaction) FillSignersAndSignWith(signers ...darc.Signer) err
 // SignWith signs all the instructions with the same signers. If some instructions need to be signed by different sets
 // of signers, then use the SignWith method of Instruction.
 func (ctx *ClientTransaction) SignWith(signers ...darc.Signer) error {
-	digest := ctx.Instructions.Hash()
+	digest := ctx.Hash()
 	for i := range ctx.Instructions {
 		if err := ctx.Instructions[i].SignWith(digest, signers...); err != nil {
 			return err
@@ -117,11 +117,30 @@ func (ctx *ClientTransaction) SignWith(signers ...darc.Signer) error {
 	return nil
 }
 
+// Hash returns the digest every instruction of the transaction must sign.
+// Starting with VersionFeePayer, it covers the fee payer, so that it cannot be
+// replaced once the transaction is signed.
+func (ctx ClientTransaction) Hash() []byte {
+	digest := ctx.Instructions.Hash()
+	if ctx.FeePayer == nil || len(ctx.Instructions) == 0 ||
+		ctx.Instructions[0].version < VersionFeePayer {
+		return digest
+	}
+	h := sha256.New()
+	h.Write(digest)
+	h.Write(ctx.FeePayer[:])
+	return h.Sum(nil)
+}
+
 // Clone creates a deep clone of the ClientTransaction - mostly used in the
 // tests.
 func (ctx *ClientTransaction) Clone() ClientTransaction {
 	newCtx := ClientTransaction{}
 	newCtx.Instructions = append(newCtx.Instructions, ctx.Instructions...)
+	if ctx.FeePayer != nil {
+		payer := *ctx.FeePayer
+		newCtx.FeePayer = &payer
+	}
 	return newCtx
 }
 
@@ -572,7 +591,7 @@ func (txr TxResults) Hash() []byte {
 
 	h := sha256.New()
 	for _, tx := range txr {
-		h.Write(tx.ClientTransaction.Instructions.Hash())
+		h.Write(tx.ClientTransaction.Hash())
 		if tx.Accepted {
 			h.Write(one[:])
 		} else {
@@ -44,3 +44,15 @@ type Bucket interface {
 	// the error is returned to the caller.
 	ForEach(func(k, v []byte) error) error
 }
+
+// Copy copies all key/value pairs of src into dst in a single transaction.
+// It can be used to move a trie to another storage backend.
+func Copy(dst, src DB) error {
+	return dst.Update(func(db Bucket) error {
+		return src.View(func(sb Bucket) error {
+			return sb.ForEach(func(k, v []byte) error {
+				return db.Put(k, v)
+			})
+		})
+	})
+}
@@ -2,9 +2,12 @@ package trie
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/syndtr/goleveldb/leveldb"
 	"golang.org/x/xerrors"
 )
 
@@ -100,4 +103,72 @@ func testMemAndDisk(t *testing.T, f func(*testing.T, DB)) {
 	disk := newDiskDB(t)
 	defer delDiskDB(t, disk)
 	f(t, disk)
+
+	level := newLevelDB(t)
+	defer delLevelDB(t, level)
+	f(t, level)
+}
+
+func TestLevelDBPrefix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leveldb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ldb, err := leveldb.OpenFile(dir, nil)
+	require.NoError(t, err)
+	defer ldb.Close()
+
+	// Two tries in the same database must not see each other.
+	db1 := NewLevelDB(ldb, []byte("one/"))
+	db2 := NewLevelDB(ldb, []byte("two/"))
+	require.NoError(t, db1.Update(func(b Bucket) error {
+		return b.Put([]byte("key"), []byte("value1"))
+	}))
+	require.NoError(t, db2.Update(func(b Bucket) error {
+		return b.Put([]byte("key"), []byte("value2"))
+	}))
+	require.NoError(t, db1.View(func(b Bucket) error {
+		require.Equal(t, []byte("value1"), b.Get([]byte("key")))
+		return b.ForEach(func(k, v []byte) error {
+			require.Equal(t, []byte("key"), k)
+			return nil
+		})
+	}))
+
+	// A failing update is not committed.
+	err = db1.Update(func(b Bucket) error {
+		if err := b.Put([]byte("key"), []byte("changed")); err != nil {
+			return err
+		}
+		return xerrors.New("abort")
+	})
+	require.Error(t, err)
+	require.NoError(t, db1.View(func(b Bucket) error {
+		require.Equal(t, []byte("value1"), b.Get([]byte("key")))
+		return nil
+	}))
+}
+
+func TestCopy(t *testing.T) {
+	src := NewMemDB()
+	require.NoError(t, src.Update(func(b Bucket) error {
+		for i := 0; i < 10; i++ {
+			if err := b.Put([]byte{byte(i)}, []byte{byte(i)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	dst := newLevelDB(t)
+	defer delLevelDB(t, dst)
+	require.NoError(t, Copy(dst, src))
+
+	var cnt int
+	require.NoError(t, dst.View(func(b Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			require.Equal(t, k, v)
+			cnt++
+			return nil
+		})
+	}))
+	require.Equal(t, 10, cnt)
 }
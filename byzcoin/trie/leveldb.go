@@ -0,0 +1,116 @@
+package trie
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"golang.org/x/xerrors"
+)
+
+// levelDB is the DB implementation for leveldb. As leveldb doesn't know about
+// buckets, all keys are stored with a prefix, so that more than one trie can
+// be stored in the same database.
+type levelDB struct {
+	db     *leveldb.DB
+	prefix []byte
+}
+
+// NewLevelDB creates a new leveldb-backed database. All keys are stored with
+// the given prefix, which must not be the prefix of another trie stored in the
+// same database.
+func NewLevelDB(db *leveldb.DB, prefix []byte) DB {
+	return &levelDB{
+		db:     db,
+		prefix: clone(prefix),
+	}
+}
+
+func (r *levelDB) Update(f func(Bucket) error) error {
+	tr, err := r.db.OpenTransaction()
+	if err != nil {
+		return xerrors.Errorf("opening transaction: %v", err)
+	}
+	if err := f(&levelBucket{r: tr, tr: tr, prefix: r.prefix}); err != nil {
+		tr.Discard()
+		return err
+	}
+	return tr.Commit()
+}
+
+func (r *levelDB) View(f func(Bucket) error) error {
+	snap, err := r.db.GetSnapshot()
+	if err != nil {
+		return xerrors.Errorf("getting snapshot: %v", err)
+	}
+	defer snap.Release()
+	return f(&levelBucket{r: snap, prefix: r.prefix})
+}
+
+// UpdateDryRun executes the given function in a transaction that is discarded
+// at the end. It is useful for seeing the intermediate values. If they need to
+// be used after doing the dry-run, they should be copied.
+func (r *levelDB) UpdateDryRun(f func(Bucket) error) error {
+	tr, err := r.db.OpenTransaction()
+	if err != nil {
+		return xerrors.Errorf("opening transaction: %v", err)
+	}
+	defer tr.Discard()
+	return f(&levelBucket{r: tr, tr: tr, prefix: r.prefix})
+}
+
+// Close does nothing, as the leveldb database is shared by all the tries
+// stored in it. It must be closed by the caller of NewLevelDB.
+func (r *levelDB) Close() error {
+	return nil
+}
+
+// levelReader is implemented by leveldb's transactions and snapshots.
+type levelReader interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+type levelBucket struct {
+	r levelReader
+	// tr is nil for read-only buckets.
+	tr     *leveldb.Transaction
+	prefix []byte
+}
+
+func (r *levelBucket) key(k []byte) []byte {
+	return append(clone(r.prefix), k...)
+}
+
+func (r *levelBucket) Delete(k []byte) error {
+	if r.tr == nil {
+		return xerrors.New("trying to use Delete in a read-only transaction")
+	}
+	return r.tr.Delete(r.key(k), nil)
+}
+
+func (r *levelBucket) Put(k, v []byte) error {
+	if r.tr == nil {
+		return xerrors.New("trying to use Put in a read-only transaction")
+	}
+	return r.tr.Put(r.key(k), v, nil)
+}
+
+func (r *levelBucket) Get(k []byte) []byte {
+	v, err := r.r.Get(r.key(k), nil)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+func (r *levelBucket) ForEach(f func(k, v []byte) error) error {
+	it := r.r.NewIterator(util.BytesPrefix(r.prefix), nil)
+	defer it.Release()
+	for it.Next() {
+		if err := f(it.Key()[len(r.prefix):], it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
@@ -8,11 +8,13 @@ import (
 	"testing/quick"
 
 	"github.com/stretchr/testify/require"
+	"github.com/syndtr/goleveldb/leveldb"
 	bbolt "go.etcd.io/bbolt"
 	"golang.org/x/xerrors"
 )
 
 const testDBName = "test_trie.db"
+const testLevelDBName = "test_trie.leveldb"
 const bucketName = "test_trie_bucket"
 
 func TestNewTrie(t *testing.T) {
@@ -530,6 +532,28 @@ func delDiskDB(t *testing.T, db DB) {
 	require.NoError(t, os.Remove(testDBName))
 }
 
+// testLevelDB closes the leveldb database with the trie, as there is no other
+// trie in it.
+type testLevelDB struct {
+	DB
+	ldb *leveldb.DB
+}
+
+func (db testLevelDB) Close() error {
+	return db.ldb.Close()
+}
+
+func newLevelDB(t *testing.T) DB {
+	ldb, err := leveldb.OpenFile(testLevelDBName, nil)
+	require.NoError(t, err)
+	return testLevelDB{NewLevelDB(ldb, []byte(bucketName)), ldb}
+}
+
+func delLevelDB(t *testing.T, db DB) {
+	require.NoError(t, db.Close())
+	require.NoError(t, os.RemoveAll(testLevelDBName))
+}
+
 func getRootNode(t *testing.T, db DB) interiorNode {
 	var root interiorNode
 	err := db.View(func(b Bucket) error {
@@ -0,0 +1,157 @@
+package byzcoin
+
+import (
+	"sync"
+)
+
+// TxStatus is what a node knows about a transaction it received or saw in a
+// block.
+type TxStatus int32
+
+const (
+	// TxStatusUnknown means that the node never saw the transaction, or
+	// that it has already been forgotten.
+	TxStatusUnknown TxStatus = iota
+	// TxStatusPending means that the transaction has been received, but is
+	// not yet included in a block.
+	TxStatusPending
+	// TxStatusAccepted means that the transaction has been included in a
+	// block and its state changes have been applied.
+	TxStatusAccepted
+	// TxStatusRefused means that the transaction has been included in a
+	// block, but it failed and none of its state changes were applied.
+	TxStatusRefused
+	// TxStatusDropped means that the transaction was pending when a
+	// view-change happened, so it will never be included. It has to be sent
+	// again.
+	TxStatusDropped
+)
+
+// String returns a human readable representation of the status.
+func (s TxStatus) String() string {
+	switch s {
+	case TxStatusPending:
+		return "pending"
+	case TxStatusAccepted:
+		return "accepted"
+	case TxStatusRefused:
+		return "refused"
+	case TxStatusDropped:
+		return "dropped"
+	default:
+		return "unknown"
+	}
+}
+
+// isFinal returns true if the status will not change anymore.
+func (s TxStatus) isFinal() bool {
+	return s == TxStatusAccepted || s == TxStatusRefused
+}
+
+type txStatusEntry struct {
+	scID   string
+	status TxStatus
+	index  int
+	err    string
+	// tx is only kept as long as the transaction is pending.
+	tx *ClientTransaction
+}
+
+// txStatusBuf keeps the status of the latest transactions, indexed by the
+// skipchain-ID and the hash of the instructions. Once more than size
+// transactions are stored, the oldest ones are forgotten.
+type txStatusBuf struct {
+	sync.Mutex
+	size    int
+	entries map[string]*txStatusEntry
+	order   []string
+}
+
+func newTxStatusBuf(size int) txStatusBuf {
+	return txStatusBuf{
+		size:    size,
+		entries: make(map[string]*txStatusEntry),
+	}
+}
+
+// entry returns the entry for the given transaction, creating it if needed.
+// The caller must hold the lock.
+func (b *txStatusBuf) entry(scID []byte, hash []byte) *txStatusEntry {
+	key := string(scID) + string(hash)
+	if e, ok := b.entries[key]; ok {
+		return e
+	}
+	if len(b.order) >= b.size {
+		delete(b.entries, b.order[0])
+		b.order = b.order[1:]
+	}
+	e := &txStatusEntry{scID: string(scID), index: -1}
+	b.entries[key] = e
+	b.order = append(b.order, key)
+	return e
+}
+
+// setPending records a transaction that has been sent to the leader. A
+// transaction that is already in a block is not changed.
+func (b *txStatusBuf) setPending(scID []byte, hash []byte, tx ClientTransaction) {
+	b.Lock()
+	defer b.Unlock()
+	e := b.entry(scID, hash)
+	if e.status.isFinal() {
+		return
+	}
+	e.status = TxStatusPending
+	e.tx = &tx
+}
+
+// setIncluded records a transaction that has been included in the block with
+// the given index.
+func (b *txStatusBuf) setIncluded(scID []byte, hash []byte, accepted bool,
+	index int, errMsg string) {
+	b.Lock()
+	defer b.Unlock()
+	e := b.entry(scID, hash)
+	e.status = TxStatusRefused
+	if accepted {
+		e.status = TxStatusAccepted
+	}
+	e.index = index
+	e.err = errMsg
+	e.tx = nil
+}
+
+// dropPending marks all pending transactions of the given chain as dropped.
+func (b *txStatusBuf) dropPending(scID []byte) {
+	b.Lock()
+	defer b.Unlock()
+	for _, e := range b.entries {
+		if e.scID == string(scID) && e.status == TxStatusPending {
+			e.status = TxStatusDropped
+			e.tx = nil
+		}
+	}
+}
+
+func (b *txStatusBuf) get(scID []byte, hash []byte) (txStatusEntry, bool) {
+	b.Lock()
+	defer b.Unlock()
+	e, ok := b.entries[string(scID)+string(hash)]
+	if !ok {
+		return txStatusEntry{index: -1}, false
+	}
+	return *e, true
+}
+
+// pending returns the pending transactions of the given chain, oldest first.
+func (b *txStatusBuf) pending(scID []byte) []ClientTransaction {
+	b.Lock()
+	defer b.Unlock()
+	var txs []ClientTransaction
+	for _, key := range b.order {
+		e := b.entries[key]
+		if e.scID == string(scID) && e.status == TxStatusPending {
+			txs = append(txs, *e.tx)
+		}
+	}
+	return txs
+}
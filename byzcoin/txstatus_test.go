@@ -0,0 +1,50 @@
+package byzcoin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxStatusBuf(t *testing.T) {
+	b := newTxStatusBuf(3)
+	scID := []byte("chain")
+	tx := ClientTransaction{Instructions: Instructions{{}}}
+
+	e, ok := b.get(scID, []byte("tx1"))
+	require.False(t, ok)
+	require.Equal(t, TxStatusUnknown, e.status)
+	require.Equal(t, -1, e.index)
+
+	b.setPending(scID, []byte("tx1"), tx)
+	b.setPending(scID, []byte("tx2"), tx)
+	b.setPending([]byte("other"), []byte("tx1"), tx)
+	require.Equal(t, 2, len(b.pending(scID)))
+
+	b.setIncluded(scID, []byte("tx1"), true, 2, "")
+	e, ok = b.get(scID, []byte("tx1"))
+	require.True(t, ok)
+	require.Equal(t, TxStatusAccepted, e.status)
+	require.Equal(t, 2, e.index)
+	require.Equal(t, 1, len(b.pending(scID)))
+
+	// Once included, the status doesn't go back to pending.
+	b.setPending(scID, []byte("tx1"), tx)
+	e, _ = b.get(scID, []byte("tx1"))
+	require.Equal(t, TxStatusAccepted, e.status)
+
+	b.dropPending(scID)
+	e, _ = b.get(scID, []byte("tx2"))
+	require.Equal(t, TxStatusDropped, e.status)
+	e, _ = b.get([]byte("other"), []byte("tx1"))
+	require.Equal(t, TxStatusPending, e.status)
+	require.Equal(t, 0, len(b.pending(scID)))
+
+	// The oldest entry is forgotten.
+	b.setIncluded(scID, []byte("tx3"), false, 3, "failed")
+	_, ok = b.get(scID, []byte("tx1"))
+	require.False(t, ok)
+	e, _ = b.get(scID, []byte("tx3"))
+	require.Equal(t, TxStatusRefused, e.status)
+	require.Equal(t, "failed", e.err)
+}
@@ -1,6 +1,8 @@
 package calypso
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
 	"time"
 
@@ -49,7 +51,7 @@ func NewClient(byzcoin *byzcoin.Client) *Client {
 // then it asks the Calypso cothority to start the DKG.
 func (c *Client) CreateLTS(ltsRoster *onet.Roster, darcID darc.ID, signers []darc.Signer, counters []uint64) (reply *CreateLTSReply, err error) {
 	// Make the transaction and get its proof
-	buf, err := protobuf.Encode(&LtsInstanceInfo{*ltsRoster})
+	buf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *ltsRoster})
 	if err != nil {
 		return nil, xerrors.Errorf("encoding roster: %v", err)
 	}
@@ -141,6 +143,30 @@ func (c *Client) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error)
 	return reply, cothority.ErrorOrNil(err, "sending DecryptKey message")
 }
 
+// ReleaseKey asks the LTS to publish the secret of a write instance that is
+// released to everyone. The time-lock of the write must be over. The
+// returned secret is verified against the hash in the time-lock.
+func (c *Client) ReleaseKey(write *byzcoin.Proof) (reply *ReleaseKeyReply, err error) {
+	var w Write
+	err = write.VerifyAndDecode(cothority.Suite, ContractWriteID, &w)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't get write: %v", err)
+	}
+	if w.ReleaseAfter == nil || w.ReleaseAfter.KeyHash == nil {
+		return nil, xerrors.New("the write-instance is not released to everyone")
+	}
+	reply = &ReleaseKeyReply{}
+	err = c.c.SendProtobuf(c.bcClient.Roster.List[0], &ReleaseKey{Write: *write}, reply)
+	if err != nil {
+		return nil, xerrors.Errorf("sending ReleaseKey message: %v", err)
+	}
+	h := sha256.Sum256(reply.Key)
+	if !bytes.Equal(h[:], w.ReleaseAfter.KeyHash) {
+		return nil, xerrors.New("got a wrong key from the LTS")
+	}
+	return reply, nil
+}
+
 // WaitProof calls the byzcoin client's wait proof
 func (c *Client) WaitProof(id byzcoin.InstanceID, interval time.Duration,
 	value []byte) (*byzcoin.Proof, error) {
@@ -203,11 +229,145 @@ func (c *Client) AddWrite(write *Write, signer darc.Signer, signerCtr uint64,
 //   - err - Error if any, nil otherwise.
 func (c *Client) AddRead(proof *byzcoin.Proof, signer darc.Signer, signerCtr uint64, wait int) (
 	reply *ReadReply, err error) {
-	var readBuf []byte
-	read := &Read{
+	return c.addRead(proof, &Read{
 		Write: byzcoin.NewInstanceID(proof.InclusionProof.Key()),
 		Xc:    signer.Ed25519.Point,
+	}, signer, signerCtr, wait)
+}
+
+// AddGroupRead creates a Read Instance for a group of readers by adding a
+// transaction on the byzcoin client. Every public key in the sign rule of
+// the group darc can then ask for the secret with DecryptKey, by setting Xc
+// in the request.
+//
+// Input:
+//   - proof - A ByzCoin proof of the Write Operation.
+//   - group - The base ID of the darc holding the readers
+//   - signer - The data owner who will sign the transaction
+//   - signerCtr - A monotonically increasing counter for the signer
+//   - wait - The number of blocks to wait -- 0 means no wait
+//
+// Output:
+//   - reply - ReadReply containing the transaction response and instance id
+//   - err - Error if any, nil otherwise.
+func (c *Client) AddGroupRead(proof *byzcoin.Proof, group darc.ID, signer darc.Signer,
+	signerCtr uint64, wait int) (reply *ReadReply, err error) {
+	return c.addRead(proof, &Read{
+		Write: byzcoin.NewInstanceID(proof.InclusionProof.Key()),
+		Group: group,
+	}, signer, signerCtr, wait)
+}
+
+// RevokeWrite revokes a Write Instance by adding a transaction on the byzcoin
+// client. Afterwards, no new Read Instances can be created, and the Calypso
+// service refuses to re-encrypt the secret.
+//
+// Input:
+//   - write - The instance ID of the Write Instance
+//   - signer - The data owner who will sign the transaction
+//   - signerCtr - A monotonically increasing counter for the signer
+//   - wait - The number of blocks to wait -- 0 means no wait
+//
+// Output:
+//   - reply - AddTxResponse containing the transaction response
+//   - err - Error if any, nil otherwise.
+func (c *Client) RevokeWrite(write byzcoin.InstanceID, signer darc.Signer,
+	signerCtr uint64, wait int) (reply *byzcoin.AddTxResponse, err error) {
+	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion,
+		byzcoin.Instruction{
+			InstanceID: write,
+			Invoke: &byzcoin.Invoke{
+				ContractID: ContractWriteID,
+				Command:    "revoke",
+			},
+			SignerCounter: []uint64{signerCtr},
+		},
+	)
+	err = ctx.FillSignersAndSignWith(signer)
+	if err != nil {
+		return nil, xerrors.Errorf("signing txn: %v", err)
+	}
+
+	reply, err = c.bcClient.AddTransactionAndWait(ctx, wait)
+	if err != nil {
+		return nil, xerrors.Errorf("adding txn: %v", err)
+	}
+	return reply, nil
+}
+
+// AddBlob stores the chunks of a blob returned by SplitBlob. Every chunk is
+// sent in its own transaction, so that it fits in a block.
+// Input:
+//   - write - The instance ID of the Write Instance holding the BlobInfo
+//   - chunks - All chunks of the blob
+//   - signer - The data owner who will sign the transactions
+//   - signerCtr - The counter for the first transaction, the following
+//     transactions use the next counters
+//   - wait - The number of blocks to wait for each chunk -- 0 means no wait
+//
+// Output:
+//   - err - Error if any, nil otherwise.
+func (c *Client) AddBlob(write byzcoin.InstanceID, chunks [][]byte,
+	signer darc.Signer, signerCtr uint64, wait int) error {
+	for i := range chunks {
+		ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion,
+			byzcoin.Instruction{
+				InstanceID: write,
+				Spawn: &byzcoin.Spawn{
+					ContractID: ContractBlobChunkID,
+					Args:       BlobChunkArgs(chunks, uint64(i)),
+				},
+				SignerCounter: []uint64{signerCtr + uint64(i)},
+			},
+		)
+		err := ctx.FillSignersAndSignWith(signer)
+		if err != nil {
+			return xerrors.Errorf("signing txn: %v", err)
+		}
+		_, err = c.bcClient.AddTransactionAndWait(ctx, wait)
+		if err != nil {
+			return xerrors.Errorf("adding chunk %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// GetBlob fetches all chunks of the blob of the write instance, verifies them
+// against the BlobInfo of the write, and returns the encrypted blob. It can
+// be decrypted with DecryptBlob, once the key has been recovered.
+func (c *Client) GetBlob(write byzcoin.InstanceID) ([]byte, error) {
+	reply, err := c.bcClient.GetProof(write.Slice())
+	if err != nil {
+		return nil, xerrors.Errorf("getting write proof: %v", err)
+	}
+	var w Write
+	err = reply.Proof.VerifyAndDecode(cothority.Suite, ContractWriteID, &w)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't get write: %v", err)
 	}
+	if w.Blob == nil {
+		return nil, xerrors.New("the write-instance has no blob")
+	}
+
+	chunks := make([][]byte, w.Blob.Chunks)
+	for i := range chunks {
+		id := BlobChunkID(write, uint64(i))
+		reply, err := c.bcClient.GetProof(id.Slice())
+		if err != nil {
+			return nil, xerrors.Errorf("getting proof of chunk %d: %v", i, err)
+		}
+		chunk, err := decodeBlobChunk(&reply.Proof, write, uint64(i))
+		if err != nil {
+			return nil, xerrors.Errorf("chunk %d: %v", i, err)
+		}
+		chunks[i] = chunk.Data
+	}
+	return JoinBlob(w.Blob, chunks)
+}
+
+func (c *Client) addRead(proof *byzcoin.Proof, read *Read, signer darc.Signer,
+	signerCtr uint64, wait int) (reply *ReadReply, err error) {
+	var readBuf []byte
 	reply = &ReadReply{}
 	readBuf, err = protobuf.Encode(read)
 	if err != nil {
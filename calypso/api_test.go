@@ -217,3 +217,69 @@ func TestClient_Calypso(t *testing.T) {
 
 	// use keyCopy to unlock the stuff in writeInstance.Data
 }
+
+// Tests the client api's AddBlob and GetBlob
+func TestClient_Blob(t *testing.T) {
+	l := onet.NewTCPTest(cothority.Suite)
+	_, roster, _ := l.GenTree(3, true)
+	defer l.CloseAll()
+
+	admin := darc.NewSignerEd25519(nil, nil)
+	msg, err := byzcoin.DefaultGenesisMsg(byzcoin.CurrentVersion, roster,
+		[]string{"spawn:" + ContractLongTermSecretID,
+			"spawn:" + ContractWriteID, "spawn:" + ContractReadID,
+			"spawn:" + ContractBlobChunkID},
+		admin.Identity())
+	require.NoError(t, err)
+	msg.BlockInterval = 500 * time.Millisecond
+	gDarc := msg.GenesisDarc
+	c, _, err := byzcoin.NewLedger(msg, false)
+	require.NoError(t, err)
+	calypsoClient := NewClient(c)
+
+	for _, who := range roster.List {
+		require.NoError(t, calypsoClient.Authorize(who, c.ID))
+	}
+	ltsReply, err := calypsoClient.CreateLTS(roster, gDarc.GetBaseID(),
+		[]darc.Signer{admin}, []uint64{1})
+	require.NoError(t, err)
+
+	data := make([]byte, 2500)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	key := NewBlobKey()
+	ct, err := EncryptBlob(key, data)
+	require.NoError(t, err)
+	info, chunks, err := SplitBlob(ct, 1000)
+	require.NoError(t, err)
+	write := NewWrite(cothority.Suite, ltsReply.InstanceID, gDarc.GetBaseID(),
+		ltsReply.X, key)
+	write.Blob = info
+	wr, err := calypsoClient.AddWrite(write, admin, 2, *gDarc, 10)
+	require.NoError(t, err)
+
+	_, err = calypsoClient.GetBlob(wr.InstanceID)
+	require.Error(t, err)
+	require.NoError(t, calypsoClient.AddBlob(wr.InstanceID, chunks, admin, 3, 10))
+	// Chunks can only be stored once.
+	require.Error(t, calypsoClient.AddBlob(wr.InstanceID, chunks, admin, 6, 10))
+
+	prWr, err := calypsoClient.WaitProof(wr.InstanceID, time.Second, nil)
+	require.NoError(t, err)
+	re, err := calypsoClient.AddRead(prWr, admin, 6, 10)
+	require.NoError(t, err)
+	prRe, err := calypsoClient.WaitProof(re.InstanceID, time.Second, nil)
+	require.NoError(t, err)
+	dk, err := calypsoClient.DecryptKey(&DecryptKey{Read: *prRe, Write: *prWr})
+	require.NoError(t, err)
+	keyCopy, err := dk.RecoverKey(admin.Ed25519.Secret)
+	require.NoError(t, err)
+
+	blob, err := calypsoClient.GetBlob(wr.InstanceID)
+	require.NoError(t, err)
+	require.Equal(t, ct, blob)
+	plain, err := DecryptBlob(keyCopy, blob)
+	require.NoError(t, err)
+	require.Equal(t, data, plain)
+}
@@ -0,0 +1,303 @@
+package calypso
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/kyber/v3/util/random"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// ContractBlobChunkID references a chunk of an encrypted blob system-wide.
+// Chunks are spawned through their write instance, so they are protected by
+// the 'spawn:calypsoChunk' rule of the write's darc.
+const ContractBlobChunkID = "calypsoChunk"
+
+// DefaultBlobChunkSize is the size of the chunks a blob is split into. It is
+// well below the default maximum block size, so that a block can hold a
+// chunk together with other transactions.
+const DefaultBlobChunkSize = 256 * 1024
+
+// BlobKeyLength is the length of the keys returned by NewBlobKey: a 16 byte
+// AES key followed by a 12 byte nonce for GCM. It is short enough to be
+// embedded in the point of a Write.
+const BlobKeyLength = 28
+
+// ContractBlobChunk represents one chunk of a blob.
+type ContractBlobChunk struct {
+	byzcoin.BasicContract
+	BlobChunk
+}
+
+func contractBlobChunkFromBytes(in []byte) (byzcoin.Contract, error) {
+	return nil, xerrors.New("calypso chunk instances are never instantiated")
+}
+
+// BlobChunkID returns the instance ID of the chunk with the given index of
+// the blob of the write instance.
+func BlobChunkID(write byzcoin.InstanceID, index uint64) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write(write.Slice())
+	binary.Write(h, binary.LittleEndian, index)
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// NewBlobKey returns a random key to encrypt a blob with EncryptBlob. The key
+// should be stored in a Write, so that it can be re-encrypted by the LTS.
+func NewBlobKey() []byte {
+	return random.Bits(BlobKeyLength*8, false, random.New())
+}
+
+// EncryptBlob encrypts the data using AES-GCM with the key, which must have
+// been created by NewBlobKey.
+func EncryptBlob(key, data []byte) ([]byte, error) {
+	aead, nonce, err := blobCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, nonce, data, nil), nil
+}
+
+// DecryptBlob decrypts the ciphertext returned by EncryptBlob.
+func DecryptBlob(key, ciphertext []byte) ([]byte, error) {
+	aead, nonce, err := blobCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't decrypt blob: %v", err)
+	}
+	return data, nil
+}
+
+func blobCipher(key []byte) (cipher.AEAD, []byte, error) {
+	if len(key) != BlobKeyLength {
+		return nil, nil, xerrors.Errorf("blob key must be %d bytes long",
+			BlobKeyLength)
+	}
+	block, err := aes.NewCipher(key[:16])
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't create cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't create GCM: %v", err)
+	}
+	return aead, key[16:], nil
+}
+
+// SplitBlob splits the data into chunks of at most chunkSize bytes, and
+// returns the BlobInfo to be stored in the Write together with the chunks.
+func SplitBlob(data []byte, chunkSize int) (*BlobInfo, [][]byte, error) {
+	if chunkSize <= 0 {
+		return nil, nil, xerrors.New("chunk size must be positive")
+	}
+	if len(data) == 0 {
+		return nil, nil, xerrors.New("cannot store an empty blob")
+	}
+	var chunks [][]byte
+	for len(data) > chunkSize {
+		chunks = append(chunks, data[:chunkSize])
+		data = data[chunkSize:]
+	}
+	chunks = append(chunks, data)
+
+	info := &BlobInfo{
+		Root:   blobMerkleRoot(blobLeaves(chunks)),
+		Chunks: uint64(len(chunks)),
+	}
+	for _, c := range chunks {
+		info.Size += uint64(len(c))
+	}
+	return info, chunks, nil
+}
+
+// JoinBlob verifies the chunks against the BlobInfo and returns the data.
+func JoinBlob(info *BlobInfo, chunks [][]byte) ([]byte, error) {
+	if uint64(len(chunks)) != info.Chunks {
+		return nil, xerrors.Errorf("got %d chunks instead of %d",
+			len(chunks), info.Chunks)
+	}
+	if !bytes.Equal(blobMerkleRoot(blobLeaves(chunks)), info.Root) {
+		return nil, xerrors.New("chunks don't match the root of the blob")
+	}
+	data := bytes.Join(chunks, nil)
+	if uint64(len(data)) != info.Size {
+		return nil, xerrors.New("chunks don't match the size of the blob")
+	}
+	return data, nil
+}
+
+// verify checks that the info can describe a blob.
+func (info *BlobInfo) verify() error {
+	if len(info.Root) != sha256.Size {
+		return xerrors.New("wrong length of the blob root")
+	}
+	if info.Chunks == 0 || info.Size < info.Chunks {
+		return xerrors.New("a blob needs at least one byte per chunk")
+	}
+	return nil
+}
+
+// spawnChunk verifies the chunk given in the instruction against the blob of
+// the write and returns the state change creating the chunk instance.
+func (c ContractWrite) spawnChunk(inst byzcoin.Instruction, darcID darc.ID) (byzcoin.StateChange, error) {
+	if c.Blob == nil {
+		return byzcoin.StateChange{}, xerrors.New("the write-instance has no blob")
+	}
+	if c.Revoked {
+		return byzcoin.StateChange{}, xerrors.New("the write-instance has been revoked")
+	}
+	idxBuf := inst.Spawn.Args.Search("index")
+	if len(idxBuf) != 8 {
+		return byzcoin.StateChange{}, xerrors.New("need an 8 byte 'index' argument")
+	}
+	chunk := BlobChunk{
+		Write: inst.InstanceID,
+		Index: binary.LittleEndian.Uint64(idxBuf),
+		Data:  inst.Spawn.Args.Search("data"),
+	}
+	if chunk.Index >= c.Blob.Chunks {
+		return byzcoin.StateChange{}, xerrors.Errorf("the blob has only %d chunks",
+			c.Blob.Chunks)
+	}
+	if len(chunk.Data) == 0 {
+		return byzcoin.StateChange{}, xerrors.New("need a 'data' argument")
+	}
+	if !verifyBlobPath(c.Blob.Root, blobLeaf(chunk.Data), chunk.Index,
+		c.Blob.Chunks, inst.Spawn.Args.Search("path")) {
+		return byzcoin.StateChange{}, xerrors.New("chunk doesn't match the root of the blob")
+	}
+
+	buf, err := protobuf.Encode(&chunk)
+	if err != nil {
+		return byzcoin.StateChange{}, xerrors.Errorf("couldn't encode chunk: %v", err)
+	}
+	return byzcoin.NewStateChange(byzcoin.Create, BlobChunkID(inst.InstanceID,
+		chunk.Index), ContractBlobChunkID, buf, darcID), nil
+}
+
+// BlobChunkArgs returns the arguments to spawn the chunk with the given index
+// out of all chunks of a blob.
+func BlobChunkArgs(chunks [][]byte, index uint64) byzcoin.Arguments {
+	idxBuf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(idxBuf, index)
+	return byzcoin.Arguments{
+		{Name: "index", Value: idxBuf},
+		{Name: "data", Value: chunks[index]},
+		{Name: "path", Value: bytes.Join(blobMerklePath(blobLeaves(chunks), index), nil)},
+	}
+}
+
+// decodeBlobChunk verifies the proof of a chunk and returns its content.
+func decodeBlobChunk(p *byzcoin.Proof, write byzcoin.InstanceID, index uint64) (*BlobChunk, error) {
+	var chunk BlobChunk
+	err := p.VerifyAndDecode(cothority.Suite, ContractBlobChunkID, &chunk)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't get chunk: %v", err)
+	}
+	if !p.InclusionProof.Match(BlobChunkID(write, index).Slice()) {
+		return nil, xerrors.New("proof is not for the chunk")
+	}
+	if !chunk.Write.Equal(write) || chunk.Index != index {
+		return nil, xerrors.New("chunk is from another blob")
+	}
+	return &chunk, nil
+}
+
+// The Merkle tree of the chunks uses different prefixes for the leaves and
+// the nodes, and the last node of a level is paired with itself if the level
+// has an odd length.
+
+func blobLeaf(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func blobNode(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+func blobLeaves(chunks [][]byte) [][]byte {
+	leaves := make([][]byte, len(chunks))
+	for i, c := range chunks {
+		leaves[i] = blobLeaf(c)
+	}
+	return leaves
+}
+
+func blobNextLevel(level [][]byte) [][]byte {
+	next := make([][]byte, (len(level)+1)/2)
+	for i := range next {
+		right := level[len(level)-1]
+		if 2*i+1 < len(level) {
+			right = level[2*i+1]
+		}
+		next[i] = blobNode(level[2*i], right)
+	}
+	return next
+}
+
+func blobMerkleRoot(leaves [][]byte) []byte {
+	for len(leaves) > 1 {
+		leaves = blobNextLevel(leaves)
+	}
+	return leaves[0]
+}
+
+// blobMerklePath returns the siblings of the leaf from the bottom to the top
+// of the tree.
+func blobMerklePath(leaves [][]byte, index uint64) [][]byte {
+	var path [][]byte
+	for len(leaves) > 1 {
+		sibling := index ^ 1
+		if sibling >= uint64(len(leaves)) {
+			sibling = index
+		}
+		path = append(path, leaves[sibling])
+		leaves = blobNextLevel(leaves)
+		index /= 2
+	}
+	return path
+}
+
+// verifyBlobPath checks that the leaf at the index of a tree with the given
+// number of leaves leads to the root. The path is the concatenation of the
+// siblings returned by blobMerklePath.
+func verifyBlobPath(root, leaf []byte, index, leaves uint64, path []byte) bool {
+	if len(path)%sha256.Size != 0 {
+		return false
+	}
+	node := leaf
+	for ; leaves > 1; leaves = (leaves + 1) / 2 {
+		if len(path) == 0 {
+			return false
+		}
+		sibling := path[:sha256.Size]
+		path = path[sha256.Size:]
+		if index%2 == 0 {
+			if index+1 == leaves && !bytes.Equal(sibling, node) {
+				return false
+			}
+			node = blobNode(node, sibling)
+		} else {
+			node = blobNode(sibling, node)
+		}
+		index /= 2
+	}
+	return len(path) == 0 && bytes.Equal(node, root)
+}
@@ -0,0 +1,74 @@
+package calypso
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/onet/v3/log"
+)
+
+func TestBlob_Split(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	_, _, err := SplitBlob(nil, 10)
+	require.Error(t, err)
+	_, _, err = SplitBlob(data, 0)
+	require.Error(t, err)
+
+	for _, size := range []int{1, 3, 7, 100, 333, 999, 1000, 2000} {
+		log.Lvl2("Splitting in chunks of", size)
+		info, chunks, err := SplitBlob(data, size)
+		require.NoError(t, err)
+		require.NoError(t, info.verify())
+		require.Equal(t, uint64(len(data)), info.Size)
+		require.Equal(t, uint64((len(data)+size-1)/size), info.Chunks)
+		joined, err := JoinBlob(info, chunks)
+		require.NoError(t, err)
+		require.Equal(t, data, joined)
+
+		leaves := blobLeaves(chunks)
+		for i := uint64(0); i < info.Chunks; i++ {
+			path := bytes.Join(blobMerklePath(leaves, i), nil)
+			require.True(t, verifyBlobPath(info.Root, leaves[i], i, info.Chunks, path))
+			// A chunk at another index must not verify.
+			other := (i + 1) % info.Chunks
+			if !bytes.Equal(chunks[i], chunks[other]) {
+				require.False(t, verifyBlobPath(info.Root, leaves[i], other,
+					info.Chunks, path))
+			}
+			require.False(t, verifyBlobPath(info.Root, leaves[i], i, info.Chunks,
+				append(path, leaves[i]...)))
+		}
+
+		if info.Chunks > 1 {
+			_, err = JoinBlob(info, chunks[1:])
+			require.Error(t, err)
+			_, err = JoinBlob(info, append([][]byte{chunks[1]}, chunks[1:]...))
+			require.Error(t, err)
+		}
+	}
+}
+
+func TestBlob_Encrypt(t *testing.T) {
+	key := NewBlobKey()
+	require.Equal(t, BlobKeyLength, len(key))
+	data := []byte("some data that needs to stay secret")
+	ct, err := EncryptBlob(key, data)
+	require.NoError(t, err)
+	require.NotEqual(t, data, ct[:len(data)])
+
+	dec, err := DecryptBlob(key, ct)
+	require.NoError(t, err)
+	require.Equal(t, data, dec)
+
+	ct[0] ^= 1
+	_, err = DecryptBlob(key, ct)
+	require.Error(t, err)
+	_, err = DecryptBlob(NewBlobKey(), ct)
+	require.Error(t, err)
+	_, err = EncryptBlob(key[1:], data)
+	require.Error(t, err)
+}
@@ -36,6 +36,19 @@ func (w Write) String() string {
 	fmt.Fprintf(out, "-- ExtraData: %s\n", w.ExtraData)
 	fmt.Fprintf(out, "-- LTSID: %s\n", w.LTSID)
 	fmt.Fprintf(out, "-- Cost: %x\n", w.Cost)
+	fmt.Fprintf(out, "-- Revoked: %t\n", w.Revoked)
+	if w.Blob != nil {
+		fmt.Fprintf(out, "-- Blob: %d bytes in %d chunks, root %x\n",
+			w.Blob.Size, w.Blob.Chunks, w.Blob.Root)
+	}
+	if w.ReleaseAfter != nil {
+		fmt.Fprintf(out, "-- ReleaseAfter: block %d, timestamp %d, public %t\n",
+			w.ReleaseAfter.BlockIndex, w.ReleaseAfter.Timestamp,
+			w.ReleaseAfter.KeyHash != nil)
+	}
+	if w.Released != nil {
+		fmt.Fprintf(out, "-- Released: %x\n", w.Released)
+	}
 
 	return out.String()
 }
@@ -49,7 +62,8 @@ func contractWriteFromBytes(in []byte) (byzcoin.Contract, error) {
 
 // Spawn is used to create a new write- or read-contract. The read-contract is
 // created by the write-instance, because the creation of a new read-instance is
-// protected by the write-contract's darc.
+// protected by the write-contract's darc. The chunks of a blob are created by
+// the write-instance, too.
 func (c ContractWrite) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
 	cout = coins
 
@@ -79,6 +93,22 @@ func (c ContractWrite) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 			err = xerrors.Errorf("proof of write failed: %v", err)
 			return
 		}
+		if c.Write.Blob != nil {
+			if err = c.Write.Blob.verify(); err != nil {
+				err = xerrors.Errorf("invalid blob: %v", err)
+				return
+			}
+		}
+		if c.Write.ReleaseAfter != nil {
+			if err = c.Write.ReleaseAfter.verify(); err != nil {
+				err = xerrors.Errorf("invalid time-lock: %v", err)
+				return
+			}
+		}
+		if c.Write.Released != nil || c.Write.Revoked {
+			err = xerrors.New("a new write cannot be released or revoked")
+			return
+		}
 		instID, err := inst.DeriveIDArg("", "preID")
 		if err != nil {
 			return nil, nil, xerrors.Errorf(
@@ -99,6 +129,22 @@ func (c ContractWrite) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 		if !rd.Write.Equal(inst.InstanceID) {
 			return nil, nil, xerrors.New("the read request doesn't reference this write-instance")
 		}
+		if c.Revoked {
+			return nil, nil, xerrors.New("the write-instance has been revoked")
+		}
+		if (rd.Xc == nil) == (rd.Group == nil) {
+			return nil, nil, xerrors.New("the read request needs either a public key or a group")
+		}
+		if rd.Group != nil {
+			var cid string
+			_, _, cid, _, err = rst.GetValues(rd.Group)
+			if err != nil {
+				return nil, nil, xerrors.Errorf("couldn't get group darc: %v", err)
+			}
+			if cid != byzcoin.ContractDarcID {
+				return nil, nil, xerrors.New("the group is not a darc")
+			}
+		}
 		if c.Cost.Value > 0 {
 			for i, coin := range cout {
 				if coin.Name.Equal(c.Cost.Name) {
@@ -118,15 +164,29 @@ func (c ContractWrite) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 		}
 		sc = byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Create,
 			instID, ContractReadID, r, darcID)}
+	case ContractBlobChunkID:
+		var chunk byzcoin.StateChange
+		chunk, err = c.spawnChunk(inst, darcID)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("invalid chunk: %v", err)
+		}
+		sc = byzcoin.StateChanges{chunk}
 	default:
-		err = xerrors.New("can only spawn writes and reads")
+		err = xerrors.New("can only spawn writes, reads and chunks")
 	}
 	return
 }
 
-// Invoke supports the following command:
+// Invoke supports the following commands:
 //  - update - it takes a 'data' and/or 'extraData' argument that is used to
 //    update the data and/or extradata part of the write structure.
+//  - revoke - marks the write as revoked, so that no new read instances can
+//    be spawned and the LTS refuses any new decryption.
+//  - release - takes the secret in the 'key' argument and stores it in the
+//    write, if the write is released to everyone and its time-lock is over.
+//    It is sent by the LTS and neither protected by the darc nor charged
+//    fees, as the secret is verified against the hash in the time-lock and
+//    can only be released once.
 func (c *ContractWrite) Invoke(rst byzcoin.ReadOnlyStateTrie,
 	inst byzcoin.Instruction, cin []byzcoin.Coin) ([]byzcoin.StateChange,
 	[]byzcoin.Coin, error) {
@@ -150,12 +210,20 @@ func (c *ContractWrite) Invoke(rst byzcoin.ReadOnlyStateTrie,
 			c.ExtraData = extraData
 			update = true
 		}
+		if !update {
+			return nil, nil, xerrors.New("neither data nor extraData update")
+		}
+	case "revoke":
+		if c.Revoked {
+			return nil, nil, xerrors.New("write is already revoked")
+		}
+		c.Revoked = true
+	case "release":
+		if err = c.release(rst, inst.Invoke.Args.Search("key")); err != nil {
+			return nil, nil, xerrors.Errorf("couldn't release: %v", err)
+		}
 	default:
-		return nil, nil, xerrors.New("only know 'update' command")
-	}
-
-	if !update {
-		return nil, nil, xerrors.New("neither data nor extraData update")
+		return nil, nil, xerrors.New("only know 'update', 'revoke' and 'release' commands")
 	}
 
 	var ciBuf []byte
@@ -214,6 +282,11 @@ func (c *contractLTS) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruct
 	if err != nil {
 		return nil, nil, xerrors.Errorf("passed lts_instance_info argument is invalid: %v", err)
 	}
+	if info.FollowRoster {
+		if err = checkByzCoinRoster(rst, &info.Roster); err != nil {
+			return nil, nil, err
+		}
+	}
 	return byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Create, inst.DeriveID(""), ContractLongTermSecretID, infoBuf, darcID)}, coins, nil
 }
 
@@ -241,6 +314,19 @@ func (c *contractLTS) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 	if err != nil {
 		return nil, nil, xerrors.Errorf("current info is invalid: %v", err)
 	}
+	if curInfo.FollowRoster {
+		// The LTS has been following the ByzCoin roster since the spawn.
+		config, err := rst.LoadConfig()
+		if err != nil {
+			return nil, nil, xerrors.Errorf("loading config: %v", err)
+		}
+		curInfo.Roster = config.Roster
+	}
+	if newInfo.FollowRoster {
+		if err = checkByzCoinRoster(rst, &newInfo.Roster); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	// Verify the intersection between new roster and the old one. There must be
 	// at least a threshold of nodes in the intersection.
@@ -254,6 +340,33 @@ func (c *contractLTS) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 	return byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID, ContractLongTermSecretID, infoBuf, darcID)}, coins, nil
 }
 
+// checkByzCoinRoster returns an error if the roster is not the current roster
+// of the ByzCoin ledger.
+func checkByzCoinRoster(rst byzcoin.ReadOnlyStateTrie, roster *onet.Roster) error {
+	config, err := rst.LoadConfig()
+	if err != nil {
+		return xerrors.Errorf("loading config: %v", err)
+	}
+	if !rostersEqual(&config.Roster, roster) {
+		return xerrors.New("an LTS following the ByzCoin roster must start with the ByzCoin roster")
+	}
+	return nil
+}
+
+// rostersEqual returns true if both rosters hold the same nodes in the same
+// order.
+func rostersEqual(r1, r2 *onet.Roster) bool {
+	if len(r1.List) != len(r2.List) {
+		return false
+	}
+	for i, si := range r1.List {
+		if !si.Equal(r2.List[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func intersectRosters(r1, r2 *onet.Roster) int {
 	res := 0
 	for _, x := range r2.List {
@@ -266,8 +379,12 @@ func intersectRosters(r1, r2 *onet.Roster) int {
 
 // VerifyInstruction uses a specific verification based on attr in the case it
 // is a read spawn. This will check if any makeAttInterpreter has been
-// registered in the service and apply them.
+// registered in the service and apply them. The 'release' command is not
+// verified against the darc, because it can only store the correct secret.
 func (c ContractWrite) VerifyInstruction(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, ctxHash []byte) error {
+	if inst.GetType() == byzcoin.InvokeType && inst.Invoke.Command == "release" {
+		return nil
+	}
 	if inst.GetType() == byzcoin.SpawnType && inst.Spawn.ContractID == ContractReadID {
 
 		evalAttr := darc.AttrInterpreters{}
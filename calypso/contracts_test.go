@@ -2,7 +2,9 @@ package calypso
 
 import (
 	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/protobuf"
 	"testing"
 )
@@ -48,3 +50,160 @@ func TestContractWrite_Invoke(t *testing.T) {
 	require.NoError(t, protobuf.Decode(scs[0].Value, &cwNew))
 	require.Equal(t, []byte("newExtraData"), cwNew.ExtraData)
 }
+
+func TestContractWrite_Revoke(t *testing.T) {
+	rost := byzcoin.NewROSTSimul()
+
+	cw := ContractWrite{Write: Write{Data: []byte("data")}}
+	cwID, err := rost.CreateRandomInstance(ContractWriteID, &cw, nil)
+	require.NoError(t, err)
+	group, err := rost.CreateBasicDarc(nil, "readers")
+	require.NoError(t, err)
+
+	spawnRead := func(rd Read) error {
+		rdBuf, err := protobuf.Encode(&rd)
+		require.NoError(t, err)
+		_, _, err = cw.Spawn(rost, byzcoin.Instruction{
+			InstanceID: cwID,
+			Spawn: &byzcoin.Spawn{
+				ContractID: ContractReadID,
+				Args:       byzcoin.Arguments{{Name: "read", Value: rdBuf}},
+			}}, nil)
+		return err
+	}
+	// A read needs either a public key or an existing group darc.
+	require.Error(t, spawnRead(Read{Write: cwID}))
+	xc := cothority.Suite.Point().Pick(cothority.Suite.RandomStream())
+	require.Error(t, spawnRead(Read{Write: cwID, Xc: xc, Group: group.GetBaseID()}))
+	require.Error(t, spawnRead(Read{Write: cwID, Group: make(darc.ID, 32)}))
+	require.Error(t, spawnRead(Read{Write: cwID, Group: darc.ID(cwID.Slice())}))
+	require.NoError(t, spawnRead(Read{Write: cwID, Group: group.GetBaseID()}))
+
+	instr := byzcoin.Instruction{
+		InstanceID: cwID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: ContractWriteID,
+			Command:    "revoke",
+		}}
+	scs, _, err := cw.Invoke(rost, instr, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(scs))
+	require.NoError(t, protobuf.Decode(scs[0].Value, &cw.Write))
+	require.True(t, cw.Revoked)
+	require.Equal(t, []byte("data"), cw.Data)
+
+	_, _, err = cw.Invoke(rost, instr, nil)
+	require.Error(t, err)
+	require.Error(t, spawnRead(Read{Write: cwID, Group: group.GetBaseID()}))
+}
+
+func TestContractWrite_SpawnChunk(t *testing.T) {
+	rost := byzcoin.NewROSTSimul()
+
+	data := make([]byte, 100)
+	info, chunks, err := SplitBlob(data, 30)
+	require.NoError(t, err)
+	cw := ContractWrite{Write: Write{Blob: info}}
+	cwID, err := rost.CreateRandomInstance(ContractWriteID, &cw, nil)
+	require.NoError(t, err)
+
+	spawnChunk := func(args byzcoin.Arguments) ([]byzcoin.StateChange, error) {
+		scs, _, err := cw.Spawn(rost, byzcoin.Instruction{
+			InstanceID: cwID,
+			Spawn: &byzcoin.Spawn{
+				ContractID: ContractBlobChunkID,
+				Args:       args,
+			}}, nil)
+		return scs, err
+	}
+	for i := range chunks {
+		scs, err := spawnChunk(BlobChunkArgs(chunks, uint64(i)))
+		require.NoError(t, err)
+		require.Equal(t, 1, len(scs))
+		require.Equal(t, BlobChunkID(cwID, uint64(i)).Slice(), scs[0].InstanceID)
+		var chunk BlobChunk
+		require.NoError(t, protobuf.Decode(scs[0].Value, &chunk))
+		require.Equal(t, chunks[i], chunk.Data)
+		require.True(t, chunk.Write.Equal(cwID))
+	}
+
+	// Chunks with wrong data or at the wrong index are refused.
+	args := BlobChunkArgs(chunks, 1)
+	args[1].Value = []byte("wrong data")
+	_, err = spawnChunk(args)
+	require.Error(t, err)
+	args = BlobChunkArgs(chunks, 1)
+	args[0].Value = BlobChunkArgs(chunks, 2)[0].Value
+	_, err = spawnChunk(args)
+	require.Error(t, err)
+	chunks = append(chunks, []byte("one too many"))
+	_, err = spawnChunk(BlobChunkArgs(chunks, 4))
+	require.Error(t, err)
+
+	// No chunks for writes without a blob.
+	cw.Blob = nil
+	_, err = spawnChunk(BlobChunkArgs(chunks, 0))
+	require.Error(t, err)
+}
+
+// timeROST adds a block index and a block time to the ROSTSimul.
+type timeROST struct {
+	*byzcoin.ROSTSimul
+	index     int
+	timestamp int64
+}
+
+func (r timeROST) GetIndex() int {
+	return r.index
+}
+
+func (r timeROST) GetCurrentBlockTimestamp() int64 {
+	return r.timestamp
+}
+
+func TestContractWrite_Release(t *testing.T) {
+	rost := timeROST{ROSTSimul: byzcoin.NewROSTSimul(), index: 9, timestamp: 1000}
+
+	secret := []byte("secret key")
+	cw := ContractWrite{Write: Write{
+		ReleaseAfter: NewReleaseAfter(10, 1000, secret, true)}}
+	cwID, err := rost.CreateRandomInstance(ContractWriteID, &cw, nil)
+	require.NoError(t, err)
+	instr := byzcoin.Instruction{
+		InstanceID: cwID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: ContractWriteID,
+			Command:    "release",
+			Args:       byzcoin.Arguments{{Name: "key", Value: secret}},
+		}}
+	// Releasing is not protected by the darc.
+	require.NoError(t, cw.VerifyInstruction(rost, instr, nil))
+
+	// Too early, either by block index or by timestamp.
+	_, _, err = cw.Invoke(rost, instr, nil)
+	require.Error(t, err)
+	rost.index = 10
+	rost.timestamp = 999
+	_, _, err = cw.Invoke(rost, instr, nil)
+	require.Error(t, err)
+
+	rost.timestamp = 1000
+	instr.Invoke.Args[0].Value = []byte("wrong key")
+	_, _, err = cw.Invoke(rost, instr, nil)
+	require.Error(t, err)
+	instr.Invoke.Args[0].Value = secret
+	scs, _, err := cw.Invoke(rost, instr, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(scs))
+	require.NoError(t, protobuf.Decode(scs[0].Value, &cw.Write))
+	require.Equal(t, secret, cw.Released)
+
+	// The secret is released only once.
+	_, _, err = cw.Invoke(rost, instr, nil)
+	require.Error(t, err)
+
+	// A time-lock without the hash of the secret cannot be released.
+	cw.Write = Write{ReleaseAfter: NewReleaseAfter(10, 0, secret, false)}
+	_, _, err = cw.Invoke(rost, instr, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/urfave/cli"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/bcadmin/lib"
+	"go.dedis.ch/cothority/v3/calypso"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3/log"
+	"golang.org/x/xerrors"
+)
+
+// blobUpload encrypts a file with a random key, stores the key in a new write
+// instance and the encrypted file in chunks on ByzCoin. The file is read from
+// --file, or from STDIN. It prints the instance id of the write instance, or
+// only sends it to STDOUT with --export.
+func blobUpload(c *cli.Context) error {
+	bcArg := c.String("bc")
+	if bcArg == "" {
+		return xerrors.New("--bc flag is required")
+	}
+
+	cfg, cl, err := lib.LoadConfig(bcArg)
+	if err != nil {
+		return xerrors.Errorf("loading configuration: %v", err)
+	}
+
+	dstr := c.String("darc")
+	if dstr == "" {
+		dstr = cfg.AdminDarc.GetIdentityString()
+	}
+	d, err := lib.GetDarcByString(cl, dstr)
+	if err != nil {
+		return err
+	}
+
+	var signer *darc.Signer
+	sstr := c.String("sign")
+	if sstr == "" {
+		signer, err = lib.LoadKey(cfg.AdminIdentity)
+	} else {
+		signer, err = lib.LoadKeyFromString(sstr)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to parse the signer: %v", err)
+	}
+
+	instidbuf, err := hex.DecodeString(c.String("instid"))
+	if err != nil || len(instidbuf) == 0 {
+		return xerrors.New("please provide the LTS instance ID with --instid")
+	}
+
+	keyBuf, err := hex.DecodeString(c.String("key"))
+	if err != nil || len(keyBuf) == 0 {
+		return xerrors.New("please provide the hex string public key with --key")
+	}
+	p := cothority.Suite.Point()
+	err = p.UnmarshalBinary(keyBuf)
+	if err != nil {
+		return xerrors.Errorf("failed to unmarshal key: %v", err)
+	}
+
+	var data []byte
+	if file := c.String("file"); file != "" {
+		data, err = ioutil.ReadFile(file)
+	} else {
+		data, err = ioutil.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to read the file: %v", err)
+	}
+
+	key := calypso.NewBlobKey()
+	ciphertext, err := calypso.EncryptBlob(key, data)
+	if err != nil {
+		return xerrors.Errorf("failed to encrypt the file: %v", err)
+	}
+	info, chunks, err := calypso.SplitBlob(ciphertext, c.Int("chunkSize"))
+	if err != nil {
+		return xerrors.Errorf("failed to split the file: %v", err)
+	}
+
+	write := calypso.NewWrite(cothority.Suite, byzcoin.NewInstanceID(instidbuf),
+		d.GetBaseID(), p, key)
+	if write == nil {
+		return xerrors.New("got a nil write, this is due to a key that is " +
+			"too long to be embeded")
+	}
+	write.Blob = info
+
+	counters, err := cl.GetSignerCounters(signer.Identity().String())
+	if err != nil {
+		return xerrors.Errorf("getting signer counters: %v", err)
+	}
+
+	calypsoClient := calypso.NewClient(cl)
+	reply, err := calypsoClient.AddWrite(write, *signer,
+		counters.Counters[0]+1, *d, 10)
+	if err != nil {
+		return xerrors.Errorf("failed to spawn the write instance: %v", err)
+	}
+	err = calypsoClient.AddBlob(reply.InstanceID, chunks, *signer,
+		counters.Counters[0]+2, 10)
+	if err != nil {
+		return xerrors.Errorf("failed to store the chunks: %v", err)
+	}
+
+	err = lib.WaitPropagation(c, cl)
+	if err != nil {
+		return xerrors.Errorf("waiting for block propagation: %v", err)
+	}
+
+	iidStr := hex.EncodeToString(reply.InstanceID.Slice())
+	if c.Bool("export") {
+		_, err = fmt.Fprint(os.Stdout, iidStr)
+		return cothority.ErrorOrNil(err, "failed to write to stdout")
+	}
+
+	log.Infof("Stored the file in %d chunks. The write instance id is:\n%s",
+		info.Chunks, iidStr)
+
+	return nil
+}
+
+// blobDownload fetches the re-encrypted key of a write instance with the
+// proof of a read instance, then fetches the chunks of the file and decrypts
+// it. The file is written to --out, or to STDOUT.
+func blobDownload(c *cli.Context) error {
+	bcArg := c.String("bc")
+	if bcArg == "" {
+		return xerrors.New("--bc flag is required")
+	}
+
+	cfg, cl, err := lib.LoadConfig(bcArg)
+	if err != nil {
+		return xerrors.Errorf("failed to load config: %v", err)
+	}
+
+	getProof := func(iidArgs string) (*byzcoin.Proof, error) {
+		iid, err := hex.DecodeString(c.String(iidArgs))
+		if err != nil || len(iid) == 0 {
+			return nil, xerrors.Errorf("please provide the "+
+				"instance id with --%s", iidArgs)
+		}
+		pr, err := cl.GetProof(iid)
+		if err != nil {
+			return nil, xerrors.Errorf("couldn't get proof: %v", err)
+		}
+		if !pr.Proof.InclusionProof.Match(iid) {
+			return nil, xerrors.New("proof does not match")
+		}
+		return &pr.Proof, nil
+	}
+
+	writeProof, err := getProof("writeid")
+	if err != nil {
+		return xerrors.Errorf("failed to get write proof: %v", err)
+	}
+	readProof, err := getProof("readid")
+	if err != nil {
+		return xerrors.Errorf("failed to get read proof: %v", err)
+	}
+
+	keyPath := c.String("key")
+	var signer *darc.Signer
+	if keyPath == "" {
+		signer, err = lib.LoadKey(cfg.AdminIdentity)
+	} else {
+		signer, err = lib.LoadSigner(keyPath)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to load key file: %v", err)
+	}
+	xc, err := signer.GetPrivate()
+	if err != nil {
+		return xerrors.Errorf("failed to get private key: %v", err)
+	}
+
+	calypsoClient := calypso.NewClient(cl)
+	dkr, err := calypsoClient.DecryptKey(&calypso.DecryptKey{
+		Write: *writeProof, Read: *readProof})
+	if err != nil {
+		return xerrors.Errorf("failed to get the decrypt key: %v", err)
+	}
+	key, err := dkr.RecoverKey(xc)
+	if err != nil {
+		return xerrors.Errorf("failed to recover the key: %v", err)
+	}
+
+	ciphertext, err := calypsoClient.GetBlob(
+		byzcoin.NewInstanceID(writeProof.InclusionProof.Key()))
+	if err != nil {
+		return xerrors.Errorf("failed to get the file: %v", err)
+	}
+	data, err := calypso.DecryptBlob(key, ciphertext)
+	if err != nil {
+		return xerrors.Errorf("failed to decrypt the file: %v", err)
+	}
+
+	if out := c.String("out"); out != "" {
+		err = ioutil.WriteFile(out, data, 0600)
+		if err != nil {
+			return xerrors.Errorf("failed to write the file: %v", err)
+		}
+		log.Infof("Wrote %d bytes to %s", len(data), out)
+		return nil
+	}
+	_, err = os.Stdout.Write(data)
+	return cothority.ErrorOrNil(err, "failed to write to stdout")
+}
@@ -62,8 +62,12 @@ func LTSSpawn(c *cli.Context) error {
 	export := c.Bool("export")
 
 	// Make the transaction and get its proof
-	ltsInstanceInfo := calypso.LtsInstanceInfo{Roster: cfg.Roster}
+	ltsInstanceInfo := calypso.LtsInstanceInfo{Roster: cfg.Roster,
+		FollowRoster: c.Bool("follow")}
 	if rFile := c.String("roster"); rFile != "" {
+		if ltsInstanceInfo.FollowRoster {
+			return xerrors.New("--roster cannot be used with --follow")
+		}
 		r, err := lib.ReadRoster(rFile)
 		if err != nil {
 			return fmt.Errorf("couldn't load roster: %v", err)
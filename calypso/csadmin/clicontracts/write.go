@@ -6,6 +6,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"time"
 
 	"go.dedis.ch/onet/v3/log"
 	"golang.org/x/xerrors"
@@ -30,8 +31,10 @@ import (
 // is useful to store cleartext data, where --data should be used to store
 // encrypted data. The 'extra data' field can be filled with either --extraData
 // or --readExtra. Both --readExtra and --readData can NOT be used at the same
-// time. If everything goes well, it prints the instance id of the newly spawned
-// Write instance. With the --export option, the instance id is sent to STDOUT.
+// time. The secret can be time-locked with --releaseIndex and --releaseTime,
+// and released to everyone after this time with --public. If everything goes
+// well, it prints the instance id of the newly spawned Write instance. With the
+// --export option, the instance id is sent to STDOUT.
 func WriteSpawn(c *cli.Context) error {
 	bcArg := c.String("bc")
 	if bcArg == "" {
@@ -121,6 +124,20 @@ func WriteSpawn(c *cli.Context) error {
 	}
 	write.Data = dataBuf
 	write.ExtraData = extraDataBuf
+	if c.IsSet("releaseIndex") || c.IsSet("releaseTime") {
+		var timestamp int64
+		if rt := c.String("releaseTime"); rt != "" {
+			releaseTime, err := time.Parse(time.RFC3339, rt)
+			if err != nil {
+				return xerrors.Errorf("failed to parse --releaseTime: %v", err)
+			}
+			timestamp = releaseTime.UnixNano()
+		}
+		write.ReleaseAfter = calypso.NewReleaseAfter(c.Int("releaseIndex"),
+			timestamp, secretBuf, c.Bool("public"))
+	} else if c.Bool("public") {
+		return xerrors.New("--public needs --releaseIndex or --releaseTime")
+	}
 	writeBuf, err := protobuf.Encode(write)
 	if err != nil {
 		return xerrors.Errorf("failed to encode Write struct: %v", err)
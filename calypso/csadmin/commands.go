@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/urfave/cli"
+	"go.dedis.ch/cothority/v3/calypso"
 	"go.dedis.ch/cothority/v3/calypso/csadmin/clicontracts"
 )
 
@@ -99,6 +100,101 @@ var cmds = cli.Commands{
 			},
 		},
 	},
+	{
+		Name:   "release",
+		Usage:  "ask the LTS to publish the secret of a write instance that is released to everyone",
+		Action: release,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:   "bc",
+				EnvVar: "BC",
+				Usage:  "the ByzCoin config to use (required)",
+			},
+			cli.StringFlag{
+				Name:  "writeid, w",
+				Usage: "instance id of the write instance",
+			},
+			cli.BoolFlag{
+				Name:  "export, x",
+				Usage: "exports the released secret to STDOUT",
+			},
+		},
+	},
+	{
+		Name:  "blob",
+		Usage: "store and retrieve encrypted files on ByzCoin",
+		Subcommands: cli.Commands{
+			{
+				Name:   "upload",
+				Usage:  "encrypt a file and store it in chunks, together with a write instance holding the key",
+				Action: blobUpload,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:   "bc",
+						EnvVar: "BC",
+						Usage:  "the ByzCoin config to use (required)",
+					},
+					cli.StringFlag{
+						Name:  "darc",
+						Usage: "DARC with the right to spawn a write and its chunks (default is the admin DARC)",
+					},
+					cli.StringFlag{
+						Name:  "sign, s",
+						Usage: "public key of the signing entity (default is the admin public key)",
+					},
+					cli.StringFlag{
+						Name:  "instid, i",
+						Usage: "the instance ID of the LTS (required)",
+					},
+					cli.StringFlag{
+						Name:  "key",
+						Usage: "the collective public key of the LTS, as a hex string (required)",
+					},
+					cli.StringFlag{
+						Name:  "file, f",
+						Usage: "the file to store (default is to read from STDIN)",
+					},
+					cli.IntFlag{
+						Name:  "chunkSize",
+						Usage: "the maximum size of a chunk, in bytes",
+						Value: calypso.DefaultBlobChunkSize,
+					},
+					cli.BoolFlag{
+						Name:  "export, x",
+						Usage: "exports the instance id of the write instance to STDOUT",
+					},
+				},
+			},
+			{
+				Name:   "download",
+				Usage:  "get the key of a write instance with a read instance, then fetch and decrypt the file",
+				Action: blobDownload,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:   "bc",
+						EnvVar: "BC",
+						Usage:  "the ByzCoin config to use (required)",
+					},
+					cli.StringFlag{
+						Name:  "writeid, w",
+						Usage: "instance id of the write instance",
+					},
+					cli.StringFlag{
+						Name:  "readid, r",
+						Usage: "instance id of the read instance",
+					},
+					cli.StringFlag{
+						Name:  "key",
+						Usage: "path to the private.toml file of the reader (default is admin key)",
+					},
+					cli.StringFlag{
+						Name:  "out, o",
+						Usage: "the file to write to (default is STDOUT)",
+					},
+				},
+			},
+		},
+	},
 	{
 		Name:  "contract",
 		Usage: "Provides cli interface for contracts",
@@ -122,6 +218,11 @@ var cmds = cli.Commands{
 								Usage: "the path of a roster file to be used as argument for the spawn. " +
 									"If not provided the config roster is used (optional)",
 							},
+							cli.BoolFlag{
+								Name: "follow",
+								Usage: "make the LTS follow the roster of ByzCoin, so that it is " +
+									"reshared automatically when the roster changes",
+							},
 							cli.StringFlag{
 								Name:  "darc",
 								Usage: "DARC with the right to create an LTS (default is the admin DARC)",
@@ -188,6 +289,18 @@ var cmds = cli.Commands{
 								Name:  "key",
 								Usage: "hexadecimal LTS public key",
 							},
+							cli.IntFlag{
+								Name:  "releaseIndex",
+								Usage: "time-lock the secret until ByzCoin reaches this block index",
+							},
+							cli.StringFlag{
+								Name:  "releaseTime",
+								Usage: "time-lock the secret until a block has this time (RFC3339 format)",
+							},
+							cli.BoolFlag{
+								Name:  "public",
+								Usage: "release the secret to everyone after the time-lock",
+							},
 							cli.BoolFlag{
 								Name:  "export, x",
 								Usage: "export the instance id to STDOUT",
@@ -384,3 +384,47 @@ func decrypt(c *cli.Context) error {
 
 	return nil
 }
+
+// release asks the LTS to publish the secret of a write instance. The write
+// instance must be released to everyone, and its time-lock must be over.
+func release(c *cli.Context) error {
+	bcArg := c.String("bc")
+	if bcArg == "" {
+		return xerrors.New("--bc flag is required")
+	}
+
+	_, cl, err := lib.LoadConfig(bcArg)
+	if err != nil {
+		return xerrors.Errorf("failed to load config: %v", err)
+	}
+
+	iid, err := hex.DecodeString(c.String("writeid"))
+	if err != nil || len(iid) == 0 {
+		return xerrors.New("please provide the instance id with --writeid")
+	}
+	pr, err := cl.GetProof(iid)
+	if err != nil {
+		return xerrors.Errorf("couldn't get proof: %v", err)
+	}
+	if !pr.Proof.InclusionProof.Match(iid) {
+		return xerrors.New("proof does not match")
+	}
+
+	reply, err := calypso.NewClient(cl).ReleaseKey(&pr.Proof)
+	if err != nil {
+		return xerrors.Errorf("failed to release the key: %v", err)
+	}
+
+	if c.Bool("export") {
+		reader := bytes.NewReader(reply.Key)
+		_, err = io.Copy(os.Stdout, reader)
+		if err != nil {
+			return xerrors.Errorf("failed to copy to stdout: %v", err)
+		}
+		return nil
+	}
+
+	log.Infof("Key released:\n%x", reply.Key)
+
+	return nil
+}
@@ -34,6 +34,8 @@ main(){
     run testContractRead
     run testReencrypt
     run testDecrypt
+    run testBlob
+    run testTimeLock
     stopTest
 }
 
@@ -292,6 +294,143 @@ aabbccddeeff0011"
 aabbccddeeff0011"
 }
 
+# Rely on:
+# - csadmin contract lts spawn
+# - csadmin authorize
+# - csadmin dkg start
+# - csadmin contract read spawn
+testBlob(){
+    rm -f config/*
+    runCoBG 1 2 3
+    runGrepSed "export BC=" "" runBA create --roster public.toml --interval .5s
+    eval $SED
+    [ -z "$BC" ] && exit 1
+
+    # Create a DARC
+    testOK runBA darc add -out_id ./darc_id.txt -out_key ./darc_key.txt -unrestricted
+    ID=`cat ./darc_id.txt`
+    KEY=`cat ./darc_key.txt`
+    testOK runBA darc rule -rule "spawn:longTermSecret" --darc $ID --sign $KEY --identity $KEY
+    testOK runBA darc rule -rule "spawn:calypsoWrite" -darc $ID -sign $KEY -identity $KEY
+    testOK runBA darc rule -rule "spawn:calypsoRead" -darc $ID -sign $KEY -identity $KEY
+
+    # Spawn LTS and start the DKG
+    OUTRES=`runCA0 contract lts spawn --darc "$ID" --sign "$KEY"`
+    LTS_ID=`echo "$OUTRES" | sed -n '2p'` # must be at the second line
+    matchOK $LTS_ID ^[0-9a-f]{64}$
+    bcID=$( ls config/bc-* | sed -e "s/.*bc-\(.*\).cfg/\1/" )
+    testOK runCA authorize co1/private.toml $bcID
+    testOK runCA authorize co2/private.toml $bcID
+    testOK runCA authorize co3/private.toml $bcID
+    runCA0 dkg start --instid "$LTS_ID" -x > key.pub
+    PUB_KEY=`cat key.pub`
+    matchOK $PUB_KEY ^[0-9a-f]{64}$
+
+    # A file of a few chunks
+    head -c 5000 /dev/urandom > blob.bin
+
+    # should fail without the rule to spawn chunks
+    testFail runCA blob upload --darc "$ID" --sign "$KEY" \
+                --instid "$LTS_ID" --key "$PUB_KEY" --file blob.bin
+    testOK runBA darc rule -rule "spawn:calypsoChunk" -darc $ID -sign $KEY -identity $KEY
+
+    OUTRES=`runCA0 blob upload --darc "$ID" --sign "$KEY" --instid "$LTS_ID" \
+                --key "$PUB_KEY" --file blob.bin --chunkSize 1000`
+    testGrep "Stored the file in 6 chunks" echo "$OUTRES"
+    WRITE_ID=`echo "$OUTRES" | sed -n '2p'` # must be at the second line
+    matchOK $WRITE_ID ^[0-9a-f]{64}$
+
+    # the data can also come from STDIN
+    WRITE_ID2=`runCA0 blob upload --darc "$ID" --sign "$KEY" \
+                --instid "$LTS_ID" --key "$PUB_KEY" -x < blob.bin`
+    matchOK $WRITE_ID2 ^[0-9a-f]{64}$
+
+    OUTRES=`runCA0 contract read spawn --sign $KEY --instid $WRITE_ID`
+    READ_ID=`echo "$OUTRES" | sed -n '2p'` # must be at the second line
+    matchOK $READ_ID ^[0-9a-f]{64}$
+
+    # should fail with the default key, or with the wrong write instance
+    testFail runCA blob download --writeid $WRITE_ID --readid $READ_ID \
+                --out out.bin
+    testFail runCA blob download --writeid $WRITE_ID2 --readid $READ_ID \
+                --key config/key-$KEY.cfg --out out.bin
+
+    testOK runCA blob download --writeid $WRITE_ID --readid $READ_ID \
+                --key config/key-$KEY.cfg --out out.bin
+    testOK cmp blob.bin out.bin
+    runCA0 blob download --writeid $WRITE_ID --readid $READ_ID \
+                --key config/key-$KEY.cfg > out2.bin
+    testOK cmp blob.bin out2.bin
+}
+
+# Rely on:
+# - csadmin contract lts spawn
+# - csadmin authorize
+# - csadmin dkg start
+# - csadmin contract write spawn
+# - csadmin contract read spawn
+testTimeLock(){
+    rm -f config/*
+    runCoBG 1 2 3
+    runGrepSed "export BC=" "" runBA create --roster public.toml --interval .5s
+    eval $SED
+    [ -z "$BC" ] && exit 1
+
+    testOK runBA darc add -out_id ./darc_id.txt -out_key ./darc_key.txt -unrestricted
+    ID=`cat ./darc_id.txt`
+    KEY=`cat ./darc_key.txt`
+    testOK runBA darc rule -rule "spawn:longTermSecret" --darc $ID --sign $KEY --identity $KEY
+    testOK runBA darc rule -rule "spawn:calypsoWrite" -darc $ID -sign $KEY -identity $KEY
+    testOK runBA darc rule -rule "spawn:calypsoRead" -darc $ID -sign $KEY -identity $KEY
+
+    OUTRES=`runCA0 contract lts spawn --darc "$ID" --sign "$KEY"`
+    LTS_ID=`echo "$OUTRES" | sed -n '2p'` # must be at the second line
+    matchOK $LTS_ID ^[0-9a-f]{64}$
+    bcID=$( ls config/bc-* | sed -e "s/.*bc-\(.*\).cfg/\1/" )
+    testOK runCA authorize co1/private.toml $bcID
+    testOK runCA authorize co2/private.toml $bcID
+    testOK runCA authorize co3/private.toml $bcID
+    runCA0 dkg start --instid "$LTS_ID" -x > key.pub
+    PUB_KEY=`cat key.pub`
+    matchOK $PUB_KEY ^[0-9a-f]{64}$
+
+    # --public needs a time-lock
+    testFail runCA contract write spawn --darc "$ID" --sign "$KEY" \
+                --instid "$LTS_ID" --secret "aabbccddeeff0011" --key "$PUB_KEY" \
+                --public
+
+    # A secret that is locked for a long time
+    WRITE_ID=`runCA0 contract write spawn --darc "$ID" --sign "$KEY" \
+                --instid "$LTS_ID" --secret "aabbccddeeff0011" --key "$PUB_KEY" \
+                --releaseIndex 1000 --public -x`
+    matchOK $WRITE_ID ^[0-9a-f]{64}$
+    OUTRES=`runCA0 contract read spawn --sign $KEY --instid $WRITE_ID`
+    READ_ID=`echo "$OUTRES" | sed -n '2p'` # must be at the second line
+    matchOK $READ_ID ^[0-9a-f]{64}$
+    testFail runCA reencrypt --writeid $WRITE_ID --readid $READ_ID
+    testFail runCA release --writeid $WRITE_ID
+
+    # A secret whose time-lock is over, but that is not public
+    WRITE_ID=`runCA0 contract write spawn --darc "$ID" --sign "$KEY" \
+                --instid "$LTS_ID" --secret "aabbccddeeff0011" --key "$PUB_KEY" \
+                --releaseTime 2000-01-01T00:00:00Z -x`
+    matchOK $WRITE_ID ^[0-9a-f]{64}$
+    testFail runCA release --writeid $WRITE_ID
+
+    # A public secret whose time-lock is over
+    WRITE_ID=`runCA0 contract write spawn --darc "$ID" --sign "$KEY" \
+                --instid "$LTS_ID" --secret "aabbccddeeff0011" --key "$PUB_KEY" \
+                --releaseTime 2000-01-01T00:00:00Z --public -x`
+    matchOK $WRITE_ID ^[0-9a-f]{64}$
+    OUTRES=`runCA0 contract read spawn --sign $KEY --instid $WRITE_ID`
+    READ_ID=`echo "$OUTRES" | sed -n '2p'` # must be at the second line
+    testOK runCA reencrypt --writeid $WRITE_ID --readid $READ_ID
+    OUTRES=`runCA0 release --writeid $WRITE_ID`
+    matchOK "$OUTRES" "Key released:
+aabbccddeeff0011"
+    testGrep "Released: aabbccddeeff0011" runCA contract write get --instid $WRITE_ID
+}
+
 runCA(){
     ./csadmin -c config/ --debug $DBG_APP "$@"
 }
@@ -6,6 +6,7 @@ import (
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/byzcoin"
 	dkgprotocol "go.dedis.ch/cothority/v3/dkg/pedersen"
+	"go.dedis.ch/cothority/v3/skipchain"
 	dkg "go.dedis.ch/kyber/v3/share/dkg/pedersen"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
@@ -27,6 +28,9 @@ type storage struct {
 	Rosters map[byzcoin.InstanceID]*onet.Roster
 	Replies map[byzcoin.InstanceID]*CreateLTSReply
 	DKS     map[byzcoin.InstanceID]*dkg.DistKeyShare
+	// Follow holds the ByzCoin ID of the LTSs that follow the roster of
+	// their ByzCoin ledger.
+	Follow map[byzcoin.InstanceID]skipchain.SkipBlockID
 
 	sync.Mutex
 }
@@ -69,6 +73,9 @@ func (s *Service) tryLoad() error {
 		if len(s.storage.DKS) == 0 {
 			s.storage.DKS = make(map[byzcoin.InstanceID]*dkg.DistKeyShare)
 		}
+		if len(s.storage.Follow) == 0 {
+			s.storage.Follow = make(map[byzcoin.InstanceID]skipchain.SkipBlockID)
+		}
 		if len(s.storage.AuthorisedByzCoinIDs) == 0 {
 			s.storage.AuthorisedByzCoinIDs = make(map[string]bool)
 		}
@@ -2,6 +2,7 @@ package calypso
 
 import (
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/onet/v3"
@@ -9,6 +10,7 @@ import (
 
 // PROTOSTART
 // type :skipchain.SkipBlockID:bytes
+// type :darc.ID:bytes
 // package calypso;
 // import "byzcoin.proto";
 // import "onet.proto";
@@ -47,13 +49,60 @@ type Write struct {
 	LTSID byzcoin.InstanceID
 	// Cost reflects how many coins you'll have to pay for a read-request
 	Cost byzcoin.Coin `protobuf:"opt"`
+	// Revoked is set once the write has been revoked. No new read
+	// instances can be spawned, and the LTS refuses to re-encrypt the
+	// secret.
+	Revoked bool `protobuf:"opt"`
+	// Blob is set if the data encrypted under the key is stored on-chain,
+	// split into calypsoChunk instances.
+	Blob *BlobInfo `protobuf:"opt"`
+	// ReleaseAfter is set if the secret must not be re-encrypted before
+	// a given block.
+	ReleaseAfter *ReleaseAfter `protobuf:"opt"`
+	// Released holds the secret once it has been released to everyone.
+	Released []byte `protobuf:"opt"`
+}
+
+// ReleaseAfter time-locks the secret of a write: the LTS refuses to
+// re-encrypt it before ByzCoin proved a block with at least the given index
+// and timestamp. A value of 0 is ignored.
+type ReleaseAfter struct {
+	// BlockIndex is the index of the first block that releases the secret.
+	BlockIndex int `protobuf:"opt"`
+	// Timestamp is the first block timestamp, in nanoseconds since the
+	// epoch, that releases the secret.
+	Timestamp int64 `protobuf:"opt"`
+	// KeyHash is the sha256 of the secret. If it is set, anybody can ask
+	// the LTS to publish the secret in the write instance, once the time
+	// is over.
+	KeyHash []byte `protobuf:"opt"`
 }
 
 // Read is the data stored in a read instance. It has a pointer to the write
-// instance and the public key used to re-encrypt the secret to.
+// instance and the public key used to re-encrypt the secret to. For a group
+// read, Group holds the base ID of a darc instead, and the secret is
+// re-encrypted to the key of any current member of the darc.
 type Read struct {
 	Write byzcoin.InstanceID
-	Xc    kyber.Point
+	Xc    kyber.Point `protobuf:"opt"`
+	Group darc.ID     `protobuf:"opt"`
+}
+
+// BlobInfo describes an encrypted blob stored in chunks on ByzCoin. Root is
+// the Merkle root of the chunks, so every chunk can be verified on its own
+// when it is added.
+type BlobInfo struct {
+	Root   []byte
+	Size   uint64
+	Chunks uint64
+}
+
+// BlobChunk is the data stored in a calypsoChunk instance. It points to the
+// write instance of the blob.
+type BlobChunk struct {
+	Write byzcoin.InstanceID
+	Index uint64
+	Data  []byte
 }
 
 // ***
@@ -128,6 +177,23 @@ type DecryptKey struct {
 	Read byzcoin.Proof
 	// Write is the proof containing the write request.
 	Write byzcoin.Proof
+	// Xc is the public key of the group member to re-encrypt the secret
+	// to. It is only used if the read instance is a group read.
+	Xc kyber.Point `protobuf:"opt"`
+}
+
+// ReleaseKey asks the LTS to publish the secret of a write instance that is
+// released to everyone. The time-lock of the write must be over.
+type ReleaseKey struct {
+	// Write is the proof containing the write request.
+	Write byzcoin.Proof
+}
+
+// ReleaseKeyReply is returned once the secret is stored in the write
+// instance.
+type ReleaseKeyReply struct {
+	// Key is the released secret.
+	Key []byte
 }
 
 // DecryptKeyReply is returned if the service verified successfully that the
@@ -150,4 +216,9 @@ type GetLTSReply struct {
 // LtsInstanceInfo is the information stored in an LTS instance.
 type LtsInstanceInfo struct {
 	Roster onet.Roster
+	// FollowRoster makes the LTS follow the roster of the ByzCoin ledger
+	// instead of Roster, which must be the ByzCoin roster at the time of
+	// the spawn. Whenever a block with a new roster appears, the nodes
+	// reshare the LTS to it.
+	FollowRoster bool `protobuf:"opt"`
 }
@@ -29,11 +29,13 @@ package calypso
 
 import (
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"math"
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -67,6 +69,14 @@ const propagationTimeout = 20 * time.Second
 
 const calypsoReshareProto = "calypso_reshare_proto"
 
+// reshareRetries is how many times every node tries to reshare an LTS that
+// follows the ByzCoin roster.
+const reshareRetries = 3
+
+// reshareRetryInterval is how long a node waits for the previous node to
+// reshare an LTS that follows the ByzCoin roster, before trying itself.
+var reshareRetryInterval = 20 * time.Second
+
 var allowInsecureAdmin = false
 
 // Allows one to register custom MakeAttrInterpreters for the read request
@@ -104,10 +114,17 @@ func init() {
 	if err != nil {
 		log.ErrFatal(err)
 	}
+	// The release is sent by the LTS, which has no coins to pay fees. The
+	// contract only accepts the secret matching the time-lock, once.
+	byzcoin.RegisterFeeExemptCommand(ContractWriteID, "release")
 	err = byzcoin.RegisterGlobalContract(ContractReadID, contractReadFromBytes)
 	if err != nil {
 		log.ErrFatal(err)
 	}
+	err = byzcoin.RegisterGlobalContract(ContractBlobChunkID, contractBlobChunkFromBytes)
+	if err != nil {
+		log.ErrFatal(err)
+	}
 	err = byzcoin.RegisterGlobalContract(ContractLongTermSecretID, contractLTSFromBytes)
 	if err != nil {
 		log.ErrFatal(err)
@@ -123,6 +140,12 @@ type Service struct {
 	// blocks are only used to insure that proofs start with the expected roster.
 	genesisBlocks     map[string]*skipchain.SkipBlock
 	genesisBlocksLock sync.Mutex
+	// following holds the IDs of the ByzCoin ledgers whose blocks are
+	// watched for roster changes, and resharing the LTSs that are being
+	// reshared to a new ByzCoin roster.
+	following  map[string]bool
+	resharing  map[byzcoin.InstanceID]bool
+	followLock sync.Mutex
 	// for use by testing only
 	afterReshare func()
 }
@@ -135,11 +158,14 @@ type pubPoly struct {
 
 // vData is sent to all nodes when re-encryption takes place. If Ephemeral
 // is non-nil, Signature needs to hold a valid signature from the reader
-// in the Proof.
+// in the Proof. Write is a proof of the write instance, from which every node
+// fetches the latest state of the write instance and of the group darc.
 type vData struct {
 	Proof     byzcoin.Proof
 	Ephemeral kyber.Point
 	Signature *darc.Signature
+	Write     *byzcoin.Proof
+	Release   bool
 }
 
 // AddReadAttrInterpreter adds a new AttrInterpreters that will be evaluated
@@ -257,6 +283,10 @@ func (s *Service) CreateLTS(req *CreateLTS) (reply *CreateLTSReply, err error) {
 	if err != nil {
 		return nil, xerrors.Errorf("get roster: %v", err)
 	}
+	info, _, err := s.getLtsInfo(&req.Proof)
+	if err != nil {
+		return nil, xerrors.Errorf("get info: %v", err)
+	}
 
 	// NOTE: the roster stored in ByzCoin must have myself.
 	tree := roster.GenerateNaryTreeWithRoot(len(roster.List), s.ServerIdentity())
@@ -305,11 +335,17 @@ func (s *Service) CreateLTS(req *CreateLTS) (reply *CreateLTSReply, err error) {
 		s.storage.Rosters[instID] = roster
 		s.storage.Replies[instID] = reply
 		s.storage.DKS[instID] = dks
+		if info.FollowRoster {
+			s.storage.Follow[instID] = reply.ByzCoinID
+		}
 		s.storage.Unlock()
 		err = s.save()
 		if err != nil {
 			return nil, xerrors.Errorf("save dkg state: %v", err)
 		}
+		if info.FollowRoster {
+			s.followRoster(reply.ByzCoinID)
+		}
 		log.Lvlf2("%v Created LTS with ID: %v, pk %v", s.ServerIdentity(), instID, reply.X)
 	case <-time.After(propagationTimeout):
 		return nil, xerrors.New("new-dkg didn't finish in time")
@@ -319,13 +355,18 @@ func (s *Service) CreateLTS(req *CreateLTS) (reply *CreateLTSReply, err error) {
 
 // ReshareLTS starts a request to reshare the LTS. The new roster which holds
 // the new secret shares must exist in the proof specified by the request.
-// All hosts must be online in this step.
+// All hosts must be online in this step. For an LTS that follows the ByzCoin
+// roster, the nodes call it on their own whenever the roster changes.
 func (s *Service) ReshareLTS(req *ReshareLTS) (*ReshareLTSReply, error) {
 	// Verify the request
 	roster, id, err := s.getLtsRoster(&req.Proof)
 	if err != nil {
 		return nil, xerrors.Errorf("get roster: %v", err)
 	}
+	info, _, err := s.getLtsInfo(&req.Proof)
+	if err != nil {
+		return nil, xerrors.Errorf("get info: %v", err)
+	}
 	if err := s.verifyProof(&req.Proof); err != nil {
 		return nil, xerrors.Errorf("verifying proof: %v", err)
 	}
@@ -433,11 +474,19 @@ func (s *Service) ReshareLTS(req *ReshareLTS) (*ReshareLTSReply, error) {
 		s.storage.Polys[id] = &pubPoly{s.Suite().Point().Base(), dks.Commits}
 		s.storage.Rosters[id] = roster
 		s.storage.DKS[id] = dks
+		if info.FollowRoster {
+			s.storage.Follow[id] = req.Proof.Latest.SkipChainID()
+		} else {
+			delete(s.storage.Follow, id)
+		}
 		s.storage.Unlock()
 		err = s.save()
 		if err != nil {
 			return nil, xerrors.Errorf("saving dkg state: %v", err)
 		}
+		if info.FollowRoster {
+			s.followRoster(req.Proof.Latest.SkipChainID())
+		}
 		if s.afterReshare != nil {
 			s.afterReshare()
 		}
@@ -506,7 +555,21 @@ func (s *Service) fetchGenesisBlock(scID skipchain.SkipBlockID, roster *onet.Ros
 	return sb, nil
 }
 
+// getLtsRoster returns the roster of the LTS instance in the proof. If the
+// LTS follows the ByzCoin roster, this is the roster of the latest block of
+// the proof.
 func (s *Service) getLtsRoster(proof *byzcoin.Proof) (*onet.Roster, byzcoin.InstanceID, error) {
+	info, id, err := s.getLtsInfo(proof)
+	if err != nil {
+		return nil, byzcoin.InstanceID{}, err
+	}
+	if info.FollowRoster {
+		return proof.Latest.Roster, id, nil
+	}
+	return &info.Roster, id, nil
+}
+
+func (s *Service) getLtsInfo(proof *byzcoin.Proof) (*LtsInstanceInfo, byzcoin.InstanceID, error) {
 	instanceID, buf, _, _, err := proof.KeyValue()
 	if err != nil {
 		return nil, byzcoin.InstanceID{},
@@ -519,7 +582,127 @@ func (s *Service) getLtsRoster(proof *byzcoin.Proof) (*onet.Roster, byzcoin.Inst
 		return nil, byzcoin.InstanceID{},
 			xerrors.Errorf("decoding roster: %v", err)
 	}
-	return &info.Roster, byzcoin.NewInstanceID(instanceID), nil
+	return &info, byzcoin.NewInstanceID(instanceID), nil
+}
+
+// followRoster watches the new blocks of the ByzCoin ledger, so that the LTSs
+// following its roster are reshared whenever the roster changes.
+func (s *Service) followRoster(bcID skipchain.SkipBlockID) {
+	s.followLock.Lock()
+	defer s.followLock.Unlock()
+	if s.following[string(bcID)] {
+		return
+	}
+	bcs, ok := s.Service(byzcoin.ServiceName).(*byzcoin.Service)
+	if !ok {
+		log.Error(s.ServerIdentity(), "cannot follow roster without ByzCoin service")
+		return
+	}
+	blocks, stop, err := bcs.StreamTransactions(&byzcoin.StreamingRequest{ID: bcID})
+	if err != nil {
+		log.Error(s.ServerIdentity(), "cannot follow roster:", err)
+		return
+	}
+	s.following[string(bcID)] = true
+
+	go func() {
+		// The channel is closed when the ByzCoin service stops.
+		for reply := range blocks {
+			s.checkRosterChange(bcID, reply.Block)
+		}
+		close(stop)
+		s.followLock.Lock()
+		delete(s.following, string(bcID))
+		s.followLock.Unlock()
+	}()
+}
+
+// checkRosterChange starts the resharing of the LTSs following the roster of
+// the ByzCoin ledger, if the block has a new roster. It must return quickly,
+// as ByzCoin waits for it before going on.
+func (s *Service) checkRosterChange(bcID skipchain.SkipBlockID, sb *skipchain.SkipBlock) {
+	var ids []byzcoin.InstanceID
+	s.storage.Lock()
+	for id, follow := range s.storage.Follow {
+		roster := s.storage.Rosters[id]
+		if follow.Equal(bcID) && roster != nil && !rostersEqual(roster, sb.Roster) {
+			ids = append(ids, id)
+		}
+	}
+	s.storage.Unlock()
+
+	s.followLock.Lock()
+	defer s.followLock.Unlock()
+	for _, id := range ids {
+		if !s.resharing[id] {
+			s.resharing[id] = true
+			go s.autoReshare(bcID, id)
+		}
+	}
+}
+
+// autoReshare reshares the LTS to the roster of the latest ByzCoin block.
+// Only the nodes in both the current and the new roster can start the
+// resharing. They try one after the other, in the order of the new roster,
+// so that an offline node doesn't prevent the resharing.
+func (s *Service) autoReshare(bcID skipchain.SkipBlockID, id byzcoin.InstanceID) {
+	defer func() {
+		s.followLock.Lock()
+		delete(s.resharing, id)
+		s.followLock.Unlock()
+	}()
+
+	done := func(roster *onet.Roster) bool {
+		s.storage.Lock()
+		defer s.storage.Unlock()
+		return rostersEqual(s.storage.Rosters[id], roster)
+	}
+	for round := 0; round < reshareRetries; round++ {
+		reply, err := s.Service(byzcoin.ServiceName).(*byzcoin.Service).GetProof(
+			&byzcoin.GetProof{
+				Version: byzcoin.CurrentVersion,
+				Key:     id.Slice(),
+				ID:      bcID,
+			})
+		if err != nil {
+			log.Error(s.ServerIdentity(), "couldn't get LTS proof:", err)
+			return
+		}
+		newRoster := reply.Proof.Latest.Roster
+		if done(newRoster) {
+			return
+		}
+
+		s.storage.Lock()
+		oldRoster := s.storage.Rosters[id]
+		s.storage.Unlock()
+		position, candidates := -1, 0
+		for _, si := range newRoster.List {
+			if i, _ := oldRoster.Search(si.ID); i >= 0 {
+				if si.Equal(s.ServerIdentity()) {
+					position = candidates
+				}
+				candidates++
+			}
+		}
+		if position < 0 {
+			return
+		}
+
+		time.Sleep(time.Duration(position) * reshareRetryInterval)
+		if done(newRoster) {
+			return
+		}
+		log.Lvlf2("%v Resharing LTS %v to the new ByzCoin roster",
+			s.ServerIdentity(), id)
+		_, err = s.ReshareLTS(&ReshareLTS{Proof: reply.Proof})
+		if err == nil {
+			return
+		}
+		log.Warn(s.ServerIdentity(), "couldn't reshare LTS:", err)
+		time.Sleep(time.Duration(candidates-position) * reshareRetryInterval)
+	}
+	log.Error(s.ServerIdentity(), "gave up resharing LTS", id)
 }
 
 // DecryptKey takes as an input a Read- and a Write-proof. Proofs contain
@@ -527,7 +710,10 @@ func (s *Service) getLtsRoster(proof *byzcoin.Proof) (*onet.Roster, byzcoin.Inst
 // stored in ByzCoin.
 // Using the Read and the Write-instance, this method verifies that the
 // requests match and then re-encrypts the secret to the public key given
-// in the Read-instance.
+// in the Read-instance. For a group read, the secret is re-encrypted to the
+// public key given in the request, which must be a current member of the
+// group darc. The latest version of the write instance is fetched from
+// ByzCoin, and the decryption is refused if it has been revoked.
 func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error) {
 	reply = &DecryptKeyReply{}
 	log.Lvl2(s.ServerIdentity(), "Re-encrypt the key to the public key of the reader")
@@ -565,8 +751,115 @@ func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error
 			err)
 	}
 
+	// The proofs given by the reader might be outdated, so the revocation
+	// and the group membership are checked against the latest state.
+	verificationData := &vData{
+		Proof: dkr.Read,
+	}
+	verificationData.Write, err = s.getLatestProof(&dkr.Write, read.Write)
+	if err != nil {
+		return nil, xerrors.Errorf("getting latest write proof: %v", err)
+	}
+	if err = checkNotRevoked(verificationData.Write); err != nil {
+		return nil, err
+	}
+	if err = checkTimeLock(verificationData.Write); err != nil {
+		return nil, err
+	}
+	xc := read.Xc
+	if read.Group != nil {
+		if dkr.Xc == nil {
+			return nil, xerrors.New("group read needs the public key of a member")
+		}
+		if err = s.checkGroupMember(&dkr.Write, read.Group, dkr.Xc); err != nil {
+			return nil, err
+		}
+		xc = dkr.Xc
+	}
+
+	reply, err = s.reencrypt(id, roster, &write, xc, verificationData)
+	if err != nil {
+		return nil, err
+	}
+	log.Lvl3("Successfully reencrypted the key")
+	return
+}
+
+// ReleaseKey publishes the secret of a write that is released to everyone,
+// once its time-lock is over. The LTS re-encrypts the secret to an ephemeral
+// key, recovers it, and stores it in the write instance.
+func (s *Service) ReleaseKey(rk *ReleaseKey) (*ReleaseKeyReply, error) {
+	var write Write
+	if err := rk.Write.VerifyAndDecode(cothority.Suite, ContractWriteID, &write); err != nil {
+		return nil, xerrors.Errorf("didn't get a write instance: %v", err)
+	}
+	if err := s.verifyProof(&rk.Write); err != nil {
+		return nil, xerrors.Errorf(
+			"write proof cannot be verified to come from scID: %v", err)
+	}
+	s.storage.Lock()
+	id := write.LTSID
+	roster := s.storage.Rosters[id]
+	s.storage.Unlock()
+	if roster == nil {
+		return nil,
+			xerrors.Errorf("don't know the LTSID '%v' stored in write", id)
+	}
+
+	writeID := byzcoin.NewInstanceID(rk.Write.InclusionProof.Key())
+	latest, err := s.getLatestProof(&rk.Write, writeID)
+	if err != nil {
+		return nil, xerrors.Errorf("getting latest write proof: %v", err)
+	}
+	if err = latest.VerifyAndDecode(cothority.Suite, ContractWriteID, &write); err != nil {
+		return nil, xerrors.Errorf("didn't get a write instance: %v", err)
+	}
+	if write.Released != nil {
+		return &ReleaseKeyReply{Key: write.Released}, nil
+	}
+	if err = checkReleasable(latest); err != nil {
+		return nil, err
+	}
+
+	kp := key.NewKeyPair(cothority.Suite)
+	dkr, err := s.reencrypt(id, roster, &write, kp.Public,
+		&vData{Write: latest, Release: true})
+	if err != nil {
+		return nil, err
+	}
+	k, err := dkr.RecoverKey(kp.Private)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't recover the key: %v", err)
+	}
+
+	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion,
+		byzcoin.Instruction{
+			InstanceID: writeID,
+			Invoke: &byzcoin.Invoke{
+				ContractID: ContractWriteID,
+				Command:    "release",
+				Args:       byzcoin.Arguments{{Name: "key", Value: k}},
+			},
+		},
+	)
+	cl := byzcoin.NewClient(latest.Latest.SkipChainID(), *latest.Latest.Roster)
+	_, err = cl.AddTransactionAndWait(ctx, 10)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't store the released key: %v", err)
+	}
+	log.Lvlf2("%v released the key of %x", s.ServerIdentity(), writeID.Slice())
+	return &ReleaseKeyReply{Key: k}, nil
+}
+
+// reencrypt runs the ocs-protocol to re-encrypt the secret of the write to
+// the public key xc. The other nodes of the LTS verify the request using the
+// verification data.
+func (s *Service) reencrypt(id byzcoin.InstanceID, roster *onet.Roster,
+	write *Write, xc kyber.Point, verificationData *vData) (*DecryptKeyReply, error) {
+	reply := &DecryptKeyReply{}
+
 	// Start ocs-protocol to re-encrypt the file's symmetric key under the
-	// reader's public key.
+	// given public key.
 	nodes := len(roster.List)
 	threshold := nodes - (nodes-1)/3
 	tree := roster.GenerateNaryTreeWithRoot(nodes, s.ServerIdentity())
@@ -576,15 +869,11 @@ func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error
 	}
 	ocsProto := pi.(*protocol.OCS)
 	ocsProto.U = write.U
-	verificationData := &vData{
-		Proof: dkr.Read,
-	}
-	ocsProto.Xc = read.Xc
+	ocsProto.Xc = xc
 	log.Lvlf2("%v Public key is: %s", s.ServerIdentity(), ocsProto.Xc)
 	ocsProto.VerificationData, err = protobuf.Encode(verificationData)
 	if err != nil {
-		return nil,
-			xerrors.Errorf("couldn't marshal verification data: %v", err)
+		return nil, xerrors.Errorf("couldn't marshal verification data: %v", err)
 	}
 
 	// Make sure everything used from the s.Storage structure is copied, so
@@ -603,8 +892,7 @@ func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error
 	log.Lvl3("Starting reencryption protocol")
 	err = ocsProto.SetConfig(&onet.GenericConfig{Data: id.Slice()})
 	if err != nil {
-		return nil,
-			xerrors.Errorf("failed to set config for ocs-protocol: %v", err)
+		return nil, xerrors.Errorf("failed to set config for ocs-protocol: %v", err)
 	}
 	err = ocsProto.Start()
 	if err != nil {
@@ -620,8 +908,108 @@ func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error
 		return nil, xerrors.Errorf("failed to recover commit: %v", err)
 	}
 	reply.C = write.C
-	log.Lvl3("Successfully reencrypted the key")
-	return
+	return reply, nil
+}
+
+// getLatestProof asks the ByzCoin ledger of the given proof for a proof of
+// the instance in its latest block, and verifies it.
+func (s *Service) getLatestProof(proof *byzcoin.Proof, id byzcoin.InstanceID) (*byzcoin.Proof, error) {
+	cl := byzcoin.NewClient(proof.Latest.SkipChainID(), *proof.Latest.Roster)
+	reply, err := cl.GetProof(id.Slice())
+	if err != nil {
+		return nil, xerrors.Errorf("getting proof: %v", err)
+	}
+	if !reply.Proof.InclusionProof.Match(id.Slice()) {
+		return nil, xerrors.Errorf("instance %x doesn't exist", id.Slice())
+	}
+	if err = s.verifyProof(&reply.Proof); err != nil {
+		return nil, xerrors.Errorf("verifying proof: %v", err)
+	}
+	return &reply.Proof, nil
+}
+
+// checkNotRevoked returns an error if the write instance in the proof has
+// been revoked.
+func checkNotRevoked(proof *byzcoin.Proof) error {
+	var write Write
+	if err := proof.VerifyAndDecode(cothority.Suite, ContractWriteID, &write); err != nil {
+		return xerrors.Errorf("didn't get a write instance: %v", err)
+	}
+	if write.Revoked {
+		return xerrors.New("the write instance has been revoked")
+	}
+	return nil
+}
+
+// checkReleasable returns an error if the write instance in the proof can
+// not be released to everyone.
+func checkReleasable(proof *byzcoin.Proof) error {
+	if err := checkNotRevoked(proof); err != nil {
+		return err
+	}
+	var write Write
+	if err := proof.VerifyAndDecode(cothority.Suite, ContractWriteID, &write); err != nil {
+		return xerrors.Errorf("didn't get a write instance: %v", err)
+	}
+	if write.ReleaseAfter == nil || write.ReleaseAfter.KeyHash == nil {
+		return xerrors.New("the write instance is not released to everyone")
+	}
+	return checkTimeLock(proof)
+}
+
+// checkGroupMember returns an error if xc cannot sign for the latest version
+// of the group darc on the ledger of the proof. The darcs the group delegates
+// to are fetched from the ledger, too.
+func (s *Service) checkGroupMember(proof *byzcoin.Proof, group darc.ID, xc kyber.Point) error {
+	d, err := s.getLatestDarc(proof, group)
+	if err != nil {
+		return xerrors.Errorf("getting group darc: %v", err)
+	}
+	getDarc := func(id string, latest bool) *darc.Darc {
+		if !latest {
+			return nil
+		}
+		base, err := hex.DecodeString(strings.TrimPrefix(id, "darc:"))
+		if err != nil {
+			return nil
+		}
+		d, err := s.getLatestDarc(proof, base)
+		if err != nil {
+			log.Lvlf2("%v couldn't get darc %s: %v", s.ServerIdentity(), id, err)
+			return nil
+		}
+		return d
+	}
+	member := darc.NewIdentityEd25519(xc).String()
+	err = darc.EvalExpr(d.Rules.GetSignExpr(), getDarc, member)
+	if err != nil {
+		return xerrors.Errorf("public key is not a member of the group: %v", err)
+	}
+	return nil
+}
+
+// getLatestDarc returns the latest version of the darc on the ledger of the
+// proof.
+func (s *Service) getLatestDarc(proof *byzcoin.Proof, base darc.ID) (*darc.Darc, error) {
+	latest, err := s.getLatestProof(proof, byzcoin.NewInstanceID(base))
+	if err != nil {
+		return nil, err
+	}
+	_, buf, cid, _, err := latest.KeyValue()
+	if err != nil {
+		return nil, xerrors.Errorf("getting darc from proof: %v", err)
+	}
+	if cid != byzcoin.ContractDarcID {
+		return nil, xerrors.New("instance is not a darc")
+	}
+	d, err := darc.NewFromProtobuf(buf)
+	if err != nil {
+		return nil, xerrors.Errorf("decoding darc: %v", err)
+	}
+	if !d.GetBaseID().Equal(base) {
+		return nil, xerrors.New("proof doesn't point to the darc")
+	}
+	return d, nil
 }
 
 // GetLTSReply returns the CreateLTSReply message of a previous LTS.
@@ -659,11 +1047,10 @@ func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfi
 		if err := s.verifyProof(&cfg.Proof); err != nil {
 			return nil, xerrors.Errorf("verifying proof: %v", err)
 		}
-		inst, _, _, _, err := cfg.KeyValue()
+		info, instID, err := s.getLtsInfo(&cfg.Proof)
 		if err != nil {
-			return nil, xerrors.Errorf("getting key value from proof: %v", err)
+			return nil, xerrors.Errorf("getting LTS info from proof: %v", err)
 		}
-		instID := byzcoin.NewInstanceID(inst)
 
 		pi, err := dkgprotocol.NewSetup(tn)
 		if err != nil {
@@ -690,11 +1077,17 @@ func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfi
 			s.storage.DKS[id] = dks
 			s.storage.Replies[id] = reply
 			s.storage.Rosters[id] = tn.Roster()
+			if info.FollowRoster {
+				s.storage.Follow[id] = bcID
+			}
 			s.storage.Unlock()
 			err = s.save()
 			if err != nil {
 				log.Error(err)
 			}
+			if info.FollowRoster {
+				s.followRoster(bcID)
+			}
 		}(cfg.Latest.SkipChainID(), instID)
 		return pi, nil
 	case calypsoReshareProto:
@@ -708,6 +1101,14 @@ func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfi
 		}
 
 		roster, id, err := s.getLtsRoster(&cfg.Proof)
+		if err != nil {
+			return nil, xerrors.Errorf("getting roster: %v", err)
+		}
+		info, _, err := s.getLtsInfo(&cfg.Proof)
+		if err != nil {
+			return nil, xerrors.Errorf("getting LTS info: %v", err)
+		}
+		bcID := cfg.Proof.Latest.SkipChainID()
 
 		// Set up the protocol
 		pi, err := dkgprotocol.NewSetup(tn)
@@ -785,11 +1186,19 @@ func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfi
 			s.storage.Shared[id] = shared
 			s.storage.DKS[id] = dks
 			s.storage.Rosters[id] = roster
+			if info.FollowRoster {
+				s.storage.Follow[id] = bcID
+			} else {
+				delete(s.storage.Follow, id)
+			}
 			s.storage.Unlock()
 			err = s.save()
 			if err != nil {
 				log.Fatal(err)
 			}
+			if info.FollowRoster {
+				s.followRoster(bcID)
+			}
 			if s.afterReshare != nil {
 				s.afterReshare()
 			}
@@ -825,6 +1234,24 @@ func pointInList(p1 kyber.Point, l []kyber.Point) bool {
 	return false
 }
 
+// latestWrite verifies the proof of the write instance sent by the leader,
+// and fetches the latest proof of the write instance from the ledger, so that
+// a revocation or a change of the group is not hidden by an old proof.
+func (s *Service) latestWrite(proof *byzcoin.Proof) (*byzcoin.Proof, error) {
+	if proof == nil {
+		return nil, xerrors.New("missing proof of the write instance")
+	}
+	if err := s.verifyProof(proof); err != nil {
+		return nil, xerrors.Errorf("verifying write proof: %v", err)
+	}
+	latest, err := s.getLatestProof(proof,
+		byzcoin.NewInstanceID(proof.InclusionProof.Key()))
+	if err != nil {
+		return nil, xerrors.Errorf("getting latest write proof: %v", err)
+	}
+	return latest, nil
+}
+
 // verifyReencryption checks that the read and the write instances match.
 func (s *Service) verifyReencryption(rc *protocol.Reencrypt) bool {
 	err := func() error {
@@ -833,6 +1260,26 @@ func (s *Service) verifyReencryption(rc *protocol.Reencrypt) bool {
 		if err != nil {
 			return xerrors.Errorf("decoding verification data: %v", err)
 		}
+		if verificationData.Release {
+			// The secret is re-encrypted to an ephemeral key of the
+			// leader, which publishes it.
+			if verificationData.Write == nil {
+				return xerrors.New("missing proof of the write instance")
+			}
+			latest, err := s.latestWrite(verificationData.Write)
+			if err != nil {
+				return err
+			}
+			var w Write
+			err = latest.VerifyAndDecode(cothority.Suite, ContractWriteID, &w)
+			if err != nil {
+				return xerrors.Errorf("didn't get a write instance: %v", err)
+			}
+			if !w.U.Equal(rc.U) {
+				return xerrors.New("wrong write instance")
+			}
+			return checkReleasable(latest)
+		}
 		_, v0, contractID, _, err := verificationData.Proof.KeyValue()
 		if err != nil {
 			return xerrors.Errorf("proof cannot return values: %v", err)
@@ -848,6 +1295,23 @@ func (s *Service) verifyReencryption(rc *protocol.Reencrypt) bool {
 		if verificationData.Ephemeral != nil {
 			return xerrors.New("ephemeral keys not supported yet")
 		}
+		if verificationData.Write == nil ||
+			!r.Write.Equal(byzcoin.NewInstanceID(verificationData.Write.InclusionProof.Key())) {
+			return xerrors.New("missing proof of the write instance")
+		}
+		latest, err := s.latestWrite(verificationData.Write)
+		if err != nil {
+			return err
+		}
+		if err = checkNotRevoked(latest); err != nil {
+			return err
+		}
+		if err = checkTimeLock(latest); err != nil {
+			return err
+		}
+		if r.Group != nil {
+			return s.checkGroupMember(latest, r.Group, rc.Xc)
+		}
 		if !r.Xc.Equal(rc.Xc) {
 			return xerrors.New("wrong reader")
 		}
@@ -867,9 +1331,11 @@ func newService(c *onet.Context) (onet.Service, error) {
 	s := &Service{
 		ServiceProcessor: onet.NewServiceProcessor(c),
 		genesisBlocks:    make(map[string]*skipchain.SkipBlock),
+		following:        make(map[string]bool),
+		resharing:        make(map[byzcoin.InstanceID]bool),
 	}
 	if err := s.RegisterHandlers(s.CreateLTS, s.ReshareLTS, s.DecryptKey,
-		s.GetLTSReply, s.Authorise, s.Authorize, s.updateValidPeers); err != nil {
+		s.ReleaseKey, s.GetLTSReply, s.Authorise, s.Authorize, s.updateValidPeers); err != nil {
 		return nil, xerrors.New("couldn't register messages")
 	}
 	if err := s.tryLoad(); err != nil {
@@ -882,5 +1348,9 @@ func newService(c *onet.Context) (onet.Service, error) {
 		s.SetValidPeers(s.NewPeerSetID(ltsID[:]), roster.List)
 	}
 
+	for _, bcID := range s.storage.Follow {
+		s.followRoster(bcID)
+	}
+
 	return s, nil
 }
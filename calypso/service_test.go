@@ -11,6 +11,7 @@ import (
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/byzcoin"
 	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/darc/expression"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/share"
 	"go.dedis.ch/kyber/v3/util/key"
@@ -54,7 +55,7 @@ func TestService_ReshareLTS_Different(t *testing.T) {
 	// The current DKG is on List[0:nodes], and this new roster will
 	// be on List[nodes:], thus entirely disjoint.
 	otherRoster := onet.NewRoster(s.allRoster.List[nodes:])
-	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{*otherRoster})
+	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *otherRoster})
 	require.NoError(t, err)
 
 	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion,
@@ -92,7 +93,7 @@ func TestService_ReshareLTS_Same(t *testing.T) {
 			require.NotNil(t, s.ltsReply.X)
 			sec1 := s.reconstructKey(t)
 
-			ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{*s.ltsRoster})
+			ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *s.ltsRoster})
 			require.NoError(t, err)
 
 			ctx, err := s.cl.CreateTransaction(byzcoin.Instruction{
@@ -160,7 +161,7 @@ func TestService_ReshareLTS_OneMore(t *testing.T) {
 			// Create a new roster that has one more node than
 			// before
 			s.ltsRoster = onet.NewRoster(s.allRoster.List[:nodes+1])
-			ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{*s.ltsRoster})
+			ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *s.ltsRoster})
 			require.NoError(t, err)
 
 			ctx, err := s.cl.CreateTransaction(byzcoin.Instruction{
@@ -210,6 +211,64 @@ func TestService_ReshareLTS_OneMore(t *testing.T) {
 	}
 }
 
+// TestService_ReshareLTS_FollowRoster adds a node to the ByzCoin roster and
+// makes sure that the LTS is reshared to it without calling ReshareLTS.
+func TestService_ReshareLTS_FollowRoster(t *testing.T) {
+	defer func(i time.Duration) { reshareRetryInterval = i }(reshareRetryInterval)
+	reshareRetryInterval = time.Second
+
+	nodes := 4
+	s := newTSWithLTS(t, nodes, 1, true)
+	defer s.closeAll(t)
+	sec1 := s.reconstructKey(t)
+
+	// The roster of a following LTS must be the ByzCoin roster.
+	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{
+		Roster: *onet.NewRoster(s.allRoster.List[1:]), FollowRoster: true})
+	require.NoError(t, err)
+	instr := byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(s.gDarc.GetBaseID()),
+		Spawn: &byzcoin.Spawn{
+			ContractID: ContractLongTermSecretID,
+			Args: byzcoin.Arguments{{Name: "lts_instance_info",
+				Value: ltsInstInfoBuf}},
+		},
+		SignerCounter: []uint64{s.nextCounter(t)},
+	}
+	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion, instr)
+	require.NoError(t, ctx.FillSignersAndSignWith(s.signer))
+	_, err = s.cl.AddTransactionAndWait(ctx, 10)
+	require.Error(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(nodes + 1)
+	s.afterReshare(func() { wg.Done() })
+
+	config, err := s.cl.GetChainConfig()
+	require.NoError(t, err)
+	s.ltsRoster = onet.NewRoster(s.allRoster.List[:nodes+1])
+	config.Roster = *s.ltsRoster
+	configBuf, err := protobuf.Encode(config)
+	require.NoError(t, err)
+	s.addInstruction(t, byzcoin.Instruction{
+		InstanceID: byzcoin.ConfigInstanceID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: byzcoin.ContractConfigID,
+			Command:    "update_config",
+			Args:       byzcoin.Arguments{{Name: "config", Value: configBuf}},
+		},
+	})
+
+	wg.Wait()
+	require.True(t, s.reconstructKey(t).Equal(sec1))
+	for _, srv := range s.services {
+		srv.storage.Lock()
+		require.True(t, rostersEqual(s.ltsRoster,
+			srv.storage.Rosters[s.ltsReply.InstanceID]))
+		srv.storage.Unlock()
+	}
+}
+
 // TestContract_Write creates a write request and check that it gets stored.
 func TestContract_Write(t *testing.T) {
 	s := newTS(t, 5)
@@ -323,6 +382,165 @@ func TestService_DecryptEphemeralKey(t *testing.T) {
 	require.Equal(t, key1, keyCopy1)
 }
 
+// TestService_DecryptKey_Revoked makes sure that no new decryption is
+// possible after the write instance has been revoked.
+func TestService_DecryptKey_Revoked(t *testing.T) {
+	s := newTS(t, 5)
+	defer s.closeAll(t)
+
+	prWr := s.addWriteAndWait(t, []byte("secret key"))
+	prRe := s.addReadAndWait(t, prWr, s.signer.Ed25519.Point)
+	_, err := s.services[0].DecryptKey(&DecryptKey{Read: *prRe, Write: *prWr})
+	require.NoError(t, err)
+
+	cl := NewClient(s.cl)
+	writeID := byzcoin.NewInstanceID(prWr.InclusionProof.Key())
+	_, err = cl.RevokeWrite(writeID, s.signer, s.nextCounter(t), 10)
+	require.NoError(t, err)
+
+	// The proofs from before the revocation are not accepted anymore.
+	_, err = s.services[0].DecryptKey(&DecryptKey{Read: *prRe, Write: *prWr})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "revoked")
+	_, err = cl.AddRead(prWr, s.signer, s.nextCounter(t), 10)
+	require.Error(t, err)
+}
+
+// TestService_DecryptKey_Group creates a read for a group darc and makes sure
+// that only the current members of the group can get the secret.
+func TestService_DecryptKey_TimeLock(t *testing.T) {
+	s := newTS(t, 5)
+	defer s.closeAll(t)
+	cl := NewClient(s.cl)
+
+	latestIndex := func() int {
+		reply, err := s.cl.GetProof(byzcoin.ConfigInstanceID.Slice())
+		require.NoError(t, err)
+		return reply.Proof.Latest.Index
+	}
+	release := latestIndex() + 4
+	secret := []byte("secret key")
+	write := NewWrite(cothority.Suite, s.ltsReply.InstanceID,
+		s.gDarc.GetBaseID(), s.ltsReply.X, secret)
+	write.ReleaseAfter = NewReleaseAfter(release, 0, secret, true)
+	wr, err := cl.AddWrite(write, s.signer, s.nextCounter(t), *s.gDarc, 10)
+	require.NoError(t, err)
+	prWr := s.waitInstID(t, wr.InstanceID)
+	prRe := s.addReadAndWait(t, prWr, s.signer.Ed25519.Point)
+
+	// Neither readers nor everyone get the secret before the release.
+	_, err = s.services[0].DecryptKey(&DecryptKey{Read: *prRe, Write: *prWr})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "time-locked")
+	_, err = cl.ReleaseKey(prWr)
+	require.Error(t, err)
+
+	for latestIndex() < release {
+		_, err = cl.AddRead(prWr, s.signer, s.nextCounter(t), 10)
+		require.NoError(t, err)
+	}
+
+	// The old proofs are enough, as the LTS checks the latest block.
+	dk, err := s.services[0].DecryptKey(&DecryptKey{Read: *prRe, Write: *prWr})
+	require.NoError(t, err)
+	keyCopy, err := dk.RecoverKey(s.signer.Ed25519.Secret)
+	require.NoError(t, err)
+	require.Equal(t, secret, keyCopy)
+
+	rk, err := cl.ReleaseKey(prWr)
+	require.NoError(t, err)
+	require.Equal(t, secret, rk.Key)
+	reply, err := s.cl.GetProof(wr.InstanceID.Slice())
+	require.NoError(t, err)
+	var released Write
+	require.NoError(t, reply.Proof.VerifyAndDecode(cothority.Suite,
+		ContractWriteID, &released))
+	require.Equal(t, secret, released.Released)
+
+	// Asking again returns the released secret.
+	rk, err = cl.ReleaseKey(prWr)
+	require.NoError(t, err)
+	require.Equal(t, secret, rk.Key)
+}
+
+func TestService_DecryptKey_Group(t *testing.T) {
+	s := newTS(t, 5)
+	defer s.closeAll(t)
+
+	memberA := darc.NewSignerEd25519(nil, nil)
+	memberB := darc.NewSignerEd25519(nil, nil)
+	memberC := darc.NewSignerEd25519(nil, nil)
+	outsider := key.NewKeyPair(cothority.Suite)
+	// memberC is in a team darc the group delegates to.
+	team := darc.NewDarc(darc.InitRules([]darc.Identity{s.signer.Identity()},
+		[]darc.Identity{memberC.Identity()}), []byte("team"))
+	group := darc.NewDarc(darc.InitRules([]darc.Identity{s.signer.Identity()},
+		[]darc.Identity{memberA.Identity(), memberB.Identity(),
+			darc.NewIdentityDarc(team.GetBaseID())}),
+		[]byte("readers"))
+	for _, d := range []*darc.Darc{team, group} {
+		buf, err := d.ToProto()
+		require.NoError(t, err)
+		s.addInstruction(t, byzcoin.Instruction{
+			InstanceID: byzcoin.NewInstanceID(s.gDarc.GetBaseID()),
+			Spawn: &byzcoin.Spawn{
+				ContractID: byzcoin.ContractDarcID,
+				Args:       byzcoin.Arguments{{Name: "darc", Value: buf}},
+			},
+		})
+	}
+
+	key1 := []byte("secret key 1")
+	prWr := s.addWriteAndWait(t, key1)
+	reply, err := NewClient(s.cl).AddGroupRead(prWr, group.GetBaseID(),
+		s.signer, s.nextCounter(t), 10)
+	require.NoError(t, err)
+	prRe := s.waitInstID(t, reply.InstanceID)
+
+	decrypt := func(xc kyber.Point) (*DecryptKeyReply, error) {
+		return s.services[0].DecryptKey(&DecryptKey{Read: *prRe,
+			Write: *prWr, Xc: xc})
+	}
+	_, err = decrypt(nil)
+	require.Error(t, err)
+	_, err = decrypt(outsider.Public)
+	require.Error(t, err)
+	dk, err := decrypt(memberA.Ed25519.Point)
+	require.NoError(t, err)
+	keyCopy, err := dk.RecoverKey(memberA.Ed25519.Secret)
+	require.NoError(t, err)
+	require.Equal(t, key1, keyCopy)
+	dk, err = decrypt(memberC.Ed25519.Point)
+	require.NoError(t, err)
+	keyCopy, err = dk.RecoverKey(memberC.Ed25519.Secret)
+	require.NoError(t, err)
+	require.Equal(t, key1, keyCopy)
+
+	// Remove memberA from the group.
+	group2 := group.Copy()
+	require.NoError(t, group2.EvolveFrom(group))
+	require.NoError(t, group2.Rules.UpdateSign(
+		expression.InitOrExpr(memberB.Identity().String())))
+	group2Buf, err := group2.ToProto()
+	require.NoError(t, err)
+	s.addInstruction(t, byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(group.GetBaseID()),
+		Invoke: &byzcoin.Invoke{
+			ContractID: byzcoin.ContractDarcID,
+			Command:    "evolve",
+			Args:       byzcoin.Arguments{{Name: "darc", Value: group2Buf}},
+		},
+	})
+
+	_, err = decrypt(memberA.Ed25519.Point)
+	require.Error(t, err)
+	dk, err = decrypt(memberB.Ed25519.Point)
+	require.NoError(t, err)
+	keyCopy, err = dk.RecoverKey(memberB.Ed25519.Secret)
+	require.NoError(t, err)
+	require.Equal(t, key1, keyCopy)
+}
+
 type ts struct {
 	local      *onet.LocalTest
 	servers    []*onet.Server
@@ -370,6 +588,22 @@ func (s *ts) addReadAndWait(t *testing.T, write *byzcoin.Proof, Xc kyber.Point)
 	return s.waitInstID(t, instID)
 }
 
+func (s *ts) nextCounter(t *testing.T) uint64 {
+	ctr, err := s.cl.GetSignerCounters(s.signer.Identity().String())
+	require.NoError(t, err)
+	return ctr.Counters[0] + 1
+}
+
+// addInstruction signs the instruction with the signer and waits for it to be
+// included.
+func (s *ts) addInstruction(t *testing.T, instr byzcoin.Instruction) {
+	instr.SignerCounter = []uint64{s.nextCounter(t)}
+	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion, instr)
+	require.NoError(t, ctx.FillSignersAndSignWith(s.signer))
+	_, err := s.cl.AddTransactionAndWait(ctx, 10)
+	require.NoError(t, err)
+}
+
 func newTS(t *testing.T, nodes int) ts {
 	return newTSWithExtras(t, nodes, 0)
 }
@@ -377,6 +611,12 @@ func newTS(t *testing.T, nodes int) ts {
 // newTSWithExtras initially the byzRoster and ltsRoster are the same, the extras are
 // there so that we can change the ltsRoster later to be something different.
 func newTSWithExtras(t *testing.T, nodes int, extras int) ts {
+	return newTSWithLTS(t, nodes, extras, false)
+}
+
+// newTSWithLTS is like newTSWithExtras, but the LTS follows the ByzCoin roster
+// if follow is true.
+func newTSWithLTS(t *testing.T, nodes int, extras int, follow bool) ts {
 	allowInsecureAdmin = true
 	s := ts{}
 	s.local = onet.NewLocalTestT(cothority.Suite, t)
@@ -395,7 +635,8 @@ func newTSWithExtras(t *testing.T, nodes int, extras int) ts {
 	s.createGenesis(t)
 
 	// Create LTS instance
-	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{*s.ltsRoster})
+	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *s.ltsRoster,
+		FollowRoster: follow})
 	require.NoError(t, err)
 	inst := byzcoin.Instruction{
 		InstanceID: byzcoin.NewInstanceID(s.gDarc.GetBaseID()),
@@ -442,7 +683,8 @@ func (s *ts) createGenesis(t *testing.T) {
 		[]string{"spawn:" + ContractWriteID,
 			"spawn:" + ContractReadID,
 			"spawn:" + ContractLongTermSecretID,
-			"invoke:" + ContractLongTermSecretID + ".reshare"},
+			"invoke:" + ContractLongTermSecretID + ".reshare",
+			"invoke:" + ContractWriteID + ".revoke"},
 		s.signer.Identity())
 	require.NoError(t, err)
 	s.gDarc = &s.genesisMsg.GenesisDarc
@@ -0,0 +1,92 @@
+package calypso
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// NewReleaseAfter returns a time-lock for the secret. If public is true, the
+// hash of the secret is added, so that anybody can ask the LTS to release the
+// secret to everyone once the time-lock is over.
+func NewReleaseAfter(blockIndex int, timestamp int64, secret []byte, public bool) *ReleaseAfter {
+	ra := &ReleaseAfter{BlockIndex: blockIndex, Timestamp: timestamp}
+	if public {
+		h := sha256.Sum256(secret)
+		ra.KeyHash = h[:]
+	}
+	return ra
+}
+
+// verify checks that the time-lock is valid.
+func (ra *ReleaseAfter) verify() error {
+	if ra.BlockIndex < 0 || ra.Timestamp < 0 {
+		return xerrors.New("negative block index or timestamp")
+	}
+	if ra.BlockIndex == 0 && ra.Timestamp == 0 {
+		return xerrors.New("need a block index or a timestamp")
+	}
+	if ra.KeyHash != nil && len(ra.KeyHash) != sha256.Size {
+		return xerrors.New("wrong length of the key hash")
+	}
+	return nil
+}
+
+// over returns true if a block with the given index and timestamp releases
+// the secret.
+func (ra *ReleaseAfter) over(index int, timestamp int64) bool {
+	return index >= ra.BlockIndex && timestamp >= ra.Timestamp
+}
+
+// release stores the key in the write, if the write is released to everyone,
+// the time-lock is over, and the key matches the hash of the time-lock.
+func (w *Write) release(rst byzcoin.ReadOnlyStateTrie, key []byte) error {
+	if w.ReleaseAfter == nil || w.ReleaseAfter.KeyHash == nil {
+		return xerrors.New("the write-instance is not released to everyone")
+	}
+	if w.Revoked {
+		return xerrors.New("the write-instance has been revoked")
+	}
+	if w.Released != nil {
+		return xerrors.New("the secret has already been released")
+	}
+	tr, ok := rst.(byzcoin.TimeReader)
+	if !ok {
+		return xerrors.New("internal error: cannot read the block time")
+	}
+	if !w.ReleaseAfter.over(rst.GetIndex(), tr.GetCurrentBlockTimestamp()) {
+		return xerrors.New("the time-lock is not over yet")
+	}
+	h := sha256.Sum256(key)
+	if !bytes.Equal(h[:], w.ReleaseAfter.KeyHash) {
+		return xerrors.New("the key doesn't match the hash of the time-lock")
+	}
+	w.Released = key
+	return nil
+}
+
+// checkTimeLock returns an error if the write in the proof is time-locked and
+// the latest block of the proof doesn't release it yet.
+func checkTimeLock(proof *byzcoin.Proof) error {
+	var write Write
+	if err := proof.VerifyAndDecode(cothority.Suite, ContractWriteID, &write); err != nil {
+		return xerrors.Errorf("didn't get a write instance: %v", err)
+	}
+	if write.ReleaseAfter == nil {
+		return nil
+	}
+	var header byzcoin.DataHeader
+	if err := protobuf.Decode(proof.Latest.Data, &header); err != nil {
+		return xerrors.Errorf("couldn't decode the block header: %v", err)
+	}
+	if !write.ReleaseAfter.over(proof.Latest.Index, header.Timestamp) {
+		return xerrors.Errorf("the secret is time-locked until block %d and "+
+			"timestamp %d", write.ReleaseAfter.BlockIndex,
+			write.ReleaseAfter.Timestamp)
+	}
+	return nil
+}
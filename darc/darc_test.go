@@ -446,6 +446,29 @@ func TestDarc_DelegationDiamond(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// TestDarc_Threshold checks that a threshold expression mixing keys and a
+// delegated darc evaluates correctly, with and without signatures.
+func TestDarc_Threshold(t *testing.T) {
+	td := createDarc(1, "threshold")
+	require.NoError(t, td.darc.Rules.UpdateSign([]byte(td.ids[0].String())))
+	getDarc := DarcsToGetDarcs([]*Darc{td.darc})
+
+	a := createIdentity()
+	b := createIdentity()
+	expr := expression.InitThresholdExpr(2, a.String(), b.String(),
+		td.darc.GetIdentityString())
+
+	require.Error(t, EvalExpr(expr, getDarc, a.String()))
+	require.NoError(t, EvalExpr(expr, getDarc, a.String(), b.String()))
+	require.NoError(t, EvalExpr(expr, getDarc, b.String(), td.ids[0].String()))
+	require.NoError(t, EvalExprDarc(expr, getDarc, true, a.String(),
+		td.darc.GetIdentityString()))
+
+	require.Error(t, EvalExprWithSigs(expr, getDarc, Signature{Signer: b}))
+	require.NoError(t, EvalExprWithSigs(expr, getDarc, Signature{Signer: a},
+		Signature{Signer: td.ids[0]}))
+}
+
 func TestDarc_X509(t *testing.T) {
 	// TODO
 }
@@ -5,7 +5,8 @@ the syntax we use is from: https://en.wikipedia.org/wiki/Extended_Backus%E2%80%9
 
 	expr = term, [ '&', term ]*
 	term = factor, [ '|', factor ]*
-	factor = '(', expr, ')' | id | openid
+	factor = '(', expr, ')' | id | openid | thexpr
+	thexpr = '[', expr, [ ',', expr ]*, ']', '/', digit, [ digit ]*
 	identity = (darc|ed25519|x509ec):[0-9a-fA-F]+
 	proxy = proxy:[0-9a-fA-F]+:[^ \n\t]*
 	evm_identity = evm_contract:[0-9a-fA-F]+:0x[0-9a-fA-F]+
@@ -17,6 +18,7 @@ Examples:
 	(ed25519:a & x509ec:b) | (darc:c & ed25519:d)
 	proxy:deadbeef:me@example.com // where deadbeef is a ed25519 public key
 	attr:time_interval:before=5pm&after=9am & ed25519:deadbeef
+	[ed25519:a, ed25519:b, darc:c]/2 // at least two of the three
 
 In the simplest case, the evaluation of an expression is performed against a
 set of valid ids.  Suppose we have the expression (a:a & b:b) | (c:c & d:d),
@@ -25,15 +27,18 @@ true.  If the set of valid ids is [a:a, c:c], then the expression will evaluate
 to false. However, the user is able to provide a ValueCheckFn to customise how
 the expressions are evaluated.
 
-EXTENSION - NOT YET IMPLEMENTED:
-To support threshold signatures, we extend the syntax to include the following.
-	thexpr = '[', id, [ ',', id ]*, ']', '/', digit
+A threshold expression [e1, e2, ..., en]/k evaluates to true if at least k of
+the n sub-expressions evaluate to true. The threshold k must be between 1 and
+n, otherwise the expression does not parse. As proxy and attr ids accept any
+non-whitespace character in their last part, they must be followed by a space
+when used inside a threshold expression, e.g. [proxy:aa:me@example.com , ed25519:b]/1.
 */
 package expression
 
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	parsec "github.com/prataprc/goparsec"
@@ -62,6 +67,11 @@ func InitParser(fn ValueCheckFn) parsec.Parser {
 	var closeparan = parsec.Token(`\)`, "CLOSEPARAN")
 	var andop = parsec.Token(`&`, "AND")
 	var orop = parsec.Token(`\|`, "OR")
+	var openbracket = parsec.Token(`\[`, "OPENBRACKET")
+	var closebracket = parsec.Token(`\]`, "CLOSEBRACKET")
+	var comma = parsec.Token(`,`, "COMMA")
+	var slash = parsec.Token(`/`, "SLASH")
+	var threshold = parsec.Token(`[0-9]+`, "THRESHOLD")
 
 	// NonTerminal rats
 	// sumOp -> "&" |  "|"
@@ -70,15 +80,22 @@ func InitParser(fn ValueCheckFn) parsec.Parser {
 	// value -> "(" expr ")"
 	var groupExpr = parsec.And(exprNode, openparan, &sum, closeparan)
 
+	// ("," expr)*
+	var thresholdK = parsec.Kleene(nil, parsec.And(many2many, comma, &sum), nil)
+
+	// value -> "[" expr ("," expr)* "]" "/" threshold
+	var thresholdExpr = parsec.And(thresholdNode, openbracket, &sum,
+		thresholdK, closebracket, slash, threshold)
+
 	// (andop prod)*
 	var prodK = parsec.Kleene(nil, parsec.And(many2many, sumOp, &value), nil)
 
 	// Circular rats come to life
 	// sum -> prod (andop prod)*
 	sum = parsec.And(sumNode(fn), &value, prodK)
-	// value -> id | "(" expr ")"
+	// value -> id | "(" expr ")" | "[" expr ("," expr)* "]" "/" threshold
 	value = parsec.OrdChoice(exprValueNode(fn), identity(), proxy(),
-		evmIdentity(), attr(), groupExpr)
+		evmIdentity(), attr(), groupExpr, thresholdExpr)
 	// expr  -> sum
 	Y = parsec.OrdChoice(one2one, sum)
 	return Y
@@ -126,6 +143,12 @@ func InitOrExpr(ids ...string) Expr {
 	return Expr(strings.Join(ids, " | "))
 }
 
+// InitThresholdExpr creates an expression that evaluates to true if at least
+// k of the IDs evaluate to true.
+func InitThresholdExpr(k int, ids ...string) Expr {
+	return Expr("[" + strings.Join(ids, ", ") + "]/" + strconv.Itoa(k))
+}
+
 // Accepts tokens of the form "identity_type:HEX"
 func identity() parsec.Parser {
 	return func(s parsec.Scanner) (parsec.ParsecNode, parsec.Scanner) {
@@ -192,6 +215,31 @@ func exprValueNode(fn ValueCheckFn) func(ns []parsec.ParsecNode) parsec.ParsecNo
 	}
 }
 
+// thresholdNode counts the sub-expressions that evaluated to true and compares
+// them to the threshold. It returns nil, i.e., it fails to parse, if the
+// threshold is not between 1 and the number of sub-expressions.
+func thresholdNode(ns []parsec.ParsecNode) parsec.ParsecNode {
+	if len(ns) == 0 {
+		return nil
+	}
+	vals := []bool{ns[1].(bool)}
+	for _, x := range ns[2].([]parsec.ParsecNode) {
+		y := x.([]parsec.ParsecNode)
+		vals = append(vals, y[1].(bool))
+	}
+	k, err := strconv.Atoi(ns[5].(*parsec.Terminal).Value)
+	if err != nil || k < 1 || k > len(vals) {
+		return nil
+	}
+	var cnt int
+	for _, v := range vals {
+		if v {
+			cnt++
+		}
+	}
+	return cnt >= k
+}
+
 func exprNode(ns []parsec.ParsecNode) parsec.ParsecNode {
 	if len(ns) == 0 {
 		return nil
@@ -262,3 +262,69 @@ func TestEval_DefaultParser(t *testing.T) {
 		t.Fatal("evaluation should return false")
 	}
 }
+
+func TestParsing_Threshold(t *testing.T) {
+	fn := func(s string) bool {
+		return s == "ed25519:a" || s == "ed25519:b"
+	}
+	for expr, exp := range map[string]bool{
+		"[ed25519:a, ed25519:b, darc:c]/1":                true,
+		"[ed25519:a, ed25519:b, darc:c]/2":                true,
+		"[ed25519:a, ed25519:b, darc:c]/3":                false,
+		"[ed25519:a,ed25519:b,darc:c]/2":                  true,
+		"[ed25519:a]/1":                                   true,
+		"[darc:c]/1":                                      false,
+		"[ed25519:a & darc:c, ed25519:b]/2":               false,
+		"[ed25519:a | darc:c, ed25519:b]/2":               true,
+		"[(ed25519:a & darc:c), [ed25519:b, darc:c]/1]/1": true,
+		"darc:c | [ed25519:a, ed25519:b]/2":               true,
+		"darc:c & [ed25519:a, ed25519:b]/2":               false,
+	} {
+		x, err := Evaluate(InitParser(fn), []byte(expr))
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if x != exp {
+			t.Fatalf("%s: wrong result", expr)
+		}
+	}
+}
+
+func TestParsing_ThresholdInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"[ed25519:a, ed25519:b]/0",
+		"[ed25519:a, ed25519:b]/3",
+		"[ed25519:a, ed25519:b]",
+		"[ed25519:a, ed25519:b]/",
+		"[ed25519:a, ed25519:b/1",
+		"[]/1",
+		"[ed25519:a,]/1",
+	} {
+		_, err := Evaluate(InitParser(trueFn), []byte(expr))
+		if err == nil {
+			t.Fatalf("%s: expect an error", expr)
+		}
+	}
+}
+
+func TestEval_ThresholdExpr(t *testing.T) {
+	keys := []string{"ed25519:a", "ed25519:b", "x509ec:c", "darc:d"}
+	expr := InitThresholdExpr(3, keys...)
+	if string(expr) != "[ed25519:a, ed25519:b, x509ec:c, darc:d]/3" {
+		t.Fatalf("wrong expression: %s", expr)
+	}
+	ok, err := DefaultParser(expr, keys[:3]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok != true {
+		t.Fatal("evaluation should return true")
+	}
+	ok, err = DefaultParser(expr, keys[:2]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok != false {
+		t.Fatal("evaluation should return false")
+	}
+}
@@ -3,7 +3,10 @@ package eventlog
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"reflect"
 	"sync"
+	"time"
 
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/byzcoin"
@@ -43,6 +46,12 @@ func NewClient(ol *byzcoin.Client) *Client {
 // return once the new eventlog has been committed into the ledger (or after
 // a timeout). Upon non-error return, c.Instance will be correctly set.
 func (c *Client) Create() error {
+	return c.CreateWithRetention(nil)
+}
+
+// CreateWithRetention creates a new event log like Create, with the retention
+// policy r if it is not nil.
+func (c *Client) CreateWithRetention(r *Retention) error {
 	if c.signerCtrs == nil {
 		c.RefreshSignerCounters()
 	}
@@ -52,6 +61,13 @@ func (c *Client) Create() error {
 		Spawn:         &byzcoin.Spawn{ContractID: contractName},
 		SignerCounter: c.nextCtrs(),
 	}
+	if r != nil {
+		buf, err := protobuf.Encode(r)
+		if err != nil {
+			return err
+		}
+		instr.Spawn.Args = byzcoin.Arguments{{Name: "retention", Value: buf}}
+	}
 	tx, err := c.ByzCoin.CreateTransaction(instr)
 	if err != nil {
 		return err
@@ -201,6 +217,7 @@ func (c *Client) prepareTx(events []Event) (*byzcoin.ClientTransaction, []LogID,
 // Search executes a search on the filter in req. See the definition of type
 // SearchRequest for additional details about how the filter is interpreted.
 // The ID and Instance fields of the SearchRequest will be filled in from c.
+// If req.Proofs is set, the proofs of the events are verified.
 func (c *Client) Search(req *SearchRequest) (*SearchResponse, error) {
 	req.ID = c.ByzCoin.ID
 	req.Instance = c.Instance
@@ -209,9 +226,423 @@ func (c *Client) Search(req *SearchRequest) (*SearchResponse, error) {
 	if err := c.c.SendProtobuf(c.ByzCoin.Roster.List[0], req, reply); err != nil {
 		return nil, err
 	}
+	if req.Proofs {
+		if err := c.verifySearch(reply); err != nil {
+			return nil, err
+		}
+	}
 	return reply, nil
 }
 
+// SearchAll executes the search and follows the cursors of the responses
+// until all the events are found. The handler is called with the events of
+// every response.
+func (c *Client) SearchAll(req *SearchRequest, handler func(*SearchResponse) error) error {
+	for {
+		resp, err := c.Search(req)
+		if err != nil {
+			return err
+		}
+		if err := handler(resp); err != nil {
+			return err
+		}
+		if !resp.Truncated {
+			return nil
+		}
+		req.Cursor = resp.Cursor
+	}
+}
+
+// verifySearch verifies the proofs of the response against the genesis block
+// and the darc of the eventlog.
+func (c *Client) verifySearch(reply *SearchResponse) error {
+	p, err := c.ByzCoin.GetProof(c.Instance.Slice())
+	if err != nil {
+		return err
+	}
+	if !p.Proof.InclusionProof.Match(c.Instance.Slice()) {
+		return errors.New("eventlog is not in the ledger")
+	}
+	_, _, cid, darcID, err := p.Proof.KeyValue()
+	if err != nil {
+		return err
+	}
+	if cid != contractName {
+		return errors.New("instance is not an eventlog")
+	}
+	return reply.VerifyProofs(c.ByzCoin.Genesis, c.Instance, darcID)
+}
+
+// The kinds of instances linking the events to their eventlog.
+const (
+	linkEventLog = iota
+	linkBucket
+	linkIndex
+)
+
+// VerifyProofs checks that every event of the response is stored in the
+// ledger starting with the genesis block, by the eventlog contract and under
+// the given darc, which is the darc of the eventlog. The events must be
+// referenced by the eventlog instance: the Links are followed from the
+// eventlog, or from the heads of the indexes of the events, and the key of
+// the proof of every event, its ID, must be referenced by one of them.
+func (r *SearchResponse) VerifyProofs(genesis *skipchain.SkipBlock, inst byzcoin.InstanceID, darcID darc.ID) error {
+	if len(r.Proofs) != len(r.Events) {
+		return errors.New("missing proofs of the events")
+	}
+
+	linked := map[string]int{string(inst.Slice()): linkEventLog}
+	for _, e := range r.Events {
+		for _, key := range e.indexKeys() {
+			linked[string(indexID(inst, key).Slice())] = linkIndex
+		}
+	}
+	refs := make(map[string]bool)
+	for i, p := range r.Links {
+		key, buf, err := verifyProof(p, genesis, darcID)
+		if err != nil {
+			return fmt.Errorf("link %d: %v", i, err)
+		}
+		kind, ok := linked[string(key)]
+		if !ok {
+			return fmt.Errorf("link %d is not linked to the eventlog", i)
+		}
+		switch kind {
+		case linkEventLog:
+			linked[string(buf)] = linkBucket
+		case linkBucket:
+			var b bucket
+			if err := protobuf.Decode(buf, &b); err != nil {
+				return fmt.Errorf("link %d: %v", i, err)
+			}
+			if !b.isFirst() {
+				linked[string(b.Prev)] = linkBucket
+			}
+			for _, id := range b.EventRefs {
+				refs[string(id)] = true
+			}
+		case linkIndex:
+			var ix index
+			if err := protobuf.Decode(buf, &ix); err != nil {
+				return fmt.Errorf("link %d: %v", i, err)
+			}
+			if !ix.isFirst() {
+				linked[string(ix.Prev)] = linkIndex
+			}
+			for _, id := range ix.EventRefs {
+				refs[string(id)] = true
+			}
+		}
+	}
+
+	for i, p := range r.Proofs {
+		key, buf, err := verifyProof(p, genesis, darcID)
+		if err != nil {
+			return fmt.Errorf("event %d: %v", i, err)
+		}
+		if !refs[string(key)] {
+			return fmt.Errorf("event %d is not referenced by the eventlog", i)
+		}
+		var e Event
+		if err := protobuf.Decode(buf, &e); err != nil {
+			return fmt.Errorf("event %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(e, r.Events[i]) {
+			return fmt.Errorf("event %d differs from its proof", i)
+		}
+	}
+	return nil
+}
+
+// verifyProof checks that the proof is valid from the genesis block, and that
+// its instance is stored by the eventlog contract under the darc. It returns
+// the key and the value of the instance.
+func verifyProof(p byzcoin.Proof, genesis *skipchain.SkipBlock, darcID darc.ID) ([]byte, []byte, error) {
+	if err := p.VerifyFromBlock(genesis); err != nil {
+		return nil, nil, err
+	}
+	key, buf, cid, did, err := p.KeyValue()
+	if err != nil {
+		return nil, nil, err
+	}
+	if cid != contractName || !did.Equal(darcID) {
+		return nil, nil, errors.New("the instance is not from the eventlog")
+	}
+	return key, buf, nil
+}
+
+// SetRetention sets the retention policy of the eventlog. The signers need
+// the "invoke:eventlog.retention" permission.
+func (c *Client) SetRetention(r Retention) error {
+	buf, err := protobuf.Encode(&r)
+	if err != nil {
+		return err
+	}
+	return c.invoke(retentionCmd, byzcoin.Argument{Name: "retention", Value: buf})
+}
+
+// Archive removes the events expired by the retention policy from the
+// eventlog, and stores an Archive committing to them. At most archiveMax
+// events are removed at once. The events and their proofs are read before
+// they are removed, and returned in the verified export of the archive,
+// which should be kept: once the removed state can no longer be replayed,
+// ExportArchive fails. The signers need the "invoke:eventlog.archive"
+// permission.
+func (c *Client) Archive() (*ArchiveExport, error) {
+	rp, err := c.ByzCoin.GetProof(retentionID(c.Instance).Slice())
+	if err != nil {
+		return nil, err
+	}
+	if !rp.Proof.InclusionProof.Match(retentionID(c.Instance).Slice()) {
+		return nil, errors.New("the eventlog has no retention policy")
+	}
+	var r Retention
+	if err := rp.Proof.VerifyAndDecode(cothority.Suite, contractName, &r); err != nil {
+		return nil, err
+	}
+
+	// Walk the buckets from the latest to the first one.
+	p, err := c.ByzCoin.GetProof(c.Instance.Slice())
+	if err != nil {
+		return nil, err
+	}
+	_, bid, _, _, err := p.Proof.KeyValue()
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(bid, make([]byte, 32)) {
+		bid = nil
+	}
+	var buckets []*bucket
+	var bids [][]byte
+	for len(bid) > 0 {
+		bp, err := c.ByzCoin.GetProof(bid)
+		if err != nil {
+			return nil, err
+		}
+		if !bp.Proof.InclusionProof.Match(bid) {
+			return nil, errors.New("missing bucket")
+		}
+		b := &bucket{}
+		if err := bp.Proof.VerifyAndDecode(cothority.Suite, contractName, b); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+		bids = append(bids, bid)
+		if b.isFirst() {
+			break
+		}
+		bid = b.Prev
+	}
+
+	first := r.firstExpired(buckets, time.Now().UnixNano())
+	if first < 0 {
+		return nil, errors.New("no expired events")
+	}
+	last := lastArchivable(buckets, first)
+
+	ids, archives, err := c.Archives()
+	if err != nil {
+		return nil, err
+	}
+	x := &ArchiveExport{}
+	var commit []byte
+	if len(archives) > 0 {
+		commit = archives[0].Commit
+		prev, err := c.ByzCoin.GetProof(ids[0])
+		if err != nil {
+			return nil, err
+		}
+		x.Prev = &prev.Proof
+	}
+
+	// Read the events before they are removed.
+	for i := len(buckets) - 1; i >= last; i-- {
+		for _, ref := range buckets[i].EventRefs {
+			ep, err := c.ByzCoin.GetProof(ref)
+			if err != nil {
+				return nil, err
+			}
+			if !ep.Proof.InclusionProof.Match(ref) {
+				return nil, errors.New("missing event")
+			}
+			_, buf, _, _, err := ep.Proof.KeyValue()
+			if err != nil {
+				return nil, err
+			}
+			var e Event
+			if err := ep.Proof.VerifyAndDecode(cothority.Suite, contractName, &e); err != nil {
+				return nil, err
+			}
+			x.Events = append(x.Events, e)
+			x.Proofs = append(x.Proofs, ep.Proof)
+			commit = extendCommit(commit, ref, buf)
+		}
+	}
+
+	err = c.invoke(archiveCmd, byzcoin.Argument{Name: "bucket", Value: bids[last]},
+		byzcoin.Argument{Name: "commit", Value: commit})
+	if err != nil {
+		return nil, err
+	}
+
+	ids, _, err = c.Archives()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("missing archive")
+	}
+	ap, err := c.ByzCoin.GetProof(ids[0])
+	if err != nil {
+		return nil, err
+	}
+	x.Archive = ap.Proof
+	a, err := x.Verify(c.ByzCoin.Genesis)
+	if err != nil {
+		return nil, err
+	}
+	if !a.EventLog.Equal(c.Instance) || !bytes.Equal(a.Bucket, bids[last]) {
+		return nil, errors.New("the latest archive is not the new one")
+	}
+	return x, nil
+}
+
+// invoke sends the command to the eventlog and waits for it to be included.
+func (c *Client) invoke(cmd string, args ...byzcoin.Argument) error {
+	if c.signerCtrs == nil {
+		c.RefreshSignerCounters()
+	}
+
+	instr := byzcoin.Instruction{
+		InstanceID: c.Instance,
+		Invoke: &byzcoin.Invoke{
+			ContractID: contractName,
+			Command:    cmd,
+			Args:       args,
+		},
+		SignerCounter: c.nextCtrs(),
+	}
+	tx, err := c.ByzCoin.CreateTransaction(instr)
+	if err != nil {
+		return err
+	}
+	if err := tx.FillSignersAndSignWith(c.Signers...); err != nil {
+		return err
+	}
+	if _, err := c.ByzCoin.AddTransactionAndWait(tx, 10); err != nil {
+		return err
+	}
+	c.incrementCtrs()
+	return nil
+}
+
+// Archives returns the archives of the eventlog and their IDs, from the
+// latest to the first one.
+func (c *Client) Archives() ([][]byte, []*Archive, error) {
+	head := archiveHeadID(c.Instance).Slice()
+	p, err := c.ByzCoin.GetProof(head)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !p.Proof.InclusionProof.Match(head) {
+		return nil, nil, nil
+	}
+	_, id, _, _, err := p.Proof.KeyValue()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ids [][]byte
+	var archives []*Archive
+	for len(id) > 0 {
+		p, err := c.ByzCoin.GetProof(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !p.Proof.InclusionProof.Match(id) {
+			return nil, nil, errors.New("missing archive")
+		}
+		a := &Archive{}
+		if err := p.Proof.VerifyAndDecode(cothority.Suite, contractName, a); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		archives = append(archives, a)
+		id = a.Prev
+	}
+	return ids, archives, nil
+}
+
+// ExportArchive returns the events removed by the archive with the given ID,
+// with the proofs that they were in the eventlog in the state of the block
+// before they were removed. The export is verified before being returned.
+// It needs the nodes to replay that state, which fails after the replay
+// window or once the blocks are pruned: the export returned by Archive
+// should be kept instead.
+func (c *Client) ExportArchive(id []byte) (*ArchiveExport, error) {
+	p, err := c.ByzCoin.GetProof(id)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Proof.InclusionProof.Match(id) {
+		return nil, errors.New("no such archive")
+	}
+	a := &Archive{}
+	if err := p.Proof.VerifyAndDecode(cothority.Suite, contractName, a); err != nil {
+		return nil, err
+	}
+	if !a.EventLog.Equal(c.Instance) {
+		return nil, errors.New("archive is from another eventlog")
+	}
+	x := &ArchiveExport{Archive: p.Proof}
+	if len(a.Prev) > 0 {
+		prev, err := c.ByzCoin.GetProof(a.Prev)
+		if err != nil {
+			return nil, err
+		}
+		x.Prev = &prev.Proof
+	}
+
+	// Walk the archived buckets in the state of the block before the
+	// archive, from the latest to the first one.
+	var refs [][]byte
+	bid := a.Bucket
+	for len(bid) > 0 {
+		bp, err := c.ByzCoin.GetProofAt(bid, a.Index)
+		if err != nil {
+			return nil, err
+		}
+		if !bp.Proof.InclusionProof.Match(bid) {
+			return nil, errors.New("missing archived bucket")
+		}
+		var b bucket
+		if err := bp.Proof.VerifyAndDecode(cothority.Suite, contractName, &b); err != nil {
+			return nil, err
+		}
+		refs = append(append([][]byte{}, b.EventRefs...), refs...)
+		bid = b.Prev
+	}
+
+	for _, ref := range refs {
+		ep, err := c.ByzCoin.GetProofAt(ref, a.Index)
+		if err != nil {
+			return nil, err
+		}
+		var e Event
+		if err := ep.Proof.VerifyAndDecode(cothority.Suite, contractName, &e); err != nil {
+			return nil, err
+		}
+		x.Events = append(x.Events, e)
+		x.Proofs = append(x.Proofs, ep.Proof)
+	}
+
+	if _, err := x.Verify(c.ByzCoin.Genesis); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
 // StreamHandler is the signature of the handler used when streaming events.
 type StreamHandler func(event Event, blockID []byte, err error)
 
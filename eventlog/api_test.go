@@ -15,6 +15,7 @@ import (
 	"go.dedis.ch/kyber/v3/suites"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
 	"go.dedis.ch/protobuf"
 )
 
@@ -319,6 +320,326 @@ func TestClient_Search(t *testing.T) {
 	require.False(t, resp.Truncated)
 }
 
+func TestClient_SearchQuery(t *testing.T) {
+	s, c := newSer(t)
+	leader := s.services[0]
+	defer s.close()
+
+	err := c.Create()
+	require.NoError(t, err)
+	waitForKey(t, leader.omni, c.ByzCoin.ID, c.Instance.Slice(), testBlockInterval)
+
+	tm0 := time.Now().UnixNano()
+	events := []Event{
+		{When: tm0, Topic: "auth.login", Content: "user alice logged in",
+			Fields: []Field{{"user", "alice"}, {"host", "a"}}},
+		{When: tm0 + 1, Topic: "auth.logout", Content: "user alice logged out",
+			Fields: []Field{{"user", "alice"}}},
+		{When: tm0 + 2, Topic: "auth.login", Content: "user bob logged in",
+			Fields: []Field{{"user", "bob"}, {"host", "b"}}},
+		{When: tm0 + 3, Topic: "backup", Content: "backup of 42 files"},
+	}
+	_, err = c.Log(events...)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		leader.waitForBlock(c.ByzCoin.ID)
+		if err = leader.checkBuckets(c.Instance, c.ByzCoin.ID, len(events)); err == nil {
+			break
+		}
+	}
+	require.NoError(t, err)
+
+	search := func(req *SearchRequest) []Event {
+		resp, err := c.Search(req)
+		require.NoError(t, err)
+		require.False(t, resp.Truncated)
+		return resp.Events
+	}
+
+	// Through the topic index.
+	res := search(&SearchRequest{Topic: "auth.login"})
+	require.Equal(t, 2, len(res))
+	require.Equal(t, events[0].Content, res[0].Content)
+	require.Equal(t, events[0].Fields, res[0].Fields)
+	require.Equal(t, 1, len(search(&SearchRequest{Topic: "auth.login", From: tm0 + 1})))
+	require.Equal(t, 0, len(search(&SearchRequest{Topic: "none"})))
+
+	// Through the field indexes.
+	require.Equal(t, 2, len(search(&SearchRequest{Fields: []Field{{"user", "alice"}}})))
+	res = search(&SearchRequest{Fields: []Field{{"user", "alice"}, {"host", "a"}}})
+	require.Equal(t, 1, len(res))
+	require.Equal(t, "auth.login", res[0].Topic)
+	require.Equal(t, 1, len(search(&SearchRequest{Topic: "auth.login",
+		Fields: []Field{{"user", "bob"}}})))
+
+	// Filters on the whole eventlog.
+	require.Equal(t, 3, len(search(&SearchRequest{TopicMatch: "auth.*"})))
+	require.Equal(t, 2, len(search(&SearchRequest{TopicMatch: "auth.log??"})))
+	require.Equal(t, 2, len(search(&SearchRequest{Content: "alice"})))
+	res = search(&SearchRequest{ContentRegexp: `[0-9]+ files$`})
+	require.Equal(t, 1, len(res))
+	require.Equal(t, "backup", res[0].Topic)
+	require.Equal(t, 1, len(search(&SearchRequest{TopicMatch: "auth.*",
+		Content: "out"})))
+
+	_, err = c.Search(&SearchRequest{ContentRegexp: "("})
+	require.Error(t, err)
+
+	// The search follows the chunks of an index.
+	many := make([]Event, indexMax+indexMax/2)
+	tm1 := time.Now().UnixNano()
+	for i := range many {
+		many[i] = Event{When: tm1 + int64(i), Topic: "many",
+			Content: fmt.Sprintf("event %d", i)}
+	}
+	_, err = c.Log(many...)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		leader.waitForBlock(c.ByzCoin.ID)
+		err = leader.checkBuckets(c.Instance, c.ByzCoin.ID, len(events)+len(many))
+		if err == nil {
+			break
+		}
+	}
+	require.NoError(t, err)
+	res = search(&SearchRequest{Topic: "many"})
+	require.Equal(t, len(many), len(res))
+	require.Equal(t, many[0].Content, res[0].Content)
+
+	// Events with invalid fields are refused.
+	_, err = c.Log(Event{When: time.Now().UnixNano(), Topic: "x",
+		Fields: []Field{{"a", "1"}, {"a", "2"}}})
+	require.Error(t, err)
+}
+
+func TestClient_SearchCursor(t *testing.T) {
+	s, c := newSer(t)
+	leader := s.services[0]
+	defer s.close()
+
+	err := c.Create()
+	require.NoError(t, err)
+	waitForKey(t, leader.omni, c.ByzCoin.ID, c.Instance.Slice(), testBlockInterval)
+
+	// All the events have the same timestamp, so they can't be paged by time.
+	tm := time.Now().UnixNano()
+	logCount := 10
+	events := make([]Event, logCount)
+	for i := range events {
+		events[i] = Event{When: tm, Topic: "t", Content: fmt.Sprintf("event %d", i)}
+	}
+	_, err = c.Log(events...)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		leader.waitForBlock(c.ByzCoin.ID)
+		if err = leader.checkBuckets(c.Instance, c.ByzCoin.ID, logCount); err == nil {
+			break
+		}
+	}
+	require.NoError(t, err)
+
+	sm := searchMax
+	searchMax = 3
+	defer func() { searchMax = sm }()
+
+	// Through the buckets and through the topic index.
+	for _, topic := range []string{"", "t"} {
+		var found []string
+		pages := 0
+		err = c.SearchAll(&SearchRequest{Topic: topic}, func(resp *SearchResponse) error {
+			pages++
+			require.Equal(t, resp.Truncated, len(resp.Cursor) > 0)
+			for _, e := range resp.Events {
+				found = append(found, e.Content)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 4, pages)
+		require.Equal(t, logCount, len(found))
+		for i := range events {
+			require.Contains(t, found, events[i].Content)
+		}
+	}
+
+	_, err = c.Search(&SearchRequest{Cursor: []byte("not a cursor")})
+	require.Error(t, err)
+
+	// With proofs.
+	smp := searchMaxProofs
+	searchMaxProofs = 2
+	defer func() { searchMaxProofs = smp }()
+	resp, err := c.Search(&SearchRequest{Proofs: true})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(resp.Events))
+	require.Equal(t, 2, len(resp.Proofs))
+	require.True(t, resp.Truncated)
+
+	resp, err = c.Search(&SearchRequest{Proofs: true, Cursor: resp.Cursor})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(resp.Events))
+	require.NoError(t, resp.VerifyProofs(c.ByzCoin.Genesis, c.Instance, s.gen.GetBaseID()))
+	require.Error(t, resp.VerifyProofs(c.ByzCoin.Genesis, c.Instance, darc.ID(make([]byte, 32))))
+
+	// Through the index, the events are tied to the eventlog by its chunks.
+	indexed, err := c.Search(&SearchRequest{Topic: "t", Proofs: true})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(indexed.Events))
+	require.NoError(t, indexed.VerifyProofs(c.ByzCoin.Genesis, c.Instance, s.gen.GetBaseID()))
+
+	// The events must be referenced by the eventlog.
+	other := NewClient(c.ByzCoin)
+	other.DarcID = c.DarcID
+	other.Signers = c.Signers
+	require.NoError(t, other.Create())
+	waitForKey(t, leader.omni, c.ByzCoin.ID, other.Instance.Slice(), testBlockInterval)
+	require.Error(t, resp.VerifyProofs(c.ByzCoin.Genesis, other.Instance, s.gen.GetBaseID()))
+	_, err = other.Log(Event{When: tm, Topic: "t", Content: "other"})
+	require.NoError(t, err)
+	var foreign *SearchResponse
+	for i := 0; i < 10; i++ {
+		leader.waitForBlock(c.ByzCoin.ID)
+		foreign, err = other.Search(&SearchRequest{Proofs: true})
+		require.NoError(t, err)
+		if len(foreign.Events) == 1 {
+			break
+		}
+	}
+	require.Equal(t, 1, len(foreign.Events))
+	require.NoError(t, foreign.VerifyProofs(c.ByzCoin.Genesis, other.Instance, s.gen.GetBaseID()))
+	require.Error(t, foreign.VerifyProofs(c.ByzCoin.Genesis, c.Instance, s.gen.GetBaseID()))
+
+	forged := *resp
+	forged.Events = []Event{resp.Events[0], foreign.Events[0]}
+	forged.Proofs = []byzcoin.Proof{resp.Proofs[0], foreign.Proofs[0]}
+	require.Error(t, forged.VerifyProofs(c.ByzCoin.Genesis, c.Instance, s.gen.GetBaseID()))
+	forged.Links = append(forged.Links, foreign.Links...)
+	require.Error(t, forged.VerifyProofs(c.ByzCoin.Genesis, c.Instance, s.gen.GetBaseID()))
+
+	// A proof of another instance of the eventlog is not a proof of the
+	// event.
+	forged = *resp
+	forged.Proofs = []byzcoin.Proof{resp.Proofs[0], resp.Links[1]}
+	require.Error(t, forged.VerifyProofs(c.ByzCoin.Genesis, c.Instance, s.gen.GetBaseID()))
+	forged = *resp
+	forged.Links = nil
+	require.Error(t, forged.VerifyProofs(c.ByzCoin.Genesis, c.Instance, s.gen.GetBaseID()))
+
+	resp.Events[1].Content = "forged"
+	require.Error(t, resp.VerifyProofs(c.ByzCoin.Genesis, c.Instance, s.gen.GetBaseID()))
+	resp.Proofs = resp.Proofs[:1]
+	require.Error(t, resp.VerifyProofs(c.ByzCoin.Genesis, c.Instance, s.gen.GetBaseID()))
+}
+
+func TestClient_Archive(t *testing.T) {
+	s, c := newSer(t)
+	leader := s.services[0]
+	defer s.close()
+
+	require.NoError(t, c.CreateWithRetention(&Retention{MaxEvents: 3}))
+	waitForKey(t, leader.omni, c.ByzCoin.ID, c.Instance.Slice(), testBlockInterval)
+	_, err := c.Archive()
+	require.Error(t, err)
+
+	// Log 3 batches of events far enough apart to be in different buckets.
+	now := time.Now()
+	var batches [][]Event
+	for i, ago := range []time.Duration{20 * time.Second, 10 * time.Second, 0} {
+		var events []Event
+		for j := 0; j < 3; j++ {
+			events = append(events, Event{
+				When:    now.Add(-ago).UnixNano() + int64(j),
+				Topic:   "t",
+				Content: fmt.Sprintf("batch %d event %d", i, j),
+			})
+		}
+		_, err := c.Log(events...)
+		require.NoError(t, err)
+		batches = append(batches, events)
+	}
+
+	// Only the last batch must be kept, but only 4 events can be archived
+	// at once, so that it takes two archives.
+	am := archiveMax
+	archiveMax = 4
+	defer func() { archiveMax = am }()
+
+	// The contract only archives the expired buckets, and all of them up to
+	// archiveMax, if the commit matches their events.
+	p, err := c.ByzCoin.GetProof(c.Instance.Slice())
+	require.NoError(t, err)
+	_, latest, _, _, err := p.Proof.KeyValue()
+	require.NoError(t, err)
+	require.Error(t, c.invoke(archiveCmd))
+	require.Error(t, c.invoke(archiveCmd, byzcoin.Argument{Name: "bucket", Value: latest},
+		byzcoin.Argument{Name: "commit", Value: []byte{}}))
+
+	var exports []*ArchiveExport
+	for i := 0; i < 2; i++ {
+		x, err := c.Archive()
+		require.NoError(t, err)
+		require.Equal(t, batches[i], x.Events)
+		exports = append(exports, x)
+	}
+	_, err = c.Archive()
+	require.Error(t, err)
+
+	for _, req := range []*SearchRequest{{}, {Topic: "t"}} {
+		resp, err := c.Search(req)
+		require.NoError(t, err)
+		require.Equal(t, batches[2], resp.Events)
+	}
+	require.NoError(t, leader.checkBuckets(c.Instance, c.ByzCoin.ID, 3))
+
+	ids, archives, err := c.Archives()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(archives))
+	require.Equal(t, ids[1], archives[0].Prev)
+	exported, err := c.ExportArchive(ids[1])
+	require.NoError(t, err)
+	require.Equal(t, exports[0].Events, exported.Events)
+	for i := range archives {
+		require.Equal(t, int64(3), archives[i].Count)
+
+		// The export must survive a round-trip through a file.
+		buf, err := network.Marshal(exports[1-i])
+		require.NoError(t, err)
+		_, msg, err := network.Unmarshal(buf, cothority.Suite)
+		require.NoError(t, err)
+		x := msg.(*ArchiveExport)
+		a, err := x.Verify(c.ByzCoin.Genesis)
+		require.NoError(t, err)
+		require.Equal(t, archives[i].Commit, a.Commit)
+
+		x.Events[0].Content = "forged"
+		_, err = x.Verify(c.ByzCoin.Genesis)
+		require.Error(t, err)
+	}
+
+	// The retention can be changed.
+	require.NoError(t, c.SetRetention(Retention{MaxEvents: 1}))
+	require.Error(t, c.SetRetention(Retention{MaxAge: -1}))
+
+	// Once the blocks before the latest archive are pruned, its state can't
+	// be replayed any more, but the export returned by Archive still
+	// verifies.
+	skID := onet.ServiceFactory.ServiceID(skipchain.ServiceName)
+	for _, sv := range s.local.GetServices(s.hosts, skID) {
+		scs := sv.(*skipchain.Service)
+		for i := 1; i <= archives[0].Index; i++ {
+			reply, err := scs.GetSingleBlockByIndex(
+				&skipchain.GetSingleBlockByIndex{Genesis: c.ByzCoin.ID, Index: i})
+			require.NoError(t, err)
+			require.NoError(t, scs.GetDB().PruneBlock(reply.SkipBlock.Hash))
+		}
+	}
+	_, err = c.ExportArchive(ids[0])
+	require.Error(t, err)
+	a, err := exports[1].Verify(c.ByzCoin.Genesis)
+	require.NoError(t, err)
+	require.Equal(t, archives[0].Commit, a.Commit)
+}
+
 func TestClient_StreamEvents(t *testing.T) {
 	s, c := newSer(t)
 	leader := s.services[0]
@@ -546,7 +867,9 @@ func newSer(t *testing.T) (*ser, *Client) {
 
 	var err error
 	s.req, err = byzcoin.DefaultGenesisMsg(byzcoin.CurrentVersion, s.roster,
-		[]string{"spawn:" + contractName, "invoke:" + contractName + "." + logCmd, "_name:" + contractName}, s.owner.Identity())
+		[]string{"spawn:" + contractName, "invoke:" + contractName + "." + logCmd,
+			"invoke:" + contractName + "." + retentionCmd,
+			"invoke:" + contractName + "." + archiveCmd, "_name:" + contractName}, s.owner.Identity())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,362 @@
+package eventlog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+)
+
+func init() {
+	network.RegisterMessage(&ArchiveExport{})
+}
+
+// archiveMax is the maximum number of events removed by one archive
+// command, so that the transaction stays small. The archive command must be
+// repeated to archive more events.
+var archiveMax = 1000
+
+// retentionID returns the instance ID of the retention policy of the
+// eventlog.
+func retentionID(inst byzcoin.InstanceID) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write(inst.Slice())
+	h.Write([]byte("retention"))
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// archiveHeadID returns the instance ID holding the ID of the latest archive
+// of the eventlog.
+func archiveHeadID(inst byzcoin.InstanceID) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write(inst.Slice())
+	h.Write([]byte("archive"))
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// extendCommit adds an event to the hash chain of an archive.
+func extendCommit(commit, id, event []byte) []byte {
+	h := sha256.New()
+	h.Write(commit)
+	h.Write(id)
+	h.Write(event)
+	return h.Sum(nil)
+}
+
+func (r Retention) verify() error {
+	if r.MaxAge < 0 || r.MaxEvents < 0 {
+		return errors.New("negative retention")
+	}
+	return nil
+}
+
+// expired returns whether the retention policy expires the bucket, given
+// the bucket following it, the number of events in the following buckets and
+// the current time.
+func (r Retention) expired(next *bucket, count int, now int64) bool {
+	if r.MaxAge > 0 && next.Start <= now-r.MaxAge {
+		return true
+	}
+	return r.MaxEvents > 0 && int64(count) >= r.MaxEvents
+}
+
+// firstExpired returns the position of the latest bucket expired by the
+// retention policy, in the buckets from the latest to the first one, or -1
+// if there is none. The latest bucket never expires.
+func (r Retention) firstExpired(buckets []*bucket, now int64) int {
+	count := 0
+	for i := 1; i < len(buckets); i++ {
+		count += len(buckets[i-1].EventRefs)
+		if r.expired(buckets[i-1], count, now) {
+			return i
+		}
+	}
+	return -1
+}
+
+// lastArchivable returns the position of the latest bucket that can be
+// archived at once with the older ones, given the position of the latest
+// expired bucket: at most archiveMax events are archived, except if the first
+// bucket alone holds more.
+func lastArchivable(buckets []*bucket, first int) int {
+	last := len(buckets) - 1
+	count := len(buckets[last].EventRefs)
+	for last > first && count+len(buckets[last-1].EventRefs) <= archiveMax {
+		last--
+		count += len(buckets[last].EventRefs)
+	}
+	return last
+}
+
+func (e eventLog) getRetention() (*Retention, error) {
+	buf, err := getValue(e.v, retentionID(e.Instance).Slice())
+	if err != nil || buf == nil {
+		return nil, err
+	}
+	var r Retention
+	if err := protobuf.Decode(buf, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// getArchive returns the archive with the given ID, and its ID, or the
+// latest archive of the eventlog if id is nil. It returns nil if there is no
+// archive.
+func (e eventLog) getArchive(id []byte) ([]byte, *Archive, error) {
+	if id == nil {
+		var err error
+		id, err = getValue(e.v, archiveHeadID(e.Instance).Slice())
+		if err != nil || id == nil {
+			return nil, nil, err
+		}
+	}
+	buf, err := getValue(e.v, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if buf == nil {
+		return nil, nil, errors.New("missing archive")
+	}
+	var a Archive
+	if err := protobuf.Decode(buf, &a); err != nil {
+		return nil, nil, err
+	}
+	return id, &a, nil
+}
+
+// setRetention returns the state changes storing the retention policy.
+func (e eventLog) setRetention(buf []byte, darcID darc.ID) ([]byzcoin.StateChange, error) {
+	var r Retention
+	if err := protobuf.Decode(buf, &r); err != nil {
+		return nil, err
+	}
+	if err := r.verify(); err != nil {
+		return nil, err
+	}
+	old, err := e.getRetention()
+	if err != nil {
+		return nil, err
+	}
+	action := byzcoin.Update
+	if old == nil {
+		action = byzcoin.Create
+	}
+	return []byzcoin.StateChange{byzcoin.NewStateChange(action,
+		retentionID(e.Instance), contractName, buf, darcID)}, nil
+}
+
+// archive returns the state changes removing the events expired by the
+// retention policy, from the oldest to the latest, and storing a new Archive
+// committing to them. The oldest bucket left becomes the first bucket of the
+// eventlog. The instruction gives the latest bucket to archive and the commit
+// of the new archive, so that the client has all the archived events before
+// they are removed.
+func (e eventLog) archive(inst byzcoin.Instruction, now int64, darcID darc.ID) ([]byzcoin.StateChange, error) {
+	target := inst.Invoke.Args.Search("bucket")
+	commit := inst.Invoke.Args.Search("commit")
+	if target == nil || commit == nil {
+		return nil, errors.New("expected the named arguments \"bucket\" and \"commit\"")
+	}
+	r, err := e.getRetention()
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, errors.New("the eventlog has no retention policy")
+	}
+
+	// Walk the buckets from the latest to the first one.
+	id, b, err := e.getLatestBucket()
+	if err != nil {
+		return nil, err
+	}
+	var buckets []*bucket
+	var bids [][]byte
+	for b != nil {
+		buckets = append(buckets, b)
+		bids = append(bids, id)
+		if b.isFirst() {
+			break
+		}
+		id = b.Prev
+		b, err = e.getBucketByID(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	first := r.firstExpired(buckets, now)
+	if first < 0 {
+		return nil, errors.New("no expired events")
+	}
+	last := -1
+	for i, bid := range bids {
+		if bytes.Equal(bid, target) {
+			last = i
+		}
+	}
+	if last < first {
+		return nil, errors.New("the bucket is not expired")
+	}
+	if last < lastArchivable(buckets, first) {
+		return nil, errors.New("too many events to archive at once")
+	}
+
+	prevID, prev, err := e.getArchive(nil)
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{EventLog: e.Instance, Prev: prevID, Index: e.v.GetIndex()}
+	if prev != nil {
+		a.Commit = prev.Commit
+	}
+
+	// Remove the buckets from the oldest one to the target.
+	var sc []byzcoin.StateChange
+	for i := len(buckets) - 1; i >= last; i-- {
+		refs := buckets[i].EventRefs
+		for _, ref := range refs {
+			buf, err := getValue(e.v, ref)
+			if err != nil {
+				return nil, err
+			}
+			if buf == nil {
+				return nil, fmt.Errorf("missing event %x", ref)
+			}
+			a.Commit = extendCommit(a.Commit, ref, buf)
+			sc = append(sc, byzcoin.NewStateChange(byzcoin.Remove,
+				byzcoin.NewInstanceID(ref), contractName, nil, darcID))
+		}
+		a.Count += int64(len(refs))
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Remove,
+			byzcoin.NewInstanceID(bids[i]), contractName, nil, darcID))
+	}
+	a.Bucket = bids[last]
+	if !bytes.Equal(a.Commit, commit) {
+		return nil, errors.New("the archived events don't match the commit")
+	}
+
+	// The bucket after the archived ones catches all the older events.
+	b = buckets[last-1]
+	b.Start = 0
+	b.Prev = nil
+	buf, err := protobuf.Encode(b)
+	if err != nil {
+		return nil, err
+	}
+	sc = append(sc, byzcoin.NewStateChange(byzcoin.Update,
+		byzcoin.NewInstanceID(bids[last-1]), contractName, buf, darcID))
+
+	buf, err = protobuf.Encode(a)
+	if err != nil {
+		return nil, err
+	}
+	aID := inst.DeriveID("archive")
+	sc = append(sc, byzcoin.NewStateChange(byzcoin.Create, aID, contractName,
+		buf, darcID))
+	action := byzcoin.Update
+	if prev == nil {
+		action = byzcoin.Create
+	}
+	sc = append(sc, byzcoin.NewStateChange(action, archiveHeadID(e.Instance),
+		contractName, aID.Slice(), darcID))
+	return sc, nil
+}
+
+// ArchiveExport holds the events removed by an archive, with the proofs that
+// they were stored in the eventlog.
+type ArchiveExport struct {
+	// Archive is the proof of the archive.
+	Archive byzcoin.Proof
+	// Prev is the proof of the previous archive, if any.
+	Prev *byzcoin.Proof `protobuf:"opt"`
+	// Events are the archived events, from the oldest to the latest.
+	Events []Event
+	// Proofs are the proofs of the events in a state before they were
+	// archived.
+	Proofs []byzcoin.Proof
+}
+
+// Verify checks the proofs of the export against the genesis block of the
+// ledger, and that the events are the ones committed to by the archive. As
+// the commit of the archive binds the IDs and the content of the events, the
+// proofs of the events can be from any block before the archive. It returns
+// the archive.
+func (x *ArchiveExport) Verify(genesis *skipchain.SkipBlock) (*Archive, error) {
+	var a Archive
+	if err := verifyArchive(x.Archive, genesis, &a); err != nil {
+		return nil, err
+	}
+
+	var commit []byte
+	if len(a.Prev) > 0 {
+		if x.Prev == nil {
+			return nil, errors.New("missing proof of the previous archive")
+		}
+		var prev Archive
+		if err := verifyArchive(*x.Prev, genesis, &prev); err != nil {
+			return nil, err
+		}
+		k, _, _, _, _ := x.Prev.KeyValue()
+		if !bytes.Equal(k, a.Prev) || !prev.EventLog.Equal(a.EventLog) {
+			return nil, errors.New("wrong previous archive")
+		}
+		commit = prev.Commit
+	}
+
+	if int64(len(x.Events)) != a.Count || len(x.Proofs) != len(x.Events) {
+		return nil, errors.New("wrong number of events")
+	}
+	for i, p := range x.Proofs {
+		if err := p.VerifyFromBlock(genesis); err != nil {
+			return nil, fmt.Errorf("event %d: %v", i, err)
+		}
+		k, buf, cid, _, err := p.KeyValue()
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %v", i, err)
+		}
+		if !p.InclusionProof.Match(k) {
+			return nil, fmt.Errorf("event %d: not an inclusion proof", i)
+		}
+		if cid != contractName {
+			return nil, fmt.Errorf("event %d is not from an eventlog", i)
+		}
+		var e Event
+		if err := protobuf.Decode(buf, &e); err != nil {
+			return nil, fmt.Errorf("event %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(e, x.Events[i]) {
+			return nil, fmt.Errorf("event %d differs from its proof", i)
+		}
+		commit = extendCommit(commit, k, buf)
+	}
+	if !bytes.Equal(commit, a.Commit) {
+		return nil, errors.New("events don't match the commitment of the archive")
+	}
+	return &a, nil
+}
+
+func verifyArchive(p byzcoin.Proof, genesis *skipchain.SkipBlock, a *Archive) error {
+	if err := p.VerifyFromBlock(genesis); err != nil {
+		return err
+	}
+	k, buf, cid, _, err := p.KeyValue()
+	if err != nil {
+		return err
+	}
+	if !p.InclusionProof.Match(k) {
+		return errors.New("not an inclusion proof of the archive")
+	}
+	if cid != contractName {
+		return errors.New("archive is not from an eventlog")
+	}
+	return protobuf.Decode(buf, a)
+}
@@ -3,8 +3,10 @@ package eventlog
 import (
 	"bytes"
 	"errors"
+	"time"
 
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/protobuf"
 )
 
@@ -16,6 +18,29 @@ type bucket struct {
 	EventRefs [][]byte
 }
 
+// cursor is the position of an event in the search results. The events found
+// through the buckets are identified by their bucket and their index in it,
+// and the events found through an index by their ID.
+type cursor struct {
+	Bucket []byte
+	Index  int
+	Event  []byte
+}
+
+func (c cursor) equal(o cursor) bool {
+	return bytes.Equal(c.Bucket, o.Bucket) && c.Index == o.Index &&
+		bytes.Equal(c.Event, o.Event)
+}
+
+// eventRef is an event found by a search.
+type eventRef struct {
+	id     []byte
+	cursor cursor
+	// link is the position, in the links returned by the search, of the
+	// bucket or the chunk of the index referencing the event.
+	link int
+}
+
 func (b bucket) isFirst() bool {
 	return len(b.Prev) == 0
 }
@@ -67,3 +92,67 @@ func (e eventLog) getIndexValue() ([]byte, error) {
 	}
 	return v0, nil
 }
+
+// searchBuckets returns the references to the events of the buckets covering
+// the time range of the request, from the earliest bucket to the latest one.
+// It also returns the links from the eventlog to the events: the ID of the
+// eventlog, and the IDs of the buckets from the latest to the earliest one.
+func (e eventLog) searchBuckets(req *SearchRequest, id []byte, b *bucket) ([]eventRef, [][]byte, error) {
+	// bEnd is normally updated from the last bucket's start. For the latest
+	// bucket, bEnd is now.
+	bEnd := time.Now().UnixNano()
+
+	// Walk backwards in the bucket chain through 2 zones: first where the
+	// bucket covers time that is not in our search range, and then where the buckets
+	// do cover the search range. When we see a bucket that ends before our search
+	// range, we can stop walking buckets.
+	var buckets []*bucket
+	var bids [][]byte
+	var links []int
+	walked := [][]byte{e.Instance.Slice()}
+	for {
+		walked = append(walked, id)
+		if req.From > bEnd {
+			// This bucket is before the search range, so we are done walking back the bucket chain.
+			break
+		}
+
+		if req.To < b.Start {
+			// This bucket is after the search range, so we do not add it to buckets, but
+			// we keep walking up the chain.
+		} else {
+			buckets = append(buckets, b)
+			bids = append(bids, id)
+			links = append(links, len(walked)-1)
+		}
+
+		if b.isFirst() {
+			break
+		}
+		bEnd = b.Start
+		id = b.Prev
+		var err error
+		b, err = e.getBucketByID(id)
+		if err != nil {
+			// This indicates that the event log data structure is wrong, so
+			// we cannot claim to correctly search it. Give up instead.
+			log.Errorf("expected event log bucket id %v not found: %v", string(id), err)
+			return nil, nil, err
+		}
+	}
+
+	// Process the time buckets from earliest to latest so that
+	// if we truncate, it is the latest events that are not returned,
+	// so that the search can continue from the cursor of the first one.
+	var refs []eventRef
+	for i := len(buckets) - 1; i >= 0; i-- {
+		for j, id := range buckets[i].EventRefs {
+			refs = append(refs, eventRef{
+				id:     id,
+				cursor: cursor{Bucket: bids[i], Index: j},
+				link:   links[i],
+			})
+		}
+	}
+	return refs, walked, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	cli "github.com/urfave/cli"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/eventlog"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+)
+
+var archiveFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:   "bc",
+		EnvVar: "BC",
+		Usage:  "the ByzCoin config",
+	},
+	cli.StringFlag{
+		Name:   "el",
+		EnvVar: "EL",
+		Usage:  "the eventlog id, from \"el create\"",
+	},
+}
+
+var archiveCommand = cli.Command{
+	Name:  "archive",
+	Usage: "manage the retention policy and the archives of an event log",
+	Subcommands: cli.Commands{
+		{
+			Name:  "policy",
+			Usage: "set the retention policy",
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "sign",
+					Usage: "the ed25519 private key that will sign the transaction",
+				},
+				cli.DurationFlag{
+					Name:  "max-age",
+					Usage: "archive the events older than this (default: no limit)",
+				},
+				cli.Int64Flag{
+					Name:  "max-events",
+					Usage: "archive all but this number of latest events (default: no limit)",
+				},
+			}, archiveFlags...),
+			Action: archivePolicy,
+		},
+		{
+			Name:      "run",
+			Usage:     "archive the events expired by the retention policy and write them with their proofs to a file",
+			ArgsUsage: "file",
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "sign",
+					Usage: "the ed25519 private key that will sign the transaction",
+				},
+			}, archiveFlags...),
+			Action: archiveRun,
+		},
+		{
+			Name:   "list",
+			Usage:  "list the archives, from the latest to the first one",
+			Flags:  archiveFlags,
+			Action: archiveList,
+		},
+		{
+			Name:      "export",
+			Usage:     "write the events of an archive with their proofs to a file",
+			ArgsUsage: "file",
+			Flags: append([]cli.Flag{
+				cli.IntFlag{
+					Name:  "n",
+					Usage: "the number of the archive in \"el archive list\" (default: the latest)",
+				},
+			}, archiveFlags...),
+			Action: archiveExport,
+		},
+	},
+}
+
+// getArchiveClient returns the client of the eventlog given by -el.
+func getArchiveClient(c *cli.Context, priv bool) (*eventlog.Client, error) {
+	cl, err := getClient(c, priv)
+	if err != nil {
+		return nil, err
+	}
+	e := c.String("el")
+	if e == "" {
+		return nil, errors.New("--el is required")
+	}
+	eb, err := hex.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	cl.Instance = byzcoin.NewInstanceID(eb)
+	return cl, nil
+}
+
+func archivePolicy(c *cli.Context) error {
+	cl, err := getArchiveClient(c, true)
+	if err != nil {
+		return err
+	}
+	return cl.SetRetention(eventlog.Retention{
+		MaxAge:    int64(c.Duration("max-age")),
+		MaxEvents: c.Int64("max-events"),
+	})
+}
+
+func archiveRun(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("please give the file to write the archive to")
+	}
+	cl, err := getArchiveClient(c, true)
+	if err != nil {
+		return err
+	}
+
+	// The events are read before they are removed, and the export is
+	// verified by the client.
+	x, err := cl.Archive()
+	if err != nil {
+		return err
+	}
+	return writeExport(c.Args().First(), x)
+}
+
+func archiveList(c *cli.Context) error {
+	cl, err := getArchiveClient(c, false)
+	if err != nil {
+		return err
+	}
+	ids, archives, err := cl.Archives()
+	if err != nil {
+		return err
+	}
+	for i, a := range archives {
+		log.Infof("%d\t%x\t%d events\tblock %d", i, ids[i], a.Count, a.Index)
+	}
+	return nil
+}
+
+func archiveExport(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("please give the file to write the archive to")
+	}
+	cl, err := getArchiveClient(c, false)
+	if err != nil {
+		return err
+	}
+	ids, _, err := cl.Archives()
+	if err != nil {
+		return err
+	}
+	n := c.Int("n")
+	if n < 0 || n >= len(ids) {
+		return fmt.Errorf("there are %d archives", len(ids))
+	}
+
+	// The export is verified by the client.
+	x, err := cl.ExportArchive(ids[n])
+	if err != nil {
+		return err
+	}
+	return writeExport(c.Args().First(), x)
+}
+
+// writeExport writes the export to the file and prints its events.
+func writeExport(file string, x *eventlog.ArchiveExport) error {
+	buf, err := network.Marshal(x)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file, buf, 0644); err != nil {
+		return err
+	}
+
+	const tsFormat = "2006-01-02 15:04:05"
+	for _, e := range x.Events {
+		log.Infof("%v\t%v\t%v", time.Unix(0, e.When).Format(tsFormat), e.Topic, e.Content)
+	}
+	return nil
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/hex"
 	"errors"
@@ -13,7 +12,6 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 
 	oidc "github.com/coreos/go-oidc"
@@ -27,9 +25,6 @@ import (
 	"go.dedis.ch/cothority/v3/eventlog"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
-	"go.dedis.ch/kyber/v3/share"
-	"go.dedis.ch/kyber/v3/sign/dss"
-	"go.dedis.ch/kyber/v3/sign/schnorr"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/cfgpath"
 	"go.dedis.ch/onet/v3/log"
@@ -77,6 +72,14 @@ var cmds = cli.Commands{
 				Name:  "darc",
 				Usage: "the DarcID that has the spawn:evenlog rule (default is the genesis DarcID)",
 			},
+			cli.DurationFlag{
+				Name:  "max-age",
+				Usage: "retention policy: archive the events older than this",
+			},
+			cli.Int64Flag{
+				Name:  "max-events",
+				Usage: "retention policy: archive all but this number of latest events",
+			},
 		},
 		Action: create,
 	},
@@ -132,6 +135,10 @@ var cmds = cli.Commands{
 				Name:  "content, c",
 				Usage: "the text of the log",
 			},
+			cli.StringSliceFlag{
+				Name:  "field, f",
+				Usage: "a field of the log, as key=value (can be repeated)",
+			},
 			cli.IntFlag{
 				Name:  "wait, w",
 				Usage: "wait for block inclusion (default: do not wait)",
@@ -159,6 +166,22 @@ var cmds = cli.Commands{
 				Name:  "topic, t",
 				Usage: "limit results to logs with this topic",
 			},
+			cli.StringFlag{
+				Name:  "match, m",
+				Usage: "limit results to logs with a topic matching this pattern, where '*' matches anything and '?' any character",
+			},
+			cli.StringFlag{
+				Name:  "grep, g",
+				Usage: "limit results to logs with a content containing this string",
+			},
+			cli.StringFlag{
+				Name:  "regexp, r",
+				Usage: "limit results to logs with a content matching this regular expression",
+			},
+			cli.StringSliceFlag{
+				Name:  "field, f",
+				Usage: "limit results to logs with this field, as key=value (can be repeated)",
+			},
 			cli.IntFlag{
 				Name:  "count, c",
 				Usage: "limit results to X events",
@@ -175,9 +198,18 @@ var cmds = cli.Commands{
 				Name:  "for",
 				Usage: "return events for this long after the from time (when for is given, to is ignored)",
 			},
+			cli.BoolFlag{
+				Name:  "all, a",
+				Usage: "return all the events, instead of stopping when the search is truncated",
+			},
+			cli.BoolFlag{
+				Name:  "proofs, p",
+				Usage: "verify the proof that each event is stored in ByzCoin",
+			},
 		},
 		Action: search,
 	},
+	archiveCommand,
 	{
 		Name:    "key",
 		Usage:   "generates a new keypair and prints the public key in the stdout",
@@ -311,7 +343,14 @@ func create(c *cli.Context) error {
 		cl.DarcID = darc.ID(eb)
 	}
 
-	err = cl.Create()
+	var r *eventlog.Retention
+	if c.Duration("max-age") != 0 || c.Int64("max-events") != 0 {
+		r = &eventlog.Retention{
+			MaxAge:    int64(c.Duration("max-age")),
+			MaxEvents: c.Int64("max-events"),
+		}
+	}
+	err = cl.CreateWithRetention(r)
 	if err != nil {
 		return err
 	}
@@ -338,17 +377,26 @@ func doLog(c *cli.Context) error {
 	t := c.String("topic")
 	content := c.String("content")
 	w := c.Int("wait")
+	fields, err := parseFields(c.StringSlice("field"))
+	if err != nil {
+		return err
+	}
+	newEvent := func(content string) eventlog.Event {
+		ev := eventlog.NewEvent(t, content)
+		ev.Fields = fields
+		return ev
+	}
 
 	// Content is set, so one shot log.
 	if content != "" {
-		_, err := cl.LogAndWait(w, eventlog.NewEvent(t, content))
+		_, err := cl.LogAndWait(w, newEvent(content))
 		return err
 	}
 
 	// Content is empty, so read from stdin.
 	s := bufio.NewScanner(os.Stdin)
 	for s.Scan() {
-		_, err := cl.LogAndWait(w, eventlog.NewEvent(t, s.Text()))
+		_, err := cl.LogAndWait(w, newEvent(s.Text()))
 		if err != nil {
 			return err
 		}
@@ -356,6 +404,19 @@ func doLog(c *cli.Context) error {
 	return bcadminlib.WaitPropagation(c, cl.ByzCoin)
 }
 
+// parseFields parses fields given as key=value.
+func parseFields(in []string) ([]eventlog.Field, error) {
+	var fields []eventlog.Field
+	for _, f := range in {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("field %q is not key=value", f)
+		}
+		fields = append(fields, eventlog.Field{Key: kv[0], Value: kv[1]})
+	}
+	return fields, nil
+}
+
 var none = time.Unix(0, 0)
 
 // parseTime will accept either dates or "X ago" where X is a duration.
@@ -376,8 +437,17 @@ func parseTime(in string) (time.Time, error) {
 }
 
 func search(c *cli.Context) error {
+	fields, err := parseFields(c.StringSlice("field"))
+	if err != nil {
+		return err
+	}
 	req := &eventlog.SearchRequest{
-		Topic: c.String("topic"),
+		Topic:         c.String("topic"),
+		TopicMatch:    c.String("match"),
+		Content:       c.String("grep"),
+		ContentRegexp: c.String("regexp"),
+		Fields:        fields,
+		Proofs:        c.Bool("proofs"),
 	}
 
 	f := c.String("from")
@@ -419,26 +489,38 @@ func search(c *cli.Context) error {
 	}
 	cl.Instance = byzcoin.NewInstanceID(eb)
 
-	resp, err := cl.Search(req)
-	if err != nil {
-		return err
-	}
-
+	// errStop stops following the cursors of the search.
+	errStop := errors.New("stop")
+	all := c.Bool("all")
 	ct := c.Int("count")
+	truncated := false
+	err = cl.SearchAll(req, func(resp *eventlog.SearchResponse) error {
+		truncated = resp.Truncated
+		for _, x := range resp.Events {
+			const tsFormat = "2006-01-02 15:04:05"
+			line := fmt.Sprintf("%v\t%v\t%v", time.Unix(0, x.When).Format(tsFormat), x.Topic, x.Content)
+			for _, f := range x.Fields {
+				line += fmt.Sprintf("\t%v=%v", f.Key, f.Value)
+			}
+			log.Info(line)
 
-	for _, x := range resp.Events {
-		const tsFormat = "2006-01-02 15:04:05"
-		log.Infof("%v\t%v\t%v", time.Unix(0, x.When).Format(tsFormat), x.Topic, x.Content)
-
-		if ct != 0 {
-			ct--
-			if ct == 0 {
-				break
+			if ct != 0 {
+				ct--
+				if ct == 0 {
+					return errStop
+				}
 			}
 		}
+		if !all {
+			return errStop
+		}
+		return nil
+	})
+	if err != nil && err != errStop {
+		return err
 	}
 
-	if resp.Truncated {
+	if truncated {
 		return cli.NewExitError("", 1)
 	}
 	return nil
@@ -641,8 +723,6 @@ func load() (*openidCfg, error) {
 func (o *openidCfg) getSigners(cl *eventlog.Client) ([]darc.Signer, error) {
 	ts := o.Config.TokenSource(context.Background(), &o.Token)
 	r := cl.ByzCoin.Roster
-	n := len(r.List)
-	T := threshold(n)
 
 	// The callback from darc.Sign where we need to go contact the Authentication Proxies.
 	cb := func(msg []byte) ([]byte, error) {
@@ -660,96 +740,22 @@ func (o *openidCfg) getSigners(cl *eventlog.Client) ([]darc.Signer, error) {
 			return nil, errors.New("no id_token in token response")
 		}
 
-		// Make the random shares for the signature.
-		rPri := share.NewPriPoly(cothority.Suite, T, nil, cothority.Suite.RandomStream())
-		rShares := rPri.Shares(n)
-		rPub := rPri.Commit(nil)
-		_, rPubCommits := rPub.Info()
-
-		// Make a shuffled list of server id's to contact.
-		shuffled := rand.Perm(len(r.List))
-		client := onet.NewClient(cothority.Suite, authprox.ServiceName)
-
-		// Connect to servers, get sigs, stop when we achieve the threshold.
-		wg := &sync.WaitGroup{}
-		wg.Add(len(r.List))
-		pChan := make(chan *share.PriShare, len(r.List))
-		for _, i := range shuffled {
-			go func(idx int) {
-				s := r.List[idx]
-
-				rpi := authprox.PriShare{
-					I: rShares[idx].I,
-					V: rShares[idx].V,
-				}
-				req := &authprox.SignatureRequest{
-					Type:     "oidc",
-					Issuer:   o.Issuer,
-					AuthInfo: []byte(rawIDToken),
-					Message:  msg,
-					RandPri:  rpi,
-					RandPubs: rPubCommits,
-				}
-				var resp authprox.SignatureResponse
-				err := client.SendProtobuf(s, req, &resp)
-
-				// If no error keep this partial. Otherwise keep going until we have enough.
-				if err == nil {
-					// Check the sig on the partial sig before trusting it.
-					ps := &dss.PartialSig{
-						Partial: &share.PriShare{
-							I: resp.PartialSignature.Partial.I,
-							V: resp.PartialSignature.Partial.V,
-						},
-						SessionID: resp.PartialSignature.SessionID,
-						Signature: resp.PartialSignature.Signature,
-					}
-					err = schnorr.Verify(cothority.Suite, s.Public, ps.Hash(cothority.Suite), ps.Signature)
-					if err == nil {
-						pChan <- ps.Partial
-					} else {
-						log.Warnf("got an incorrectly signed partial signature from %v: %v", s, err)
-					}
-				} else {
-					log.Warnf("could not get a partial signature from %v: %v", s, err)
-				}
-
-				wg.Done()
-			}(i)
-		}
-
-		// TODO: In a perfect world, we'd be using an http.Client
-		// with a context, and then once we got enough signatures, we'd
-		// kill the outstanding requests. But for now, we will wait until
-		// they all timeout, even if we've already got enough.
-		wg.Wait()
-		close(pChan)
-
-		// TODO: If we "got enough", but in fact we talked to a dishonest signer
-		// who sent us an incorrect signature, we won't know it until we reconstruct
-		// the sig and then our txn is refused. The brute force method would be to get
-		// sigs from all, then remove one sig at a time and retry as long as the
-		// reconstructed sig is invalid. A nicer way would be for the signer to
-		// send back some proof that it faithfully did the signature.
-		var partials []*share.PriShare
-		for p := range pChan {
-			partials = append(partials, p)
+		// The proxies generate the nonce of the signature among
+		// themselves, and the first one combines their partial
+		// signatures.
+		req := &authprox.SignatureRequest{
+			Type:     "oidc",
+			Issuer:   o.Issuer,
+			AuthInfo: []byte(rawIDToken),
+			Message:  msg,
+			Roster:   r,
 		}
-		if len(partials) < T {
-			return nil, errors.New("not enough partial signatures")
-		}
-
-		gamma, err := share.RecoverSecret(cothority.Suite, partials, T, n)
-		if err != nil {
+		client := onet.NewClient(cothority.Suite, authprox.ServiceName)
+		var resp authprox.SignatureResponse
+		if err := client.SendProtobuf(r.List[0], req, &resp); err != nil {
 			return nil, err
 		}
-
-		// RandomPublic || gamma
-		var buff bytes.Buffer
-		_, _ = rPub.Commit().MarshalTo(&buff)
-		_, _ = gamma.MarshalTo(&buff)
-		sig := buff.Bytes()
-		return sig, nil
+		return resp.Signature, nil
 	}
 
 	s := darc.NewSignerProxy(o.Data, o.Public, cb)
@@ -819,11 +825,3 @@ func key(c *cli.Context) error {
 	_, err = fmt.Fprintln(fo, newSigner.Identity().String())
 	return err
 }
-
-func faultThreshold(n int) int {
-	return (n - 1) / 3
-}
-
-func threshold(n int) int {
-	return n - faultThreshold(n)
-}
@@ -27,7 +27,7 @@ main(){
 
 testEventLog(){
 	##### setup phase
-	rm -f *.cfg
+	rm -f *.cfg archive.bin run.bin
 	runCoBG 1 2 3
 	runGrepSed "export BC=" "" ./bcadmin -c . create --roster public.toml --interval .5s
 	eval "$SED"
@@ -39,6 +39,8 @@ testEventLog(){
 	testOK ./bcadmin -c . darc rule -rule spawn:eventlog -identity "$KEY"
 	./bcadmin debug counters bc*cfg key*cfg
 	testOK ./bcadmin -c . darc rule -rule invoke:eventlog.log -identity "$KEY"
+	testOK ./bcadmin -c . darc rule -rule invoke:eventlog.retention -identity "$KEY"
+	testOK ./bcadmin -c . darc rule -rule invoke:eventlog.archive -identity "$KEY"
 
 	runGrepSed "export EL=" "" $el create -sign "$KEY"
 	eval "$SED"
@@ -57,6 +59,36 @@ testEventLog(){
 	# The first form of relative date is for MacOS, the second for Linux.
 	testCountLines 0 $el search -t test -from '1h ago' -to `date -v -1d +%Y-%m-%d || date -d yesterday +%Y-%m-%d`
 	testCountLines 1 $el search -t test -to `date -v +1d +%Y-%m-%d || date -d tomorrow +%Y-%m-%d`
+
+	testOK $el log -t auth.login -c 'alice logged in' -f user=alice -f host=a -w 10 -sign "$KEY"
+	testOK $el log -t auth.logout -c 'alice logged out' -f user=alice -w 10 -sign "$KEY"
+	testFail $el log -t auth.login -c 'bad field' -f user -sign "$KEY"
+	testCountLines 2 $el search -m 'auth.*'
+	testCountLines 1 $el search -m 'seq*' -g 10
+	testCountLines 2 $el search -r '^[0-9]$' -t seq100 -from '1h ago' -c 2
+	testCountLines 2 $el search -f user=alice
+	testGrep "host=a" $el search -f user=alice -f host=a
+	testCountLines 0 $el search -t auth.logout -f host=a
+
+	testCountLines 15 $el search -proofs
+	testCountLines 15 $el search -all -proofs -m '*'
+	testCountLines 3 $el search -all -t seq100 -count 3
+
+	testFail $el archive run -sign "$KEY" run.bin
+	testOK $el archive policy -max-events 1 -sign "$KEY"
+	testCountLines 0 $el archive list
+	# Wait for the next event to be in a new bucket.
+	sleep 6
+	testOK $el log -t last -c 'last event' -w 10 -sign "$KEY"
+	testFail $el archive run -sign "$KEY"
+	testGrep "alice logged in" $el archive run -sign "$KEY" run.bin
+	testFile run.bin
+	testCountLines 1 $el archive list
+	testCountLines 1 $el search -all
+	testGrep "last event" $el search -all
+	testGrep "alice logged in" $el archive export archive.bin
+	testFile archive.bin
+	testFail $el archive export -n 1 archive.bin
 }
 
 main
@@ -0,0 +1,312 @@
+package eventlog
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/protobuf"
+)
+
+// The secondary indexes of an eventlog map a topic, or the value of a field,
+// to the events that have it. The head of each index is stored at an instance
+// derived from the eventlog and the indexed value. When the head is full, its
+// content is moved to a new instance and the head links back to it.
+//
+// Eventlogs created before the indexes existed only index the events logged
+// after their first indexed event, so the eventlog also stores the time from
+// which its indexes are complete.
+
+// indexMax is the maximum number of events in one chunk of an index. Logging
+// an event rewrites the heads of up to maxEventFields+1 indexes, so the heads
+// are kept small, and the full ones are moved to their own instance, which
+// is never written again.
+const indexMax = 32
+
+// indexMargin is how far apart in time two events can be logged out of order:
+// an event can be 30 seconds in the past and 5 seconds in the future when it
+// is logged.
+const indexMargin = 35 * time.Second
+
+// maxEventFields is the maximum number of fields of an event.
+const maxEventFields = 16
+
+type index struct {
+	Prev      []byte
+	EventRefs [][]byte
+	Whens     []int64
+}
+
+func (i index) isFirst() bool {
+	return len(i.Prev) == 0
+}
+
+// topicKey returns the key of the index of a topic.
+func topicKey(topic string) string {
+	return "topic\x00" + topic
+}
+
+// fieldKey returns the key of the index of the value of a field.
+func fieldKey(f Field) string {
+	return "field\x00" + f.Key + "\x00" + f.Value
+}
+
+// indexKeys returns the keys of all the indexes of the event.
+func (e Event) indexKeys() []string {
+	keys := []string{topicKey(e.Topic)}
+	for _, f := range e.Fields {
+		keys = append(keys, fieldKey(f))
+	}
+	return keys
+}
+
+// indexID returns the instance ID of the head of an index of the eventlog.
+func indexID(inst byzcoin.InstanceID, key string) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write(inst.Slice())
+	h.Write([]byte("index\x00"))
+	h.Write([]byte(key))
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// indexStartID returns the instance ID holding the time from which the
+// indexes of the eventlog are complete.
+func indexStartID(inst byzcoin.InstanceID) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write(inst.Slice())
+	h.Write([]byte("index-start"))
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+func encodeTime(t int64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(t))
+	return buf
+}
+
+// getValue returns the value of the instance, or nil if it doesn't exist.
+func getValue(v byzcoin.ReadOnlyStateTrie, key []byte) ([]byte, error) {
+	p, err := v.GetProof(key)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := p.Exists(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	v0, _, _, _, err := v.GetValues(key)
+	return v0, err
+}
+
+func (e eventLog) getIndex(id []byte) (*index, error) {
+	buf, err := getValue(e.v, id)
+	if err != nil || buf == nil {
+		return nil, err
+	}
+	var i index
+	if err := protobuf.Decode(buf, &i); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// getIndexStart returns the time from which the indexes of the eventlog are
+// complete, and false if nothing has been indexed yet.
+func (e eventLog) getIndexStart() (int64, bool, error) {
+	buf, err := getValue(e.v, indexStartID(e.Instance).Slice())
+	if err != nil || buf == nil {
+		return 0, false, err
+	}
+	if len(buf) != 8 {
+		return 0, false, errors.New("wrong length of the index start")
+	}
+	return int64(binary.LittleEndian.Uint64(buf)), true, nil
+}
+
+// indexEvent returns the state changes adding the event to the indexes of
+// the eventlog.
+func (e eventLog) indexEvent(inst byzcoin.Instruction, event *Event, eventID []byte, darcID darc.ID) ([]byzcoin.StateChange, error) {
+	var sc []byzcoin.StateChange
+	if _, ok, err := e.getIndexStart(); err != nil {
+		return nil, err
+	} else if !ok {
+		// The eventlog has been created before the indexes: older events
+		// can't be older than this one by more than indexMargin.
+		start := event.When + int64(indexMargin)
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Create,
+			indexStartID(e.Instance), contractName, encodeTime(start), darcID))
+	}
+
+	for _, key := range event.indexKeys() {
+		id := indexID(e.Instance, key)
+		head, err := e.getIndex(id.Slice())
+		if err != nil {
+			return nil, err
+		}
+		action := byzcoin.Update
+		if head == nil {
+			action = byzcoin.Create
+			head = &index{}
+		} else if len(head.EventRefs) >= indexMax {
+			// Move the full head to its own instance.
+			prevID := inst.DeriveID("index\x00" + key)
+			buf, err := protobuf.Encode(head)
+			if err != nil {
+				return nil, err
+			}
+			sc = append(sc, byzcoin.NewStateChange(byzcoin.Create, prevID,
+				contractName, buf, darcID))
+			head = &index{Prev: prevID.Slice()}
+		}
+		head.EventRefs = append(head.EventRefs, eventID)
+		head.Whens = append(head.Whens, event.When)
+		buf, err := protobuf.Encode(head)
+		if err != nil {
+			return nil, err
+		}
+		sc = append(sc, byzcoin.NewStateChange(action, id, contractName, buf,
+			darcID))
+	}
+	return sc, nil
+}
+
+// searchIndex returns the references to the events of the index that are in
+// the time range of the request, sorted by time, and the links from the
+// eventlog to the events: the IDs of the chunks of the index, from the head to
+// the oldest one. It returns false if the index can't be used for the
+// request.
+func (e eventLog) searchIndex(req *SearchRequest) ([]eventRef, [][]byte, bool, error) {
+	var key string
+	switch {
+	case req.Topic != "":
+		key = topicKey(req.Topic)
+	case len(req.Fields) > 0:
+		key = fieldKey(req.Fields[0])
+	default:
+		return nil, nil, false, nil
+	}
+	start, ok, err := e.getIndexStart()
+	if err != nil || !ok || req.From < start {
+		return nil, nil, false, err
+	}
+
+	type ref struct {
+		eventRef
+		when int64
+	}
+	var refs []ref
+	id := indexID(e.Instance, key).Slice()
+	var links [][]byte
+	i, err := e.getIndex(id)
+	for i != nil && err == nil {
+		links = append(links, id)
+		// The events of older chunks have been logged before the events of
+		// this one, so once a chunk ends before the search range, the older
+		// ones do too.
+		last := int64(0)
+		for j, eid := range i.EventRefs {
+			when := i.Whens[j]
+			if when > last {
+				last = when
+			}
+			if req.From <= when && when < req.To {
+				refs = append(refs, ref{eventRef{
+					id:     eid,
+					cursor: cursor{Event: eid},
+					link:   len(links) - 1,
+				}, when})
+			}
+		}
+		if i.isFirst() || last < req.From-int64(indexMargin) {
+			break
+		}
+		id = i.Prev
+		i, err = e.getIndex(id)
+		if err == nil && i == nil {
+			err = errors.New("missing chunk of the index")
+		}
+	}
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	sort.SliceStable(refs, func(a, b int) bool {
+		return refs[a].when < refs[b].when
+	})
+	found := make([]eventRef, len(refs))
+	for j := range refs {
+		found[j] = refs[j].eventRef
+	}
+	return found, links, true, nil
+}
+
+// query matches events against the filters of a SearchRequest, except for
+// the time range.
+type query struct {
+	req     *SearchRequest
+	topic   *regexp.Regexp
+	content *regexp.Regexp
+}
+
+func newQuery(req *SearchRequest) (*query, error) {
+	q := &query{req: req}
+	if req.TopicMatch != "" {
+		pattern := regexp.QuoteMeta(req.TopicMatch)
+		pattern = strings.Replace(pattern, `\*`, ".*", -1)
+		pattern = strings.Replace(pattern, `\?`, ".", -1)
+		q.topic = regexp.MustCompile("^(?s:" + pattern + ")$")
+	}
+	if req.ContentRegexp != "" {
+		var err error
+		q.content, err = regexp.Compile(req.ContentRegexp)
+		if err != nil {
+			return nil, errors.New("invalid content regexp: " + err.Error())
+		}
+	}
+	return q, nil
+}
+
+func (q *query) match(ev *Event) bool {
+	if q.req.Topic != "" && q.req.Topic != ev.Topic {
+		return false
+	}
+	if q.topic != nil && !q.topic.MatchString(ev.Topic) {
+		return false
+	}
+	if q.req.Content != "" && !strings.Contains(ev.Content, q.req.Content) {
+		return false
+	}
+	if q.content != nil && !q.content.MatchString(ev.Content) {
+		return false
+	}
+	for _, f := range q.req.Fields {
+		if v, ok := ev.Field(f.Key); !ok || v != f.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// checkFields returns an error if the fields of the event can't be indexed.
+func (e Event) checkFields() error {
+	if len(e.Fields) > maxEventFields {
+		return errors.New("too many fields in the event")
+	}
+	keys := make(map[string]bool)
+	for _, f := range e.Fields {
+		if f.Key == "" {
+			return errors.New("empty field key")
+		}
+		if keys[f.Key] {
+			return errors.New("duplicate field key " + f.Key)
+		}
+		keys[f.Key] = true
+	}
+	return nil
+}
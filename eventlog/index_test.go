@@ -0,0 +1,43 @@
+package eventlog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery_Match(t *testing.T) {
+	ev := &Event{Topic: "auth/login", Content: "user alice\nlogged in",
+		Fields: []Field{{"user", "alice"}, {"host", "a"}}}
+
+	match := func(req SearchRequest) bool {
+		q, err := newQuery(&req)
+		require.NoError(t, err)
+		return q.match(ev)
+	}
+	require.True(t, match(SearchRequest{}))
+	require.True(t, match(SearchRequest{Topic: "auth/login"}))
+	require.False(t, match(SearchRequest{Topic: "auth"}))
+	require.True(t, match(SearchRequest{TopicMatch: "auth*"}))
+	require.True(t, match(SearchRequest{TopicMatch: "*/log?n"}))
+	require.False(t, match(SearchRequest{TopicMatch: "auth"}))
+	require.False(t, match(SearchRequest{TopicMatch: "auth.*"}))
+	require.True(t, match(SearchRequest{Content: "alice"}))
+	require.False(t, match(SearchRequest{Content: "bob"}))
+	require.True(t, match(SearchRequest{ContentRegexp: "(?m)^logged"}))
+	require.False(t, match(SearchRequest{ContentRegexp: "^logged"}))
+	require.True(t, match(SearchRequest{Fields: []Field{{"host", "a"}, {"user", "alice"}}}))
+	require.False(t, match(SearchRequest{Fields: []Field{{"user", "bob"}}}))
+	require.False(t, match(SearchRequest{Fields: []Field{{"group", ""}}}))
+
+	_, err := newQuery(&SearchRequest{ContentRegexp: "["})
+	require.Error(t, err)
+}
+
+func TestEvent_CheckFields(t *testing.T) {
+	require.NoError(t, Event{}.checkFields())
+	require.NoError(t, Event{Fields: []Field{{"a", ""}, {"b", "1"}}}.checkFields())
+	require.Error(t, Event{Fields: []Field{{"", "1"}}}.checkFields())
+	require.Error(t, Event{Fields: []Field{{"a", "1"}, {"a", "2"}}}.checkFields())
+	require.Error(t, Event{Fields: make([]Field, maxEventFields+1)}.checkFields())
+}
@@ -25,11 +25,22 @@ func NewEvent(topic, content string) Event {
 	}
 }
 
+// Field returns the value of the field of the event with the given key.
+func (e Event) Field(key string) (string, bool) {
+	for _, f := range e.Fields {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
 // PROTOSTART
 // type :skipchain.SkipBlockID:bytes
 // type :byzcoin.InstanceID:bytes
 //
 // package eventlog;
+// import "byzcoin.proto";
 //
 // option java_package = "ch.epfl.dedis.lib.proto";
 // option java_outer_classname = "EventLogProto";
@@ -41,7 +52,8 @@ func NewEvent(topic, content string) Event {
 // SearchRequest includes all the search parameters (AND of all provided search
 // parameters). Topic == "" means "any topic". From == 0 means "from the first
 // event", and To == 0 means "until now". From and To should be set using the
-// UnixNano() method in package time.
+// UnixNano() method in package time. Searches on Topic or Fields use the
+// indexes of the eventlog, the other parameters only filter the results.
 type SearchRequest struct {
 	Instance byzcoin.InstanceID
 	ID       skipchain.SkipBlockID
@@ -51,15 +63,43 @@ type SearchRequest struct {
 	From int64
 	// Return events where When is <= To.
 	To int64
+	// Return events where Topic matches this pattern, if TopicMatch != "".
+	// A '*' matches any sequence of characters, and a '?' any character,
+	// so "auth.*" matches all the topics starting with "auth.".
+	TopicMatch string `protobuf:"opt"`
+	// Return events where Content contains this string, if Content != "".
+	Content string `protobuf:"opt"`
+	// Return events where Content matches this regular expression, if
+	// ContentRegexp != "". The syntax is the one of package regexp.
+	ContentRegexp string `protobuf:"opt"`
+	// Return events having all of these fields.
+	Fields []Field `protobuf:"opt"`
+	// Cursor continues a truncated search, and must be the Cursor of its
+	// SearchResponse. The other parameters must not change between the two
+	// searches.
+	Cursor []byte `protobuf:"opt"`
+	// Proofs asks for a proof of each event found. Fewer events are returned
+	// at once when the proofs are requested.
+	Proofs bool `protobuf:"opt"`
 }
 
 // SearchResponse is the reply to LogRequest.
 type SearchResponse struct {
 	Events []Event
-	// Events does not contain all the results. The caller should formulate
-	// a new SearchRequest to continue searching, for instance by setting
-	// From to the time of the last received event.
+	// Events does not contain all the results. The caller should send the
+	// same SearchRequest with Cursor set to continue searching.
 	Truncated bool
+	// Cursor is set if the search is truncated, and points to the next
+	// event found.
+	Cursor []byte `protobuf:"opt"`
+	// Proofs holds the proof that each event is stored in ByzCoin, if they
+	// were requested: Proofs[i] is the proof of Events[i].
+	Proofs []byzcoin.Proof `protobuf:"opt"`
+	// Links holds, if the proofs were requested, the proofs of the
+	// instances tying the events to the eventlog: the eventlog and its
+	// buckets from the latest one on, or the chunks of the searched index
+	// from its head on, down to the oldest one referencing an event.
+	Links []byzcoin.Proof `protobuf:"opt"`
 }
 
 // Event is sent to create an event log. When should be set using the UnixNano() method
@@ -68,4 +108,48 @@ type Event struct {
 	When    int64
 	Topic   string
 	Content string
+	// Fields are structured key/value pairs of the event. Each key can only
+	// appear once. Events can be searched by the value of their fields.
+	Fields []Field `protobuf:"opt"`
+}
+
+// Field is a key/value pair of an event.
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Retention is the retention policy of an eventlog. The events that expire
+// are removed from the eventlog by the "archive" command, which only keeps a
+// commitment to them. The policy applies to whole buckets of events, so a
+// few more events than required might be kept.
+type Retention struct {
+	// MaxAge is the time in nanoseconds after which events expire, 0 if
+	// they don't expire with time.
+	MaxAge int64 `protobuf:"opt"`
+	// MaxEvents is the number of latest events to keep, 0 if there is no
+	// limit.
+	MaxEvents int64 `protobuf:"opt"`
+}
+
+// Archive is the commitment to the events removed from an eventlog by one
+// "archive" command.
+type Archive struct {
+	// EventLog is the instance ID of the eventlog.
+	EventLog byzcoin.InstanceID
+	// Prev is the ID of the previous archive of the eventlog, empty for the
+	// first archive.
+	Prev []byte `protobuf:"opt"`
+	// Commit is the head of the hash chain of the archived events. For
+	// every event, from the oldest to the latest, the chain is extended
+	// with sha256(chain | event ID | event). It starts with the Commit of
+	// the previous archive.
+	Commit []byte
+	// Count is the number of archived events.
+	Count int64
+	// Index is the index of the last block whose state holds the archived
+	// events.
+	Index int
+	// Bucket is the ID of the latest archived bucket.
+	Bucket []byte
 }
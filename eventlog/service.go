@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/protobuf"
@@ -18,6 +19,8 @@ var sid onet.ServiceID
 
 const contractName = "eventlog"
 const logCmd = "log"
+const retentionCmd = "retention"
+const archiveCmd = "archive"
 
 // Set a relatively low time for bucketMaxAge: during peak message arrival
 // this will pretect the buckets from getting too big. During low message
@@ -47,9 +50,13 @@ type Service struct {
 
 const defaultBlockInterval = 5 * time.Second
 
-// This should be a const, but we want to be able to hack it from tests.
+// These should be consts, but we want to be able to hack them from tests.
 var searchMax = 10000
 
+// searchMaxProofs limits the number of events of a search with proofs,
+// because every proof holds the latest block and the links to it.
+var searchMaxProofs = 100
+
 // Search will search the event log for matching entries.
 func (s *Service) Search(req *SearchRequest) (*SearchResponse, error) {
 	if req.ID.IsNull() {
@@ -65,6 +72,10 @@ func (s *Service) Search(req *SearchRequest) (*SearchResponse, error) {
 		return nil, err
 	}
 	el := &eventLog{Instance: req.Instance, v: v}
+	q, err := newQuery(req)
+	if err != nil {
+		return nil, err
+	}
 
 	id, b, err := el.getLatestBucket()
 	if err != nil {
@@ -75,74 +86,100 @@ func (s *Service) Search(req *SearchRequest) (*SearchResponse, error) {
 		return &SearchResponse{}, nil
 	}
 
-	// bEnd is normally updated from the last bucket's start. For the latest
-	// bucket, bEnd is now.
-	bEnd := time.Now().UnixNano()
-
-	// Walk backwards in the bucket chain through 2 zones: first where the
-	// bucket covers time that is not in our search range, and then where the buckets
-	// do cover the search range. When we see a bucket that ends before our search
-	// range, we can stop walking buckets.
-	var buckets []*bucket
-	var bids [][]byte
-	for {
-		if req.From > bEnd {
-			// This bucket is before the search range, so we are done walking back the bucket chain.
-			break
+	refs, links, ok, err := el.searchIndex(req)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		refs, links, err = el.searchBuckets(req, id, b)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		if req.To < b.Start {
-			// This bucket is after the search range, so we do not add it to buckets, but
-			// we keep walking up the chain.
-		} else {
-			buckets = append(buckets, b)
-			bids = append(bids, id)
+	// Skip the events returned by the previous searches.
+	if len(req.Cursor) > 0 {
+		var c cursor
+		if err := protobuf.Decode(req.Cursor, &c); err != nil {
+			return nil, errors.New("invalid cursor: " + err.Error())
 		}
-
-		if b.isFirst() {
-			break
+		i := 0
+		for i < len(refs) && !refs[i].cursor.equal(c) {
+			i++
 		}
-		bEnd = b.Start
-		id = b.Prev
-		b, err = el.getBucketByID(id)
-		if err != nil {
-			// This indicates that the event log data structure is wrong, so
-			// we cannot claim to correctly search it. Give up instead.
-			log.Errorf("expected event log bucket id %v not found: %v", string(id), err)
-			return nil, err
+		if i == len(refs) {
+			return nil, errors.New("cursor is not in the search range")
 		}
+		refs = refs[i:]
 	}
 
+	max := searchMax
+	if req.Proofs && max > searchMaxProofs {
+		max = searchMaxProofs
+	}
 	reply := &SearchResponse{}
-
-	// Process the time buckets from earliest to latest so that
-	// if we truncate, it is the latest events that are not returned,
-	// so that they can set req.From = resp.Events[len(resp.Events)-1].When.
-filter:
-	for i := len(buckets) - 1; i >= 0; i-- {
-		b := buckets[i]
-		for _, e := range b.EventRefs {
-			ev, err := getEventByID(v, e)
+	// lastLink is the position of the oldest link needed to tie the events
+	// to the eventlog.
+	lastLink := -1
+	for _, ref := range refs {
+		ev, err := getEventByID(v, ref.id)
+		if err != nil {
+			if ok {
+				// The indexes still point to the archived events, so only
+				// give up if the event is there but can't be read.
+				if buf, err := getValue(v, ref.id); err == nil && buf == nil {
+					continue
+				}
+			}
+			log.Errorf("eventlog points to event %x, but the event was not found: %v", ref.id, err)
+			return nil, err
+		}
+		if ev.When < req.From || ev.When >= req.To || !q.match(ev) {
+			continue
+		}
+		if len(reply.Events) >= max {
+			reply.Truncated = true
+			reply.Cursor, err = protobuf.Encode(&ref.cursor)
 			if err != nil {
-				log.Errorf("bucket %x points to event %x, but the event was not found: %v", bids[i], e, err)
 				return nil, err
 			}
-
-			if req.From <= ev.When && ev.When < req.To {
-				if req.Topic == "" || req.Topic == ev.Topic {
-					reply.Events = append(reply.Events, *ev)
-					if len(reply.Events) >= searchMax {
-						reply.Truncated = true
-						break filter
-					}
-				}
+			break
+		}
+		reply.Events = append(reply.Events, *ev)
+		if req.Proofs {
+			p, err := s.getProof(req.ID, ref.id)
+			if err != nil {
+				return nil, err
+			}
+			reply.Proofs = append(reply.Proofs, *p)
+			if ref.link > lastLink {
+				lastLink = ref.link
 			}
 		}
 	}
+	for _, link := range links[:lastLink+1] {
+		p, err := s.getProof(req.ID, link)
+		if err != nil {
+			return nil, err
+		}
+		reply.Links = append(reply.Links, *p)
+	}
 
 	return reply, nil
 }
 
+func (s *Service) getProof(scID skipchain.SkipBlockID, key []byte) (*byzcoin.Proof, error) {
+	p, err := s.omni.GetProof(&byzcoin.GetProof{
+		Version: byzcoin.CurrentVersion,
+		Key:     key,
+		ID:      scID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p.Proof, nil
+}
+
 func decodeAndCheckEvent(coll byzcoin.ReadOnlyStateTrie, eventBuf []byte) (*Event, error) {
 	// Check the timestamp of the event: it should never be in the future,
 	// and it should not be more than 30 seconds in the past. (Why 30 sec
@@ -165,6 +202,9 @@ func decodeAndCheckEvent(coll byzcoin.ReadOnlyStateTrie, eventBuf []byte) (*Even
 	if when.After(now.Add(5 * time.Second)) {
 		return nil, errors.New("event timestamp is too far in the future")
 	}
+	if err := event.checkFields(); err != nil {
+		return nil, err
+	}
 	return event, nil
 }
 
@@ -179,8 +219,27 @@ func (c *contract) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instructio
 	if cid != contractName {
 		return nil, nil, fmt.Errorf("expected contract ID to be \"%s\" but got \"%s\"", contractName, cid)
 	}
-	if inst.Invoke.Command != logCmd {
-		return nil, nil, fmt.Errorf("invalid command, got \"%s\" but need \"%s\"", inst.Invoke.Command, logCmd)
+
+	el := &eventLog{Instance: inst.InstanceID, v: rst}
+	switch inst.Invoke.Command {
+	case logCmd:
+	case retentionCmd:
+		buf := inst.Invoke.Args.Search("retention")
+		if buf == nil {
+			return nil, nil, errors.New("expected a named argument of \"retention\"")
+		}
+		sc, err = el.setRetention(buf, darcID)
+		return
+	case archiveCmd:
+		tr, ok := rst.(byzcoin.TimeReader)
+		if !ok {
+			return nil, nil, errors.New("internal error: cannot read the block time")
+		}
+		sc, err = el.archive(inst, tr.GetCurrentBlockTimestamp(), darcID)
+		return
+	default:
+		return nil, nil, fmt.Errorf("invalid command, got \"%s\" but need \"%s\", \"%s\" or \"%s\"",
+			inst.Invoke.Command, logCmd, retentionCmd, archiveCmd)
 	}
 
 	eventBuf := inst.Invoke.Args.Search("event")
@@ -199,6 +258,12 @@ func (c *contract) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instructio
 
 	sc = append(sc, byzcoin.NewStateChange(byzcoin.Create, eventID, cid, eventBuf, darcID))
 
+	isc, err := el.indexEvent(inst, event, eventID.Slice(), darcID)
+	if err != nil {
+		return nil, nil, err
+	}
+	sc = append(sc, isc...)
+
 	// Walk from latest bucket back towards beginning looking for the right bucket.
 	//
 	// If you don't find a bucket with b.Start <= ev.When,
@@ -212,7 +277,6 @@ func (c *contract) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instructio
 	// For now: buckets are allowed to grow as big as needed (but the previous
 	// rule prevents buckets from getting too big by timing them out).
 
-	el := &eventLog{Instance: inst.InstanceID, v: rst}
 	bID, b, err := el.getLatestBucket()
 	if err != nil {
 		return nil, nil, err
@@ -302,9 +366,23 @@ func (c *contract) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction
 	// Store c.iid as the pointer to the first bucket. It will in fact be all zeros,
 	// because during spawning, ByzCoin passes a zero-length slice to the new contract factory.
 	// In invoke we'll detect that the first bucket does not exist and do the necessary.
-	return []byzcoin.StateChange{
-		byzcoin.NewStateChange(byzcoin.Create, inst.DeriveID(""), contractName, c.iid.Slice(), darcID),
-	}, nil, nil
+	// All the events of a new eventlog are indexed.
+	iid := inst.DeriveID("")
+	sc = []byzcoin.StateChange{
+		byzcoin.NewStateChange(byzcoin.Create, iid, contractName, c.iid.Slice(), darcID),
+		byzcoin.NewStateChange(byzcoin.Create, indexStartID(iid), contractName, encodeTime(0), darcID),
+	}
+
+	// The retention policy is optional.
+	if buf := inst.Spawn.Args.Search("retention"); buf != nil {
+		el := &eventLog{Instance: iid, v: rst}
+		rsc, err := el.setRetention(buf, darcID)
+		if err != nil {
+			return nil, nil, err
+		}
+		sc = append(sc, rsc...)
+	}
+	return sc, nil, nil
 }
 
 type contract struct {
@@ -6,6 +6,8 @@ import (
 	"go.dedis.ch/onet/v3"
 
 	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+	"go.dedis.ch/cothority/v3/skipchain"
 )
 
 // ServiceName is the identifier of the service (application name).
@@ -39,3 +41,14 @@ func (c *Client) LookupSciper(roster *onet.Roster, sciper string) (reply *Lookup
 	err = c.SendProtobuf(roster.RandomServerIdentity(), &LookupSciper{Sciper: sciper, LookupURL: c.LookupURL}, reply)
 	return
 }
+
+// ExportTranscript returns the transcript of the election. It must be
+// verified with Transcript.Verify before using it.
+func (c *Client) ExportTranscript(roster *onet.Roster, id skipchain.SkipBlockID) (*lib.Transcript, error) {
+	reply := &ExportTranscriptReply{}
+	err := c.SendProtobuf(roster.RandomServerIdentity(), &ExportTranscript{ID: id}, reply)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Transcript, nil
+}
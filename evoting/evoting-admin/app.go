@@ -5,8 +5,10 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
@@ -14,12 +16,15 @@ import (
 
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/evoting"
+	"go.dedis.ch/cothority/v3/evoting/identity"
+	"go.dedis.ch/cothority/v3/evoting/lib"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/util/key"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/app"
 	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
 )
 
 var (
@@ -35,6 +40,12 @@ var (
 	argDumpElection = flag.Bool("dumpelection", false, "Dump the current election config for the election specified with -id.")
 	argJSON         = flag.Bool("json", false, "Dump in json mode.")
 	argLoad         = flag.String("load", "", "Load the specified json file to modify the election specified with -id.")
+	argIdentity     = flag.String("identity", "", "identity provider of the master chain: epfl (default), csv, oidc or darc")
+	argRoll         = flag.String("roll", "", "path to the CSV roll of voters for the csv, oidc and darc identity providers")
+	argIssuer       = flag.String("issuer", "", "URL of the issuer for the oidc identity provider")
+	argClientID     = flag.String("clientid", "", "client ID the ID tokens must be issued for, for the oidc identity provider")
+	argExport       = flag.String("export", "", "Write the transcript of the election specified with -id to the given file, for evoting-verify.")
+	argTally        = flag.Bool("tally", false, "Show the result of the decrypted election specified with -id.")
 )
 
 func main() {
@@ -63,6 +74,9 @@ func main() {
 		fmt.Printf(" Admins: %v\n", m.Admins)
 		fmt.Printf(" Roster: %v\n", m.Roster.List)
 		fmt.Printf("    Key: %v\n", m.Key)
+		if m.Identity != nil {
+			fmt.Printf("   Identity: %v, %d voters\n", m.Identity.Provider, len(m.Identity.Voters))
+		}
 		return
 	}
 
@@ -102,6 +116,9 @@ func main() {
 				Users:        e.Users,
 				Candidates:   e.Candidates,
 				MaxChoices:   e.MaxChoices,
+				BallotType:   e.BallotType.String(),
+				Seats:        e.Seats,
+				Weights:      e.Weights,
 				Subtitle:     e.Subtitle,
 				MoreInfo:     e.MoreInfo,
 				MoreInfoLang: e.MoreInfoLang,
@@ -127,6 +144,57 @@ func main() {
 		return
 	}
 
+	if *argExport != "" {
+		id, err := hex.DecodeString(*argID)
+		if err != nil {
+			log.Fatal("id decode", err)
+		}
+		transcript, err := evoting.NewClient().ExportTranscript(roster, id)
+		if err != nil {
+			log.Fatal("export transcript request: ", err)
+		}
+		buf, err := network.Marshal(transcript)
+		if err != nil {
+			log.Fatal("cannot encode transcript: ", err)
+		}
+		if err = ioutil.WriteFile(*argExport, buf, 0644); err != nil {
+			log.Fatal("cannot write transcript: ", err)
+		}
+		log.Infof("Wrote %d blocks of election %x to %s", len(transcript.Blocks),
+			id, *argExport)
+		return
+	}
+
+	if *argTally {
+		id, err := hex.DecodeString(*argID)
+		if err != nil {
+			log.Fatal("id decode", err)
+		}
+		client := onet.NewClient(cothority.Suite, evoting.ServiceName)
+		box := &evoting.GetBoxReply{}
+		if err = client.SendProtobuf(roster.List[0], &evoting.GetBox{ID: id}, box); err != nil {
+			log.Fatal("get box request: ", err)
+		}
+		reply := &evoting.ReconstructReply{}
+		if err = client.SendProtobuf(roster.List[0], &evoting.Reconstruct{ID: id}, reply); err != nil {
+			log.Fatal("reconstruct request: ", err)
+		}
+		// Count again locally, so that the result doesn't depend on the
+		// tally computed by the leader.
+		tally := box.Election.Tally(reply.Points)
+
+		if *argJSON {
+			b, err := json.MarshalIndent(tally, "", "\t")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(b))
+		} else {
+			printTally(box.Election, tally)
+		}
+		return
+	}
+
 	if *argLoad != "" {
 		id, err := hex.DecodeString(*argID)
 		if err != nil {
@@ -158,6 +226,12 @@ func main() {
 		e.Name = j.Name
 		e.Candidates = j.Candidates
 		e.MaxChoices = j.MaxChoices
+		e.BallotType, err = parseBallotType(j.BallotType)
+		if err != nil {
+			log.Fatal("cannot parse ballot type:", err)
+		}
+		e.Seats = j.Seats
+		e.Weights = j.Weights
 		e.Subtitle = j.Subtitle
 		e.MoreInfo = j.MoreInfo
 		e.MoreInfoLang = j.MoreInfoLang
@@ -219,7 +293,13 @@ func main() {
 		pub = kp.Public
 	}
 
-	request := &evoting.Link{Pin: *argPin, Roster: roster, Key: pub, Admins: admins}
+	idConfig, err := parseIdentity(*argIdentity, *argRoll, *argIssuer, *argClientID)
+	if err != nil {
+		log.Fatal("cannot parse identity provider: ", err)
+	}
+
+	request := &evoting.Link{Pin: *argPin, Roster: roster, Key: pub, Admins: admins,
+		Identity: idConfig}
 	if *argID != "" {
 		id, err := hex.DecodeString(*argID)
 		if err != nil {
@@ -282,6 +362,59 @@ func parseAdmins(scipers string) ([]uint32, error) {
 	return admins, nil
 }
 
+// parseIdentity returns the configuration of the identity provider, or nil
+// for the default EPFL provider.
+func parseIdentity(provider, roll, issuer, clientID string) (*lib.IdentityConfig, error) {
+	if provider == "" {
+		if roll != "" || issuer != "" || clientID != "" {
+			return nil, errors.New("-roll, -issuer and -clientid need an -identity provider")
+		}
+		return nil, nil
+	}
+	c := &lib.IdentityConfig{Provider: provider, Issuer: issuer, ClientID: clientID}
+	if roll != "" {
+		f, err := os.Open(roll)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		c.Voters, err = identity.ParseRoll(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, c.Verify()
+}
+
+// parseBallotType returns the ballot type given by its name. An empty name is
+// a plurality election.
+func parseBallotType(name string) (lib.BallotType, error) {
+	switch name {
+	case "", lib.Plurality.String():
+		return lib.Plurality, nil
+	case lib.RankedChoice.String():
+		return lib.RankedChoice, nil
+	}
+	return 0, fmt.Errorf("unknown ballot type %q", name)
+}
+
+// printTally prints the votes per candidate and the elected candidates.
+func printTally(e *lib.Election, t *lib.Tally) {
+	fmt.Printf("Ballot type: %v\n", e.BallotType)
+	fmt.Printf("Valid ballots: %d, invalid ballots: %d\n", t.Valid, t.Invalid)
+	if e.BallotType == lib.RankedChoice {
+		fmt.Println("First preferences:")
+	} else {
+		fmt.Println("Votes:")
+	}
+	for i, c := range e.Candidates {
+		fmt.Printf("  %d: %d\n", c, t.Counts[i])
+	}
+	if e.BallotType == lib.RankedChoice {
+		fmt.Printf("Elected: %v\n", t.Elected)
+	}
+}
+
 // parseKey unmarshals a Ed25519 point given in hexadecimal form.
 func parseKey(key string) (kyber.Point, error) {
 	b, err := hex.DecodeString(key)
@@ -304,6 +437,9 @@ type jsonElection struct {
 
 	Candidates   []uint32          // Candidates is the list of candidate scipers.
 	MaxChoices   int               // MaxChoices is the max votes in allowed in a ballot.
+	BallotType   string            // BallotType is either "plurality" or "ranked".
+	Seats        int               // Seats is the number of candidates elected in a ranked election.
+	Weights      []uint32          // Weights holds the weight of each user, in the order of Users.
 	Subtitle     map[string]string // Description in string format. lang-code, value pair
 	MoreInfo     string            // MoreInfo is the url to AE Website for the given election.
 	MoreInfoLang map[string]string
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/evoting/lib"
 	"go.dedis.ch/kyber/v3/util/random"
 	"go.dedis.ch/onet/v3/log"
 )
@@ -35,3 +36,16 @@ func TestParseAdmins(t *testing.T) {
 	admins, _ = parseAdmins("1,2,3")
 	assert.Equal(t, []uint32{1, 2, 3}, admins)
 }
+
+func TestParseBallotType(t *testing.T) {
+	bt, err := parseBallotType("")
+	assert.Nil(t, err)
+	assert.Equal(t, lib.Plurality, bt)
+
+	bt, err = parseBallotType("ranked")
+	assert.Nil(t, err)
+	assert.Equal(t, lib.RankedChoice, bt)
+
+	_, err = parseBallotType("approval")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,130 @@
+// This is a command line tool to verify the transcript of an election without
+// network access. The transcript is exported with `evoting-admin -export`.
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/app"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+)
+
+var (
+	argID     = flag.String("id", "", "expected ID of the election skipchain (optional)")
+	argRoster = flag.String("roster", "", "path to the toml file of the expected roster (optional)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-id ID] [-roster roster.toml] transcript\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	t, err := readTranscript(flag.Arg(0))
+	if err != nil {
+		log.Fatal("cannot read transcript: ", err)
+	}
+	if err = checkAnchors(t, *argID, *argRoster); err != nil {
+		log.Fatal(err)
+	}
+	res, err := t.Verify()
+	if err != nil {
+		log.Fatal("verification failed: ", err)
+	}
+
+	e := res.Election
+	fmt.Printf("Election %x\n", t.ID())
+	fmt.Printf("Roster: %v\n", e.Roster.List)
+	fmt.Printf("Blocks: %d\n", len(t.Blocks))
+	fmt.Printf("Ballots: %d\n", len(res.Ballots))
+	fmt.Printf("Verified shuffles: %d\n", len(res.Mixes))
+	fmt.Printf("Verified partial decryptions: %d\n", len(res.Partials))
+	if len(res.Partials) > 0 && len(e.Commits) == 0 {
+		fmt.Println("Warning: the election has no DKG commits, the partial " +
+			"decryptions have no proofs")
+	}
+	if res.Tally == nil {
+		fmt.Println("The election is not decrypted yet.")
+		return
+	}
+	fmt.Printf("Valid ballots: %d, invalid ballots: %d\n", res.Tally.Valid,
+		res.Tally.Invalid)
+	for i, c := range e.Candidates {
+		fmt.Printf("  %d: %d\n", c, res.Tally.Counts[i])
+	}
+	if e.BallotType == lib.RankedChoice {
+		fmt.Printf("Elected: %v\n", res.Tally.Elected)
+	}
+}
+
+// readTranscript decodes a transcript written by evoting-admin.
+func readTranscript(path string) (*lib.Transcript, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	_, msg, err := network.Unmarshal(buf, cothority.Suite)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := msg.(*lib.Transcript)
+	if !ok {
+		return nil, errors.New("file doesn't hold a transcript")
+	}
+	return t, nil
+}
+
+// checkAnchors compares the transcript to the expected election ID and
+// roster, as the transcript is only signed by its own roster.
+func checkAnchors(t *lib.Transcript, id, rosterPath string) error {
+	if id != "" {
+		buf, err := hex.DecodeString(id)
+		if err != nil {
+			return fmt.Errorf("id decode: %v", err)
+		}
+		if !t.ID().Equal(buf) {
+			return errors.New("the transcript is for another election")
+		}
+	}
+	if rosterPath != "" {
+		roster, err := parseRoster(rosterPath)
+		if err != nil {
+			return fmt.Errorf("cannot parse roster: %v", err)
+		}
+		if len(t.Blocks) == 0 || !roster.ID.Equal(t.Blocks[0].Roster.ID) {
+			return errors.New("the transcript is signed by another roster")
+		}
+	}
+	return nil
+}
+
+// parseRoster reads a Dedis group toml file a converts it to a cothority roster.
+func parseRoster(path string) (*onet.Roster, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	group, err := app.ReadGroupDescToml(file)
+	if err != nil {
+		return nil, err
+	}
+	return group.Roster, nil
+}
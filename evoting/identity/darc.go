@@ -0,0 +1,35 @@
+package identity
+
+import (
+	"errors"
+
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+	"go.dedis.ch/cothority/v3/skipchain"
+)
+
+// DARC authenticates the users of a roll with a signature of their darc
+// identity on their Message. This lets users sign with the same keys they
+// use on ByzCoin, without going through a front-end.
+type DARC struct {
+	Master skipchain.SkipBlockID
+	Config *lib.IdentityConfig
+}
+
+// Authenticate verifies the signature of the identity of the user.
+func (d *DARC) Authenticate(user uint32, credential []byte) error {
+	v := d.Config.Voter(user)
+	if v == nil {
+		return errors.New("user is not in the roll")
+	}
+	id, err := darc.ParseIdentity(v.Identity)
+	if err != nil {
+		return err
+	}
+	return id.Verify(Message(d.Master, user), credential)
+}
+
+// Lookup returns the name and email of the user in the roll.
+func (d *DARC) Lookup(user uint32) (*Info, error) {
+	return lookupRoll(d.Config, user)
+}
@@ -0,0 +1,75 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-ldap/ldap/v3"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+	"go.dedis.ch/cothority/v3/skipchain"
+)
+
+// DefaultLDAPURL is the EPFL LDAP directory used to look up SCIPER numbers.
+const DefaultLDAPURL = "ldaps://ldap.epfl.ch"
+
+// EPFL authenticates SCIPER numbers with the signature of the front-end,
+// which logs the users in through Tequila.
+type EPFL struct {
+	Master skipchain.SkipBlockID // Master is the ID of the master chain.
+	Key    kyber.Point           // Key is the public key of the front-end.
+	URL    string                // URL of the LDAP directory, DefaultLDAPURL if empty.
+}
+
+// NewEPFL returns the EPFL provider for the master chain.
+func NewEPFL(m *lib.Master) *EPFL {
+	return &EPFL{Master: m.ID, Key: m.Key}
+}
+
+// Authenticate verifies the schnorr signature of the front-end on the
+// Message of the user.
+func (e *EPFL) Authenticate(user uint32, credential []byte) error {
+	return schnorr.Verify(cothority.Suite, e.Key, Message(e.Master, user),
+		credential)
+}
+
+// Lookup searches the SCIPER number in the LDAP directory.
+func (e *EPFL) Lookup(user uint32) (*Info, error) {
+	url := DefaultLDAPURL
+	if e.URL != "" {
+		url = e.URL
+	}
+	l, err := ldap.DialURL(url)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	// Search for the given username
+	searchRequest := ldap.NewSearchRequest(
+		"o=epfl, c=ch",
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&(objectClass=person)(uniqueIdentifier=%d))", user),
+		[]string{"displayName", "mail"},
+		nil,
+	)
+
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// If no results, err.
+	// If more than one are returned, we look only at the first one.
+	if len(sr.Entries) == 0 {
+		return nil, errors.New("SCIPER not found")
+	}
+
+	return &Info{
+		FullName: sr.Entries[0].GetAttributeValue("displayName"),
+		Email:    sr.Entries[0].GetAttributeValue("mail"),
+	}, nil
+}
@@ -0,0 +1,70 @@
+// Package identity implements the providers authenticating the users of the
+// evoting service. Each master chain configures its provider with a
+// lib.IdentityConfig; without configuration, users are authenticated as EPFL
+// SCIPER numbers.
+package identity
+
+import (
+	"errors"
+	"strconv"
+
+	"go.dedis.ch/cothority/v3/evoting/lib"
+	"go.dedis.ch/cothority/v3/skipchain"
+)
+
+// Provider authenticates users and looks up information about them.
+type Provider interface {
+	// Authenticate returns an error if the credential doesn't prove that the
+	// request comes from the user.
+	Authenticate(user uint32, credential []byte) error
+	// Lookup returns the name and email of the user.
+	Lookup(user uint32) (*Info, error)
+}
+
+// Info holds the public information about a user.
+type Info struct {
+	FullName string
+	Email    string
+}
+
+// New returns the identity provider configured in the master.
+func New(m *lib.Master) (Provider, error) {
+	if m.Identity == nil {
+		return NewEPFL(m), nil
+	}
+	if err := m.Identity.Verify(); err != nil {
+		return nil, err
+	}
+	switch m.Identity.Provider {
+	case lib.ProviderEPFL:
+		return NewEPFL(m), nil
+	case lib.ProviderCSV:
+		return &Roll{EPFL: *NewEPFL(m), Config: m.Identity}, nil
+	case lib.ProviderOIDC:
+		return &OIDC{Master: m.ID, Config: m.Identity,
+			Validator: oidcValidator{clientID: m.Identity.ClientID}}, nil
+	case lib.ProviderDARC:
+		return &DARC{Master: m.ID, Config: m.Identity}, nil
+	}
+	return nil, errors.New("unknown identity provider")
+}
+
+// Message returns the message a user signs to authenticate to the master
+// chain: the master ID followed by the decimal digits of the user, one digit
+// per byte.
+func Message(master skipchain.SkipBlockID, user uint32) []byte {
+	message := append([]byte{}, master...)
+	for _, c := range strconv.Itoa(int(user)) {
+		message = append(message, byte(c-'0'))
+	}
+	return message
+}
+
+// lookupRoll returns the information of the roll about the user.
+func lookupRoll(c *lib.IdentityConfig, user uint32) (*Info, error) {
+	v := c.Voter(user)
+	if v == nil {
+		return nil, errors.New("user is not in the roll")
+	}
+	return &Info{FullName: v.Name, Email: v.Email}, nil
+}
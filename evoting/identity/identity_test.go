@@ -0,0 +1,140 @@
+package identity
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/kyber/v3/util/key"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+)
+
+var master = []byte("master chain id")
+
+func TestMessage(t *testing.T) {
+	require.Equal(t, append([]byte("master chain id"), 1, 2, 3, 0),
+		Message(master, 1230))
+	require.Equal(t, []byte("master chain id"), master)
+}
+
+func TestParseRoll(t *testing.T) {
+	voters, err := ParseRoll(strings.NewReader(`# id,name,email,identity
+1
+2,Alice,alice@example.com
+3, Bob, bob@example.com, ed25519:0102
+`))
+	require.NoError(t, err)
+	require.Equal(t, []lib.Voter{
+		{ID: 1},
+		{ID: 2, Name: "Alice", Email: "alice@example.com"},
+		{ID: 3, Name: "Bob", Email: "bob@example.com", Identity: "ed25519:0102"},
+	}, voters)
+
+	_, err = ParseRoll(strings.NewReader("a,b\n"))
+	require.Error(t, err)
+	_, err = ParseRoll(strings.NewReader("1,a,b,c,d\n"))
+	require.Error(t, err)
+	_, err = ParseRoll(strings.NewReader("4294967296\n"))
+	require.Error(t, err)
+}
+
+func TestRoll(t *testing.T) {
+	kp := key.NewKeyPair(cothority.Suite)
+	m := &lib.Master{ID: master, Key: kp.Public}
+	p, err := New(m)
+	require.NoError(t, err)
+	require.IsType(t, &EPFL{}, p)
+
+	m.Identity = &lib.IdentityConfig{Provider: lib.ProviderCSV,
+		Voters: []lib.Voter{{ID: 1, Name: "Alice"}}}
+	p, err = New(m)
+	require.NoError(t, err)
+
+	sign := func(user uint32) []byte {
+		sig, err := schnorr.Sign(cothority.Suite, kp.Private, Message(master, user))
+		require.NoError(t, err)
+		return sig
+	}
+	require.NoError(t, p.Authenticate(1, sign(1)))
+	require.Error(t, p.Authenticate(1, sign(2)))
+	require.Error(t, p.Authenticate(2, sign(2)))
+
+	info, err := p.Lookup(1)
+	require.NoError(t, err)
+	require.Equal(t, "Alice", info.FullName)
+	_, err = p.Lookup(2)
+	require.Error(t, err)
+
+	m.Identity.Voters = nil
+	_, err = New(m)
+	require.Error(t, err)
+}
+
+// fakeValidator accepts the tokens "email|nonce".
+type fakeValidator struct{}
+
+func (fakeValidator) FindClaim(issuer string, token []byte) (string, string, error) {
+	parts := strings.Split(string(token), "|")
+	if issuer != "https://issuer" || len(parts) != 2 {
+		return "", "", errors.New("invalid token")
+	}
+	return parts[0], parts[1], nil
+}
+
+func TestOIDC(t *testing.T) {
+	c := &lib.IdentityConfig{Provider: lib.ProviderOIDC, Issuer: "https://issuer",
+		ClientID: "evoting",
+		Voters:   []lib.Voter{{ID: 1, Email: "alice@example.com"}}}
+	p, err := New(&lib.Master{ID: master, Identity: c})
+	require.NoError(t, err)
+	require.Equal(t, oidcValidator{clientID: "evoting"}, p.(*OIDC).Validator)
+	p.(*OIDC).Validator = fakeValidator{}
+
+	nonce := hex.EncodeToString(Message(master, 1))
+	require.NoError(t, p.Authenticate(1, []byte("Alice@example.com|"+nonce)))
+	require.Error(t, p.Authenticate(1, []byte("bob@example.com|"+nonce)))
+	require.Error(t, p.Authenticate(1, []byte("alice@example.com|"+
+		hex.EncodeToString(Message([]byte("other"), 1)))))
+	require.Error(t, p.Authenticate(2, []byte("alice@example.com|"+nonce)))
+	require.Error(t, p.Authenticate(1, []byte("alice@example.com")))
+}
+
+func TestOIDCClaims(t *testing.T) {
+	var c oidcClaims
+	require.NoError(t, json.Unmarshal([]byte(`{"email":"alice@example.com",
+		"email_verified":true,"nonce":"00"}`), &c))
+	require.NoError(t, c.verify())
+	require.Equal(t, "00", c.Nonce)
+
+	c.EmailVerified = false
+	require.Error(t, c.verify())
+	require.NoError(t, json.Unmarshal([]byte(`{"email":"alice@example.com"}`), &c))
+	require.Error(t, c.verify())
+	require.NoError(t, json.Unmarshal([]byte(`{"email_verified":true}`), &c))
+	require.Error(t, c.verify())
+}
+
+func TestDARC(t *testing.T) {
+	alice := darc.NewSignerEd25519(nil, nil)
+	bob := darc.NewSignerEd25519(nil, nil)
+	c := &lib.IdentityConfig{Provider: lib.ProviderDARC,
+		Voters: []lib.Voter{{ID: 1, Identity: alice.Identity().String()}}}
+	p, err := New(&lib.Master{ID: master, Identity: c})
+	require.NoError(t, err)
+
+	sig, err := alice.Sign(Message(master, 1))
+	require.NoError(t, err)
+	require.NoError(t, p.Authenticate(1, sig))
+	require.Error(t, p.Authenticate(2, sig))
+
+	sig, err = bob.Sign(Message(master, 1))
+	require.NoError(t, err)
+	require.Error(t, p.Authenticate(1, sig))
+}
@@ -0,0 +1,121 @@
+package identity
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc"
+	"go.dedis.ch/cothority/v3/authprox"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+	"go.dedis.ch/cothority/v3/skipchain"
+)
+
+// verifiers holds the verifiers of all issuers and client IDs, so that the
+// issuers are only discovered once.
+var verifiers = struct {
+	sync.Mutex
+	v map[[2]string]*oidc.IDTokenVerifier
+}{v: make(map[[2]string]*oidc.IDTokenVerifier)}
+
+// OIDC authenticates the users of a roll with ID tokens of an OpenID Connect
+// issuer. The token must be issued for the client ID of the configuration,
+// its verified email claim must be the email of the user in the roll, and the
+// nonce of the token must be the hex encoded Message of the user, so that a
+// token cannot be replayed on another master chain.
+type OIDC struct {
+	Master    skipchain.SkipBlockID
+	Config    *lib.IdentityConfig
+	Validator authprox.Validator
+}
+
+// Authenticate verifies the ID token given as the credential.
+func (o *OIDC) Authenticate(user uint32, credential []byte) error {
+	v := o.Config.Voter(user)
+	if v == nil {
+		return errors.New("user is not in the roll")
+	}
+	email, nonce, err := o.Validator.FindClaim(o.Config.Issuer, credential)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(email, v.Email) {
+		return errors.New("the token is for another user")
+	}
+	if nonce != hex.EncodeToString(Message(o.Master, user)) {
+		return errors.New("the nonce of the token doesn't match")
+	}
+	return nil
+}
+
+// Lookup returns the name and email of the user in the roll.
+func (o *OIDC) Lookup(user uint32) (*Info, error) {
+	return lookupRoll(o.Config, user)
+}
+
+// oidcValidator verifies that ID tokens are issued for its client ID, and
+// returns their verified email and their nonce.
+type oidcValidator struct {
+	clientID string
+}
+
+func (o oidcValidator) FindClaim(issuer string, token []byte) (string, string, error) {
+	v, err := verifier(issuer, o.clientID)
+	if err != nil {
+		return "", "", err
+	}
+	// Verify checks the signature, the issuer, the audience and the expiry
+	// of the token, but not the nonce, which is checked by Authenticate.
+	idToken, err := v.Verify(context.Background(), string(token))
+	if err != nil {
+		return "", "", err
+	}
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return "", "", fmt.Errorf("could not parse the claims: %v", err)
+	}
+	if err := claims.verify(); err != nil {
+		return "", "", err
+	}
+	return claims.Email, claims.Nonce, nil
+}
+
+// oidcClaims are the claims of an ID token used by OIDC.
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Nonce         string `json:"nonce"`
+}
+
+// verify makes sure that the issuer verified the email, else anybody could
+// sign up with the email of a voter.
+func (c oidcClaims) verify() error {
+	if c.Email == "" {
+		return errors.New("the token has no email")
+	}
+	if !c.EmailVerified {
+		return errors.New("the email of the token is not verified")
+	}
+	return nil
+}
+
+// verifier returns the verifier of the ID tokens of the issuer for the client
+// ID.
+func verifier(issuer, clientID string) (*oidc.IDTokenVerifier, error) {
+	verifiers.Lock()
+	defer verifiers.Unlock()
+	k := [2]string{issuer, clientID}
+	if v, ok := verifiers.v[k]; ok {
+		return v, nil
+	}
+	p, err := oidc.NewProvider(context.Background(), issuer)
+	if err != nil {
+		return nil, err
+	}
+	v := p.Verifier(&oidc.Config{ClientID: clientID})
+	verifiers.v[k] = v
+	return v, nil
+}
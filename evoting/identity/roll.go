@@ -0,0 +1,72 @@
+package identity
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"go.dedis.ch/cothority/v3/evoting/lib"
+)
+
+// Roll authenticates the users of a static roll. Like EPFL, the front-end
+// logs the users in and signs their Message, but only users of the roll are
+// accepted, and they are looked up in the roll.
+type Roll struct {
+	EPFL
+	Config *lib.IdentityConfig
+}
+
+// Authenticate verifies the signature of the front-end for a user of the
+// roll.
+func (r *Roll) Authenticate(user uint32, credential []byte) error {
+	if r.Config.Voter(user) == nil {
+		return errors.New("user is not in the roll")
+	}
+	return r.EPFL.Authenticate(user, credential)
+}
+
+// Lookup returns the name and email of the user in the roll.
+func (r *Roll) Lookup(user uint32) (*Info, error) {
+	return lookupRoll(r.Config, user)
+}
+
+// ParseRoll reads the voters from a CSV file. Each line holds the ID of a
+// user, then optionally the name, the email and the darc identity. Empty
+// lines and lines starting with '#' are ignored.
+func ParseRoll(r io.Reader) ([]lib.Voter, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var voters []lib.Voter
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read roll: %v", err)
+		}
+		if len(record) > 4 {
+			return nil, fmt.Errorf("entry %d: too many fields", len(voters)+1)
+		}
+		for len(record) < 4 {
+			record = append(record, "")
+		}
+		id, err := strconv.ParseUint(strings.TrimSpace(record[0]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: invalid id: %v", len(voters)+1, err)
+		}
+		voters = append(voters, lib.Voter{
+			ID:       uint32(id),
+			Name:     record[1],
+			Email:    record[2],
+			Identity: record[3],
+		})
+	}
+	return voters, nil
+}
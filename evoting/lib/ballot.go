@@ -0,0 +1,266 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"go.dedis.ch/kyber/v3"
+)
+
+// MaxBallotChoices is the maximum number of candidates a ballot can hold: each
+// candidate takes 3 bytes and a point can embed at most 29 bytes.
+const MaxBallotChoices = 9
+
+// MaxWeight is the maximum weight of a user. A ballot with weight w is
+// shuffled as w copies, so the weights are bounded to keep the mixes small.
+const MaxWeight = 100
+
+// EncodeBallot returns the plaintext of a ballot with the given candidates.
+// Each candidate is encoded as 3 bytes in little-endian order. For ranked-choice
+// elections, the candidates are given in order of preference.
+func EncodeBallot(candidates []uint32) ([]byte, error) {
+	if len(candidates) > MaxBallotChoices {
+		return nil, fmt.Errorf("a ballot holds at most %d candidates",
+			MaxBallotChoices)
+	}
+	data := make([]byte, 0, 3*len(candidates))
+	for _, c := range candidates {
+		if c >= 1<<24 {
+			return nil, fmt.Errorf("candidate %d doesn't fit in 3 bytes", c)
+		}
+		data = append(data, byte(c), byte(c>>8), byte(c>>16))
+	}
+	return data, nil
+}
+
+// DecodeBallot returns the candidates of a plaintext created by EncodeBallot.
+func DecodeBallot(data []byte) ([]uint32, error) {
+	if len(data)%3 != 0 {
+		return nil, errors.New("ballot length is not a multiple of 3")
+	}
+	candidates := make([]uint32, len(data)/3)
+	for i := range candidates {
+		candidates[i] = uint32(data[3*i]) | uint32(data[3*i+1])<<8 |
+			uint32(data[3*i+2])<<16
+	}
+	return candidates, nil
+}
+
+// ValidBallot returns an error if the candidates cannot be cast together in
+// a ballot of the election.
+func (e *Election) ValidBallot(candidates []uint32) error {
+	if e.MaxChoices > 0 && len(candidates) > e.MaxChoices {
+		return fmt.Errorf("ballot has %d choices, but only %d are allowed",
+			len(candidates), e.MaxChoices)
+	}
+	seen := make(map[uint32]bool)
+	for _, c := range candidates {
+		if seen[c] {
+			return fmt.Errorf("candidate %d is chosen twice", c)
+		}
+		seen[c] = true
+		if !e.isCandidate(c) {
+			return fmt.Errorf("%d is not a candidate", c)
+		}
+	}
+	return nil
+}
+
+func (e *Election) isCandidate(c uint32) bool {
+	for _, cand := range e.Candidates {
+		if cand == c {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyBallotType checks that the ballot type, seats and weights of the
+// election are valid.
+func (e *Election) verifyBallotType() error {
+	switch e.BallotType {
+	case Plurality:
+		if e.Seats != 0 {
+			return errors.New("seats are only used by ranked-choice elections")
+		}
+	case RankedChoice:
+		// Unset seats, i.e. 0, mean a single seat.
+		if e.Seats < 0 || e.seats() > len(e.Candidates) {
+			return errors.New("seats must be unset or between 1 and the " +
+				"number of candidates")
+		}
+	default:
+		return fmt.Errorf("unknown ballot type %d", e.BallotType)
+	}
+	if e.MaxChoices > MaxBallotChoices {
+		return fmt.Errorf("a ballot holds at most %d candidates", MaxBallotChoices)
+	}
+	if len(e.Weights) == 0 {
+		return nil
+	}
+	if len(e.Weights) != len(e.Users) {
+		return errors.New("need one weight per user")
+	}
+	for i, w := range e.Weights {
+		if w == 0 || w > MaxWeight {
+			return fmt.Errorf("weight of user %d must be between 1 and %d",
+				e.Users[i], MaxWeight)
+		}
+	}
+	return nil
+}
+
+// seats returns the number of seats of a ranked-choice election.
+func (e *Election) seats() int {
+	if e.Seats == 0 {
+		return 1
+	}
+	return e.Seats
+}
+
+// Weight returns the weight of the user's ballot.
+func (e *Election) Weight(user uint32) uint32 {
+	for i, u := range e.Users {
+		if u == user && i < len(e.Weights) {
+			return e.Weights[i]
+		}
+	}
+	return 1
+}
+
+// WeightedBallots returns the ballots of the box, where the ballot of each
+// user is repeated as often as the weight of the user. This is the input of
+// the first shuffle: the re-encryption unlinks the copies of a ballot, so the
+// weights cannot be traced through the mixes.
+func (e *Election) WeightedBallots(box *Box) []*Ballot {
+	if len(e.Weights) == 0 {
+		return box.Ballots
+	}
+	ballots := make([]*Ballot, 0, len(box.Ballots))
+	for _, b := range box.Ballots {
+		for i := uint32(0); i < e.Weight(b.User); i++ {
+			ballots = append(ballots, b)
+		}
+	}
+	return ballots
+}
+
+// Tally is the result of an election, computed from the decrypted ballots.
+type Tally struct {
+	Valid   int      // Valid is the number of counted ballots.
+	Invalid int      // Invalid is the number of ballots which are not valid for the election.
+	Counts  []uint32 // Counts holds the votes per candidate in the order of Election.Candidates, first preferences only for ranked-choice.
+	Elected []uint32 // Elected holds the candidates elected by a ranked-choice election, in order of election.
+}
+
+// Tally decodes and counts the ballots. The points are the plaintexts
+// returned by the Reconstruct service, so weighted ballots are already
+// repeated.
+func (e *Election) Tally(points []kyber.Point) *Tally {
+	tally := &Tally{Counts: make([]uint32, len(e.Candidates))}
+	index := make(map[uint32]int)
+	for i, c := range e.Candidates {
+		index[c] = i
+	}
+
+	var ballots [][]uint32
+	for _, p := range points {
+		data, err := p.Data()
+		if err != nil {
+			tally.Invalid++
+			continue
+		}
+		candidates, err := DecodeBallot(data)
+		if err == nil {
+			err = e.ValidBallot(candidates)
+		}
+		if err != nil {
+			tally.Invalid++
+			continue
+		}
+		tally.Valid++
+		ballots = append(ballots, candidates)
+		for i, c := range candidates {
+			if e.BallotType == RankedChoice && i > 0 {
+				break
+			}
+			tally.Counts[index[c]]++
+		}
+	}
+
+	if e.BallotType == RankedChoice {
+		tally.Elected = stv(e.Candidates, ballots, e.seats())
+	}
+	return tally
+}
+
+// stv counts ranked ballots with single transferable vote, using the Droop
+// quota and fractional transfers of the surplus. Each round either elects the
+// candidate with the most votes if it reaches the quota, or eliminates the
+// candidate with the fewest votes. Ties are broken by the order of the
+// candidates: the first one is elected and the last one is eliminated.
+func stv(candidates []uint32, ballots [][]uint32, seats int) []uint32 {
+	weights := make([]*big.Rat, len(ballots))
+	for i := range weights {
+		weights[i] = big.NewRat(1, 1)
+	}
+	quota := new(big.Rat).SetInt64(int64(len(ballots)/(seats+1) + 1))
+	hopeful := make(map[uint32]bool)
+	for _, c := range candidates {
+		hopeful[c] = true
+	}
+
+	// top returns the preferred hopeful candidate of a ballot, or false if
+	// the ballot is exhausted.
+	top := func(b []uint32) (uint32, bool) {
+		for _, c := range b {
+			if hopeful[c] {
+				return c, true
+			}
+		}
+		return 0, false
+	}
+
+	var elected []uint32
+	for len(elected) < seats && len(hopeful) > 0 {
+		votes := make(map[uint32]*big.Rat)
+		var order []uint32
+		for _, c := range candidates {
+			if hopeful[c] {
+				votes[c] = new(big.Rat)
+				order = append(order, c)
+			}
+		}
+		for i, b := range ballots {
+			if c, ok := top(b); ok {
+				votes[c].Add(votes[c], weights[i])
+			}
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return votes[order[i]].Cmp(votes[order[j]]) > 0
+		})
+
+		if len(order) <= seats-len(elected) {
+			return append(elected, order...)
+		}
+
+		best := order[0]
+		if votes[best].Cmp(quota) >= 0 {
+			// Transfer the surplus of the elected candidate.
+			ratio := new(big.Rat).Sub(votes[best], quota)
+			ratio.Quo(ratio, votes[best])
+			for i, b := range ballots {
+				if c, ok := top(b); ok && c == best {
+					weights[i].Mul(weights[i], ratio)
+				}
+			}
+			elected = append(elected, best)
+			delete(hopeful, best)
+			continue
+		}
+		delete(hopeful, order[len(order)-1])
+	}
+	return elected
+}
@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/random"
+
+	"go.dedis.ch/cothority/v3"
+)
+
+// votes are n plaintext ballots with the same candidates.
+type votes struct {
+	n          int
+	candidates []uint32
+}
+
+// genPoints embeds the plaintexts of the ballots.
+func genPoints(ballots ...votes) []kyber.Point {
+	var points []kyber.Point
+	for _, v := range ballots {
+		data, _ := EncodeBallot(v.candidates)
+		for i := 0; i < v.n; i++ {
+			points = append(points, cothority.Suite.Point().Embed(data, random.New()))
+		}
+	}
+	return points
+}
+
+func TestEncodeBallot(t *testing.T) {
+	candidates := []uint32{123456, 1, 1<<24 - 1}
+	data, err := EncodeBallot(candidates)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x40, 0xe2, 0x01, 1, 0, 0, 0xff, 0xff, 0xff}, data)
+	decoded, err := DecodeBallot(data)
+	assert.Nil(t, err)
+	assert.Equal(t, candidates, decoded)
+
+	_, err = EncodeBallot([]uint32{1 << 24})
+	assert.NotNil(t, err)
+	_, err = EncodeBallot(make([]uint32, MaxBallotChoices+1))
+	assert.NotNil(t, err)
+	_, err = DecodeBallot(data[1:])
+	assert.NotNil(t, err)
+}
+
+func TestValidBallot(t *testing.T) {
+	e := &Election{Candidates: []uint32{1, 2, 3}, MaxChoices: 2}
+	assert.Nil(t, e.ValidBallot(nil))
+	assert.Nil(t, e.ValidBallot([]uint32{3, 1}))
+	assert.NotNil(t, e.ValidBallot([]uint32{1, 2, 3}))
+	assert.NotNil(t, e.ValidBallot([]uint32{1, 1}))
+	assert.NotNil(t, e.ValidBallot([]uint32{4}))
+}
+
+func TestVerifyBallotType(t *testing.T) {
+	e := &Election{Users: []uint32{1, 2}, Candidates: []uint32{1, 2, 3}}
+	assert.Nil(t, e.verifyBallotType())
+	e.Seats = 2
+	assert.NotNil(t, e.verifyBallotType())
+	e.BallotType = RankedChoice
+	assert.Nil(t, e.verifyBallotType())
+	e.Seats = 4
+	assert.NotNil(t, e.verifyBallotType())
+	e.Seats = -1
+	assert.NotNil(t, e.verifyBallotType())
+	e.Seats = 0
+	assert.Nil(t, e.verifyBallotType())
+
+	e.Weights = []uint32{1}
+	assert.NotNil(t, e.verifyBallotType())
+	e.Weights = []uint32{1, 0}
+	assert.NotNil(t, e.verifyBallotType())
+	e.Weights = []uint32{1, MaxWeight + 1}
+	assert.NotNil(t, e.verifyBallotType())
+	e.Weights = []uint32{1, MaxWeight}
+	assert.Nil(t, e.verifyBallotType())
+
+	e.BallotType = 2
+	assert.NotNil(t, e.verifyBallotType())
+}
+
+func TestWeightedBallots(t *testing.T) {
+	_, X := RandomKeyPair()
+	box := genBox(X, 3)
+
+	e := &Election{Users: []uint32{0, 1, 2}}
+	assert.Equal(t, box.Ballots, e.WeightedBallots(box))
+
+	e.Weights = []uint32{1, 3, 2}
+	assert.Equal(t, uint32(3), e.Weight(1))
+	assert.Equal(t, uint32(1), e.Weight(4))
+	ballots := e.WeightedBallots(box)
+	assert.Equal(t, 6, len(ballots))
+	assert.Equal(t, box.Ballots[1], ballots[3])
+
+	// The weighted ballots must still be verifiable through the shuffle.
+	mix := (&Box{Ballots: ballots}).genMix(X, 1)[0]
+	x, y := Split(ballots)
+	v, w := Split(mix.Ballots)
+	assert.Nil(t, Verify(mix.Proof, X, x, y, v, w))
+}
+
+func TestTally_Plurality(t *testing.T) {
+	e := &Election{Candidates: []uint32{1, 2, 3}, MaxChoices: 2}
+	points := genPoints(votes{3, []uint32{1, 2}}, votes{1, []uint32{3}},
+		votes{1, []uint32{2, 3}}, votes{1, []uint32{2, 3, 1}},
+		votes{1, []uint32{4}})
+	points = append(points, cothority.Suite.Point().Embed([]byte{1, 2},
+		random.New()))
+
+	tally := e.Tally(points)
+	assert.Equal(t, 5, tally.Valid)
+	assert.Equal(t, 3, tally.Invalid)
+	assert.Equal(t, []uint32{3, 4, 2}, tally.Counts)
+	assert.Nil(t, tally.Elected)
+}
+
+func TestTally_InstantRunoff(t *testing.T) {
+	e := &Election{Candidates: []uint32{1, 2, 3}, BallotType: RankedChoice}
+	points := genPoints(votes{4, []uint32{1, 2}}, votes{3, []uint32{2, 3}},
+		votes{2, []uint32{3, 2}})
+
+	tally := e.Tally(points)
+	assert.Equal(t, 9, tally.Valid)
+	assert.Equal(t, []uint32{4, 3, 2}, tally.Counts)
+	// Candidate 1 has the most first preferences, but not a majority: once
+	// candidate 3 is eliminated, candidate 2 has one.
+	assert.Equal(t, []uint32{2}, tally.Elected)
+}
+
+func TestTally_SingleTransferableVote(t *testing.T) {
+	e := &Election{Candidates: []uint32{1, 2, 3, 4}, BallotType: RankedChoice,
+		Seats: 2}
+	points := genPoints(votes{6, []uint32{1, 2}}, votes{2, []uint32{2}},
+		votes{3, []uint32{3}}, votes{1, []uint32{4, 3}})
+
+	tally := e.Tally(points)
+	assert.Equal(t, 12, tally.Valid)
+	assert.Equal(t, []uint32{6, 2, 3, 1}, tally.Counts)
+	// The quota is 5: the surplus of candidate 1 is too small to elect
+	// candidate 2, so candidate 3 gets the second seat.
+	assert.Equal(t, []uint32{1, 3}, tally.Elected)
+
+	// With a quota of 3, half of the votes of candidate 1 go to candidate 2.
+	e.Seats = 4
+	assert.Equal(t, []uint32{1, 2, 3, 4}, e.Tally(points).Elected)
+}
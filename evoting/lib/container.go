@@ -1,11 +1,18 @@
 package lib
 
 import (
+	"errors"
+	"fmt"
+
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/proof"
+	"go.dedis.ch/kyber/v3/proof/dleq"
+	"go.dedis.ch/kyber/v3/share"
 	"go.dedis.ch/kyber/v3/share/dkg/rabin"
 	"go.dedis.ch/kyber/v3/shuffle"
 	"go.dedis.ch/kyber/v3/util/random"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
 
 	"go.dedis.ch/cothority/v3"
@@ -57,6 +64,80 @@ type Partial struct {
 
 	NodeID    network.ServerIdentityID // NodeID is the node having signed the partial
 	Signature []byte                   // Signature of the public key
+
+	Proofs []*dleq.Proof `protobuf:"opt"` // Proofs that the points are decrypted with the share of the node.
+}
+
+// NewPartial partially decrypts the ballots of the mix with the shared
+// secret. For each ballot, it adds a proof that the same secret is used in
+// the public share of the node and in the decryption.
+func NewPartial(secret *SharedSecret, m *Mix) (*Partial, error) {
+	partial := &Partial{
+		Points: make([]kyber.Point, len(m.Ballots)),
+		Proofs: make([]*dleq.Proof, len(m.Ballots)),
+	}
+	for i, ballot := range m.Ballots {
+		partial.Points[i] = Decrypt(secret.V, ballot.Alpha, ballot.Beta)
+		proof, _, _, err := dleq.NewDLEQProof(cothority.Suite,
+			cothority.Suite.Point().Base(), ballot.Alpha, secret.V)
+		if err != nil {
+			return nil, err
+		}
+		partial.Proofs[i] = proof
+	}
+	return partial, nil
+}
+
+// VerifyProofs checks the decryption proofs of the partial of the node with
+// the given index, using the public commitments of the DKG.
+func (p *Partial) VerifyProofs(commits []kyber.Point, index int, m *Mix) error {
+	if len(p.Points) != len(m.Ballots) || len(p.Proofs) != len(m.Ballots) {
+		return errors.New("partial doesn't match the mix")
+	}
+	pub := share.NewPubPoly(cothority.Suite, nil, commits).Eval(index).V
+	for i, ballot := range m.Ballots {
+		if p.Proofs[i] == nil {
+			return fmt.Errorf("missing proof for ballot %d", i)
+		}
+		xH := cothority.Suite.Point().Sub(ballot.Beta, p.Points[i])
+		err := p.Proofs[i].Verify(cothority.Suite, cothority.Suite.Point().Base(),
+			ballot.Alpha, pub, xH)
+		if err != nil {
+			return fmt.Errorf("wrong decryption of ballot %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Reconstruct recovers the plaintexts from the partials of the roster nodes
+// using Lagrange interpolation.
+func Reconstruct(roster *onet.Roster, partials []*Partial) ([]kyber.Point, error) {
+	n := len(roster.List)
+	if len(partials) == 0 {
+		return nil, errors.New("no partials to reconstruct from")
+	}
+	points := make([]kyber.Point, 0)
+	for i := 0; i < len(partials[0].Points); i++ {
+		shares := make([]*share.PubShare, n)
+		for _, partial := range partials {
+			j, _ := roster.Search(partial.NodeID)
+			if j < 0 {
+				return nil, errors.New("partial from a node outside of the roster")
+			}
+			if len(partial.Points) != len(partials[0].Points) {
+				return nil, errors.New("partials have different lengths")
+			}
+			shares[j] = &share.PubShare{I: j, V: partial.Points[i]}
+		}
+
+		log.Lvl3("Recovering commits", i)
+		message, err := share.RecoverCommit(cothority.Suite, shares, 2*n/3+1, n)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, message)
+	}
+	return points, nil
 }
 
 // genPartials generates partial decryptions for a given list of shared secrets.
@@ -65,13 +146,7 @@ func (m *Mix) genPartials(dkgs []*dkg.DistKeyGenerator) []*Partial {
 
 	for i, gen := range dkgs {
 		secret, _ := NewSharedSecret(gen)
-		points := make([]kyber.Point, len(m.Ballots))
-		for j, ballot := range m.Ballots {
-			points[j] = Decrypt(secret.V, ballot.Alpha, ballot.Beta)
-		}
-		partials[i] = &Partial{
-			Points: points,
-		}
+		partials[i], _ = NewPartial(secret, m)
 	}
 	return partials
 }
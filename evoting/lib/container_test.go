@@ -41,3 +41,22 @@ func TestCombine(t *testing.T) {
 	assert.Equal(t, X2, ballots[0].Beta)
 	assert.Equal(t, X2, ballots[1].Beta)
 }
+
+func TestPartial_VerifyProofs(t *testing.T) {
+	dkgs, _ := DKGSimulate(4, 3)
+	secret, _ := NewSharedSecret(dkgs[0])
+	mix := genBox(secret.X, 3).genMix(secret.X, 1)[0]
+	partials := mix.genPartials(dkgs)
+
+	for i, partial := range partials {
+		s, _ := NewSharedSecret(dkgs[i])
+		assert.Nil(t, partial.VerifyProofs(secret.Commits, s.Index, mix))
+	}
+	s, _ := NewSharedSecret(dkgs[1])
+	assert.NotNil(t, partials[0].VerifyProofs(secret.Commits, s.Index, mix))
+
+	partials[0].Points[1] = partials[0].Points[0]
+	assert.NotNil(t, partials[0].VerifyProofs(secret.Commits, 0, mix))
+	partials[1].Proofs = partials[1].Proofs[1:]
+	assert.NotNil(t, partials[1].VerifyProofs(secret.Commits, 1, mix))
+}
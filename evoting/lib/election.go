@@ -58,6 +58,35 @@ type Election struct {
 
 	Voted        skipchain.SkipBlockID // Voted denotes if a user has already cast a ballot for this election.
 	MoreInfoLang map[string]string     // MoreInfoLang, is MoreInfo, but as a lang-code/value map. MoreInfoLang should be used in preference to MoreInfo.
+
+	BallotType BallotType `protobuf:"opt"` // BallotType tells how the choices of a ballot are counted.
+	Seats      int        `protobuf:"opt"` // Seats is the number of candidates elected by a ranked-choice election, 1 if unset.
+	Weights    []uint32   `protobuf:"opt"` // Weights holds the weight of each user, in the order of Users. All weights are 1 if unset.
+
+	Commits []kyber.Point `protobuf:"opt"` // Commits are the public commitments of the DKG, to verify the partial decryptions.
+}
+
+// BallotType defines how the choices in a ballot are counted.
+type BallotType uint32
+
+const (
+	// Plurality ballots give one vote to each chosen candidate.
+	Plurality BallotType = iota
+	// RankedChoice ballots list the candidates in order of preference. They
+	// are counted using single transferable vote, which is instant-runoff
+	// voting if only one seat is available.
+	RankedChoice
+)
+
+// String returns the name of the ballot type.
+func (bt BallotType) String() string {
+	switch bt {
+	case Plurality:
+		return "plurality"
+	case RankedChoice:
+		return "ranked"
+	}
+	return fmt.Sprintf("unknown(%d)", uint32(bt))
 }
 
 // Footer denotes the fields for the election footer
@@ -187,7 +216,6 @@ func (e *Election) Box(s *skipchain.Service) (*Box, error) {
 	}
 	block := search.SkipBlock
 
-	// Use map to only included a user's last ballot.
 	ballots := make([]*Ballot, 0)
 	for {
 		transaction := UnmarshalTransaction(block.Data)
@@ -204,15 +232,22 @@ func (e *Election) Box(s *skipchain.Service) (*Box, error) {
 			})
 	}
 
+	return &Box{Ballots: lastBallots(ballots)}, nil
+}
+
+// lastBallots returns the last ballot of each user, in the order they were
+// cast.
+func lastBallots(ballots []*Ballot) []*Ballot {
 	// Reverse ballot list
-	for i, j := 0, len(ballots)-1; i < j; i, j = i+1, j-1 {
-		ballots[i], ballots[j] = ballots[j], ballots[i]
+	reversed := make([]*Ballot, len(ballots))
+	for i, ballot := range ballots {
+		reversed[len(ballots)-1-i] = ballot
 	}
 
 	// Only keep last casted ballot per user
 	mapping := make(map[uint32]bool)
 	unique := make([]*Ballot, 0)
-	for _, ballot := range ballots {
+	for _, ballot := range reversed {
 		if _, found := mapping[ballot.User]; !found {
 			unique = append(unique, ballot)
 			mapping[ballot.User] = true
@@ -223,7 +258,7 @@ func (e *Election) Box(s *skipchain.Service) (*Box, error) {
 	for i, j := 0, len(unique)-1; i < j; i, j = i+1, j-1 {
 		unique[i], unique[j] = unique[j], unique[i]
 	}
-	return &Box{Ballots: unique}, nil
+	return unique
 }
 
 // Mixes returns all mixes created by the roster conodes.
@@ -343,6 +378,10 @@ func (e *Election) String() string {
 	printLang(str, e.Subtitle)
 	fmt.Fprintf(str, "Candidates: %v\n", e.Candidates)
 	fmt.Fprintf(str, "MaxChoices: %v\n", e.MaxChoices)
+	fmt.Fprintf(str, "BallotType: %v\n", e.BallotType)
+	if e.BallotType == RankedChoice {
+		fmt.Fprintf(str, "Seats: %v\n", e.seats())
+	}
 	fmt.Fprintf(str, "MoreInfo: %v\n", e.MoreInfo)
 	fmt.Fprintf(str, "MoreInfoLang:\n")
 	printLang(str, e.MoreInfoLang)
@@ -357,6 +396,9 @@ func (e *Election) String() string {
 	fmt.Fprintf(str, "Authentication server pubkey: %v\n", e.MasterKey)
 	fmt.Fprintf(str, "Stage: %v\n", e.Stage)
 	fmt.Fprintf(str, "Voters: %v\n", e.Users)
+	if len(e.Weights) > 0 {
+		fmt.Fprintf(str, "Weights: %v\n", e.Weights)
+	}
 
 	return str.String()
 }
@@ -2,11 +2,13 @@ package lib
 
 import (
 	"errors"
+	"fmt"
 
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/network"
 
+	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/skipchain"
 )
 
@@ -24,6 +26,88 @@ type Master struct {
 	Admins []uint32 // Admins is the list of administrators.
 
 	Key kyber.Point // Key is the front-end public key.
+
+	Identity *IdentityConfig `protobuf:"opt"` // Identity configures how users authenticate, EPFL Tequila if nil.
+}
+
+// Identity providers supported by IdentityConfig.
+const (
+	// ProviderEPFL authenticates SCIPER numbers through Tequila and looks
+	// them up in the EPFL LDAP directory.
+	ProviderEPFL = "epfl"
+	// ProviderCSV authenticates the users of a static roll through the
+	// front-end, like ProviderEPFL.
+	ProviderCSV = "csv"
+	// ProviderOIDC authenticates the users of a roll with an ID token of an
+	// OpenID Connect issuer, matched against their email.
+	ProviderOIDC = "oidc"
+	// ProviderDARC authenticates the users of a roll with a signature of
+	// their darc identity.
+	ProviderDARC = "darc"
+)
+
+// IdentityConfig tells which identity provider authenticates the users of a
+// master chain. Except for ProviderEPFL, the user identifiers stored in
+// elections are the IDs of the voters in the roll.
+type IdentityConfig struct {
+	Provider string  // Provider is one of the Provider* constants.
+	Issuer   string  `protobuf:"opt"` // Issuer is the URL of the OIDC issuer.
+	Voters   []Voter `protobuf:"opt"` // Voters is the roll of users.
+	ClientID string  `protobuf:"opt"` // ClientID is the OIDC audience of the ID tokens.
+}
+
+// Voter is an entry of the roll of an IdentityConfig.
+type Voter struct {
+	ID       uint32 // ID is the user identifier used in elections.
+	Name     string `protobuf:"opt"` // Name is the full name of the user.
+	Email    string `protobuf:"opt"` // Email is matched against the OIDC claim.
+	Identity string `protobuf:"opt"` // Identity is the darc identity of the user.
+}
+
+// Verify checks that the configuration can be used by an identity provider.
+func (c *IdentityConfig) Verify() error {
+	switch c.Provider {
+	case ProviderEPFL:
+		if len(c.Voters) > 0 {
+			return errors.New("the epfl provider has no roll")
+		}
+		return nil
+	case ProviderCSV, ProviderOIDC, ProviderDARC:
+	default:
+		return fmt.Errorf("unknown identity provider %q", c.Provider)
+	}
+	if c.Provider == ProviderOIDC && (c.Issuer == "" || c.ClientID == "") {
+		return errors.New("the oidc provider needs an issuer and a client ID")
+	}
+	if len(c.Voters) == 0 {
+		return errors.New("the roll is empty")
+	}
+	ids := make(map[uint32]bool)
+	for _, v := range c.Voters {
+		if ids[v.ID] {
+			return fmt.Errorf("user %d is twice in the roll", v.ID)
+		}
+		ids[v.ID] = true
+		if c.Provider == ProviderOIDC && v.Email == "" {
+			return fmt.Errorf("user %d has no email", v.ID)
+		}
+		if c.Provider == ProviderDARC {
+			if _, err := darc.ParseIdentity(v.Identity); err != nil {
+				return fmt.Errorf("identity of user %d: %v", v.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Voter returns the entry of the user in the roll, or nil if it is missing.
+func (c *IdentityConfig) Voter(user uint32) *Voter {
+	for i := range c.Voters {
+		if c.Voters[i].ID == user {
+			return &c.Voters[i]
+		}
+	}
+	return nil
 }
 
 // Link is a wrapper around the genesis Skipblock identifier of an
@@ -11,3 +11,36 @@ func TestIsAdmin(t *testing.T) {
 	assert.True(t, m.IsAdmin(0))
 	assert.False(t, m.IsAdmin(1))
 }
+
+func TestIdentityConfig_Verify(t *testing.T) {
+	c := &IdentityConfig{Provider: ProviderEPFL}
+	assert.Nil(t, c.Verify())
+	c.Voters = []Voter{{ID: 1}}
+	assert.NotNil(t, c.Verify())
+
+	c.Provider = ProviderCSV
+	assert.Nil(t, c.Verify())
+	assert.NotNil(t, c.Voter(1))
+	assert.Nil(t, c.Voter(2))
+	c.Voters = append(c.Voters, Voter{ID: 1})
+	assert.NotNil(t, c.Verify())
+	c.Voters = nil
+	assert.NotNil(t, c.Verify())
+
+	c.Provider = ProviderOIDC
+	c.Voters = []Voter{{ID: 1, Email: "a@example.com"}}
+	assert.NotNil(t, c.Verify())
+	c.Issuer = "https://accounts.example.com"
+	assert.NotNil(t, c.Verify())
+	c.ClientID = "evoting"
+	assert.Nil(t, c.Verify())
+	c.Voters = append(c.Voters, Voter{ID: 2})
+	assert.NotNil(t, c.Verify())
+
+	c.Provider = ProviderDARC
+	c.Voters = []Voter{{ID: 1, Identity: "ed25519:zz"}}
+	assert.NotNil(t, c.Verify())
+
+	c.Provider = "ldap"
+	assert.NotNil(t, c.Verify())
+}
@@ -128,6 +128,12 @@ func hashMap(h io.Writer, m map[string]string) {
 // Verify checks that the corresponding transaction is valid before storing it.
 func (t *Transaction) Verify(genesis skipchain.SkipBlockID, s *skipchain.Service) error {
 	if t.Master != nil {
+		if t.Master.Identity != nil {
+			if err := t.Master.Identity.Verify(); err != nil {
+				return fmt.Errorf("invalid identity config: %v", err)
+			}
+		}
+
 		// Find the current master in order to compare against it.
 		m, err := GetMaster(s, genesis)
 		if err != nil {
@@ -185,6 +191,9 @@ func (t *Transaction) Verify(genesis skipchain.SkipBlockID, s *skipchain.Service
 		if !master.IsAdmin(t.User) {
 			return errors.New("open error: user not admin")
 		}
+		if err := election.verifyBallotType(); err != nil {
+			return fmt.Errorf("open error: %v", err)
+		}
 		return nil
 	} else if t.Ballot != nil {
 		null := cothority.Suite.Point().Null()
@@ -273,12 +282,12 @@ func (t *Transaction) Verify(genesis skipchain.SkipBlockID, s *skipchain.Service
 		// check if Mix is valid
 		var x, y []kyber.Point
 		if len(mixes) == 0 {
-			// verify against Boxes
+			// verify against Boxes, with the ballots repeated by their weight
 			boxes, err := election.Box(s)
 			if err != nil {
 				return err
 			}
-			x, y = Split(boxes.Ballots)
+			x, y = Split(election.WeightedBallots(boxes))
 		} else {
 			// verify against the last mix
 			x, y = Split(mixes[len(mixes)-1].Ballots)
@@ -331,6 +340,15 @@ func (t *Transaction) Verify(genesis skipchain.SkipBlockID, s *skipchain.Service
 		if err != nil {
 			return err
 		}
+
+		// Elections opened before the commits were stored have no proofs.
+		if len(election.Commits) > 0 {
+			index, _ := election.Roster.Search(t.Partial.NodeID)
+			err = t.Partial.VerifyProofs(election.Commits, index, mixes[len(mixes)-1])
+			if err != nil {
+				return fmt.Errorf("decrypt error: %v", err)
+			}
+		}
 		return nil
 	}
 	return errors.New("transaction error: empty transaction")
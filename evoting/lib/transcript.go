@@ -0,0 +1,258 @@
+package lib
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/onet/v3/network"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/skipchain"
+)
+
+func init() {
+	network.RegisterMessage(&Transcript{})
+}
+
+// Transcript holds all the blocks of an election skipchain. The blocks hold
+// the election, the ballots, the mixes with their shuffle proofs and the
+// partials with their decryption proofs, so that the whole election can be
+// verified offline.
+type Transcript struct {
+	Blocks []*skipchain.SkipBlock // Blocks of the election skipchain, starting with the genesis block.
+}
+
+// TranscriptResult is the content of a verified transcript.
+type TranscriptResult struct {
+	Election *Election     // Election is the last configuration of the election.
+	Ballots  []*Ballot     // Ballots are the last ballots of each user.
+	Mixes    []*Mix        // Mixes are the verified shuffles.
+	Partials []*Partial    // Partials are the verified partial decryptions.
+	Points   []kyber.Point // Points are the decrypted ballots, nil if the election is not decrypted.
+	Tally    *Tally        // Tally is the result of the election, nil if it is not decrypted.
+}
+
+// GetTranscript returns the transcript of the election skipchain.
+func GetTranscript(s *skipchain.Service, id skipchain.SkipBlockID) (*Transcript, error) {
+	db := s.GetDB()
+	block := db.GetByID(id)
+	if block == nil {
+		return nil, errors.New("no such election skipchain")
+	}
+	if block.Index != 0 {
+		return nil, errors.New("not the genesis block of an election")
+	}
+	t := &Transcript{}
+	for {
+		t.Blocks = append(t.Blocks, block)
+		if len(block.ForwardLink) == 0 {
+			break
+		}
+		block = db.GetByID(block.ForwardLink[0].To)
+		if block == nil {
+			return nil, errors.New("missing block in the election skipchain")
+		}
+	}
+	return t, nil
+}
+
+// ID returns the ID of the election of the transcript.
+func (t *Transcript) ID() skipchain.SkipBlockID {
+	if len(t.Blocks) == 0 {
+		return nil
+	}
+	return t.Blocks[0].Hash
+}
+
+// Verify checks the links and signatures of the blocks, the signatures of
+// the transactions, every shuffle proof and every decryption proof. It
+// doesn't need network access, but the caller must check that the ID and the
+// roster of the election are the expected ones.
+func (t *Transcript) Verify() (*TranscriptResult, error) {
+	if err := t.verifyChain(); err != nil {
+		return nil, err
+	}
+
+	res := &TranscriptResult{}
+	var all []*Ballot
+	for _, block := range t.Blocks[1:] {
+		tx := UnmarshalTransaction(block.Data)
+		if tx == nil {
+			return nil, fmt.Errorf("block %d: invalid transaction", block.Index)
+		}
+		if err := res.add(block, tx, &all); err != nil {
+			return nil, fmt.Errorf("block %d: %v", block.Index, err)
+		}
+	}
+	if res.Election == nil {
+		return nil, errors.New("no election in the transcript")
+	}
+	if len(res.Mixes) == 0 {
+		res.Ballots = lastBallots(all)
+	}
+
+	if len(res.Partials) > 2*len(res.Election.Roster.List)/3 {
+		var err error
+		res.Points, err = Reconstruct(res.Election.Roster, res.Partials)
+		if err != nil {
+			return nil, err
+		}
+		res.Tally = res.Election.Tally(res.Points)
+	}
+	return res, nil
+}
+
+// verifyChain checks that the blocks form a skipchain signed by its roster.
+func (t *Transcript) verifyChain() error {
+	if len(t.Blocks) < 2 {
+		return errors.New("transcript needs at least the genesis and the election block")
+	}
+	for i, block := range t.Blocks {
+		if block.Index != i {
+			return fmt.Errorf("block %d has index %d", i, block.Index)
+		}
+		if !block.Hash.Equal(block.CalculateHash()) {
+			return fmt.Errorf("block %d: wrong hash", i)
+		}
+		if !block.SkipChainID().Equal(t.ID()) {
+			return fmt.Errorf("block %d is from another skipchain", i)
+		}
+		if i > 0 && (len(block.BackLinkIDs) == 0 ||
+			!block.BackLinkIDs[0].Equal(t.Blocks[i-1].Hash)) {
+			return fmt.Errorf("block %d doesn't link back to the previous block", i)
+		}
+		if i == len(t.Blocks)-1 {
+			break
+		}
+		if len(block.ForwardLink) == 0 ||
+			!block.ForwardLink[0].From.Equal(block.Hash) ||
+			!block.ForwardLink[0].To.Equal(t.Blocks[i+1].Hash) {
+			return fmt.Errorf("block %d doesn't link to the next block", i)
+		}
+		if err := block.VerifyForwardSignatures(); err != nil {
+			return fmt.Errorf("block %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// add verifies the transaction of the block against the content of the
+// transcript up to the block, and adds it.
+func (res *TranscriptResult) add(block *skipchain.SkipBlock, tx *Transaction, all *[]*Ballot) error {
+	switch {
+	case tx.Election != nil:
+		if err := verifyLeader(block, tx); err != nil {
+			return err
+		}
+		if len(*all) > 0 {
+			return errors.New("election changed after the first ballot")
+		}
+		if !tx.Election.ID.Equal(block.SkipChainID()) {
+			return errors.New("election is for another skipchain")
+		}
+		if tx.Election.Roster == nil || !tx.Election.Roster.ID.Equal(block.Roster.ID) {
+			return errors.New("election roster differs from the skipchain roster")
+		}
+		if len(tx.Election.Commits) > 0 && !tx.Election.Commits[0].Equal(tx.Election.Key) {
+			return errors.New("commits of the election don't match its key")
+		}
+		res.Election = tx.Election
+	case tx.Ballot != nil:
+		if err := verifyLeader(block, tx); err != nil {
+			return err
+		}
+		if res.Election == nil || len(res.Mixes) > 0 {
+			return errors.New("ballot outside of the running stage")
+		}
+		if tx.Ballot.User != tx.User || !res.Election.IsUser(tx.User) {
+			return errors.New("ballot of an invalid user")
+		}
+		*all = append(*all, tx.Ballot)
+	case tx.Mix != nil:
+		if res.Election == nil || len(res.Partials) > 0 {
+			return errors.New("mix outside of the shuffle stage")
+		}
+		if len(res.Mixes) == 0 {
+			res.Ballots = lastBallots(*all)
+		}
+		if err := res.verifyNode(tx, tx.Mix.NodeID, tx.Mix.Signature); err != nil {
+			return err
+		}
+		for _, mix := range res.Mixes {
+			if mix.NodeID.Equal(tx.Mix.NodeID) {
+				return errors.New("node already proposed a mix")
+			}
+		}
+		var x, y []kyber.Point
+		if len(res.Mixes) == 0 {
+			x, y = Split(res.Election.WeightedBallots(&Box{Ballots: res.Ballots}))
+		} else {
+			x, y = Split(res.Mixes[len(res.Mixes)-1].Ballots)
+		}
+		v, w := Split(tx.Mix.Ballots)
+		if len(v) != len(x) {
+			return errors.New("mix has a different number of ballots")
+		}
+		if err := Verify(tx.Mix.Proof, res.Election.Key, x, y, v, w); err != nil {
+			return fmt.Errorf("wrong shuffle: %v", err)
+		}
+		res.Mixes = append(res.Mixes, tx.Mix)
+	case tx.Partial != nil:
+		if res.Election == nil ||
+			len(res.Mixes) <= 2*len(res.Election.Roster.List)/3 {
+			return errors.New("partial before the end of the shuffle")
+		}
+		if err := res.verifyNode(tx, tx.Partial.NodeID, tx.Partial.Signature); err != nil {
+			return err
+		}
+		for _, partial := range res.Partials {
+			if partial.NodeID.Equal(tx.Partial.NodeID) {
+				return errors.New("node already proposed a partial")
+			}
+		}
+		if len(res.Election.Commits) > 0 {
+			index, _ := res.Election.Roster.Search(tx.Partial.NodeID)
+			err := tx.Partial.VerifyProofs(res.Election.Commits, index,
+				res.Mixes[len(res.Mixes)-1])
+			if err != nil {
+				return err
+			}
+		}
+		res.Partials = append(res.Partials, tx.Partial)
+	default:
+		return errors.New("unexpected transaction")
+	}
+	return nil
+}
+
+// verifyNode checks that the mix or partial was requested by the creator of
+// the election and is signed by a node of the roster.
+func (res *TranscriptResult) verifyNode(tx *Transaction, id network.ServerIdentityID, sig []byte) error {
+	if !res.Election.IsCreator(tx.User) {
+		return errors.New("user is not the election creator")
+	}
+	_, node := res.Election.Roster.Search(id)
+	if node == nil {
+		return errors.New("node is not in the roster")
+	}
+	data, err := node.Public.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return schnorr.Verify(cothority.Suite, node.Public, data, sig)
+}
+
+// verifyLeader checks the signature of the leader on a transaction it stored.
+func verifyLeader(block *skipchain.SkipBlock, tx *Transaction) error {
+	msg := make([]byte, 8)
+	binary.LittleEndian.PutUint64(msg, uint64(block.Index))
+	msg = append(msg, tx.Hash()...)
+	err := schnorr.Verify(cothority.Suite, block.Roster.List[0].Public, msg, tx.Signature)
+	if err != nil {
+		return fmt.Errorf("wrong signature of the leader: %v", err)
+	}
+	return nil
+}
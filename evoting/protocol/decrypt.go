@@ -5,7 +5,6 @@ import (
 	"sync"
 
 	"go.dedis.ch/cothority/v3"
-	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/sign/schnorr"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/network"
@@ -101,10 +100,6 @@ func (d *Decrypt) HandlePrompt(prompt MessagePromptDecrypt) error {
 	if !d.IsRoot() || d.LeaderParticipates {
 		err := func() error {
 			mix := mixes[len(mixes)-1]
-			points := make([]kyber.Point, len(mix.Ballots))
-			for i := range points {
-				points[i] = lib.Decrypt(d.Secret.V, mix.Ballots[i].Alpha, mix.Ballots[i].Beta)
-			}
 			index := -1
 			for i, node := range d.Election.Roster.List {
 				if node.Public.Equal(d.Public()) {
@@ -116,10 +111,12 @@ func (d *Decrypt) HandlePrompt(prompt MessagePromptDecrypt) error {
 				return d.SendTo(d.Root(), &TerminateDecrypt{Error: "couldn't find index in Roster"})
 			}
 
-			partial = &lib.Partial{
-				Points: points,
-				NodeID: d.ServerIdentity().ID,
+			var err error
+			partial, err = lib.NewPartial(d.Secret, mix)
+			if err != nil {
+				return err
 			}
+			partial.NodeID = d.ServerIdentity().ID
 			data, err := d.ServerIdentity().Public.MarshalBinary()
 			if err != nil {
 				return d.SendTo(d.Root(), &TerminateDecrypt{Error: err.Error()})
@@ -92,7 +92,7 @@ func (s *Shuffle) HandlePrompt(prompt MessagePrompt) error {
 			if err != nil {
 				return err
 			}
-			ballots = box.Ballots
+			ballots = s.Election.WeightedBallots(box)
 		} else {
 			ballots = mixes[len(mixes)-1].Ballots
 		}
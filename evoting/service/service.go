@@ -13,17 +13,16 @@ import (
 	"time"
 
 	"go.dedis.ch/kyber/v3"
-	"go.dedis.ch/kyber/v3/share"
 	"go.dedis.ch/kyber/v3/sign/schnorr"
 	"go.dedis.ch/kyber/v3/util/random"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
 
-	"github.com/go-ldap/ldap/v3"
 	"go.dedis.ch/cothority/v3"
 	dkgprotocol "go.dedis.ch/cothority/v3/dkg/rabin"
 	"go.dedis.ch/cothority/v3/evoting"
+	"go.dedis.ch/cothority/v3/evoting/identity"
 	"go.dedis.ch/cothority/v3/evoting/lib"
 	"go.dedis.ch/cothority/v3/evoting/protocol"
 	"go.dedis.ch/cothority/v3/skipchain"
@@ -85,6 +84,11 @@ func (s *Service) Link(req *evoting.Link) (*evoting.LinkReply, error) {
 	if req.Pin != s.pin {
 		return nil, errors.New("link error: invalid pin")
 	}
+	if req.Identity != nil {
+		if err := req.Identity.Verify(); err != nil {
+			return nil, fmt.Errorf("link error: %v", err)
+		}
+	}
 
 	var id skipchain.SkipBlockID
 	var user uint32
@@ -101,7 +105,7 @@ func (s *Service) Link(req *evoting.Link) (*evoting.LinkReply, error) {
 		}
 		user = *req.User
 
-		err = auth(*req.User, *req.Signature, m.ID, m.Key)
+		err = auth(*req.User, *req.Signature, m)
 		if err != nil {
 			return nil, err
 		}
@@ -116,10 +120,11 @@ func (s *Service) Link(req *evoting.Link) (*evoting.LinkReply, error) {
 	}
 
 	master := &lib.Master{
-		ID:     id,
-		Roster: req.Roster,
-		Admins: req.Admins,
-		Key:    req.Key,
+		ID:       id,
+		Roster:   req.Roster,
+		Admins:   req.Admins,
+		Key:      req.Key,
+		Identity: req.Identity,
 	}
 	transaction := lib.NewTransaction(master, user)
 	if _, err := lib.Store(s.skipchain, master.ID, transaction, s.ServerIdentity().GetPrivate()); err != nil {
@@ -145,7 +150,7 @@ func (s *Service) Open(req *evoting.Open) (*evoting.OpenReply, error) {
 		return nil, errOnlyLeader
 	}
 
-	err = auth(req.User, req.Signature, master.ID, master.Key)
+	err = auth(req.User, req.Signature, master)
 	if err != nil {
 		return nil, err
 	}
@@ -218,6 +223,7 @@ func (s *Service) Open(req *evoting.Open) (*evoting.OpenReply, error) {
 		req.Election.Master = req.ID
 		req.Election.Roster = master.Roster
 		req.Election.Key = secret.X
+		req.Election.Commits = secret.Commits
 		req.Election.MasterKey = master.Key
 		req.Election.Creator = req.User
 
@@ -309,74 +315,78 @@ func (s *Service) sciperPut(sciper int, reply *evoting.LookupSciperReply) {
 	return
 }
 
-// LookupSciper calls https://people.epfl.ch/cgi-bin/people/vCard?id=sciper
-// to convert Sciper numbers to names.
+// LookupSciper looks up the name and email of a user with the identity
+// provider of the master chain. For EPFL, it searches the SCIPER number in
+// the EPFL LDAP directory.
 func (s *Service) LookupSciper(req *evoting.LookupSciper) (*evoting.LookupSciperReply, error) {
-	if len(req.Sciper) != 6 {
+	p, err := s.provider()
+	if err != nil {
+		return nil, err
+	}
+	epfl, isEPFL := p.(*identity.EPFL)
+	if isEPFL && len(req.Sciper) != 6 {
 		return nil, errors.New("sciper should be 6 digits only")
 	}
-	sciper, err := strconv.Atoi(req.Sciper)
+	sciper, err := strconv.ParseUint(req.Sciper, 10, 32)
 	if err != nil {
 		return nil, errors.New("couldn't convert Sciper to integer")
 	}
 
 	// Try to find it in cache first
-	if res := s.sciperGet(sciper); res != nil {
+	if res := s.sciperGet(int(sciper)); res != nil {
 		log.Lvl3("Got vcard (cache hit)", res)
 		return res, nil
 	}
 
-	url := "ldaps://ldap.epfl.ch"
-	if req.LookupURL != "" {
-		url = req.LookupURL
+	if isEPFL {
+		epfl.URL = req.LookupURL
 	}
-	l, err := ldap.DialURL(url)
+	info, err := p.Lookup(uint32(sciper))
 	if err != nil {
 		return nil, err
 	}
-	defer l.Close()
-
-	// Search for the given username
-	searchRequest := ldap.NewSearchRequest(
-		"o=epfl, c=ch",
-		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-		fmt.Sprintf("(&(objectClass=person)(uniqueIdentifier=%d))", sciper),
-		[]string{"displayName", "mail"},
-		nil,
-	)
-
-	sr, err := l.Search(searchRequest)
-	if err != nil {
-		return nil, err
-	}
-
-	// If no results, err.
-	// If more than one are returned, we look only at the first one.
-	if len(sr.Entries) == 0 {
-		return nil, errors.New("SCIPER not found")
-	}
-
-	reply := &evoting.LookupSciperReply{}
-	reply.FullName = sr.Entries[0].GetAttributeValue("displayName")
-	reply.Email = sr.Entries[0].GetAttributeValue("mail")
+	reply := &evoting.LookupSciperReply{FullName: info.FullName, Email: info.Email}
 
 	// Put it into the cache
-	s.sciperPut(sciper, reply)
+	s.sciperPut(int(sciper), reply)
 
 	log.Lvl3("Got vcard (cache miss): ", reply)
 	return reply, nil
 }
 
-func auth(u uint32, sig []byte, master skipchain.SkipBlockID, pub kyber.Point) error {
-	var message []byte
-	message = append(message, master...)
+// provider returns the identity provider of the master chain of the service.
+func (s *Service) provider() (identity.Provider, error) {
+	s.mutex.Lock()
+	id := s.storage.Master
+	s.mutex.Unlock()
+	if len(id) == 0 {
+		return &identity.EPFL{}, nil
+	}
+	m, err := lib.GetMaster(s.skipchain, id)
+	if err != nil {
+		return nil, err
+	}
+	return identity.New(m)
+}
 
-	for _, c := range strconv.Itoa(int(u)) {
-		d, _ := strconv.Atoi(string(c))
-		message = append(message, byte(d))
+// auth verifies the credential of the user with the identity provider of the
+// master chain.
+func auth(u uint32, sig []byte, m *lib.Master) error {
+	p, err := identity.New(m)
+	if err != nil {
+		return err
 	}
+	return p.Authenticate(u, sig)
+}
 
-	return schnorr.Verify(cothority.Suite, pub, message, sig)
+// authElection verifies the credential of the user with the identity
+// provider of the master chain of the election.
+func (s *Service) authElection(u uint32, sig []byte, e *lib.Election) error {
+	m, err := lib.GetMaster(s.skipchain, e.Master)
+	if err != nil {
+		return err
+	}
+	return auth(u, sig, m)
 }
 
 // Cast message handler. Cast a ballot in a given election.
@@ -389,7 +399,7 @@ func (s *Service) Cast(req *evoting.Cast) (*evoting.CastReply, error) {
 	if err != nil {
 		return nil, fmt.Errorf("could not cast ballot on election %x for user %v: %v", req.ID, req.User, err)
 	}
-	err = auth(req.User, req.Signature, election.Master, election.MasterKey)
+	err = s.authElection(req.User, req.Signature, election)
 	if err != nil {
 		return nil, fmt.Errorf("could not cast ballot on election %x for user %v: %v", req.ID, req.User, err)
 	}
@@ -416,7 +426,7 @@ func (s *Service) GetElections(req *evoting.GetElections) (*evoting.GetElections
 	}
 
 	userValid := false
-	err = auth(req.User, req.Signature, master.ID, master.Key)
+	err = auth(req.User, req.Signature, master)
 	if err == nil {
 		userValid = true
 	}
@@ -500,7 +510,7 @@ func (s *Service) Shuffle(req *evoting.Shuffle) (*evoting.ShuffleReply, error) {
 		return nil, err
 	}
 
-	err = auth(req.User, req.Signature, election.Master, election.MasterKey)
+	err = s.authElection(req.User, req.Signature, election)
 	if err != nil {
 		return nil, err
 	}
@@ -577,7 +587,7 @@ func (s *Service) Decrypt(req *evoting.Decrypt) (*evoting.DecryptReply, error) {
 		return nil, err
 	}
 
-	err = auth(req.User, req.Signature, election.Master, election.MasterKey)
+	err = s.authElection(req.User, req.Signature, election)
 	if err != nil {
 		return nil, err
 	}
@@ -664,25 +674,22 @@ func (s *Service) Reconstruct(req *evoting.Reconstruct) (*evoting.ReconstructRep
 		return nil, errors.New("reconstruct error, election not closed yet")
 	}
 
-	points := make([]kyber.Point, 0)
+	points, err := lib.Reconstruct(election.Roster, partials)
+	if err != nil {
+		return nil, err
+	}
 
-	n := len(election.Roster.List)
-	for i := 0; i < len(partials[0].Points); i++ {
-		shares := make([]*share.PubShare, n)
-		for _, partial := range partials {
-			j, _ := election.Roster.Search(partial.NodeID)
-			shares[j] = &share.PubShare{I: j, V: partial.Points[i]}
-		}
+	return &evoting.ReconstructReply{Points: points, Tally: election.Tally(points)}, nil
+}
 
-		log.Lvl3("Recovering commits", i)
-		message, err := share.RecoverCommit(cothority.Suite, shares, 2*n/3+1, n)
-		if err != nil {
-			return nil, err
-		}
-		points = append(points, message)
+// ExportTranscript message handler. Returns all the blocks of the election
+// skipchain, so that the election can be verified offline.
+func (s *Service) ExportTranscript(req *evoting.ExportTranscript) (*evoting.ExportTranscriptReply, error) {
+	transcript, err := lib.GetTranscript(s.skipchain, req.ID)
+	if err != nil {
+		return nil, err
 	}
-
-	return &evoting.ReconstructReply{Points: points}, nil
+	return &evoting.ExportTranscriptReply{Transcript: transcript}, nil
 }
 
 // NewProtocol hooks non-root nodes into created protocols.
@@ -888,6 +895,7 @@ func new(context *onet.Context) (onet.Service, error) {
 		service.GetPartials,
 		service.Decrypt,
 		service.Reconstruct,
+		service.ExportTranscript,
 		service.LookupSciper,
 	)
 	skipchain.RegisterVerification(context, lib.TransactionVerifierID, service.verify)
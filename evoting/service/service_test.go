@@ -305,6 +305,28 @@ func TestService(t *testing.T) {
 	for _, p := range reconstructReply.Points {
 		log.Lvl2("Point is:", p.String())
 	}
+
+	// Export the transcript and verify it offline.
+	transcriptReply, err := s1.ExportTranscript(&evoting.ExportTranscript{ID: replyOpen.ID})
+	require.NoError(t, err)
+	buf, err := network.Marshal(transcriptReply.Transcript)
+	require.NoError(t, err)
+	_, msg, err := network.Unmarshal(buf, cothority.Suite)
+	require.NoError(t, err)
+	transcript := msg.(*lib.Transcript)
+	res, err := transcript.Verify()
+	require.NoError(t, err)
+	require.Equal(t, 3, len(res.Ballots))
+	require.Equal(t, len(reconstructReply.Points), len(res.Points))
+	for i := range res.Points {
+		require.True(t, reconstructReply.Points[i].Equal(res.Points[i]))
+	}
+	require.NotEqual(t, 0, len(res.Election.Commits))
+
+	last := transcript.Blocks[len(transcript.Blocks)-1]
+	last.Data[len(last.Data)-1]++
+	_, err = transcript.Verify()
+	require.Error(t, err)
 }
 
 // This is an end-to end test, just like TestService, so it has a lot of copy-paste
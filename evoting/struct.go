@@ -22,16 +22,18 @@ func init() {
 	network.RegisterMessages(GetMixes{}, GetMixesReply{})
 	network.RegisterMessages(GetPartials{}, GetPartialsReply{})
 	network.RegisterMessages(Reconstruct{}, ReconstructReply{})
+	network.RegisterMessages(ExportTranscript{}, ExportTranscriptReply{})
 }
 
-// LookupSciper takes a SCIPER number and looks up the full name.
+// LookupSciper takes a user identifier and looks up the full name with the
+// identity provider of the master chain. For EPFL, it is a SCIPER number.
 type LookupSciper struct {
 	Sciper string
 	// If LookupURL is set, use it instead of the default (for testing).
 	LookupURL string
 }
 
-// LookupSciperReply returns user info, as looked up via LDAP.
+// LookupSciperReply returns user info, as looked up via LDAP or in the roll.
 type LookupSciperReply struct {
 	FullName string
 	Email    string
@@ -48,6 +50,7 @@ type Link struct {
 	ID        *skipchain.SkipBlockID // ID of the master skipchain to update; optional.
 	User      *uint32                // User identifier; optional (required with ID).
 	Signature *[]byte                // Signature authenticating the message; optional (required with ID).
+	Identity  *lib.IdentityConfig    // Identity configures the identity provider; optional (EPFL if nil).
 }
 
 // LinkReply message.
@@ -161,6 +164,17 @@ type Reconstruct struct {
 // ReconstructReply message.
 type ReconstructReply struct {
 	Points []kyber.Point // Points are the decrypted plaintexts.
+	Tally  *lib.Tally    // Tally is the result computed from the plaintexts.
+}
+
+// ExportTranscript message.
+type ExportTranscript struct {
+	ID skipchain.SkipBlockID // ID of the election skipchain.
+}
+
+// ExportTranscriptReply message.
+type ExportTranscriptReply struct {
+	Transcript *lib.Transcript // Transcript holds all blocks of the election skipchain.
 }
 
 // Ping message.
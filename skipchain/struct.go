@@ -1292,6 +1292,27 @@ func (db *SkipBlockDB) RemoveBlock(blockID SkipBlockID) error {
 	})
 }
 
+// PruneBlock removes the payload of the given block, but keeps the rest of
+// it. As the payload is not part of the hash of the block, the block and its
+// forward links can still be verified, and proofs going through it stay
+// valid. It returns an error if the block is not found.
+func (db *SkipBlockDB) PruneBlock(blockID SkipBlockID) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		sb, err := db.getFromTx(tx, blockID)
+		if err != nil {
+			return xerrors.Errorf("reading block: %v", err)
+		}
+		if sb == nil {
+			return xerrors.New("block not found")
+		}
+		if len(sb.Payload) == 0 {
+			return nil
+		}
+		sb.Payload = nil
+		return db.storeToTx(tx, sb)
+	})
+}
+
 // storeToTx stores the skipblock into the database.
 // An error is returned on failure.
 // The caller must ensure that this function is called from within a valid transaction.
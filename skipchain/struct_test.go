@@ -418,6 +418,29 @@ func TestSkipBlockDB_GetProof(t *testing.T) {
 	require.Error(t, err)
 }
 
+// Checks that pruning a block only removes its payload.
+func TestSkipBlockDB_PruneBlock(t *testing.T) {
+	db, file := setupSkipBlockDB(t)
+	defer os.Remove(file)
+
+	sb := NewSkipBlock()
+	sb.Data = []byte("header")
+	sb.Payload = []byte("body")
+	sb.updateHash()
+	db.Store(sb)
+
+	require.Error(t, db.PruneBlock(SkipBlockID{1, 2, 3}))
+	require.NoError(t, db.PruneBlock(sb.Hash))
+	// Pruning twice is not a problem.
+	require.NoError(t, db.PruneBlock(sb.Hash))
+
+	pruned := db.GetByID(sb.Hash)
+	require.NotNil(t, pruned)
+	require.Empty(t, pruned.Payload)
+	require.Equal(t, sb.Data, pruned.Data)
+	require.True(t, pruned.CalculateHash().Equal(sb.Hash))
+}
+
 // Test the edge cases of the verification function
 func TestProof_Verify(t *testing.T) {
 	sb := NewSkipBlock()